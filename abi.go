@@ -0,0 +1,487 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package llgo
+
+import (
+	"github.com/axw/gollvm/llvm"
+	"strings"
+)
+
+// Functions called from or by C code must follow the target's C ABI:
+// those declared with "//extern", and the entry points created for those
+// marked with "//export". Other functions pass and return aggregates as
+// LLVM first class values, which only matches the C ABI for scalars, so
+// the signatures of the C functions are lowered, and their callers and
+// entry points convert between the two.
+//
+// Aggregate results are lowered as follows:
+//
+//   - x86-64 System V: aggregates of up to 16 bytes are returned in
+//     registers, coerced to a type whose elements are the classes of each
+//     eightbyte: an integer for those containing an integer or pointer,
+//     and otherwise a float, double or <2 x float>. Larger aggregates are
+//     returned in memory provided by the caller, through a pointer passed
+//     as a hidden first parameter with the sret attribute.
+//   - Win64: aggregates of 1, 2, 4 or 8 bytes are returned as an integer
+//     of the same size, and others in memory, as for System V.
+//   - Other targets: aggregates are returned unchanged.
+//
+// Aggregate parameters are lowered as follows:
+//
+//   - x86-64 System V: aggregates of up to 16 bytes are passed in
+//     registers, coerced as for results, with each eightbyte passed as a
+//     separate parameter, if enough of the six integer and eight SSE
+//     registers remain. Otherwise, they are passed in memory on the
+//     stack, through a pointer with the byval attribute.
+//   - Win64: aggregates of 1, 2, 4 or 8 bytes are passed as an integer of
+//     the same size, and others by a pointer to a copy made by the caller.
+//   - Other targets: aggregates are passed unchanged.
+
+// abiKind identifies how a value is passed to or returned from a C
+// function.
+type abiKind int
+
+const (
+	abiDirect   abiKind = iota // as its LLVM type
+	abiCoerce                  // as another type, through memory
+	abiIndirect                // in memory, by pointer
+)
+
+// abiInfo describes how a value is passed to or returned from a C
+// function.
+type abiInfo struct {
+	kind    abiKind
+	typ     llvm.Type // the value's LLVM type
+	coerced llvm.Type // the type passed, for abiCoerce
+	byval   bool      // whether an abiIndirect parameter is passed byval
+	index   int       // the index of a parameter's first C parameter
+}
+
+// cParams returns the types of the C parameters that pass a parameter.
+func (info abiInfo) cParams() []llvm.Type {
+	switch info.kind {
+	case abiCoerce:
+		if info.coerced.TypeKind() == llvm.StructTypeKind {
+			return info.coerced.StructElementTypes()
+		}
+		return []llvm.Type{info.coerced}
+	case abiIndirect:
+		return []llvm.Type{llvm.PointerType(info.typ, 0)}
+	}
+	return []llvm.Type{info.typ}
+}
+
+// cFunctionType describes the lowering of a function type to the C ABI.
+type cFunctionType struct {
+	gotyp  llvm.Type // the function type used by Go code
+	ctyp   llvm.Type // the C function type
+	result abiInfo
+	params []abiInfo
+}
+
+// lowered reports whether the C function type differs from the Go one.
+func (f *cFunctionType) lowered() bool {
+	return f.ctyp != f.gotyp
+}
+
+// cFunctionType lowers a function type, as used by Go code, to the C ABI.
+func (c *compiler) cFunctionType(gotyp llvm.Type) *cFunctionType {
+	f := &cFunctionType{gotyp: gotyp}
+	var params []llvm.Type
+	result := gotyp.ReturnType()
+	f.result = c.classifyResult(result)
+	regs := x86_64Registers{ints: 6, sses: 8}
+	switch f.result.kind {
+	case abiCoerce:
+		result = f.result.coerced
+	case abiIndirect:
+		params = append(params, llvm.PointerType(result, 0))
+		result = c.context.VoidType()
+		regs.ints--
+	}
+	lowered := f.result.kind != abiDirect
+	for _, typ := range gotyp.ParamTypes() {
+		info := c.classifyParam(typ, &regs)
+		info.index = len(params)
+		f.params = append(f.params, info)
+		params = append(params, info.cParams()...)
+		lowered = lowered || info.kind != abiDirect
+	}
+	if lowered {
+		f.ctyp = llvm.FunctionType(result, params, gotyp.IsFunctionVarArg())
+	} else {
+		f.ctyp = gotyp
+	}
+	return f
+}
+
+// isWin64 reports whether the target uses the Win64 ABI.
+func (c *compiler) isWin64() bool {
+	return c.targetArch == "x86-64" && (c.targetOs == "win32" || strings.HasPrefix(c.targetOs, "mingw"))
+}
+
+// classifyResult determines how a result of the specified type is
+// returned from a C function.
+func (c *compiler) classifyResult(typ llvm.Type) abiInfo {
+	info := abiInfo{kind: abiDirect, typ: typ}
+	switch typ.TypeKind() {
+	case llvm.StructTypeKind, llvm.ArrayTypeKind:
+	default:
+		return info
+	}
+	size := c.target.TypeAllocSize(typ)
+	if size == 0 || c.targetArch != "x86-64" {
+		return info
+	}
+	if c.isWin64() {
+		switch size {
+		case 1, 2, 4, 8:
+			info.kind = abiCoerce
+			info.coerced = c.context.IntType(int(size) * 8)
+		default:
+			info.kind = abiIndirect
+		}
+		return info
+	}
+	if coerced, ok := c.classifyX86_64(typ); ok {
+		info.kind = abiCoerce
+		info.coerced = coerced
+	} else {
+		info.kind = abiIndirect
+	}
+	return info
+}
+
+// x86_64Registers counts the registers that remain for passing the
+// parameters of a function, on x86-64 System V.
+type x86_64Registers struct {
+	ints, sses int
+}
+
+// classifyParam determines how a parameter of the specified type is
+// passed to a C function, allocating it registers from regs.
+func (c *compiler) classifyParam(typ llvm.Type, regs *x86_64Registers) abiInfo {
+	info := abiInfo{kind: abiDirect, typ: typ}
+	switch typ.TypeKind() {
+	case llvm.StructTypeKind, llvm.ArrayTypeKind:
+	case llvm.FloatTypeKind, llvm.DoubleTypeKind:
+		regs.sses--
+		return info
+	default:
+		regs.ints--
+		return info
+	}
+	size := c.target.TypeAllocSize(typ)
+	if size == 0 || c.targetArch != "x86-64" {
+		return info
+	}
+	if c.isWin64() {
+		switch size {
+		case 1, 2, 4, 8:
+			info.kind = abiCoerce
+			info.coerced = c.context.IntType(int(size) * 8)
+		default:
+			info.kind = abiIndirect
+		}
+		return info
+	}
+	if coerced, ok := c.classifyX86_64(typ); ok {
+		info.kind = abiCoerce
+		info.coerced = coerced
+		ints, sses := 0, 0
+		for _, t := range info.cParams() {
+			if t.TypeKind() == llvm.IntegerTypeKind {
+				ints++
+			} else {
+				sses++
+			}
+		}
+		if ints <= regs.ints && sses <= regs.sses {
+			regs.ints -= ints
+			regs.sses -= sses
+			return info
+		}
+	}
+	// An aggregate is passed in memory as a whole, if it does not fit
+	// in the remaining registers.
+	info.kind = abiIndirect
+	info.byval = true
+	info.coerced = llvm.Type{}
+	return info
+}
+
+// abiScalar is a scalar within an aggregate, at the specified offset.
+type abiScalar struct {
+	offset uint64
+	typ    llvm.Type
+}
+
+// scalars appends the scalars within a value of the specified type to
+// list, returning false if the type contains one that cannot be
+// classified.
+func (c *compiler) scalars(typ llvm.Type, offset uint64, list *[]abiScalar) bool {
+	switch typ.TypeKind() {
+	case llvm.StructTypeKind:
+		for i, elem := range typ.StructElementTypes() {
+			if !c.scalars(elem, offset+c.target.ElementOffset(typ, i), list) {
+				return false
+			}
+		}
+	case llvm.ArrayTypeKind:
+		elem := typ.ElementType()
+		size := c.target.TypeAllocSize(elem)
+		for i := 0; i < typ.ArrayLength(); i++ {
+			if !c.scalars(elem, offset+uint64(i)*size, list) {
+				return false
+			}
+		}
+	case llvm.IntegerTypeKind, llvm.PointerTypeKind, llvm.FloatTypeKind, llvm.DoubleTypeKind:
+		*list = append(*list, abiScalar{offset, typ})
+	default:
+		return false
+	}
+	return true
+}
+
+// classifyX86_64 classifies an aggregate for the x86-64 System V ABI,
+// returning the type to which it is coerced to be passed in registers,
+// or false if it is passed in memory.
+func (c *compiler) classifyX86_64(typ llvm.Type) (llvm.Type, bool) {
+	size := c.target.TypeAllocSize(typ)
+	var list []abiScalar
+	if size > 16 || !c.scalars(typ, 0, &list) {
+		return llvm.Type{}, false
+	}
+	var eightbytes []llvm.Type
+	for start := uint64(0); start < size; start += 8 {
+		var isint bool
+		var floats []llvm.Type
+		for _, s := range list {
+			if s.offset < start || s.offset >= start+8 {
+				continue
+			}
+			switch s.typ.TypeKind() {
+			case llvm.FloatTypeKind, llvm.DoubleTypeKind:
+				floats = append(floats, s.typ)
+			default:
+				isint = true
+			}
+		}
+		var t llvm.Type
+		switch {
+		case isint || len(floats) == 0:
+			n := size - start
+			if n > 8 {
+				n = 8
+			}
+			t = c.context.IntType(int(n) * 8)
+		case len(floats) == 1:
+			t = floats[0]
+		default:
+			t = llvm.VectorType(c.context.FloatType(), 2)
+		}
+		eightbytes = append(eightbytes, t)
+	}
+	if len(eightbytes) == 1 {
+		return eightbytes[0], true
+	}
+	return c.context.StructType(eightbytes, false), true
+}
+
+// coerce converts a value to another type, by storing it to memory and
+// loading it as the other type.
+func (c *compiler) coerce(v llvm.Value, typ llvm.Type) llvm.Value {
+	memtyp := v.Type()
+	if c.target.TypeAllocSize(typ) > c.target.TypeAllocSize(memtyp) {
+		memtyp = typ
+	}
+	mem := c.createEntryAlloca(memtyp, "")
+	align := c.target.ABITypeAlignment(v.Type())
+	if a := c.target.ABITypeAlignment(typ); a > align {
+		align = a
+	}
+	mem.SetAlignment(align)
+	c.builder.CreateStore(v, c.builder.CreateBitCast(mem, llvm.PointerType(v.Type(), 0), ""))
+	return c.builder.CreateLoad(c.builder.CreateBitCast(mem, llvm.PointerType(typ, 0), ""), "")
+}
+
+// declareCFunction declares the C function with the specified name and
+// Go function type. If the function's type is lowered, the C function is
+// called through a private function with the Go type, which is returned.
+func (c *compiler) declareCFunction(name string, gotyp llvm.Type) llvm.Value {
+	f := c.cFunctionType(gotyp)
+	fn := c.module.NamedFunction(name)
+	if fn.IsNil() || fn.Type().ElementType() != f.ctyp {
+		fn = llvm.AddFunction(c.module.Module, name, f.ctyp)
+		fn.SetFunctionCallConv(llvm.CCallConv)
+		f.setAttributes(fn)
+	}
+	if !f.lowered() {
+		return fn
+	}
+
+	defer c.restoreInsertPoint(c.saveInsertPoint())
+	wrapper := llvm.AddFunction(c.module.Module, "", gotyp)
+	wrapper.SetLinkage(llvm.PrivateLinkage)
+	entry := c.context.AddBasicBlock(wrapper, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	c.builder.SetCurrentDebugLocation(llvm.Value{})
+	args := c.cArgs(f, wrapper.Params())
+	var sret llvm.Value
+	if f.result.kind == abiIndirect {
+		sret = c.createEntryAlloca(f.result.typ, "")
+		args = append([]llvm.Value{sret}, args...)
+	}
+	call := c.builder.CreateCall(fn, args, "")
+	call.SetInstructionCallConv(llvm.CCallConv)
+	f.setCallAttributes(call)
+	switch f.result.kind {
+	case abiDirect:
+		if f.result.typ.TypeKind() == llvm.VoidTypeKind {
+			c.builder.CreateRetVoid()
+		} else {
+			c.builder.CreateRet(call)
+		}
+	case abiCoerce:
+		c.builder.CreateRet(c.coerce(call, f.result.typ))
+	case abiIndirect:
+		c.builder.CreateRet(c.builder.CreateLoad(sret, ""))
+	}
+	return wrapper
+}
+
+// defineCFunction defines a C function with the specified name, which
+// calls the Go function fn, after calling each of the functions in
+// prologue.
+func (c *compiler) defineCFunction(name string, gofn llvm.Value, prologue ...llvm.Value) llvm.Value {
+	f := c.cFunctionType(gofn.Type().ElementType())
+	fn := llvm.AddFunction(c.module.Module, name, f.ctyp)
+	fn.SetFunctionCallConv(llvm.CCallConv)
+	f.setAttributes(fn)
+	entry := c.context.AddBasicBlock(fn, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	for _, pfn := range prologue {
+		c.builder.CreateCall(pfn, nil, "")
+	}
+	params := fn.Params()
+	var sret llvm.Value
+	if f.result.kind == abiIndirect {
+		sret = params[0]
+	}
+	result := c.builder.CreateCall(gofn, c.goArgs(f, params), "")
+	switch f.result.kind {
+	case abiDirect:
+		if f.result.typ.TypeKind() == llvm.VoidTypeKind {
+			c.builder.CreateRetVoid()
+		} else {
+			c.builder.CreateRet(result)
+		}
+	case abiCoerce:
+		c.builder.CreateRet(c.coerce(result, f.result.coerced))
+	case abiIndirect:
+		c.builder.CreateStore(result, sret)
+		c.builder.CreateRetVoid()
+	}
+	return fn
+}
+
+// cArgs converts the arguments of a call to a C function from the Go
+// convention to the C ABI, excluding any sret pointer.
+func (c *compiler) cArgs(f *cFunctionType, goargs []llvm.Value) []llvm.Value {
+	var args []llvm.Value
+	for i, info := range f.params {
+		arg := goargs[i]
+		switch info.kind {
+		case abiDirect:
+			args = append(args, arg)
+		case abiCoerce:
+			arg = c.coerce(arg, info.coerced)
+			if info.coerced.TypeKind() != llvm.StructTypeKind {
+				args = append(args, arg)
+				break
+			}
+			for j := range info.coerced.StructElementTypes() {
+				args = append(args, c.builder.CreateExtractValue(arg, j, ""))
+			}
+		case abiIndirect:
+			mem := c.createEntryAlloca(info.typ, "")
+			c.builder.CreateStore(arg, mem)
+			args = append(args, mem)
+		}
+	}
+	return args
+}
+
+// goArgs converts the parameters of a C function, including any sret
+// pointer, to the arguments of a call to a Go function.
+func (c *compiler) goArgs(f *cFunctionType, params []llvm.Value) []llvm.Value {
+	args := make([]llvm.Value, len(f.params))
+	for i, info := range f.params {
+		switch info.kind {
+		case abiDirect:
+			args[i] = params[info.index]
+		case abiCoerce:
+			if info.coerced.TypeKind() != llvm.StructTypeKind {
+				args[i] = c.coerce(params[info.index], info.typ)
+				break
+			}
+			value := llvm.Undef(info.coerced)
+			for j := range info.coerced.StructElementTypes() {
+				value = c.builder.CreateInsertValue(value, params[info.index+j], j, "")
+			}
+			args[i] = c.coerce(value, info.typ)
+		case abiIndirect:
+			args[i] = c.builder.CreateLoad(params[info.index], "")
+		}
+	}
+	return args
+}
+
+// setAttributes sets the parameter attributes of a C function.
+func (f *cFunctionType) setAttributes(fn llvm.Value) {
+	if f.result.kind == abiIndirect {
+		fn.Param(0).AddAttribute(llvm.StructRetAttribute)
+		fn.Param(0).AddAttribute(llvm.NoAliasAttribute)
+	}
+	for _, info := range f.params {
+		if info.byval {
+			fn.Param(info.index).AddAttribute(llvm.ByValAttribute)
+		}
+	}
+}
+
+// setCallAttributes sets the parameter attributes of a call to a C
+// function. Attribute index 0 is the return value, so the parameters'
+// indices are offset by one.
+func (f *cFunctionType) setCallAttributes(call llvm.Value) {
+	if f.result.kind == abiIndirect {
+		call.AddInstrAttribute(1, llvm.StructRetAttribute)
+	}
+	for _, info := range f.params {
+		if info.byval {
+			call.AddInstrAttribute(info.index+1, llvm.ByValAttribute)
+		}
+	}
+}
+
+// vim: set ft=go :
@@ -0,0 +1,150 @@
+/*
+Copyright (c) 2011, 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package llgo
+
+import (
+	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo/types"
+	"go/ast"
+	"strconv"
+)
+
+// callIntrinsic generates the code that replaces a call to a function,
+// given the call's arguments, and returns the result of the call.
+type callIntrinsic func(c *compiler, args []ast.Expr) Value
+
+// callIntrinsics maps functions, keyed by package path and name, to the
+// code generators that replace calls to them. It is initialised by init,
+// as the code generators refer to it indirectly.
+var callIntrinsics map[string]callIntrinsic
+
+func init() {
+	callIntrinsics = map[string]callIntrinsic{
+		"math.Abs":      llvmMathIntrinsic("llvm.fabs.f64", 1),
+		"math.Ceil":     llvmMathIntrinsic("llvm.ceil.f64", 1),
+		"math.Copysign": llvmMathIntrinsic("llvm.copysign.f64", 2),
+		"math.FMA":      llvmMathIntrinsic("llvm.fma.f64", 3),
+		"math.Floor":    llvmMathIntrinsic("llvm.floor.f64", 1),
+		"math.Sqrt":     llvmMathIntrinsic("llvm.sqrt.f64", 1),
+		"math.Trunc":    llvmMathIntrinsic("llvm.trunc.f64", 1),
+	}
+	for _, t := range []string{"Int32", "Int64", "Uint32", "Uint64", "Uintptr", "Pointer"} {
+		if t != "Pointer" {
+			callIntrinsics["sync/atomic.Add"+t] = atomicAdd
+		}
+		callIntrinsics["sync/atomic.Load"+t] = atomicLoad
+		callIntrinsics["sync/atomic.Store"+t] = atomicStore
+		callIntrinsics["sync/atomic.CompareAndSwap"+t] = atomicCompareAndSwap
+	}
+}
+
+// lookupCallIntrinsic returns the code generator for calls to the function
+// selected by sel, or nil if sel does not select a function in callIntrinsics
+// from an imported package.
+func (c *compiler) lookupCallIntrinsic(sel *ast.SelectorExpr) callIntrinsic {
+	ident, ok := sel.X.(*ast.Ident)
+	if !ok || ident.Obj == nil || ident.Obj.Kind != ast.Pkg {
+		return nil
+	}
+	spec, ok := ident.Obj.Decl.(*ast.ImportSpec)
+	if !ok {
+		return nil
+	}
+	path, err := strconv.Unquote(spec.Path.Value)
+	if err != nil {
+		return nil
+	}
+	return callIntrinsics[path+"."+sel.Sel.Name]
+}
+
+// llvmMathIntrinsic returns a code generator that replaces calls to a
+// function of n float64 arguments, returning float64, with calls to the
+// named LLVM intrinsic. Only intrinsics that compute exactly the results
+// of the Go functions are used, so libm is never needed.
+func llvmMathIntrinsic(name string, n int) callIntrinsic {
+	return func(c *compiler, args []ast.Expr) Value {
+		f64 := c.context.DoubleType()
+		paramTypes := make([]llvm.Type, n)
+		llvmargs := make([]llvm.Value, n)
+		for i, arg := range args {
+			paramTypes[i] = f64
+			llvmargs[i] = c.VisitExpr(arg).Convert(types.Float64).LLVMValue()
+		}
+		fn := c.cFunction(name, llvm.FunctionType(f64, paramTypes, false))
+		result := c.builder.CreateCall(fn, llvmargs, "")
+		return c.NewLLVMValue(result, types.Float64)
+	}
+}
+
+// atomicAddress evaluates the address operand of a sync/atomic function,
+// returning the address and the type of the value it points to. The value
+// is always an integer in LLVM, as unsafe.Pointer is represented as intptr.
+func (c *compiler) atomicAddress(arg ast.Expr) (llvm.Value, types.Type) {
+	addr := c.VisitExpr(arg)
+	ptr := addr.LLVMValue()
+	c.nilCheck(ptr)
+	return ptr, types.Underlying(addr.Type()).(*types.Pointer).Base
+}
+
+// atomicAdd replaces calls to sync/atomic.Add* with an atomicrmw add,
+// returning the new value.
+func atomicAdd(c *compiler, args []ast.Expr) Value {
+	ptr, typ := c.atomicAddress(args[0])
+	delta := c.VisitExpr(args[1]).Convert(typ).LLVMValue()
+	ordering := llvm.AtomicOrderingSequentiallyConsistent
+	old := c.builder.CreateAtomicRMW(llvm.AtomicRMWBinOpAdd, ptr, delta, ordering, false)
+	return c.NewLLVMValue(c.builder.CreateAdd(old, delta, ""), typ)
+}
+
+// atomicLoad replaces calls to sync/atomic.Load* with an atomic load.
+func atomicLoad(c *compiler, args []ast.Expr) Value {
+	ptr, typ := c.atomicAddress(args[0])
+	result := c.builder.CreateLoad(ptr, "")
+	result.SetOrdering(llvm.AtomicOrderingSequentiallyConsistent)
+	result.SetAlignment(c.alignofType(typ))
+	return c.NewLLVMValue(result, typ)
+}
+
+// atomicStore replaces calls to sync/atomic.Store* with an atomic store.
+func atomicStore(c *compiler, args []ast.Expr) Value {
+	ptr, typ := c.atomicAddress(args[0])
+	value := c.VisitExpr(args[1]).Convert(typ).LLVMValue()
+	store := c.builder.CreateStore(value, ptr)
+	store.SetOrdering(llvm.AtomicOrderingSequentiallyConsistent)
+	store.SetAlignment(c.alignofType(typ))
+	return nil
+}
+
+// atomicCompareAndSwap replaces calls to sync/atomic.CompareAndSwap* with
+// a cmpxchg, returning whether the old value was the one expected.
+func atomicCompareAndSwap(c *compiler, args []ast.Expr) Value {
+	ptr, typ := c.atomicAddress(args[0])
+	old := c.VisitExpr(args[1]).Convert(typ).LLVMValue()
+	new := c.VisitExpr(args[2]).Convert(typ).LLVMValue()
+	ordering := llvm.AtomicOrderingSequentiallyConsistent
+	prev := c.builder.CreateAtomicCmpXchg(ptr, old, new, ordering, false)
+	swapped := c.builder.CreateICmp(llvm.IntEQ, prev, old, "")
+	return c.NewLLVMValue(swapped, types.Bool)
+}
+
+// vim: set ft=go :
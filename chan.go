@@ -0,0 +1,87 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package llgo
+
+import (
+	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo/types"
+)
+
+// makeChan allocates a new channel, with a buffer of the specified
+// capacity.
+func (c *compiler) makeChan(typ types.Type, cap_ Value) *LLVMValue {
+	chanmake := c.NamedFunction("runtime.chanmake", "func f(t uintptr, cap int) uintptr")
+	ptrType := c.target.IntPtrType()
+	args := make([]llvm.Value, 2)
+	args[0] = llvm.ConstPtrToInt(c.types.ToRuntime(typ), ptrType)
+	if cap_ != nil {
+		args[1] = cap_.Convert(types.Int).LLVMValue()
+	} else {
+		args[1] = llvm.ConstNull(c.types.ToLLVM(types.Int))
+	}
+	ch := c.builder.CreateCall(chanmake, args, "")
+	ch = c.builder.CreateIntToPtr(ch, c.types.ToLLVM(typ), "")
+	return c.NewLLVMValue(ch, typ)
+}
+
+// chanSend sends a value on a channel, blocking until the channel has
+// room for it.
+func (c *compiler) chanSend(ch *LLVMValue, elem Value) {
+	chanType := types.Underlying(ch.Type()).(*types.Chan)
+	elem = elem.Convert(chanType.Elt)
+	elemptr := c.createEntryAlloca(c.types.ToLLVM(chanType.Elt), "")
+	c.builder.CreateStore(elem.LLVMValue(), elemptr)
+	chansend := c.NamedFunction("runtime.chansend", "func f(c, elem uintptr)")
+	ptrType := c.target.IntPtrType()
+	args := make([]llvm.Value, 2)
+	args[0] = c.builder.CreatePtrToInt(ch.LLVMValue(), ptrType, "")
+	args[1] = c.builder.CreatePtrToInt(elemptr, ptrType, "")
+	c.builder.CreateCall(chansend, args, "")
+}
+
+// chanRecv receives a value from a channel, blocking until one is
+// available. The second result reports whether the value was sent, as
+// opposed to being the zero value received from a closed channel.
+func (c *compiler) chanRecv(ch *LLVMValue) (elem *LLVMValue, ok *LLVMValue) {
+	chanType := types.Underlying(ch.Type()).(*types.Chan)
+	elemptr := c.createEntryAlloca(c.types.ToLLVM(chanType.Elt), "")
+	chanrecv := c.NamedFunction("runtime.chanrecv", "func f(c, elem uintptr) bool")
+	ptrType := c.target.IntPtrType()
+	args := make([]llvm.Value, 2)
+	args[0] = c.builder.CreatePtrToInt(ch.LLVMValue(), ptrType, "")
+	args[1] = c.builder.CreatePtrToInt(elemptr, ptrType, "")
+	received := c.builder.CreateCall(chanrecv, args, "")
+	elem = c.NewLLVMValue(c.builder.CreateLoad(elemptr, ""), chanType.Elt)
+	ok = c.NewLLVMValue(received, types.Bool)
+	return elem, ok
+}
+
+// chanClose closes a channel.
+func (c *compiler) chanClose(ch *LLVMValue) {
+	chanclose := c.NamedFunction("runtime.chanclose", "func f(c uintptr)")
+	ptrType := c.target.IntPtrType()
+	arg := c.builder.CreatePtrToInt(ch.LLVMValue(), ptrType, "")
+	c.builder.CreateCall(chanclose, []llvm.Value{arg}, "")
+}
+
+// vim: set ft=go :
@@ -0,0 +1,139 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package llgo
+
+import (
+	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo/types"
+	"go/ast"
+)
+
+// isConstFalse reports whether the value is the constant boolean false,
+// in which case a runtime check guarded by it can be elided.
+func isConstFalse(v llvm.Value) bool {
+	if c := v.IsAConstantInt(); !c.IsNil() {
+		return c.ZExtValue() == 0
+	}
+	return false
+}
+
+// checkCondition emits a branch to a call to the runtime panic function
+// fn, with the specified arguments, if cond is true, and continues in a
+// new block otherwise.
+func (c *compiler) checkCondition(cond llvm.Value, fn llvm.Value, args ...llvm.Value) {
+	if isConstFalse(cond) {
+		return
+	}
+	currBlock := c.builder.GetInsertBlock()
+	contBlock := c.context.InsertBasicBlock(currBlock, "")
+	contBlock.MoveAfter(currBlock)
+	panicBlock := c.context.InsertBasicBlock(contBlock, "")
+	c.builder.CreateCondBr(cond, panicBlock, contBlock)
+	c.builder.SetInsertPointAtEnd(panicBlock)
+	c.builder.CreateCall(fn, args, "")
+	c.builder.CreateUnreachable()
+	c.builder.SetInsertPointAtEnd(contBlock)
+}
+
+// boundsCheck checks that 0 <= index < length, panicking if not. Both
+// values must be of type int.
+func (c *compiler) boundsCheck(index, length llvm.Value) {
+	if c.noBoundsCheck {
+		return
+	}
+	// A negative index is out of range when compared as unsigned.
+	outOfRange := c.builder.CreateICmp(llvm.IntUGE, index, length, "")
+	panicindex := c.NamedFunction("runtime.panicindex", "func f(index, length int)")
+	c.checkCondition(outOfRange, panicindex, index, length)
+}
+
+// sliceBoundsCheck checks that 0 <= low <= high <= max <= bound, panicking
+// if not. All values must be of type int; max is nil for two-index slice
+// expressions. isLen reports whether bound is the operand's length, as for
+// strings and arrays, rather than a slice's capacity, which the runtime
+// reports in the panic message.
+func (c *compiler) sliceBoundsCheck(low, high, max, bound llvm.Value, isLen bool) {
+	if c.noBoundsCheck {
+		return
+	}
+	var outOfRange llvm.Value
+	var panicslice llvm.Value
+	var args []llvm.Value
+	if max.IsNil() {
+		outOfRange = c.builder.CreateICmp(llvm.IntUGT, high, bound, "")
+		panicslice = c.NamedFunction("runtime.panicslice", "func f(low, high, bound int, islen bool)")
+		args = []llvm.Value{low, high, bound}
+	} else {
+		outOfRange = c.builder.CreateICmp(llvm.IntUGT, max, bound, "")
+		highOutOfRange := c.builder.CreateICmp(llvm.IntUGT, high, max, "")
+		outOfRange = c.builder.CreateOr(outOfRange, highOutOfRange, "")
+		panicslice = c.NamedFunction("runtime.panicslice3", "func f(low, high, max, bound int, islen bool)")
+		args = []llvm.Value{low, high, max, bound}
+	}
+	lowOutOfRange := c.builder.CreateICmp(llvm.IntUGT, low, high, "")
+	outOfRange = c.builder.CreateOr(outOfRange, lowOutOfRange, "")
+	islen := llvm.ConstNull(c.context.Int1Type())
+	if isLen {
+		islen = llvm.ConstAllOnes(c.context.Int1Type())
+	}
+	c.checkCondition(outOfRange, panicslice, append(args, islen)...)
+}
+
+// nilCheck checks that the pointer is not nil, panicking if it is.
+func (c *compiler) nilCheck(ptr llvm.Value) {
+	isnil := c.builder.CreateIsNull(ptr, "")
+	c.checkCondition(isnil, c.NamedFunction("runtime.panicnil", "func f()"))
+}
+
+// divideByZeroCheck checks that the integer divisor is not zero,
+// panicking if it is.
+func (c *compiler) divideByZeroCheck(divisor llvm.Value) {
+	zero := llvm.ConstNull(divisor.Type())
+	iszero := c.builder.CreateICmp(llvm.IntEQ, divisor, zero, "")
+	c.checkCondition(iszero, c.NamedFunction("runtime.panicdivide", "func f()"))
+}
+
+// visitPanic compiles a call to the panic builtin, which converts its
+// argument to an interface value and passes it to runtime.gopanic. The
+// call does not return, so the statements following it are unreachable;
+// see isPanicStmt.
+func (c *compiler) visitPanic(arg ast.Expr) {
+	value := c.VisitExpr(arg).Convert(&types.Interface{})
+	gopanic := c.NamedFunction("runtime.gopanic", "func f(e interface{})")
+	c.builder.CreateCall(gopanic, []llvm.Value{value.LLVMValue()}, "")
+	c.builder.CreateUnreachable()
+}
+
+// isPanicStmt reports whether the statement is a call to the panic
+// builtin.
+func isPanicStmt(stmt ast.Stmt) bool {
+	if stmt, ok := stmt.(*ast.ExprStmt); ok {
+		if call, ok := stmt.X.(*ast.CallExpr); ok {
+			ident, ok := call.Fun.(*ast.Ident)
+			return ok && ident.Name == "panic" && ident.Obj != nil && ident.Obj.Decl == nil
+		}
+	}
+	return false
+}
+
+// vim: set ft=go :
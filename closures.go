@@ -0,0 +1,317 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package llgo
+
+import (
+	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo/types"
+	"go/ast"
+)
+
+// freeVars returns the variables referenced by a function literal (or by
+// any function literals nested within it) which are declared outside of
+// the literal.
+func freeVars(lit *ast.FuncLit) []*ast.Object {
+	var objects []*ast.Object
+	seen := make(map[*ast.Object]bool)
+	var visit func(n ast.Node) bool
+	visit = func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			// Field and method names are not variables.
+			ast.Inspect(n.X, visit)
+			return false
+		case *ast.KeyValueExpr:
+			// Nor are the field names in struct literals.
+			if _, ok := n.Key.(*ast.Ident); !ok {
+				ast.Inspect(n.Key, visit)
+			}
+			ast.Inspect(n.Value, visit)
+			return false
+		case *ast.Ident:
+			obj := n.Obj
+			if obj == nil || obj.Kind != ast.Var || seen[obj] {
+				break
+			}
+			seen[obj] = true
+			if decl, ok := obj.Decl.(ast.Node); ok {
+				pos := decl.Pos()
+				if pos < lit.Pos() || pos >= lit.End() {
+					objects = append(objects, obj)
+				}
+			}
+		}
+		return true
+	}
+	ast.Inspect(lit.Body, visit)
+	return objects
+}
+
+// allocateVar allocates memory for a local variable, returning a pointer
+// to the memory. The memory is on the stack, unless escape analysis has
+// determined that the variable may outlive the function.
+func (c *compiler) allocateVar(obj *ast.Object, typ llvm.Type) llvm.Value {
+	var name string
+	if obj != nil {
+		name = obj.Name
+		if c.escaping[obj] {
+			return c.createTypeMalloc(typ)
+		}
+	}
+	if c.preciseGC {
+		var gotyp types.Type
+		if obj != nil {
+			gotyp, _ = obj.Type.(types.Type)
+		}
+		return c.createGCRoot(gotyp, typ, name)
+	}
+	return c.createEntryAlloca(typ, name)
+}
+
+// makeFuncValue converts a top-level function into a function value, with
+// a nil context pointer.
+func (c *compiler) makeFuncValue(fn *LLVMValue) *LLVMValue {
+	fnptr := fn.LLVMValue()
+	if fnptr.Type().TypeKind() == llvm.StructTypeKind {
+		return fn
+	}
+	ctx := llvm.ConstNull(llvm.PointerType(c.context.Int8Type(), 0))
+	value := c.context.ConstStruct([]llvm.Value{fnptr, ctx}, false)
+	return c.NewLLVMValue(value, fn.Type())
+}
+
+// createCall creates a call to the specified function with the specified
+// arguments. The function may be either a function pointer, or a function
+// value; in the latter case, a non-nil context pointer is passed to the
+// function in a "nest" parameter.
+func (c *compiler) createCall(fn llvm.Value, args []llvm.Value) llvm.Value {
+	if fn.Type().TypeKind() == llvm.StructTypeKind {
+		fnptr := c.builder.CreateExtractValue(fn, 0, "")
+		ctx := c.builder.CreateExtractValue(fn, 1, "")
+		if ctx.IsConstant() && ctx.IsNull() {
+			fn = fnptr
+		} else {
+			fntype := fnptr.Type().ElementType()
+			paramtypes := append([]llvm.Type{ctx.Type()}, fntype.ParamTypes()...)
+			fntype = llvm.FunctionType(fntype.ReturnType(), paramtypes, false)
+			fnptr = c.builder.CreateBitCast(fnptr, llvm.PointerType(fntype, 0), "")
+			args = append([]llvm.Value{ctx}, args...)
+			result := c.builder.CreateCall(fnptr, args, "")
+			result.AddInstrAttribute(1, llvm.NestAttribute)
+			return result
+		}
+	}
+	return c.builder.CreateCall(fn, args, "")
+}
+
+// contextType returns the LLVM type of the context for a closure that
+// captures the specified variables: a struct of pointers to the variables.
+func (c *compiler) contextType(captures []*ast.Object) llvm.Type {
+	elements := make([]llvm.Type, len(captures))
+	for i, obj := range captures {
+		typ := obj.Data.(*LLVMValue).Type()
+		elements[i] = c.types.ToLLVM(&types.Pointer{Base: typ})
+	}
+	return c.context.StructType(elements, false)
+}
+
+// makeClosure creates a function value for a function literal, storing
+// pointers to the captured variables in a heap-allocated context.
+func (c *compiler) makeClosure(lit *ast.FuncLit) *LLVMValue {
+	ftyp := c.types.expr[lit].(*types.Func)
+	fnptr_type := c.types.rawFuncLLVMType(ftyp)
+
+	// Find the captured variables that are local to the enclosing
+	// function; globals may be referenced directly.
+	var captures []*ast.Object
+	var captureptrs []llvm.Value
+	for _, obj := range freeVars(lit) {
+		value, ok := obj.Data.(*LLVMValue)
+		if !ok {
+			continue
+		}
+		var ptr llvm.Value
+		if value.pointer != nil {
+			ptr = value.pointer.LLVMValue()
+			if !ptr.IsAGlobalVariable().IsNil() {
+				continue
+			}
+		} else {
+			if !value.LLVMValue().IsAGlobalVariable().IsNil() {
+				continue
+			}
+			// The variable isn't addressable, so capture a copy.
+			ptr = c.createTypeMalloc(value.LLVMValue().Type())
+			c.builder.CreateStore(value.LLVMValue(), ptr)
+		}
+		captures = append(captures, obj)
+		captureptrs = append(captureptrs, ptr)
+	}
+
+	// Store the pointers to the captured variables in the context.
+	i8ptr := llvm.PointerType(c.context.Int8Type(), 0)
+	ctx := llvm.ConstNull(i8ptr)
+	if len(captures) > 0 {
+		ctxptr := c.createTypeMalloc(c.contextType(captures))
+		for i, ptr := range captureptrs {
+			c.builder.CreateStore(ptr, c.builder.CreateStructGEP(ctxptr, i, ""))
+		}
+		ctx = c.builder.CreateBitCast(ctxptr, i8ptr, "")
+	}
+
+	// Create the function. If there are any captured variables, the
+	// function takes the context pointer as its first parameter.
+	llvm_fn_type := fnptr_type.ElementType()
+	if len(captures) > 0 {
+		paramtypes := append([]llvm.Type{i8ptr}, llvm_fn_type.ParamTypes()...)
+		llvm_fn_type = llvm.FunctionType(
+			llvm_fn_type.ReturnType(), paramtypes, false)
+	}
+	fn := llvm.AddFunction(c.module.Module, "", llvm_fn_type)
+	fn.SetLinkage(llvm.PrivateLinkage)
+	if len(captures) > 0 {
+		fn.Param(0).AddAttribute(llvm.NestAttribute)
+	}
+
+	// Build the function, restoring the outer bindings of the captured
+	// variables when done.
+	outer := make([]interface{}, len(captures))
+	for i, obj := range captures {
+		outer[i] = obj.Data
+	}
+	ip := c.saveInsertPoint()
+	f := c.NewLLVMValue(fn, ftyp)
+	c.buildFunction(f, captures, ftyp.Params, lit.Body)
+	for i, obj := range captures {
+		obj.Data = outer[i]
+	}
+	c.restoreInsertPoint(ip)
+
+	fnptr := c.builder.CreateBitCast(fn, fnptr_type, "")
+	value := llvm.Undef(c.types.ToLLVM(ftyp))
+	value = c.builder.CreateInsertValue(value, fnptr, 0, "")
+	value = c.builder.CreateInsertValue(value, ctx, 1, "")
+	return c.NewLLVMValue(value, ftyp)
+}
+
+// makeMethodValue creates a function value for a method bound to its
+// receiver, as in "f := x.Method". The method and receiver are stored in a
+// heap-allocated context, which is passed to a thunk that calls the method.
+func (c *compiler) makeMethodValue(method *LLVMValue) *LLVMValue {
+	methodtyp := method.Type().(*types.Func)
+	fntyp := &types.Func{
+		Params:     methodtyp.Params,
+		Results:    methodtyp.Results,
+		IsVariadic: methodtyp.IsVariadic,
+	}
+	fnptr := method.LLVMValue()
+	recv := method.receiver.LLVMValue()
+	ctxtyp := c.context.StructType([]llvm.Type{fnptr.Type(), recv.Type()}, false)
+	ctxptr := c.createTypeMalloc(ctxtyp)
+	c.builder.CreateStore(fnptr, c.builder.CreateStructGEP(ctxptr, 0, ""))
+	c.builder.CreateStore(recv, c.builder.CreateStructGEP(ctxptr, 1, ""))
+
+	i8ptr := llvm.PointerType(c.context.Int8Type(), 0)
+	thunk := c.methodValueThunk(fntyp, ctxtyp)
+	thunk = c.builder.CreateBitCast(thunk, c.types.rawFuncLLVMType(fntyp), "")
+	value := llvm.Undef(c.types.ToLLVM(fntyp))
+	value = c.builder.CreateInsertValue(value, thunk, 0, "")
+	value = c.builder.CreateInsertValue(value, c.builder.CreateBitCast(ctxptr, i8ptr, ""), 1, "")
+	return c.NewLLVMValue(value, fntyp)
+}
+
+// methodValueThunk creates a function that calls the method stored in a
+// method value's context, passing the receiver stored alongside it
+// followed by the thunk's own arguments.
+func (c *compiler) methodValueThunk(fntyp *types.Func, ctxtyp llvm.Type) llvm.Value {
+	defer c.restoreInsertPoint(c.saveInsertPoint())
+
+	i8ptr := llvm.PointerType(c.context.Int8Type(), 0)
+	llvm_fn_type := c.types.rawFuncLLVMType(fntyp).ElementType()
+	paramtypes := append([]llvm.Type{i8ptr}, llvm_fn_type.ParamTypes()...)
+	llvm_fn_type = llvm.FunctionType(llvm_fn_type.ReturnType(), paramtypes, false)
+	thunk := llvm.AddFunction(c.module.Module, "", llvm_fn_type)
+	thunk.SetLinkage(llvm.PrivateLinkage)
+	thunk.Param(0).AddAttribute(llvm.NestAttribute)
+
+	entry := c.context.AddBasicBlock(thunk, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	ctxptr := c.builder.CreateBitCast(thunk.Param(0), llvm.PointerType(ctxtyp, 0), "")
+	fnptr := c.builder.CreateLoad(c.builder.CreateStructGEP(ctxptr, 0, ""), "")
+	recv := c.builder.CreateLoad(c.builder.CreateStructGEP(ctxptr, 1, ""), "")
+	args := append([]llvm.Value{recv}, thunk.Params()[1:]...)
+	result := c.builder.CreateCall(fnptr, args, "")
+	if llvm_fn_type.ReturnType().TypeKind() == llvm.VoidTypeKind {
+		c.builder.CreateRetVoid()
+	} else {
+		c.builder.CreateRet(result)
+	}
+	return thunk
+}
+
+// makeMethodExpr creates a function value for a method expression, as in
+// "T.Method" or "(*T).Method": a function taking the receiver as its first
+// argument. If the receiver type differs from that of the method, which is
+// the case for a pointer to a type with value methods, or for an interface
+// type, a wrapper function is created to adapt the receiver.
+func (c *compiler) makeMethodExpr(expr *ast.SelectorExpr, recvtyp types.Type) *LLVMValue {
+	fntyp := c.types.expr[expr].(*types.Func)
+	obj := expr.Sel.Obj
+	_, isiface := types.Underlying(recvtyp).(*types.Interface)
+	if !isiface {
+		methodtyp := obj.Type.(*types.Func)
+		if types.Identical(recvtyp, methodtyp.Recv.Type.(types.Type)) {
+			method := c.Resolve(obj).(*LLVMValue)
+			return c.makeFuncValue(c.NewLLVMValue(method.LLVMValue(), fntyp))
+		}
+	}
+
+	defer c.restoreInsertPoint(c.saveInsertPoint())
+	llvm_fn_type := c.types.rawFuncLLVMType(fntyp).ElementType()
+	fn := llvm.AddFunction(c.module.Module, "", llvm_fn_type)
+	fn.SetLinkage(llvm.PrivateLinkage)
+	entry := c.context.AddBasicBlock(fn, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+
+	var method, recv llvm.Value
+	if isiface {
+		m := c.interfaceMethod(c.NewLLVMValue(fn.Param(0), recvtyp), expr.Sel.Name)
+		method, recv = m.LLVMValue(), m.receiver.LLVMValue()
+	} else {
+		// Calling a value method through a nil pointer panics.
+		c.nilCheck(fn.Param(0))
+		ptr := c.NewLLVMValue(fn.Param(0), recvtyp)
+		method = c.Resolve(obj).LLVMValue()
+		recv = ptr.makePointee().LLVMValue()
+	}
+	args := append([]llvm.Value{recv}, fn.Params()[1:]...)
+	result := c.builder.CreateCall(method, args, "")
+	if llvm_fn_type.ReturnType().TypeKind() == llvm.VoidTypeKind {
+		c.builder.CreateRetVoid()
+	} else {
+		c.builder.CreateRet(result)
+	}
+	return c.makeFuncValue(c.NewLLVMValue(fn, fntyp))
+}
+
+// vim: set ft=go :
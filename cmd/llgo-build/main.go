@@ -0,0 +1,133 @@
+// Copyright 2012 Andrew Wilkins.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+// llgo-build compiles packages from a GOPATH tree with llgo, in dependency
+// order, into a package cache directory. Each package is cached as LLVM
+// bitcode (<path>.bc) alongside its export data (<path>.gox), and is only
+// recompiled if it is stale: if its sources, or the cached packages it
+// depends on, have changed since it was compiled.
+//
+// Usage:
+//
+//	llgo-build [flags] [packages]
+//
+// Packages are specified by import path; a path ending in "/..." matches
+// the packages in and below that directory. The default is the llgo
+// standard library subset, "github.com/axw/llgo/pkg/...".
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/build"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	llgobin  string
+	pkgdir   string
+	triple   string
+	rebuild  bool
+	verbose  bool
+	llgoArgs []string
+)
+
+const defaultPackages = "github.com/axw/llgo/pkg/..."
+
+func errorf(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format, args...)
+	os.Exit(1)
+}
+
+func init() {
+	flag.StringVar(&llgobin, "llgo", "llgo",
+		"Path to the llgo executable")
+	flag.StringVar(&pkgdir, "pkgdir", "",
+		"Package cache directory (default $GOPATH/pkg/llgo/<triple>)")
+	flag.StringVar(&triple, "target", "",
+		"Target triple to compile for (default is llgo's default target)")
+	flag.BoolVar(&rebuild, "a", false,
+		"Rebuild all packages, even those that are up to date")
+	flag.BoolVar(&verbose, "v", false,
+		"Print the import paths of packages as they are compiled")
+}
+
+// initTarget determines the target triple and package cache directory.
+func initTarget() error {
+	if triple == "" {
+		output, err := exec.Command(llgobin, "-print-triple").CombinedOutput()
+		if err != nil {
+			return err
+		}
+		triple = strings.TrimSpace(string(output))
+	} else {
+		llgoArgs = append(llgoArgs, "-target", triple)
+	}
+	if pkgdir == "" {
+		gopath := filepath.SplitList(build.Default.GOPATH)
+		if len(gopath) == 0 {
+			return fmt.Errorf("GOPATH is not set; specify -pkgdir")
+		}
+		pkgdir = filepath.Join(gopath[0], "pkg", "llgo", triple)
+	}
+	return nil
+}
+
+func main() {
+	flag.Parse()
+
+	var err error
+	llgobin, err = exec.LookPath(llgobin)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		errorf("Specify the path with \"-llgo=...\"\n")
+	}
+	if err = initTarget(); err != nil {
+		errorf("%s\n", err)
+	}
+
+	patterns := flag.Args()
+	if len(patterns) == 0 {
+		patterns = []string{defaultPackages}
+	}
+	pkgs, err := loadPackages(patterns)
+	if err != nil {
+		errorf("%s\n", err)
+	}
+	for _, pkg := range pkgs {
+		if err = buildPackage(pkg); err != nil {
+			errorf("%s: %s\n", pkg.ImportPath, err)
+		}
+	}
+}
+
+// buildPackage compiles a package into the package cache, unless the
+// cached package is up to date.
+func buildPackage(pkg *build.Package) error {
+	outfile := cacheFile(pkg.ImportPath)
+	if !rebuild && !isStale(pkg, outfile) {
+		return nil
+	}
+	if verbose {
+		log.Println(pkg.ImportPath)
+	}
+	err := os.MkdirAll(filepath.Dir(outfile), os.FileMode(0755))
+	if err != nil {
+		return err
+	}
+
+	args := []string{"-emit-llvm", "-I", pkgdir, "-o", outfile, "-p", pkg.ImportPath}
+	args = append(args, llgoArgs...)
+	for _, filename := range pkg.GoFiles {
+		args = append(args, filepath.Join(pkg.Dir, filename))
+	}
+	cmd := exec.Command(llgobin, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
@@ -0,0 +1,128 @@
+// Copyright 2012 Andrew Wilkins.
+// Use of this source code is governed by an MIT-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"go/build"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// cacheFile returns the name of the cached bitcode for the package with
+// the specified import path. The export data is written alongside it by
+// llgo, with the extension ".gox".
+func cacheFile(path string) string {
+	return filepath.Join(pkgdir, filepath.FromSlash(path)+".bc")
+}
+
+// matchPackages expands a "/..." pattern to the import paths of the
+// packages in and below the named directory of each GOPATH tree. Other
+// patterns are returned unchanged.
+func matchPackages(pattern string) ([]string, error) {
+	if !strings.HasSuffix(pattern, "/...") {
+		return []string{pattern}, nil
+	}
+	prefix := pattern[:len(pattern)-len("/...")]
+	var paths []string
+	for _, src := range build.Default.SrcDirs() {
+		root := filepath.Join(src, filepath.FromSlash(prefix))
+		filepath.Walk(root, func(dir string, fi os.FileInfo, err error) error {
+			if err != nil || !fi.IsDir() {
+				return nil
+			}
+			if name := fi.Name(); dir != root && (name[0] == '.' || name[0] == '_' || name == "testdata") {
+				return filepath.SkipDir
+			}
+			if _, err := build.ImportDir(dir, 0); err == nil {
+				rel, _ := filepath.Rel(src, dir)
+				paths = append(paths, filepath.ToSlash(rel))
+			}
+			return nil
+		})
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no packages match %q", pattern)
+	}
+	return paths, nil
+}
+
+// loadPackages locates the packages matching the specified patterns, and
+// those they import, and returns them in dependency order: each package
+// follows the packages it imports. Packages in GOROOT are not included;
+// llgo imports those from the packages compiled by gc.
+func loadPackages(patterns []string) ([]*build.Package, error) {
+	var ordered []*build.Package
+	visited := make(map[string]bool)
+	active := make(map[string]bool)
+
+	var visit func(path string) error
+	visit = func(path string) error {
+		if active[path] {
+			return fmt.Errorf("import cycle involving %q", path)
+		}
+		if visited[path] || path == "unsafe" || path == "C" {
+			return nil
+		}
+		pkg, err := build.Import(path, "", 0)
+		if err != nil {
+			return err
+		}
+		if pkg.Goroot {
+			visited[path] = true
+			return nil
+		}
+		active[path] = true
+		for _, imp := range pkg.Imports {
+			if err := visit(imp); err != nil {
+				return err
+			}
+		}
+		delete(active, path)
+		visited[path] = true
+		ordered = append(ordered, pkg)
+		return nil
+	}
+
+	for _, pattern := range patterns {
+		paths, err := matchPackages(pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			if err := visit(path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return ordered, nil
+}
+
+// isStale reports whether the cached package is missing or out of date:
+// that is, older than any of the package's source files, or than any of
+// the cached packages it imports.
+func isStale(pkg *build.Package, outfile string) bool {
+	fi, err := os.Stat(outfile)
+	if err != nil {
+		return true
+	}
+	if _, err := os.Stat(outfile[:len(outfile)-len(".bc")] + ".gox"); err != nil {
+		return true
+	}
+	built := fi.ModTime()
+	for _, filename := range pkg.GoFiles {
+		fi, err := os.Stat(filepath.Join(pkg.Dir, filename))
+		if err != nil || fi.ModTime().After(built) {
+			return true
+		}
+	}
+	for _, imp := range pkg.Imports {
+		if fi, err := os.Stat(cacheFile(imp)); err == nil && fi.ModTime().After(built) {
+			return true
+		}
+	}
+	return false
+}
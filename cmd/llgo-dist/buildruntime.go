@@ -61,7 +61,7 @@ func buildPackage(name, pkgdir string) error {
 	}
 
 	outfile := path.Join(pkgdir, file) + ".a"
-	args := []string{"-c", "-o", outfile}
+	args := []string{"-c", "-emit-llvm", "-o", outfile}
 	args = append(args, pkg.GoFiles...)
 	cmd := exec.Command(llgobin, args...)
 	cmd.Stdout = os.Stdout
@@ -106,7 +106,7 @@ func buildRuntime() error {
 		return err
 	}
 
-	runtimePackages := []string{"runtime", "syscall"}
+	runtimePackages := []string{"runtime", "syscall", "os", "sync", "testing"}
 	for _, name := range runtimePackages {
 		log.Printf("- %s", name)
 		err = buildPackage(name, outdir)
@@ -98,7 +98,6 @@ func main() {
 	actions := []func() error{
 		initLlvm,
 		buildLlgo,
-		genSyscalls,
 		buildRuntime,
 	}
 	for _, action := range actions {
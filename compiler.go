@@ -27,16 +27,45 @@ import (
 	"github.com/axw/gollvm/llvm"
 	"github.com/axw/llgo/types"
 	"go/ast"
+	"go/scanner"
 	"go/token"
 	"log"
-	"os"
 	"runtime"
+	"sort"
+	"strings"
+	"sync"
 )
 
 type Module struct {
 	llvm.Module
 	Name     string
 	Disposed bool
+
+	// ImportPath is the import path of the compiled package.
+	ImportPath string
+
+	// Triple and DataLayout are the LLVM target triple and data layout
+	// for which the module was compiled, which are also set on the LLVM
+	// module.
+	Triple     string
+	DataLayout string
+
+	// ExportData describes the package's exported declarations, in the
+	// format read by types.GcImportData.
+	ExportData []byte
+
+	// Imports holds the import paths of the packages on which the module
+	// depends, directly or indirectly, in sorted order.
+	Imports []string
+
+	// Exports holds the names of the C entry points created for the
+	// functions marked with "//export", in declaration order.
+	Exports []string
+
+	// ExportTypes maps the name of each C entry point to the type of
+	// the Go function it calls, which describes its signature before it
+	// is lowered to the C ABI.
+	ExportTypes map[string]llvm.Type
 }
 
 func (m Module) Dispose() {
@@ -46,33 +75,142 @@ func (m Module) Dispose() {
 	}
 }
 
+// CompilerOptions holds the options with which a Compiler is created. The
+// zero value compiles for the host, without optimization or debug
+// information, and with bounds checking enabled.
+type CompilerOptions struct {
+	// TargetArch and TargetOs select the target, and may be either the
+	// names recognised by the gc compiler, or LLVM names. They default
+	// to the host's.
+	TargetArch string
+	TargetOs   string
+
+	// TargetTriple, if non-empty, is an LLVM target triple of the form
+	// arch-vendor-os[-environment], which is used verbatim for compiled
+	// modules and overrides TargetArch and TargetOs.
+	TargetTriple string
+
+	// TargetCPU is the CPU for which code is generated, e.g. "core2". The
+	// default is the generic CPU for the target architecture.
+	TargetCPU string
+
+	// TargetFeatures is a comma-separated list of target-specific
+	// features to enable or disable, such as "+sse4.1,-avx".
+	TargetFeatures string
+
+	// OptLevel is the level of optimization applied to compiled modules,
+	// from 0 (none) to 3. As with C compilers, level 1 inlines only
+	// functions that must always be inlined; levels 2 and above inline
+	// functions whose cost is below InlineThreshold.
+	OptLevel int
+
+	// InlineThreshold is the cost below which functions are inlined when
+	// optimizing. A threshold of 0 selects the default for OptLevel.
+	InlineThreshold int
+
+	// GenerateDebug specifies whether DWARF debug information is
+	// generated for compiled packages.
+	GenerateDebug bool
+
+	// NoBoundsCheck disables checking that array, slice and string
+	// indexing and slicing operations are in range.
+	NoBoundsCheck bool
+
+	// PreciseGC specifies whether stack variables holding pointers are
+	// registered as garbage collection roots, so the collector can find
+	// them precisely rather than scanning the stack conservatively. All
+	// packages in a program, including the runtime, must be compiled with
+	// the same setting.
+	PreciseGC bool
+
+	// Verify specifies whether the generated LLVM IR is verified: each
+	// function as soon as it is built, and the whole module before it is
+	// optimized. Invalid IR indicates a bug in the compiler, and is
+	// reported as an error at the position of the Go function that
+	// produced it. Verification slows compilation.
+	Verify bool
+
+	// ImportPaths is the list of directories searched for the export data
+	// of imported packages compiled by llgo. Packages not found there are
+	// imported from those compiled by gc.
+	ImportPaths []string
+
+	// Logger, if non-nil, receives a trace of the compilation.
+	Logger *log.Logger
+}
+
+// Compiler compiles packages to LLVM modules.
+//
+// A Compiler may be shared by multiple goroutines. Their calls to Compile
+// parse imports and type-check concurrently, but generate code one at a
+// time, since the modules compiled by a Compiler share its LLVM context,
+// which is not safe for concurrent use. The LLVM types and the runtime
+// function signatures are cached by the compiler for all of its
+// compilations, and are safe for concurrent use. The modules must not be
+// used while the compiler is compiling. Packages are compiled fully in
+// parallel by using a Compiler per goroutine.
 type Compiler interface {
-	Compile(*token.FileSet, *ast.Package, map[ast.Expr]types.Type) (*Module, error)
-	SetTraceEnabled(bool)
-	SetTargetArch(string)
-	SetTargetOs(string)
+	// Compile resolves and type-checks the files as a single package with
+	// the specified import path, and compiles it to a module.
+	Compile(fset *token.FileSet, files []*ast.File, importpath string) (*Module, error)
+
+	// Diagnostics returns the diagnostics reported by the most recently
+	// completed call to Compile, sorted by position.
+	Diagnostics() []Diagnostic
+
+	// Options returns the options with which the compiler was created.
+	Options() CompilerOptions
+
+	// GetTargetTriple returns the LLVM target triple of compiled modules.
 	GetTargetTriple() string
 }
 
 type compiler struct {
-	builder        llvm.Builder
-	module         *Module
-	targetArch     string
-	targetOs       string
-	target         llvm.TargetData
-	functions      []Value
-	breakblocks    []llvm.BasicBlock
-	continueblocks []llvm.BasicBlock
-	initfuncs      []Value
-	varinitfuncs   []Value
-	pkg            *ast.Package
-	fileset        *token.FileSet
-	filescope      *ast.Scope
-	scope          *ast.Scope
-	pkgmap         map[*ast.Object]string
+	mu              sync.Mutex // serialises code generation
+	opts            CompilerOptions
+	diagnostics     []Diagnostic
+	context         llvm.Context
+	builder         llvm.Builder
+	module          *Module
+	targetArch      string
+	targetOs        string
+	targetTriple    string
+	targetCPU       string
+	targetFeatures  string
+	target          targetData
+	functions       []Value
+	breakblocks     []llvm.BasicBlock
+	continueblocks  []llvm.BasicBlock
+	labels          map[string]*labelInfo
+	deferframe      llvm.Value // the current function's deferred calls
+	initfuncs       []Value
+	varinitfuncs    []Value
+	exports         []*ast.FuncDecl
+	funcsyms        map[llvm.Value]*funcSym
+	pkg             *ast.Package
+	fileset         *token.FileSet
+	filescope       *ast.Scope
+	scope           *ast.Scope
+	pkgmap          map[*ast.Object]string
+	pathmap         map[*ast.Object]string
+	importpath      string
+	info            *types.Info // the results of type checking the package
+	escaping        map[*ast.Object]bool
+	generateDebug   bool
+	noBoundsCheck   bool
+	preciseGC       bool
+	verify          bool
+	verifyFailed    bool
+	optLevel        int
+	inlineThreshold int
+	gcroots         []gcRoot
+	debug           *debugInfo
+	errors          scanner.ErrorList
 	*FunctionCache
-	types  *TypeMap
-	logger *log.Logger
+	types            *TypeMap
+	llvmtypes        *LLVMTypeMap // shared by the compiler's compilations
+	runtimeFuncTypes runtimeFuncTypes
+	logger           *log.Logger
 }
 
 func (c *compiler) LookupObj(name string) *ast.Object {
@@ -117,8 +255,9 @@ func (c *compiler) Resolve(obj *ast.Object) Value {
 			default:
 				panic(fmt.Sprintf("unreachable (%T)", x))
 			}
-			value = ConstValue{(obj.Data.(types.Const)), c, typ}
-			obj.Data = value
+			// Universe and imported constants may be shared between
+			// compilers, so the value is not recorded in the object.
+			return ConstValue{(obj.Data.(types.Const)), c, typ}
 		}
 
 	case ast.Fun:
@@ -153,7 +292,6 @@ func (c *compiler) Resolve(obj *ast.Object) Value {
 			t := obj.Type.(types.Type)
 			name := c.pkgmap[obj] + "." + obj.Name
 			g := llvm.AddGlobal(module, c.types.ToLLVM(t), name)
-			g.SetLinkage(llvm.AvailableExternallyLinkage)
 			obj.Data = c.NewLLVMValue(g, &types.Pointer{Base: t}).makePointee()
 		}
 
@@ -188,45 +326,118 @@ func createPackageMap(pkg *ast.Package) map[*ast.Object]string {
 	return pkgmap
 }
 
+// createPathMap maps the package-level objects of the package, which has
+// the specified import path, and of the packages it imports, to the import
+// paths of their packages.
+func createPathMap(pkg *ast.Package, importpath string) map[*ast.Object]string {
+	pathmap := make(map[*ast.Object]string)
+	for _, obj := range pkg.Scope.Objects {
+		pathmap[obj] = importpath
+	}
+	for path, pkgobj := range pkg.Imports {
+		scope := pkgobj.Data.(*ast.Scope)
+		for _, obj := range scope.Objects {
+			pathmap[obj] = path
+		}
+	}
+	return pathmap
+}
+
+// packagePos returns the position of the package clause of the package's
+// first file, by name, at which errors concerning the package as a whole
+// are reported.
+func packagePos(pkg *ast.Package) token.Pos {
+	var first string
+	for filename := range pkg.Files {
+		if first == "" || filename < first {
+			first = filename
+		}
+	}
+	if first == "" {
+		return token.NoPos
+	}
+	return pkg.Files[first].Package
+}
+
 ///////////////////////////////////////////////////////////////////////////////
 
-func NewCompiler() Compiler {
+// NewCompiler creates a new Compiler with the specified options. Each
+// compiler has its own LLVM context, in which the modules it compiles are
+// created. Modules compiled by one compiler may be linked together, and
+// share no LLVM state with those of another compiler.
+func NewCompiler(opts CompilerOptions) Compiler {
 	compiler := new(compiler)
-	compiler.SetTargetArch(runtime.GOARCH)
-	compiler.SetTargetOs(runtime.GOOS)
+	compiler.opts = opts
+	compiler.context = llvm.NewContext()
+	compiler.logger = opts.Logger
+	compiler.generateDebug = opts.GenerateDebug
+	compiler.noBoundsCheck = opts.NoBoundsCheck
+	compiler.preciseGC = opts.PreciseGC
+	compiler.verify = opts.Verify
+	compiler.optLevel = opts.OptLevel
+	compiler.inlineThreshold = opts.InlineThreshold
+	compiler.targetCPU = opts.TargetCPU
+	compiler.targetFeatures = opts.TargetFeatures
+	if opts.TargetTriple != "" {
+		compiler.setTargetTriple(opts.TargetTriple)
+	} else {
+		arch, os := opts.TargetArch, opts.TargetOs
+		if arch == "" {
+			arch = runtime.GOARCH
+		}
+		if os == "" {
+			os = runtime.GOOS
+		}
+		compiler.setTargetArch(arch)
+		compiler.setTargetOs(os)
+	}
 	return compiler
 }
 
-func (c *compiler) SetTraceEnabled(enabled bool) {
-	if enabled {
-		c.logger = log.New(os.Stderr, "", 0)
-	} else {
-		c.logger = nil
-	}
+func (c *compiler) Options() CompilerOptions {
+	return c.opts
 }
 
-// SetTargetArch sets the target architecture, which must be either one of the
-// architecture names recognised by the gc compiler, or an LLVM architecture
-// name.
-func (c *compiler) SetTargetArch(arch string) {
+// setTargetArch sets the target architecture, which must be either one of
+// the architecture names recognised by the gc compiler, or an LLVM
+// architecture name.
+func (c *compiler) setTargetArch(arch string) {
 	switch arch {
-	case "386":
+	case "386", "i386", "i486", "i586", "i686":
 		c.targetArch = "x86"
 	case "amd64", "x86_64":
 		c.targetArch = "x86-64"
+	case "powerpc":
+		c.targetArch = "ppc32"
+	case "powerpc64":
+		c.targetArch = "ppc64"
 	default:
 		c.targetArch = arch
 	}
+	c.targetTriple = ""
 }
 
-// SetTargetOs sets the target OS, which must be either one of the OS names
+// setTargetOs sets the target OS, which must be either one of the OS names
 // recognised by the gc compiler, or an LLVM OS name.
-func (c *compiler) SetTargetOs(os string) {
+func (c *compiler) setTargetOs(os string) {
 	if os == "windows" {
 		c.targetOs = "win32"
 	} else {
 		c.targetOs = os
 	}
+	c.targetTriple = ""
+}
+
+// setTargetTriple sets the target architecture and OS from an LLVM target
+// triple of the form arch-vendor-os[-environment]. The triple is used
+// verbatim for the module.
+func (c *compiler) setTargetTriple(triple string) {
+	parts := strings.SplitN(triple, "-", 4)
+	c.setTargetArch(parts[0])
+	if len(parts) >= 3 {
+		c.setTargetOs(parts[2])
+	}
+	c.targetTriple = triple
 }
 
 // Convert the architecture name to the string used in LLVM triples.
@@ -248,22 +459,81 @@ func getTripleArchName(llvmArch string) string {
 }
 
 func (compiler *compiler) GetTargetTriple() string {
+	if compiler.targetTriple != "" {
+		return compiler.targetTriple
+	}
 	arch := getTripleArchName(compiler.targetArch)
 	os := compiler.targetOs
 	return fmt.Sprintf("%s-unknown-%s", arch, os)
 }
 
-func (compiler *compiler) Compile(fset *token.FileSet,
+func (compiler *compiler) Compile(fset *token.FileSet, files []*ast.File, importpath string) (m *Module, err error) {
+	// The package is resolved and type-checked without holding the
+	// compiler's mutex, so concurrent compilations overlap.
+	pkg, info, err := compiler.checkPackage(fset, files)
+
+	compiler.mu.Lock()
+	defer compiler.mu.Unlock()
+	defer func() { compiler.diagnostics = errorDiagnostics(err) }()
+	if err != nil {
+		return nil, err
+	}
+	m, err = compiler.compilePackage(fset, pkg, importpath, info)
+	if err != nil {
+		return nil, err
+	}
+	m.ImportPath = importpath
+	return m, nil
+}
+
+// checkPackage resolves all identifiers in the files, which make up a
+// single package, and then type-checks the package. It uses no state of the
+// compiler's but its options, so it may be called concurrently.
+func (compiler *compiler) checkPackage(fset *token.FileSet, files []*ast.File) (*ast.Package, *types.Info, error) {
+	filemap := make(map[string]*ast.File)
+	for _, file := range files {
+		filemap[fset.Position(file.Pos()).Filename] = file
+	}
+	importer := newImporter(compiler.opts.ImportPaths)
+	pkg, err := ast.NewPackage(fset, filemap, importer, types.Universe)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := types.Check(fset, pkg)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pkg, info, nil
+}
+
+func (c *compiler) Diagnostics() []Diagnostic {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Diagnostic(nil), c.diagnostics...)
+}
+
+// compilePackage compiles a resolved and type-checked package. The
+// compiler's mutex must be held.
+func (compiler *compiler) compilePackage(fset *token.FileSet,
 	pkg *ast.Package,
-	exprTypes map[ast.Expr]types.Type) (m *Module, err error) {
+	importpath string,
+	info *types.Info) (m *Module, err error) {
+
 	// FIXME create a compilation state, rather than storing in 'compiler'.
 	compiler.fileset = fset
 	compiler.pkg = pkg
+	compiler.importpath = importpath
+	compiler.info = info
 	compiler.initfuncs = nil
 	compiler.varinitfuncs = nil
+	compiler.exports = nil
+	compiler.funcsyms = make(map[llvm.Value]*funcSym)
+	compiler.escaping = make(map[*ast.Object]bool)
+	compiler.errors = nil
+	compiler.verifyFailed = false
 
 	// Create a Builder, for building LLVM instructions.
-	compiler.builder = llvm.GlobalContext().NewBuilder()
+	compiler.builder = compiler.context.NewBuilder()
 	defer compiler.builder.Dispose()
 
 	// Create a TargetMachine from the OS & Arch.
@@ -271,7 +541,8 @@ func (compiler *compiler) Compile(fset *token.FileSet,
 	var machine llvm.TargetMachine
 	for target := llvm.FirstTarget(); target.C != nil && machine.C == nil; target = target.NextTarget() {
 		if target.Name() == compiler.targetArch {
-			machine = target.CreateTargetMachine(triple, "", "",
+			machine = target.CreateTargetMachine(triple,
+				compiler.targetCPU, compiler.targetFeatures,
 				llvm.CodeGenLevelDefault,
 				llvm.RelocDefault,
 				llvm.CodeModelDefault)
@@ -288,63 +559,140 @@ func (compiler *compiler) Compile(fset *token.FileSet,
 	// otherwise we'll set a finalizer at the end. The caller may invoke
 	// Dispose manually, which will render the finalizer a no-op.
 	modulename := pkg.Name
-	compiler.target = machine.TargetData()
-	compiler.module = &Module{llvm.NewModule(modulename), modulename, false}
-	compiler.module.SetTarget(triple)
-	compiler.module.SetDataLayout(compiler.target.String())
+	compiler.target = targetData{machine.TargetData(), compiler.context}
+	compiler.module = &Module{
+		Module:     compiler.context.NewModule(modulename),
+		Name:       modulename,
+		Triple:     triple,
+		DataLayout: compiler.target.String(),
+	}
+	compiler.module.SetTarget(compiler.module.Triple)
+	compiler.module.SetDataLayout(compiler.module.DataLayout)
 	defer func() {
 		if e := recover(); e != nil {
 			compiler.module.Dispose()
-			panic(e)
-			//err = e.(error)
+			switch e := e.(type) {
+			case compileError:
+				compiler.errorf(e.pos, "%s", e.msg)
+			case typeError:
+				// Types used by a declaration are reported there by
+				// compileDecl; those the package needs outside of any
+				// declaration, such as for its runtime type descriptors,
+				// are reported at its package clause.
+				compiler.errorf(packagePos(pkg), "%s", e)
+			default:
+				panic(e)
+			}
+			compiler.errors.Sort()
+			m, err = nil, compiler.errors.Err()
 		}
 	}()
-	llvmtypemap := NewLLVMTypeMap(compiler.module.Module, compiler.target)
+	// The LLVM types are mapped once for all of the compiler's
+	// compilations, with target data that outlives this one's machine.
+	if compiler.llvmtypes == nil {
+		target := llvm.NewTargetData(compiler.target.String())
+		compiler.llvmtypes = NewLLVMTypeMap(compiler.context, target)
+	}
 	compiler.FunctionCache = NewFunctionCache(compiler)
-	compiler.types = NewTypeMap(llvmtypemap, exprTypes, compiler.FunctionCache)
+	compiler.types = NewTypeMap(compiler.llvmtypes, compiler.module.Module,
+		importpath, info.Types, compiler.FunctionCache)
 
 	// Create a mapping from objects back to packages, so we can create the
-	// appropriate symbol names.
+	// appropriate symbol names. Runtime type descriptors are named with
+	// import paths, which distinguish packages with the same name.
 	compiler.pkgmap = createPackageMap(pkg)
+	compiler.pathmap = createPathMap(pkg, importpath)
+
+	// Create a compile unit for the package, if we're generating debug
+	// information.
+	compiler.debug = nil
+	if compiler.generateDebug {
+		compiler.createCompileUnit()
+	}
 
-	// Compile each file in the package.
-	for _, file := range pkg.Files {
-		file.Scope.Outer = pkg.Scope
+	// Sort the files by name, so init functions are run in a fixed order.
+	filenames := make([]string, 0, len(pkg.Files))
+	for filename := range pkg.Files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	files := make([]*ast.File, len(filenames))
+	for i, filename := range filenames {
+		files[i] = pkg.Files[filename]
+		files[i].Scope.Outer = pkg.Scope
+		compiler.fixConstDecls(files[i])
+	}
+
+	// Compile the package-level variables first, in initialisation order,
+	// so their initialisers are run in that order. Then compile each file
+	// in the package.
+	varinits := compiler.initOrder(files)
+	if len(compiler.errors) > 0 {
+		compiler.module.Dispose()
+		compiler.errors.Sort()
+		return nil, compiler.errors.Err()
+	}
+	for _, v := range varinits {
+		compiler.filescope = v.file.Scope
+		compiler.scope = v.file.Scope
+		decl := &ast.GenDecl{TokPos: v.spec.Pos(), Tok: token.VAR}
+		decl.Specs = []ast.Spec{v.spec}
+		compiler.compileDecl(decl)
+	}
+	for _, file := range files {
 		compiler.filescope = file.Scope
 		compiler.scope = file.Scope
-		compiler.fixConstDecls(file)
 		for _, decl := range file.Decls {
-			compiler.VisitDecl(decl)
+			compiler.compileDecl(decl)
 		}
 	}
+	compiler.module.ExportData = compiler.exportData()
+	for path, pkgobj := range pkg.Imports {
+		if pkgobj != types.Unsafe {
+			compiler.module.Imports = append(compiler.module.Imports, path)
+		}
+	}
+	sort.Strings(compiler.module.Imports)
+
+	// Create the package's init function, and for package main, the C
+	// main function that initialises the program and runs main.main.
+	// Functions marked with "//export" are given C entry points.
+	initfn := compiler.createInitFunction()
+	if pkg.Name == "main" {
+		compiler.createMainFunction(initfn)
+	}
+	compiler.createExportFunctions(initfn)
+	if len(compiler.errors) > 0 {
+		compiler.module.Dispose()
+		compiler.errors.Sort()
+		return nil, compiler.errors.Err()
+	}
+
+	// Define the runtime type descriptors needed by the package's code.
+	compiler.types.EmitRuntimeTypes()
 
 	// Define intrinsics for use by the runtime: malloc, free, memcpy, etc.
 	compiler.defineRuntimeIntrinsics()
+	if compiler.module.Name == "syscall" {
+		compiler.defineSyscallIntrinsics()
+	}
 
-	// Create global constructors.
-	//
-	// XXX When imports are handled, we'll need to defer creating
-	//     llvm.global_ctors until we create an executable. This is
-	//     due to (a) imports having to be initialised before the
-	//     importer, and (b) LLVM having no specified order of
-	//     initialisation for ctors with the same priority.
-	var initfuncs [][]Value
-	if compiler.varinitfuncs != nil {
-		initfuncs = append(initfuncs, compiler.varinitfuncs)
-	}
-	if compiler.initfuncs != nil {
-		initfuncs = append(initfuncs, compiler.initfuncs)
-	}
-	if initfuncs != nil {
-		elttypes := []llvm.Type{llvm.Int32Type(), llvm.PointerType(llvm.FunctionType(llvm.VoidType(), nil, false), 0)}
-		ctortype := llvm.StructType(elttypes, false)
-		var ctors []llvm.Value
-		for priority, initfuncs := range initfuncs {
-			priority := llvm.ConstInt(llvm.Int32Type(), uint64(priority), false)
-			for _, fn := range initfuncs {
-				struct_values := []llvm.Value{priority, fn.LLVMValue()}
-				ctors = append(ctors, llvm.ConstStruct(struct_values, false))
-			}
+	// Create global constructors. Garbage collector roots and function
+	// tables are registered in every package before the program starts;
+	// packages are initialised by the C main function.
+	var ctors []llvm.Value
+	if roots := compiler.createGCRoots(); roots != nil {
+		ctors = append(ctors, roots.LLVMValue())
+	}
+	if functab := compiler.createFunctionTable(); functab != nil {
+		ctors = append(ctors, functab.LLVMValue())
+	}
+	if len(ctors) > 0 {
+		elttypes := []llvm.Type{compiler.context.Int32Type(), llvm.PointerType(llvm.FunctionType(compiler.context.VoidType(), nil, false), 0)}
+		ctortype := compiler.context.StructType(elttypes, false)
+		priority := llvm.ConstInt(compiler.context.Int32Type(), 0, false)
+		for i, fn := range ctors {
+			ctors[i] = compiler.context.ConstStruct([]llvm.Value{priority, fn}, false)
 		}
 		global_ctors_init := llvm.ConstArray(ctortype, ctors)
 		global_ctors_var := llvm.AddGlobal(compiler.module.Module, global_ctors_init.Type(), "llvm.global_ctors")
@@ -353,7 +701,19 @@ func (compiler *compiler) Compile(fset *token.FileSet,
 	}
 
 	// Create debug metadata.
-	//compiler.createMetadata()
+	if compiler.debug != nil {
+		compiler.createMetadata()
+	}
+
+	compiler.verifyModule()
+	if len(compiler.errors) > 0 {
+		compiler.module.Dispose()
+		compiler.errors.Sort()
+		return nil, compiler.errors.Err()
+	}
+
+	compiler.buildSSA()
+	compiler.optimize(machine)
 
 	return compiler.module, nil
 }
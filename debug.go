@@ -24,7 +24,11 @@ package llgo
 
 import (
 	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo/types"
 	"go/ast"
+	"go/token"
+	"path"
+	"sort"
 )
 
 // llgo constants.
@@ -33,60 +37,313 @@ const (
 	LLGOProducer = "llgo " + LLGOVersion + " (" + LLGOAuthor + ")"
 )
 
-// Go builtin types.
-var (
-	int32_debug_type = &llvm.BasicTypeDescriptor{
-		Name:         "int32",
-		Size:         32,
-		Alignment:    32,
-		TypeEncoding: llvm.DW_ATE_signed,
-	}
-)
+// debugInfo holds the state for generating DWARF debug information for a
+// package.
+type debugInfo struct {
+	llvm.DebugInfo
+	compileUnit *llvm.CompileUnitDescriptor
+	files       map[string]*llvm.FileDescriptor
+	types       map[types.Type]llvm.DebugDescriptor
+
+	// context is a stack of the subprograms being generated.
+	context []llvm.DebugDescriptor
+
+	// declare is the llvm.dbg.declare intrinsic.
+	declare llvm.Value
+}
 
-// Debug intrinsic collectors.
-func createGlobalVariableMetadata(global llvm.Value) llvm.DebugDescriptor {
-	return &llvm.GlobalVariableDescriptor{
-		Name:        global.Name(),
-		DisplayName: global.Name(),
-		//File:
-		//Line:
-		Type:  int32_debug_type, // FIXME
-		Value: global}
+func (c *compiler) createCompileUnit() {
+	filenames := make([]string, 0, len(c.pkg.Files))
+	for filename := range c.pkg.Files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	var filename string
+	if len(filenames) > 0 {
+		filename = filenames[0]
+	}
+	c.debug = &debugInfo{
+		compileUnit: &llvm.CompileUnitDescriptor{
+			Path:     llvm.FileDescriptor(filename),
+			Language: llvm.DW_LANG_Go,
+			Producer: LLGOProducer,
+			Runtime:  LLGORuntimeVersion,
+		},
+		files: make(map[string]*llvm.FileDescriptor),
+		types: make(map[types.Type]llvm.DebugDescriptor),
+	}
 }
 
+// createMetadata adds the compile unit, and everything reachable from it,
+// to the module.
 func (c *compiler) createMetadata() {
-	functions := []llvm.DebugDescriptor{}
-	globals := []llvm.DebugDescriptor{}
-	debugInfo := &llvm.DebugInfo{}
-
-	// Create global metadata.
-	//
-	// TODO We should store the global llgo.Value objects, and refer to them,
-	// so we can calculate the type metadata properly.
-	global := c.module.FirstGlobal()
-	for ; !global.IsNil(); global = llvm.NextGlobal(global) {
-		if !global.IsAGlobalVariable().IsNil() {
-			name := global.Name()
-			if ast.IsExported(name) {
-				descriptor := createGlobalVariableMetadata(global)
-				globals = append(globals, descriptor)
-				c.module.AddNamedMetadataOperand(
-					"llvm.dbg.gv", debugInfo.MDNode(descriptor))
-			}
+	c.module.AddNamedMetadataOperand(
+		"llvm.dbg.cu", c.debug.MDNode(c.debug.compileUnit))
+}
+
+func (c *compiler) debugFile(filename string) *llvm.FileDescriptor {
+	file, ok := c.debug.files[filename]
+	if !ok {
+		fd := llvm.FileDescriptor(filename)
+		file = &fd
+		c.debug.files[filename] = file
+	}
+	return file
+}
+
+// pushDebugContext creates a subprogram descriptor for a function, and
+// makes it the context for subsequent debug locations and variables.
+func (c *compiler) pushDebugContext(f *LLVMValue, pos token.Pos) {
+	if c.debug == nil {
+		return
+	}
+	position := c.fileset.Position(pos)
+	fn := f.LLVMValue()
+	subprogram := &llvm.SubprogramDescriptor{
+		Context:     c.debugFile(position.Filename),
+		Name:        fn.Name(),
+		DisplayName: fn.Name(),
+		Type:        c.debugFunctionType(f.Type().(*types.Func)),
+		Line:        uint32(position.Line),
+		Function:    fn,
+		Path:        path.Dir(position.Filename),
+		File:        path.Base(position.Filename),
+	}
+	cu := c.debug.compileUnit
+	cu.Subprograms = append(cu.Subprograms, subprogram)
+	c.debug.context = append(c.debug.context, subprogram)
+	c.setDebugLine(pos)
+}
+
+// popDebugContext restores the debug context that was current before the
+// last call to pushDebugContext.
+func (c *compiler) popDebugContext() {
+	if c.debug == nil {
+		return
+	}
+	c.debug.context = c.debug.context[:len(c.debug.context)-1]
+	c.builder.SetCurrentDebugLocation(llvm.Value{})
+}
+
+// setDebugLine sets the source location for subsequently generated
+// instructions.
+func (c *compiler) setDebugLine(pos token.Pos) {
+	if c.debug == nil || len(c.debug.context) == 0 || !pos.IsValid() {
+		return
+	}
+	position := c.fileset.Position(pos)
+	line := &llvm.LineDescriptor{
+		Line:    uint32(position.Line),
+		Column:  uint32(position.Column),
+		Context: c.debug.context[len(c.debug.context)-1],
+	}
+	c.builder.SetCurrentDebugLocation(c.debug.MDNode(line))
+}
+
+// debugDeclare describes a local variable or parameter, given a pointer
+// to its storage. Parameters are numbered from 1; argno is zero for local
+// variables.
+func (c *compiler) debugDeclare(obj *ast.Object, ptr *LLVMValue, argno int) {
+	if c.debug == nil || len(c.debug.context) == 0 || obj == nil {
+		return
+	}
+	var position token.Position
+	if decl, ok := obj.Decl.(ast.Node); ok {
+		position = c.fileset.Position(decl.Pos())
+	}
+	tag := llvm.DW_TAG_auto_variable
+	if argno > 0 {
+		tag = llvm.DW_TAG_arg_variable
+	}
+	variable := llvm.NewLocalVariableDescriptor(tag)
+	variable.Context = c.debug.context[len(c.debug.context)-1]
+	variable.Name = obj.Name
+	variable.File = c.debugFile(position.Filename)
+	variable.Line = uint32(position.Line)
+	variable.Argument = uint32(argno)
+	variable.Type = c.debugType(types.Deref(ptr.Type()))
+
+	if c.debug.declare.IsNil() {
+		mdtype := c.context.MDNode(nil).Type()
+		fntype := llvm.FunctionType(
+			c.context.VoidType(), []llvm.Type{mdtype, mdtype}, false)
+		c.debug.declare = llvm.AddFunction(
+			c.module.Module, "llvm.dbg.declare", fntype)
+	}
+	args := []llvm.Value{
+		c.context.MDNode([]llvm.Value{ptr.LLVMValue()}),
+		c.debug.MDNode(variable),
+	}
+	c.builder.CreateCall(c.debug.declare, args, "")
+}
+
+// debugGlobal describes a package-level variable.
+func (c *compiler) debugGlobal(name *ast.Ident, v *LLVMValue) {
+	if c.debug == nil {
+		return
+	}
+	global, typ := v.LLVMValue(), types.Deref(v.Type())
+	if v.pointer != nil {
+		global, typ = v.pointer.LLVMValue(), v.Type()
+	}
+	position := c.fileset.Position(name.Pos())
+	variable := &llvm.GlobalVariableDescriptor{
+		Context:     c.debug.compileUnit,
+		Name:        name.Name,
+		DisplayName: name.Name,
+		File:        c.debugFile(position.Filename),
+		Line:        uint32(position.Line),
+		Type:        c.debugType(typ),
+		Local:       !name.IsExported(),
+		External:    true,
+		Value:       global,
+	}
+	cu := c.debug.compileUnit
+	cu.GlobalVariables = append(cu.GlobalVariables, variable)
+}
+
+// debugType returns a debug type descriptor for the specified type. A
+// recursive reference to a type whose descriptor is still being created
+// is described as nil, so recursive types are described with untyped
+// pointers.
+func (c *compiler) debugType(t types.Type) llvm.DebugDescriptor {
+	if d, ok := c.debug.types[t]; ok {
+		return d
+	}
+	c.debug.types[t] = nil
+	d := c.createDebugType(t)
+	if name, ok := t.(*types.Name); ok {
+		switch d := d.(type) {
+		case *llvm.BasicTypeDescriptor:
+			d.Name = name.Obj.Name
+		case *llvm.CompositeTypeDescriptor:
+			d.Name = name.Obj.Name
 		}
 	}
+	c.debug.types[t] = d
+	return d
+}
 
-	compile_unit := &llvm.CompileUnitDescriptor{
-		Language: llvm.DW_LANG_Go,
-		//Path: 
-		Producer:        LLGOProducer,
-		Runtime:         LLGORuntimeVersion,
-		Subprograms:     functions,
-		GlobalVariables: globals}
+func (c *compiler) createDebugType(t types.Type) llvm.DebugDescriptor {
+	llvmtyp := c.types.ToLLVM(t)
+	size := c.target.TypeAllocSize(llvmtyp) * 8
+	align := uint64(c.target.ABITypeAlignment(llvmtyp)) * 8
+	u8ptr := &types.Pointer{Base: types.Uint8}
 
-	// TODO resolve descriptors.
-	c.module.AddNamedMetadataOperand(
-		"llvm.dbg.cu", debugInfo.MDNode(compile_unit))
+	switch t := types.Underlying(t).(type) {
+	case *types.Basic:
+		var encoding llvm.DwarfTypeEncoding
+		switch t.Kind {
+		case types.BoolKind:
+			encoding = llvm.DW_ATE_boolean
+		case types.IntKind, types.Int8Kind, types.Int16Kind,
+			types.Int32Kind, types.Int64Kind:
+			encoding = llvm.DW_ATE_signed
+		case types.UintKind, types.Uint8Kind, types.Uint16Kind,
+			types.Uint32Kind, types.Uint64Kind, types.UintptrKind:
+			encoding = llvm.DW_ATE_unsigned
+		case types.Float32Kind, types.Float64Kind:
+			encoding = llvm.DW_ATE_float
+		case types.Complex64Kind, types.Complex128Kind:
+			encoding = llvm.DW_ATE_complex_float
+		case types.StringKind:
+			return c.debugStructType(llvmtyp,
+				[]string{"str", "len"},
+				[]types.Type{u8ptr, types.Int})
+		case types.UnsafePointerKind:
+			return c.debugPointerType(nil)
+		}
+		return &llvm.BasicTypeDescriptor{
+			Name:         t.Kind.String(),
+			Size:         size,
+			Alignment:    align,
+			TypeEncoding: encoding,
+		}
+
+	case *types.Pointer:
+		return c.debugPointerType(c.debugType(t.Base))
+
+	case *types.Struct:
+		names := make([]string, len(t.Fields))
+		fieldtypes := make([]types.Type, len(t.Fields))
+		for i, field := range t.Fields {
+			names[i] = field.Name
+			fieldtypes[i] = field.Type.(types.Type)
+		}
+		return c.debugStructType(llvmtyp, names, fieldtypes)
+
+	case *types.Slice:
+		return c.debugStructType(llvmtyp,
+			[]string{"array", "len", "cap"},
+			[]types.Type{&types.Pointer{Base: t.Elt}, types.Uint, types.Uint})
+
+	case *types.Interface:
+		return c.debugStructType(llvmtyp,
+			[]string{"tab", "data"}, []types.Type{u8ptr, u8ptr})
+
+	case *types.Func:
+		return c.debugStructType(llvmtyp,
+			[]string{"fn", "ctx"}, []types.Type{u8ptr, u8ptr})
+
+	case *types.Array:
+		// TODO describe arrays with a subrange, rather than as
+		// opaque blocks of memory.
+		d := llvm.NewStructCompositeType(nil)
+		d.Size = size
+		d.Alignment = align
+		return d
+	}
+
+	// Maps and channels are pointers to runtime structures.
+	return c.debugPointerType(nil)
+}
+
+// debugFunctionType creates a subroutine type descriptor for a function
+// signature.
+func (c *compiler) debugFunctionType(t *types.Func) llvm.DebugDescriptor {
+	var result llvm.DebugDescriptor
+	switch len(t.Results) {
+	case 0:
+	case 1:
+		result = c.debugType(t.Results[0].Type.(types.Type))
+	default:
+		result = c.debugType(&types.Struct{Fields: t.Results})
+	}
+	params := make([]llvm.DebugDescriptor, 0, len(t.Params)+1)
+	if t.Recv != nil {
+		params = append(params, c.debugType(t.Recv.Type.(types.Type)))
+	}
+	for _, param := range t.Params {
+		params = append(params, c.debugType(param.Type.(types.Type)))
+	}
+	return llvm.NewSubroutineCompositeType(result, params)
+}
+
+func (c *compiler) debugPointerType(base llvm.DebugDescriptor) llvm.DebugDescriptor {
+	ptrtyp := llvm.PointerType(c.context.Int8Type(), 0)
+	d := llvm.NewPointerDerivedType(base)
+	d.Size = c.target.TypeAllocSize(ptrtyp) * 8
+	d.Alignment = uint64(c.target.ABITypeAlignment(ptrtyp)) * 8
+	return d
+}
+
+// debugStructType creates a descriptor for a structure, given its LLVM
+// type and the names and types of its fields.
+func (c *compiler) debugStructType(llvmtyp llvm.Type, names []string, fieldtypes []types.Type) llvm.DebugDescriptor {
+	members := make([]llvm.DebugDescriptor, len(fieldtypes))
+	for i, fieldtype := range fieldtypes {
+		fieldllvmtyp := c.types.ToLLVM(fieldtype)
+		member := llvm.NewMemberDerivedType(c.debugType(fieldtype))
+		member.Name = names[i]
+		member.Size = c.target.TypeAllocSize(fieldllvmtyp) * 8
+		member.Alignment = uint64(c.target.ABITypeAlignment(fieldllvmtyp)) * 8
+		member.Offset = c.target.ElementOffset(llvmtyp, i) * 8
+		members[i] = member
+	}
+	d := llvm.NewStructCompositeType(members)
+	d.Size = c.target.TypeAllocSize(llvmtyp) * 8
+	d.Alignment = uint64(c.target.ABITypeAlignment(llvmtyp)) * 8
+	return d
 }
 
 // vim: set ft=go :
@@ -23,16 +23,64 @@ SOFTWARE.
 package llgo
 
 import (
-	"fmt"
 	"github.com/axw/gollvm/llvm"
 	"github.com/axw/llgo/types"
 	"go/ast"
-	"go/scanner"
 	"go/token"
 	"reflect"
 	"strconv"
+	"strings"
 )
 
+// llgoDirective returns the value of the "#llgo <key>:" directive in the
+// comment group, or the empty string if there is none. For example, a
+// function declared with the comment
+//
+//	// #llgo name: runtime.args
+//
+// is given the symbol name "runtime.args", which allows the packages that
+// llgo provides in place of the standard library to call the runtime.
+func llgoDirective(doc *ast.CommentGroup, key string) string {
+	if doc == nil {
+		return ""
+	}
+	prefix := "#llgo " + key + ":"
+	for _, comment := range doc.List {
+		text := comment.Text
+		if strings.HasPrefix(text, "//") {
+			text = strings.TrimSpace(text[2:])
+			if strings.HasPrefix(text, prefix) {
+				return strings.TrimSpace(text[len(prefix):])
+			}
+		}
+	}
+	return ""
+}
+
+// commentDirective returns the argument of a "//name" directive comment,
+// as used by gccgo and cgo, or the empty string if there is none. For
+// example, a function declared without a body and with the comment
+//
+//	//extern strlen
+//
+// refers to the C function strlen, and a function with the comment
+//
+//	//export Add
+//
+// may be called from C as Add.
+func commentDirective(doc *ast.CommentGroup, name string) string {
+	if doc == nil {
+		return ""
+	}
+	prefix := "//" + name + " "
+	for _, comment := range doc.List {
+		if strings.HasPrefix(comment.Text, prefix) {
+			return strings.TrimSpace(comment.Text[len(prefix):])
+		}
+	}
+	return ""
+}
+
 func (c *compiler) VisitFuncProtoDecl(f *ast.FuncDecl) *LLVMValue {
 	var fn_type *types.Func
 	fn_name := f.Name.String()
@@ -46,27 +94,49 @@ func (c *compiler) VisitFuncProtoDecl(f *ast.FuncDecl) *LLVMValue {
 		fn_type = &types.Func{ /* no params or result */}
 	} else {
 		fn_type = f.Name.Obj.Type.(*types.Func)
-		if c.module.Name == "main" && fn_name == "main" {
+		if c.module.Name == "main" && fn_name == "main" && f.Recv == nil {
+			// main.main is called by the C main function; see
+			// createMainFunction.
 			exported = true
-		} else {
-			if f.Recv != nil {
-				recv := fn_type.Recv
-				if recvtyp, ok := recv.Type.(*types.Pointer); ok {
-					recv = recvtyp.Base.(*types.Name).Obj
-				}
-				pkgname := c.pkgmap[recv]
-				fn_name = pkgname + "." + recv.Name + "." + fn_name
-			} else {
-				pkgname := c.pkgmap[f.Name.Obj]
-				fn_name = pkgname + "." + fn_name
+		}
+		if fn_type.Recv != nil {
+			recv := fn_type.Recv
+			if recvtyp, ok := recv.Type.(*types.Pointer); ok {
+				recv = recvtyp.Base.(*types.Name).Obj
 			}
+			pkgname := c.pkgmap[recv]
+			fn_name = pkgname + "." + recv.Name + "." + fn_name
+		} else {
+			pkgname := c.pkgmap[f.Name.Obj]
+			fn_name = pkgname + "." + fn_name
 		}
 	}
 
-	llvm_fn_type := c.types.ToLLVM(fn_type).ElementType()
-	fn := llvm.AddFunction(c.module.Module, fn_name, llvm_fn_type)
-	if exported {
-		fn.SetLinkage(llvm.ExternalLinkage)
+	if name := llgoDirective(f.Doc, "name"); name != "" {
+		fn_name = name
+		exported = true
+	}
+
+	// External C functions are called by their unmangled names, with the
+	// C calling convention and ABI; see declareCFunction.
+	extern := ""
+	if f.Body == nil && f.Recv == nil {
+		extern = commentDirective(f.Doc, "extern")
+	}
+	if extern != "" {
+		fn_name = extern
+		exported = true
+	}
+
+	llvm_fn_type := c.types.rawFuncLLVMType(fn_type).ElementType()
+	var fn llvm.Value
+	if extern != "" {
+		fn = c.declareCFunction(fn_name, llvm_fn_type)
+	} else {
+		fn = llvm.AddFunction(c.module.Module, fn_name, llvm_fn_type)
+		if exported {
+			fn.SetLinkage(llvm.ExternalLinkage)
+		}
 	}
 
 	result := c.NewLLVMValue(fn, fn_type)
@@ -77,23 +147,59 @@ func (c *compiler) VisitFuncProtoDecl(f *ast.FuncDecl) *LLVMValue {
 	return result
 }
 
-// buildFunction takes a function Value, a list of parameters, and a body,
-// and generates code for the function.
-func (c *compiler) buildFunction(f *LLVMValue, params []*ast.Object, body *ast.BlockStmt) {
+// buildFunction takes a function Value, a list of captured variables, a
+// list of parameters, and a body, and generates code for the function. If
+// there are captured variables, then the function's first parameter is a
+// pointer to a context containing pointers to the variables.
+func (c *compiler) buildFunction(f *LLVMValue, captures, params []*ast.Object, body *ast.BlockStmt) {
 	ftyp := f.Type().(*types.Func)
 	llvm_fn := f.LLVMValue()
-	entry := llvm.AddBasicBlock(llvm_fn, "entry")
+	entry := c.context.AddBasicBlock(llvm_fn, "entry")
 	c.builder.SetInsertPointAtEnd(entry)
+	c.recordFunction(llvm_fn, body.Pos())
+	c.analyseEscapes(ftyp, params, body)
+	c.pushDebugContext(f, body.Pos())
+	outerroots := c.gcroots
+	c.gcroots = nil
+	outerlabels := c.labels
+	c.labels = make(map[string]*labelInfo)
+	outerdefers := c.deferframe
+	c.deferframe = llvm.Value{}
+	if hasDefer(body) {
+		c.deferframe = c.createDeferFrame()
+	}
+
+	// Bind captured variables to the pointers stored in the context.
+	paramOffset := 0
+	if len(captures) > 0 {
+		ctx_type := c.contextType(captures)
+		if c.preciseGC {
+			// Keep the context alive while the function runs.
+			ctxroot := c.createGCRoot(nil, llvm_fn.Param(0).Type(), "")
+			c.builder.CreateStore(llvm_fn.Param(0), ctxroot)
+		}
+		ctx := c.builder.CreateBitCast(
+			llvm_fn.Param(0), llvm.PointerType(ctx_type, 0), "")
+		for i, obj := range captures {
+			typ := obj.Data.(*LLVMValue).Type()
+			ptr := c.builder.CreateStructGEP(ctx, i, "")
+			ptr = c.builder.CreateLoad(ptr, obj.Name)
+			ptrvalue := c.NewLLVMValue(ptr, &types.Pointer{Base: typ})
+			obj.Data = ptrvalue.makePointee()
+		}
+		paramOffset = 1
+	}
 
 	// Bind receiver, arguments and return values to their identifiers/objects.
 	// We'll store each parameter on the stack so they're addressable.
 	for i, obj := range params {
 		if obj.Name != "" {
-			value := llvm_fn.Param(i)
+			value := llvm_fn.Param(i + paramOffset)
 			typ := obj.Type.(types.Type)
-			stackvalue := c.builder.CreateAlloca(c.types.ToLLVM(typ), obj.Name)
+			stackvalue := c.allocateVar(obj, c.types.ToLLVM(typ))
 			c.builder.CreateStore(value, stackvalue)
 			ptrvalue := c.NewLLVMValue(stackvalue, &types.Pointer{Base: typ})
+			c.debugDeclare(obj, ptrvalue, i+1)
 			obj.Data = ptrvalue.makePointee()
 		}
 	}
@@ -103,9 +209,10 @@ func (c *compiler) buildFunction(f *LLVMValue, params []*ast.Object, body *ast.B
 		if obj.Name != "" {
 			typ := obj.Type.(types.Type)
 			llvmtyp := c.types.ToLLVM(typ)
-			stackptr := c.builder.CreateAlloca(llvmtyp, obj.Name)
-			c.builder.CreateStore(llvm.ConstNull(llvmtyp), stackptr)
+			stackptr := c.allocateVar(obj, llvmtyp)
+			c.storeZero(stackptr)
 			ptrvalue := c.NewLLVMValue(stackptr, &types.Pointer{Base: typ})
+			c.debugDeclare(obj, ptrvalue, 0)
 			obj.Data = ptrvalue.makePointee()
 		}
 	}
@@ -117,8 +224,15 @@ func (c *compiler) buildFunction(f *LLVMValue, params []*ast.Object, body *ast.B
 	if in := last.LastInstruction(); in.IsNil() || in.IsATerminatorInst().IsNil() {
 		// Assume nil return type, AST should be checked first.
 		c.builder.SetInsertPointAtEnd(last)
+		c.runDefers()
 		c.builder.CreateRetVoid()
 	}
+	c.declareGCRoots(llvm_fn)
+	c.gcroots = outerroots
+	c.labels = outerlabels
+	c.deferframe = outerdefers
+	c.popDebugContext()
+	c.verifyFunction(llvm_fn)
 }
 
 func (c *compiler) VisitFuncDecl(f *ast.FuncDecl) Value {
@@ -137,7 +251,14 @@ func (c *compiler) VisitFuncDecl(f *ast.FuncDecl) Value {
 	if f.Recv != nil {
 		paramObjects = append([]*ast.Object{fn_type.Recv}, paramObjects...)
 	}
-	c.buildFunction(fn, paramObjects, f.Body)
+	c.buildFunction(fn, nil, paramObjects, f.Body)
+	if commentDirective(f.Doc, "export") != "" {
+		if f.Recv != nil {
+			c.errorf(f.Pos(), "cannot export method %s", f.Name.Name)
+		} else {
+			c.exports = append(c.exports, f)
+		}
+	}
 
 	// Is it an 'init' function? Then record it.
 	if f.Name.Name == "init" {
@@ -155,8 +276,9 @@ func isArray(t types.Type) bool {
 	return isarray
 }
 
-// Create a constructor function which initialises a global.
-// TODO collapse all global inits into one init function?
+// Create a global variable. If the initialiser is not constant, a function
+// which initialises the global is created, to be called by the package's
+// init function.
 func (c *compiler) createGlobal(e ast.Expr, t types.Type, name string, export bool) (g *LLVMValue) {
 	if e == nil {
 		llvmtyp := c.types.ToLLVM(t)
@@ -172,36 +294,32 @@ func (c *compiler) createGlobal(e ast.Expr, t types.Type, name string, export bo
 		return g
 	}
 
-	if block := c.builder.GetInsertBlock(); !block.IsNil() {
-		defer c.builder.SetInsertPointAtEnd(block)
-	}
+	defer c.restoreInsertPoint(c.saveInsertPoint())
 	fn_type := new(types.Func)
-	llvm_fn_type := c.types.ToLLVM(fn_type).ElementType()
+	llvm_fn_type := c.types.rawFuncLLVMType(fn_type).ElementType()
 	fn := llvm.AddFunction(c.module.Module, "", llvm_fn_type)
 	fn.SetLinkage(llvm.PrivateLinkage)
-	entry := llvm.AddBasicBlock(fn, "entry")
+	entry := c.context.AddBasicBlock(fn, "entry")
 	c.builder.SetInsertPointAtEnd(entry)
 
-	// Visit the expression. Dereference if necessary, and generalise
-	// the type if one hasn't been specified.
-	init_ := c.VisitExpr(e)
-	_, isconst := init_.(ConstValue)
-	if t == nil {
-		t = init_.Type()
-	} else {
-		init_ = init_.Convert(t)
-	}
-
-	// If the result is a constant, discard the function before
-	// creating any llvm.Value's.
+	// If the initialiser can be computed at compile time, it becomes the
+	// global's initialiser and the function is discarded. Otherwise the
+	// function evaluates the expression and stores it in the global when
+	// the package is initialised.
+	var init_ Value
+	value, valuetyp, isconst := c.constInitializer(e, t)
 	if isconst {
+		t = valuetyp
 		fn.EraseFromParentAsFunction()
-		fn = llvm.Value{nil}
+	} else {
+		init_ = c.VisitExpr(e)
+		if t == nil {
+			t = init_.Type()
+		} else {
+			init_ = init_.Convert(t)
+		}
 	}
 
-	// Set the initialiser. If it's a non-const value, then
-	// we'll have to do the assignment in a global constructor
-	// function.
 	gv := llvm.AddGlobal(c.module.Module, c.types.ToLLVM(t), name)
 	if !export {
 		gv.SetLinkage(llvm.PrivateLinkage)
@@ -211,20 +329,148 @@ func (c *compiler) createGlobal(e ast.Expr, t types.Type, name string, export bo
 		g = g.makePointee()
 	}
 	if isconst {
-		// Initialiser is constant; discard function and return global now.
-		gv.SetInitializer(init_.LLVMValue())
-	} else {
-		gv.SetInitializer(llvm.ConstNull(c.types.ToLLVM(t)))
-		c.builder.CreateStore(init_.LLVMValue(), gv)
+		gv.SetInitializer(value)
+		return g
 	}
+	gv.SetInitializer(llvm.ConstNull(c.types.ToLLVM(t)))
+	c.storeValue(init_, gv)
+	c.builder.CreateRetVoid()
+	c.varinitfuncs = append(c.varinitfuncs, c.NewLLVMValue(fn, fn_type))
+	return g
+}
 
-	if !fn.IsNil() {
-		c.builder.CreateRetVoid()
-		// FIXME order global ctors
-		fn_value := c.NewLLVMValue(fn, fn_type)
-		c.varinitfuncs = append(c.varinitfuncs, fn_value)
+// constInitializer returns the value of a package-level variable's
+// initialiser as an LLVM constant, along with its type, if the value can
+// be computed at compile time. That is the case for constant expressions,
+// and for array and struct literals whose elements are themselves
+// constant initialisers. The type t may be nil, if the variable's type is
+// taken from its initialiser.
+func (c *compiler) constInitializer(e ast.Expr, t types.Type) (llvm.Value, types.Type, bool) {
+	if t != nil {
+		if _, ok := types.Underlying(t).(*types.Interface); ok {
+			return llvm.Value{}, nil, false
+		}
 	}
-	return g
+
+	switch e := e.(type) {
+	case *ast.ParenExpr:
+		return c.constInitializer(e.X, t)
+	case *ast.CompositeLit:
+		return c.constCompositeLit(e, t)
+	}
+	if !isConstExpr(e) {
+		return llvm.Value{}, nil, false
+	}
+	switch value := c.VisitExpr(e).(type) {
+	case ConstValue:
+		defer c.positionPanic(e.Pos())
+		if t != nil {
+			var ok bool
+			if value, ok = value.Convert(t).(ConstValue); !ok {
+				return llvm.Value{}, nil, false
+			}
+		}
+		return value.LLVMValue(), value.Type(), true
+	case NilValue:
+		if t != nil {
+			return llvm.ConstNull(c.types.ToLLVM(t)), t, true
+		}
+	}
+	return llvm.Value{}, nil, false
+}
+
+// convertConst converts the value of the constant expression e to the
+// type t, reporting a value that is not representable by t at the
+// position of e rather than that of its declaration.
+func (c *compiler) convertConst(e ast.Expr, value Value, t types.Type) Value {
+	defer c.positionPanic(e.Pos())
+	return value.Convert(t)
+}
+
+// constCompositeLit returns the value of an array or struct composite
+// literal as an LLVM constant, if each of its elements is a constant
+// initialiser.
+func (c *compiler) constCompositeLit(lit *ast.CompositeLit, t types.Type) (llvm.Value, types.Type, bool) {
+	var typ types.Type
+	switch littyp := lit.Type.(type) {
+	case nil:
+		typ = c.types.expr[lit]
+	case *ast.ArrayType:
+		if _, ok := littyp.Len.(*ast.Ellipsis); ok {
+			typ = c.types.expr[lit]
+		} else {
+			typ = c.GetType(littyp)
+		}
+	default:
+		typ = c.GetType(littyp)
+	}
+	if typ == nil || (t != nil && !types.Identical(typ, t)) {
+		return llvm.Value{}, nil, false
+	}
+
+	switch utyp := types.Underlying(typ).(type) {
+	case *types.Array:
+		elttyp := c.types.ToLLVM(utyp.Elt)
+		values := make([]llvm.Value, utyp.Len)
+		index := 0
+		for _, elt := range lit.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				index = int(c.VisitExpr(kv.Key).(ConstValue).Int64())
+				elt = kv.Value
+			}
+			value, _, ok := c.constInitializer(elt, utyp.Elt)
+			if !ok {
+				return llvm.Value{}, nil, false
+			}
+			values[index] = value
+			index++
+		}
+		for i, value := range values {
+			if value.IsNil() {
+				values[i] = llvm.ConstNull(elttyp)
+			}
+		}
+		return llvm.ConstArray(elttyp, values), typ, true
+
+	case *types.Struct:
+		values := make([]llvm.Value, len(utyp.Fields))
+		for i, elt := range lit.Elts {
+			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				i = int(utyp.FieldIndices[kv.Key.(*ast.Ident).Name])
+				elt = kv.Value
+			}
+			value, _, ok := c.constInitializer(elt, c.ObjGetType(utyp.Fields[i]))
+			if !ok {
+				return llvm.Value{}, nil, false
+			}
+			values[i] = value
+		}
+		for i, value := range values {
+			if value.IsNil() {
+				values[i] = llvm.ConstNull(c.types.ToLLVM(c.ObjGetType(utyp.Fields[i])))
+			}
+		}
+		return llvm.ConstNamedStruct(c.types.ToLLVM(typ), values), typ, true
+	}
+	return llvm.Value{}, nil, false
+}
+
+// createBlankInit creates a function which evaluates the initialiser of a
+// package-level blank variable for its side effects, to be called by the
+// package's init function.
+func (c *compiler) createBlankInit(e ast.Expr) *LLVMValue {
+	defer c.restoreInsertPoint(c.saveInsertPoint())
+	fn_type := new(types.Func)
+	llvm_fn_type := c.types.rawFuncLLVMType(fn_type).ElementType()
+	fn := llvm.AddFunction(c.module.Module, "", llvm_fn_type)
+	fn.SetLinkage(llvm.PrivateLinkage)
+	entry := c.context.AddBasicBlock(fn, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	c.VisitExpr(e)
+	c.builder.CreateRetVoid()
+	fn_value := c.NewLLVMValue(fn, fn_type)
+	c.varinitfuncs = append(c.varinitfuncs, fn_value)
+	return fn_value
 }
 
 func (c *compiler) VisitValueSpec(valspec *ast.ValueSpec, isconst bool) {
@@ -233,6 +479,14 @@ func (c *compiler) VisitValueSpec(valspec *ast.ValueSpec, isconst bool) {
 		iota_obj.Data = data
 	}(iota_obj.Data)
 
+	// Multi-value initialisers (e.g. "var v, ok = x.(T)") must be
+	// evaluated once, up front.
+	var values []Value
+	ispackagelevel := len(c.functions) == 0
+	if !isconst && !ispackagelevel && len(valspec.Values) == 1 && len(valspec.Names) > 1 {
+		values = c.destructureExpr(valspec.Values[0])
+	}
+
 	var value_type types.Type
 	for i, name_ := range valspec.Names {
 		// We may resolve constants in the process of resolving others.
@@ -244,7 +498,7 @@ func (c *compiler) VisitValueSpec(valspec *ast.ValueSpec, isconst bool) {
 		// Expression may have side-effects, so compute it regardless of
 		// whether it'll be assigned to a name.
 		var expr ast.Expr
-		if i < len(valspec.Values) && valspec.Values[i] != nil {
+		if values == nil && i < len(valspec.Values) && valspec.Values[i] != nil {
 			expr = valspec.Values[i]
 		}
 
@@ -264,7 +518,6 @@ func (c *compiler) VisitValueSpec(valspec *ast.ValueSpec, isconst bool) {
 		// If the name is "_", then we can just evaluate the expression
 		// and ignore the result. We handle package level variables
 		// specially, below.
-		ispackagelevel := len(c.functions) == 0
 		name := name_.String()
 		if name == "_" && !ispackagelevel {
 			if expr != nil {
@@ -284,39 +537,50 @@ func (c *compiler) VisitValueSpec(valspec *ast.ValueSpec, isconst bool) {
 			if !ispackagelevel {
 				// Visit the expression.
 				var init_ Value
-				if expr != nil {
+				if values != nil {
+					init_ = values[i]
+				} else if expr != nil {
 					init_ = c.VisitExpr(expr)
-					if value_type == nil {
-						value_type = init_.Type()
-					}
+				}
+				if init_ != nil && valspec.Type == nil {
+					value_type = init_.Type()
 				}
 
 				// The variable should be allocated on the stack if it's
 				// declared inside a function.
-				var llvm_init llvm.Value
-				stack_value := c.builder.CreateAlloca(
-					c.types.ToLLVM(value_type), name)
+				stack_value := c.allocateVar(
+					name_.Obj, c.types.ToLLVM(value_type))
 				if init_ == nil {
 					// If no initialiser was specified, set it to the
 					// zero value.
-					llvm_init = llvm.ConstNull(c.types.ToLLVM(value_type))
+					c.storeZero(stack_value)
 				} else {
-					llvm_init = init_.Convert(value_type).LLVMValue()
+					c.storeValue(c.convertValue(init_, value_type), stack_value)
 				}
-				c.builder.CreateStore(llvm_init, stack_value)
 				llvm_value := c.NewLLVMValue(stack_value, &types.Pointer{Base: value_type})
+				c.debugDeclare(name_.Obj, llvm_value, 0)
 				value = llvm_value.makePointee()
+			} else if name == "_" {
+				// Package-level blank variables are never stored to, so
+				// no global is created. The function that evaluates the
+				// initialiser is recorded instead, so the initialiser is
+				// only evaluated once.
+				if expr != nil {
+					value = c.createBlankInit(expr)
+				}
 			} else { // ispackagelevel
 				// Set the initialiser. If it's a non-const value, then
 				// we'll have to do the assignment in a global constructor
 				// function.
 				export := name_.IsExported()
+				name = c.pkgmap[name_.Obj] + "." + name
 				value = c.createGlobal(expr, value_type, name, export)
+				c.debugGlobal(name_, value.(*LLVMValue))
 			}
 		} else { // isconst
 			value = c.VisitExpr(expr).(ConstValue)
 			if value_type != nil {
-				value = value.Convert(value_type)
+				value = c.convertConst(expr, value, value_type)
 			}
 		}
 		obj.Data = value
@@ -375,17 +639,10 @@ func (c *compiler) VisitGenDecl(decl *ast.GenDecl) {
 }
 
 func (c *compiler) VisitDecl(decl ast.Decl) Value {
-	// This is temporary. We'll return errors later, rather than panicking.
 	if c.logger != nil {
 		c.logger.Println("Compile declaration:", c.fileset.Position(decl.Pos()))
 	}
-	defer func() {
-		if e := recover(); e != nil {
-			elist := new(scanner.ErrorList)
-			elist.Add(c.fileset.Position(decl.Pos()), fmt.Sprint(e))
-			panic(elist)
-		}
-	}()
+	defer c.positionPanic(decl.Pos())
 
 	switch x := decl.(type) {
 	case *ast.FuncDecl:
@@ -394,9 +651,8 @@ func (c *compiler) VisitDecl(decl ast.Decl) Value {
 		c.VisitGenDecl(x)
 		return nil
 	}
-	panic(fmt.Sprintf("Unhandled decl (%s) at %s\n",
-		reflect.TypeOf(decl),
-		c.fileset.Position(decl.Pos())))
+	c.fatalf(decl.Pos(), "unhandled declaration: %s", reflect.TypeOf(decl))
+	panic("unreachable")
 }
 
 // vim: set ft=go :
@@ -0,0 +1,102 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package llgo
+
+import (
+	"github.com/axw/gollvm/llvm"
+	"go/ast"
+)
+
+// Each function containing defer statements keeps a list of its deferred
+// calls, most recent first, in a frame on its stack, which is pushed onto
+// the goroutine's list of such frames on entry to the function:
+//
+//     struct deferframe { deferframe *prev; defer *defers; };
+//
+// A defer statement evaluates the function and arguments of its call into
+// a record on the heap, which is pushed onto the frame's list:
+//
+//     struct defer { defer *next; void (*fn)(defer*); args... };
+//
+// The calls are made by runtime.rundefers when the function returns, after
+// the results have been stored in the named results, so that deferred
+// function literals may modify them; it then pops the frame. There is no
+// support for recover, so a panic is fatal, but the runtime makes the
+// deferred calls of every frame on the goroutine's list before printing
+// the panic and exiting.
+
+// hasDefer reports whether the function body contains a defer statement,
+// excluding those in function literals.
+func hasDefer(body *ast.BlockStmt) bool {
+	found := false
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch n.(type) {
+		case *ast.DeferStmt:
+			found = true
+		case *ast.FuncLit:
+			return false
+		}
+		return !found
+	})
+	return found
+}
+
+// createDeferFrame allocates the current function's defer frame, and
+// pushes it onto the goroutine's list.
+func (c *compiler) createDeferFrame() llvm.Value {
+	i8ptr := llvm.PointerType(c.context.Int8Type(), 0)
+	frametyp := c.context.StructType([]llvm.Type{i8ptr, i8ptr}, false)
+	frame := c.allocateVar(nil, frametyp)
+	c.storeZero(frame)
+	pushdeferframe := c.NamedFunction("runtime.pushdeferframe", "func f(f unsafe.Pointer)")
+	c.builder.CreateCall(pushdeferframe, []llvm.Value{c.builder.CreateBitCast(frame, i8ptr, "")}, "")
+	return frame
+}
+
+func (c *compiler) VisitDeferStmt(stmt *ast.DeferStmt) {
+	i8ptr := llvm.PointerType(c.context.Int8Type(), 0)
+	rectyp, values, fn := c.indirectCall(stmt.Call, []llvm.Type{i8ptr, i8ptr})
+	rec := c.createTypeMalloc(rectyp)
+	defers := c.builder.CreateStructGEP(c.deferframe, 1, "")
+	next := c.builder.CreateLoad(defers, "")
+	c.builder.CreateStore(next, c.builder.CreateStructGEP(rec, 0, ""))
+	fnptr := c.builder.CreateBitCast(fn, i8ptr, "")
+	c.builder.CreateStore(fnptr, c.builder.CreateStructGEP(rec, 1, ""))
+	for i, value := range values {
+		c.builder.CreateStore(value, c.builder.CreateStructGEP(rec, i+2, ""))
+	}
+	c.builder.CreateStore(c.builder.CreateBitCast(rec, i8ptr, ""), defers)
+}
+
+// runDefers makes the current function's deferred calls, if it has any,
+// and pops its defer frame.
+func (c *compiler) runDefers() {
+	if c.deferframe.IsNil() {
+		return
+	}
+	i8ptr := llvm.PointerType(c.context.Int8Type(), 0)
+	rundefers := c.NamedFunction("runtime.rundefers", "func f(f unsafe.Pointer)")
+	c.builder.CreateCall(rundefers, []llvm.Value{c.builder.CreateBitCast(c.deferframe, i8ptr, "")}, "")
+}
+
+// vim: set ft=go :
@@ -0,0 +1,169 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package llgo
+
+import (
+	"fmt"
+	"go/ast"
+	"go/scanner"
+	"go/token"
+	"runtime"
+)
+
+// Severity classifies a Diagnostic.
+type Severity int
+
+const (
+	// Error diagnostics prevent the package from being compiled.
+	Error Severity = iota
+
+	// Warning diagnostics do not prevent compilation.
+	Warning
+)
+
+func (s Severity) String() string {
+	switch s {
+	case Error:
+		return "error"
+	case Warning:
+		return "warning"
+	}
+	return fmt.Sprintf("Severity(%d)", int(s))
+}
+
+// Diagnostic is a message reported while compiling a package, tagged with
+// the position in the source to which it refers. The position is invalid
+// for diagnostics that do not refer to the source, such as a failure to
+// create the target machine.
+type Diagnostic struct {
+	Severity Severity
+	Pos      token.Position
+	Msg      string
+}
+
+// String formats the diagnostic as "file:line:column: message", as go/scanner
+// does for errors; warnings are marked as such.
+func (d Diagnostic) String() string {
+	msg := d.Msg
+	if d.Severity != Error {
+		msg = d.Severity.String() + ": " + msg
+	}
+	if d.Pos.IsValid() {
+		return d.Pos.String() + ": " + msg
+	}
+	return msg
+}
+
+// errorDiagnostics converts an error returned while compiling a package
+// into error diagnostics, one for each error in a scanner.ErrorList.
+func errorDiagnostics(err error) []Diagnostic {
+	switch err := err.(type) {
+	case nil:
+		return nil
+	case scanner.ErrorList:
+		err.Sort()
+		diagnostics := make([]Diagnostic, len(err))
+		for i, e := range err {
+			diagnostics[i] = Diagnostic{Error, e.Pos, e.Msg}
+		}
+		return diagnostics
+	case *scanner.Error:
+		return []Diagnostic{{Error, err.Pos, err.Msg}}
+	}
+	return []Diagnostic{{Severity: Error, Msg: err.Error()}}
+}
+
+// compileError is an error from which the compiler cannot recover within
+// the current declaration. It is raised with panic, and recovered by
+// compileDecl, which records it and moves on to the next declaration.
+type compileError struct {
+	pos token.Pos
+	msg string
+}
+
+func (e compileError) Error() string {
+	return e.msg
+}
+
+// errorf records an error at the specified position. Compilation
+// continues, but Compile will report the recorded errors rather than
+// returning a module.
+func (c *compiler) errorf(pos token.Pos, format string, args ...interface{}) {
+	c.errors.Add(c.fileset.Position(pos), fmt.Sprintf(format, args...))
+}
+
+// fatalf raises an error at the specified position, abandoning the
+// compilation of the current declaration.
+func (c *compiler) fatalf(pos token.Pos, format string, args ...interface{}) {
+	panic(compileError{pos, fmt.Sprintf(format, args...)})
+}
+
+// positionPanic converts a panic raised while compiling the node at pos
+// into a compileError at that position. Panics that are already
+// compileErrors, and runtime errors, which indicate a bug in the compiler,
+// are propagated unchanged. It must be deferred directly.
+func (c *compiler) positionPanic(pos token.Pos) {
+	if e := recover(); e != nil {
+		switch e.(type) {
+		case compileError, runtime.Error:
+			panic(e)
+		}
+		panic(compileError{pos, fmt.Sprint(e)})
+	}
+}
+
+// compileDecl compiles a top-level declaration, recording any compileError
+// or typeError raised and restoring the compiler's state so that compilation may
+// continue with the next declaration.
+func (c *compiler) compileDecl(decl ast.Decl) {
+	functions := c.functions
+	breakblocks := c.breakblocks
+	continueblocks := c.continueblocks
+	labels := c.labels
+	var debugContext int
+	if c.debug != nil {
+		debugContext = len(c.debug.context)
+	}
+	defer func() {
+		if e := recover(); e != nil {
+			switch err := e.(type) {
+			case compileError:
+				c.errorf(err.pos, "%s", err.msg)
+			case typeError:
+				c.errorf(decl.Pos(), "%s", err)
+			default:
+				panic(e)
+			}
+			c.functions = functions
+			c.breakblocks = breakblocks
+			c.continueblocks = continueblocks
+			c.labels = labels
+			if c.debug != nil {
+				c.debug.context = c.debug.context[:debugContext]
+			}
+		}
+	}()
+	c.VisitDecl(decl)
+}
+
+// vim: set ft=go :
@@ -0,0 +1,449 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package llgo
+
+import (
+	"github.com/axw/llgo/types"
+	"go/ast"
+	"go/token"
+)
+
+// escapeState holds the state of the escape analysis of a function body.
+//
+// The analysis is flow-insensitive and intraprocedural. A variable must be
+// heap allocated if it is captured by a function literal, or if its
+// address (or a slice of it, for arrays) may outlive the function: that
+// is, if the address is returned, passed to a function, stored anywhere
+// other than in a local variable, or converted to an interface. Addresses
+// stored in local variables are tracked, so that variables whose address
+// is only ever dereferenced locally may remain on the stack. A value that
+// is loaded through a pointer, and so may itself be one of the tracked
+// addresses, is tracked along with the pointer it was loaded through.
+type escapeState struct {
+	*compiler
+	body   *ast.BlockStmt
+	locals map[*ast.Object]bool
+
+	// heap records the variables that must be heap allocated.
+	heap map[*ast.Object]bool
+
+	// leaks records the variables whose values may outlive the function,
+	// or be stored where the analysis cannot follow them.
+	leaks map[*ast.Object]bool
+
+	// addrs records, for each local variable, the variables whose
+	// addresses may be stored in it; values records the local variables
+	// whose values may be stored in it.
+	addrs  map[*ast.Object][]*ast.Object
+	values map[*ast.Object][]*ast.Object
+
+	// loads records, for each local variable, the local variables through
+	// which the values stored in it may have been loaded; pointeesLeak
+	// records the variables whose pointees' values may outlive the
+	// function.
+	loads        map[*ast.Object][]*ast.Object
+	pointeesLeak map[*ast.Object]bool
+
+	// safe records the identifiers, address-of and slice expressions
+	// whose use has been accounted for by an enclosing node.
+	safe map[ast.Node]bool
+}
+
+// analyseEscapes determines which of the variables in a function (its
+// parameters, named results, and the variables declared in its body) must
+// be heap allocated, and records them in c.escaping for allocateVar.
+func (c *compiler) analyseEscapes(ftyp *types.Func, params []*ast.Object, body *ast.BlockStmt) {
+	e := &escapeState{
+		compiler: c,
+		body:     body,
+		locals:   make(map[*ast.Object]bool),
+		heap:     make(map[*ast.Object]bool),
+		leaks:    make(map[*ast.Object]bool),
+		addrs:    make(map[*ast.Object][]*ast.Object),
+		values:   make(map[*ast.Object][]*ast.Object),
+		safe:     make(map[ast.Node]bool),
+
+		loads:        make(map[*ast.Object][]*ast.Object),
+		pointeesLeak: make(map[*ast.Object]bool),
+	}
+	for _, obj := range params {
+		e.locals[obj] = true
+	}
+	for _, obj := range ftyp.Results {
+		// Named results are returned by bare return statements.
+		e.locals[obj] = true
+		e.leaks[obj] = true
+	}
+	ast.Inspect(body, e.visit)
+
+	// If a variable leaks, then so do the values stored in it, and the
+	// variables whose addresses are stored in it must be on the heap. So
+	// do the values of the variables that may be pointed to by the
+	// pointers through which its value was loaded.
+	var work, pointeesWork []*ast.Object
+	for obj := range e.leaks {
+		work = append(work, obj)
+	}
+	for obj := range e.pointeesLeak {
+		pointeesWork = append(pointeesWork, obj)
+	}
+	leak := func(obj *ast.Object) {
+		if !e.leaks[obj] {
+			e.leaks[obj] = true
+			work = append(work, obj)
+		}
+	}
+	leakPointees := func(obj *ast.Object) {
+		if !e.pointeesLeak[obj] {
+			e.pointeesLeak[obj] = true
+			pointeesWork = append(pointeesWork, obj)
+		}
+	}
+	for len(work) > 0 || len(pointeesWork) > 0 {
+		if len(work) > 0 {
+			obj := work[len(work)-1]
+			work = work[:len(work)-1]
+			for _, v := range e.addrs[obj] {
+				e.heap[v] = true
+				leak(v)
+			}
+			for _, v := range e.values[obj] {
+				leak(v)
+			}
+			for _, v := range e.loads[obj] {
+				leakPointees(v)
+			}
+			continue
+		}
+
+		// The variables that obj may point to are those whose addresses
+		// are stored in it, and those pointed to by the variables whose
+		// values are stored in it. A pointer loaded through another
+		// points to the values of its pointees, which then leak.
+		obj := pointeesWork[len(pointeesWork)-1]
+		pointeesWork = pointeesWork[:len(pointeesWork)-1]
+		for _, v := range e.addrs[obj] {
+			leak(v)
+		}
+		for _, v := range append(e.values[obj], e.loads[obj]...) {
+			leakPointees(v)
+		}
+	}
+	for obj := range e.heap {
+		c.escaping[obj] = true
+	}
+}
+
+// isLocal reports whether obj is a variable local to the function being
+// analysed.
+func (e *escapeState) isLocal(obj *ast.Object) bool {
+	if obj == nil || obj.Kind != ast.Var {
+		return false
+	}
+	if e.locals[obj] {
+		return true
+	}
+	if decl, ok := obj.Decl.(ast.Node); ok {
+		pos := decl.Pos()
+		return pos >= e.body.Pos() && pos < e.body.End()
+	}
+	return false
+}
+
+// exprType returns the type of an expression, or nil if it is unknown.
+func (e *escapeState) exprType(x ast.Expr) types.Type {
+	if ident, ok := x.(*ast.Ident); ok && ident.Obj != nil {
+		if t, ok := ident.Obj.Type.(types.Type); ok {
+			return t
+		}
+	}
+	return e.types.expr[x]
+}
+
+func (e *escapeState) isArray(x ast.Expr) bool {
+	_, isarray := types.Underlying(e.exprType(x)).(*types.Array)
+	return isarray
+}
+
+// addrRoot returns the variable whose storage holds the value of the
+// addressable expression x, or nil if x refers to storage reached through
+// a pointer or slice.
+func (e *escapeState) addrRoot(x ast.Expr) *ast.Object {
+	switch x := x.(type) {
+	case *ast.ParenExpr:
+		return e.addrRoot(x.X)
+	case *ast.Ident:
+		if x.Obj != nil && x.Obj.Kind == ast.Var {
+			return x.Obj
+		}
+	case *ast.SelectorExpr:
+		if _, isptr := types.Underlying(e.exprType(x.X)).(*types.Pointer); !isptr {
+			return e.addrRoot(x.X)
+		}
+	case *ast.IndexExpr:
+		if e.isArray(x.X) {
+			return e.addrRoot(x.X)
+		}
+	}
+	return nil
+}
+
+// baseVar returns the local variable at the base of an operand, such as
+// p in p.f[i], marking the operand and its identifier as accounted for.
+func (e *escapeState) baseVar(x ast.Expr) *ast.Object {
+	e.safe[x] = true
+	switch x := x.(type) {
+	case *ast.ParenExpr:
+		return e.baseVar(x.X)
+	case *ast.SelectorExpr:
+		return e.baseVar(x.X)
+	case *ast.IndexExpr:
+		return e.baseVar(x.X)
+	case *ast.SliceExpr:
+		return e.baseVar(x.X)
+	case *ast.StarExpr:
+		return e.baseVar(x.X)
+	case *ast.Ident:
+		if e.isLocal(x.Obj) {
+			return x.Obj
+		}
+	}
+	return nil
+}
+
+// isLoad reports whether x loads a value from a variable's storage, or
+// through a pointer, slice or map, rather than selecting a method.
+func isLoad(x ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.StarExpr, *ast.IndexExpr:
+		return true
+	case *ast.SelectorExpr:
+		return x.Sel.Obj == nil || x.Sel.Obj.Kind != ast.Fun
+	}
+	return false
+}
+
+// mayHavePointers reports whether values of the type of x may contain
+// pointers, which is assumed if the type is unknown.
+func (e *escapeState) mayHavePointers(x ast.Expr) bool {
+	t := e.exprType(x)
+	return t == nil || hasPointers(t)
+}
+
+// source determines what the value of x may refer to: the storage of a
+// variable, if x takes the address of a variable or slices an array
+// variable; the value of a local variable, from which x is derived or in
+// whose storage x is loaded; or the pointees of a local variable, if x is
+// loaded through the pointer, slice or map held by the variable.
+func (e *escapeState) source(x ast.Expr) (addr, value, load *ast.Object) {
+	switch x := x.(type) {
+	case *ast.ParenExpr:
+		return e.source(x.X)
+	case *ast.Ident:
+		if e.isLocal(x.Obj) {
+			e.safe[x] = true
+			return nil, x.Obj, nil
+		}
+	case *ast.UnaryExpr:
+		if x.Op == token.AND {
+			e.safe[x] = true
+			if addr = e.addrRoot(x.X); addr != nil {
+				e.baseVar(x.X)
+				return addr, nil, nil
+			}
+			return nil, e.baseVar(x.X), nil
+		}
+	case *ast.SliceExpr:
+		e.safe[x] = true
+		if e.isArray(x.X) {
+			if addr = e.addrRoot(x.X); addr != nil {
+				e.baseVar(x.X)
+				return addr, nil, nil
+			}
+		}
+		return nil, e.baseVar(x.X), nil
+	case *ast.StarExpr, *ast.IndexExpr, *ast.SelectorExpr:
+		if !isLoad(x) {
+			break
+		}
+		e.safe[x] = true
+		if !e.mayHavePointers(x) {
+			e.baseVar(x)
+			return nil, nil, nil
+		}
+		if root := e.addrRoot(x); root != nil && e.isLocal(root) {
+			e.baseVar(x)
+			return nil, root, nil
+		}
+		return nil, nil, e.baseVar(x)
+	}
+	return nil, nil, nil
+}
+
+// assign records the flow of the value of rhs into the local variable lhs.
+func (e *escapeState) assign(lhs *ast.Object, rhs ast.Expr) {
+	addr, value, load := e.source(rhs)
+	if addr != nil {
+		e.addrs[lhs] = append(e.addrs[lhs], addr)
+	}
+	if value != nil {
+		e.values[lhs] = append(e.values[lhs], value)
+	}
+	if load != nil {
+		e.loads[lhs] = append(e.loads[lhs], load)
+	}
+}
+
+// escape records that the value of x may outlive the function.
+func (e *escapeState) escape(x ast.Expr) {
+	addr, value, load := e.source(x)
+	if addr != nil {
+		e.heap[addr] = true
+		e.leaks[addr] = true
+	}
+	if value != nil {
+		e.leaks[value] = true
+	}
+	if load != nil {
+		e.pointeesLeak[load] = true
+	}
+}
+
+func (e *escapeState) visit(n ast.Node) bool {
+	switch n := n.(type) {
+	case *ast.FuncLit:
+		// Captured variables are shared with the function literal, which
+		// may outlive the function. The literal's body is analysed when
+		// it is compiled.
+		for _, obj := range freeVars(n) {
+			e.heap[obj] = true
+			e.leaks[obj] = true
+		}
+		return false
+
+	case *ast.AssignStmt:
+		for i, lhs := range n.Lhs {
+			ident, ok := lhs.(*ast.Ident)
+			if !ok {
+				// Storing through a pointer or into an element does
+				// not load the value stored there.
+				e.baseVar(lhs)
+				continue
+			}
+			if !e.isLocal(ident.Obj) {
+				continue
+			}
+			e.safe[ident] = true
+			if len(n.Lhs) == len(n.Rhs) && (n.Tok == token.DEFINE || n.Tok == token.ASSIGN) {
+				e.assign(ident.Obj, n.Rhs[i])
+			}
+		}
+
+	case *ast.ValueSpec:
+		for i, name := range n.Names {
+			e.safe[name] = true
+			if len(n.Names) == len(n.Values) && e.isLocal(name.Obj) {
+				e.assign(name.Obj, n.Values[i])
+			}
+		}
+
+	case *ast.RangeStmt:
+		// Ranging over a value copies its elements, which may be
+		// pointers stored in an array variable or reached through the
+		// slice or map being ranged over.
+		for _, x := range []ast.Expr{n.Key, n.Value} {
+			if ident, ok := x.(*ast.Ident); ok {
+				e.safe[ident] = true
+			}
+		}
+		var root *ast.Object
+		if e.isArray(n.X) {
+			root = e.addrRoot(n.X)
+		}
+		base := e.baseVar(n.X)
+		if ident, ok := n.Value.(*ast.Ident); ok && e.isLocal(ident.Obj) && e.mayHavePointers(ident) {
+			if root != nil && e.isLocal(root) {
+				e.values[ident.Obj] = append(e.values[ident.Obj], root)
+			} else if base != nil {
+				e.loads[ident.Obj] = append(e.loads[ident.Obj], base)
+			}
+		}
+
+	case *ast.IncDecStmt:
+		e.baseVar(n.X)
+
+	case *ast.StarExpr, *ast.IndexExpr:
+		// Loading through a pointer, or from an array, slice or map,
+		// copies the value loaded, but the value may be a pointer that
+		// was stored in a variable's storage or reached through the
+		// pointer.
+		if !e.safe[n] {
+			e.escape(n.(ast.Expr))
+		}
+
+	case *ast.SelectorExpr:
+		// So does selecting a field, but a method value refers to its
+		// receiver. A method with a pointer receiver, selected on an
+		// addressable value, implicitly takes the value's address.
+		if isLoad(n) {
+			if !e.safe[n] {
+				e.escape(n)
+			}
+		} else if ftyp, ok := n.Sel.Obj.Type.(*types.Func); ok && ftyp.Recv != nil {
+			_, ptrrecv := ftyp.Recv.Type.(*types.Pointer)
+			_, isptr := types.Underlying(e.exprType(n.X)).(*types.Pointer)
+			if v := e.addrRoot(n.X); ptrrecv && !isptr && v != nil {
+				e.heap[v] = true
+				e.leaks[v] = true
+			}
+		}
+
+	case *ast.BinaryExpr:
+		// Comparing pointers does not leak them.
+		if n.Op == token.EQL || n.Op == token.NEQ {
+			for _, x := range []ast.Expr{n.X, n.Y} {
+				if ident, ok := x.(*ast.Ident); ok {
+					e.safe[ident] = true
+				}
+			}
+		}
+
+	case *ast.UnaryExpr:
+		if n.Op == token.AND && !e.safe[n] {
+			e.escape(n)
+		}
+
+	case *ast.SliceExpr:
+		if !e.safe[n] {
+			e.escape(n)
+		}
+
+	case *ast.Ident:
+		if !e.safe[n] && e.isLocal(n.Obj) {
+			e.leaks[n.Obj] = true
+		}
+	}
+	return true
+}
+
+// vim: set ft=go :
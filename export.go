@@ -0,0 +1,312 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package llgo
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/axw/llgo/types"
+	"go/ast"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+)
+
+// exporter writes a package's export data in the textual format produced
+// by the gc compiler, so that it may be read by types.GcImportData.
+type exporter struct {
+	*compiler
+	buf     bytes.Buffer
+	paths   map[*ast.Object]string // object -> import path ("" for this package)
+	written map[*ast.Object]bool   // type names already queued for export
+	pending []*ast.Object          // type names yet to be exported
+}
+
+// exportData returns the export data for the package being compiled:
+// its exported constants, types, variables and functions, along with any
+// unexported types they refer to. The data is preceded by a "go object"
+// header line, so it may be located with types.FindGcExportData.
+func (c *compiler) exportData() []byte {
+	e := &exporter{
+		compiler: c,
+		paths:    make(map[*ast.Object]string),
+		written:  make(map[*ast.Object]bool),
+	}
+	for _, obj := range c.pkg.Scope.Objects {
+		e.paths[obj] = ""
+	}
+	for _, obj := range types.Unsafe.Data.(*ast.Scope).Objects {
+		e.paths[obj] = "unsafe"
+	}
+
+	fmt.Fprintf(&e.buf, "go object %s llgo %s\n\n$$\n", c.GetTargetTriple(), LLGOVersion)
+	fmt.Fprintf(&e.buf, "package %s\n", c.pkg.Name)
+
+	// Imported packages must be named before any of their types are
+	// referred to.
+	paths := make([]string, 0, len(c.pkg.Imports))
+	for path := range c.pkg.Imports {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		pkgobj := c.pkg.Imports[path]
+		if pkgobj == types.Unsafe || pkgobj.Name == "" {
+			continue
+		}
+		for _, obj := range pkgobj.Data.(*ast.Scope).Objects {
+			e.paths[obj] = path
+		}
+		fmt.Fprintf(&e.buf, "import %s %q\n", pkgobj.Name, path)
+	}
+
+	names := make([]string, 0, len(c.pkg.Scope.Objects))
+	for name := range c.pkg.Scope.Objects {
+		if ast.IsExported(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		obj := c.pkg.Scope.Objects[name]
+		switch obj.Kind {
+		case ast.Con:
+			e.writeConst(obj)
+		case ast.Typ:
+			e.queueType(obj)
+		case ast.Var:
+			fmt.Fprintf(&e.buf, "var @\"\".%s ", obj.Name)
+			e.writeType(obj.Type.(types.Type))
+			e.buf.WriteByte('\n')
+		case ast.Fun:
+			fmt.Fprintf(&e.buf, "func @\"\".%s ", obj.Name)
+			e.writeSignature(obj.Type.(*types.Func))
+			e.buf.WriteByte('\n')
+		}
+	}
+
+	// Writing a type declaration may queue further (unexported) types.
+	for len(e.pending) > 0 {
+		obj := e.pending[0]
+		e.pending = e.pending[1:]
+		e.writeTypeDecl(obj)
+	}
+
+	e.buf.WriteString("$$\n")
+	return e.buf.Bytes()
+}
+
+func (e *exporter) queueType(obj *ast.Object) {
+	if !e.written[obj] {
+		e.written[obj] = true
+		e.pending = append(e.pending, obj)
+	}
+}
+
+func (e *exporter) writeConst(obj *ast.Object) {
+	v := e.Resolve(obj).(ConstValue)
+	fmt.Fprintf(&e.buf, "const @\"\".%s ", obj.Name)
+	if _, untyped := v.typ.(*types.Basic); !untyped {
+		e.writeType(v.typ)
+		e.buf.WriteByte(' ')
+	}
+	e.buf.WriteString("= ")
+	switch x := v.Val.(type) {
+	case bool:
+		e.buf.WriteString(strconv.FormatBool(x))
+	case *big.Int:
+		e.buf.WriteString(x.String())
+	case *big.Rat:
+		e.buf.WriteString(exportFloat(x))
+	case string:
+		e.buf.WriteString(strconv.Quote(x))
+	default:
+		e.errorf(obj.Pos(), "cannot export constant %s", obj.Name)
+	}
+	e.buf.WriteByte('\n')
+}
+
+// exportFloat formats a floating point constant as a mantissa and a
+// binary exponent. The value is exact if the denominator is a power of
+// two, and otherwise is rounded to the nearest float64.
+func exportFloat(x *big.Rat) string {
+	num, denom := x.Num(), x.Denom()
+	if n := denom.BitLen() - 1; denom.Cmp(new(big.Int).Lsh(big.NewInt(1), uint(n))) == 0 {
+		return fmt.Sprintf("%sp%+d", num, -n)
+	}
+	f, _ := x.Float64()
+	mant, exp := math.Frexp(f)
+	return fmt.Sprintf("%dp%+d", int64(math.Ldexp(mant, 53)), exp-53)
+}
+
+func (e *exporter) writeTypeDecl(obj *ast.Object) {
+	name := obj.Type.(*types.Name)
+	fmt.Fprintf(&e.buf, "type @\"\".%s ", obj.Name)
+	e.writeType(name.Underlying)
+	e.buf.WriteByte('\n')
+	for _, m := range name.Methods {
+		ftyp := m.Type.(*types.Func)
+		e.buf.WriteString("func (? ")
+		e.writeType(ftyp.Recv.Type.(types.Type))
+		e.buf.WriteString(") ")
+		e.writeName("", m.Name)
+		e.buf.WriteByte(' ')
+		e.writeSignature(ftyp)
+		e.buf.WriteByte('\n')
+	}
+}
+
+// writeName writes a field or method name. Unexported names are
+// qualified with the import path of the package declaring them, or
+// "" for the package being exported, as gc does.
+func (e *exporter) writeName(pkg, name string) {
+	switch {
+	case name == "":
+		e.buf.WriteByte('?')
+	case ast.IsExported(name) || name == "_":
+		e.buf.WriteString(name)
+	default:
+		fmt.Fprintf(&e.buf, "@%q.%s", pkg, name)
+	}
+}
+
+func (e *exporter) writeParams(params types.ObjList, isVariadic bool) {
+	e.buf.WriteByte('(')
+	for i, p := range params {
+		if i > 0 {
+			e.buf.WriteString(", ")
+		}
+		if p.Name == "" {
+			e.buf.WriteByte('?')
+		} else {
+			e.buf.WriteString(p.Name)
+		}
+		e.buf.WriteByte(' ')
+		typ := p.Type.(types.Type)
+		if isVariadic && i == len(params)-1 {
+			e.buf.WriteString("...")
+			typ = typ.(*types.Slice).Elt
+		}
+		e.writeType(typ)
+	}
+	e.buf.WriteByte(')')
+}
+
+func (e *exporter) writeSignature(f *types.Func) {
+	e.writeParams(f.Params, f.IsVariadic)
+	if len(f.Results) > 0 {
+		e.buf.WriteByte(' ')
+		e.writeParams(f.Results, false)
+	}
+}
+
+func (e *exporter) writeType(t types.Type) {
+	switch t := t.(type) {
+	case *types.Basic:
+		if t.Kind == types.UnsafePointerKind {
+			e.buf.WriteString(`@"unsafe".Pointer`)
+		} else {
+			e.buf.WriteString(t.Kind.String())
+		}
+	case *types.Array:
+		fmt.Fprintf(&e.buf, "[%d]", t.Len)
+		e.writeType(t.Elt)
+	case *types.Slice:
+		e.buf.WriteString("[]")
+		e.writeType(t.Elt)
+	case *types.Struct:
+		e.buf.WriteString("struct { ")
+		for i, f := range t.Fields {
+			if i > 0 {
+				e.buf.WriteString("; ")
+			}
+			e.writeName(t.Pkg, f.Name)
+			e.buf.WriteByte(' ')
+			e.writeType(f.Type.(types.Type))
+			if t.Tags != nil && t.Tags[i] != "" {
+				tag := t.Tags[i]
+				if s, err := strconv.Unquote(tag); err == nil {
+					tag = s
+				}
+				fmt.Fprintf(&e.buf, " %q", tag)
+			}
+		}
+		e.buf.WriteString(" }")
+	case *types.Pointer:
+		e.buf.WriteByte('*')
+		e.writeType(t.Base)
+	case *types.Func:
+		e.buf.WriteString("func")
+		e.writeSignature(t)
+	case *types.Interface:
+		e.buf.WriteString("interface { ")
+		for i, m := range t.Methods {
+			if i > 0 {
+				e.buf.WriteString("; ")
+			}
+			e.writeName(t.Pkg, m.Name)
+			e.writeSignature(m.Type.(*types.Func))
+		}
+		e.buf.WriteString(" }")
+	case *types.Map:
+		e.buf.WriteString("map[")
+		e.writeType(t.Key)
+		e.buf.WriteByte(']')
+		e.writeType(t.Elt)
+	case *types.Chan:
+		switch t.Dir {
+		case ast.SEND:
+			e.buf.WriteString("chan<- ")
+		case ast.RECV:
+			e.buf.WriteString("<-chan ")
+		default:
+			e.buf.WriteString("chan ")
+		}
+		if _, ok := t.Elt.(*types.Chan); ok {
+			e.buf.WriteByte('(')
+			e.writeType(t.Elt)
+			e.buf.WriteByte(')')
+		} else {
+			e.writeType(t.Elt)
+		}
+	case *types.Name:
+		if types.Universe.Lookup(t.Obj.Name) == t.Obj {
+			e.buf.WriteString(t.Obj.Name)
+			break
+		}
+		path, ok := e.paths[t.Obj]
+		if !ok {
+			e.errorf(t.Obj.Pos(), "cannot export local type %s", t.Obj.Name)
+			return
+		}
+		if path == "" {
+			e.queueType(t.Obj)
+		}
+		fmt.Fprintf(&e.buf, "@%q.%s", path, t.Obj.Name)
+	default:
+		panic(fmt.Sprintf("unhandled type: %T", t))
+	}
+}
+
+// vim: set ft=go :
@@ -28,6 +28,7 @@ import (
 	"github.com/axw/llgo/types"
 	"go/ast"
 	"go/token"
+	"math/big"
 	"reflect"
 	"sort"
 	"strconv"
@@ -45,10 +46,10 @@ func isNilIdent(x ast.Expr) bool {
 // on the appropriate value depending on which basic blocks branch to it.
 func (c *compiler) compileLogicalOp(op token.Token, lhs Value, rhsFunc func() Value) Value {
 	lhsBlock := c.builder.GetInsertBlock()
-	resultBlock := llvm.AddBasicBlock(lhsBlock.Parent(), "")
+	resultBlock := c.context.AddBasicBlock(lhsBlock.Parent(), "")
 	resultBlock.MoveAfter(lhsBlock)
-	rhsBlock := llvm.InsertBasicBlock(resultBlock, "")
-	falseBlock := llvm.InsertBasicBlock(resultBlock, "")
+	rhsBlock := c.context.InsertBasicBlock(resultBlock, "")
+	falseBlock := c.context.InsertBasicBlock(resultBlock, "")
 
 	if op == token.LOR {
 		c.builder.CreateCondBr(lhs.LLVMValue(), resultBlock, rhsBlock)
@@ -63,9 +64,9 @@ func (c *compiler) compileLogicalOp(op token.Token, lhs Value, rhsFunc func() Va
 	c.builder.CreateBr(resultBlock)
 	c.builder.SetInsertPointAtEnd(resultBlock)
 
-	result := c.builder.CreatePHI(llvm.Int1Type(), "")
-	trueValue := llvm.ConstAllOnes(llvm.Int1Type())
-	falseValue := llvm.ConstNull(llvm.Int1Type())
+	result := c.builder.CreatePHI(c.context.Int1Type(), "")
+	trueValue := llvm.ConstAllOnes(c.context.Int1Type())
+	falseValue := llvm.ConstNull(c.context.Int1Type())
 	var values []llvm.Value
 	var blocks []llvm.BasicBlock
 	if op == token.LOR {
@@ -80,6 +81,13 @@ func (c *compiler) compileLogicalOp(op token.Token, lhs Value, rhsFunc func() Va
 }
 
 func (c *compiler) VisitBinaryExpr(expr *ast.BinaryExpr) Value {
+	if operands := c.stringConcatOperands(expr); len(operands) > 2 {
+		values := make([]Value, len(operands))
+		for i, operand := range operands {
+			values[i] = c.VisitExpr(operand)
+		}
+		return c.concatenateStringValues(values, c.types.expr[expr])
+	}
 	lhs := c.VisitExpr(expr.X)
 	switch expr.Op {
 	case token.LOR, token.LAND:
@@ -100,20 +108,66 @@ func (c *compiler) VisitBinaryExpr(expr *ast.BinaryExpr) Value {
 		return c.compileLogicalOp(expr.Op, lhs, func() Value { return c.VisitExpr(expr.Y) })
 	case token.SHL, token.SHR:
 		rhs := c.VisitExpr(expr.Y)
+		if rhs, ok := rhs.(ConstValue); ok {
+			if n, ok := rhs.Val.(*big.Int); ok && n.Sign() < 0 {
+				c.errorf(expr.Y.Pos(), "invalid negative shift count: %s", n)
+			}
+		}
 		if _, ok := lhs.(ConstValue); ok {
 			typ := c.types.expr[expr]
 			lhs = lhs.Convert(typ)
 		}
 		return lhs.BinaryOp(expr.Op, rhs)
 	}
-	return lhs.BinaryOp(expr.Op, c.VisitExpr(expr.Y))
+	rhs := c.VisitExpr(expr.Y)
+	if _, ok := lhs.(NilValue); ok {
+		// nil == x, nil != x
+		lhs, rhs = rhs, lhs
+	}
+	return lhs.BinaryOp(expr.Op, rhs)
 }
 
 func (c *compiler) VisitUnaryExpr(expr *ast.UnaryExpr) Value {
 	value := c.VisitExpr(expr.X)
+	if expr.Op == token.ARROW {
+		elem, _ := c.chanRecv(value.(*LLVMValue))
+		return elem
+	}
 	return value.UnaryOp(expr.Op)
 }
 
+// evalCallArgs evaluates the arguments of a call to a function of the
+// given type, converting each to its parameter's type. The trailing
+// arguments of a variadic call are packed into a new slice, or a nil slice
+// if there are none; a call of the form f(x, s...) passes s through as-is.
+func (c *compiler) evalCallArgs(fn_type *types.Func, expr *ast.CallExpr) []llvm.Value {
+	nparams := len(fn_type.Params)
+	if fn_type.IsVariadic && !expr.Ellipsis.IsValid() {
+		nparams--
+	}
+	args := make([]llvm.Value, 0, len(fn_type.Params))
+	for i := 0; i < nparams; i++ {
+		value := c.VisitExpr(expr.Args[i])
+		param_type := fn_type.Params[i].Type.(types.Type)
+		args = append(args, value.Convert(param_type).LLVMValue())
+	}
+	if fn_type.IsVariadic && !expr.Ellipsis.IsValid() {
+		slice_type := fn_type.Params[nparams].Type.(types.Type)
+		if len(expr.Args) == nparams {
+			args = append(args, llvm.ConstNull(c.types.ToLLVM(slice_type)))
+		} else {
+			elt_type := types.Underlying(slice_type).(*types.Slice).Elt
+			varargs := make([]llvm.Value, 0, len(expr.Args)-nparams)
+			for i := nparams; i < len(expr.Args); i++ {
+				value := c.VisitExpr(expr.Args[i])
+				varargs = append(varargs, value.Convert(elt_type).LLVMValue())
+			}
+			args = append(args, c.makeLiteralSlice(varargs, elt_type))
+		}
+	}
+	return args
+}
+
 func (c *compiler) VisitCallExpr(expr *ast.CallExpr) Value {
 	switch x := (expr.Fun).(type) {
 	case *ast.Ident:
@@ -124,19 +178,27 @@ func (c *compiler) VisitCallExpr(expr *ast.CallExpr) Value {
 			return c.VisitPrint(expr, true)
 		case "len":
 			return c.VisitLen(expr)
+		case "cap":
+			return c.VisitCap(expr)
 		case "new":
 			return c.VisitNew(expr)
 		case "make":
 			return c.VisitMake(expr)
 		case "append":
 			return c.VisitAppend(expr)
+		case "copy":
+			return c.VisitCopy(expr)
 		case "delete":
 			m := c.VisitExpr(expr.Args[0]).(*LLVMValue)
 			key := c.VisitExpr(expr.Args[1])
 			c.mapDelete(m, key)
 			return nil
+		case "close":
+			ch := c.VisitExpr(expr.Args[0]).(*LLVMValue)
+			c.chanClose(ch)
+			return nil
 		case "panic":
-			// TODO
+			c.visitPanic(expr.Args[0])
 			return nil
 		}
 
@@ -155,19 +217,24 @@ func (c *compiler) VisitCallExpr(expr *ast.CallExpr) Value {
 				return value
 			}
 		}
+		if intrinsic := c.lookupCallIntrinsic(x); intrinsic != nil {
+			return intrinsic(c, expr.Args)
+		}
 	}
-	lhs := c.VisitExpr(expr.Fun)
-
 	// Is it a type conversion?
-	if len(expr.Args) == 1 {
-		if _, ok := lhs.(TypeValue); ok {
-			typ := lhs.Type()
-			value := c.VisitExpr(expr.Args[0])
-			return value.Convert(typ)
-		}
+	if c.info.TypeExprs[expr.Fun] {
+		value := c.VisitExpr(expr.Args[0])
+		return value.Convert(c.info.Types[expr.Fun])
 	}
 
-	// Not a type conversion, so must be a function call.
+	// Not a type conversion, so must be a function call. Methods selected
+	// for a call needn't be bound into a method value.
+	var lhs Value
+	if sel, ok := expr.Fun.(*ast.SelectorExpr); ok {
+		lhs = c.visitSelector(sel)
+	} else {
+		lhs = c.VisitExpr(expr.Fun)
+	}
 	fn := lhs.(*LLVMValue)
 	fn_type := types.Underlying(fn.Type()).(*types.Func)
 	args := make([]llvm.Value, 0)
@@ -177,27 +244,7 @@ func (c *compiler) VisitCallExpr(expr *ast.CallExpr) Value {
 		receiver := fn.receiver
 		args = append(args, receiver.LLVMValue())
 	}
-	if nparams := len(fn_type.Params); nparams > 0 {
-		if fn_type.IsVariadic {
-			nparams--
-		}
-		for i := 0; i < nparams; i++ {
-			value := c.VisitExpr(expr.Args[i])
-			param_type := fn_type.Params[i].Type.(types.Type)
-			args = append(args, value.Convert(param_type).LLVMValue())
-		}
-		if fn_type.IsVariadic {
-			param_type := fn_type.Params[nparams].Type.(*types.Slice).Elt
-			varargs := make([]llvm.Value, 0)
-			for i := nparams; i < len(expr.Args); i++ {
-				value := c.VisitExpr(expr.Args[i])
-				value = value.Convert(param_type)
-				varargs = append(varargs, value.LLVMValue())
-			}
-			slice_value := c.makeLiteralSlice(varargs, param_type)
-			args = append(args, slice_value)
-		}
-	}
+	args = append(args, c.evalCallArgs(fn_type, expr)...)
 
 	var result_type types.Type
 	switch len(fn_type.Results) {
@@ -215,7 +262,7 @@ func (c *compiler) VisitCallExpr(expr *ast.CallExpr) Value {
 	// After calling the function, we must bitcast to the computed LLVM
 	// type. This is a no-op, and exists just to satisfy LLVM's type
 	// comparisons.
-	result := c.builder.CreateCall(fn.LLVMValue(), args, "")
+	result := c.createCall(fn.LLVMValue(), args)
 	if len(fn_type.Results) == 1 {
 		result = c.builder.CreateBitCast(result, c.types.ToLLVM(result_type), "")
 	}
@@ -245,20 +292,27 @@ func isIntType(t types.Type) bool {
 }
 
 func (c *compiler) VisitIndexExpr(expr *ast.IndexExpr) Value {
-	value := c.VisitExpr(expr.X).(*LLVMValue)
+	x := c.VisitExpr(expr.X)
 	index := c.VisitExpr(expr.Index)
 
-	typ := value.Type()
-	if typ == types.String {
-		ptr := c.builder.CreateExtractValue(value.LLVMValue(), 0, "")
-		gepindices := []llvm.Value{index.LLVMValue()}
-		ptr = c.builder.CreateGEP(ptr, gepindices, "")
-		result := c.NewLLVMValue(ptr, &types.Pointer{Base: types.Byte})
-		return result.makePointee()
+	// Strings, which may be constant, are indexed by loading the byte
+	// directly; string elements are not addressable.
+	typ := x.Type()
+	if types.Underlying(typ) == types.String {
+		str := x.LLVMValue()
+		indexValue := index.Convert(types.Int).LLVMValue()
+		length := c.builder.CreateExtractValue(str, 1, "")
+		c.boundsCheck(indexValue, length)
+		ptr := c.builder.CreateExtractValue(str, 0, "")
+		ptr = c.builder.CreateGEP(ptr, []llvm.Value{indexValue}, "")
+		return c.NewLLVMValue(c.builder.CreateLoad(ptr, ""), types.Byte)
 	}
+	value := x.(*LLVMValue)
 
 	// We can index a pointer to an array.
 	if _, ok := types.Underlying(typ).(*types.Pointer); ok {
+		value = c.NewLLVMValue(value.LLVMValue(), typ)
+		c.nilCheck(value.LLVMValue())
 		value = value.makePointee()
 		typ = value.Type()
 	}
@@ -266,7 +320,7 @@ func (c *compiler) VisitIndexExpr(expr *ast.IndexExpr) Value {
 	switch types.Underlying(typ).(type) {
 	case *types.Array, *types.Slice:
 		var gep_indices []llvm.Value
-		var ptr llvm.Value
+		var ptr, length llvm.Value
 		var result_type types.Type
 		switch typ := types.Underlying(typ).(type) {
 		case *types.Array:
@@ -274,13 +328,17 @@ func (c *compiler) VisitIndexExpr(expr *ast.IndexExpr) Value {
 			// Do we have to load the array onto the stack?
 			result_type = typ.Elt
 			ptr = value.pointer.LLVMValue()
-			gep_indices = append(gep_indices, llvm.ConstNull(llvm.Int32Type()))
+			length = llvm.ConstInt(c.target.IntPtrType(), typ.Len, false)
+			gep_indices = append(gep_indices, llvm.ConstNull(c.context.Int32Type()))
 		case *types.Slice:
 			result_type = typ.Elt
 			ptr = c.builder.CreateExtractValue(value.LLVMValue(), 0, "")
+			length = c.builder.CreateExtractValue(value.LLVMValue(), 1, "")
 		}
 
-		gep_indices = append(gep_indices, index.LLVMValue())
+		indexValue := index.Convert(types.Int).LLVMValue()
+		c.boundsCheck(indexValue, length)
+		gep_indices = append(gep_indices, indexValue)
 		element := c.builder.CreateGEP(ptr, gep_indices, "")
 		result := c.NewLLVMValue(element, &types.Pointer{Base: result_type})
 		return result.makePointee()
@@ -292,95 +350,100 @@ func (c *compiler) VisitIndexExpr(expr *ast.IndexExpr) Value {
 	panic(fmt.Sprintf("unreachable (%s)", typ))
 }
 
-type selectorCandidate struct {
-	Indices []int
-	Type    types.Type
+// VisitSelectorExpr evaluates a selector expression as a value. A method
+// selected on a value is bound to its receiver, creating a method value.
+func (c *compiler) VisitSelectorExpr(expr *ast.SelectorExpr) Value {
+	value := c.visitSelector(expr)
+	if fn, ok := value.(*LLVMValue); ok && fn.receiver != nil {
+		return c.makeMethodValue(fn)
+	}
+	return value
 }
 
-func (c *compiler) VisitSelectorExpr(expr *ast.SelectorExpr) Value {
+// interfaceMethod returns the named method of an interface value, with
+// the value's receiver.
+func (c *compiler) interfaceMethod(lhs Value, name string) *LLVMValue {
+	iface := types.Underlying(lhs.Type()).(*types.Interface)
+	i := sort.Search(len(iface.Methods), func(i int) bool {
+		return iface.Methods[i].Name >= name
+	})
+	structValue := lhs.LLVMValue()
+	tab := c.builder.CreateExtractValue(structValue, 0, "")
+	receiver := c.builder.CreateExtractValue(structValue, 1, "")
+	f := c.loadItabWord(tab, itabMethodsWord+i)
+
+	// The method is called with the interface's data word as an opaque
+	// pointer receiver.
+	mtype := c.ObjGetType(iface.Methods[i]).(*types.Func)
+	recv := ast.NewObj(ast.Var, "")
+	recv.Type = &types.Pointer{Base: types.Int8}
+	ftype := &types.Func{
+		Recv:       recv,
+		Params:     mtype.Params,
+		Results:    mtype.Results,
+		IsVariadic: mtype.IsVariadic,
+	}
+	method := c.NewLLVMValue(c.builder.CreateBitCast(f, c.types.rawFuncLLVMType(ftype), ""), ftype)
+	method.receiver = c.NewLLVMValue(receiver, recv.Type.(types.Type))
+	return method
+}
+
+// visitSelector evaluates a selector expression. If a method is selected
+// on a value, the result is the method with its receiver attached, ready
+// to be called.
+func (c *compiler) visitSelector(expr *ast.SelectorExpr) Value {
 	lhs := c.VisitExpr(expr.X)
+	if recvtyp, ok := lhs.(TypeValue); ok {
+		return c.makeMethodExpr(expr, recvtyp.Type())
+	}
 	if lhs == nil {
 		// The only time we should get a nil result is if the object is
 		// a package.
-		obj := expr.Sel.Obj
+		obj := c.info.Objects[expr.Sel]
 		if obj.Kind == ast.Typ {
 			return TypeValue{obj.Type.(types.Type)}
 		}
-		return c.Resolve(obj)
+		value := c.Resolve(obj)
+		if fn, ok := value.(*LLVMValue); ok && obj.Kind == ast.Fun {
+			return c.makeFuncValue(fn)
+		}
+		return value
 	}
 
-	// TODO(?) record path to field/method during typechecking, so we don't
-	// have to search again here.
-
 	name := expr.Sel.Name
-	if iface, ok := types.Underlying(lhs.Type()).(*types.Interface); ok {
-		i := sort.Search(len(iface.Methods), func(i int) bool {
-			return iface.Methods[i].Name >= name
-		})
-		structValue := lhs.LLVMValue()
-		receiver := c.builder.CreateExtractValue(structValue, 0, "")
-		f := c.builder.CreateExtractValue(structValue, i+2, "")
-		ftype := c.ObjGetType(iface.Methods[i]).(*types.Func)
-		method := c.NewLLVMValue(c.builder.CreateBitCast(f, c.types.ToLLVM(ftype), ""), ftype)
-		method.receiver = c.NewLLVMValue(receiver, ftype.Recv.Type.(types.Type))
-		return method
+	if _, ok := types.Underlying(lhs.Type()).(*types.Interface); ok {
+		return c.interfaceMethod(lhs, name)
 	}
 
-	// Search through embedded types for field/method.
-	var result selectorCandidate
-	curr := []selectorCandidate{{nil, lhs.Type()}}
-	for result.Type == nil && len(curr) > 0 {
-		var next []selectorCandidate
-		for _, candidate := range curr {
-			indices := candidate.Indices[0:]
-			t := candidate.Type
-
-			if p, ok := types.Underlying(t).(*types.Pointer); ok {
-				if _, ok := types.Underlying(p.Base).(*types.Struct); ok {
-					t = p.Base
-				}
-			}
+	// The type checker has recorded the path through embedded fields to
+	// the selected field or method.
+	sel := c.info.Selections[expr]
 
-			if n, ok := t.(*types.Name); ok {
-				i := sort.Search(len(n.Methods), func(i int) bool {
-					return n.Methods[i].Name >= name
-				})
-				if i < len(n.Methods) && n.Methods[i].Name == name {
-					result.Indices = indices
-					result.Type = t
-				}
-			}
-
-			if t, ok := types.Underlying(t).(*types.Struct); ok {
-				if i, ok := t.FieldIndices[name]; ok {
-					result.Indices = append(indices, int(i))
-					result.Type = t
-				} else {
-					// Add embedded types to the next set of types
-					// to check.
-					for i, field := range t.Fields {
-						if field.Name == "" {
-							indices = append(indices[0:], i)
-							t := field.Type.(types.Type)
-							candidate := selectorCandidate{indices, t}
-							next = append(next, candidate)
-						}
-					}
-				}
-			}
-		}
-		curr = next
-	}
-
-	// Get a pointer to the field/receiver.
+	// Get a pointer to the field/receiver. If the selector is applied to
+	// a pointer, the pointer must be checked for nil before any field is
+	// accessed through it.
 	recvValue := lhs.(*LLVMValue)
-	if _, ok := types.Underlying(lhs.Type()).(*types.Pointer); !ok {
-		recvValue = recvValue.pointer
+	_, isptr := types.Underlying(lhs.Type()).(*types.Pointer)
+	if !isptr {
+		if recvValue.pointer == nil {
+			// The value is not addressable, e.g. a function result or
+			// map element, so copy it to select a field or value method.
+			// The type checker has rejected calls to pointer methods.
+			ptr := c.createEntryAlloca(c.types.ToLLVM(lhs.Type()), "")
+			c.builder.CreateStore(recvValue.LLVMValue(), ptr)
+			recvValue = c.NewLLVMValue(ptr, &types.Pointer{Base: lhs.Type()})
+		} else {
+			recvValue = recvValue.pointer
+		}
 	}
 	recvValue = c.NewLLVMValue(recvValue.LLVMValue(), recvValue.Type())
-	if len(result.Indices) > 0 {
-		for _, v := range result.Indices {
+	if len(sel.Index) > 0 {
+		checkNil := isptr
+		for _, v := range sel.Index {
 			ptr := recvValue.LLVMValue()
+			if checkNil {
+				c.nilCheck(ptr)
+			}
 			field := types.Underlying(types.Deref(recvValue.typ)).(*types.Struct).Fields[v]
 			fieldPtr := c.builder.CreateStructGEP(ptr, v, "")
 			fieldPtrTyp := &types.Pointer{Base: field.Type.(types.Type)}
@@ -388,25 +451,28 @@ func (c *compiler) VisitSelectorExpr(expr *ast.SelectorExpr) Value {
 
 			// GEP returns a pointer; if the field is a pointer,
 			// we must load our pointer-to-a-pointer.
-			if _, ok := field.Type.(*types.Pointer); ok {
+			_, checkNil = field.Type.(*types.Pointer)
+			if checkNil {
 				recvValue = recvValue.makePointee()
 			}
 		}
 	}
-	if !types.Identical(recvValue.typ, expr.Sel.Obj.Type.(types.Type)) {
+	if !types.Identical(recvValue.typ, sel.Obj.Type.(types.Type)) {
 		recvValue = recvValue.makePointee()
 	}
 
 	// Method?
-	if expr.Sel.Obj.Kind == ast.Fun {
-		method := c.Resolve(expr.Sel.Obj).(*LLVMValue)
-		methodType := expr.Sel.Obj.Type.(*types.Func)
+	if sel.Obj.Kind == ast.Fun {
+		method := c.Resolve(sel.Obj).(*LLVMValue)
+		methodType := sel.Obj.Type.(*types.Func)
 		receiverType := methodType.Recv.Type.(types.Type)
 		if types.Identical(recvValue.Type(), receiverType) {
 			method.receiver = recvValue
 		} else if types.Identical(&types.Pointer{Base: recvValue.Type()}, receiverType) {
 			method.receiver = recvValue.pointer
 		} else {
+			// Calling a value method through a nil pointer panics.
+			c.nilCheck(recvValue.LLVMValue())
 			method.receiver = recvValue.makePointee()
 		}
 		return method
@@ -417,16 +483,13 @@ func (c *compiler) VisitSelectorExpr(expr *ast.SelectorExpr) Value {
 }
 
 func (c *compiler) VisitStarExpr(expr *ast.StarExpr) Value {
-	switch operand := c.VisitExpr(expr.X).(type) {
-	case TypeValue:
-		return TypeValue{&types.Pointer{Base: operand.Type()}}
-	case *LLVMValue:
-		// We don't want to immediately load the value, as we might be doing an
-		// assignment rather than an evaluation. Instead, we return the pointer
-		// and tell the caller to load it on demand.
-		return operand.makePointee()
-	}
-	panic("unreachable")
+	// We don't want to immediately load the value, as we might be doing an
+	// assignment rather than an evaluation. Instead, we return the pointer
+	// and tell the caller to load it on demand.
+	operand := c.VisitExpr(expr.X).(*LLVMValue)
+	ptr := c.NewLLVMValue(operand.LLVMValue(), operand.Type())
+	c.nilCheck(ptr.LLVMValue())
+	return ptr.makePointee()
 }
 
 func (c *compiler) VisitTypeAssertExpr(expr *ast.TypeAssertExpr) Value {
@@ -435,14 +498,20 @@ func (c *compiler) VisitTypeAssertExpr(expr *ast.TypeAssertExpr) Value {
 		// XXX this will probably be handled in the switch statement.
 		panic("TODO")
 	} else {
-		lhs := c.VisitExpr(expr.X)
+		lhs := c.VisitExpr(expr.X).(*LLVMValue)
 		typ := c.GetType(expr.Type)
-		return lhs.Convert(typ)
+		return lhs.mustTypeAssert(typ)
 	}
 	return nil
 }
 
+// VisitExpr evaluates an expression. Expressions that the type checker has
+// recorded as denoting types evaluate to a TypeValue of the type.
 func (c *compiler) VisitExpr(expr ast.Expr) Value {
+	defer c.positionPanic(expr.Pos())
+	if c.info.TypeExprs[expr] {
+		return TypeValue{c.info.Types[expr]}
+	}
 	switch x := expr.(type) {
 	case *ast.BasicLit:
 		return c.VisitBasicLit(x)
@@ -468,21 +537,18 @@ func (c *compiler) VisitExpr(expr ast.Expr) Value {
 		return c.VisitTypeAssertExpr(x)
 	case *ast.SliceExpr:
 		return c.VisitSliceExpr(x)
-	case *ast.FuncType:
-		return c.VisitFuncType(x)
-	case *ast.MapType:
-		return c.VisitMapType(x)
-	case *ast.ArrayType:
-		return c.VisitArrayType(x)
-	case *ast.StructType:
-		return c.VisitStructType(x)
-	case *ast.InterfaceType:
-		return c.VisitInterfaceType(x)
 	case *ast.Ident:
-		if x.Obj == nil {
-			x.Obj = c.LookupObj(x.Name)
+		// The checker records the object denoted by each identifier it
+		// checks; identifiers created by the compiler carry their own.
+		obj := c.info.Objects[x]
+		if obj == nil {
+			obj = x.Obj
+		}
+		value := c.Resolve(obj)
+		if fn, ok := value.(*LLVMValue); ok && obj.Kind == ast.Fun {
+			return c.makeFuncValue(fn)
 		}
-		return c.Resolve(x.Obj)
+		return value
 	}
 	panic(fmt.Sprintf("Unhandled Expr node: %s", reflect.TypeOf(expr)))
 }
@@ -0,0 +1,327 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package llgo
+
+import (
+	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo/types"
+	"strings"
+)
+
+// createMalloc allocates size bytes of zeroed, garbage collected memory
+// with runtime.mallocgc, returning an i8*.
+func (c *compiler) createMalloc(size llvm.Value) llvm.Value {
+	mallocgc := c.NamedFunction("runtime.mallocgc", "func f(size uintptr) unsafe.Pointer")
+	size = c.builder.CreateIntCast(size, c.target.IntPtrType(), "")
+	ptr := c.builder.CreateCall(mallocgc, []llvm.Value{size}, "")
+	i8ptr := llvm.PointerType(c.context.Int8Type(), 0)
+	ptr = c.builder.CreateIntToPtr(ptr, i8ptr, "")
+	if c.preciseGC {
+		// The memory may not be stored anywhere reachable before the
+		// next allocation, so keep it alive for the rest of the call.
+		root := c.createGCRoot(nil, i8ptr, "")
+		c.builder.CreateStore(ptr, root)
+	}
+	return ptr
+}
+
+// createTypeMalloc allocates garbage collected memory for a value of the
+// specified type, returning a pointer to the zeroed memory.
+func (c *compiler) createTypeMalloc(typ llvm.Type) llvm.Value {
+	size := llvm.ConstInt(c.target.IntPtrType(), c.target.TypeAllocSize(typ), false)
+	ptr := c.createMalloc(size)
+	return c.builder.CreateBitCast(ptr, llvm.PointerType(typ, 0), "")
+}
+
+// createArrayMalloc allocates garbage collected memory for n values of
+// the specified type, returning a pointer to the first zeroed element.
+func (c *compiler) createArrayMalloc(elttyp llvm.Type, n llvm.Value) llvm.Value {
+	intptr := c.target.IntPtrType()
+	eltsize := llvm.ConstInt(intptr, c.target.TypeAllocSize(elttyp), false)
+	n = c.builder.CreateIntCast(n, intptr, "")
+	ptr := c.createMalloc(c.builder.CreateMul(n, eltsize, ""))
+	return c.builder.CreateBitCast(ptr, llvm.PointerType(elttyp, 0), "")
+}
+
+// createGCRoots creates a function that registers each of the module's
+// global variables with the garbage collector, so pointers held in them
+// keep their referents alive. The function is returned as a constructor
+// to be run before any variable initialisers; if the module defines no
+// variables, nil is returned.
+func (c *compiler) createGCRoots() Value {
+	var globals []llvm.Value
+	for g := c.module.FirstGlobal(); !g.IsNil(); g = llvm.NextGlobal(g) {
+		if g.IsDeclaration() || g.IsGlobalConstant() {
+			continue
+		}
+		if strings.HasPrefix(g.Name(), "llvm.") || g.Name() == gcRootChainName {
+			continue
+		}
+		globals = append(globals, g)
+	}
+	if len(globals) == 0 {
+		return nil
+	}
+
+	fntype := llvm.FunctionType(c.context.VoidType(), nil, false)
+	fn := llvm.AddFunction(c.module.Module, "", fntype)
+	fn.SetLinkage(llvm.PrivateLinkage)
+	entry := c.context.AddBasicBlock(fn, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	gcaddroots := c.NamedFunction("runtime.gcaddroots", "func f(start, size uintptr)")
+	intptr := c.target.IntPtrType()
+	for _, g := range globals {
+		start := c.builder.CreatePtrToInt(g, intptr, "")
+		typ := g.Type().ElementType()
+		size := llvm.ConstInt(intptr, c.target.TypeAllocSize(typ), false)
+		c.builder.CreateCall(gcaddroots, []llvm.Value{start, size}, "")
+	}
+	c.builder.CreateRetVoid()
+	return c.NewLLVMValue(fn, new(types.Func))
+}
+
+// gcRoot is a stack slot registered with the garbage collector, along
+// with the map of pointers within it.
+type gcRoot struct {
+	alloca, meta llvm.Value
+}
+
+// createGCRoot allocates stack memory for a value of the specified type in
+// the current function's entry block. If precise garbage collection is
+// enabled and the type contains pointers, the memory is registered as a
+// root in the function's shadow stack entry once the function has been
+// built; see declareGCRoots.
+//
+// The type may be nil, in which case pointers are found in the LLVM type.
+func (c *compiler) createGCRoot(typ types.Type, llvmtyp llvm.Type, name string) llvm.Value {
+	defer c.restoreInsertPoint(c.saveInsertPoint())
+	c.setInsertPointAtEntry()
+
+	// The collector requires each root to be initialised before
+	// anything that may trigger a collection, so the slot is zeroed
+	// immediately.
+	alloca := c.builder.CreateAlloca(llvmtyp, name)
+	c.storeZero(alloca)
+	if c.preciseGC {
+		offsets := c.pointerOffsets(typ, llvmtyp, 0, nil)
+		if len(offsets) > 0 {
+			meta := c.createGCRootMap(offsets)
+			c.gcroots = append(c.gcroots, gcRoot{alloca, meta})
+		}
+	}
+	return alloca
+}
+
+// createGCRootMap creates a constant describing a root's layout, for the
+// runtime to scan it: the number of pointers in it, and the offset of each
+// pointer.
+func (c *compiler) createGCRootMap(offsets []uint64) llvm.Value {
+	intptr := c.target.IntPtrType()
+	values := []llvm.Value{llvm.ConstInt(intptr, uint64(len(offsets)), false)}
+	for _, offset := range offsets {
+		values = append(values, llvm.ConstInt(intptr, offset, false))
+	}
+	init := llvm.ConstArray(intptr, values)
+	global := llvm.AddGlobal(c.module.Module, init.Type(), "")
+	global.SetInitializer(init)
+	global.SetGlobalConstant(true)
+	global.SetLinkage(llvm.PrivateLinkage)
+	return llvm.ConstBitCast(global, llvm.PointerType(c.context.Int8Type(), 0))
+}
+
+// pointerOffsets appends to offsets the offset of each pointer in a value
+// of the specified type, relative to base. Pointers are found in the LLVM
+// type, except for unsafe.Pointer, which is represented as an integer; if
+// typ is nil, only the LLVM type is considered.
+func (c *compiler) pointerOffsets(typ types.Type, llvmtyp llvm.Type, base uint64, offsets []uint64) []uint64 {
+	switch typ := typ.(type) {
+	case *types.Name:
+		return c.pointerOffsets(typ.Underlying, llvmtyp, base, offsets)
+	case *types.Basic:
+		if typ.Kind == types.UnsafePointerKind {
+			return append(offsets, base)
+		}
+	case *types.Array:
+		elttyp := llvmtyp.ElementType()
+		eltsize := c.target.TypeAllocSize(elttyp)
+		for i := uint64(0); i < typ.Len; i++ {
+			offsets = c.pointerOffsets(typ.Elt, elttyp, base+i*eltsize, offsets)
+		}
+		return offsets
+	case *types.Struct:
+		elttypes := llvmtyp.StructElementTypes()
+		for i, field := range typ.Fields {
+			offset := base + c.target.ElementOffset(llvmtyp, i)
+			offsets = c.pointerOffsets(field.Type.(types.Type), elttypes[i], offset, offsets)
+		}
+		return offsets
+	}
+
+	switch llvmtyp.TypeKind() {
+	case llvm.PointerTypeKind:
+		offsets = append(offsets, base)
+	case llvm.ArrayTypeKind:
+		elttyp := llvmtyp.ElementType()
+		eltsize := c.target.TypeAllocSize(elttyp)
+		for i := 0; i < llvmtyp.ArrayLength(); i++ {
+			offsets = c.pointerOffsets(nil, elttyp, base+uint64(i)*eltsize, offsets)
+		}
+	case llvm.StructTypeKind:
+		for i, elttyp := range llvmtyp.StructElementTypes() {
+			offset := base + c.target.ElementOffset(llvmtyp, i)
+			offsets = c.pointerOffsets(nil, elttyp, offset, offsets)
+		}
+	}
+	return offsets
+}
+
+// gcRootChainName is the name of the thread-local variable holding the
+// head of each thread's shadow stack.
+const gcRootChainName = "llgo_gc_root_chain"
+
+// gcRootChain returns the variable holding the head of the calling
+// thread's shadow stack: a linked list with an entry for each active
+// function with roots. Every module that refers to the variable defines
+// it, with linkonce linkage, so the program has one.
+//
+// LLVM's shadow stack garbage collection strategy is not used, since it
+// keeps a single list for the whole process, which threads running
+// goroutines at once would corrupt.
+func (c *compiler) gcRootChain() llvm.Value {
+	chain := c.module.NamedGlobal(gcRootChainName)
+	if chain.IsNil() {
+		i8ptr := llvm.PointerType(c.context.Int8Type(), 0)
+		chain = llvm.AddGlobal(c.module.Module, i8ptr, gcRootChainName)
+		chain.SetInitializer(llvm.ConstNull(i8ptr))
+		chain.SetLinkage(llvm.LinkOnceAnyLinkage)
+		chain.SetThreadLocal(true)
+	}
+	return chain
+}
+
+// declareGCRoots registers the roots created by createGCRoot in the
+// specified function, so the runtime can find pointers on the stack
+// precisely. The function pushes an entry onto the calling thread's shadow
+// stack once its roots are initialised, and pops it before each return.
+// The entry holds the next entry, the number of roots, and the address
+// and map of each root:
+//
+//	struct entry { entry *next; uintptr nroots; struct { void *root, *map; } roots[]; };
+func (c *compiler) declareGCRoots(fn llvm.Value) {
+	if len(c.gcroots) == 0 {
+		return
+	}
+
+	// Each root was created at the start of the entry block, so the
+	// first root's initialisation is the last. The entry must be pushed
+	// after all of the initialisation.
+	defer c.restoreInsertPoint(c.saveInsertPoint())
+	init := llvm.NextInstruction(c.gcroots[0].alloca)
+	if next := llvm.NextInstruction(init); next.IsNil() {
+		c.builder.SetInsertPointAtEnd(init.InstructionParent())
+	} else {
+		c.builder.SetInsertPointBefore(next)
+	}
+
+	intptr := c.target.IntPtrType()
+	i8ptr := llvm.PointerType(c.context.Int8Type(), 0)
+	roottype := c.context.StructType([]llvm.Type{i8ptr, i8ptr}, false)
+	roots := llvm.ArrayType(roottype, len(c.gcroots))
+	entry := c.builder.CreateAlloca(c.context.StructType([]llvm.Type{i8ptr, intptr, roots}, false), "")
+	nroots := llvm.ConstInt(intptr, uint64(len(c.gcroots)), false)
+	c.builder.CreateStore(nroots, c.builder.CreateStructGEP(entry, 1, ""))
+	rootsptr := c.builder.CreateBitCast(c.builder.CreateStructGEP(entry, 2, ""), llvm.PointerType(roottype, 0), "")
+	for i, root := range c.gcroots {
+		index := llvm.ConstInt(c.context.Int32Type(), uint64(i), false)
+		rootptr := c.builder.CreateGEP(rootsptr, []llvm.Value{index}, "")
+		alloca := c.builder.CreateBitCast(root.alloca, i8ptr, "")
+		c.builder.CreateStore(alloca, c.builder.CreateStructGEP(rootptr, 0, ""))
+		c.builder.CreateStore(root.meta, c.builder.CreateStructGEP(rootptr, 1, ""))
+	}
+	chain := c.gcRootChain()
+	next := c.builder.CreateStructGEP(entry, 0, "")
+	c.builder.CreateStore(c.builder.CreateLoad(chain, ""), next)
+	c.builder.CreateStore(c.builder.CreateBitCast(entry, i8ptr, ""), chain)
+
+	for block := fn.FirstBasicBlock(); !block.IsNil(); block = llvm.NextBasicBlock(block) {
+		term := block.LastInstruction()
+		if term.IsNil() || term.InstructionOpcode() != llvm.Ret {
+			continue
+		}
+		c.builder.SetInsertPointBefore(term)
+		c.builder.CreateStore(c.builder.CreateLoad(next, ""), chain)
+	}
+	c.gcroots = nil
+}
+
+// defineGCShadowStackFunction defines runtime.gcshadowstack, which returns
+// the head of the calling thread's shadow stack, or zero if it is empty.
+func (c *compiler) defineGCShadowStackFunction(fn llvm.Value) {
+	entry := c.context.AddBasicBlock(fn, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	head := c.builder.CreateLoad(c.gcRootChain(), "")
+	c.builder.CreateRet(c.builder.CreatePtrToInt(head, c.target.IntPtrType(), ""))
+}
+
+// defineGCSetShadowStackFunction defines runtime.gcsetshadowstack, which
+// sets the head of the calling thread's shadow stack to the entry passed
+// to it.
+func (c *compiler) defineGCSetShadowStackFunction(fn llvm.Value) {
+	entry := c.context.AddBasicBlock(fn, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	i8ptr := llvm.PointerType(c.context.Int8Type(), 0)
+	head := c.builder.CreateIntToPtr(fn.FirstParam(), i8ptr, "")
+	c.builder.CreateStore(head, c.gcRootChain())
+	c.builder.CreateRetVoid()
+}
+
+// defineGCScanStackFunction defines runtime.gcscanstack, which scans the
+// calling goroutine's stack for pointers with runtime.gcscan, up to the
+// base passed to it. Callee-saved registers are first spilled to the
+// stack, so that pointers held only in registers are also found. If the
+// base is zero, it is taken from glibc's __libc_stack_end, the base of the
+// main thread's stack.
+func (c *compiler) defineGCScanStackFunction(fn llvm.Value) {
+	entry := c.context.AddBasicBlock(fn, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	unwindinit := c.NamedFunction("llvm.eh.unwind.init", "func f()")
+	c.builder.CreateCall(unwindinit, nil, "")
+
+	intptr := c.target.IntPtrType()
+	i8ptr := llvm.PointerType(c.context.Int8Type(), 0)
+	stackend := c.module.NamedGlobal("__libc_stack_end")
+	if stackend.IsNil() {
+		stackend = llvm.AddGlobal(c.module.Module, i8ptr, "__libc_stack_end")
+	}
+	top := c.builder.CreateAlloca(intptr, "")
+	base := fn.FirstParam()
+	mainbase := c.builder.CreatePtrToInt(c.builder.CreateLoad(stackend, ""), intptr, "")
+	isZero := c.builder.CreateICmp(llvm.IntEQ, base, llvm.ConstNull(intptr), "")
+	base = c.builder.CreateSelect(isZero, mainbase, base, "")
+	gcscan := c.NamedFunction("runtime.gcscan", "func f(start, end uintptr)")
+	args := []llvm.Value{c.builder.CreatePtrToInt(top, intptr, ""), base}
+	c.builder.CreateCall(gcscan, args, "")
+	c.builder.CreateRetVoid()
+}
+
+// vim: set ft=go :
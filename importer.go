@@ -0,0 +1,88 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package llgo
+
+import (
+	"bufio"
+	"github.com/axw/llgo/types"
+	"go/ast"
+	"os"
+	"path/filepath"
+)
+
+// ExportDataExt is the extension of the files holding the export data for
+// packages compiled by llgo. The export data is written alongside the
+// package's compiled output, sharing its base name.
+const ExportDataExt = ".gox"
+
+// FindPackage searches the directories in importPaths for the export data
+// of the package with the specified import path, returning the name of the
+// export data file, or the empty string if none is found.
+func FindPackage(importPaths []string, path string) string {
+	for _, dir := range importPaths {
+		filename := filepath.Join(dir, filepath.FromSlash(path)+ExportDataExt)
+		if fi, err := os.Stat(filename); err == nil && !fi.IsDir() {
+			return filename
+		}
+	}
+	return ""
+}
+
+// newImporter returns an ast.Importer that resolves imports from the
+// export data of packages in the import paths, falling back to packages
+// compiled by gc. Each package is imported at most once.
+func newImporter(importPaths []string) ast.Importer {
+	imported := make(map[string]bool)
+	return func(imports map[string]*ast.Object, path string) (*ast.Object, error) {
+		if imported[path] {
+			return imports[path], nil
+		}
+		pkg, err := importPackage(importPaths, imports, path)
+		if err == nil {
+			imported[path] = true
+		}
+		return pkg, err
+	}
+}
+
+func importPackage(importPaths []string, imports map[string]*ast.Object, path string) (*ast.Object, error) {
+	if path == "unsafe" {
+		return types.Unsafe, nil
+	}
+	filename := FindPackage(importPaths, path)
+	if filename == "" {
+		return types.GcImport(imports, path)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	buf := bufio.NewReader(f)
+	if err = types.FindGcExportData(buf); err != nil {
+		return nil, err
+	}
+	return types.GcImportData(imports, filename, path, buf)
+}
+
+// vim: set ft=go :
@@ -0,0 +1,247 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package llgo
+
+import (
+	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo/types"
+	"go/ast"
+	"go/token"
+)
+
+// varInit is a package-level variable specification, along with the file
+// in which it is declared.
+type varInit struct {
+	file *ast.File
+	spec *ast.ValueSpec
+}
+
+// initOrder returns the package-level variable specifications of the
+// specified files in the order they must be initialised. A variable is
+// initialised after the variables its initialiser refers to, either
+// directly or through the functions it calls; otherwise variables are
+// initialised in declaration order. Initialisation loops are reported as
+// errors.
+func (c *compiler) initOrder(files []*ast.File) []varInit {
+	var inits []varInit
+	specs := make(map[*ast.Object]*ast.ValueSpec)
+	index := make(map[*ast.ValueSpec]int)
+	for _, file := range files {
+		for _, decl := range file.Decls {
+			gendecl, ok := decl.(*ast.GenDecl)
+			if !ok || gendecl.Tok != token.VAR {
+				continue
+			}
+			for _, spec := range gendecl.Specs {
+				valspec := spec.(*ast.ValueSpec)
+				for _, name := range valspec.Names {
+					if name.Obj != nil {
+						specs[name.Obj] = valspec
+					}
+				}
+				index[valspec] = len(inits)
+				inits = append(inits, varInit{file, valspec})
+			}
+		}
+	}
+
+	// deps returns the variable specifications that the initialiser of
+	// spec refers to, following references to functions declared in the
+	// package.
+	deps := func(spec *ast.ValueSpec) []*ast.ValueSpec {
+		var result []*ast.ValueSpec
+		seen := make(map[ast.Node]bool)
+		var visit func(node ast.Node)
+		visit = func(node ast.Node) {
+			ast.Inspect(node, func(n ast.Node) bool {
+				ident, ok := n.(*ast.Ident)
+				if !ok || ident.Obj == nil {
+					return true
+				}
+				switch decl := ident.Obj.Decl.(type) {
+				case *ast.ValueSpec:
+					if dep, ok := specs[ident.Obj]; ok && !seen[dep] {
+						seen[dep] = true
+						result = append(result, dep)
+					}
+				case *ast.FuncDecl:
+					if decl.Body != nil && !seen[decl] {
+						seen[decl] = true
+						visit(decl.Body)
+					}
+				}
+				return true
+			})
+		}
+		for _, value := range spec.Values {
+			visit(value)
+		}
+		return result
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(inits))
+	order := make([]varInit, 0, len(inits))
+	var visit func(i int)
+	visit = func(i int) {
+		switch state[i] {
+		case visited:
+			return
+		case visiting:
+			spec := inits[i].spec
+			c.errorf(spec.Pos(), "initialization loop for %s", spec.Names[0])
+			state[i] = visited
+			return
+		}
+		state[i] = visiting
+		for _, dep := range deps(inits[i].spec) {
+			visit(index[dep])
+		}
+		if state[i] == visiting {
+			state[i] = visited
+			order = append(order, inits[i])
+		}
+	}
+	for i := range inits {
+		visit(i)
+	}
+	return order
+}
+
+// createInitFunction creates the package's initialisation function,
+// "<pkg>.init". The function initialises each imported package, then the
+// package-level variables, and finally calls the package's init functions
+// in the order they were declared. Packages may be imported by more than
+// one package, so the function returns immediately if it has already run.
+func (c *compiler) createInitFunction() Value {
+	fntype := llvm.FunctionType(c.context.VoidType(), nil, false)
+	fn := llvm.AddFunction(c.module.Module, c.module.Name+".init", fntype)
+	done := llvm.AddGlobal(c.module.Module, c.context.Int1Type(), c.module.Name+".init.done")
+	done.SetLinkage(llvm.PrivateLinkage)
+	done.SetInitializer(llvm.ConstNull(c.context.Int1Type()))
+
+	entry := c.context.AddBasicBlock(fn, "entry")
+	initblock := c.context.AddBasicBlock(fn, "init")
+	doneblock := c.context.AddBasicBlock(fn, "done")
+	c.builder.SetInsertPointAtEnd(entry)
+	c.builder.CreateCondBr(c.builder.CreateLoad(done, ""), doneblock, initblock)
+
+	c.builder.SetInsertPointAtEnd(initblock)
+	c.builder.CreateStore(llvm.ConstAllOnes(c.context.Int1Type()), done)
+	for _, path := range c.module.Imports {
+		name := c.pkg.Imports[path].Name + ".init"
+		importfn := c.module.NamedFunction(name)
+		if importfn.IsNil() {
+			importfn = llvm.AddFunction(c.module.Module, name, fntype)
+		}
+		c.builder.CreateCall(importfn, nil, "")
+	}
+	for _, f := range c.varinitfuncs {
+		c.builder.CreateCall(f.LLVMValue(), nil, "")
+	}
+	for _, f := range c.initfuncs {
+		c.builder.CreateCall(f.LLVMValue(), nil, "")
+	}
+	c.builder.CreateBr(doneblock)
+
+	c.builder.SetInsertPointAtEnd(doneblock)
+	c.builder.CreateRetVoid()
+	return c.NewLLVMValue(fn, new(types.Func))
+}
+
+// createMainFunction creates the program's C entry point, main, which
+// records its arguments in the runtime, initialises the runtime and then
+// the main package, which initialises the packages it imports, and calls
+// main.main. The program exits with status 0 when main.main returns.
+func (c *compiler) createMainFunction(initfn Value) {
+	obj := c.pkg.Scope.Lookup("main")
+	if obj == nil || obj.Kind != ast.Fun {
+		var pos token.Pos
+		for _, file := range c.pkg.Files {
+			if pos == token.NoPos || file.Package < pos {
+				pos = file.Package
+			}
+		}
+		c.errorf(pos, "function main is undeclared in the main package")
+		return
+	}
+	mainfn := c.Resolve(obj).LLVMValue()
+
+	i32 := c.context.Int32Type()
+	i8ptrptr := llvm.PointerType(llvm.PointerType(c.context.Int8Type(), 0), 0)
+	fntype := llvm.FunctionType(i32, []llvm.Type{i32, i8ptrptr, i8ptrptr}, false)
+	fn := llvm.AddFunction(c.module.Module, "main", fntype)
+	entry := c.context.AddBasicBlock(fn, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+
+	setargs := c.NamedFunction("runtime.setargs", "func f(argc int32, argv, envp unsafe.Pointer)")
+	argv := c.builder.CreatePtrToInt(fn.Param(1), c.target.IntPtrType(), "")
+	envp := c.builder.CreatePtrToInt(fn.Param(2), c.target.IntPtrType(), "")
+	c.builder.CreateCall(setargs, []llvm.Value{fn.Param(0), argv, envp}, "")
+
+	c.builder.CreateCall(c.runtimeInitFunction(), nil, "")
+	c.builder.CreateCall(initfn.LLVMValue(), nil, "")
+	c.builder.CreateCall(mainfn, nil, "")
+	c.builder.CreateRet(llvm.ConstNull(i32))
+}
+
+// runtimeInitFunction returns the runtime's init function. The runtime is
+// linked into every program, but not imported.
+func (c *compiler) runtimeInitFunction() llvm.Value {
+	runtimeinit := c.module.NamedFunction("runtime.init")
+	if runtimeinit.IsNil() {
+		voidfntype := llvm.FunctionType(c.context.VoidType(), nil, false)
+		runtimeinit = llvm.AddFunction(c.module.Module, "runtime.init", voidfntype)
+	}
+	return runtimeinit
+}
+
+// createExportFunctions creates a C entry point for each function marked
+// with an "//export Name" comment. The entry point is named Name, and has
+// the signature of the Go function lowered to the C ABI; see abi.go. It
+// initialises the runtime and the package, which is a no-op after the
+// first call, so the function may be called from a C program that was not
+// started by llgo's C main function, such as one that embeds a library
+// compiled by llgo.
+func (c *compiler) createExportFunctions(initfn Value) {
+	for _, f := range c.exports {
+		name := commentDirective(f.Doc, "export")
+		if !c.module.NamedFunction(name).IsNil() {
+			c.errorf(f.Pos(), "exported name %s is already defined", name)
+			continue
+		}
+		gofn := c.Resolve(f.Name.Obj).LLVMValue()
+		c.defineCFunction(name, gofn, c.runtimeInitFunction(), initfn.LLVMValue())
+		if c.module.ExportTypes == nil {
+			c.module.ExportTypes = make(map[string]llvm.Type)
+		}
+		c.module.Exports = append(c.module.Exports, name)
+		c.module.ExportTypes[name] = gofn.Type().ElementType()
+	}
+}
+
+// vim: set ft=go :
@@ -25,199 +25,347 @@ package llgo
 import (
 	"github.com/axw/gollvm/llvm"
 	"github.com/axw/llgo/types"
-	"sort"
+	"go/ast"
 )
 
+// Interface values are a pair of pointers: a type word, and a data
+// word. The type word of an empty interface is the runtime type of its
+// dynamic type; the type word of any other interface is an itab, created
+// by the runtime and cached per pair of interface and dynamic type, whose
+// words are the interface's runtime type, the dynamic type, a link used
+// by the runtime's cache, and then the functions implementing the
+// interface's methods. Both words are nil in a nil interface.
+const (
+	itabTypeWord    = 1
+	itabMethodsWord = 3
+)
+
+// loadItabWord loads the word at the specified index of an itab.
+func (c *compiler) loadItabWord(tab llvm.Value, index int) llvm.Value {
+	i8ptr := llvm.PointerType(c.context.Int8Type(), 0)
+	words := c.builder.CreateBitCast(tab, llvm.PointerType(i8ptr, 0), "")
+	indices := []llvm.Value{llvm.ConstInt(c.context.Int32Type(), uint64(index), false)}
+	return c.builder.CreateLoad(c.builder.CreateGEP(words, indices, ""), "")
+}
+
+// interfaceDynamicType returns the runtime type of an interface's dynamic
+// type as a uintptr, or zero if the interface is nil.
+func (v *LLVMValue) interfaceDynamicType() llvm.Value {
+	c := v.compiler
+	builder := c.builder
+	uintptrType := c.target.IntPtrType()
+	tab := builder.CreateExtractValue(v.LLVMValue(), 0, "")
+	iface := types.Underlying(v.Type()).(*types.Interface)
+	if len(iface.Methods) == 0 {
+		return builder.CreatePtrToInt(tab, uintptrType, "")
+	}
+
+	// Load the dynamic type from the itab, if the interface is non-nil.
+	startBlock := builder.GetInsertBlock()
+	end := v.compiler.context.InsertBasicBlock(startBlock, "end")
+	end.MoveAfter(startBlock)
+	nonnil := v.compiler.context.InsertBasicBlock(end, "nonnil")
+	builder.CreateCondBr(builder.CreateIsNull(tab, ""), end, nonnil)
+
+	builder.SetInsertPointAtEnd(nonnil)
+	typ := c.loadItabWord(tab, itabTypeWord)
+	typ = builder.CreatePtrToInt(typ, uintptrType, "")
+	builder.CreateBr(end)
+
+	builder.SetInsertPointAtEnd(end)
+	result := builder.CreatePHI(uintptrType, "")
+	result.AddIncoming(
+		[]llvm.Value{llvm.ConstNull(uintptrType), typ},
+		[]llvm.BasicBlock{startBlock, nonnil})
+	return result
+}
+
+// makeInterface creates an interface value of the specified type from a
+// data word, and the runtime type of the dynamic type as a uintptr. For
+// non-empty interfaces, the runtime is called to get the itab for the
+// pair of interface and dynamic type; the itab is nil if the dynamic type
+// is nil, or if it does not implement the interface.
+func (c *compiler) makeInterface(iface *types.Interface, typ, data llvm.Value) llvm.Value {
+	builder := c.builder
+	uintptrType := c.target.IntPtrType()
+	i8ptr := llvm.PointerType(c.context.Int8Type(), 0)
+	if len(iface.Methods) > 0 {
+		getitab := c.NamedFunction("runtime.getitab", "func f(iface, t uintptr) uintptr")
+		ifaceType := builder.CreatePtrToInt(c.types.ToRuntime(iface), uintptrType, "")
+		typ = builder.CreateCall(getitab, []llvm.Value{ifaceType, typ}, "")
+	}
+	tab := builder.CreateIntToPtr(typ, i8ptr, "")
+	value := llvm.ConstNull(c.types.ToLLVM(iface))
+	value = builder.CreateInsertValue(value, tab, 0, "")
+	value = builder.CreateInsertValue(value, data, 1, "")
+	return value
+}
+
+// boxValue returns the data word of an interface holding the specified
+// value. Values no larger than a pointer are stored in the data word
+// itself; larger values are copied to the heap, and the data word points
+// to the copy, so the interface never aliases the original value.
+func (c *compiler) boxValue(value llvm.Value) llvm.Value {
+	builder := c.builder
+	i8ptr := llvm.PointerType(c.context.Int8Type(), 0)
+	llvmtyp := value.Type()
+	if c.target.TypeAllocSize(llvmtyp) > uint64(c.target.PointerSize()) {
+		ptr := c.createTypeMalloc(llvmtyp)
+		builder.CreateStore(value, ptr)
+		return builder.CreateBitCast(ptr, i8ptr, "")
+	}
+	if llvmtyp.TypeKind() == llvm.PointerTypeKind {
+		return builder.CreateBitCast(value, i8ptr, "")
+	}
+	bits := c.target.TypeSizeInBits(llvmtyp)
+	if bits == 0 {
+		return llvm.ConstNull(i8ptr)
+	}
+	inttyp := c.context.IntType(int(bits))
+	switch llvmtyp.TypeKind() {
+	case llvm.StructTypeKind, llvm.ArrayTypeKind:
+		// Aggregates can't be bitcast, so reinterpret them through memory.
+		mem := builder.CreateAlloca(llvmtyp, "")
+		builder.CreateStore(value, mem)
+		mem = builder.CreateBitCast(mem, llvm.PointerType(inttyp, 0), "")
+		value = builder.CreateLoad(mem, "")
+	default:
+		value = builder.CreateBitCast(value, inttyp, "")
+	}
+	return builder.CreateIntToPtr(value, i8ptr, "")
+}
+
+// unboxValue returns the value of the specified LLVM type held in an
+// interface's data word, which must have been created by boxValue. Boxed
+// values are loaded, so the result is a copy of the interface's value.
+func (c *compiler) unboxValue(data llvm.Value, llvmtyp llvm.Type) llvm.Value {
+	builder := c.builder
+	if c.target.TypeAllocSize(llvmtyp) > uint64(c.target.PointerSize()) {
+		ptr := builder.CreateBitCast(data, llvm.PointerType(llvmtyp, 0), "")
+		return builder.CreateLoad(ptr, "")
+	}
+	if llvmtyp.TypeKind() == llvm.PointerTypeKind {
+		return builder.CreateBitCast(data, llvmtyp, "")
+	}
+	bits := c.target.TypeSizeInBits(llvmtyp)
+	if bits == 0 {
+		return llvm.ConstNull(llvmtyp)
+	}
+	inttyp := c.context.IntType(int(bits))
+	value := builder.CreatePtrToInt(data, inttyp, "")
+	switch llvmtyp.TypeKind() {
+	case llvm.StructTypeKind, llvm.ArrayTypeKind:
+		mem := builder.CreateAlloca(llvmtyp, "")
+		intmem := builder.CreateBitCast(mem, llvm.PointerType(inttyp, 0), "")
+		builder.CreateStore(value, intmem)
+		return builder.CreateLoad(mem, "")
+	}
+	return builder.CreateBitCast(value, llvmtyp, "")
+}
+
 // convertV2I converts a value to an interface.
 func (v *LLVMValue) convertV2I(iface *types.Interface) Value {
-	// TODO deref indirect value, then use 'pointer' as pointer value.
-	var srcname *types.Name
-	srctyp := v.Type()
-	if name, isname := srctyp.(*types.Name); isname {
-		srcname = name
-		srctyp = name.Underlying
-	}
-
-	isptr := false
-	if p, fromptr := srctyp.(*types.Pointer); fromptr {
-		isptr = true
-		srctyp = p.Base
-		if name, isname := srctyp.(*types.Name); isname {
-			srcname = name
-			srctyp = name.Underlying
-		}
+	c := v.compiler
+	data := c.boxValue(v.LLVMValue())
+	runtimeType := c.types.ToRuntime(v.Type())
+	runtimeType = c.builder.CreatePtrToInt(runtimeType, c.target.IntPtrType(), "")
+	return c.NewLLVMValue(c.makeInterface(iface, runtimeType, data), iface)
+}
+
+// makeInterfaceMethod returns the function stored in itabs for the method
+// m of a named type, which is called with an interface's data word as its
+// receiver. If ptr is true, the data word is a pointer to the named type;
+// otherwise it holds a value of the named type, boxed by boxValue. Methods
+// with value receivers are wrapped to load or unbox the receiver.
+func (c *compiler) makeInterfaceMethod(m *ast.Object, ptr bool) llvm.Value {
+	fn := c.Resolve(m).LLVMValue()
+	ftyp := m.Type.(*types.Func)
+	recvtyp := ftyp.Recv.Type.(types.Type)
+	if _, isptr := recvtyp.(*types.Pointer); isptr {
+		return fn
+	}
+	llvmrecvtyp := c.types.ToLLVM(recvtyp)
+	if !ptr && llvmrecvtyp.TypeKind() == llvm.PointerTypeKind {
+		return fn
 	}
 
-	iface_struct_type := v.compiler.types.ToLLVM(iface)
-	element_types := iface_struct_type.StructElementTypes()
-	iface_elements := make([]llvm.Value, len(element_types))
-	for i, _ := range iface_elements {
-		iface_elements[i] = llvm.ConstNull(element_types[i])
+	recv := ast.NewObj(ast.Var, "")
+	recv.Type = &types.Pointer{Base: types.Int8}
+	wrappertyp := &types.Func{
+		Recv:       recv,
+		Params:     ftyp.Params,
+		Results:    ftyp.Results,
+		IsVariadic: ftyp.IsVariadic,
 	}
-	iface_struct := llvm.ConstStruct(iface_elements, false)
+	defer c.restoreInsertPoint(c.saveInsertPoint())
+	llvm_fn_type := c.types.rawFuncLLVMType(wrappertyp).ElementType()
+	wrapper := llvm.AddFunction(c.module.Module, "", llvm_fn_type)
+	wrapper.SetLinkage(llvm.PrivateLinkage)
+	entry := c.context.AddBasicBlock(wrapper, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
 
-	builder := v.compiler.builder
-	var ptr llvm.Value
-	if isptr {
-		ptr = v.LLVMValue()
+	var recvValue llvm.Value
+	if ptr {
+		// Calling a value method through a nil pointer panics.
+		data := wrapper.Param(0)
+		c.nilCheck(data)
+		data = c.builder.CreateBitCast(data, llvm.PointerType(llvmrecvtyp, 0), "")
+		recvValue = c.builder.CreateLoad(data, "")
 	} else {
-		// If the value fits exactly in a pointer, then we can just
-		// bitcast it. Otherwise we need to malloc, and create a shim
-		// function to load the receiver.
-		lv := v.LLVMValue()
-		c := v.compiler
-		ptrsize := c.target.PointerSize()
-		if c.target.TypeStoreSize(lv.Type()) <= uint64(ptrsize) {
-			bits := c.target.TypeSizeInBits(lv.Type())
-			if bits > 0 {
-				lv = builder.CreateBitCast(lv, llvm.IntType(int(bits)), "")
-				ptr = builder.CreateIntToPtr(lv, element_types[0], "")
-			} else {
-				ptr = llvm.ConstNull(element_types[0])
-			}
-		} else {
-			ptr = builder.CreateMalloc(v.compiler.types.ToLLVM(srctyp), "")
-			builder.CreateStore(lv, ptr)
-			// TODO signal that shim functions are required. Probably later
-			// we'll have the CallExpr handler pick out the type, and check
-			// if the receiver is a pointer or a value type, and load as
-			// necessary.
-		}
+		recvValue = c.unboxValue(wrapper.Param(0), llvmrecvtyp)
 	}
-	ptr = builder.CreateBitCast(ptr, element_types[0], "")
-	iface_struct = builder.CreateInsertValue(iface_struct, ptr, 0, "")
-
-	var runtimeType llvm.Value
-	if srcname != nil {
-		runtimeType = v.compiler.types.ToRuntime(srcname)
+	args := append([]llvm.Value{recvValue}, wrapper.Params()[1:]...)
+	result := c.builder.CreateCall(fn, args, "")
+	if llvm_fn_type.ReturnType().TypeKind() == llvm.VoidTypeKind {
+		c.builder.CreateRetVoid()
 	} else {
-		runtimeType = v.compiler.types.ToRuntime(srctyp)
-	}
-	runtimeType = builder.CreateBitCast(runtimeType, element_types[1], "")
-	iface_struct = builder.CreateInsertValue(iface_struct, runtimeType, 1, "")
-
-	// TODO assert either source is a named type (or pointer to), or the
-	// interface has an empty methodset.
-
-	if srcname != nil {
-		// TODO check whether the functions in the struct take
-		// value or pointer receivers.
-
-		// Look up the method by name.
-		methods := srcname.Methods
-		for i, m := range iface.Methods {
-			// TODO make this loop linear by iterating through the
-			// interface methods and type methods together.
-			mi := sort.Search(len(methods), func(i int) bool {
-				return methods[i].Name >= m.Name
-			})
-			if mi >= len(methods) || methods[mi].Name != m.Name {
-				panic("Failed to locate method: " + m.Name)
-			}
-			method_obj := methods[mi]
-			method := v.compiler.Resolve(method_obj).(*LLVMValue)
-			llvm_value := method.LLVMValue()
-			llvm_value = builder.CreateBitCast(
-				llvm_value, element_types[i+2], "")
-			iface_struct = builder.CreateInsertValue(
-				iface_struct, llvm_value, i+2, "")
-		}
+		c.builder.CreateRet(result)
 	}
-
-	return v.compiler.NewLLVMValue(iface_struct, iface)
+	return wrapper
 }
 
 // convertI2I converts an interface to another interface.
 func (v *LLVMValue) convertI2I(iface *types.Interface) Value {
-	builder := v.compiler.builder
-	src_typ := v.Type()
-	vptr := v.pointer.LLVMValue()
-	src_typ = src_typ.(*types.Name).Underlying
-
-	iface_struct_type := v.compiler.types.ToLLVM(iface)
-	element_types := iface_struct_type.StructElementTypes()
-	iface_elements := make([]llvm.Value, len(element_types))
-	for i, _ := range iface_elements {
-		iface_elements[i] = llvm.ConstNull(element_types[i])
-	}
-	iface_struct := llvm.ConstStruct(iface_elements, false)
-	receiver := builder.CreateLoad(builder.CreateStructGEP(vptr, 0, ""), "")
-	iface_struct = builder.CreateInsertValue(iface_struct, receiver, 0, "")
-
-	// TODO check whether the functions in the struct take
-	// value or pointer receivers.
-
-	// TODO handle dynamic interface conversion (non-subset).
-	methods := src_typ.(*types.Interface).Methods
-	for i, m := range iface.Methods {
-		// TODO make this loop linear by iterating through the
-		// interface methods and type methods together.
-		mi := sort.Search(len(methods), func(i int) bool {
-			return methods[i].Name >= m.Name
-		})
-		if mi >= len(methods) || methods[mi].Name != m.Name {
-			panic("Failed to locate method: " + m.Name)
-		}
-		method := builder.CreateStructGEP(vptr, mi+2, "")
-		iface_struct = builder.CreateInsertValue(
-			iface_struct, builder.CreateLoad(method, ""), i+2, "")
-	}
-	return v.compiler.NewLLVMValue(iface_struct, iface)
+	c := v.compiler
+	typ := v.interfaceDynamicType()
+	data := c.builder.CreateExtractValue(v.LLVMValue(), 1, "")
+	return c.NewLLVMValue(c.makeInterface(iface, typ, data), iface)
 }
 
-// convertI2V converts an interface to a value.
-func (v *LLVMValue) convertI2V(typ types.Type) Value {
-	typptrType := llvm.PointerType(llvm.Int8Type(), 0)
-	runtimeType := v.compiler.types.ToRuntime(typ)
-	runtimeType = llvm.ConstBitCast(runtimeType, typptrType)
-	vptr := v.pointer.LLVMValue()
-
-	builder := v.compiler.builder
-	ifaceType := builder.CreateLoad(builder.CreateStructGEP(vptr, 1, ""), "")
-	diff := builder.CreatePtrDiff(runtimeType, ifaceType, "")
-	zero := llvm.ConstNull(diff.Type())
-	predicate := builder.CreateICmp(llvm.IntEQ, diff, zero, "")
-	llvmtype := v.compiler.types.ToLLVM(typ)
-	result := builder.CreateAlloca(llvmtype, "")
-
-	// If result is zero, then we've got a match.
-	end := llvm.InsertBasicBlock(builder.GetInsertBlock(), "end")
-	end.MoveAfter(builder.GetInsertBlock())
-	nonmatch := llvm.InsertBasicBlock(end, "nonmatch")
-	match := llvm.InsertBasicBlock(nonmatch, "match")
+// convertI2V converts an interface to a value. The result is a pair of
+// values: the value stored in the interface, if the dynamic type matches
+// the specified type; and a boolean value indicating whether the types
+// match. If the types do not match, the resulting value will be the zero
+// value of the specified type.
+func (v *LLVMValue) convertI2V(typ types.Type) (result, success Value) {
+	c := v.compiler
+	builder := c.builder
+	uintptrType := c.target.IntPtrType()
+	runtimeType := c.types.ToRuntime(typ)
+	runtimeType = builder.CreatePtrToInt(runtimeType, uintptrType, "")
+	ifaceType := v.interfaceDynamicType()
+	predicate := builder.CreateICmp(llvm.IntEQ, ifaceType, runtimeType, "")
+
+	startBlock := builder.GetInsertBlock()
+	end := v.compiler.context.InsertBasicBlock(startBlock, "end")
+	end.MoveAfter(startBlock)
+	nonmatch := v.compiler.context.InsertBasicBlock(end, "nonmatch")
+	match := v.compiler.context.InsertBasicBlock(nonmatch, "match")
 	builder.CreateCondBr(predicate, match, nonmatch)
 
 	builder.SetInsertPointAtEnd(match)
-	value := builder.CreateLoad(builder.CreateStructGEP(vptr, 0, ""), "")
-	value = builder.CreateBitCast(value, result.Type(), "")
-	value = builder.CreateLoad(value, "")
-	builder.CreateStore(value, result)
+	matchValue := v.loadI2V(typ).LLVMValue()
+	match = builder.GetInsertBlock()
 	builder.CreateBr(end)
 
-	// TODO should return {value, ok}
 	builder.SetInsertPointAtEnd(nonmatch)
-	builder.CreateStore(llvm.ConstNull(llvmtype), result)
+	nonmatchValue := llvm.ConstNull(matchValue.Type())
 	builder.CreateBr(end)
 
-	//builder.SetInsertPointAtEnd(end)
-	result = builder.CreateLoad(result, "")
-	return v.compiler.NewLLVMValue(result, typ)
+	builder.SetInsertPointAtEnd(end)
+	blocks := []llvm.BasicBlock{match, nonmatch}
+	resultValue := builder.CreatePHI(matchValue.Type(), "")
+	resultValue.AddIncoming([]llvm.Value{matchValue, nonmatchValue}, blocks)
+	successValue := builder.CreatePHI(v.compiler.context.Int1Type(), "")
+	successValue.AddIncoming([]llvm.Value{
+		llvm.ConstAllOnes(v.compiler.context.Int1Type()),
+		llvm.ConstNull(v.compiler.context.Int1Type()),
+	}, blocks)
+
+	result = c.NewLLVMValue(resultValue, typ)
+	success = c.NewLLVMValue(successValue, types.Bool)
+	return result, success
+}
+
+// typeAssert performs a type assertion on an interface value. The result
+// is the asserted value, and a boolean value indicating whether the
+// assertion succeeded; if it did not, the asserted value is the zero
+// value of the specified type.
+func (v *LLVMValue) typeAssert(typ types.Type) (result, success Value) {
+	if iface, ok := types.Underlying(typ).(*types.Interface); ok {
+		src := types.Underlying(v.Type()).(*types.Interface)
+		if !methodsSubset(iface, src) {
+			return v.dynamicConvertI2I(iface, typ)
+		}
+
+		// The interface's methods are a subset of the source interface's
+		// methods, so the assertion succeeds if and only if the interface
+		// is non-nil.
+		c := v.compiler
+		value := v.convertI2I(iface).LLVMValue()
+		tab := c.builder.CreateExtractValue(v.LLVMValue(), 0, "")
+		notnull := c.builder.CreateIsNotNull(tab, "")
+		result = c.NewLLVMValue(value, typ)
+		success = c.NewLLVMValue(notnull, types.Bool)
+		return result, success
+	}
+	return v.convertI2V(typ)
+}
+
+// methodsSubset reports whether the methods of interface a are a subset
+// of the methods of interface b.
+func methodsSubset(a, b *types.Interface) bool {
+	j := 0
+	for _, m := range a.Methods {
+		for j < len(b.Methods) && b.Methods[j].Name < m.Name {
+			j++
+		}
+		if j == len(b.Methods) || b.Methods[j].Name != m.Name {
+			return false
+		}
+	}
+	return true
+}
+
+// dynamicConvertI2I converts an interface to another interface whose
+// methods are not a subset of the source interface's, by having the
+// runtime look up the methods of the dynamic type. The result is the
+// converted interface value, and a boolean value indicating whether the
+// dynamic type implements the interface; if it does not, the converted
+// value is nil.
+func (v *LLVMValue) dynamicConvertI2I(iface *types.Interface, typ types.Type) (result, success Value) {
+	c := v.compiler
+	builder := c.builder
+	value := v.convertI2I(iface).LLVMValue()
+	ok := builder.CreateIsNotNull(builder.CreateExtractValue(value, 0, ""), "")
+	value = builder.CreateSelect(ok, value, llvm.ConstNull(value.Type()), "")
+	result = c.NewLLVMValue(value, typ)
+	success = c.NewLLVMValue(ok, types.Bool)
+	return result, success
+}
+
+// mustTypeAssert performs a type assertion on an interface value,
+// calling runtime.panictypeassert if the assertion fails.
+func (v *LLVMValue) mustTypeAssert(typ types.Type) Value {
+	c := v.compiler
+	builder := c.builder
+	result, success := v.typeAssert(typ)
+
+	currBlock := builder.GetInsertBlock()
+	okBlock := v.compiler.context.InsertBasicBlock(currBlock, "")
+	okBlock.MoveAfter(currBlock)
+	failBlock := v.compiler.context.InsertBasicBlock(okBlock, "")
+	builder.CreateCondBr(success.LLVMValue(), okBlock, failBlock)
+
+	builder.SetInsertPointAtEnd(failBlock)
+	uintptrType := c.target.IntPtrType()
+	iface := builder.CreatePtrToInt(c.types.ToRuntime(v.Type()), uintptrType, "")
+	have := v.interfaceDynamicType()
+	want := builder.CreatePtrToInt(c.types.ToRuntime(typ), uintptrType, "")
+	panictypeassert := c.NamedFunction("runtime.panictypeassert", "func f(iface, have, want uintptr)")
+	builder.CreateCall(panictypeassert, []llvm.Value{iface, have, want}, "")
+	builder.CreateUnreachable()
+
+	builder.SetInsertPointAtEnd(okBlock)
+	return result
 }
 
 // loadI2V loads an interface value to a type, without checking
 // that the interface type matches.
 func (v *LLVMValue) loadI2V(typ types.Type) Value {
 	c := v.compiler
-	if c.sizeofType(typ) > c.target.PointerSize() {
-		ptr := c.builder.CreateExtractValue(v.LLVMValue(), 0, "")
-		typ = &types.Pointer{Base: typ}
-		ptr = c.builder.CreateBitCast(ptr, c.types.ToLLVM(typ), "")
-		return c.NewLLVMValue(ptr, typ).makePointee()
-	}
-	bits := c.target.TypeSizeInBits(c.types.ToLLVM(typ))
-	value := c.builder.CreateExtractValue(v.LLVMValue(), 0, "")
-	value = c.builder.CreatePtrToInt(value, llvm.IntType(int(bits)), "")
-	value = c.builder.CreateBitCast(value, c.types.ToLLVM(typ), "")
+	data := c.builder.CreateExtractValue(v.LLVMValue(), 1, "")
+	value := c.unboxValue(data, c.types.ToLLVM(typ))
 	return c.NewLLVMValue(value, typ)
 }
 
@@ -227,23 +375,23 @@ func (lhs *LLVMValue) compareI2I(rhs *LLVMValue) Value {
 	c := lhs.compiler
 	b := c.builder
 
-	lhsValue := b.CreateExtractValue(lhs.LLVMValue(), 0, "")
-	rhsValue := b.CreateExtractValue(rhs.LLVMValue(), 0, "")
-	lhsType := b.CreateExtractValue(lhs.LLVMValue(), 1, "")
-	rhsType := b.CreateExtractValue(rhs.LLVMValue(), 1, "")
+	lhsValue := b.CreateExtractValue(lhs.LLVMValue(), 1, "")
+	rhsValue := b.CreateExtractValue(rhs.LLVMValue(), 1, "")
+	lhsType := lhs.interfaceDynamicType()
+	rhsType := rhs.interfaceDynamicType()
 
 	llvmUintptr := c.target.IntPtrType()
 	runtimeCompareI2I := c.module.Module.NamedFunction("runtime.compareI2I")
 	if runtimeCompareI2I.IsNil() {
 		args := []llvm.Type{llvmUintptr, llvmUintptr, llvmUintptr, llvmUintptr}
-		functype := llvm.FunctionType(llvm.Int1Type(), args, false)
+		functype := llvm.FunctionType(lhs.compiler.context.Int1Type(), args, false)
 		runtimeCompareI2I = llvm.AddFunction(
 			c.module.Module, "runtime.compareI2I", functype)
 	}
 
 	args := []llvm.Value{
-		c.builder.CreatePtrToInt(lhsType, llvmUintptr, ""),
-		c.builder.CreatePtrToInt(rhsType, llvmUintptr, ""),
+		lhsType,
+		rhsType,
 		c.builder.CreatePtrToInt(lhsValue, llvmUintptr, ""),
 		c.builder.CreatePtrToInt(rhsValue, llvmUintptr, ""),
 	}
@@ -25,6 +25,7 @@ package llgo
 import (
 	"github.com/axw/gollvm/llvm"
 	"strconv"
+	"strings"
 )
 
 func (c *compiler) defineRuntimeIntrinsics() {
@@ -52,20 +53,145 @@ func (c *compiler) defineRuntimeIntrinsics() {
 	if !fn.IsNil() {
 		c.defineMemsetFunction(fn)
 	}
+
+	fn = c.module.NamedFunction("runtime.gcscanstack")
+	if !fn.IsNil() {
+		c.defineGCScanStackFunction(fn)
+	}
+
+	fn = c.module.NamedFunction("runtime.gcshadowstack")
+	if !fn.IsNil() {
+		c.defineGCShadowStackFunction(fn)
+	}
+
+	fn = c.module.NamedFunction("runtime.gcsetshadowstack")
+	if !fn.IsNil() {
+		c.defineGCSetShadowStackFunction(fn)
+	}
+
+	fn = c.module.NamedFunction("runtime.putchar")
+	if !fn.IsNil() {
+		c.definePutcharFunction(fn)
+	}
+
+	// Other packages may declare runtime.write, runtime.exit and
+	// runtime.yield with the "#llgo name" directive, so they are only
+	// defined in the runtime.
+	if c.module.Name == "runtime" {
+		fn = c.module.NamedFunction("runtime.yield")
+		if !fn.IsNil() {
+			c.defineYieldFunction(fn)
+		}
+
+		fn = c.module.NamedFunction("runtime.write")
+		if !fn.IsNil() {
+			c.defineWriteFunction(fn)
+		}
+
+		fn = c.module.NamedFunction("runtime.exit")
+		if !fn.IsNil() {
+			c.defineExitFunction(fn)
+		}
+	}
+}
+
+// defineSyscallIntrinsics defines the functions that the syscall package
+// uses to make system calls.
+func (c *compiler) defineSyscallIntrinsics() {
+	for _, name := range []string{"Syscall", "Syscall6", "RawSyscall", "RawSyscall6"} {
+		fn := c.module.NamedFunction("syscall." + name)
+		if !fn.IsNil() {
+			raw := strings.HasPrefix(name, "Raw")
+			c.defineSyscallFunction(fn, raw)
+		}
+	}
 }
 
 func (c *compiler) memsetZero(ptr llvm.Value, size llvm.Value) {
 	memset := c.NamedFunction("runtime.memset", "func f(dst unsafe.Pointer, fill byte, size int)")
 	ptr = c.builder.CreatePtrToInt(ptr, c.target.IntPtrType(), "")
-	fill := llvm.ConstNull(llvm.Int8Type())
+	fill := llvm.ConstNull(c.context.Int8Type())
 	c.builder.CreateCall(memset, []llvm.Value{ptr, fill, size}, "")
 }
 
+// isAggregate reports whether values of the LLVM type are structs or
+// arrays, which LLVM loads and stores element by element.
+func isAggregate(typ llvm.Type) bool {
+	switch typ.TypeKind() {
+	case llvm.StructTypeKind, llvm.ArrayTypeKind:
+		return true
+	}
+	return false
+}
+
+// memcpyIntrinsic copies size bytes from src to dst with llvm.memcpy. The
+// memory regions must not partially overlap, and both must have the specified
+// alignment.
+func (c *compiler) memcpyIntrinsic(dst, src, size llvm.Value, align int) {
+	sizeBits := size.Type().IntTypeWidth()
+	memcpyName := "llvm.memcpy.p0i8.p0i8.i" + strconv.Itoa(sizeBits)
+	memcpy := c.NamedFunction(memcpyName, "func f(dst, src *int8, size int, align int32, volatile bool)")
+	pint8 := memcpy.Type().ElementType().ParamTypes()[0]
+	args := []llvm.Value{
+		c.builder.CreateBitCast(dst, pint8, ""),
+		c.builder.CreateBitCast(src, pint8, ""),
+		size,
+		llvm.ConstInt(c.context.Int32Type(), uint64(align), false),
+		llvm.ConstInt(c.context.Int1Type(), 0, false),
+	}
+	c.builder.CreateCall(memcpy, args, "")
+}
+
+// memsetZeroIntrinsic zeroes size bytes at dst, which must have the
+// specified alignment, with llvm.memset.
+func (c *compiler) memsetZeroIntrinsic(dst, size llvm.Value, align int) {
+	sizeBits := size.Type().IntTypeWidth()
+	memsetName := "llvm.memset.p0i8.i" + strconv.Itoa(sizeBits)
+	memset := c.NamedFunction(memsetName, "func f(dst *int8, fill byte, size int, align int32, volatile bool)")
+	pint8 := memset.Type().ElementType().ParamTypes()[0]
+	args := []llvm.Value{
+		c.builder.CreateBitCast(dst, pint8, ""),
+		llvm.ConstNull(c.context.Int8Type()),
+		size,
+		llvm.ConstInt(c.context.Int32Type(), uint64(align), false),
+		llvm.ConstInt(c.context.Int1Type(), 0, false),
+	}
+	c.builder.CreateCall(memset, args, "")
+}
+
+// storeZero stores the zero value of ptr's element type into the memory
+// that ptr points to. Structs and arrays are zeroed with llvm.memset.
+func (c *compiler) storeZero(ptr llvm.Value) {
+	typ := ptr.Type().ElementType()
+	if !isAggregate(typ) {
+		c.builder.CreateStore(llvm.ConstNull(typ), ptr)
+		return
+	}
+	size := llvm.ConstInt(c.target.IntPtrType(), c.target.TypeAllocSize(typ), false)
+	c.memsetZeroIntrinsic(ptr, size, c.target.ABITypeAlignment(typ))
+}
+
+// storeValue stores value into the memory that ptr points to. A struct
+// or array value that is held in memory of the same type is copied with
+// llvm.memcpy, rather than loaded and stored.
+func (c *compiler) storeValue(value Value, ptr llvm.Value) {
+	typ := ptr.Type().ElementType()
+	if v, ok := value.(*LLVMValue); ok && v.pointer != nil && isAggregate(typ) {
+		src := v.pointer.LLVMValue()
+		if src.Type() == ptr.Type() {
+			size := llvm.ConstInt(c.target.IntPtrType(), c.target.TypeAllocSize(typ), false)
+			c.memcpyIntrinsic(ptr, src, size, c.target.ABITypeAlignment(typ))
+			return
+		}
+	}
+	c.builder.CreateStore(value.LLVMValue(), ptr)
+}
+
 func (c *compiler) defineMallocFunction(fn llvm.Value) {
-	entry := llvm.AddBasicBlock(fn, "entry")
+	entry := c.context.AddBasicBlock(fn, "entry")
 	c.builder.SetInsertPointAtEnd(entry)
 	size := fn.FirstParam()
-	ptr := c.builder.CreateArrayMalloc(llvm.Int8Type(), size, "")
+	ptr := c.builder.CreateArrayMalloc(c.context.Int8Type(), size, "")
 	c.memsetZero(ptr, size)
 	fn_type := fn.Type().ElementType()
 	result := c.builder.CreatePtrToInt(ptr, fn_type.ReturnType(), "")
@@ -73,16 +199,16 @@ func (c *compiler) defineMallocFunction(fn llvm.Value) {
 }
 
 func (c *compiler) defineFreeFunction(fn llvm.Value) {
-	entry := llvm.AddBasicBlock(fn, "entry")
+	entry := c.context.AddBasicBlock(fn, "entry")
 	c.builder.SetInsertPointAtEnd(entry)
 	ptr := fn.FirstParam()
-	ptrtyp := llvm.PointerType(llvm.Int8Type(), 0)
+	ptrtyp := llvm.PointerType(c.context.Int8Type(), 0)
 	c.builder.CreateFree(c.builder.CreateIntToPtr(ptr, ptrtyp, ""))
 	c.builder.CreateRetVoid()
 }
 
 func (c *compiler) defineMemcpyFunction(fn llvm.Value, name string) {
-	entry := llvm.AddBasicBlock(fn, "entry")
+	entry := c.context.AddBasicBlock(fn, "entry")
 	c.builder.SetInsertPointAtEnd(entry)
 	dst, src, size := fn.Param(0), fn.Param(1), fn.Param(2)
 	sizeType := size.Type()
@@ -96,15 +222,112 @@ func (c *compiler) defineMemcpyFunction(fn llvm.Value, name string) {
 	src = c.builder.CreateIntToPtr(src, pint8, "")
 	args := []llvm.Value{
 		dst, src, size,
-		llvm.ConstInt(llvm.Int32Type(), 1, false), // single byte alignment
-		llvm.ConstInt(llvm.Int1Type(), 0, false),  // not volatile
+		llvm.ConstInt(c.context.Int32Type(), 1, false), // single byte alignment
+		llvm.ConstInt(c.context.Int1Type(), 0, false),  // not volatile
 	}
 	c.builder.CreateCall(memcpy, args, "")
 	c.builder.CreateRetVoid()
 }
 
+func (c *compiler) defineYieldFunction(fn llvm.Value) {
+	entry := c.context.AddBasicBlock(fn, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	fnType := llvm.FunctionType(c.context.Int32Type(), nil, false)
+	schedYield := c.cFunction("sched_yield", fnType)
+	c.builder.CreateCall(schedYield, nil, "")
+	c.builder.CreateRetVoid()
+}
+
+func (c *compiler) definePutcharFunction(fn llvm.Value) {
+	entry := c.context.AddBasicBlock(fn, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	i32 := c.context.Int32Type()
+	putchar := c.cFunction("putchar", llvm.FunctionType(i32, []llvm.Type{i32}, false))
+	arg := c.builder.CreateZExt(fn.FirstParam(), i32, "")
+	c.builder.CreateCall(putchar, []llvm.Value{arg}, "")
+	c.builder.CreateRetVoid()
+}
+
+// cFunction returns the external function, such as a C library function
+// or an LLVM intrinsic, with the specified name, declaring it with the
+// specified type if the module does not already.
+func (c *compiler) cFunction(name string, fnType llvm.Type) llvm.Value {
+	fn := c.module.NamedFunction(name)
+	if fn.IsNil() {
+		fn = llvm.AddFunction(c.module.Module, name, fnType)
+	}
+	return fn
+}
+
+func (c *compiler) defineWriteFunction(fn llvm.Value) {
+	entry := c.context.AddBasicBlock(fn, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	i8ptr := llvm.PointerType(c.context.Int8Type(), 0)
+	i32 := c.context.Int32Type()
+	fflush := c.cFunction("fflush", llvm.FunctionType(i32, []llvm.Type{i8ptr}, false))
+	c.builder.CreateCall(fflush, []llvm.Value{llvm.ConstNull(i8ptr)}, "")
+
+	fd, p, n := fn.Param(0), fn.Param(1), fn.Param(2)
+	sizeType := n.Type()
+	writeType := llvm.FunctionType(sizeType, []llvm.Type{i32, i8ptr, sizeType}, false)
+	write := c.cFunction("write", writeType)
+	p = c.builder.CreateIntToPtr(p, i8ptr, "")
+	result := c.builder.CreateCall(write, []llvm.Value{fd, p, n}, "")
+	c.builder.CreateRet(result)
+}
+
+func (c *compiler) defineExitFunction(fn llvm.Value) {
+	entry := c.context.AddBasicBlock(fn, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	i32 := c.context.Int32Type()
+	exit := c.cFunction("exit", llvm.FunctionType(c.context.VoidType(), []llvm.Type{i32}, false))
+	c.builder.CreateCall(exit, []llvm.Value{fn.FirstParam()}, "")
+	c.builder.CreateUnreachable()
+}
+
+// defineSyscallFunction defines a function that makes a system call with
+// the C library's syscall function, passing the trap number and arguments
+// through. The function returns (r1, r2, errno); r2 is always zero, and
+// errno is read from the C library when syscall returns -1.
+//
+// Unless raw is true, the system call is bracketed by calls to the
+// runtime's entersyscall and exitsyscall, so that other goroutines may run
+// while it blocks. errno is read before exitsyscall, which may resume the
+// goroutine on another thread.
+func (c *compiler) defineSyscallFunction(fn llvm.Value, raw bool) {
+	entry := c.context.AddBasicBlock(fn, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	params := fn.Params()
+	uintptrType := params[0].Type()
+	syscallType := llvm.FunctionType(uintptrType, []llvm.Type{uintptrType}, true)
+	syscall := c.cFunction("syscall", syscallType)
+	if !raw {
+		entersyscall := c.NamedFunction("runtime.entersyscall", "func f()")
+		c.builder.CreateCall(entersyscall, nil, "")
+	}
+	r1 := c.builder.CreateCall(syscall, params, "")
+
+	i32 := c.context.Int32Type()
+	errnoLocationType := llvm.FunctionType(llvm.PointerType(i32, 0), nil, false)
+	errnoLocation := c.cFunction("__errno_location", errnoLocationType)
+	errno := c.builder.CreateLoad(c.builder.CreateCall(errnoLocation, nil, ""), "")
+	errno = c.builder.CreateZExt(errno, uintptrType, "")
+	failed := c.builder.CreateICmp(llvm.IntEQ, r1, llvm.ConstAllOnes(uintptrType), "")
+	errno = c.builder.CreateSelect(failed, errno, llvm.ConstNull(uintptrType), "")
+	if !raw {
+		exitsyscall := c.NamedFunction("runtime.exitsyscall", "func f()")
+		c.builder.CreateCall(exitsyscall, nil, "")
+	}
+
+	result := llvm.Undef(fn.Type().ElementType().ReturnType())
+	result = c.builder.CreateInsertValue(result, r1, 0, "")
+	result = c.builder.CreateInsertValue(result, llvm.ConstNull(uintptrType), 1, "")
+	result = c.builder.CreateInsertValue(result, errno, 2, "")
+	c.builder.CreateRet(result)
+}
+
 func (c *compiler) defineMemsetFunction(fn llvm.Value) {
-	entry := llvm.AddBasicBlock(fn, "entry")
+	entry := c.context.AddBasicBlock(fn, "entry")
 	c.builder.SetInsertPointAtEnd(entry)
 	dst, fill, size := fn.Param(0), fn.Param(1), fn.Param(2)
 	sizeType := size.Type()
@@ -115,8 +338,8 @@ func (c *compiler) defineMemsetFunction(fn llvm.Value) {
 	dst = c.builder.CreateIntToPtr(dst, pint8, "")
 	args := []llvm.Value{
 		dst, fill, size,
-		llvm.ConstInt(llvm.Int32Type(), 1, false), // single byte alignment
-		llvm.ConstInt(llvm.Int1Type(), 0, false),  // not volatile
+		llvm.ConstInt(c.context.Int32Type(), 1, false), // single byte alignment
+		llvm.ConstInt(c.context.Int1Type(), 0, false),  // not volatile
 	}
 	c.builder.CreateCall(memset, args, "")
 	c.builder.CreateRetVoid()
@@ -24,6 +24,7 @@ package llgo
 
 import (
 	"fmt"
+	"github.com/axw/gollvm/llvm"
 	"github.com/axw/llgo/types"
 	"go/ast"
 	"go/token"
@@ -60,26 +61,81 @@ func (c *compiler) VisitLen(expr *ast.CallExpr) Value {
 		//len_field := c.builder.CreateStructGEP(ptr.LLVMValue(), 1, "")
 		sliceval := value.LLVMValue()
 		lenval := c.builder.CreateExtractValue(sliceval, 1, "") //c.builder.CreateLoad(len_field, "")
-		return c.NewLLVMValue(lenval, types.Int32).Convert(types.Int)
+		return c.NewLLVMValue(lenval, types.Int)
 
 	case *types.Map:
+		// len(nil map) == 0
 		mapval := value.LLVMValue()
-		lenval := c.builder.CreateExtractValue(mapval, 0, "") //c.builder.CreateLoad(len_field, "")
-		return c.NewLLVMValue(lenval, types.Int32).Convert(types.Int)
+		notnull := c.builder.CreateIsNotNull(mapval, "")
+		currBlock := c.builder.GetInsertBlock()
+		endBlock := c.context.InsertBasicBlock(currBlock, "")
+		endBlock.MoveAfter(currBlock)
+		notnullBlock := c.context.InsertBasicBlock(endBlock, "")
+		c.builder.CreateCondBr(notnull, notnullBlock, endBlock)
+		c.builder.SetInsertPointAtEnd(notnullBlock)
+		len_field := c.builder.CreateStructGEP(mapval, 0, "")
+		lenval := c.builder.CreateLoad(len_field, "")
+		c.builder.CreateBr(endBlock)
+		c.builder.SetInsertPointAtEnd(endBlock)
+		result := c.builder.CreatePHI(lenval.Type(), "")
+		result.AddIncoming([]llvm.Value{llvm.ConstNull(lenval.Type()), lenval},
+			[]llvm.BasicBlock{currBlock, notnullBlock})
+		return c.NewLLVMValue(result, types.Int)
 
 	case *types.Array:
 		v := strconv.FormatUint(typ.Len, 10)
 		return c.NewConstValue(token.INT, v)
 
+	case *types.Chan:
+		chanlen := c.NamedFunction("runtime.chanlen", "func f(c uintptr) int")
+		chanval := value.LLVMValue()
+		chanval = c.builder.CreatePtrToInt(chanval, c.target.IntPtrType(), "")
+		lenval := c.builder.CreateCall(chanlen, []llvm.Value{chanval}, "")
+		return c.NewLLVMValue(lenval, types.Int)
+
 	case *types.Basic:
-		if typ == types.String.Underlying {
-			ptr := value.(*LLVMValue).pointer
-			len_field := c.builder.CreateStructGEP(ptr.LLVMValue(), 1, "")
-			len_value := c.builder.CreateLoad(len_field, "")
-			return c.NewLLVMValue(len_value, types.Int32).Convert(types.Int)
+		if typ.Kind == types.StringKind {
+			// The length of a constant string is constant.
+			if value, isconst := value.(ConstValue); isconst {
+				n := len(value.Val.(string))
+				return c.NewConstValue(token.INT, strconv.Itoa(n))
+			}
+			len_value := c.builder.CreateExtractValue(value.LLVMValue(), 1, "")
+			return c.NewLLVMValue(len_value, types.Int)
 		}
 	}
 	panic(fmt.Sprint("Unhandled value type: ", value.Type()))
 }
 
+func (c *compiler) VisitCap(expr *ast.CallExpr) Value {
+	if len(expr.Args) > 1 {
+		panic("Expecting only one argument to cap")
+	}
+
+	value := c.VisitExpr(expr.Args[0])
+	typ := types.Underlying(value.Type())
+	if p, ok := typ.(*types.Pointer); ok {
+		typ = types.Underlying(p.Base)
+	}
+
+	switch typ := typ.(type) {
+	case *types.Array:
+		v := strconv.FormatUint(typ.Len, 10)
+		return c.NewConstValue(token.INT, v)
+
+	case *types.Slice:
+		sliceval := value.LLVMValue()
+		capval := c.builder.CreateExtractValue(sliceval, 2, "")
+		return c.NewLLVMValue(capval, types.Int)
+
+	case *types.Chan:
+		chancap := c.NamedFunction("runtime.chancap", "func f(c uintptr) int")
+		chanval := value.LLVMValue()
+		chanval = c.builder.CreatePtrToInt(chanval, c.target.IntPtrType(), "")
+		capval := c.builder.CreateCall(chancap, []llvm.Value{chanval}, "")
+		return c.NewLLVMValue(capval, types.Int)
+	}
+	panic(fmt.Sprint("Unhandled value type: ", value.Type()))
+}
+
 // vim: set ft=go :
@@ -27,6 +27,8 @@ import (
 	"github.com/axw/gollvm/llvm"
 	"github.com/axw/llgo/types"
 	"go/ast"
+	"go/token"
+	"strconv"
 )
 
 func (c *compiler) VisitBasicLit(lit *ast.BasicLit) Value {
@@ -38,85 +40,103 @@ func (c *compiler) VisitBasicLit(lit *ast.BasicLit) Value {
 }
 
 func (c *compiler) VisitFuncLit(lit *ast.FuncLit) Value {
-	ftyp := c.types.expr[lit].(*types.Func)
-	fn_value := llvm.AddFunction(c.module.Module, "", c.types.ToLLVM(ftyp).ElementType())
-	fn_value.SetFunctionCallConv(llvm.FastCallConv)
-	defer c.builder.SetInsertPointAtEnd(c.builder.GetInsertBlock())
-	f := c.NewLLVMValue(fn_value, ftyp)
-	c.buildFunction(f, ftyp.Params, lit.Body)
-	return f
+	return c.makeClosure(lit)
 }
 
 func (c *compiler) VisitCompositeLit(lit *ast.CompositeLit) Value {
-	typ := c.GetType(lit.Type)
+	// The type of a literal nested within another composite literal may
+	// be elided, in which case the checker records the element type. An
+	// elided &T yields a pointer to the literal.
+	if lit.Type == nil {
+		typ := c.types.expr[lit]
+		if ptrtyp, ok := types.Underlying(typ).(*types.Pointer); ok {
+			value := c.compositeLit(lit, ptrtyp.Base).(*LLVMValue)
+			if value.pointer == nil {
+				mem := c.createTypeMalloc(c.types.ToLLVM(ptrtyp.Base))
+				c.builder.CreateStore(value.LLVMValue(), mem)
+				return c.NewLLVMValue(mem, typ)
+			}
+			return c.NewLLVMValue(value.pointer.LLVMValue(), typ)
+		}
+		return c.compositeLit(lit, typ)
+	}
+	// The length of a [...]T array is determined by the checker.
+	if t, ok := lit.Type.(*ast.ArrayType); ok {
+		if _, ok := t.Len.(*ast.Ellipsis); ok {
+			return c.compositeLit(lit, c.types.expr[lit])
+		}
+	}
+	return c.compositeLit(lit, c.GetType(lit.Type))
+}
+
+func (c *compiler) compositeLit(lit *ast.CompositeLit, typ types.Type) Value {
+	// Map literals insert their elements in order, as the keys need not
+	// be constant.
+	if _, ismap := types.Underlying(typ).(*types.Map); ismap {
+		return c.mapLit(lit, typ)
+	}
+
 	var valuemap map[interface{}]Value
 	var valuelist []Value
 	_, isstruct := types.Underlying(typ).(*types.Struct)
-	if lit.Elts != nil {
-		for _, elt := range lit.Elts {
-			var value Value
-			if kv, iskv := elt.(*ast.KeyValueExpr); iskv {
-				value = c.VisitExpr(kv.Value)
+	index := 0
+	for _, elt := range lit.Elts {
+		if kv, iskv := elt.(*ast.KeyValueExpr); iskv {
+			if isstruct {
 				if valuemap == nil {
 					valuemap = make(map[interface{}]Value)
 				}
-				var key interface{}
-				if isstruct {
-					key = kv.Key.(*ast.Ident).Name
-				} else {
-					key = c.VisitExpr(kv.Key)
-				}
-				valuemap[key] = value
-			} else {
-				value = c.VisitExpr(elt)
-				valuelist = append(valuelist, value)
+				valuemap[kv.Key.(*ast.Ident).Name] = c.VisitExpr(kv.Value)
+				continue
 			}
+			// Array and slice keys are constant indices. Elements
+			// without keys follow on from the preceding element.
+			index = int(c.VisitExpr(kv.Key).(ConstValue).Int64())
+			elt = kv.Value
 		}
-	}
-
-	// For array/slice types, convert key:value to contiguous
-	// values initialiser.
-	switch types.Underlying(typ).(type) {
-	case *types.Array, *types.Slice:
-		if len(valuemap) > 0 {
-			maxi := int64(-1)
-			for key, _ := range valuemap {
-				i := key.(ConstValue).Int64()
-				if i < 0 {
-					panic("array index must be non-negative integer constant")
-				} else if i > maxi {
-					maxi = i
-				}
-			}
-			valuelist = make([]Value, maxi+1)
-			for key, value := range valuemap {
-				i := key.(ConstValue).Int64()
-				valuelist[i] = value
-			}
+		for len(valuelist) <= index {
+			valuelist = append(valuelist, nil)
 		}
+		valuelist[index] = c.VisitExpr(elt)
+		index++
 	}
 
 	origtyp := typ
 	switch typ := types.Underlying(typ).(type) {
 	case *types.Array:
-		typ.Len = uint64(len(valuelist))
+		// The gaps between keyed elements are zero-filled.
 		elttype := typ.Elt
+		llvmelttype := c.types.ToLLVM(elttype)
 		llvm_values := make([]llvm.Value, typ.Len)
-		for i, value := range valuelist {
-			if value == nil {
-				llvm_values[i] = llvm.ConstNull(c.types.ToLLVM(elttype))
+		for i := range llvm_values {
+			if i < len(valuelist) && valuelist[i] != nil {
+				llvm_values[i] = valuelist[i].Convert(elttype).LLVMValue()
+			} else {
+				llvm_values[i] = llvm.ConstNull(llvmelttype)
+			}
+		}
+
+		// Create a constant array, and then insert the non-constant values.
+		constants := make([]llvm.Value, len(llvm_values))
+		for i, value := range llvm_values {
+			if value.IsConstant() {
+				constants[i] = value
 			} else {
-				llvm_values[i] = value.Convert(elttype).LLVMValue()
+				constants[i] = llvm.ConstNull(llvmelttype)
+			}
+		}
+		array := llvm.ConstArray(llvmelttype, constants)
+		for i, value := range llvm_values {
+			if !value.IsConstant() {
+				array = c.builder.CreateInsertValue(array, value, i, "")
 			}
 		}
-		// TODO set non-const values after creating const array.
-		return c.NewLLVMValue(
-			llvm.ConstArray(c.types.ToLLVM(elttype), llvm_values), origtyp)
+		return c.NewLLVMValue(array, origtyp)
 
 	case *types.Slice:
-		ptr := c.builder.CreateMalloc(c.types.ToLLVM(typ), "")
-		length := llvm.ConstInt(llvm.Int32Type(), uint64(len(valuelist)), false)
-		valuesPtr := c.builder.CreateArrayMalloc(c.types.ToLLVM(typ.Elt), length, "")
+		ptr := c.createTypeMalloc(c.types.ToLLVM(typ))
+		length := llvm.ConstInt(c.target.IntPtrType(), uint64(len(valuelist)), false)
+		valuesPtr := c.createArrayMalloc(c.types.ToLLVM(typ.Elt), length)
 		//valuesPtr = c.builder.CreateBitCast(valuesPtr, llvm.PointerType(valuesPtr.Type(), 0), "")
 		// TODO check result of mallocs
 		c.builder.CreateStore(valuesPtr, c.builder.CreateStructGEP(ptr, 0, "")) // data
@@ -124,7 +144,7 @@ func (c *compiler) VisitCompositeLit(lit *ast.CompositeLit) Value {
 		c.builder.CreateStore(length, c.builder.CreateStructGEP(ptr, 2, ""))    // cap
 		null := llvm.ConstNull(c.types.ToLLVM(typ.Elt))
 		for i, value := range valuelist {
-			index := llvm.ConstInt(llvm.Int32Type(), uint64(i), false)
+			index := llvm.ConstInt(c.context.Int32Type(), uint64(i), false)
 			valuePtr := c.builder.CreateGEP(valuesPtr, []llvm.Value{index}, "")
 			if value == nil {
 				c.builder.CreateStore(null, valuePtr)
@@ -137,7 +157,7 @@ func (c *compiler) VisitCompositeLit(lit *ast.CompositeLit) Value {
 
 	case *types.Struct:
 		values := valuelist
-		struct_value := c.builder.CreateMalloc(c.types.ToLLVM(typ), "")
+		struct_value := c.createTypeMalloc(c.types.ToLLVM(typ))
 		if valuemap != nil {
 			for key, value := range valuemap {
 				fieldName := key.(string)
@@ -162,12 +182,23 @@ func (c *compiler) VisitCompositeLit(lit *ast.CompositeLit) Value {
 		m := c.NewLLVMValue(struct_value, &types.Pointer{Base: origtyp})
 		return m.makePointee()
 
-	case *types.Map:
-		value := llvm.ConstNull(c.types.ToLLVM(typ))
-		// TODO initialise map
-		return c.NewLLVMValue(value, origtyp)
 	}
 	panic(fmt.Sprint("Unhandled type kind: ", typ))
 }
 
+// mapLit creates a map with the elements of a map composite literal.
+func (c *compiler) mapLit(lit *ast.CompositeLit, typ types.Type) Value {
+	elttyp := types.Underlying(typ).(*types.Map).Elt
+	n := c.NewConstValue(token.INT, strconv.Itoa(len(lit.Elts)))
+	m := c.makeMap(typ, n)
+	for _, elt := range lit.Elts {
+		kv := elt.(*ast.KeyValueExpr)
+		key := c.VisitExpr(kv.Key)
+		value := c.VisitExpr(kv.Value).Convert(elttyp)
+		ptr, _ := c.mapLookup(m, key, true)
+		c.builder.CreateStore(value.LLVMValue(), ptr.pointer.LLVMValue())
+	}
+	return m
+}
+
 // vim: set ft=go :
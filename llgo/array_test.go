@@ -4,8 +4,11 @@ import (
 	"testing"
 )
 
-func TestArrayRange(t *testing.T) { checkOutputEqual(t, "arrays/range.go") }
-func TestArrayIndex(t *testing.T) { checkOutputEqual(t, "arrays/index.go") }
-func TestArraySlice(t *testing.T) { checkOutputEqual(t, "arrays/slice.go") }
+func TestArrayRange(t *testing.T)     { checkOutputEqual(t, "arrays/range.go") }
+func TestArrayIndex(t *testing.T)     { checkOutputEqual(t, "arrays/index.go") }
+func TestArraySlice(t *testing.T)     { checkOutputEqual(t, "arrays/slice.go") }
+func TestArrayInterface(t *testing.T) { checkOutputEqual(t, "arrays/interface.go") }
+func TestArrayCompare(t *testing.T)   { checkOutputEqual(t, "arrays/compare.go") }
+func TestArrayKeyed(t *testing.T)     { checkOutputEqual(t, "arrays/keyed.go") }
 
 // vim: set ft=go:
@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestAtomic(t *testing.T) {
+	checkOutputEqual(t, "atomic/atomic.go")
+	m, err := compileFiles(testdata("atomic/atomic.go"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Dispose()
+	ir := m.String()
+	for _, s := range []string{"atomicrmw add", "cmpxchg", "load atomic", "store atomic", "seq_cst"} {
+		if !strings.Contains(ir, s) {
+			t.Errorf("missing %q:\n%s", s, ir)
+		}
+	}
+	if strings.Contains(ir, "@atomic.") {
+		t.Errorf("sync/atomic functions are called:\n%s", ir)
+	}
+}
+
+// vim: set ft=go:
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// benchmarkCompile measures the time taken to compile the files, which
+// includes type checking and building the LLVM types in the TypeMap.
+func benchmarkCompile(b *testing.B, files ...string) {
+	files = testdata(files...)
+	for i := 0; i < b.N; i++ {
+		m, err := compileFiles(append([]string(nil), files...), "")
+		if err != nil {
+			b.Fatal(err)
+		}
+		m.Dispose()
+	}
+}
+
+func BenchmarkCompileFib(b *testing.B)        { benchmarkCompile(b, "bench/fib.go") }
+func BenchmarkCompileStructCopy(b *testing.B) { benchmarkCompile(b, "structs/copy.go") }
+func BenchmarkCompileInterfaces(b *testing.B) { benchmarkCompile(b, "interfaces/methods.go") }
+func BenchmarkCompileChannels(b *testing.B)   { benchmarkCompile(b, "chan/range.go") }
+func BenchmarkCompileMath(b *testing.B)       { benchmarkCompile(b, "math/intrinsics.go") }
+
+// benchmarkRun measures the time taken to run the program, built as an
+// executable by llgo or, if gc is true, by gc for comparison. Building the
+// program is not measured.
+func benchmarkRun(b *testing.B, file string, gc bool) {
+	b.StopTimer()
+	dir, err := ioutil.TempDir("", "llgo-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	exe := filepath.Join(dir, "bench")
+	if gc {
+		output, err := exec.Command("go", "build", "-o", exe, testdata(file)[0]).CombinedOutput()
+		if err != nil {
+			b.Fatalf("go build failed: %v\n%s", err, output)
+		}
+	} else {
+		m, err := compileFiles(testdata(file), "")
+		if err != nil {
+			b.Fatal(err)
+		}
+		err = buildExecutable(m, exe)
+		m.Dispose()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.StartTimer()
+	for i := 0; i < b.N; i++ {
+		if output, err := exec.Command(exe).CombinedOutput(); err != nil {
+			b.Fatalf("%v\n%s", err, output)
+		}
+	}
+}
+
+func BenchmarkRunFib(b *testing.B)      { benchmarkRun(b, "bench/fib.go", false) }
+func BenchmarkRunFibGc(b *testing.B)    { benchmarkRun(b, "bench/fib.go", true) }
+func BenchmarkRunMatmul(b *testing.B)   { benchmarkRun(b, "bench/matmul.go", false) }
+func BenchmarkRunMatmulGc(b *testing.B) { benchmarkRun(b, "bench/matmul.go", true) }
+func BenchmarkRunMaps(b *testing.B)     { benchmarkRun(b, "bench/maps.go", false) }
+func BenchmarkRunMapsGc(b *testing.B)   { benchmarkRun(b, "bench/maps.go", true) }
+
+// TestBenchmarkPrograms checks that the benchmark programs produce the
+// same output as with gc.
+func TestBenchmarkPrograms(t *testing.T) {
+	checkOutputEqual(t, "bench/fib.go")
+	checkOutputEqual(t, "bench/matmul.go")
+	checkOutputEqual(t, "bench/maps.go")
+}
+
+// vim: set ft=go:
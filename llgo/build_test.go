@@ -0,0 +1,31 @@
+package main
+
+import (
+	"runtime"
+	"testing"
+)
+
+// TestBuildConstraints checks that files excluded by build constraints are
+// not compiled. "go run" compiles every file it is given, so the expected
+// output is determined here instead.
+func TestBuildConstraints(t *testing.T) {
+	m, err := compileFiles(testdata("buildtags/main.go",
+		"buildtags/platform_linux.go", "buildtags/platform_other.go",
+		"buildtags/ignored.go", "buildtags/tagged.go"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := runFunction(m, "main")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"not linux", "not ignored"}
+	if runtime.GOOS == "linux" {
+		expected[0] = "linux"
+	}
+	if err = checkStringsEqual(output, expected); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// vim: set ft=go:
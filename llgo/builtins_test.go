@@ -4,6 +4,8 @@ import (
 	"testing"
 )
 
-func TestNew(t *testing.T) { checkOutputEqual(t, "new.go") }
+func TestNew(t *testing.T)        { checkOutputEqual(t, "new.go") }
+func TestPrintFloat(t *testing.T) { checkOutputEqual(t, "print/float.go") }
+func TestPrintKinds(t *testing.T) { checkOutputEqual(t, "print/kinds.go") }
 
 // vim: set ft=go:
@@ -0,0 +1,557 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// cTypes maps the C basic types to the names by which Go code refers to
+// them, e.g. C.uint for "unsigned int".
+var cTypes = map[string]string{
+	"char":               "char",
+	"signed char":        "schar",
+	"unsigned char":      "uchar",
+	"short":              "short",
+	"unsigned short":     "ushort",
+	"int":                "int",
+	"unsigned int":       "uint",
+	"long":               "long",
+	"unsigned long":      "ulong",
+	"long long":          "longlong",
+	"unsigned long long": "ulonglong",
+	"float":              "float",
+	"double":             "double",
+}
+
+// cgoPackage holds the state of the translation of a package's references
+// to C.
+type cgoPackage struct {
+	name     string
+	preamble string
+
+	// functions and typedefs map the names declared by the preamble to
+	// their C types, as reported by clang.
+	functions map[string]string
+	typedefs  map[string]string
+
+	// gotypes holds the definitions of the Go types that represent C
+	// types, keyed by name; wrappers holds the C wrapper function for each
+	// C function called from Go, and gofuncs their Go declarations.
+	gotypes  map[string]string
+	wrappers map[string]string
+	gofuncs  map[string]string
+}
+
+// processCgo translates the references to C in the files that import "C".
+// The C preambles of the files are compiled with clang, to find the types
+// of the C functions and types referred to. Each C.f is replaced by a call
+// to a C wrapper function declared with "//extern", and each C.T by a Go
+// type with the same representation; these are declared in a generated
+// file added to files. The C source for the wrappers is returned, to be
+// compiled and linked with the package by linkCgo, or the empty string if
+// no file imports "C".
+func processCgo(fset *token.FileSet, files map[string]*ast.File) (string, error) {
+	var filenames []string
+	for filename := range files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	var cgofiles []*ast.File
+	var preambles []string
+	for _, filename := range filenames {
+		file := files[filename]
+		if preamble, ok := removeImportC(file); ok {
+			cgofiles = append(cgofiles, file)
+			preambles = append(preambles, preamble)
+		}
+	}
+	if len(cgofiles) == 0 {
+		return "", nil
+	}
+
+	p := &cgoPackage{
+		name:     cgofiles[0].Name.Name,
+		preamble: strings.Join(preambles, "\n"),
+		gotypes:  make(map[string]string),
+		wrappers: make(map[string]string),
+		gofuncs:  make(map[string]string),
+	}
+	if err := p.loadDeclarations(); err != nil {
+		return "", err
+	}
+	if _, err := p.goType("char"); err != nil {
+		return "", err
+	}
+	var errs []string
+	for _, file := range cgofiles {
+		rewriteCgoRefs(file, func(sel *ast.SelectorExpr) *ast.Ident {
+			name, err := p.resolve(sel.Sel.Name)
+			if err != nil {
+				pos := fset.Position(sel.Pos())
+				errs = append(errs, fmt.Sprintf("%s: %s", pos, err))
+			}
+			return &ast.Ident{NamePos: sel.Sel.NamePos, Name: name}
+		})
+	}
+	if len(errs) > 0 {
+		return "", errors.New(strings.Join(errs, "\n"))
+	}
+
+	filename := filepath.Join(filepath.Dir(fset.Position(cgofiles[0].Pos()).Filename), "_cgo_gotypes.go")
+	file, err := parser.ParseFile(fset, filename, p.goSource(), parser.ParseComments)
+	if err != nil {
+		return "", err
+	}
+	files[filename] = file
+	return p.cSource(), nil
+}
+
+// removeImportC removes the import of "C" from the file, returning the
+// preamble from the comment preceding it, and whether the file imported
+// "C". References to C in the file are left unresolved.
+func removeImportC(file *ast.File) (string, bool) {
+	for i, decl := range file.Decls {
+		gen, ok := decl.(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		for j, spec := range gen.Specs {
+			spec := spec.(*ast.ImportSpec)
+			if spec.Path.Value != `"C"` {
+				continue
+			}
+			doc := spec.Doc
+			if doc == nil && !gen.Lparen.IsValid() {
+				doc = gen.Doc
+			}
+			gen.Specs = append(gen.Specs[:j], gen.Specs[j+1:]...)
+			if len(gen.Specs) == 0 {
+				file.Decls = append(file.Decls[:i], file.Decls[i+1:]...)
+			}
+			for k, imp := range file.Imports {
+				if imp == spec {
+					file.Imports = append(file.Imports[:k], file.Imports[k+1:]...)
+					break
+				}
+			}
+			unresolved := file.Unresolved[:0]
+			for _, ident := range file.Unresolved {
+				if ident.Name != "C" {
+					unresolved = append(unresolved, ident)
+				}
+			}
+			file.Unresolved = unresolved
+			return doc.Text(), true
+		}
+	}
+	return "", false
+}
+
+var (
+	objectType = reflect.TypeOf((*ast.Object)(nil))
+	scopeType  = reflect.TypeOf((*ast.Scope)(nil))
+	exprType   = reflect.TypeOf((*ast.Expr)(nil)).Elem()
+)
+
+// rewriteCgoRefs replaces each C.name selector in the file with the
+// identifier returned by f, and records the identifier as unresolved, so
+// that it is resolved to the generated declaration.
+func rewriteCgoRefs(file *ast.File, f func(*ast.SelectorExpr) *ast.Ident) {
+	var rewrite func(v reflect.Value)
+	rewrite = func(v reflect.Value) {
+		switch v.Kind() {
+		case reflect.Ptr:
+			if !v.IsNil() && v.Type() != objectType && v.Type() != scopeType {
+				rewrite(v.Elem())
+			}
+		case reflect.Interface:
+			if v.IsNil() {
+				return
+			}
+			if sel, ok := v.Interface().(*ast.SelectorExpr); ok && isCgoRef(sel) && v.Type() == exprType {
+				ident := f(sel)
+				file.Unresolved = append(file.Unresolved, ident)
+				v.Set(reflect.ValueOf(ident))
+				return
+			}
+			rewrite(v.Elem())
+		case reflect.Struct:
+			for i := 0; i < v.NumField(); i++ {
+				rewrite(v.Field(i))
+			}
+		case reflect.Slice:
+			for i := 0; i < v.Len(); i++ {
+				rewrite(v.Index(i))
+			}
+		}
+	}
+	rewrite(reflect.ValueOf(file.Decls))
+}
+
+func isCgoRef(sel *ast.SelectorExpr) bool {
+	x, ok := sel.X.(*ast.Ident)
+	return ok && x.Name == "C" && x.Obj == nil
+}
+
+// declPattern matches the name and type of a top-level declaration in
+// clang's AST dump, e.g.
+//
+//	|-FunctionDecl 0x1d2b4d0 <a.c:1:1, col:40> col:12 used add 'int (int, int)' static
+//	|-TypedefDecl 0x1d2b120 <a.c:3:1, col:23> col:23 size_t '__size_t':'unsigned long'
+//
+// The type is the canonical type, after the colon, if there is one.
+var declPattern = regexp.MustCompile(`^[|` + "`" + `]-(FunctionDecl|TypedefDecl) .* (\w+) '([^']*)'(?::'([^']*)')?`)
+
+// loadDeclarations runs clang on the preamble, recording the functions
+// and typedefs it declares.
+func (p *cgoPackage) loadDeclarations() error {
+	p.functions = make(map[string]string)
+	p.typedefs = make(map[string]string)
+	output, err := runClang(p.preamble, "-fsyntax-only", "-Xclang", "-ast-dump")
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(output), "\n") {
+		match := declPattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		name, typ := match[2], match[3]
+		if match[1] == "FunctionDecl" {
+			p.functions[name] = typ
+		} else {
+			if match[4] != "" {
+				typ = match[4]
+			}
+			p.typedefs[name] = typ
+		}
+	}
+	return nil
+}
+
+// runClang writes the C source to a temporary file and runs clang on it,
+// for the target, with the specified arguments. The output of clang is
+// returned.
+func runClang(source string, args ...string) ([]byte, error) {
+	dir, err := ioutil.TempDir("", "llgo-cgo")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "_cgo_.c")
+	if err = ioutil.WriteFile(filename, []byte(source), 0666); err != nil {
+		return nil, err
+	}
+	args = append([]string{"-target", compiler.GetTargetTriple()}, args...)
+	args = append(args, filename)
+	var stderr bytes.Buffer
+	cmd := exec.Command(*clang, args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("cgo: %s: %v\n%s", *clang, err, stderr.String())
+	}
+	return output, nil
+}
+
+// resolve returns the name of the Go declaration that represents C.name,
+// generating it if necessary.
+func (p *cgoPackage) resolve(name string) (string, error) {
+	switch name {
+	case "CString", "GoString":
+		p.gofuncs["CString"] = fmt.Sprintf(cStringGoSource, p.name)
+		p.wrappers["CString"] = fmt.Sprintf(cStringCSource, p.name)
+		return "_Cfunc_" + name, nil
+	}
+	if typ, ok := p.functions[name]; ok {
+		return "_Cfunc_" + name, p.function(name, typ)
+	}
+	if _, ok := p.typedefs[name]; ok {
+		return p.goType(name)
+	}
+	for ctype, goname := range cTypes {
+		if goname == name {
+			return p.goType(ctype)
+		}
+	}
+	return "_C_" + name, fmt.Errorf("cgo: could not determine kind of name for C.%s", name)
+}
+
+// function generates the C wrapper and Go declaration for the C function
+// with the specified name and type, such as "int (int, int)".
+func (p *cgoPackage) function(name, typ string) error {
+	if _, ok := p.gofuncs[name]; ok {
+		return nil
+	}
+	lparen := strings.Index(typ, "(")
+	rparen := strings.LastIndex(typ, ")")
+	if lparen == -1 || rparen < lparen || strings.Contains(typ[lparen+1:], "(") {
+		return fmt.Errorf("cgo: unsupported type for C.%s: %s", name, typ)
+	}
+	result := strings.TrimSpace(typ[:lparen])
+	var params []string
+	if s := strings.TrimSpace(typ[lparen+1 : rparen]); s != "" && s != "void" {
+		params = strings.Split(s, ", ")
+	}
+
+	wrapper := "_cgo_" + p.name + "_" + name
+	var cparams, cargs, goparams []string
+	for i, param := range params {
+		if param == "..." {
+			return fmt.Errorf("cgo: variadic C function C.%s is not supported", name)
+		}
+		gotyp, err := p.goType(param)
+		if err != nil {
+			return err
+		}
+		if gotyp == "" {
+			return fmt.Errorf("cgo: unsupported type for C.%s: %s", name, typ)
+		}
+		cparams = append(cparams, fmt.Sprintf("%s p%d", param, i))
+		cargs = append(cargs, fmt.Sprintf("p%d", i))
+		goparams = append(goparams, fmt.Sprintf("p%d %s", i, gotyp))
+	}
+	goresult, err := p.goType(result)
+	if err != nil {
+		return err
+	}
+
+	call := fmt.Sprintf("%s(%s)", name, strings.Join(cargs, ", "))
+	if goresult != "" {
+		call = "return " + call
+	}
+	if len(cparams) == 0 {
+		cparams = []string{"void"}
+	}
+	p.wrappers[name] = fmt.Sprintf("%s %s(%s) {\n\t%s;\n}\n",
+		result, wrapper, strings.Join(cparams, ", "), call)
+	p.gofuncs[name] = fmt.Sprintf("//extern %s\nfunc _Cfunc_%s(%s) %s\n",
+		wrapper, name, strings.Join(goparams, ", "), goresult)
+	return nil
+}
+
+// goType returns the Go type that represents the C type, generating its
+// declaration if necessary. The empty string is returned for void.
+func (p *cgoPackage) goType(ctype string) (string, error) {
+	var words []string
+	for _, word := range strings.Fields(strings.Replace(ctype, "*", " * ", -1)) {
+		switch word {
+		case "const", "volatile", "restrict", "__restrict":
+		default:
+			words = append(words, word)
+		}
+	}
+	ctype = strings.Join(words, " ")
+
+	if strings.HasSuffix(ctype, "*") {
+		base := strings.TrimSpace(ctype[:len(ctype)-1])
+		if base == "void" {
+			return "unsafe.Pointer", nil
+		}
+		gotyp, err := p.goType(base)
+		if err != nil {
+			return "", err
+		}
+		return "*" + gotyp, nil
+	}
+	if ctype == "void" {
+		return "", nil
+	}
+
+	name := cTypes[ctype]
+	typedef := name == ""
+	if typedef {
+		if _, ok := p.typedefs[ctype]; !ok {
+			return "", fmt.Errorf("cgo: unsupported C type: %s", ctype)
+		}
+		name = ctype
+	}
+	gotyp := "_Ctype_" + name
+	if _, ok := p.gotypes[gotyp]; ok {
+		return gotyp, nil
+	}
+
+	var underlying string
+	if typedef {
+		p.gotypes[gotyp] = "" // guard against recursion
+		var err error
+		underlying, err = p.goType(p.typedefs[ctype])
+		if err != nil {
+			return "", err
+		}
+		if underlying == "" {
+			return "", fmt.Errorf("cgo: unsupported C type: %s", ctype)
+		}
+	} else {
+		underlying = basicGoType(name)
+	}
+	p.gotypes[gotyp] = underlying
+	return gotyp, nil
+}
+
+// basicGoType returns the Go type with the representation of the C basic
+// type named by Go code as C.name. long and unsigned long are 32 bits on
+// 32-bit targets, and 64 bits otherwise.
+func basicGoType(name string) string {
+	longBits := "64"
+	switch buildContext().GOARCH {
+	case "386", "arm":
+		longBits = "32"
+	}
+	switch name {
+	case "char", "schar":
+		return "int8"
+	case "uchar":
+		return "uint8"
+	case "short":
+		return "int16"
+	case "ushort":
+		return "uint16"
+	case "int":
+		return "int32"
+	case "uint":
+		return "uint32"
+	case "long":
+		return "int" + longBits
+	case "ulong":
+		return "uint" + longBits
+	case "longlong":
+		return "int64"
+	case "ulonglong":
+		return "uint64"
+	case "float":
+		return "float32"
+	}
+	return "float64"
+}
+
+// cStringGoSource and cStringCSource define C.CString and C.GoString,
+// for the package whose name is substituted. CString allocates with the
+// C library's malloc, so the result may be released with C.free.
+const cStringGoSource = `//extern _cgo_%s_malloc
+func _cgo_malloc(n uintptr) unsafe.Pointer
+
+func _Cfunc_CString(s string) *_Ctype_char {
+	p := uintptr(_cgo_malloc(uintptr(len(s) + 1)))
+	for i := 0; i < len(s); i++ {
+		*(*byte)(unsafe.Pointer(p + uintptr(i))) = s[i]
+	}
+	*(*byte)(unsafe.Pointer(p + uintptr(len(s)))) = 0
+	return (*_Ctype_char)(unsafe.Pointer(p))
+}
+
+func _Cfunc_GoString(p *_Ctype_char) string {
+	b := uintptr(unsafe.Pointer(p))
+	n := 0
+	for *(*byte)(unsafe.Pointer(b + uintptr(n))) != 0 {
+		n++
+	}
+	s := make([]byte, n)
+	for i := range s {
+		s[i] = *(*byte)(unsafe.Pointer(b + uintptr(i)))
+	}
+	return string(s)
+}
+`
+
+const cStringCSource = `void *_cgo_%s_malloc(__SIZE_TYPE__ n) {
+	return __builtin_malloc(n);
+}
+`
+
+// goSource returns the source of the generated Go file, declaring the Go
+// types and functions that represent C types and functions.
+func (p *cgoPackage) goSource() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "package %s\n\nimport \"unsafe\"\n\nvar _ unsafe.Pointer\n\n", p.name)
+	for _, name := range sortedKeys(p.gotypes) {
+		fmt.Fprintf(&buf, "type %s %s\n", name, p.gotypes[name])
+	}
+	for _, name := range sortedKeys(p.gofuncs) {
+		fmt.Fprintf(&buf, "\n%s", p.gofuncs[name])
+	}
+	return buf.String()
+}
+
+// cSource returns the C source to be linked with the package: the
+// preamble, followed by the wrapper functions.
+func (p *cgoPackage) cSource() string {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "%s\n", p.preamble)
+	for _, name := range sortedKeys(p.wrappers) {
+		fmt.Fprintf(&buf, "\n%s", p.wrappers[name])
+	}
+	return buf.String()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// linkCgo compiles the C source generated by processCgo to LLVM bitcode
+// with clang, and links it into the module.
+func linkCgo(m *llgo.Module, source string) error {
+	dir, err := ioutil.TempDir("", "llgo-cgo")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	bitcode := filepath.Join(dir, "_cgo_"+m.Name+".bc")
+	if _, err = runClang(source, "-c", "-emit-llvm", "-o", bitcode); err != nil {
+		return err
+	}
+	buf, err := llvm.NewMemoryBufferFromFile(bitcode)
+	if err != nil {
+		return err
+	}
+	cModule, err := llvm.ParseBitcodeInContext(m.Context(), buf)
+	buf.Dispose()
+	if err != nil {
+		return err
+	}
+	return llvm.LinkModules(m.Module, cModule, llvm.LinkerDestroySource)
+}
+
+// vim: set ft=go :
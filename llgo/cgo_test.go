@@ -0,0 +1,17 @@
+package main
+
+import (
+	"os/exec"
+	"testing"
+)
+
+// TestCgo checks that C functions, including static functions defined in
+// the preamble, and C types can be used from a file that imports "C".
+func TestCgo(t *testing.T) {
+	if _, err := exec.LookPath(*clang); err != nil {
+		t.Skip("clang is required for cgo")
+	}
+	checkOutputEqual(t, "cgo/basic.go")
+}
+
+// vim: set ft=go:
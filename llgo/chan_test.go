@@ -0,0 +1,11 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestChanClose(t *testing.T) { checkOutputEqual(t, "chan/close.go") }
+func TestChanRange(t *testing.T) { checkOutputEqual(t, "chan/range.go") }
+func TestChanLen(t *testing.T)   { checkOutputEqual(t, "chan/len.go") }
+
+// vim: set ft=go:
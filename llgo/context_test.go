@@ -0,0 +1,212 @@
+package main
+
+import (
+	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo"
+	"github.com/axw/llgo/types"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+// TestCompilerContext checks that each compiler creates modules in its own
+// LLVM context, and that programs compiled in a new context run correctly.
+func TestCompilerContext(t *testing.T) {
+	m1, err := compileFiles(testdata("fun.go"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m1.Dispose()
+
+	withCompilerOptions(llgo.CompilerOptions{}, func() {
+		m2, err := compileFiles(testdata("fun.go"), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer m2.Dispose()
+		if m1.Context() == m2.Context() {
+			t.Fatal("modules compiled by separate compilers share a context")
+		}
+		checkOutputEqual(t, "fun.go")
+	})
+}
+
+// TestParallelCompile checks that packages may be compiled concurrently by
+// compilers in separate goroutines.
+func TestParallelCompile(t *testing.T) {
+	files := testdata("fun.go", "closures/capture.go",
+		"interfaces/basic.go", "strings/add.go")
+	errors := make(chan error, len(files))
+	for _, file := range files {
+		go func(file string) {
+			errors <- compileAndVerify(llgo.NewCompiler(llgo.CompilerOptions{}), file)
+		}(file)
+	}
+	for _ = range files {
+		if err := <-errors; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// TestCompilerOptions checks that a compiler reports the options with
+// which it was created, and targets the specified triple.
+func TestCompilerOptions(t *testing.T) {
+	opts := llgo.CompilerOptions{TargetTriple: "x86_64-unknown-linux", OptLevel: 2}
+	c := llgo.NewCompiler(opts)
+	if triple := c.GetTargetTriple(); triple != opts.TargetTriple {
+		t.Errorf("expected target triple %q, got %q", opts.TargetTriple, triple)
+	}
+	if level := c.Options().OptLevel; level != opts.OptLevel {
+		t.Errorf("expected optimization level %d, got %d", opts.OptLevel, level)
+	}
+}
+
+// TestModuleTarget checks that compiled modules record the target triple
+// and data layout for which they were compiled, on both the llgo.Module
+// and the LLVM module.
+func TestModuleTarget(t *testing.T) {
+	opts := llgo.CompilerOptions{TargetTriple: "x86_64-unknown-linux"}
+	withCompilerOptions(opts, func() {
+		m, err := compileFiles(testdata("fun.go"), "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer m.Dispose()
+		if m.Triple != opts.TargetTriple {
+			t.Errorf("expected triple %q, got %q", opts.TargetTriple, m.Triple)
+		}
+		if target := m.Target(); target != m.Triple {
+			t.Errorf("expected LLVM module triple %q, got %q", m.Triple, target)
+		}
+		if m.DataLayout == "" {
+			t.Error("expected a data layout")
+		}
+		if layout := m.Module.DataLayout(); layout != m.DataLayout {
+			t.Errorf("expected LLVM module data layout %q, got %q", m.DataLayout, layout)
+		}
+	})
+}
+
+// TestVerifyOption checks that programs exercising closures, defers,
+// interfaces and goroutines compile without errors when each function is
+// verified as it is built.
+func TestVerifyOption(t *testing.T) {
+	withCompilerOptions(llgo.CompilerOptions{Verify: true}, func() {
+		for _, file := range []string{"fun.go", "closures/capture.go", "defer/defer.go",
+			"interfaces/basic.go", "sched/gosched.go", "strings/concat.go"} {
+			m, err := compileFiles(testdata(file), "")
+			if err != nil {
+				t.Errorf("%s: %v", file, err)
+				continue
+			}
+			m.Dispose()
+		}
+	})
+}
+
+// TestSharedCompiler checks that a compiler may be shared by goroutines
+// compiling packages concurrently. The goroutines start together, so their
+// compilations overlap. The modules share the compiler's LLVM context, so
+// they are verified once all of the compilations are done.
+func TestSharedCompiler(t *testing.T) {
+	c := llgo.NewCompiler(llgo.CompilerOptions{})
+	files := testdata("fun.go", "closures/capture.go",
+		"interfaces/basic.go", "strings/add.go")
+	type result struct {
+		m   *llgo.Module
+		err error
+	}
+	start := make(chan bool)
+	results := make(chan result, len(files))
+	for _, file := range files {
+		go func(file string) {
+			<-start
+			m, err := parseAndCompile(c, file)
+			results <- result{m, err}
+		}(file)
+	}
+	close(start)
+	var modules []*llgo.Module
+	for _ = range files {
+		r := <-results
+		if r.err != nil {
+			t.Error(r.err)
+			continue
+		}
+		modules = append(modules, r.m)
+	}
+	for _, m := range modules {
+		if err := llvm.VerifyModule(m.Module, llvm.ReturnStatusAction); err != nil {
+			t.Errorf("%s: %v", m.ImportPath, err)
+		}
+		m.Dispose()
+	}
+}
+
+// TestSharedLLVMTypeMap checks that an LLVM type map may be used by
+// goroutines concurrently, and that they all get the same LLVM type for
+// each Go type.
+func TestSharedLLVMTypeMap(t *testing.T) {
+	ctx := llvm.NewContext()
+	defer ctx.Dispose()
+	target := llvm.NewTargetData("e-p:64:64:64")
+	defer target.Dispose()
+	tm := llgo.NewLLVMTypeMap(ctx, target)
+
+	typs := []types.Type{
+		types.Int,
+		types.String,
+		&types.Slice{Elt: types.Byte},
+		&types.Pointer{Base: types.Uint8},
+		&types.Array{Elt: types.String, Len: 4},
+		&types.Map{Key: types.String, Elt: types.Int},
+	}
+	const n = 8
+	start := make(chan bool)
+	results := make(chan []llvm.Type, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			<-start
+			lts := make([]llvm.Type, len(typs))
+			for j, typ := range typs {
+				lts[j] = tm.ToLLVM(typ)
+			}
+			results <- lts
+		}()
+	}
+	close(start)
+	first := <-results
+	for i := 1; i < n; i++ {
+		lts := <-results
+		for j := range typs {
+			if lts[j] != first[j] {
+				t.Errorf("%v: got distinct LLVM types", typs[j])
+			}
+		}
+	}
+}
+
+// compileAndVerify parses and compiles a file with the specified compiler,
+// and verifies the resulting module.
+func compileAndVerify(c llgo.Compiler, filename string) error {
+	m, err := parseAndCompile(c, filename)
+	if err != nil {
+		return err
+	}
+	defer m.Dispose()
+	return llvm.VerifyModule(m.Module, llvm.ReturnStatusAction)
+}
+
+// parseAndCompile parses and compiles a file with the specified compiler.
+func parseAndCompile(c llgo.Compiler, filename string) (*llgo.Module, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, nil, parser.DeclarationErrors)
+	if err != nil {
+		return nil, err
+	}
+	return c.Compile(fset, []*ast.File{file}, file.Name.Name)
+}
+
+// vim: set ft=go:
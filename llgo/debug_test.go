@@ -0,0 +1,14 @@
+package main
+
+import (
+	"github.com/axw/llgo"
+	"testing"
+)
+
+// TestDebugInfo checks that programs compiled with debug information
+// verify and run correctly.
+func TestDebugInfo(t *testing.T) {
+	withCompilerOptions(llgo.CompilerOptions{GenerateDebug: true}, func() {
+		checkOutputEqual(t, "closures/capture.go")
+	})
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"github.com/axw/llgo"
+	"testing"
+)
+
+func TestUnhandledStatement(t *testing.T)  { checkCompileErrors(t, "errors/unhandled.go", 6, 12) }
+func TestPointerMethodErrors(t *testing.T) { checkCompileErrors(t, "errors/addressof.go", 13, 14) }
+func TestLabelErrors(t *testing.T)         { checkCompileErrors(t, "errors/labels.go", 4, 9, 18, 21) }
+func TestInitLoopErrors(t *testing.T)      { checkCompileErrors(t, "errors/initloop.go", 3, 10) }
+func TestTypeErrors(t *testing.T)          { checkCompileErrors(t, "errors/types.go", 3, 6) }
+func TestSlice3Errors(t *testing.T)        { checkCompileErrors(t, "errors/slice3.go", 5) }
+func TestArrayKeyErrors(t *testing.T)      { checkCompileErrors(t, "errors/arraykeys.go", 4, 5) }
+func TestPrintErrors(t *testing.T)         { checkCompileErrors(t, "errors/print.go", 7, 8) }
+func TestNoMainErrors(t *testing.T)        { checkCompileErrors(t, "errors/nomain.go", 1) }
+func TestExportErrors(t *testing.T)        { checkCompileErrors(t, "errors/export.go", 6, 11) }
+func TestOverflowErrors(t *testing.T) {
+	checkCompileErrors(t, "errors/overflow.go", 5, 6, 8, 10, 11, 14, 19)
+}
+
+// TestDiagnostics checks that compile errors are reported as diagnostics,
+// with their positions.
+func TestDiagnostics(t *testing.T) {
+	if _, err := compileFiles(testdata("errors/labels.go"), ""); err == nil {
+		t.Fatal("expected compile errors")
+	}
+	lines := []int{4, 9, 18, 21}
+	diagnostics := compiler.Diagnostics()
+	if len(diagnostics) != len(lines) {
+		t.Fatalf("expected %d diagnostics, got %d: %v", len(lines), len(diagnostics), diagnostics)
+	}
+	for i, d := range diagnostics {
+		if d.Severity != llgo.Error || d.Pos.Line != lines[i] {
+			t.Errorf("expected error at line %d, got: %v", lines[i], d)
+		}
+	}
+}
+
+// vim: set ft=go:
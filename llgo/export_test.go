@@ -0,0 +1,99 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestExport checks that functions marked with "//export" have C entry
+// points, which initialise the package before calling the function, and
+// whose parameters and results are lowered to the C ABI.
+func TestExport(t *testing.T) {
+	m, err := compileFiles(testdata("export.go"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"define void @Greet()", "define i32 @Add(i32, i32)", "define i64 @Pair(i32)", "define void @Triple({ i64, i64, i64 }*", "sret", "define double @Dot(double, double, double, double)", "define i64 @Length(i64, i64)", "byval"} {
+		if !strings.Contains(m.String(), s) {
+			t.Errorf("missing %q:\n%s", s, m.String())
+		}
+	}
+	output, err := runFunction(m, "Greet")
+	if err == nil {
+		err = checkStringsEqual(output, []string{"hello from Go"})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestExportHeader checks the C header written for libraries built with
+// -buildmode=c-archive or c-shared.
+func TestExportHeader(t *testing.T) {
+	m, err := compileFiles(testdata("export.go"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Dispose()
+	dir, err := ioutil.TempDir("", "llgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "export.h")
+	if err = writeExportHeader(m, filename); err != nil {
+		t.Fatal(err)
+	}
+	header, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"#ifndef EXPORT_H", "extern void Greet(void);", "extern int32_t Add(int32_t p0, int32_t p1);", "Pair(int32_t p0);", "Triple(int64_t p0);"} {
+		if !strings.Contains(string(header), s) {
+			t.Errorf("missing %q:\n%s", s, header)
+		}
+	}
+}
+
+// TestExportABI checks that a C program can call exported functions with
+// aggregate parameters and results, by linking it with the package built
+// as a C archive. The program declares the functions itself, rather than
+// including the generated header, so the ABI is checked independently.
+func TestExportABI(t *testing.T) {
+	if _, err := exec.LookPath(*clang); err != nil {
+		t.Skip("clang is required to build the C program")
+	}
+	m, err := compileFiles(testdata("export.go"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := ioutil.TempDir("", "llgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	archive := filepath.Join(dir, "export.a")
+	if err = buildLibrary(m, archive, false); err != nil {
+		t.Fatal(err)
+	}
+	exe := filepath.Join(dir, "abi")
+	cmd := exec.Command(*clang, "-o", exe, testdata("export_abi.c")[0], archive, "-lpthread")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("%v\n%s", err, output)
+	}
+	output, err := exec.Command(exe).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	err = checkStringsEqual(lines, []string{"3", "1 2", "2 4 6", "11", "12", "36", "5"})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// vim: set ft=go:
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestExtern checks that functions declared with an "//extern" comment
+// call the named C functions, with their results lowered to the C ABI.
+// The program cannot be built with gc, so the expected output is given
+// here.
+func TestExtern(t *testing.T) {
+	m, err := compileFiles(testdata("extern.go"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"declare i64 @strlen(i8*)", "declare i32 @abs(i32)", "declare double @sqrt(double)", "declare i64 @div(i32, i32)", "declare { i64, i64 } @ldiv(i64, i64)"} {
+		if !strings.Contains(m.String(), s) {
+			t.Errorf("missing %q:\n%s", s, m.String())
+		}
+	}
+	output, err := runFunction(m, "main")
+	if err == nil {
+		err = checkStringsEqual(output, []string{"5", "42", "4", "3 1", "-2 -1"})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// vim: set ft=go:
@@ -6,5 +6,10 @@ import (
 
 func TestFunction(t *testing.T)        { checkOutputEqual(t, "fun.go") }
 func TestVarargsFunction(t *testing.T) { checkOutputEqual(t, "varargs.go") }
+func TestVarargsSpread(t *testing.T)   { checkOutputEqual(t, "varargs/spread.go") }
+func TestClosureCapture(t *testing.T)  { checkOutputEqual(t, "closures/capture.go") }
+func TestEscapeAnalysis(t *testing.T)  { checkOutputEqual(t, "escape/escape.go") }
+func TestMethodValues(t *testing.T)    { checkOutputEqual(t, "methods/values.go") }
+func TestMethodAddressOf(t *testing.T) { checkOutputEqual(t, "methods/addressof.go") }
 
 // vim: set ft=go:
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var fuzzCount = flag.Int(
+	"fuzz.count", 0,
+	"Run the specified number of random programs with gc and llgo, reporting those whose output differs")
+
+var fuzzSeed = flag.Int64(
+	"fuzz.seed", 1,
+	"Set the seed of the first random program run with -fuzz.count; each program is seeded with the next")
+
+// TestFuzz generates random programs, made up of integer arithmetic,
+// control flow, and slice and string operations, and compares their
+// output under "go run" with their output under llgo. A program whose
+// output differs is kept, and its seed reported, so that it can be
+// reproduced with -fuzz.count=1 -fuzz.seed=<seed>.
+func TestFuzz(t *testing.T) {
+	if *fuzzCount == 0 {
+		t.Skip("specify the number of programs to run with -fuzz.count")
+	}
+	for i := 0; i < *fuzzCount; i++ {
+		seed := *fuzzSeed + int64(i)
+		dir, err := ioutil.TempDir("", "llgo-fuzz")
+		if err != nil {
+			t.Fatal(err)
+		}
+		file := filepath.Join(dir, "main.go")
+		src := newFuzzer(seed).program()
+		if err = ioutil.WriteFile(file, src, 0666); err != nil {
+			t.Fatal(err)
+		}
+		if err = runAndCheckMain(checkStringsEqual, []string{file}); err != nil {
+			t.Errorf("seed %d, program %s: %v", seed, file, err)
+			continue
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+// fuzzer generates a random program. The program's variables are the ints
+// a, b, c and d, the strings s and t, and the slice xs, of length 8. Every
+// operation is defined for all values of its operands: divisors are
+// non-zero constants, shift counts are small constants, and indices are
+// masked to be in range.
+// Loops have constant bounds, so every program terminates.
+type fuzzer struct {
+	rand  *rand.Rand
+	buf   bytes.Buffer
+	depth int
+}
+
+var fuzzInts = []string{"a", "b", "c", "d"}
+var fuzzStrings = []string{"s", "t"}
+var fuzzLiterals = []string{`""`, `"x"`, `"llgo"`, `"héllo"`, `"0123456789"`}
+
+func newFuzzer(seed int64) *fuzzer {
+	return &fuzzer{rand: rand.New(rand.NewSource(seed))}
+}
+
+func (f *fuzzer) choose(options []string) string {
+	return options[f.rand.Intn(len(options))]
+}
+
+func (f *fuzzer) printf(format string, args ...interface{}) {
+	f.buf.WriteString(strings.Repeat("\t", f.depth))
+	fmt.Fprintf(&f.buf, format, args...)
+	f.buf.WriteByte('\n')
+}
+
+// program returns the source of a random program.
+func (f *fuzzer) program() []byte {
+	f.buf.WriteString("package main\n\nfunc main() {\n")
+	f.depth = 1
+	f.printf("a, b, c, d := %d, %d, %d, %d", f.rand.Intn(100), -f.rand.Intn(100), f.rand.Intn(1000), f.rand.Int63()>>32)
+	f.printf("s, t := %s, %s", f.choose(fuzzLiterals), f.choose(fuzzLiterals))
+	f.printf("xs := make([]int, 8)")
+	for n := 5 + f.rand.Intn(10); n > 0; n-- {
+		f.stmt(3)
+	}
+	f.printf("println(a, b, c, d, s, t, len(s), len(t))")
+	f.printf("println(xs[0], xs[1], xs[2], xs[3], xs[4], xs[5], xs[6], xs[7])")
+	f.buf.WriteString("}\n")
+	return f.buf.Bytes()
+}
+
+// stmt generates a statement, nesting blocks at most depth deep.
+func (f *fuzzer) stmt(depth int) {
+	n := 6
+	if depth > 0 {
+		n = 9
+	}
+	switch f.rand.Intn(n) {
+	case 0, 1:
+		f.printf("%s = %s", f.choose(fuzzInts), f.intExpr(3))
+	case 2:
+		f.printf("xs[(%s)&7] = %s", f.intExpr(2), f.intExpr(2))
+	case 3:
+		s := f.choose(fuzzStrings)
+		f.printf("%s = %s", s, f.stringExpr(2))
+		f.printf("if len(%s) > 32 {", s)
+		f.printf("\t%s = %s[:32]", s, s)
+		f.printf("}")
+	case 4:
+		s := f.choose(fuzzStrings)
+		f.printf("if len(%s) > 0 {", s)
+		f.depth++
+		f.printf("%s = %s[(%s)&63%%len(%s):]", s, s, f.intExpr(1), s)
+		f.printf("println(%s[(%s)&63%%len(%s)])", s, f.intExpr(1), s)
+		f.depth--
+		f.printf("}")
+	case 5:
+		f.printf("println(%s, %s)", f.intExpr(2), f.boolExpr(2))
+	case 6:
+		f.printf("{")
+		f.depth++
+		f.printf("ys := xs[%d:%d]", f.rand.Intn(4), 4+f.rand.Intn(5))
+		f.printf("ys[0] = %s", f.intExpr(1))
+		f.printf("println(len(ys), cap(ys), ys[len(ys)-1])")
+		f.depth--
+		f.printf("}")
+	case 7:
+		f.printf("if %s {", f.boolExpr(2))
+		f.block(depth - 1)
+		if f.rand.Intn(2) == 0 {
+			f.printf("} else {")
+			f.block(depth - 1)
+		}
+		f.printf("}")
+	case 8:
+		v := fmt.Sprintf("i%d", depth)
+		f.printf("for %s := 0; %s < %d; %s++ {", v, v, 1+f.rand.Intn(4), v)
+		f.depth++
+		f.printf("%s += %s", f.choose(fuzzInts), v)
+		f.depth--
+		f.block(depth - 1)
+		f.printf("}")
+	}
+}
+
+func (f *fuzzer) block(depth int) {
+	f.depth++
+	for n := 1 + f.rand.Intn(3); n > 0; n-- {
+		f.stmt(depth)
+	}
+	f.depth--
+}
+
+// intExpr generates an int expression of at most the specified depth.
+func (f *fuzzer) intExpr(depth int) string {
+	if depth == 0 || f.rand.Intn(4) == 0 {
+		switch f.rand.Intn(4) {
+		case 0:
+			return fmt.Sprint(f.rand.Intn(20) - 5)
+		case 1:
+			return fmt.Sprintf("len(%s)", f.choose(fuzzStrings))
+		case 2:
+			return fmt.Sprintf("xs[%d]", f.rand.Intn(8))
+		}
+		return f.choose(fuzzInts)
+	}
+	x, y := f.intExpr(depth-1), f.intExpr(depth-1)
+	switch f.rand.Intn(10) {
+	case 0:
+		return fmt.Sprintf("(%s / %d)", x, 1+f.rand.Intn(9))
+	case 1:
+		return fmt.Sprintf("(%s %% %d)", x, 1+f.rand.Intn(9))
+	case 2:
+		return fmt.Sprintf("(%s << %d)", x, f.rand.Intn(8))
+	case 3:
+		return fmt.Sprintf("(%s >> %d)", x, f.rand.Intn(8))
+	case 4:
+		return fmt.Sprintf("(-(%s))", x)
+	case 5:
+		// The operands of conversions are not constant, as constants
+		// must be representable by the type.
+		return fmt.Sprintf("int(int8(%s + %s))", x, f.choose(fuzzInts))
+	case 6:
+		return fmt.Sprintf("int(uint16(%s + %s))", x, f.choose(fuzzInts))
+	}
+	op := []string{"+", "-", "*", "&", "|", "^", "&^"}[f.rand.Intn(7)]
+	return fmt.Sprintf("(%s %s %s)", x, op, y)
+}
+
+// boolExpr generates a bool expression of at most the specified depth.
+func (f *fuzzer) boolExpr(depth int) string {
+	if depth == 0 || f.rand.Intn(3) == 0 {
+		if f.rand.Intn(3) == 0 {
+			op := []string{"==", "!=", "<", "<=", ">", ">="}[f.rand.Intn(6)]
+			return fmt.Sprintf("(%s %s %s)", f.stringExpr(1), op, f.stringExpr(1))
+		}
+		op := []string{"==", "!=", "<", "<=", ">", ">="}[f.rand.Intn(6)]
+		return fmt.Sprintf("(%s %s %s)", f.intExpr(1), op, f.intExpr(1))
+	}
+	switch f.rand.Intn(3) {
+	case 0:
+		return fmt.Sprintf("!%s", f.boolExpr(depth-1))
+	case 1:
+		return fmt.Sprintf("(%s && %s)", f.boolExpr(depth-1), f.boolExpr(depth-1))
+	}
+	return fmt.Sprintf("(%s || %s)", f.boolExpr(depth-1), f.boolExpr(depth-1))
+}
+
+// stringExpr generates a string expression of at most the specified
+// depth. Strings assigned to variables are truncated to 32 bytes, so
+// that loops cannot make them grow without bound.
+func (f *fuzzer) stringExpr(depth int) string {
+	if depth == 0 || f.rand.Intn(3) == 0 {
+		if f.rand.Intn(2) == 0 {
+			return f.choose(fuzzLiterals)
+		}
+		return f.choose(fuzzStrings)
+	}
+	switch f.rand.Intn(3) {
+	case 0:
+		return fmt.Sprintf("string(byte(%s + %s))", f.intExpr(1), f.choose(fuzzInts))
+	case 1:
+		return fmt.Sprintf("(%s + %s)", f.stringExpr(depth-1), f.choose(fuzzLiterals))
+	}
+	return fmt.Sprintf("(%s + %s)", f.stringExpr(depth-1), f.stringExpr(depth-1))
+}
+
+// vim: set ft=go:
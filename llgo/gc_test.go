@@ -0,0 +1,40 @@
+package main
+
+import (
+	"github.com/axw/llgo"
+	"testing"
+)
+
+func TestGarbageCollection(t *testing.T) { checkOutputEqual(t, "gc/gc.go") }
+
+// TestParallelGC checks that collections stop the world, so that the lists
+// held by goroutines running on other processors survive them.
+func TestParallelGC(t *testing.T) { checkOutputEqual(t, "gc/parallel.go") }
+
+// TestPreciseGC checks that programs compiled with precise garbage
+// collection, where stack roots are found with a shadow stack, run
+// correctly.
+func TestPreciseGC(t *testing.T) {
+	withCompilerOptions(llgo.CompilerOptions{PreciseGC: true}, func() {
+		checkOutputEqual(t, "gc/gc.go")
+	})
+}
+
+// TestPreciseGCGoroutines checks that precise collection scans the shadow
+// stacks of goroutines parked while another goroutine collects.
+func TestPreciseGCGoroutines(t *testing.T) {
+	withCompilerOptions(llgo.CompilerOptions{PreciseGC: true}, func() {
+		checkOutputEqual(t, "gc/goroutines.go")
+	})
+}
+
+// TestPreciseParallelGC checks that precise collection finds the roots of
+// goroutines running on other processors, each of whose threads keeps a
+// shadow stack of its own.
+func TestPreciseParallelGC(t *testing.T) {
+	withCompilerOptions(llgo.CompilerOptions{PreciseGC: true}, func() {
+		checkOutputEqual(t, "gc/parallel.go")
+	})
+}
+
+// vim: set ft=go:
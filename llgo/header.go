@@ -0,0 +1,134 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// headerWriter generates the C declarations of exported functions from
+// their LLVM types. LLVM integer types do not record signedness, so Go's
+// integer types are declared as signed C types of the same width; structs,
+// such as Go strings and slices, are declared with their LLVM layout.
+type headerWriter struct {
+	typedefs bytes.Buffer
+	structs  map[llvm.Type]string
+}
+
+// writeExportHeader writes a C header declaring the functions marked with
+// "//export" in the module, for C programs that use a library built with
+// -buildmode=c-archive or c-shared.
+func writeExportHeader(m *llgo.Module, filename string) error {
+	w := &headerWriter{structs: make(map[llvm.Type]string)}
+	var decls bytes.Buffer
+	for _, name := range m.Exports {
+		// The C function's signature is lowered to the C ABI; the
+		// header declares it with the Go function's, which C compilers
+		// lower in the same way.
+		fntype := m.ExportTypes[name]
+		result, err := w.cType(fntype.ReturnType())
+		if err != nil {
+			return fmt.Errorf("cannot export %s: %v", name, err)
+		}
+		var params []string
+		for i, param := range fntype.ParamTypes() {
+			ctype, err := w.cType(param)
+			if err != nil {
+				return fmt.Errorf("cannot export %s: %v", name, err)
+			}
+			params = append(params, fmt.Sprintf("%s p%d", ctype, i))
+		}
+		if len(params) == 0 {
+			params = []string{"void"}
+		}
+		fmt.Fprintf(&decls, "extern %s %s(%s);\n", result, name, strings.Join(params, ", "))
+	}
+
+	guard := strings.Map(func(r rune) rune {
+		switch {
+		case 'a' <= r && r <= 'z':
+			return r - 'a' + 'A'
+		case 'A' <= r && r <= 'Z', '0' <= r && r <= '9':
+			return r
+		}
+		return '_'
+	}, filepath.Base(filename))
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "/* Generated by llgo from package %s. */\n\n", m.Name)
+	fmt.Fprintf(&buf, "#ifndef %s\n#define %s\n\n#include <stdint.h>\n\n", guard, guard)
+	fmt.Fprintf(&buf, "#ifdef __cplusplus\nextern \"C\" {\n#endif\n\n")
+	if w.typedefs.Len() > 0 {
+		fmt.Fprintf(&buf, "%s\n", w.typedefs.String())
+	}
+	fmt.Fprintf(&buf, "%s\n#ifdef __cplusplus\n}\n#endif\n\n#endif\n", decls.String())
+	return ioutil.WriteFile(filename, buf.Bytes(), 0666)
+}
+
+// cType returns the C type with the representation of the LLVM type,
+// declaring it if it is a struct.
+func (w *headerWriter) cType(t llvm.Type) (string, error) {
+	switch t.TypeKind() {
+	case llvm.VoidTypeKind:
+		return "void", nil
+	case llvm.IntegerTypeKind:
+		switch bits := t.IntTypeWidth(); bits {
+		case 1:
+			return "_Bool", nil
+		case 8, 16, 32, 64:
+			return fmt.Sprintf("int%d_t", bits), nil
+		}
+	case llvm.FloatTypeKind:
+		return "float", nil
+	case llvm.DoubleTypeKind:
+		return "double", nil
+	case llvm.PointerTypeKind:
+		return "void *", nil
+	case llvm.StructTypeKind:
+		if name, ok := w.structs[t]; ok {
+			return name, nil
+		}
+		var fields []string
+		for i, field := range t.StructElementTypes() {
+			ctype, err := w.cType(field)
+			if err != nil {
+				return "", err
+			}
+			fields = append(fields, fmt.Sprintf("\t%s f%d;\n", ctype, i))
+		}
+		name := fmt.Sprintf("llgo_struct%d", len(w.structs))
+		w.structs[t] = name
+		fmt.Fprintf(&w.typedefs, "typedef struct {\n%s} %s;\n", strings.Join(fields, ""), name)
+		return name, nil
+	}
+	return "", errors.New("unsupported parameter or result type")
+}
+
+// vim: set ft=go :
@@ -1,5 +1,5 @@
 /*
-Copyright (c) 2011 Andrew Wilkins <axwalk@gmail.com>
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
 
 Permission is hereby granted, free of charge, to any person obtaining a copy of
 this software and associated documentation files (the "Software"), to deal in
@@ -20,38 +20,31 @@ OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
 SOFTWARE.
 */
 
-#include <alloca.h>
-#include <pthread.h>
-#include <string.h>
-
-struct call_args_t
-{
-    void (*indirect_fn)(void*);
-    void *fn_arg;
-    size_t fn_argsize;
-    volatile int init : 1;
-};
-
-static void* call_gofunction(void *arg)
-{
-    struct call_args_t *call_args = (struct call_args_t*)arg;
-    void(*indirect_fn)(void*) = call_args->indirect_fn;
-    void *fn_arg = alloca(call_args->fn_argsize);
-    memcpy(fn_arg, call_args->fn_arg, call_args->fn_argsize);
-    call_args->init = 1;
-    indirect_fn(fn_arg);
-    return NULL;
+package main
+
+import (
+	"github.com/axw/llgo"
+	"path/filepath"
+	"strings"
+)
+
+// importPaths is the list of directories searched for packages compiled
+// by llgo, as specified with the -I flag.
+type importPaths []string
+
+func (p *importPaths) String() string {
+	return strings.Join(*p, string(filepath.ListSeparator))
+}
+
+func (p *importPaths) Set(dir string) error {
+	*p = append(*p, dir)
+	return nil
 }
 
-void llgo_newgoroutine(void (*indirect_fn)(void*), void *arg, size_t argsize)
-{
-    pthread_t thread;
-    pthread_attr_t attr;
-    struct call_args_t call_args = {indirect_fn, arg, argsize, 0};
-    pthread_attr_init(&attr);
-    pthread_attr_setdetachstate(&attr, PTHREAD_CREATE_DETACHED);
-    pthread_create(&thread, &attr, &call_gofunction, &call_args);
-    while (!call_args.init)
-        /*Do-nothing*/;
+// exportDataFile returns the name of the export data file written
+// alongside the specified output file.
+func exportDataFile(outfile string) string {
+	return outfile[:len(outfile)-len(filepath.Ext(outfile))] + llgo.ExportDataExt
 }
 
+// vim: set ft=go :
@@ -2,12 +2,48 @@ package main
 
 import (
 	"fmt"
+	"strings"
 	"testing"
 )
 
 // Test file-level var declarations.
 func TestVarDecl(t *testing.T) { checkOutputEqual(t, "var.go") }
 
+// Test that package-level variables are initialised in dependency order,
+// and init functions are run in declaration order.
+func TestInitOrder(t *testing.T) {
+	checkOutputEqual(t, "init/order.go", "init/order2.go")
+}
+
+// TestStaticInit checks that package-level variables with constant
+// initialisers are initialised statically, rather than by main.init.
+func TestStaticInit(t *testing.T) {
+	checkOutputEqual(t, "init/static.go")
+	m, err := compileFiles(testdata("init/static.go"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Dispose()
+	ir := m.String()
+	for _, name := range []string{"table", "origin", "points", "greeting", "ratio", "dynamic"} {
+		prefix := "@main." + name + " = "
+		var def string
+		for _, line := range strings.Split(ir, "\n") {
+			if strings.HasPrefix(line, prefix) {
+				def = line
+			}
+		}
+		if def == "" {
+			t.Errorf("missing definition of %s:\n%s", name, ir)
+			continue
+		}
+		static := !strings.Contains(def, "zeroinitializer")
+		if dynamic := name == "dynamic"; static == dynamic {
+			t.Errorf("unexpected initialiser for %s: %s", name, def)
+		}
+	}
+}
+
 func TestInitFunctions(t *testing.T) {
 	// There are two init functions, and their order is unspecified. So we just
 	// want to check that sets {first two lines} for each execution are equal.
@@ -27,4 +63,20 @@ func TestInitFunctions(t *testing.T) {
 	}
 }
 
+// TestMainFunction checks that package main defines a C main function,
+// which initialises the package and calls main.main.
+func TestMainFunction(t *testing.T) {
+	m, err := compileFiles(testdata("init.go", "init2.go"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Dispose()
+	ir := m.String()
+	for _, s := range []string{"define i32 @main(i32", "call void @main.init()", "call void @main.main()"} {
+		if !strings.Contains(ir, s) {
+			t.Errorf("missing %q:\n%s", s, ir)
+		}
+	}
+}
+
 // vim: set ft=go:
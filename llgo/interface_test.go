@@ -1,6 +1,7 @@
 package main
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -20,7 +21,83 @@ func _TestInterfaceToInterfaceConversion(t *testing.T) {
 }
 */
 
-func TestStaticBasicV2I(t *testing.T)   { checkOutputEqual(t, "interfaces/basic.go") }
-func TestInterfaceMethods(t *testing.T) { checkOutputEqual(t, "interfaces/methods.go") }
+func TestStaticBasicV2I(t *testing.T)     { checkOutputEqual(t, "interfaces/basic.go") }
+func TestInterfaceMethods(t *testing.T)   { checkOutputEqual(t, "interfaces/methods.go") }
+func TestInterfaceAssert(t *testing.T)    { checkOutputEqual(t, "interfaces/assert.go") }
+func TestInterfaceFunc(t *testing.T)      { checkOutputEqual(t, "interfaces/func.go") }
+func TestInterfaceChan(t *testing.T)      { checkOutputEqual(t, "interfaces/chan.go") }
+func TestInterfaceDynamic(t *testing.T)   { checkOutputEqual(t, "interfaces/dynamic.go") }
+func TestInterfaceMethodSet(t *testing.T) { checkOutputEqual(t, "interfaces/methodset.go") }
+func TestInterfaceCompare(t *testing.T)   { checkOutputEqual(t, "interfaces/compare.go") }
+func TestInterfaceItab(t *testing.T)      { checkOutputEqual(t, "interfaces/itab.go") }
+func TestInterfaceBoxing(t *testing.T)    { checkOutputEqual(t, "interfaces/boxing.go") }
+func TestInterfaceSignature(t *testing.T) { checkOutputEqual(t, "interfaces/signature.go") }
+
+// TestInterfaceDescriptors checks that type descriptors are created only
+// for the types that need them, and the types they refer to, and that
+// every descriptor declared while compiling is defined.
+func TestInterfaceDescriptors(t *testing.T) {
+	m, err := compileFiles(testdata("interfaces/descriptors.go"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Dispose()
+	ir := m.String()
+	for _, name := range []string{"type.main.Boxed", "type.main.Arg", "type.func(main.Arg) int"} {
+		if !strings.Contains(ir, "@\""+name+"\" = linkonce_odr") && !strings.Contains(ir, "@"+name+" = linkonce_odr") {
+			t.Errorf("missing descriptor for %s:\n%s", name, ir)
+		}
+	}
+	if strings.Contains(ir, "type.main.Unboxed") {
+		t.Errorf("found a descriptor for a type that is never boxed:\n%s", ir)
+	}
+	for _, line := range strings.Split(ir, "\n") {
+		if strings.HasPrefix(line, "@") && strings.Contains(line, "type.") && strings.Contains(line, "external global") {
+			t.Errorf("descriptor declared but not defined: %s", line)
+		}
+	}
+	checkOutputEqual(t, "interfaces/descriptors.go")
+}
+
+// TestInterfaceSymbols checks that type descriptors for package-level types
+// are named after the type, and may be unified with those of other packages.
+func TestInterfaceSymbols(t *testing.T) {
+	m, err := compileFiles(testdata("interfaces/symbols.go"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Dispose()
+	ir := m.String()
+	if !strings.Contains(ir, "@type.main.T = linkonce_odr") {
+		t.Errorf("missing linkonce_odr descriptor for main.T:\n%s", ir)
+	}
+	if strings.Contains(ir, "type.main.L") {
+		t.Errorf("found a named descriptor for a local type:\n%s", ir)
+	}
+	if !strings.Contains(ir, "@\"type.struct { main.y int }\" = linkonce_odr") {
+		t.Errorf("missing descriptor with a qualified field name:\n%s", ir)
+	}
+	checkOutputEqual(t, "interfaces/symbols.go")
+}
+
+// TestInterfaceSymbolsImportPath checks that descriptor names are qualified
+// with the import path of the package, rather than its name, so that
+// packages with the same name do not share descriptors.
+func TestInterfaceSymbolsImportPath(t *testing.T) {
+	m, err := compileFiles(testdata("interfaces/symbols.go"), "a/util")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Dispose()
+	ir := m.String()
+	for _, name := range []string{"type.a/util.T", "type.struct { a/util.y int }"} {
+		if !strings.Contains(ir, "@\""+name+"\" = linkonce_odr") {
+			t.Errorf("missing descriptor %s:\n%s", name, ir)
+		}
+	}
+	if strings.Contains(ir, "type.main.T") {
+		t.Errorf("found a descriptor qualified with the package name:\n%s", ir)
+	}
+}
 
 // vim: set ft=go:
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestIR compiles each program in testdata/ir, and checks its LLVM IR
+// against the FileCheck-style directives in its comments, so that changes
+// to the shape of the generated code, which output comparisons cannot
+// detect, are noticed:
+//
+//	// CHECK: s        s occurs on a line after the previous match
+//	// CHECK-NEXT: s   s occurs on the line after the previous match
+//	// CHECK-NOT: s    s does not occur between the previous match and the
+//	                   next, or the end of the function
+//	// CHECK-LABEL: s  s occurs, and starts the function to which the
+//	                   following directives are confined
+//
+// The IR is normalised first: lines are trimmed, runs of spaces are
+// collapsed, and blank and comment lines are removed.
+func TestIR(t *testing.T) {
+	files, err := filepath.Glob(testdata("ir/*.go")[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no programs in testdata/ir")
+	}
+	for _, file := range files {
+		if err := checkIR(file); err != nil {
+			t.Errorf("%s: %v", file, err)
+		}
+	}
+}
+
+// irDirective is a CHECK directive, and the line of the file on which it
+// appears.
+type irDirective struct {
+	kind, pattern string
+	line          int
+}
+
+// readIRDirectives reads the CHECK directives from the comments of file.
+func readIRDirectives(file string) ([]irDirective, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var directives []irDirective
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if !strings.HasPrefix(text, "// CHECK") {
+			continue
+		}
+		i := strings.Index(text, ":")
+		if i == -1 {
+			return nil, fmt.Errorf("line %d: malformed directive %q", line, text)
+		}
+		kind := text[len("// "):i]
+		switch kind {
+		case "CHECK", "CHECK-NEXT", "CHECK-NOT", "CHECK-LABEL":
+		default:
+			return nil, fmt.Errorf("line %d: unknown directive %s", line, kind)
+		}
+		pattern := strings.TrimSpace(text[i+1:])
+		directives = append(directives, irDirective{kind, pattern, line})
+	}
+	return directives, scanner.Err()
+}
+
+// normaliseIR splits the IR into lines, trimming and collapsing spaces,
+// and removing blank and comment lines.
+func normaliseIR(ir string) []string {
+	var lines []string
+	for _, line := range strings.Split(ir, "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line != "" && line[0] != ';' {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// checkIR compiles the file, and checks its IR against its directives.
+func checkIR(file string) error {
+	directives, err := readIRDirectives(file)
+	if err != nil {
+		return err
+	}
+	m, err := compileFiles([]string{file}, "")
+	if err != nil {
+		return err
+	}
+	defer m.Dispose()
+	lines := normaliseIR(m.String())
+
+	// pos is the index of the line after the previous match, and end that
+	// of the end of the current function, or of the IR.
+	pos, end := 0, len(lines)
+	var nots []irDirective
+	// checkNots checks the pending CHECK-NOT directives against the lines
+	// from pos up to limit.
+	checkNots := func(limit int) error {
+		for _, d := range nots {
+			for i := pos; i < limit; i++ {
+				if strings.Contains(lines[i], d.pattern) {
+					return fmt.Errorf("line %d: %s: %q found: %s", d.line, d.kind, d.pattern, lines[i])
+				}
+			}
+		}
+		nots = nil
+		return nil
+	}
+	for _, d := range directives {
+		switch d.kind {
+		case "CHECK-NOT":
+			nots = append(nots, d)
+			continue
+		case "CHECK-LABEL":
+			end = len(lines)
+		}
+		match := -1
+		switch d.kind {
+		case "CHECK-NEXT":
+			if pos < end && strings.Contains(lines[pos], d.pattern) {
+				match = pos
+			}
+		default:
+			for i := pos; i < end; i++ {
+				if strings.Contains(lines[i], d.pattern) {
+					match = i
+					break
+				}
+			}
+		}
+		if match == -1 {
+			return fmt.Errorf("line %d: %s: %q not found", d.line, d.kind, d.pattern)
+		}
+		if err := checkNots(match); err != nil {
+			return err
+		}
+		pos = match + 1
+		if d.kind == "CHECK-LABEL" {
+			end = pos
+			for end < len(lines) && !strings.HasPrefix(lines[end], "define ") {
+				end++
+			}
+		}
+	}
+	return checkNots(end)
+}
+
+// vim: set ft=go:
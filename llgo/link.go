@@ -0,0 +1,317 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo"
+	"go/build"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// runtimePackage is the import path of the llgo runtime package.
+const runtimePackage = "github.com/axw/llgo/pkg/runtime"
+
+// buildContext returns a build.Context that selects source files for the
+// target's GOOS and GOARCH.
+func buildContext() build.Context {
+	ctx := build.Default
+	ctx.GOOS, ctx.GOARCH = goos(*os_), goarch(*arch)
+	if *target != "" {
+		parts := strings.SplitN(*target, "-", 4)
+		ctx.GOARCH = goarch(parts[0])
+		if len(parts) >= 3 {
+			ctx.GOOS = goos(parts[2])
+		}
+	}
+	return ctx
+}
+
+// goarch converts an architecture name, which may be an LLVM or triple
+// architecture name, to the name used by gc.
+func goarch(a string) string {
+	switch {
+	case a == "x86_64" || a == "x86-64":
+		return "amd64"
+	case a == "x86" || len(a) == 4 && a[0] == 'i' && strings.HasSuffix(a, "86"):
+		return "386"
+	case strings.HasPrefix(a, "arm"):
+		return "arm"
+	}
+	return a
+}
+
+// goos converts an OS name, which may be an LLVM or triple OS name, to
+// the name used by gc.
+func goos(o string) string {
+	switch {
+	case o == "win32" || strings.HasPrefix(o, "mingw"):
+		return "windows"
+	case strings.HasPrefix(o, "darwin") || strings.HasPrefix(o, "macosx"):
+		return "darwin"
+	case strings.HasPrefix(o, "freebsd"):
+		return "freebsd"
+	}
+	return o
+}
+
+// compileRuntime compiles the runtime package from source, selecting files
+// according to the target's build constraints.
+func compileRuntime() (*llgo.Module, error) {
+	ctx := buildContext()
+	pkg, err := ctx.Import(runtimePackage, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, len(pkg.GoFiles))
+	for i, filename := range pkg.GoFiles {
+		files[i] = filepath.Join(pkg.Dir, filename)
+	}
+	return compileFiles(files, "")
+}
+
+// installDir returns the directory into which llgo-dist installs packages
+// compiled for the target.
+func installDir() string {
+	triple := compiler.GetTargetTriple()
+	return filepath.Join(runtime.GOROOT(), "pkg", "llgo", triple)
+}
+
+// loadRuntime returns a module containing the runtime package, in the
+// specified context. The runtime installed by llgo-dist for the target is
+// used if it exists; otherwise the runtime is compiled from source, in the
+// compiler's context.
+func loadRuntime(ctx llvm.Context) (llvm.Module, error) {
+	path := filepath.Join(installDir(), "runtime.a")
+	if buf, err := llvm.NewMemoryBufferFromFile(path); err == nil {
+		defer buf.Dispose()
+		return llvm.ParseBitcodeInContext(ctx, buf)
+	}
+	m, err := compileRuntime()
+	if err != nil {
+		return llvm.Module{}, err
+	}
+	return m.Module, nil
+}
+
+// linkPackages links the bitcode of each package imported by the module
+// into the module. Packages are found in the import path, where each
+// package's bitcode must be alongside its export data with the extension
+// ".bc", or ".a" as installed by llgo-dist; packages that are not found
+// there are assumed to be provided by the runtime.
+func linkPackages(m *llgo.Module) error {
+	for _, path := range m.Imports {
+		filename := llgo.FindPackage(importPath, path)
+		if filename == "" {
+			continue
+		}
+		filename = filename[:len(filename)-len(llgo.ExportDataExt)]
+		if _, err := os.Stat(filename + ".bc"); err == nil {
+			filename += ".bc"
+		} else {
+			filename += ".a"
+		}
+		buf, err := llvm.NewMemoryBufferFromFile(filename)
+		if err != nil {
+			return err
+		}
+		pkgModule, err := llvm.ParseBitcodeInContext(m.Context(), buf)
+		buf.Dispose()
+		if err != nil {
+			return err
+		}
+		err = llvm.LinkModules(m.Module, pkgModule, llvm.LinkerDestroySource)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// installPackage compiles the files as the package with the import path,
+// and writes its export data and bitcode into dir, where they are found by
+// importers with dir in the import path, and by linkPackages.
+func installPackage(files []string, dir, path string) error {
+	m, err := compileFiles(files, path)
+	if err != nil {
+		return err
+	}
+	defer m.Dispose()
+	filename := filepath.Join(dir, filepath.FromSlash(path))
+	if err = os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+		return err
+	}
+	err = ioutil.WriteFile(filename+llgo.ExportDataExt, m.ExportData, 0666)
+	if err != nil {
+		return err
+	}
+	return writeBitcode(m, filename+".bc")
+}
+
+// writeBitcode writes the module's bitcode to the named file.
+func writeBitcode(m *llgo.Module, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return llvm.WriteBitcodeToFile(m.Module, f)
+}
+
+// buildProgram links the module with the packages it imports and the
+// runtime, and builds the output selected by -buildmode: an executable,
+// a C archive or a C shared library. The output is named by -o, or after
+// the first of the specified files.
+func buildProgram(m *llgo.Module, filenames []string) error {
+	outfile := *outputFile
+	if outfile == "-" {
+		outfile = executableName(filenames)
+		switch *buildMode {
+		case "c-archive":
+			outfile += ".a"
+		case "c-shared":
+			outfile += ".so"
+		}
+	}
+	switch *buildMode {
+	case "exe":
+		return buildExecutable(m, outfile)
+	case "c-archive":
+		return buildLibrary(m, outfile, false)
+	case "c-shared":
+		return buildLibrary(m, outfile, true)
+	}
+	return fmt.Errorf("unknown build mode %q", *buildMode)
+}
+
+// linkProgram links the imported packages and the runtime into the module.
+func linkProgram(m *llgo.Module) error {
+	if err := linkPackages(m); err != nil {
+		return err
+	}
+	runtimeModule, err := loadRuntime(m.Context())
+	if err != nil {
+		return err
+	}
+	return llvm.LinkModules(m.Module, runtimeModule, llvm.LinkerDestroySource)
+}
+
+// writeObjectFile generates native code for the module, with the
+// specified relocation model, into a temporary object file, whose name
+// is returned. The caller must remove the file.
+func writeObjectFile(m *llgo.Module, reloc llvm.RelocMode) (string, error) {
+	objfile, err := ioutil.TempFile("", "llgo")
+	if err != nil {
+		return "", err
+	}
+	err = writeNativeCode(m, llvm.ObjectFile, reloc, objfile)
+	objfile.Close()
+	if err != nil {
+		os.Remove(objfile.Name())
+		return "", err
+	}
+	return objfile.Name(), nil
+}
+
+// buildExecutable links the imported packages and the runtime into the
+// module, generates native code for the target, and invokes the system
+// linker to produce an executable. The linker is run as a compiler driver,
+// so that it adds the appropriate crt objects and C library.
+func buildExecutable(m *llgo.Module, outfile string) error {
+	if err := linkProgram(m); err != nil {
+		return err
+	}
+	objfile, err := writeObjectFile(m, relocationModel())
+	if err != nil {
+		return err
+	}
+	defer os.Remove(objfile)
+	args := []string{"-o", outfile, objfile, "-lpthread"}
+	if *pie {
+		args = append([]string{"-pie"}, args...)
+	}
+	return runCommand(*linker, args...)
+}
+
+// buildLibrary builds a library for use by C programs: a shared library
+// if shared is true, or else a static archive. The C main function is
+// omitted, so that the C program provides its own; the functions marked
+// with "//export" are its entry points, and are declared in a C header
+// written alongside the library, with the extension ".h". Shared
+// libraries are always position-independent.
+func buildLibrary(m *llgo.Module, outfile string, shared bool) error {
+	reloc := relocationModel()
+	if shared {
+		if reloc != llvm.RelocDefault && reloc != llvm.RelocPIC {
+			return fmt.Errorf("-buildmode=c-shared requires -relocation-model=pic, not %q", *relocModel)
+		}
+		reloc = llvm.RelocPIC
+	}
+	if err := linkProgram(m); err != nil {
+		return err
+	}
+	if m.Name == "main" {
+		m.NamedFunction("main").EraseFromParentAsFunction()
+	}
+	objfile, err := writeObjectFile(m, reloc)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(objfile)
+	if shared {
+		err = runCommand(*linker, "-shared", "-o", outfile, objfile, "-lpthread")
+	} else {
+		// ar adds to an existing archive, rather than replacing it.
+		os.Remove(outfile)
+		err = runCommand(*archiver, "rcs", outfile, objfile)
+	}
+	if err != nil {
+		return err
+	}
+	header := outfile[:len(outfile)-len(filepath.Ext(outfile))] + ".h"
+	return writeExportHeader(m, header)
+}
+
+// runCommand runs the specified program, with its output going to the
+// driver's.
+func runCommand(name string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// executableName returns the default name for an executable built from
+// the specified files: the name of the first file, without its extension.
+func executableName(filenames []string) string {
+	name := filepath.Base(filenames[0])
+	return name[:len(name)-len(filepath.Ext(name))]
+}
+
+// vim: set ft=go :
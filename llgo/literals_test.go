@@ -7,3 +7,4 @@ import (
 func TestLiteralSlice(t *testing.T)   { checkOutputEqual(t, "literals/slice.go") }
 func TestLiteralStruct(t *testing.T)  { checkOutputEqual(t, "literals/struct.go") }
 func TestLiteralFuncton(t *testing.T) { checkOutputEqual(t, "literals/func.go") }
+func TestLiteralNested(t *testing.T)  { checkOutputEqual(t, "literals/nested.go") }
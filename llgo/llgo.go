@@ -33,12 +33,15 @@ import (
 	"fmt"
 	"github.com/axw/gollvm/llvm"
 	"github.com/axw/llgo"
-	"github.com/axw/llgo/types"
 	"go/ast"
 	"go/parser"
 	"go/scanner"
 	"go/token"
+	"io"
+	"io/ioutil"
+	"log"
 	"os"
+	"path/filepath"
 	"runtime"
 	"sort"
 	"strings"
@@ -56,27 +59,87 @@ var trace = flag.Bool(
 	"trace", false,
 	"Trace the compilation process")
 
+var debug = flag.Bool(
+	"g", false,
+	"Generate DWARF debug information")
+
+var noBoundsCheck = flag.Bool(
+	"B", false,
+	"Disable bounds checking")
+
+var preciseGC = flag.Bool(
+	"precise-gc", false,
+	"Register pointers on the stack with the garbage collector")
+
+var verify = flag.Bool(
+	"verify", false,
+	"Verify the generated LLVM IR of each function, to localise compiler bugs")
+
+var optLevel = flag.Int(
+	"O", 0,
+	"Set the optimization level, from 0 to 3; -O2 is short for -O=2")
+
+var inlineThreshold = flag.Int(
+	"inline-threshold", 0,
+	"Set the cost below which functions are inlined when optimizing")
+
+var interactive = flag.Bool(
+	"i", false,
+	"Read Go statements, expressions and declarations from stdin, and run them with the JIT compiler")
+
 var version = flag.Bool(
 	"version", false,
 	"Display version information and exit")
 
 var os_ = flag.String("os", runtime.GOOS, "Set the target OS")
 var arch = flag.String("arch", runtime.GOARCH, "Set the target architecture")
+var target = flag.String("target", "", "Set the target triple, overriding -os and -arch")
+var mcpu = flag.String("mcpu", "", "Set the target CPU")
+var mattr = flag.String("mattr", "", "Set the target features, e.g. +sse4.1,-avx")
 var printTriple = flag.Bool("print-triple", false, "Print out target triple and exit")
 var compileOnly = flag.Bool("c", false, "Compile only, don't link")
+var emitLLVM = flag.Bool("emit-llvm", false, "Emit LLVM bitcode, or LLVM IR with -S")
+var emitAssembly = flag.Bool("S", false, "Emit native assembly, or LLVM IR with -emit-llvm")
 var outputFile = flag.String("o", "-", "Output filename")
+var linker = flag.String("linker", "cc", "Set the program used to link executables")
+var archiver = flag.String("ar", "ar", "Set the program used to create archives for -buildmode=c-archive")
+var relocModel = flag.String("relocation-model", "default", "Set the relocation model: default, static, pic or dynamic-no-pic")
+var pie = flag.Bool("pie", false, "Link a position-independent executable with \"llgo build\"; implies -relocation-model=pic")
+var buildMode = flag.String("buildmode", "exe", "Set the output of \"llgo build\": exe, c-archive or c-shared")
+var clang = flag.String("clang", "clang", "Set the clang used to compile the C preambles of cgo files")
+var pkgPath = flag.String("p", "", "Set the import path of the package being compiled; defaults to the package name")
+var importPath importPaths
+
+func init() {
+	flag.Var(&importPath, "I", "Add a directory to the package search path")
+}
 
 var exitCode = 0
-var compiler = llgo.NewCompiler()
+
+// compiler is created in main with the options given on the command line.
+var compiler llgo.Compiler
 
 func report(err error) {
 	scanner.PrintError(os.Stderr, err)
 	exitCode = 2
 }
 
+// reportDiagnostics prints the diagnostics from the most recent
+// compilation, setting a non-zero exit status if any are errors.
+func reportDiagnostics() {
+	for _, d := range compiler.Diagnostics() {
+		fmt.Fprintln(os.Stderr, d)
+		if d.Severity == llgo.Error {
+			exitCode = 2
+		}
+	}
+}
+
 func parseFile(fset *token.FileSet, filename string) *ast.File {
 	// parse entire file
-	mode := parser.DeclarationErrors
+	// Comments are parsed for "#llgo" and "//extern" directives, and for
+	// the C preambles of files that import "C".
+	mode := parser.DeclarationErrors | parser.ParseComments
 	//if *allErrors {
 	//    mode |= parser.SpuriousErrors
 	//}
@@ -111,59 +174,187 @@ func isGoFilename(filename string) bool {
 		strings.HasSuffix(filename, ".go")
 }
 
-func compileFiles(filenames []string) (*llgo.Module, error) {
-	i := 0
+// compileFiles compiles the specified files as a package with the import
+// path, or if it is empty, with the package's name as its import path.
+// Files excluded by build constraints for the target, "//go:build" or
+// "// +build" lines or _GOOS/_GOARCH filename suffixes, are skipped, as
+// they are by go/build.
+func compileFiles(filenames []string, importpath string) (*llgo.Module, error) {
+	ctx := buildContext()
+	i, excluded := 0, 0
 	for _, filename := range filenames {
-		switch _, err := os.Stat(filename); {
+		if _, err := os.Stat(filename); err != nil {
+			report(err)
+			continue
+		}
+		dir, name := filepath.Split(filename)
+		switch match, err := ctx.MatchFile(dir, name); {
 		case err != nil:
 			report(err)
+		case !match:
+			excluded++
 		default:
 			filenames[i] = filename
 			i++
 		}
 	}
 	if i == 0 {
+		if excluded > 0 {
+			return nil, errors.New("Build constraints exclude all Go source files")
+		}
 		return nil, errors.New("No Go source files were specified")
 	}
 	fset := token.NewFileSet()
-	return compilePackage(fset, parseFiles(fset, filenames[0:i]))
-}
-
-func compilePackage(fset *token.FileSet, files map[string]*ast.File) (*llgo.Module, error) {
-	// make a package (resolve all identifiers)
-	pkg, err := ast.NewPackage(fset, files, types.GcImport, types.Universe)
+	files := parseFiles(fset, filenames[0:i])
+	csource, err := processCgo(fset, files)
 	if err != nil {
-		report(err)
 		return nil, err
 	}
-
-	exprTypes, err := types.Check(fset, pkg)
-	if err != nil {
-		report(err)
-		return nil, err
+	m, err := compilePackage(fset, files, importpath)
+	if err == nil && csource != "" {
+		if err = linkCgo(m, csource); err != nil {
+			m.Dispose()
+			return nil, err
+		}
 	}
+	return m, err
+}
 
+func compilePackage(fset *token.FileSet, files map[string]*ast.File, importpath string) (*llgo.Module, error) {
 	if *dumpast {
-		ast.Fprint(os.Stderr, fset, pkg, nil)
+		ast.Fprint(os.Stderr, fset, files, nil)
 		os.Exit(0)
 	}
 
-	return compiler.Compile(fset, pkg, exprTypes)
+	var pkgfiles []*ast.File
+	for _, file := range files {
+		pkgfiles = append(pkgfiles, file)
+	}
+	if len(pkgfiles) == 0 {
+		return nil, errors.New("No Go source files could be parsed")
+	}
+	if importpath == "" {
+		importpath = pkgfiles[0].Name.Name
+	}
+	return compiler.Compile(fset, pkgfiles, importpath)
 }
 
-func writeObjectFile(m *llgo.Module) error {
+// writeOutputFile writes the compiled module to the output file, in the
+// format selected by the -emit-llvm, -S and -c flags: LLVM IR (-emit-llvm
+// -S), native assembly (-S), an object file (-c), or otherwise LLVM
+// bitcode. Unless the module is for package main, the package's export
+// data is written alongside the output file, for use by importers.
+func writeOutputFile(m *llgo.Module) (err error) {
 	var outfile *os.File
 	switch *outputFile {
 	case "-":
 		outfile = os.Stdout
 	default:
-		var err error
 		outfile, err = os.Create(*outputFile)
 		if err != nil {
 			return err
 		}
+		defer func() {
+			if cerr := outfile.Close(); err == nil {
+				err = cerr
+			}
+		}()
+		if m.Name != "main" {
+			err = ioutil.WriteFile(exportDataFile(*outputFile), m.ExportData, 0666)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	switch {
+	case *emitLLVM && *emitAssembly:
+		_, err = io.WriteString(outfile, m.String())
+	case *emitLLVM:
+		err = llvm.WriteBitcodeToFile(m.Module, outfile)
+	case *emitAssembly:
+		err = writeNativeCode(m, llvm.AssemblyFile, relocationModel(), outfile)
+	case *compileOnly:
+		err = writeNativeCode(m, llvm.ObjectFile, relocationModel(), outfile)
+	default:
+		err = llvm.WriteBitcodeToFile(m.Module, outfile)
+	}
+	return err
+}
+
+// writeNativeCode generates native assembly or object code for the
+// module's target, with the specified relocation model, and writes it to w.
+func writeNativeCode(m *llgo.Module, filetype llvm.CodeGenFileType, reloc llvm.RelocMode, w io.Writer) error {
+	triple := m.Triple
+	llvmtarget, err := llvm.GetTargetFromTriple(triple)
+	if err != nil {
+		return err
+	}
+	machine := llvmtarget.CreateTargetMachine(triple, *mcpu, *mattr,
+		llvm.CodeGenLevelDefault,
+		reloc,
+		llvm.CodeModelDefault)
+	defer machine.Dispose()
+	buf, err := machine.EmitToMemoryBuffer(m.Module, filetype)
+	if err != nil {
+		return err
+	}
+	defer buf.Dispose()
+	_, err = w.Write(buf.Bytes())
+	return err
+}
+
+// relocationModels maps the names accepted by -relocation-model to LLVM's
+// relocation models.
+var relocationModels = map[string]llvm.RelocMode{
+	"default":        llvm.RelocDefault,
+	"static":         llvm.RelocStatic,
+	"pic":            llvm.RelocPIC,
+	"dynamic-no-pic": llvm.RelocDynamicNoPic,
+}
+
+// checkRelocationModel checks that the relocation model selected by the
+// -relocation-model and -pie flags is valid.
+func checkRelocationModel() error {
+	if _, ok := relocationModels[*relocModel]; !ok {
+		return fmt.Errorf("unknown relocation model %q", *relocModel)
+	}
+	if *pie && *relocModel != "default" && *relocModel != "pic" {
+		return fmt.Errorf("-pie requires -relocation-model=pic, not %q", *relocModel)
 	}
-	return llvm.WriteBitcodeToFile(m.Module, outfile)
+	return nil
+}
+
+// relocationModel returns the relocation model with which native code is
+// generated.
+func relocationModel() llvm.RelocMode {
+	if *pie {
+		return llvm.RelocPIC
+	}
+	return relocationModels[*relocModel]
+}
+
+// compilerOptions returns the compiler options selected by the command
+// line flags.
+func compilerOptions() llgo.CompilerOptions {
+	opts := llgo.CompilerOptions{
+		TargetArch:      *arch,
+		TargetOs:        *os_,
+		TargetTriple:    *target,
+		TargetCPU:       *mcpu,
+		TargetFeatures:  *mattr,
+		OptLevel:        *optLevel,
+		InlineThreshold: *inlineThreshold,
+		GenerateDebug:   *debug,
+		NoBoundsCheck:   *noBoundsCheck,
+		PreciseGC:       *preciseGC,
+		Verify:          *verify,
+		ImportPaths:     importPath,
+	}
+	if *trace {
+		opts.Logger = log.New(os.Stderr, "", 0)
+	}
+	return opts
 }
 
 func displayVersion() {
@@ -202,33 +393,90 @@ func main() {
 	llvm.InitializeAllTargets()
 	llvm.InitializeAllTargetMCs()
 	llvm.InitializeAllTargetInfos()
+
+	// "llgo build [flags] files" links an executable, "llgo run [flags]
+	// files [args]" runs the program with the JIT compiler, and "llgo test
+	// [flags] [dir] [args]" runs the tests of the package in dir.
+	var build, run, test bool
+	if len(os.Args) > 1 {
+		build, run, test = os.Args[1] == "build", os.Args[1] == "run", os.Args[1] == "test"
+		if build || run || test {
+			os.Args = append(os.Args[:1], os.Args[2:]...)
+		}
+	}
+	// Accept -O0 to -O3, as C compilers do.
+	for i, arg := range os.Args {
+		if len(arg) == 3 && strings.HasPrefix(arg, "-O") {
+			os.Args[i] = "-O=" + arg[2:]
+		}
+	}
 	flag.Parse()
 	if *version {
 		displayVersion()
 	}
+	if err := checkRelocationModel(); err != nil {
+		report(err)
+		os.Exit(exitCode)
+	}
 
-	compiler.SetTraceEnabled(*trace)
-	compiler.SetTargetArch(*arch)
-	compiler.SetTargetOs(*os_)
+	compiler = llgo.NewCompiler(compilerOptions())
 	if *printTriple {
 		displayTriple()
 	}
 
-	module, err := compileFiles(flag.Args())
+	// Packages installed by llgo-dist for the target, such as os, are
+	// searched after those in the directories given with -I.
+	importPath = append(importPath, installDir())
+	compiler = llgo.NewCompiler(compilerOptions())
+
+	if *interactive {
+		runREPL(os.Stdin, os.Stdout)
+		os.Exit(exitCode)
+	}
+
+	if test {
+		dir, testargs := splitTestArgs(flag.Args())
+		status, err := testPackage(dir, testargs)
+		if err != nil {
+			report(err)
+		} else {
+			exitCode = status
+		}
+		os.Exit(exitCode)
+	}
+
+	filenames, progargs := flag.Args(), []string(nil)
+	if run {
+		filenames, progargs = splitRunArgs(filenames)
+	}
+	module, err := compileFiles(filenames, *pkgPath)
+	reportDiagnostics()
 	if err == nil {
 		defer module.Dispose()
 		if exitCode == 0 {
 			if *dump {
 				module.Dump()
+			} else if build {
+				err := buildProgram(module, filenames)
+				if err != nil {
+					report(err)
+				}
+			} else if run {
+				status, err := runProgram(module, filenames, progargs)
+				if err != nil {
+					report(err)
+				} else {
+					exitCode = status
+				}
 			} else {
-				err := writeObjectFile(module)
+				err := writeOutputFile(module)
 				if err != nil {
-					fmt.Println(err)
+					report(err)
 				}
 			}
 		}
-	} else {
-		//fmt.Printf("llg.Compile(%v) failed: %v", file.Name, err)
+	} else if exitCode == 0 {
+		// The error was not reported as a diagnostic.
 		report(err)
 	}
 	os.Exit(exitCode)
@@ -3,3 +3,5 @@ package main
 import "testing"
 
 func TestLoopBranching(t *testing.T) { checkOutputEqual(t, "for/branch.go") }
+func TestLoopLabels(t *testing.T)    { checkOutputEqual(t, "for/labels.go") }
+func TestLoopLocals(t *testing.T)    { checkOutputEqual(t, "for/locals.go") }
@@ -4,9 +4,12 @@ import (
 	"testing"
 )
 
-func TestMapRange(t *testing.T) { checkOutputEqualUnordered(t, "maps/range.go") }
+func TestMapRange(t *testing.T)       { checkOutputEqualUnordered(t, "maps/range.go") }
+func TestMapRangeDelete(t *testing.T) { checkOutputEqual(t, "maps/rangedelete.go") }
 
-//func TestMapLiteral(t *testing.T) { checkOutputEqual(t, "maps/literal.go") }
-func TestMapInsert(t *testing.T) { checkOutputEqual(t, "maps/insert.go") }
-func TestMapDelete(t *testing.T) { checkOutputEqual(t, "maps/delete.go") }
-func TestMapLookup(t *testing.T) { checkOutputEqual(t, "maps/lookup.go") }
+func TestMapLiteral(t *testing.T) { checkOutputEqual(t, "maps/literal.go") }
+func TestMapInsert(t *testing.T)  { checkOutputEqual(t, "maps/insert.go") }
+func TestMapDelete(t *testing.T)  { checkOutputEqual(t, "maps/delete.go") }
+func TestMapLookup(t *testing.T)  { checkOutputEqual(t, "maps/lookup.go") }
+func TestMapGrowth(t *testing.T)  { checkOutputEqual(t, "maps/growth.go") }
+func TestMapKeys(t *testing.T)    { checkOutputEqual(t, "maps/keys.go") }
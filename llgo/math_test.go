@@ -0,0 +1,26 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMathIntrinsics(t *testing.T) {
+	checkOutputEqual(t, "math/intrinsics.go")
+	m, err := compileFiles(testdata("math/intrinsics.go"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Dispose()
+	for _, name := range []string{"sqrt", "fabs", "floor", "ceil", "trunc", "copysign", "fma"} {
+		s := "call double @llvm." + name + ".f64("
+		if !strings.Contains(m.String(), s) {
+			t.Errorf("missing %q:\n%s", s, m.String())
+		}
+	}
+	if strings.Contains(m.String(), "@math.") {
+		t.Errorf("math functions are called:\n%s", m.String())
+	}
+}
+
+// vim: set ft=go:
@@ -4,4 +4,5 @@ import (
 	"testing"
 )
 
-func TestNilComparison(t *testing.T) { checkOutputEqual(t, "nil.go") }
+func TestNilComparison(t *testing.T)    { checkOutputEqual(t, "nil.go") }
+func TestNilPointerMethod(t *testing.T) { checkOutputEqual(t, "nilptr.go") }
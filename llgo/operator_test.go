@@ -5,3 +5,5 @@ import (
 )
 
 func TestOperators(t *testing.T) { checkOutputEqual(t, "operators.go") }
+func TestDivide(t *testing.T)    { checkOutputEqual(t, "divide.go") }
+func TestShift(t *testing.T)     { checkOutputEqual(t, "shift.go") }
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"github.com/axw/llgo"
+	"testing"
+)
+
+// TestOptimization checks that programs compiled with optimization
+// verify and run correctly.
+func TestOptimization(t *testing.T) {
+	for _, level := range []int{1, 2, 3} {
+		withCompilerOptions(llgo.CompilerOptions{OptLevel: level}, func() {
+			checkOutputEqual(t, "closures/capture.go")
+			checkOutputEqual(t, "for/locals.go")
+		})
+	}
+}
+
+// vim: set ft=go:
@@ -0,0 +1,10 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestOsArgsEnviron(t *testing.T) { checkOutputEqual(t, "os/env.go") }
+func TestOsWrite(t *testing.T)       { checkOutputEqual(t, "os/write.go") }
+
+// vim: set ft=go:
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestPanicTrace checks that an unrecovered panic prints the panic value
+// and a stack trace, and exits with status 2. The panic would exit the
+// test, so the program is built as an executable and run; each frame is
+// given the line of its function's declaration.
+func TestPanicTrace(t *testing.T) {
+	dir, err := ioutil.TempDir("", "llgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	exe := filepath.Join(dir, "trace")
+	buildPanicExecutable(t, "panic/trace.go", exe)
+	output := runPanicExecutable(t, exe)
+	expected := []string{
+		"start",
+		"panic: boom",
+		"",
+		"goroutine 1 [running]:",
+		"main.f(...)", "\ttrace.go:7 +0x",
+		"main.f(...)", "\ttrace.go:7 +0x",
+		"main.f(...)", "\ttrace.go:7 +0x",
+		"main.main(...)", "\ttrace.go:14 +0x",
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) != len(expected) {
+		t.Fatalf("expected %d lines, got:\n%s", len(expected), output)
+	}
+	for i, line := range lines {
+		// Frames are printed with the full path of the file, and the
+		// offset of the return address.
+		if strings.HasPrefix(expected[i], "\t") {
+			line = "\t" + filepath.Base(line)
+			line = line[:strings.Index(line, "+0x")+3]
+		}
+		if line != expected[i] {
+			t.Errorf("line %d: expected %q, got %q", i+1, expected[i], line)
+		}
+	}
+}
+
+// TestPanicDefers checks that a panic makes the pending deferred calls of
+// each function on the stack, innermost first, before printing the panic,
+// and that a panic in a deferred call is printed after the one it
+// interrupted.
+func TestPanicDefers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "llgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	exe := filepath.Join(dir, "defer")
+	buildPanicExecutable(t, "panic/defer.go", exe)
+	output := runPanicExecutable(t, exe)
+	expected := []string{
+		"deferred f",
+		"deferred main",
+		"deferred closure 2",
+		"panic: boom",
+		"\tpanic: again",
+		"",
+		"goroutine 1 [running]:",
+	}
+	lines := strings.Split(string(output), "\n")
+	if len(lines) < len(expected) {
+		t.Fatalf("expected at least %d lines, got:\n%s", len(expected), output)
+	}
+	for i, line := range expected {
+		if lines[i] != line {
+			t.Errorf("line %d: expected %q, got %q", i+1, line, lines[i])
+		}
+	}
+}
+
+// TestRuntimeErrors checks that the runtime's checks panic with the same
+// errors as gc's, by comparing the panic message of each case of the test
+// program with that of the program built by gc.
+func TestRuntimeErrors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "llgo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	exe := filepath.Join(dir, "errors")
+	buildPanicExecutable(t, "panic/errors.go", exe)
+	gcexe := filepath.Join(dir, "errors.gc")
+	gcoutput, err := exec.Command("go", "build", "-o", gcexe, testdata("panic/errors.go")[0]).CombinedOutput()
+	if err != nil {
+		t.Fatalf("go build failed: %v\n%s", err, gcoutput)
+	}
+
+	cases := []string{
+		"index", "negindex", "strindex",
+		"slicecap", "slicearray", "slicestring", "slicelow", "slicelowhigh",
+		"slice3cap", "slice3high", "slice3low",
+		"nilmap", "nilptr", "divide",
+		"assert", "assertiface", "assertnil",
+		"closenil", "closeclosed", "sendclosed",
+	}
+	for _, name := range cases {
+		output := runPanicExecutable(t, exe, name)
+		// gc writes the panic message to standard error.
+		var stderr bytes.Buffer
+		cmd := exec.Command(gcexe, name)
+		cmd.Stderr = &stderr
+		cmd.Run()
+		expected := panicMessage(stderr.Bytes())
+		if message := panicMessage(output); message != expected {
+			t.Errorf("%s: expected %q, got %q", name, expected, message)
+		}
+	}
+}
+
+// buildPanicExecutable compiles the test program and builds it as an
+// executable, for programs that panic, which would exit the test if run
+// in the test process.
+func buildPanicExecutable(t *testing.T, file, exe string) {
+	m, err := compileFiles(testdata(file), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = buildExecutable(m, exe); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// runPanicExecutable runs the executable, checking that it exits with
+// status 2, and returns its standard output.
+func runPanicExecutable(t *testing.T, exe string, args ...string) []byte {
+	output, err := exec.Command(exe, args...).Output()
+	if status := exitStatus(err); status != 2 {
+		t.Fatalf("expected exit status 2, got: %v", err)
+	}
+	return output
+}
+
+// exitStatus returns the exit status of a command that failed with err,
+// or -1 if it did not exit. The wait statuses of Unix and Windows both
+// have an ExitStatus method.
+func exitStatus(err error) int {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		if ws, ok := exitErr.Sys().(interface {
+			ExitStatus() int
+		}); ok {
+			return ws.ExitStatus()
+		}
+	}
+	return -1
+}
+
+// panicMessage returns the first line of the output beginning with
+// "panic: ".
+func panicMessage(output []byte) string {
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.HasPrefix(line, "panic: ") {
+			return line
+		}
+	}
+	return ""
+}
+
+// vim: set ft=go:
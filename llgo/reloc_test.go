@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"github.com/axw/gollvm/llvm"
+	"strings"
+	"testing"
+)
+
+func TestRelocationModelFlags(t *testing.T) {
+	defer func(model string, p bool) { *relocModel, *pie = model, p }(*relocModel, *pie)
+	for _, test := range []struct {
+		model string
+		pie   bool
+		reloc llvm.RelocMode
+		ok    bool
+	}{
+		{"default", false, llvm.RelocDefault, true},
+		{"static", false, llvm.RelocStatic, true},
+		{"pic", false, llvm.RelocPIC, true},
+		{"dynamic-no-pic", false, llvm.RelocDynamicNoPic, true},
+		{"default", true, llvm.RelocPIC, true},
+		{"static", true, 0, false},
+		{"pie", false, 0, false},
+	} {
+		*relocModel, *pie = test.model, test.pie
+		err := checkRelocationModel()
+		if (err == nil) != test.ok {
+			t.Errorf("-relocation-model=%s -pie=%v: unexpected error: %v", test.model, test.pie, err)
+		} else if err == nil && relocationModel() != test.reloc {
+			t.Errorf("-relocation-model=%s -pie=%v: got %v, expected %v", test.model, test.pie, relocationModel(), test.reloc)
+		}
+	}
+}
+
+// TestRelocationModelCodegen checks that position-independent code calls
+// functions defined in other modules through the PLT.
+func TestRelocationModelCodegen(t *testing.T) {
+	m, err := compileFiles(testdata("export.go"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Dispose()
+	var static, pic bytes.Buffer
+	if err = writeNativeCode(m, llvm.AssemblyFile, llvm.RelocStatic, &static); err != nil {
+		t.Fatal(err)
+	}
+	if err = writeNativeCode(m, llvm.AssemblyFile, llvm.RelocPIC, &pic); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(static.String(), "@PLT") {
+		t.Errorf("static code uses the PLT:\n%s", static.String())
+	}
+	if !strings.Contains(pic.String(), "runtime.init@PLT") {
+		t.Errorf("position-independent code does not call runtime.init through the PLT:\n%s", pic.String())
+	}
+}
+
+// vim: set ft=go:
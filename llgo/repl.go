@@ -0,0 +1,217 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"io"
+	"os"
+	"strings"
+)
+
+// replFilename is the name of the synthetic source file compiled by the
+// REPL.
+const replFilename = "<repl>"
+
+// repl holds the state of an interactive session: the imports and
+// package-level declarations entered so far, and the statements, which
+// make up the body of the synthetic main function.
+//
+// Each input is compiled into a new module, together with everything
+// entered before it, and run with the JIT compiler. Variables therefore
+// persist between inputs by re-running the statements that assign them.
+// Expression statements, such as calls to fmt.Println, are run only once,
+// so that their output is not repeated.
+type repl struct {
+	imports []string
+	decls   []string
+	stmts   []string
+}
+
+// source returns the synthetic program made up of the session so far,
+// followed by the statements in extra.
+func (r *repl) source(extra ...string) string {
+	var buf []string
+	buf = append(buf, "package main")
+	for _, path := range r.imports {
+		buf = append(buf, "import "+path)
+	}
+	buf = append(buf, r.decls...)
+	buf = append(buf, "func main() {")
+	buf = append(buf, r.stmts...)
+	buf = append(buf, extra...)
+	buf = append(buf, "}")
+	return strings.Join(buf, "\n") + "\n"
+}
+
+// eval compiles and runs the input, and updates the session with it if
+// it compiles. An expression is evaluated and its value printed; if it
+// has no value, it is run as a statement instead.
+func (r *repl) eval(input string) error {
+	input = strings.TrimSpace(input)
+	switch {
+	case input == "":
+		return nil
+	case hasKeyword(input, "import"):
+		path := strings.TrimSpace(input[len("import"):])
+		r.imports = append(r.imports, path)
+		if err := r.run(r.source()); err != nil {
+			r.imports = r.imports[:len(r.imports)-1]
+			return err
+		}
+		return nil
+	case isDeclaration(input):
+		r.decls = append(r.decls, input)
+		if err := r.run(r.source()); err != nil {
+			r.decls = r.decls[:len(r.decls)-1]
+			return err
+		}
+		return nil
+	}
+
+	if expr, err := parser.ParseExpr(input); err == nil {
+		if _, ok := expr.(*ast.CallExpr); !ok {
+			return r.run(r.source("println(" + input + ")"))
+		}
+		// A call may or may not have a value.
+		if r.run(r.source("println("+input+")")) == nil {
+			return nil
+		}
+		return r.run(r.source(input))
+	}
+	if err := r.run(r.source(input)); err != nil {
+		return err
+	}
+	r.stmts = append(r.stmts, input)
+	return nil
+}
+
+// isDeclaration reports whether the input is a package-level declaration.
+// Variable declarations are run as statements.
+func isDeclaration(input string) bool {
+	for _, keyword := range []string{"func", "type", "const"} {
+		if hasKeyword(input, keyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasKeyword reports whether the input begins with the keyword.
+func hasKeyword(input, keyword string) bool {
+	rest := strings.TrimPrefix(input, keyword)
+	return len(rest) < len(input) && (rest == "" || strings.ContainsAny(rest[:1], " \t("))
+}
+
+// run compiles the synthetic program and runs it with the JIT compiler.
+// Compilation errors are returned, without the positions at which they
+// occurred, which refer to the synthetic program rather than the input.
+func (r *repl) run(src string) error {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, replFilename, src, parser.DeclarationErrors)
+	if err != nil {
+		return stripPositions(err)
+	}
+	m, err := compiler.Compile(fset, []*ast.File{file}, "main")
+	if err != nil {
+		return stripPositions(err)
+	}
+	defer m.Dispose()
+	_, err = runMain(m, []string{"llgo"})
+	return err
+}
+
+// stripPositions removes the positions from a list of errors.
+func stripPositions(err error) error {
+	list, ok := err.(scanner.ErrorList)
+	if !ok {
+		return err
+	}
+	msgs := make([]string, len(list))
+	for i, e := range list {
+		msgs[i] = e.Msg
+	}
+	return fmt.Errorf("%s", strings.Join(msgs, "\n"))
+}
+
+// incomplete reports whether the input is incomplete: whether it has
+// unclosed parentheses, brackets or braces, as when a block is still open.
+func incomplete(input string) bool {
+	var s scanner.Scanner
+	fset := token.NewFileSet()
+	file := fset.AddFile("", fset.Base(), len(input))
+	s.Init(file, []byte(input), nil, 0)
+	depth := 0
+	for {
+		_, tok, _ := s.Scan()
+		switch tok {
+		case token.EOF:
+			return depth > 0
+		case token.LPAREN, token.LBRACK, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACK, token.RBRACE:
+			depth--
+		}
+	}
+}
+
+// runREPL reads inputs from in, evaluating each, until the end of the
+// input. An input continues over several lines while it is incomplete.
+// A program that calls os.Exit, or panics, exits the driver.
+func runREPL(in io.Reader, out io.Writer) {
+	var r repl
+	reader := bufio.NewReader(in)
+	var input string
+	for {
+		if input == "" {
+			fmt.Fprint(out, "llgo> ")
+		} else {
+			fmt.Fprint(out, "..... ")
+		}
+		line, err := reader.ReadString('\n')
+		input += line
+		if err != nil {
+			if input != "" {
+				if err := r.eval(input); err != nil {
+					fmt.Fprintln(os.Stderr, err)
+				}
+			}
+			fmt.Fprintln(out)
+			return
+		}
+		if incomplete(input) {
+			continue
+		}
+		if err := r.eval(input); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		input = ""
+	}
+}
+
+// vim: set ft=go :
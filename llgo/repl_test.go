@@ -0,0 +1,51 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestREPLIncomplete(t *testing.T) {
+	tests := []struct {
+		input      string
+		incomplete bool
+	}{
+		{"x := 1", false},
+		{"for i := 0; i < 3; i++ {", true},
+		{"for i := 0; i < 3; i++ {\nprintln(i)\n}", false},
+		{"f(1,", true},
+		{"s := []int{1, 2}", false},
+	}
+	for _, test := range tests {
+		if incomplete(test.input) != test.incomplete {
+			t.Errorf("incomplete(%q) != %v", test.input, test.incomplete)
+		}
+	}
+}
+
+func TestREPLSource(t *testing.T) {
+	r := repl{
+		imports: []string{`"fmt"`},
+		decls:   []string{"func f() int { return 1 }"},
+		stmts:   []string{"x := f()"},
+	}
+	expected := "package main\nimport \"fmt\"\nfunc f() int { return 1 }\n" +
+		"func main() {\nx := f()\nprintln(x)\n}\n"
+	if src := r.source("println(x)"); src != expected {
+		t.Errorf("source: %q (actual) != %q (expected)", src, expected)
+	}
+}
+
+func TestREPLDeclaration(t *testing.T) {
+	for _, input := range []string{"func f() {}", "type T int", "const(c = 1)"} {
+		if !isDeclaration(input) {
+			t.Errorf("%q is not a declaration", input)
+		}
+	}
+	for _, input := range []string{"functions := 1", "var x int", "f()"} {
+		if isDeclaration(input) {
+			t.Errorf("%q is a declaration", input)
+		}
+	}
+}
+
+// vim: set ft=go:
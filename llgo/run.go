@@ -0,0 +1,113 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo"
+	"os"
+	"unsafe"
+)
+
+// splitRunArgs splits the arguments of "llgo run" into the leading Go
+// source files, which make up the program, and the arguments passed to
+// the program, as "go run" does.
+func splitRunArgs(args []string) (files, progargs []string) {
+	n := 0
+	for n < len(args) && isGoFilename(args[n]) {
+		n++
+	}
+	return args[:n], args[n:]
+}
+
+// cstrings returns a NULL-terminated array of pointers to NUL-terminated
+// copies of the strings, as passed to a C main function.
+func cstrings(strs []string) []*byte {
+	array := make([]*byte, len(strs)+1)
+	for i, s := range strs {
+		array[i] = &append([]byte(s), 0)[0]
+	}
+	return array
+}
+
+// runProgram links the module with the packages it imports and the
+// runtime, and runs it with LLVM's JIT compiler, without generating an
+// executable. The program's C main function is called with the program
+// name, taken from the first of the specified files, followed by args, and
+// with the driver's environment; its result, the program's exit status, is
+// returned. A program that calls os.Exit exits the driver.
+func runProgram(m *llgo.Module, filenames, args []string) (int, error) {
+	return runMain(m, append([]string{executableName(filenames)}, args...))
+}
+
+// runMain links the module with the packages it imports and the runtime,
+// and calls its C main function with the JIT compiler, passing argv and
+// the driver's environment. Output buffered by the C library, such as that
+// of print and println, is flushed when main returns, as the driver does
+// not exit through the C library.
+func runMain(m *llgo.Module, argv []string) (int, error) {
+	if err := linkProgram(m); err != nil {
+		return 0, err
+	}
+	i8ptr := llvm.PointerType(m.Context().Int8Type(), 0)
+	fflush := m.NamedFunction("fflush")
+	if fflush.IsNil() {
+		fntype := llvm.FunctionType(m.Context().Int32Type(), []llvm.Type{i8ptr}, false)
+		fflush = llvm.AddFunction(m.Module, "fflush", fntype)
+	}
+	if err := llvm.VerifyModule(m.Module, llvm.ReturnStatusAction); err != nil {
+		return 0, err
+	}
+
+	llvm.LinkInJIT()
+	engine, err := llvm.NewJITCompiler(m.Module, *optLevel)
+	if err != nil {
+		return 0, err
+	}
+	defer engine.Dispose()
+	// The engine owns the module until it is removed, and the caller
+	// disposes of the module.
+	defer engine.RemoveModule(m.Module)
+	mainfn := engine.FindFunction("main")
+	if mainfn.IsNil() {
+		return 0, fmt.Errorf("%s: no main function", m.Name)
+	}
+
+	cargv := cstrings(argv)
+	envp := cstrings(os.Environ())
+	i32 := m.Context().Int32Type()
+	execargs := []llvm.GenericValue{
+		llvm.NewGenericValueFromInt(i32, uint64(len(argv)), false),
+		llvm.NewGenericValueFromPointer(unsafe.Pointer(&cargv[0])),
+		llvm.NewGenericValueFromPointer(unsafe.Pointer(&envp[0])),
+	}
+	engine.RunStaticConstructors()
+	result := engine.RunFunction(mainfn, execargs)
+	engine.RunStaticDestructors()
+	nullptr := llvm.NewGenericValueFromPointer(unsafe.Pointer(uintptr(0)))
+	engine.RunFunction(fflush, []llvm.GenericValue{nullptr})
+	return int(int32(result.Int(true))), nil
+}
+
+// vim: set ft=go :
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitRunArgs(t *testing.T) {
+	tests := []struct {
+		args, files, progargs []string
+	}{
+		{[]string{"a.go"}, []string{"a.go"}, []string{}},
+		{[]string{"a.go", "b.go", "x", "y.go"}, []string{"a.go", "b.go"}, []string{"x", "y.go"}},
+		{[]string{"-v", "a.go"}, []string{}, []string{"-v", "a.go"}},
+	}
+	for _, test := range tests {
+		files, progargs := splitRunArgs(test.args)
+		if !reflect.DeepEqual(files, test.files) || !reflect.DeepEqual(progargs, test.progargs) {
+			t.Errorf("splitRunArgs(%q) = %q, %q; expected %q, %q",
+				test.args, files, progargs, test.files, test.progargs)
+		}
+	}
+}
+
+// vim: set ft=go:
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSchedSteal(t *testing.T)    { checkOutputEqual(t, "sched/steal.go") }
+func TestSchedPingPong(t *testing.T) { checkOutputEqual(t, "sched/pingpong.go") }
+func TestSchedGosched(t *testing.T)  { checkOutputEqual(t, "sched/gosched.go") }
+func TestSchedSyscall(t *testing.T)  { checkOutputEqual(t, "sched/syscall.go") }
+
+// TestNetpoll checks that goroutines waiting on the network poller are
+// readied when their descriptors become ready, or are unblocked. The
+// program calls into the runtime, so cannot be built with gc, and the
+// expected output is given here.
+func TestNetpoll(t *testing.T) {
+	m, err := compileFiles(testdata("sched/netpoll.go"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := runFunction(m, "main")
+	if err == nil {
+		err = checkStringsEqual(output, []string{"0", "5 hello true", "closing"})
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// vim: set ft=go:
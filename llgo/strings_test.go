@@ -1,6 +1,7 @@
 package main
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -9,3 +10,24 @@ func TestStringComparison(t *testing.T)    { checkOutputEqual(t, "strings/compar
 func TestStringIndex(t *testing.T)         { checkOutputEqual(t, "strings/index.go") }
 func TestStringSlice(t *testing.T)         { checkOutputEqual(t, "strings/slice.go") }
 func TestStringBytes(t *testing.T)         { checkOutputEqual(t, "strings/bytes.go") }
+func TestStringRange(t *testing.T)         { checkOutputEqual(t, "strings/range.go") }
+func TestStringRunes(t *testing.T)         { checkOutputEqual(t, "strings/runes.go") }
+func TestStringLen(t *testing.T)           { checkOutputEqual(t, "strings/len.go") }
+func TestStringConcatChain(t *testing.T)   { checkOutputEqual(t, "strings/concat.go") }
+
+// TestStringConstFolding checks that operations on constant strings are
+// evaluated at compile time, rather than by calls to the runtime.
+func TestStringConstFolding(t *testing.T) {
+	m, err := compileFiles(testdata("strings/constfold.go"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Dispose()
+	ir := m.String()
+	for _, fn := range []string{"runtime.strcat", "runtime.strcmp"} {
+		if strings.Contains(ir, fn) {
+			t.Errorf("found a call to %s:\n%s", fn, ir)
+		}
+	}
+	checkOutputEqual(t, "strings/constfold.go")
+}
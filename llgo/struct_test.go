@@ -1,8 +1,29 @@
 package main
 
 import (
+	"strings"
 	"testing"
 )
 
-func TestCircularType(t *testing.T)   { checkOutputEqual(t, "circulartype.go") }
-func TestEmbeddedStruct(t *testing.T) { checkOutputEqual(t, "structs/embed.go") }
+func TestCircularType(t *testing.T)     { checkOutputEqual(t, "circulartype.go") }
+func TestEmbeddedStruct(t *testing.T)   { checkOutputEqual(t, "structs/embed.go") }
+func TestStructInterface(t *testing.T)  { checkOutputEqual(t, "structs/interface.go") }
+func TestStructCompare(t *testing.T)    { checkOutputEqual(t, "structs/compare.go") }
+func TestStructCopy(t *testing.T)       { checkOutputEqual(t, "structs/copy.go") }
+func TestStructLocalTypes(t *testing.T) { checkOutputEqual(t, "structs/localtypes.go") }
+
+// TestStructCopyIntrinsics checks that large values are copied and zeroed
+// with the llvm.memcpy and llvm.memset intrinsics.
+func TestStructCopyIntrinsics(t *testing.T) {
+	m, err := compileFiles(testdata("structs/copy.go"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Dispose()
+	ir := m.String()
+	for _, fn := range []string{"@llvm.memcpy.", "@llvm.memset."} {
+		if !strings.Contains(ir, fn) {
+			t.Errorf("expected a call to %s:\n%s", fn, ir)
+		}
+	}
+}
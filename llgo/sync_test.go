@@ -0,0 +1,11 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSyncMutex(t *testing.T)   { checkOutputEqual(t, "sync/mutex.go") }
+func TestSyncRWMutex(t *testing.T) { checkOutputEqual(t, "sync/rwmutex.go") }
+func TestSyncOnce(t *testing.T)    { checkOutputEqual(t, "sync/once.go") }
+
+// vim: set ft=go:
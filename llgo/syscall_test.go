@@ -0,0 +1,10 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSyscallFileIO(t *testing.T)  { checkOutputEqual(t, "syscall/fileio.go") }
+func TestSyscallProcess(t *testing.T) { checkOutputEqual(t, "syscall/process.go") }
+
+// vim: set ft=go:
@@ -0,0 +1,199 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/axw/llgo"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"unicode"
+	"unicode/utf8"
+)
+
+// splitTestArgs splits the arguments of "llgo test" into the directory of
+// the package to test, "." unless one is given first, and the arguments
+// passed to the test program, such as -test.v.
+func splitTestArgs(args []string) (dir string, testargs []string) {
+	if len(args) > 0 && len(args[0]) > 0 && args[0][0] != '-' {
+		return args[0], args[1:]
+	}
+	return ".", args
+}
+
+// testPackage compiles the package in the directory together with its
+// _test.go files, and runs its tests with the JIT compiler, passing args
+// to the test program. Test files in the package are compiled with it;
+// those in the package's "_test" package are compiled separately, and
+// import it. The test program generated to run the tests is linked with
+// both, and with llgo's testing package; its exit status is returned.
+func testPackage(dir string, args []string) (int, error) {
+	ctx := buildContext()
+	pkg, err := ctx.ImportDir(dir, 0)
+	if err != nil {
+		return 0, err
+	}
+	if pkg.Name == "main" {
+		return 0, errors.New("cannot test package main")
+	}
+	if len(pkg.TestGoFiles) == 0 && len(pkg.XTestGoFiles) == 0 {
+		fmt.Fprintf(os.Stderr, "?\t%s\t[no test files]\n", pkg.ImportPath)
+		return 0, nil
+	}
+	path := pkg.ImportPath
+	if build.IsLocalImport(path) {
+		path = pkg.Name
+	}
+
+	// The packages are installed into a temporary directory, which is
+	// added to the import path, for the test program to import.
+	tmpdir, err := ioutil.TempDir("", "llgo-test")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(tmpdir)
+	importPath = append(importPath, tmpdir)
+	compiler = llgo.NewCompiler(compilerOptions())
+
+	files := joinDir(pkg.Dir, pkg.GoFiles, pkg.CgoFiles, pkg.TestGoFiles)
+	if err := installPackage(files, tmpdir, path); err != nil {
+		return 0, err
+	}
+	tests, err := findTests(joinDir(pkg.Dir, pkg.TestGoFiles))
+	if err != nil {
+		return 0, err
+	}
+	var xtests []string
+	if len(pkg.XTestGoFiles) > 0 {
+		files := joinDir(pkg.Dir, pkg.XTestGoFiles)
+		if err := installPackage(files, tmpdir, path+"_test"); err != nil {
+			return 0, err
+		}
+		if xtests, err = findTests(files); err != nil {
+			return 0, err
+		}
+	}
+
+	fset := token.NewFileSet()
+	src := testMainSource(path, tests, xtests)
+	file, err := parser.ParseFile(fset, "_testmain.go", src, 0)
+	if err != nil {
+		return 0, err
+	}
+	m, err := compiler.Compile(fset, []*ast.File{file}, "main")
+	if err != nil {
+		return 0, err
+	}
+	defer m.Dispose()
+	return runMain(m, append([]string{pkg.Name + ".test"}, args...))
+}
+
+// joinDir returns the names of the files in each list, joined to dir.
+func joinDir(dir string, lists ...[]string) []string {
+	var files []string
+	for _, list := range lists {
+		for _, filename := range list {
+			files = append(files, filepath.Join(dir, filename))
+		}
+	}
+	return files
+}
+
+// findTests returns the names of the test functions in the files: the
+// functions named TestXxx, where Xxx does not start with a lower case
+// letter, that take a *testing.T, in the order in which they appear.
+func findTests(files []string) ([]string, error) {
+	var tests []string
+	fset := token.NewFileSet()
+	for _, filename := range files {
+		file, err := parser.ParseFile(fset, filename, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		for _, decl := range file.Decls {
+			if f, ok := decl.(*ast.FuncDecl); ok && isTest(f) {
+				tests = append(tests, f.Name.Name)
+			}
+		}
+	}
+	return tests, nil
+}
+
+// isTest reports whether the function declaration is a test function.
+func isTest(f *ast.FuncDecl) bool {
+	name := f.Name.Name
+	if f.Recv != nil || len(name) < len("Test") || name[:len("Test")] != "Test" {
+		return false
+	}
+	if r, _ := utf8.DecodeRuneInString(name[len("Test"):]); unicode.IsLower(r) {
+		return false
+	}
+	params := f.Type.Params.List
+	if f.Type.Results != nil || len(params) != 1 || len(params[0].Names) > 1 {
+		return false
+	}
+	ptr, ok := params[0].Type.(*ast.StarExpr)
+	if !ok {
+		return false
+	}
+	sel, ok := ptr.X.(*ast.SelectorExpr)
+	return ok && sel.Sel.Name == "T"
+}
+
+// testMainSource returns the source of the program that runs the tests:
+// those named in tests, of the package with the import path, and those
+// named in xtests, of its "_test" package.
+func testMainSource(path string, tests, xtests []string) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintln(&buf, "package main")
+	fmt.Fprintln(&buf, "import \"testing\"")
+	if len(tests) > 0 {
+		fmt.Fprintf(&buf, "import _test %q\n", path)
+	} else {
+		fmt.Fprintf(&buf, "import _ %q\n", path)
+	}
+	if len(xtests) > 0 {
+		fmt.Fprintf(&buf, "import _xtest %q\n", path+"_test")
+	}
+	fmt.Fprintln(&buf, "var tests = []testing.InternalTest{")
+	for _, name := range tests {
+		fmt.Fprintf(&buf, "\t{Name: %q, F: _test.%s},\n", name, name)
+	}
+	for _, name := range xtests {
+		fmt.Fprintf(&buf, "\t{Name: %q, F: _xtest.%s},\n", name, name)
+	}
+	fmt.Fprintln(&buf, "}")
+	fmt.Fprintln(&buf, "func main() {")
+	fmt.Fprintln(&buf, "\ttesting.Main(tests)")
+	fmt.Fprintln(&buf, "}")
+	return buf.Bytes()
+}
+
+// vim: set ft=go :
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"github.com/axw/llgo"
+	"testing"
+)
+
+func TestFindTests(t *testing.T) {
+	tests, err := findTests(testdata("testpkg/add_test.go", "testpkg/sum_test.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = checkStringsEqual(tests, []string{"TestAdd", "TestSum", "TestSumEmpty"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestTestPackage runs the tests of a package, which all pass; a failing
+// test would exit the test process.
+func TestTestPackage(t *testing.T) {
+	defer func(paths importPaths, c llgo.Compiler) {
+		importPath, compiler = paths, c
+	}(importPath, compiler)
+	status, err := testPackage("testdata/testpkg", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status != 0 {
+		t.Fatalf("tests exited with status %d", status)
+	}
+}
+
+// vim: set ft=go:
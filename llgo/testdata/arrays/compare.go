@@ -0,0 +1,30 @@
+package main
+
+func main() {
+	var e1, e2 [0]int
+	println(e1 == e2)
+
+	a := [3]int{1, 2, 3}
+	b := [3]int{1, 2, 3}
+	println(a == b, a != b)
+	b[2] = 4
+	println(a == b, a != b)
+	b[2] = 3
+	b[0] = 0
+	println(a == b, a != b)
+
+	s1 := [2]string{"x", "y"}
+	s2 := [2]string{"x", "y"}
+	println(s1 == s2)
+	s2[1] = "z"
+	println(s1 == s2)
+
+	var n1 [2][2]int
+	n1[0][1] = 2
+	n1[1][0] = 3
+	n2 := n1
+	println(n1 == n2)
+	n2[1][1] = 5
+	println(n1 == n2)
+
+}
@@ -0,0 +1,18 @@
+package main
+
+type A [3]int
+type B [2]int
+
+func main() {
+	var i interface{} = A{1, 2, 3}
+	a := i.(A)
+	println(a[0], a[1], a[2])
+
+	_, ok := i.(B)
+	println(ok)
+
+	a[1] = 5
+	i = a
+	b := i.(A)
+	println(b[1])
+}
@@ -0,0 +1,29 @@
+package main
+
+const three = 3
+
+func main() {
+	// The length of a [...]T array is one more than the greatest index.
+	a := [...]int{5: 1, 10: 2}
+	println(len(a), a[0], a[5], a[9], a[10])
+
+	// Elements without keys follow on from the preceding element.
+	b := [...]string{"a", 4: "e", "f", 1: "b"}
+	println(len(b), b[0], b[1], b[2] == "", b[4], b[5])
+
+	// Fixed-length arrays are zero-filled, and keys may be constant
+	// expressions.
+	c := [8]int{three: 3, three * 2: 6}
+	println(len(c), c[2], c[3], c[6], c[7])
+
+	// Non-constant elements.
+	x := 42
+	d := [...]int{2: x, x + 1}
+	println(len(d), d[0], d[2], d[3])
+
+	// Slices.
+	s := []int{3: 1, 2, 0: 7}
+	println(len(s), cap(s), s[0], s[1], s[3], s[4])
+	e := [...]int{}
+	println(len(e))
+}
@@ -0,0 +1,54 @@
+package main
+
+import _ "runtime"
+
+var _ = f("package-level blank")
+var _, x = 1, f("x")
+var _ int
+
+type T struct {
+	a int
+	_ int
+	b int
+}
+
+func (_ T) m(_ int) int {
+	return 3
+}
+
+func f(s string) int {
+	println(s)
+	return 1
+}
+
+func g() (int, int) {
+	println("g")
+	return 1, 2
+}
+
+func main() {
+	_ = f("assign")
+	_, y := g()
+	var _ = f("local var")
+	var _, z = g()
+	_, _ = g()
+	for _, v := range []int{4, 5} {
+		println(v)
+	}
+	for i, _ := range []int{6, 7} {
+		println(i)
+	}
+	var i, v int
+	for i, _ = range "ab" {
+		println(i)
+	}
+	for _, v = range []int{8, 9} {
+		println(v)
+	}
+	for _ = range []int{10} {
+		println("blank range")
+	}
+	m := make(map[string]int)
+	_, ok := m["a"]
+	println(x, y, z, ok, T{a: 1, b: 2}.m(0))
+}
@@ -0,0 +1,43 @@
+package main
+
+var trace string
+
+func g(s string, n int) int {
+	trace += s
+	return n
+}
+
+func pair() (int, int) {
+	return 1, 2
+}
+
+func main() {
+	a, b := 1, 2
+	a, b = b, a
+	println(a, b)
+
+	a, b = pair()
+	b, a = a, b
+	println(a, b)
+
+	x, y, z := g("x", 1), g("y", 2), g("z", 3)
+	println(x, y, z, trace)
+
+	s := []int{0, 0, 0}
+	i := 0
+	i, s[i] = 1, 9
+	println(i, s[0], s[1])
+
+	m := map[string]int{}
+	m["a"], b = 5, len(m)
+	println(m["a"], b)
+
+	arr := [2]int{1, 2}
+	arr[0], arr[1] = arr[1], arr[0]
+	println(arr[0], arr[1])
+
+	c := 1
+	f := func() int { return c }
+	c, d := 3, 4
+	println(f(), c, d)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+func main() {
+	var i32 int32 = 1
+	var i64 int64 = -5
+	var u32 uint32 = 7
+	var u64 uint64 = 1 << 40
+	var uptr uintptr = 3
+
+	println(atomic.AddInt32(&i32, 2), i32)
+	println(atomic.AddInt64(&i64, 10), i64)
+	println(atomic.AddUint32(&u32, ^uint32(0)), u32)
+	println(atomic.AddUint64(&u64, 1), u64)
+	println(atomic.AddUintptr(&uptr, 4), uptr)
+
+	atomic.StoreInt32(&i32, -9)
+	atomic.StoreUint64(&u64, 42)
+	println(atomic.LoadInt32(&i32), atomic.LoadUint64(&u64))
+
+	println(atomic.CompareAndSwapInt32(&i32, -9, 10), i32)
+	println(atomic.CompareAndSwapInt32(&i32, -9, 11), i32)
+	println(atomic.CompareAndSwapUint32(&u32, 6, 0), u32)
+
+	x, y := 1, 2
+	var p unsafe.Pointer
+	atomic.StorePointer(&p, unsafe.Pointer(&x))
+	println(*(*int)(atomic.LoadPointer(&p)))
+	println(atomic.CompareAndSwapPointer(&p, unsafe.Pointer(&y), nil))
+	println(atomic.CompareAndSwapPointer(&p, unsafe.Pointer(&x), unsafe.Pointer(&y)))
+	println(*(*int)(atomic.LoadPointer(&p)))
+}
@@ -0,0 +1,14 @@
+package main
+
+func main() {
+	m := make(map[int]int)
+	total := 0
+	for i := 0; i < 200000; i++ {
+		m[i%5000] += i
+		if i%3 == 0 {
+			delete(m, (i*7)%5000)
+		}
+		total += len(m)
+	}
+	println(total)
+}
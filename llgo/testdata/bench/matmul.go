@@ -0,0 +1,36 @@
+package main
+
+const n = 120
+
+func matrix(seed float64) [][]float64 {
+	m := make([][]float64, n)
+	for i := range m {
+		m[i] = make([]float64, n)
+		for j := range m[i] {
+			m[i][j] = seed * float64(i-j)
+		}
+	}
+	return m
+}
+
+func multiply(a, b [][]float64) [][]float64 {
+	c := matrix(0)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			var sum float64
+			for k := 0; k < n; k++ {
+				sum += a[i][k] * b[k][j]
+			}
+			c[i][j] = sum
+		}
+	}
+	return c
+}
+
+func main() {
+	a, b := matrix(0.5), matrix(0.25)
+	for i := 0; i < 4; i++ {
+		a = multiply(a, b)
+	}
+	println(a[1][2] != 0)
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+func platform() string {
+	return "not linux"
+}
@@ -0,0 +1,8 @@
+//go:build !ignore
+// +build !ignore
+
+package main
+
+func tagged() string {
+	return "not ignored"
+}
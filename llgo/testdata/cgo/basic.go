@@ -0,0 +1,26 @@
+package main
+
+// #include <stdlib.h>
+// #include <string.h>
+//
+// static unsigned int add(unsigned int a, unsigned int b) {
+//	return a + b;
+// }
+//
+// static double half(double x) {
+//	return x / 2;
+// }
+import "C"
+
+import "unsafe"
+
+func main() {
+	println(C.add(1, C.uint(2)))
+	println(C.abs(-3))
+	println(C.half(5))
+
+	cs := C.CString("hello, world")
+	println(C.strlen(cs))
+	println(C.GoString(cs))
+	C.free(unsafe.Pointer(cs))
+}
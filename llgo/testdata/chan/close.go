@@ -0,0 +1,31 @@
+package main
+
+func sender(c chan int) {
+	for i := 0; i < 3; i++ {
+		c <- i
+	}
+	close(c)
+}
+
+func main() {
+	c := make(chan int, 5)
+	c <- 1
+	c <- 2
+	println(cap(c))
+	close(c)
+	for i := 0; i < 3; i++ {
+		x, ok := <-c
+		println(x, ok)
+	}
+
+	c = make(chan int)
+	go sender(c)
+	for {
+		x, ok := <-c
+		if !ok {
+			break
+		}
+		println(x)
+	}
+	println(<-c)
+}
@@ -0,0 +1,18 @@
+package main
+
+func main() {
+	var nilch chan int
+	println(len(nilch), cap(nilch))
+
+	ch := make(chan int, 4)
+	println(len(ch), cap(ch))
+	ch <- 1
+	ch <- 2
+	ch <- 3
+	println(len(ch), cap(ch))
+	<-ch
+	println(len(ch), cap(ch))
+
+	unbuffered := make(chan int)
+	println(len(unbuffered), cap(unbuffered))
+}
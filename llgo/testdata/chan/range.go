@@ -0,0 +1,33 @@
+package main
+
+func sender(c chan int, n int) {
+	for i := 0; i < n; i++ {
+		c <- i * i
+	}
+	close(c)
+}
+
+func main() {
+	c := make(chan int)
+	go sender(c, 5)
+	for x := range c {
+		println(x)
+	}
+
+	// Buffered values are received before the channel is seen as closed.
+	c = make(chan int, 3)
+	c <- 1
+	c <- 2
+	close(c)
+	for x := range c {
+		println(x)
+	}
+
+	c = make(chan int, 1)
+	go sender(c, 3)
+	n := 0
+	for _ = range c {
+		n++
+	}
+	println(n)
+}
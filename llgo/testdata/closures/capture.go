@@ -0,0 +1,47 @@
+package main
+
+func counter() func() int {
+	n := 0
+	return func() int {
+		n++
+		return n
+	}
+}
+
+func apply(f func(int) int, x int) int {
+	return f(x)
+}
+
+func main() {
+	c1 := counter()
+	c2 := counter()
+	println(c1(), c1(), c2(), c1())
+
+	x := 10
+	add := func(y int) int {
+		return x + y
+	}
+	println(apply(add, 5))
+	x = 20
+	println(apply(add, 5))
+
+	setx := func(v int) {
+		x = v
+	}
+	setx(30)
+	println(x)
+
+	nested := func() func() int {
+		return func() int {
+			x++
+			return x
+		}
+	}
+	inc := nested()
+	println(inc(), inc(), x)
+
+	var f func() int
+	println(f == nil)
+	f = c2
+	println(f != nil, f())
+}
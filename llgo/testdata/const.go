@@ -33,8 +33,6 @@ func main() {
 	println(Big3)
 	println(bias32)
 
-	// Currently fails, due to difference in C printf and Go's println
-	// formatting of the exponent.
-	//println(10 * 1e9)
+	println(10 * 1e9)
 	println(darwinAMD64)
 }
@@ -0,0 +1,33 @@
+package main
+
+type T struct {
+	name string
+}
+
+func (t T) Print() {
+	println("method", t.name)
+}
+
+func f(n int) {
+	println("f", n)
+}
+
+func main() {
+	n := 1
+	defer f(n)
+	n = 2
+	defer func() {
+		println("closure", n)
+	}()
+	for i := 0; i < 3; i++ {
+		defer f(i + 10)
+	}
+	t := T{"t"}
+	defer t.Print()
+	t.name = "changed"
+	if n == 2 {
+		println("return")
+		return
+	}
+	println("unreachable")
+}
@@ -0,0 +1,57 @@
+package main
+
+func bare() (x int, s string) {
+	x = 1
+	s = "bare"
+	return
+}
+
+func swap() (a, b int) {
+	a, b = 1, 2
+	return b, a
+}
+
+func blank() (_ int, y int) {
+	return 3, 4
+}
+
+func double(n int) (result int) {
+	defer func() {
+		result *= 2
+	}()
+	return n + 1
+}
+
+func doubleBare(n int) (result int) {
+	defer func() {
+		result *= 2
+	}()
+	result = n
+	return
+}
+
+func blankDeferred() (_ int, y int) {
+	defer func() {
+		y++
+	}()
+	return 5, 6
+}
+
+func unnamed() int {
+	x := 7
+	defer func() {
+		x++
+	}()
+	return x
+}
+
+func main() {
+	x, s := bare()
+	println(x, s)
+	println(swap())
+	println(blank())
+	println(double(4))
+	println(doubleBare(5))
+	println(blankDeferred())
+	println(unnamed())
+}
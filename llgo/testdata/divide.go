@@ -0,0 +1,16 @@
+package main
+
+func main() {
+	x, y := -7, 2
+	println(x/y, x%y)
+	x /= y
+	println(x)
+
+	var a, b uint8 = 200, 7
+	println(a/b, a%b)
+
+	n := 3
+	for i := 1; i <= n; i++ {
+		println(12 / i)
+	}
+}
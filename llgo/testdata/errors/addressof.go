@@ -0,0 +1,17 @@
+package main
+
+type T struct{}
+
+func (t *T) M() {}
+
+func makeT() T {
+	return T{}
+}
+
+func main() {
+	m := make(map[int]T)
+	m[0].M()
+	makeT().M()
+	var t T
+	t.M()
+}
@@ -0,0 +1,7 @@
+package main
+
+func main() {
+	a := [2]int{1, 2, 3}
+	b := [2]int{5: 1}
+	println(len(a), len(b))
+}
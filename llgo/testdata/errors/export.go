@@ -0,0 +1,13 @@
+package main
+
+type T int
+
+//export M
+func (T) M() {}
+
+// The C main function is called main.
+
+//export main
+func g() {}
+
+func main() {}
@@ -0,0 +1,13 @@
+package main
+
+var a = f()
+var b = a
+
+func f() int {
+	return b
+}
+
+var c int = d
+var d int = c
+
+func main() {}
@@ -0,0 +1,23 @@
+package main
+
+func main() {
+	goto L
+	x := 1
+L:
+	println(x)
+
+	goto M
+	{
+	M:
+	}
+
+N:
+	for {
+		break N
+	}
+O:
+	println()
+	for {
+		continue O
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+const big = 1 << 40
+
+var a int8 = 300
+var b uint = -1
+
+const c int16 = big
+
+var d = int32(big)
+var e int = 2.5
+
+func f() {
+	var x uint8 = 256
+	println(x)
+}
+
+func g() int64 {
+	return int64(big) * big
+}
+
+func main() {
+	const ok int8 = -128
+	println(ok, ^uint8(0), big>>30)
+}
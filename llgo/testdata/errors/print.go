@@ -0,0 +1,9 @@
+package main
+
+type T struct{ x int }
+
+func main() {
+	var a [2]int
+	println(a)
+	print(T{}, 1)
+}
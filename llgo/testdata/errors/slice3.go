@@ -0,0 +1,7 @@
+package main
+
+func main() {
+	s := "abc"
+	t := s[0:1:2]
+	println(t)
+}
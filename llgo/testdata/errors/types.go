@@ -0,0 +1,8 @@
+package main
+
+var g complex64
+
+func main() {
+	var c complex128
+	println(c == c)
+}
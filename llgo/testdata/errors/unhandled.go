@@ -0,0 +1,13 @@
+package main
+
+func f() {}
+
+func main() {
+	defer f()
+	println("unreachable")
+}
+
+func g() {
+	println("ok")
+	defer f()
+}
@@ -0,0 +1,93 @@
+package main
+
+type counter struct {
+	n int
+}
+
+func (c *counter) inc() *counter {
+	c.n++
+	return c
+}
+
+var global *int
+
+func newInt(v int) *int {
+	x := v
+	return &x
+}
+
+func viaLocals(v int) *int {
+	x := v
+	p := &x
+	q := p
+	return q
+}
+
+func viaGlobal(v int) {
+	x := v
+	global = &x
+}
+
+func viaMethod(n int) *counter {
+	var c counter
+	c.n = n
+	return c.inc()
+}
+
+func viaSlice(v int) []int {
+	var a [3]int
+	a[0] = v
+	return a[:]
+}
+
+func viaInterface(v int) interface{} {
+	x := v
+	return &x
+}
+
+func viaPointerToPointer(v int) *int {
+	x := v
+	p := &x
+	pp := &p
+	return *pp
+}
+
+func local(v int) int {
+	x := v
+	p := &x
+	*p++
+	return x
+}
+
+func main() {
+	a := newInt(1)
+	b := newInt(2)
+	println(*a, *b)
+
+	c := viaLocals(3)
+	d := viaLocals(4)
+	println(*c, *d)
+
+	viaGlobal(5)
+	g := global
+	viaGlobal(6)
+	println(*g, *global)
+
+	e := viaMethod(7)
+	f := viaMethod(8)
+	println(e.n, f.n)
+
+	s := viaSlice(9)
+	t := viaSlice(10)
+	println(s[0], t[0])
+
+	i := viaInterface(11)
+	j := viaInterface(12)
+	println(*i.(*int), *j.(*int))
+
+	k := viaPointerToPointer(13)
+	l := viaPointerToPointer(14)
+	println(*k, *l)
+
+	println(local(15))
+}
@@ -0,0 +1,76 @@
+package main
+
+var greeting = makeGreeting()
+
+func makeGreeting() string {
+	return "hello"
+}
+
+//export Greet
+func greet() {
+	println(greeting, "from Go")
+}
+
+//export Add
+func add(a, b int32) int32 {
+	return a + b
+}
+
+// Results of up to 16 bytes are returned in registers, and larger ones
+// in memory.
+
+//export Pair
+func pair(a int32) (int32, int32) {
+	return a, a + 1
+}
+
+//export Triple
+func triple(a int64) (int64, int64, int64) {
+	return a, a * 2, a * 3
+}
+
+type point struct {
+	X, Y float64
+}
+
+type pair64 struct {
+	A, B int64
+}
+
+type triple64 struct {
+	A, B, C int64
+}
+
+// Parameters of up to 16 bytes are passed in registers while they last,
+// and larger ones in memory.
+
+//export Dot
+func dot(p, q point) float64 {
+	return p.X*q.X + p.Y*q.Y
+}
+
+//export Sum
+func sum(t triple64) int64 {
+	return t.A + t.B + t.C
+}
+
+//export Sum4
+func sum4(a, b, c, d pair64) int64 {
+	return a.A + a.B + b.A + b.B + c.A + c.B + d.A + d.B
+}
+
+//export Length
+func length(s string) int {
+	return len(s)
+}
+
+func main() {
+	greet()
+	println(add(1, 2))
+	println(pair(1))
+	println(triple(1))
+	println(dot(point{1, 2}, point{3, 4}))
+	println(sum(triple64{1, 2, 3}))
+	println(sum4(pair64{1, 2}, pair64{3, 4}, pair64{5, 6}, pair64{7, 8}))
+	println(length("hello"))
+}
@@ -0,0 +1,32 @@
+#include <stdint.h>
+#include <stdio.h>
+
+struct pair32 { int32_t a, b; };
+struct pair64 { int64_t a, b; };
+struct triple64 { int64_t a, b, c; };
+struct point { double x, y; };
+struct gostring { const char *p; int64_t n; };
+
+extern int32_t Add(int32_t, int32_t);
+extern struct pair32 Pair(int32_t);
+extern struct triple64 Triple(int64_t);
+extern double Dot(struct point, struct point);
+extern int64_t Sum(struct triple64);
+extern int64_t Sum4(struct pair64, struct pair64, struct pair64, struct pair64);
+extern int64_t Length(struct gostring);
+
+int main(void) {
+	struct pair32 p = Pair(1);
+	struct triple64 t = Triple(2);
+	struct point u = {1, 2}, v = {3, 4};
+	struct pair64 a = {1, 2}, b = {3, 4}, c = {5, 6}, d = {7, 8};
+	struct gostring s = {"hello", 5};
+	printf("%d\n", Add(1, 2));
+	printf("%d %d\n", p.a, p.b);
+	printf("%ld %ld %ld\n", (long)t.a, (long)t.b, (long)t.c);
+	printf("%g\n", Dot(u, v));
+	printf("%ld\n", (long)Sum(t));
+	printf("%ld\n", (long)Sum4(a, b, c, d));
+	printf("%ld\n", (long)Length(s));
+	return 0;
+}
@@ -0,0 +1,35 @@
+package main
+
+//extern strlen
+func c_strlen(s *byte) uintptr
+
+//extern abs
+func c_abs(n int32) int32
+
+//extern sqrt
+func c_sqrt(x float64) float64
+
+type div_t struct {
+	quot, rem int32
+}
+
+type ldiv_t struct {
+	quot, rem int64
+}
+
+//extern div
+func c_div(n, d int32) div_t
+
+//extern ldiv
+func c_ldiv(n, d int64) ldiv_t
+
+func main() {
+	s := []byte("hello\x00")
+	println(c_strlen(&s[0]))
+	println(c_abs(-42))
+	println(c_sqrt(16))
+	q := c_div(10, 3)
+	println(q.quot, q.rem)
+	lq := c_ldiv(-9, 4)
+	println(lq.quot, lq.rem)
+}
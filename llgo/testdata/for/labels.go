@@ -0,0 +1,57 @@
+package main
+
+func main() {
+	// Labeled continue and break of an outer loop.
+outer:
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 3; j++ {
+			if j == 1 {
+				continue outer
+			}
+			if i == 2 {
+				break outer
+			}
+			println(i, j)
+		}
+	}
+
+	// Labeled break out of a switch within a loop, and of a loop
+	// within a switch.
+	n := 0
+loop:
+	for {
+		switch n {
+		case 3:
+			break loop
+		default:
+			n++
+		}
+	}
+	println(n)
+
+	s := []int{1, 2, 3}
+sw:
+	switch {
+	case n > 0:
+		for _, x := range s {
+			if x == 2 {
+				break sw
+			}
+			println(x)
+		}
+		println("unreachable")
+	}
+
+	// goto skips unreachable statements up to its label.
+	i := 0
+	goto check
+	println("skipped")
+body:
+	println("body", i)
+	i++
+check:
+	if i < 2 {
+		goto body
+	}
+	println("done")
+}
@@ -0,0 +1,14 @@
+package main
+
+func main() {
+	// Variables declared in a loop body reuse the same stack memory on
+	// each iteration, rather than growing the stack.
+	sum := 0
+	for i := 0; i < 200000; i++ {
+		var a [16]int
+		a[i%16] = i
+		b := a
+		sum += b[i%16] % 7
+	}
+	println(sum)
+}
@@ -0,0 +1,43 @@
+package main
+
+import "runtime"
+
+type node struct {
+	value int
+	next  *node
+}
+
+var head *node
+
+func garbage() {
+	for i := 0; i < 1000; i++ {
+		_ = make([]byte, 1024)
+	}
+}
+
+func main() {
+	for i := 0; i < 10; i++ {
+		head = &node{i, head}
+	}
+	local := make([]int, 3)
+	local[0] = 1
+	local[1] = 2
+	local[2] = 3
+	garbage()
+	runtime.GC()
+	garbage()
+	runtime.GC()
+
+	sum := 0
+	for n := head; n != nil; n = n.next {
+		sum += n.value
+	}
+	println(sum)
+	println(local[0], local[1], local[2])
+	s := "abc"
+	for i := 0; i < 3; i++ {
+		s += s
+	}
+	runtime.GC()
+	println(len(s), s[:6])
+}
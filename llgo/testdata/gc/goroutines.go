@@ -0,0 +1,55 @@
+package main
+
+import "runtime"
+
+type node struct {
+	value int
+	next  *node
+}
+
+func build(n int) *node {
+	var head *node
+	for i := 0; i < n; i++ {
+		head = &node{i, head}
+	}
+	return head
+}
+
+func sum(head *node) int {
+	total := 0
+	for n := head; n != nil; n = n.next {
+		total += n.value
+	}
+	return total
+}
+
+// worker builds a list held only by its own stack, and parks until told
+// to sum it, while the main goroutine collects.
+func worker(n int, start <-chan bool, results chan<- int) {
+	head := build(n)
+	<-start
+	results <- sum(head)
+}
+
+func main() {
+	start := make(chan bool)
+	results := make(chan int)
+	for i := 1; i <= 3; i++ {
+		go worker(i*100, start, results)
+	}
+	runtime.Gosched()
+	for i := 0; i < 100; i++ {
+		_ = make([]byte, 1024)
+	}
+	runtime.GC()
+	for i := 0; i < 1000; i++ {
+		_ = build(10)
+	}
+	runtime.GC()
+	total := 0
+	for i := 0; i < 3; i++ {
+		start <- true
+		total += <-results
+	}
+	println(total)
+}
@@ -0,0 +1,44 @@
+package main
+
+import "runtime"
+
+type node struct {
+	value int
+	next  *node
+}
+
+// build makes a list of n nodes, allocating garbage between them so that
+// collections run while other goroutines hold lists of their own.
+func build(n int) *node {
+	var head *node
+	for i := 0; i < n; i++ {
+		head = &node{i, head}
+		for j := 0; j < 16; j++ {
+			_ = make([]byte, 4096)
+		}
+	}
+	return head
+}
+
+func sum(head *node) int {
+	total := 0
+	for n := head; n != nil; n = n.next {
+		total += n.value
+	}
+	return total
+}
+
+func main() {
+	runtime.GOMAXPROCS(4)
+	results := make(chan int)
+	for i := 0; i < 4; i++ {
+		go func() {
+			head := build(500)
+			runtime.GC()
+			results <- sum(head)
+		}()
+	}
+	for i := 0; i < 4; i++ {
+		println(<-results)
+	}
+}
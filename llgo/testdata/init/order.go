@@ -0,0 +1,28 @@
+package main
+
+var a = b + c // == 9
+var b = f()   // == 4
+var c = g()   // == 5
+var d = 3
+
+func f() int {
+	println("f")
+	return d + 1
+}
+
+func g() int {
+	println("g")
+	return b + 1
+}
+
+func init() {
+	println("first init", a, b, c)
+}
+
+func init() {
+	println("second init")
+}
+
+func main() {
+	println("main", a)
+}
@@ -0,0 +1,7 @@
+package main
+
+var e = a * 2 // == 18
+
+func init() {
+	println("third init", e)
+}
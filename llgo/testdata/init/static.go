@@ -0,0 +1,36 @@
+package main
+
+const n = 3
+
+type point struct {
+	x, y int
+	name string
+	next *point
+}
+
+var table = [...]int{1, 2, n * 2, 5: 10}
+var origin = point{name: "origin"}
+var points = [2]point{{1, 2, "a", nil}, {x: n, y: -n}}
+var greeting = "hello, " + "world"
+var ratio float64 = n / 2.0
+
+var dynamic = sum(table[:])
+var head = &points[1]
+
+func sum(xs []int) int {
+	total := 0
+	for _, x := range xs {
+		total += x
+	}
+	return total
+}
+
+func main() {
+	println(len(table), table[0], table[2], table[4], table[5])
+	println(origin.x, origin.y, origin.name, origin.next == nil)
+	println(points[0].x, points[0].y, points[0].name, points[1].x, points[1].y, points[1].name == "")
+	println(greeting, ratio == 1.5)
+	println(dynamic, head.x)
+	table[0] = 100
+	println(sum(table[:]))
+}
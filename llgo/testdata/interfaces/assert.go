@@ -0,0 +1,21 @@
+package main
+
+type any interface{}
+
+func main() {
+	var x any = 123
+	println(x.(int))
+
+	i, ok := x.(int)
+	println(i, ok)
+
+	s, ok := x.(string)
+	println(s, ok)
+
+	var y any
+	_, ok = y.(int)
+	println(ok)
+
+	var j, ok2 = x.(int)
+	println(j, ok2)
+}
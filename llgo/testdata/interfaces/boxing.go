@@ -0,0 +1,66 @@
+package main
+
+type Big struct {
+	a, b, c int
+}
+
+func (b Big) Sum() int {
+	return b.a + b.b + b.c
+}
+
+type Small struct {
+	x, y int8
+}
+
+func (s Small) Sum() int {
+	return int(s.x) + int(s.y)
+}
+
+type Summer interface {
+	Sum() int
+}
+
+func main() {
+	// Boxing copies the value, so later changes to the original value
+	// are not visible through the interface.
+	big := Big{1, 2, 3}
+	var e interface{} = big
+	big.a = 100
+	b := e.(Big)
+	println(b.a, b.b, b.c)
+
+	// Unboxing copies the value too.
+	b.a = 200
+	println(e.(Big).a)
+
+	arr := [4]int{1, 2, 3, 4}
+	e = arr
+	arr[0] = 0
+	println(e.([4]int)[0])
+
+	s := "hello"
+	e = s
+	s = "world"
+	println(e.(string), s)
+
+	e = Small{1, 2}
+	println(e.(Small).x, e.(Small).y)
+
+	e = 1.5
+	println(e.(float64) == 1.5)
+
+	e = [2]int8{3, 4}
+	a := e.([2]int8)
+	println(a[0], a[1])
+
+	// Value methods are callable through interfaces holding values of
+	// any size, and holding pointers.
+	var i Summer = Big{4, 5, 6}
+	println(i.Sum())
+	i = Small{7, 8}
+	println(i.Sum())
+	i = &big
+	println(i.Sum())
+	big.a = 1
+	println(i.Sum())
+}
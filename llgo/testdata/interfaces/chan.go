@@ -0,0 +1,14 @@
+package main
+
+type C chan int
+type R <-chan int
+
+func main() {
+	var c C
+	var i interface{} = c
+	c2, ok := i.(C)
+	println(c2 == nil, ok)
+
+	_, ok = i.(R)
+	println(ok)
+}
@@ -0,0 +1,57 @@
+package main
+
+type E struct {
+	msg string
+}
+
+func (e *E) Error() string {
+	return e.msg
+}
+
+type error_ interface {
+	Error() string
+}
+
+func fail(b bool) error_ {
+	if b {
+		return &E{"failed"}
+	}
+	return nil
+}
+
+func main() {
+	var i, j interface{}
+	println(i == nil, i != nil, nil == i, i == j)
+
+	i = 1
+	println(i == nil, i == j)
+	j = 1
+	println(i == j, i != j)
+	j = 2
+	println(i == j, i != j)
+	j = "1"
+	println(i == j)
+
+	i = "abc"
+	j = "ab" + "c"
+	println(i == j)
+
+	var x int = 2
+	i = x
+	println(i == x, x == i, i != x)
+	x = 3
+	println(i == x, x == i, i != x)
+
+	// An interface holding a nil pointer is not nil.
+	var p *E
+	var e error_ = p
+	println(e == nil, e != nil)
+
+	err := fail(false)
+	println(err == nil, err != nil)
+	err = fail(true)
+	println(err == nil, err != nil)
+	if err != nil {
+		println(err.Error())
+	}
+}
@@ -0,0 +1,37 @@
+package main
+
+// Boxed is converted to an interface, so it has a type descriptor, as
+// do Arg and the signatures of Boxed's methods, which its descriptor
+// refers to.
+type Boxed struct {
+	x int
+}
+
+func (b Boxed) M(a Arg) int {
+	return b.x + a.y
+}
+
+type Arg struct {
+	y int
+}
+
+// Unboxed is never converted to an interface, or asserted to, so it has
+// no type descriptor.
+type Unboxed struct {
+	z int
+}
+
+func (u Unboxed) M(a Arg) int {
+	return u.z - a.y
+}
+
+type I interface {
+	M(Arg) int
+}
+
+func main() {
+	var i I = Boxed{1}
+	println(i.M(Arg{2}))
+	u := Unboxed{3}
+	println(u.M(Arg{4}))
+}
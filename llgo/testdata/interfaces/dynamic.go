@@ -0,0 +1,31 @@
+package main
+
+type I interface {
+	M()
+}
+
+type J interface {
+	M()
+	N()
+}
+
+type T int
+
+func (t T) M() {
+	println("T.M", int(t))
+}
+
+func main() {
+	var i I = T(1)
+	i.M()
+	_, ok := i.(J)
+	println(ok)
+
+	var e interface{} = 123
+	_, ok = e.(I)
+	println(ok)
+
+	e = nil
+	_, ok = e.(J)
+	println(ok)
+}
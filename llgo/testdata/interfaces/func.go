@@ -0,0 +1,22 @@
+package main
+
+type F func(int) int
+type G func(...int)
+
+func double(x int) int {
+	return x * 2
+}
+
+func main() {
+	var i interface{} = F(double)
+	f := i.(F)
+	println(f(21))
+
+	_, ok := i.(G)
+	println(ok)
+
+	n := 3
+	i = F(func(x int) int { return x + n })
+	f, ok = i.(F)
+	println(f(4), ok)
+}
@@ -0,0 +1,69 @@
+package main
+
+type Namer interface {
+	Name() string
+}
+
+type Sizer interface {
+	Size() int
+}
+
+type NameSizer interface {
+	Name() string
+	Size() int
+}
+
+type T struct {
+	name string
+	size int
+}
+
+func (t *T) Name() string {
+	return t.name
+}
+
+func (t *T) Size() int {
+	return t.size
+}
+
+type U int
+
+func (u U) Name() string {
+	return "U"
+}
+
+func describe(n Namer) {
+	if s, ok := n.(Sizer); ok {
+		println(n.Name(), s.Size())
+	} else {
+		println(n.Name(), "unsized")
+	}
+}
+
+func main() {
+	t := &T{"t", 3}
+	var ns NameSizer = t
+	var n Namer = ns
+	describe(n)
+	describe(U(1))
+
+	// Interfaces holding the same dynamic type and value are equal.
+	var n2 Namer = t
+	println(n == n2)
+	var e interface{} = n
+	var e2 interface{} = ns
+	println(e == e2)
+
+	// Conversions between interfaces keep the value.
+	s := e.(Sizer)
+	println(s.Size())
+	ns = s.(NameSizer)
+	println(ns.Name(), ns.Size())
+
+	// A nil interface converts to nil.
+	n = nil
+	e = n
+	println(e == nil)
+	_, ok := e.(Sizer)
+	println(ok)
+}
@@ -0,0 +1,49 @@
+package main
+
+type I interface {
+	M()
+}
+
+type J interface {
+	M()
+	N()
+}
+
+type T int
+
+func (t T) M() {
+	println("T.M", int(t))
+}
+
+func (t T) N() {
+	println("T.N", int(t))
+}
+
+type List struct {
+	next *List
+	x    int
+}
+
+func (l *List) M() {
+	println("List.M", l.x)
+}
+
+func main() {
+	var e interface{} = T(1)
+	if i, ok := e.(I); ok {
+		i.M()
+	}
+	j := e.(J)
+	j.N()
+	j.M()
+
+	var i I = T(2)
+	j, ok := i.(J)
+	println(ok)
+	j.N()
+
+	l := &List{x: 3}
+	e = l
+	i, ok = e.(I)
+	println(ok)
+}
@@ -0,0 +1,40 @@
+package main
+
+type T struct{}
+
+func (T) M(s string) int { return len(s) }
+func (T) N(x int) int    { return x + 1 }
+
+// I has a method named M, but with a different signature to T's, so T
+// does not implement I.
+type I interface {
+	M(int) int
+}
+
+type J interface {
+	M(string) int
+}
+
+// K's method N returns a different type to T's.
+type K interface {
+	N(int) int64
+}
+
+func main() {
+	var e interface{} = T{}
+	_, ok := e.(I)
+	println(ok)
+	j, ok := e.(J)
+	println(ok, j.M("four"))
+	_, ok = e.(K)
+	println(ok)
+
+	switch e.(type) {
+	case I:
+		println("I")
+	case K:
+		println("K")
+	case J:
+		println("J")
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+type T struct {
+	x int
+}
+
+func box() interface{} {
+	return []int{1, 2, 3}
+}
+
+func main() {
+	type L int
+	var e interface{} = T{1}
+	println(e.(T).x)
+	e = L(2)
+	println(e.(L))
+	s, ok := box().([]int)
+	println(ok, len(s))
+	e = struct{ y int }{3}
+	println(e.(struct{ y int }).y)
+}
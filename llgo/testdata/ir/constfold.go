@@ -0,0 +1,12 @@
+package main
+
+// Operations on constant strings are folded at compile time.
+
+// CHECK-NOT: runtime.strcat
+// CHECK-NOT: runtime.strcmp
+
+const s = "ll" + "go"
+
+func main() {
+	println(s, s == "llgo", s+"!")
+}
@@ -0,0 +1,20 @@
+package main
+
+// Exported functions have C entry points whose signatures are lowered to
+// the C ABI.
+
+// CHECK: define i32 @Add(i32, i32)
+// CHECK: define double @Scale(double, double)
+
+//export Add
+func Add(a, b int32) int32 {
+	return a + b
+}
+
+//export Scale
+func Scale(x, factor float64) float64 {
+	return x * factor
+}
+
+func main() {
+}
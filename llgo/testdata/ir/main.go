@@ -0,0 +1,15 @@
+package main
+
+// The C main function records its arguments, and initialises the runtime
+// and the package before calling main.main.
+
+// CHECK-LABEL: define i32 @main(i32
+// CHECK: call void @runtime.setargs(
+// CHECK-NOT: call void @main.main()
+// CHECK: call void @runtime.init()
+// CHECK-NEXT: call void @main.init()
+// CHECK-NEXT: call void @main.main()
+// CHECK-NEXT: ret i32 0
+
+func main() {
+}
@@ -0,0 +1,13 @@
+package main
+
+import "math"
+
+// math.Sqrt is lowered to the LLVM intrinsic, rather than called.
+
+// CHECK-NOT: @math.Sqrt
+// CHECK: call double @llvm.sqrt.f64(
+
+func main() {
+	x := 2.0
+	println(math.Sqrt(x))
+}
@@ -0,0 +1,20 @@
+package main
+
+// Structs and arrays held in memory are copied with llvm.memcpy, and
+// variables without an initialiser are zeroed with llvm.memset, rather
+// than being loaded and stored element by element.
+
+// CHECK-LABEL: define void @main.main()
+// CHECK: call void @llvm.memset.
+// CHECK: call void @llvm.memcpy.
+
+type big struct {
+	a [64]int
+}
+
+func main() {
+	var x big
+	x.a[3] = 1
+	y := x
+	println(y.a[3])
+}
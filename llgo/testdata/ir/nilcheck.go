@@ -0,0 +1,20 @@
+package main
+
+// A pointer is checked for nil once, however many times it is
+// dereferenced.
+
+// CHECK-LABEL: @main.sum(
+// CHECK: call void @runtime.panicnil()
+// CHECK-NOT: call void @runtime.panicnil()
+
+type T struct {
+	a, b, c int
+}
+
+func sum(p *T) int {
+	return p.a + p.b + p.c
+}
+
+func main() {
+	println(sum(&T{1, 2, 3}))
+}
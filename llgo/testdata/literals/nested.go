@@ -0,0 +1,25 @@
+package main
+
+type T struct {
+	a, b int
+}
+
+func main() {
+	s := [][]int{{1, 2}, {3}, {}}
+	println(len(s), len(s[0]), len(s[1]), len(s[2]), s[0][1], s[1][0])
+
+	a := [2][2]int{{1, 2}, {3, 4}}
+	println(a[0][0], a[0][1], a[1][0], a[1][1])
+
+	ts := []T{{1, 2}, {b: 3}}
+	println(ts[0].a, ts[0].b, ts[1].a, ts[1].b)
+
+	// An elided &T yields a pointer.
+	ps := []*T{{5, 6}, {a: 7}}
+	println(ps[0].a, ps[0].b, ps[1].a)
+	ps[0].a = 8
+	println(ps[0].a)
+
+	deep := [][][]string{{{"x", "y"}}, {{"z"}, {}}}
+	println(len(deep), len(deep[1]), deep[0][0][1], deep[1][0][0])
+}
@@ -0,0 +1,28 @@
+package main
+
+func main() {
+	m := make(map[int]int)
+	for i := 0; i < 100; i++ {
+		m[i] = i * i
+	}
+	println(len(m))
+
+	for i := 0; i < 100; i += 2 {
+		delete(m, i)
+	}
+	println(len(m))
+
+	sum := 0
+	for k, v := range m {
+		if v != k*k {
+			println("mismatch:", k, v)
+		}
+		sum += k
+	}
+	println(sum)
+
+	// Maps are reference types.
+	n := m
+	n[1000] = 1
+	println(len(m), m[1000])
+}
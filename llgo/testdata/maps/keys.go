@@ -0,0 +1,23 @@
+package main
+
+func main() {
+	m := make(map[string]int)
+	k := "a"
+	k += "b"
+	m[k] = 1
+	println(m["ab"])
+	m["ab"] = 2
+	println(m[k], len(m))
+
+	var x interface{} = k
+	var y interface{} = "ab"
+	println(x == y)
+
+	mi := make(map[interface{}]int)
+	mi[1] = 10
+	mi["ab"] = 20
+	mi[k] = 30
+	println(mi[1], mi["ab"], len(mi))
+	delete(mi, x)
+	println(mi[y], len(mi))
+}
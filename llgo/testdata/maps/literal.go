@@ -0,0 +1,24 @@
+package main
+
+type P struct {
+	x, y int
+}
+
+func main() {
+	m := map[string]int{"one": 1, "two": 2, "three": 3}
+	println(len(m), m["one"], m["two"], m["three"], m["four"])
+
+	var empty = map[int]bool{}
+	println(len(empty))
+
+	// Keys need not be constant.
+	k := 5
+	n := map[int]int{k: 1, k + 1: 2}
+	println(len(n), n[5], n[6])
+
+	// Element types may be elided.
+	pts := map[string]P{"a": {1, 2}, "b": {x: 3}}
+	println(pts["a"].x, pts["a"].y, pts["b"].x, pts["b"].y)
+	lists := map[int][]string{1: {"x"}, 2: {"y", "z"}}
+	println(len(lists[1]), len(lists[2]), lists[2][1])
+}
@@ -1,10 +1,29 @@
 package main
 
+type M map[string]int
+
 func main() {
 	m := make(map[int]int)
 	v, ok := m[8]
 	println(v, ok)
-	//m[8] = 1
-	//v, ok = m[8]
-	//println(v, ok)
+	m[8] = 1
+	v, ok = m[8]
+	println(v, ok)
+	println(m[9], len(m))
+	_, ok = m[9]
+	println(ok)
+
+	// Compound assignment and increment insert missing keys.
+	m[9] += 5
+	m[10]++
+	m[8]--
+	println(m[8], m[9], m[10], len(m))
+
+	// Named map types.
+	n := make(M)
+	n["a"] = 1
+	n["b"] += 2
+	n["a"]++
+	x, present := n["a"]
+	println(x, present, n["b"], n["c"], len(n))
 }
@@ -0,0 +1,31 @@
+package main
+
+func main() {
+	m := make(map[int]int)
+	for i := 0; i < 100; i++ {
+		m[i] = i * 2
+	}
+
+	// Delete each entry's partner; only one of each pair is visited.
+	n, sum := 0, 0
+	for k, v := range m {
+		delete(m, k^1)
+		n++
+		sum += v - k*2
+	}
+	println(n, sum, len(m))
+
+	// Delete the current entry; every entry is visited once.
+	n = 0
+	for k := range m {
+		delete(m, k)
+		n++
+	}
+	println(n, len(m))
+
+	// Ranging over a nil map produces no entries.
+	var nilmap map[string]int
+	for k, v := range nilmap {
+		println(k, v)
+	}
+}
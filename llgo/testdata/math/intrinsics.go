@@ -0,0 +1,13 @@
+package main
+
+import "math"
+
+func main() {
+	x := 2.25
+	println(math.Sqrt(x), math.Sqrt(2), math.Sqrt(-1))
+	println(math.Abs(-x), math.Abs(x))
+	println(math.Floor(x), math.Floor(-x), math.Ceil(x), math.Ceil(-x))
+	println(math.Trunc(x), math.Trunc(-x))
+	println(math.Copysign(x, -1), math.Copysign(-x, 1))
+	println(math.FMA(x, 2, 0.5))
+}
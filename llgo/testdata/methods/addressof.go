@@ -0,0 +1,59 @@
+package main
+
+type T struct {
+	n int
+}
+
+func (t *T) Inc() {
+	t.n++
+}
+
+func (t T) Get() int {
+	return t.n
+}
+
+type Embed struct {
+	T
+}
+
+type EmbedPtr struct {
+	*T
+}
+
+func makeT() T {
+	return T{5}
+}
+
+func main() {
+	// The address of an addressable value is taken implicitly.
+	var t T
+	t.Inc()
+	t.Inc()
+	println(t.n)
+
+	var e Embed
+	e.Inc()
+	println(e.n, e.T.n)
+
+	ep := EmbedPtr{&t}
+	ep.Inc()
+	println(t.n)
+
+	s := make([]T, 2)
+	s[1].Inc()
+	println(s[0].n, s[1].n)
+
+	var a [2]T
+	a[0].Inc()
+	println(a[0].n)
+
+	p := &e
+	p.Inc()
+	println(e.n)
+
+	// Value methods may be called on values that aren't addressable.
+	println(makeT().Get())
+	m := make(map[string]T)
+	m["x"] = T{7}
+	println(m["x"].Get())
+}
@@ -0,0 +1,46 @@
+package main
+
+type T struct {
+	x int
+}
+
+func (t T) Get() int {
+	return t.x
+}
+
+func (t *T) Add(n int) {
+	t.x += n
+}
+
+type Getter interface {
+	Get() int
+}
+
+func call(f func() int) int {
+	return f()
+}
+
+func main() {
+	t := T{1}
+
+	// Method values bind the receiver when evaluated.
+	get := t.Get
+	add := t.Add
+	add(10)
+	println(get(), t.x)
+	println(call(t.Get))
+
+	var g Getter = t
+	iget := g.Get
+	println(iget())
+
+	// Method expressions take the receiver as the first argument.
+	f := T.Get
+	println(f(t))
+	addp := (*T).Add
+	addp(&t, 5)
+	getp := (*T).Get
+	println(getp(&t))
+	fi := Getter.Get
+	println(fi(g))
+}
@@ -0,0 +1,28 @@
+package main
+
+type T struct {
+	x    int
+	next *T
+}
+
+func (t *T) IsNil() bool {
+	return t == nil
+}
+
+func main() {
+	// Calling a pointer method on a nil pointer is fine, so long as the
+	// method doesn't dereference it.
+	var t *T
+	println(t.IsNil())
+
+	t = &T{x: 1}
+	t.next = &T{x: 2}
+	println(t.IsNil(), t.x, t.next.x)
+
+	p := &t.x
+	*p = 3
+	println(*p, t.x)
+
+	a := &[2]int{4, 5}
+	println(a[1])
+}
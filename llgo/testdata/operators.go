@@ -46,5 +46,10 @@ func main() {
 	var ui int = 123
 	println(^si)
 	println(^ui)
+
+	// Comparisons of signed integers must take the sign into account.
+	println(si < 0, si <= ui, si > ui, si >= 0)
+	var u8 uint8 = 200
+	println(u8 > 100, int8(-1) < int8(1))
 }
 
@@ -0,0 +1,16 @@
+package main
+
+import "os"
+
+func main() {
+	println(len(os.Args))
+	println(os.Getenv("PATH") != "")
+	println(os.Getenv("LLGO_TEST_UNSET_VARIABLE") == "")
+	found := false
+	for _, kv := range os.Environ() {
+		if len(kv) > 5 && kv[:5] == "PATH=" {
+			found = true
+		}
+	}
+	println(found)
+}
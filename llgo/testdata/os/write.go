@@ -0,0 +1,12 @@
+package main
+
+import "os"
+
+func main() {
+	println("before")
+	n, err := os.Stdout.Write([]byte("hello, world\n"))
+	println(n, err == nil)
+	n, err = os.Stdout.WriteString("goodbye\n")
+	println(n, err == nil)
+	println(os.Stdout.Name(), os.Stdout.Fd())
+}
@@ -0,0 +1,26 @@
+package main
+
+type E string
+
+func (e E) Error() string { return string(e) }
+
+func deferred(name string) {
+	println("deferred", name)
+}
+
+func f() {
+	defer deferred("f")
+	panic(E("boom"))
+}
+
+func main() {
+	n := 1
+	defer func() {
+		println("deferred closure", n)
+		panic(E("again"))
+	}()
+	defer deferred("main")
+	n = 2
+	f()
+	println("unreachable")
+}
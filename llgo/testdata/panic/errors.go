@@ -0,0 +1,66 @@
+package main
+
+import "os"
+
+type I interface {
+	M()
+}
+
+func main() {
+	s := make([]int, 3, 5)
+	a := [3]int{}
+	str := "abc"
+	n, m, k := 6, 4, 2
+	var e interface{} = "x"
+	var p *int
+	var mp map[int]int
+	var c chan int
+	zero := 0
+	switch os.Args[1] {
+	case "index":
+		println(s[n])
+	case "negindex":
+		println(s[k-n])
+	case "strindex":
+		println(str[n])
+	case "slicecap":
+		println(len(s[:n]))
+	case "slicearray":
+		println(len(a[:n]))
+	case "slicestring":
+		println(len(str[:n]))
+	case "slicelow":
+		println(len(s[n:]))
+	case "slicelowhigh":
+		println(len(s[m:k]))
+	case "slice3cap":
+		println(len(s[:k:n]))
+	case "slice3high":
+		println(len(s[:m:k]))
+	case "slice3low":
+		println(len(s[m : k : n-1]))
+	case "nilmap":
+		mp[1] = 1
+	case "nilptr":
+		println(*p)
+	case "divide":
+		println(n / zero)
+	case "assert":
+		println(e.(int))
+	case "assertiface":
+		println(e.(error))
+	case "assertnil":
+		e = nil
+		e.(I).M()
+	case "closenil":
+		close(c)
+	case "closeclosed":
+		c = make(chan int)
+		close(c)
+		close(c)
+	case "sendclosed":
+		c = make(chan int, 1)
+		close(c)
+		c <- 1
+	}
+}
@@ -0,0 +1,18 @@
+package main
+
+type E struct{}
+
+func (E) Error() string { return "boom" }
+
+func f(n int) {
+	if n == 0 {
+		panic(E{})
+	}
+	f(n - 1)
+}
+
+func main() {
+	println("start")
+	f(2)
+	println("unreachable")
+}
@@ -0,0 +1,16 @@
+package main
+
+func main() {
+	println(1.5, 0.0, 100.0, 1e100, 1.0/3)
+	var f float32 = 0.25
+	println(f, f*3)
+	var g float32 = 0.1
+	println(g, float64(g))
+	x := 123456789.0
+	println(x, x/1e12)
+	y := 2.5
+	println(-y, 9.9999999)
+	var zero float64
+	println(1/zero, -1/zero, zero/zero == zero/zero)
+	print(y, "\n")
+}
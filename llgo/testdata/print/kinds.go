@@ -0,0 +1,30 @@
+package main
+
+type T struct{}
+
+func main() {
+	s := "a\x00b"
+	println(s, len(s))
+	print("c\x00d", "\n")
+	println("100%", "%d")
+
+	var u8 uint8 = 255
+	var u16 uint16 = 65535
+	var u32 uint32 = 1<<32 - 1
+	var u64 uint64 = 1<<64 - 1
+	var u uint = 1 << 30
+	var up uintptr = 42
+	println(u8, u16, u32, u64, u, up)
+
+	t, f := true, false
+	println(t, f, !t, t == f)
+
+	var p *int
+	var m map[int]int
+	var c chan int
+	var fn func()
+	var e interface{}
+	var err error
+	var b []byte
+	println(p, m, c, fn, e, err, b)
+}
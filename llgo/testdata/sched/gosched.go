@@ -0,0 +1,21 @@
+package main
+
+import "runtime"
+
+var done bool
+
+func worker() {
+	done = true
+}
+
+func main() {
+	runtime.GOMAXPROCS(1)
+	println(runtime.NumGoroutine())
+
+	// With a single processor, the worker runs only when main yields.
+	go worker()
+	for !done {
+		runtime.Gosched()
+	}
+	println(done)
+}
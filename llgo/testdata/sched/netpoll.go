@@ -0,0 +1,57 @@
+package main
+
+import "syscall"
+
+// #llgo name: runtime.netpollopen
+func netpollopen(fd int32) (uintptr, int32)
+
+// #llgo name: runtime.netpollwait
+func netpollwait(pd uintptr, mode int32) int32
+
+// #llgo name: runtime.netpollunblock
+func netpollunblock(pd uintptr)
+
+// #llgo name: runtime.netpollclose
+func netpollclose(pd uintptr)
+
+func reader(fd int, pd uintptr, started, done chan bool) {
+	buf := make([]byte, 5)
+	waits := 0
+	started <- true
+	for {
+		n, err := syscall.Read(fd, buf)
+		if err != syscall.EAGAIN {
+			println(n, string(buf[:n]), waits > 0)
+			break
+		}
+		waits++
+		if netpollwait(pd, 'r') != 0 {
+			println("closing")
+			break
+		}
+	}
+	done <- true
+}
+
+func main() {
+	var p [2]int
+	syscall.Pipe(p[:])
+	syscall.SetNonblock(p[0], true)
+	pd, errno := netpollopen(int32(p[0]))
+	println(errno)
+
+	started, done := make(chan bool), make(chan bool)
+	go reader(p[0], pd, started, done)
+	<-started
+	syscall.Write(p[1], []byte("hello"))
+	<-done
+
+	// A reader waiting when the descriptor is closed is woken.
+	go reader(p[0], pd, started, done)
+	<-started
+	netpollunblock(pd)
+	<-done
+	netpollclose(pd)
+	syscall.Close(p[0])
+	syscall.Close(p[1])
+}
@@ -0,0 +1,40 @@
+package main
+
+func player(in <-chan int, out chan<- int) {
+	for n := range in {
+		out <- n + 1
+	}
+	close(out)
+}
+
+func main() {
+	ping := make(chan int)
+	pong := make(chan int)
+	go player(ping, pong)
+	n := 0
+	for i := 0; i < 1000; i++ {
+		ping <- n
+		n = <-pong
+	}
+	close(ping)
+	_, ok := <-pong
+	println(n, ok)
+
+	// Senders block while the buffer is full, and are readied as the
+	// receiver makes room.
+	buffered := make(chan int, 2)
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 10; i++ {
+			buffered <- i
+		}
+		close(buffered)
+		done <- true
+	}()
+	sum := 0
+	for v := range buffered {
+		sum += v
+	}
+	<-done
+	println(sum)
+}
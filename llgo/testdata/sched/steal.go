@@ -0,0 +1,31 @@
+package main
+
+import "runtime"
+
+func sum(from, to int, result chan int) {
+	s := 0
+	for i := from; i < to; i++ {
+		s += i
+	}
+	result <- s
+}
+
+func main() {
+	old := runtime.GOMAXPROCS(4)
+	println(runtime.GOMAXPROCS(0))
+
+	// More goroutines than processors, so that idle processors steal
+	// queued goroutines.
+	result := make(chan int)
+	for i := 0; i < 100; i++ {
+		go sum(i*1000, (i+1)*1000, result)
+	}
+	total := 0
+	for i := 0; i < 100; i++ {
+		total += <-result
+	}
+	println(total)
+
+	runtime.GOMAXPROCS(old)
+	println(runtime.GOMAXPROCS(0) == old)
+}
@@ -0,0 +1,22 @@
+package main
+
+import (
+	"runtime"
+	"syscall"
+)
+
+func main() {
+	runtime.GOMAXPROCS(1)
+	var p [2]int
+	syscall.Pipe(p[:])
+
+	// The writer runs while main is blocked reading, on the only
+	// processor.
+	go func() {
+		println("writing")
+		syscall.Write(p[1], []byte("hello"))
+	}()
+	buf := make([]byte, 5)
+	n, _ := syscall.Read(p[0], buf)
+	println(n, string(buf[:n]))
+}
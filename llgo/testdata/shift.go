@@ -0,0 +1,17 @@
+package main
+
+func main() {
+	var x int32 = -8
+	var u uint8 = 0x81
+	for _, n := range []uint{0, 1, 7, 8, 31, 32, 100} {
+		println(n, x<<n, x>>n, u<<n, u>>n)
+	}
+
+	// The count may be wider than the operand.
+	var big uint64 = 1 << 40
+	println(u<<big, x>>big)
+
+	y := 1
+	y <<= 3
+	println(y)
+}
@@ -0,0 +1,35 @@
+package main
+
+func printslice(s []int) {
+	println(len(s))
+	for i := 0; i < len(s); i++ {
+		println(s[i])
+	}
+}
+
+func main() {
+	var s []int
+	s = append(s)
+	printslice(s)
+	s = append(s, 1, 2, 3)
+	printslice(s)
+	other := []int{4, 5, 6, 7, 8}
+	s = append(s, other...)
+	printslice(s)
+	s = append(s[:2], s[5:]...)
+	printslice(s)
+
+	// Growth preserves existing elements.
+	var t []int
+	for i := 0; i < 50; i++ {
+		t = append(t, i, i*2)
+	}
+	println(len(t), t[0], t[49], t[98], t[99])
+
+	var b []byte
+	b = append(b, "hello"...)
+	str := ", world"
+	b = append(b, str...)
+	b = append(b, '!')
+	println(string(b))
+}
@@ -0,0 +1,24 @@
+package main
+
+func main() {
+	s := []int{1, 2, 3}
+	for i := 0; i < len(s); i++ {
+		println(s[i])
+	}
+
+	// Slicing up to the capacity, and at the end, are in range.
+	t := s[:0]
+	println(len(t), cap(t))
+	t = t[0:3]
+	println(len(t), t[2])
+	t = s[3:]
+	println(len(t), cap(t))
+
+	var a [4]int
+	n := 3
+	a[n] = 4
+	println(a[3], len(a[n:]), len(a[:n]))
+
+	str := "abc"
+	println(str[n-1])
+}
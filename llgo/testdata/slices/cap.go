@@ -0,0 +1,16 @@
+package main
+
+func main() {
+	s := make([]int, 3, 10)
+	println(len(s), cap(s))
+	s = s[2:]
+	println(len(s), cap(s))
+
+	var a [5]int
+	println(cap(a))
+	p := &a
+	println(cap(p))
+
+	var n []int
+	println(cap(n))
+}
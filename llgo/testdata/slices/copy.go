@@ -0,0 +1,21 @@
+package main
+
+func main() {
+	a := []int{1, 2, 3, 4, 5}
+	b := make([]int, 3)
+	n := copy(b, a)
+	println(n, b[0], b[1], b[2])
+
+	// Overlapping copy.
+	n = copy(a[1:], a)
+	println(n, a[0], a[1], a[2], a[3], a[4])
+
+	s := []string{"x", "y"}
+	t := []string{"a", "b", "c"}
+	n = copy(t, s)
+	println(n, t[0], t[1], t[2])
+
+	bytes := make([]byte, 3)
+	n = copy(bytes, "hello")
+	println(n, bytes[0], bytes[1], bytes[2])
+}
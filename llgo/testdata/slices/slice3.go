@@ -0,0 +1,41 @@
+package main
+
+type Ints []int
+
+func main() {
+	s := make([]int, 5, 10)
+	for i := 0; i < len(s); i++ {
+		s[i] = i
+	}
+
+	// The three-index form limits the capacity.
+	t := s[1:3:4]
+	println(len(t), cap(t), t[0], t[1])
+	t = s[:2:2]
+	println(len(t), cap(t))
+	t = s[2:]
+	println(len(t), cap(t), t[0])
+
+	// Appending beyond a limited capacity does not overwrite s.
+	u := s[0:1:1]
+	u = append(u, 99)
+	println(s[1], u[1])
+
+	// Arrays and pointers to arrays.
+	var a [6]int
+	a[3] = 3
+	v := a[2:4:5]
+	println(len(v), cap(v), v[1])
+	p := &a
+	v = p[1:]
+	println(len(v), cap(v), v[2])
+	v = p[:3:3]
+	println(len(v), cap(v))
+	v[0] = 7
+	println(a[0])
+
+	// Named slice types are preserved.
+	n := Ints(s)
+	n = n[1:2:3]
+	println(len(n), cap(n), n[0])
+}
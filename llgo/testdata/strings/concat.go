@@ -0,0 +1,32 @@
+package main
+
+type S string
+
+func f(s string) string {
+	println("f", s)
+	return s
+}
+
+func main() {
+	a, b, c := "abc", "", "de"
+	s := a + b + c + "!"
+	println(s, len(s))
+	println(f("x") + f("y") + f("z"))
+	println("[" + b + b + "]")
+	println(b + b + b)
+
+	var t S = "t"
+	t = t + "u" + t
+	println(t)
+
+	var x string
+	for i := 0; i < 4; i++ {
+		x += a + "-" + c
+		x += "|"
+	}
+	println(x, len(x))
+
+	y := "0"
+	y += (a + "1") + ("2" + c)
+	println(y)
+}
@@ -0,0 +1,19 @@
+package main
+
+type S string
+
+const prefix = "llgo"
+const name = prefix + "-" + "compiler"
+const greater = "b" > "a"
+
+const typed S = "typed"
+
+func main() {
+	println(name, len(name))
+	println(greater, "abc" == "ab"+"c", "x" != "x", "a" <= "a", "b" >= "c")
+	s := typed + "!"
+	println(string(s), len(s))
+	if prefix+"x" < "llgoy" {
+		println("less")
+	}
+}
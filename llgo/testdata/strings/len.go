@@ -0,0 +1,15 @@
+package main
+
+const greeting = "hello"
+
+func main() {
+	s := "abc"
+	println(len(s))
+	println(len(greeting), len("world!"))
+	const n = len(greeting) * 2
+	println(n)
+	s += greeting
+	println(len(s))
+	var a [7]int
+	println(len(a), cap(a), len(&a))
+}
@@ -0,0 +1,23 @@
+package main
+
+func main() {
+	const s = "abc"
+	for i := range s + "def" {
+		println(i)
+	}
+	for i, c := range "abc" {
+		println(i, c)
+	}
+	for i, c := range "absolutely fantastic" {
+		println(i, c)
+		if c == 'f' {
+			break
+		}
+	}
+	for i, c := range "héllo, 世界" {
+		println(i, c)
+	}
+	for i, c := range "a\xffb\xe4\xb8" {
+		println(i, c)
+	}
+}
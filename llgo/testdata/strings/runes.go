@@ -0,0 +1,25 @@
+package main
+
+func main() {
+	s := "héllo, 世界"
+	r := []rune(s)
+	println(len(s), len(r))
+	for i := 0; i < len(r); i++ {
+		println(r[i])
+	}
+	println(string(r) == s)
+	r[1] = 'e'
+	println(string(r))
+
+	b := []byte(s)
+	b[0] = 'H'
+	println(string(b), s)
+
+	println(string(65), string(0x4e16))
+	var i int = 0x1F600
+	var u uint8 = 0xe9
+	var neg int32 = -1
+	println(string(i), string(u), string(neg) == "�")
+	println([]rune(string([]rune{0xD800, 0x110000}))[0])
+	println(len(string([]byte{})), len([]rune("")))
+}
@@ -1,9 +1,25 @@
 package main
 
+type S string
+
+const greeting = "hello"
+
 func main() {
 	s := "abcdef"
 	println(s[:])
 	println(s[1:])
 	println(s[:3])
 	println(s[1:4])
+
+	// Slicing at the end, and to an empty string, are in range.
+	println(s[6:], len(s[6:]), len(s[:6]), len(s[3:3]))
+
+	// Substrings may be sliced and indexed further.
+	t := s[2:]
+	println(t[1:3], t[0])
+
+	// Constant and named strings.
+	println(greeting[1:3], greeting[4], "xyz"[1])
+	n := S("named")
+	println(string(n[1:]), n[0])
 }
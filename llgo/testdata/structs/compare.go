@@ -0,0 +1,39 @@
+package main
+
+type S0 struct{}
+
+type S1 struct {
+	a int
+	b string
+}
+
+type S2 struct {
+	S1
+	c float64
+	p *int
+}
+
+func main() {
+	var x, y S0
+	println(x == y, x != y)
+
+	a, b := S1{1, "one"}, S1{1, "one"}
+	println(a == b, a != b)
+	b.b = "two"
+	println(a == b, a != b)
+
+	var n int
+	c := S2{S1{1, "one"}, 2.5, &n}
+	d := c
+	println(c == d)
+	d.p = nil
+	println(c == d)
+	d.p = &n
+	d.S1.a = 2
+	println(c == d, c.S1 == a)
+
+	var s []int
+	println(s == nil, s != nil, nil == s, nil != s)
+	s = make([]int, 0)
+	println(s == nil, s != nil, nil == s, nil != s)
+}
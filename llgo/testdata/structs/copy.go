@@ -0,0 +1,62 @@
+package main
+
+type big struct {
+	a    [64]int
+	name string
+	p    *int
+}
+
+func fill(b *big, n int) {
+	for i := range b.a {
+		b.a[i] = n + i
+	}
+	b.name = "filled"
+	b.p = &b.a[0]
+}
+
+func sum(b big) int {
+	total := 0
+	for _, x := range b.a {
+		total += x
+	}
+	return total
+}
+
+func zero() big {
+	var b big
+	return b
+}
+
+func main() {
+	var x big
+	println(sum(x), x.name == "", x.p == nil)
+
+	fill(&x, 1)
+	y := x
+	x.a[0] = 100
+	println(sum(x), sum(y), y.name, *y.p)
+
+	var z big
+	z = y
+	y.a[63] = 0
+	println(sum(y), sum(z))
+
+	p := new(big)
+	println(sum(*p), p.name == "")
+	*p = z
+	println(sum(*p), p.name)
+	z = zero()
+	println(sum(z), z.name == "")
+
+	arrays := make([][16]int, 3)
+	arrays[1][15] = 7
+	arrays[2] = arrays[1]
+	arrays[1] = [16]int{}
+	println(arrays[0][15], arrays[1][15], arrays[2][15])
+
+	for i := 0; i < 3; i++ {
+		var a [32]int
+		a[i] = i + 1
+		println(a[0], a[1], a[2])
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+type S struct {
+	A int
+	b int `tag:"b"`
+	T
+}
+
+type T struct {
+	c int
+}
+
+func main() {
+	var x interface{} = S{1, 2, T{3}}
+	var y interface{} = S{1, 2, T{3}}
+	println(x == y)
+
+	y = S{1, 3, T{3}}
+	println(x == y)
+
+	s := x.(S)
+	println(s.A, s.b, s.c)
+}
@@ -0,0 +1,37 @@
+package main
+
+// The local types below have the same name, so the struct types that
+// contain them have the same string, but different representations.
+
+func f() {
+	type T struct {
+		a int8
+	}
+	var x struct {
+		t T
+		n int
+	}
+	x.t.a = 1
+	x.n = 2
+	println(x.t.a, x.n)
+}
+
+func g() {
+	type T struct {
+		s string
+		b [4]int
+	}
+	var x struct {
+		t T
+		n int
+	}
+	x.t.s = "g"
+	x.t.b[3] = 3
+	x.n = 4
+	println(x.t.s, x.t.b[3], x.n)
+}
+
+func main() {
+	f()
+	g()
+}
@@ -0,0 +1,80 @@
+package main
+
+type Color int
+
+const (
+	Red Color = iota
+	Green
+	Blue
+)
+
+func classify(x int) {
+	switch x {
+	case 1, 2:
+		println(x, "small")
+	default:
+		println(x, "other")
+	case 10:
+		println(x, "ten")
+		fallthrough
+	case 11:
+		println(x, "ten or eleven")
+	case -1:
+		println(x, "negative")
+		fallthrough
+	case 12:
+		println(x, "twelve or negative")
+	}
+}
+
+func value(n int) int {
+	println("evaluated", n)
+	return n
+}
+
+func main() {
+	classify(-1)
+	for i := 1; i < 13; i++ {
+		if i > 2 && i < 10 {
+			continue
+		}
+		classify(i)
+	}
+	classify(5)
+
+	switch c := Blue; c {
+	case Red:
+		println("red")
+	case Green, Blue:
+		println("green or blue")
+	}
+
+	switch s := "b"; s {
+	case "a":
+		println("a")
+	case "b":
+		println("b")
+		fallthrough
+	default:
+		println("default")
+	}
+
+	// Non-constant cases are evaluated in order, until one matches.
+	switch 2 {
+	case value(1), value(2), value(3):
+		println("matched")
+	case value(4):
+		println("unreachable")
+	}
+
+	x := 5
+	switch {
+	case x > 10:
+		println("big")
+	case x > 3:
+		println("medium")
+		fallthrough
+	case x > 100:
+		println("fell through")
+	}
+}
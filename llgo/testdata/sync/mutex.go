@@ -0,0 +1,34 @@
+package main
+
+import "sync"
+
+type counter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func increment(c *counter, times int, wg *sync.WaitGroup) {
+	for i := 0; i < times; i++ {
+		c.mu.Lock()
+		c.n++
+		c.mu.Unlock()
+	}
+	wg.Done()
+}
+
+func main() {
+	var c counter
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go increment(&c, 1000, &wg)
+	}
+	wg.Wait()
+	println(c.n)
+
+	var l sync.Locker = &c.mu
+	l.Lock()
+	c.n = 0
+	l.Unlock()
+	println(c.n)
+}
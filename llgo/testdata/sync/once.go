@@ -0,0 +1,29 @@
+package main
+
+import "sync"
+
+var calls int
+
+func setup() {
+	calls++
+}
+
+func worker(once *sync.Once, wg *sync.WaitGroup) {
+	once.Do(setup)
+	if calls != 1 {
+		println("setup not complete")
+	}
+	wg.Done()
+}
+
+func main() {
+	var once sync.Once
+	var wg sync.WaitGroup
+	wg.Add(8)
+	for i := 0; i < 8; i++ {
+		go worker(&once, &wg)
+	}
+	wg.Wait()
+	once.Do(setup)
+	println(calls)
+}
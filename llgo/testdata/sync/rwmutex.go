@@ -0,0 +1,60 @@
+package main
+
+import "sync"
+
+type table struct {
+	mu     sync.RWMutex
+	values [8]int
+}
+
+func (t *table) sum() int {
+	t.mu.RLock()
+	s := 0
+	for _, v := range t.values {
+		s += v
+	}
+	t.mu.RUnlock()
+	return s
+}
+
+func writer(t *table, wg *sync.WaitGroup) {
+	for i := 0; i < 100; i++ {
+		t.mu.Lock()
+		// Readers must never observe a partial update.
+		for j := range t.values {
+			t.values[j]++
+		}
+		t.mu.Unlock()
+	}
+	wg.Done()
+}
+
+func reader(t *table, bad *int, wg *sync.WaitGroup) {
+	for i := 0; i < 100; i++ {
+		if t.sum()%len(t.values) != 0 {
+			*bad = *bad + 1
+		}
+	}
+	wg.Done()
+}
+
+func main() {
+	var t table
+	var wg sync.WaitGroup
+	bad := make([]int, 3)
+	wg.Add(5)
+	go writer(&t, &wg)
+	go writer(&t, &wg)
+	for i := range bad {
+		go reader(&t, &bad[i], &wg)
+	}
+	wg.Wait()
+	println(t.sum(), bad[0]+bad[1]+bad[2])
+
+	r := t.mu.RLocker()
+	r.Lock()
+	r.Lock()
+	println(t.values[0])
+	r.Unlock()
+	r.Unlock()
+}
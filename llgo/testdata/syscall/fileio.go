@@ -0,0 +1,25 @@
+package main
+
+import "syscall"
+
+const filename = "/tmp/llgo-syscall-fileio"
+
+func main() {
+	fd, err := syscall.Open(filename, syscall.O_RDWR|syscall.O_CREAT|syscall.O_TRUNC, 0644)
+	println(fd > 2, err == nil)
+	n, err := syscall.Write(fd, []byte("hello, world"))
+	println(n, err == nil)
+	off, err := syscall.Seek(fd, 7, 0)
+	println(off, err == nil)
+	buf := make([]byte, 16)
+	n, err = syscall.Read(fd, buf)
+	println(n, err == nil, string(buf[:n]))
+	println(syscall.Close(fd) == nil)
+	println(syscall.Unlink(filename) == nil)
+
+	_, err = syscall.Open(filename, syscall.O_RDONLY, 0)
+	println(err == syscall.ENOENT, err.Error())
+	err = syscall.Close(-1)
+	println(err == syscall.EBADF, err.Error())
+	println(syscall.Errno(1000).Error())
+}
@@ -0,0 +1,17 @@
+package main
+
+import "syscall"
+
+func main() {
+	pid := syscall.Getpid()
+	println(pid > 0, syscall.Getppid() > 0, pid != syscall.Getppid())
+	println(syscall.Kill(pid, 0) == nil)
+	p := make([]int, 2)
+	println(syscall.Pipe(p) == nil)
+	syscall.Write(p[1], []byte("through a pipe"))
+	buf := make([]byte, 32)
+	n, _ := syscall.Read(p[0], buf)
+	println(string(buf[:n]))
+	syscall.Close(p[0])
+	syscall.Close(p[1])
+}
@@ -0,0 +1,12 @@
+package testpkg
+
+func add(a, b int) int { return a + b }
+
+// Sum returns the sum of its arguments.
+func Sum(values ...int) int {
+	total := 0
+	for _, v := range values {
+		total = add(total, v)
+	}
+	return total
+}
@@ -0,0 +1,15 @@
+package testpkg
+
+import "testing"
+
+func TestAdd(t *testing.T) {
+	if n := add(1, 2); n != 3 {
+		t.Errorf("add(1, 2) = %d", n)
+	}
+}
+
+func Testing(t *testing.T) {
+	t.Fatal("not a test")
+}
+
+func helper(t *testing.T) {}
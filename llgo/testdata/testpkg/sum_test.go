@@ -0,0 +1,18 @@
+package testpkg_test
+
+import (
+	"github.com/axw/llgo/llgo/testdata/testpkg"
+	"testing"
+)
+
+func TestSum(t *testing.T) {
+	if n := testpkg.Sum(1, 2, 3); n != 6 {
+		t.Fatalf("Sum(1, 2, 3) = %d", n)
+	}
+}
+
+func TestSumEmpty(t *testing.T) {
+	if n := testpkg.Sum(); n != 0 {
+		t.Fatalf("Sum() = %d", n)
+	}
+}
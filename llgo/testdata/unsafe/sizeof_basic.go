@@ -0,0 +1,20 @@
+package main
+
+import "unsafe"
+
+func main() {
+	var i int
+	var u uint
+	var p uintptr
+	var s string
+	println(unsafe.Sizeof(i) == unsafe.Sizeof(p))
+	println(unsafe.Sizeof(u) == unsafe.Sizeof(p))
+	println(unsafe.Sizeof(s) == 2*unsafe.Sizeof(p))
+
+	// int is wide enough to hold the product on 64-bit targets.
+	x := 1000000
+	println(x * x)
+
+	y := int32(-5)
+	println(int(y))
+}
@@ -0,0 +1,65 @@
+package main
+
+func count(prefix string, xs ...int) {
+	println(prefix, len(xs), xs == nil)
+	for _, x := range xs {
+		println(x)
+	}
+}
+
+func describe(args ...interface{}) {
+	println(len(args))
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case int:
+			println("int", arg)
+		case string:
+			println("string", arg)
+		case bool:
+			println("bool", arg)
+		case nil:
+			println("nil")
+		}
+	}
+}
+
+type T int
+
+func (t T) add(xs ...int) int {
+	sum := int(t)
+	for _, x := range xs {
+		sum += x
+	}
+	return sum
+}
+
+func deferred() {
+	defer count("deferred", 7, 8)
+	defer count("deferred")
+	println("deferring")
+}
+
+func main() {
+	count("none")
+	count("one", 1)
+	count("three", 1, 2, 3)
+
+	xs := []int{4, 5}
+	count("spread", xs...)
+	xs = nil
+	count("spread nil", xs...)
+	xs = []int{}
+	count("spread empty", xs...)
+
+	describe()
+	describe(1, "two", true, nil)
+	ifaces := []interface{}{3, "four"}
+	describe(ifaces...)
+
+	var t T = 10
+	println(t.add())
+	println(t.add(1, 2, 3))
+	println(t.add([]int{5, 5}...))
+
+	deferred()
+}
@@ -7,3 +7,4 @@ import (
 func TestUnsafePointer(t *testing.T) { checkOutputEqual(t, "unsafe/pointer.go") }
 func TestSizeofStruct(t *testing.T)  { checkOutputEqual(t, "unsafe/sizeof_struct.go") }
 func TestSizeofArray(t *testing.T)   { checkOutputEqual(t, "unsafe/sizeof_array.go") }
+func TestSizeofBasic(t *testing.T)   { checkOutputEqual(t, "unsafe/sizeof_basic.go") }
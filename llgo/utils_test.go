@@ -1,16 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"flag"
 	"fmt"
 	"github.com/axw/gollvm/llvm"
 	"github.com/axw/llgo"
 	"go/build"
+	"go/scanner"
+	"io/ioutil"
+	"os"
 	"os/exec"
-	"path"
+	"path/filepath"
 	"reflect"
 	"sort"
 	"strings"
-	"syscall"
 	"testing"
 	"unsafe"
 )
@@ -22,66 +26,108 @@ func testdata(files ...string) []string {
 	return files
 }
 
+// native selects the native test mode, in which test programs are built
+// as executables, with the system linker, and run as subprocesses, rather
+// than run with the JIT compiler. This exercises the code generator and
+// the C ABI as "llgo build" does.
+var native = flag.Bool(
+	"native", false,
+	"Run test programs as natively linked executables instead of with the JIT compiler")
+
+// helperBitcodeEnv and helperFunctionEnv are the environment variables
+// with which runFunction tells the test binary, run as a subprocess, to
+// run a function in a bitcode file instead of the tests.
+const (
+	helperBitcodeEnv  = "LLGO_TEST_BITCODE"
+	helperFunctionEnv = "LLGO_TEST_FUNCTION"
+)
+
 func init() {
 	llvm.LinkInJIT()
 	llvm.InitializeNativeTarget()
+	if filename := os.Getenv(helperBitcodeEnv); filename != "" {
+		os.Exit(runBitcode(filename, os.Getenv(helperFunctionEnv)))
+	}
+	compiler = llgo.NewCompiler(llgo.CompilerOptions{})
+	if err := installPackages(); err != nil {
+		panic(err)
+	}
+	compiler = llgo.NewCompiler(llgo.CompilerOptions{ImportPaths: importPath})
 }
 
-func readPipe(p int, c chan<- string) {
-	var s string
-	buf := make([]byte, 4096)
-	n, _ := syscall.Read(p, buf)
-	for n > 0 {
-		s += string(buf[:n])
-		n, _ = syscall.Read(p, buf)
+// installPackages compiles the llgo-specific packages, such as os and
+// syscall, into a temporary directory, and adds the directory to the import
+// path. Test programs importing them are linked with them by runFunction.
+func installPackages() error {
+	dir, err := ioutil.TempDir("", "llgo-test")
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{"os", "sync", "syscall", "testing"} {
+		pkg, err := build.Import("github.com/axw/llgo/pkg/"+name, "", 0)
+		if err != nil {
+			return err
+		}
+		files := make([]string, len(pkg.GoFiles))
+		for i, filename := range pkg.GoFiles {
+			files[i] = filepath.Join(pkg.Dir, filename)
+		}
+		if err = installPackage(files, dir, name); err != nil {
+			return err
+		}
 	}
-	c <- s
+	importPath = append(importPath, dir)
+	return nil
+}
+
+// mainArgv and mainEnvp hold the arguments and environment passed to the
+// C main function, keeping them reachable while it runs.
+var mainArgv, mainEnvp []*byte
+
+// withCompilerOptions calls f with the compiler replaced by one created
+// with the specified options.
+func withCompilerOptions(opts llgo.CompilerOptions, f func()) {
+	defer func(c llgo.Compiler) { compiler = c }(compiler)
+	compiler = llgo.NewCompiler(opts)
+	f()
 }
 
 func addExterns(m *llgo.Module) {
-	CharPtr := llvm.PointerType(llvm.Int8Type(), 0)
+	ctx := m.Context()
+	CharPtr := llvm.PointerType(ctx.Int8Type(), 0)
 	fn_type := llvm.FunctionType(
-		llvm.Int32Type(), []llvm.Type{CharPtr}, false)
+		ctx.Int32Type(), []llvm.Type{CharPtr}, false)
 	fflush := llvm.AddFunction(m.Module, "fflush", fn_type)
 	fflush.SetFunctionCallConv(llvm.CCallConv)
 }
 
-func getRuntimeFiles() (files []string, err error) {
-	var pkg *build.Package
-	pkgpath := "github.com/axw/llgo/pkg/runtime"
-	pkg, err = build.Import(pkgpath, "", 0)
-	if err == nil {
-		files = make([]string, len(pkg.GoFiles))
-		for i, filename := range pkg.GoFiles {
-			files[i] = path.Join(pkg.Dir, filename)
-		}
-	}
-	return
-}
-
-func getRuntimeModule() (m llvm.Module, err error) {
-	gofiles, err := getRuntimeFiles()
-	if err == nil {
-		var runtimeModule *llgo.Module
-		runtimeModule, err = compileFiles(gofiles)
-		if runtimeModule != nil {
-			m = runtimeModule.Module
-		}
-	}
-	return
-}
-
 func addRuntime(m *llgo.Module) (err error) {
-	runtimeModule, err := getRuntimeModule()
+	runtimeModule, err := compileRuntime()
 	if err != nil {
 		return
 	}
-	llvm.LinkModules(m.Module, runtimeModule, llvm.LinkerDestroySource)
-	return
+	return llvm.LinkModules(m.Module, runtimeModule.Module, llvm.LinkerDestroySource)
 }
 
+// runFunction links the module with the packages it imports and the
+// runtime, and runs the named function with the JIT compiler, returning
+// the lines of its standard output. The function is run by the test
+// binary in a subprocess, whose output is read through a pipe by os/exec,
+// so that tests run wherever os/exec does, and a program that crashes
+// does not take the tests with it. The module is disposed of.
+//
+// With -native, main is instead run by building and running an
+// executable; see runExecutable.
 func runFunction(m *llgo.Module, name string) (output []string, err error) {
+	defer m.Dispose()
+	if *native && name == "main" {
+		return runExecutable(m)
+	}
 	addExterns(m)
+	err = linkPackages(m)
+	if err != nil {
+		return
+	}
 	err = addRuntime(m)
 	if err != nil {
 		return
@@ -92,58 +138,102 @@ func runFunction(m *llgo.Module, name string) (output []string, err error) {
 		return
 	}
 
-	engine, err := llvm.NewExecutionEngine(m.Module)
+	f, err := ioutil.TempFile("", "llgo-test")
 	if err != nil {
 		return
 	}
-	defer engine.Dispose()
-
-	fn := engine.FindFunction(name)
-	if fn.IsNil() {
-		err = fmt.Errorf("Couldn't find function '%s'", name)
+	f.Close()
+	defer os.Remove(f.Name())
+	if err = writeBitcode(m, f.Name()); err != nil {
 		return
 	}
 
-	// Redirect stdout to a pipe.
-	pipe_fds := make([]int, 2)
-	err = syscall.Pipe(pipe_fds)
+	var stderr bytes.Buffer
+	cmd := exec.Command(os.Args[0])
+	cmd.Env = append(os.Environ(),
+		helperBitcodeEnv+"="+f.Name(),
+		helperFunctionEnv+"="+name)
+	cmd.Stderr = &stderr
+	stdout, err := cmd.Output()
 	if err != nil {
+		err = fmt.Errorf("running %s: %v\n%s", name, err, stderr.Bytes())
 		return
 	}
-	defer syscall.Close(pipe_fds[0])
-	defer syscall.Close(pipe_fds[1])
-	old_stdout, err := syscall.Dup(syscall.Stdout)
+	output = strings.Split(strings.TrimSpace(string(stdout)), "\n")
+	return
+}
+
+// runExecutable builds the module as an executable, as "llgo build" does,
+// and runs it, returning the lines of its standard output.
+func runExecutable(m *llgo.Module) (output []string, err error) {
+	dir, err := ioutil.TempDir("", "llgo-test")
 	if err != nil {
 		return
 	}
-	defer syscall.Close(old_stdout)
-	err = syscall.Dup2(pipe_fds[1], syscall.Stdout)
+	defer os.RemoveAll(dir)
+	exe := filepath.Join(dir, "main")
+	if err = buildExecutable(m, exe); err != nil {
+		return
+	}
+	var stderr bytes.Buffer
+	cmd := exec.Command(exe)
+	cmd.Stderr = &stderr
+	stdout, err := cmd.Output()
 	if err != nil {
+		err = fmt.Errorf("running %s: %v\n%s", exe, err, stderr.Bytes())
 		return
 	}
-	defer syscall.Dup2(old_stdout, syscall.Stdout)
+	output = strings.Split(strings.TrimSpace(string(stdout)), "\n")
+	return
+}
 
-	c := make(chan string)
-	go readPipe(pipe_fds[0], c)
+// runBitcode runs the named function in the bitcode file with the JIT
+// compiler, in the test binary run as a subprocess by runFunction, and
+// returns the exit status of the subprocess: that returned by the C main
+// function, or 0 for other functions, which take no arguments. The C
+// main function is called with the program name as its only argument,
+// and the test's environment.
+func runBitcode(filename, name string) int {
+	m, err := llvm.ParseBitcodeFile(filename)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	engine, err := llvm.NewExecutionEngine(m)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer engine.Dispose()
 
+	fn := engine.FindFunction(name)
+	if fn.IsNil() {
+		fmt.Fprintf(os.Stderr, "Couldn't find function '%s'\n", name)
+		return 1
+	}
 	exec_args := []llvm.GenericValue{}
+	if name == "main" {
+		mainArgv = cstrings([]string{"main"})
+		mainEnvp = cstrings(os.Environ())
+		argc := llvm.NewGenericValueFromInt(m.Context().Int32Type(), 1, false)
+		argv := llvm.NewGenericValueFromPointer(unsafe.Pointer(&mainArgv[0]))
+		envp := llvm.NewGenericValueFromPointer(unsafe.Pointer(&mainEnvp[0]))
+		exec_args = []llvm.GenericValue{argc, argv, envp}
+	}
 	engine.RunStaticConstructors()
-	engine.RunFunction(fn, exec_args)
-	defer engine.RunStaticDestructors()
+	result := engine.RunFunction(fn, exec_args)
+	engine.RunStaticDestructors()
 
-	// Call fflush to flush stdio (printf), then sync and close the write
-	// end of the pipe.
+	// Call fflush to flush stdio (printf) before exiting, which the Go
+	// runtime does without doing so.
 	fflush := engine.FindFunction("fflush")
 	ptr0 := unsafe.Pointer(uintptr(0))
 	exec_args = []llvm.GenericValue{llvm.NewGenericValueFromPointer(ptr0)}
 	engine.RunFunction(fflush, exec_args)
-	syscall.Fsync(pipe_fds[1])
-	syscall.Close(pipe_fds[1])
-	syscall.Close(syscall.Stdout)
-
-	output_str := <-c
-	output = strings.Split(strings.TrimSpace(output_str), "\n")
-	return
+	if name == "main" {
+		return int(int32(result.Int(true)))
+	}
+	return 0
 }
 
 func checkStringsEqual(out, expectedOut []string) error {
@@ -179,7 +269,7 @@ func runAndCheckMain(check func(a, b []string) error, files []string) error {
 
 	// Now compile to and interpret the LLVM bitcode, comparing the output to
 	// the output of "go run" above.
-	m, err := compileFiles(files)
+	m, err := compileFiles(files, "")
 	if err != nil {
 		return err
 	}
@@ -209,4 +299,22 @@ func checkOutputEqualUnordered(t *testing.T, files ...string) {
 	}
 }
 
+// checkCompileErrors compiles the specified file using llgo, and checks
+// that compilation fails with errors reported at the specified lines.
+func checkCompileErrors(t *testing.T, file string, lines ...int) {
+	_, err := compileFiles(testdata(file), "")
+	errors, ok := err.(scanner.ErrorList)
+	if !ok {
+		t.Fatalf("expected compile errors, got: %v", err)
+	}
+	if len(errors) != len(lines) {
+		t.Fatalf("expected %d errors, got %d: %v", len(lines), len(errors), err)
+	}
+	for i, e := range errors {
+		if e.Pos.Line != lines[i] {
+			t.Errorf("expected error at line %d, got: %v", lines[i], e)
+		}
+	}
+}
+
 // vim: set ft=go:
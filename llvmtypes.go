@@ -23,24 +23,61 @@ SOFTWARE.
 package llgo
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/axw/gollvm/llvm"
 	"github.com/axw/llgo/types"
 	"go/ast"
 	"reflect"
+	"sync"
 )
 
+// targetData is an llvm.TargetData that creates types in the context of
+// the module being compiled, rather than in the global context.
+type targetData struct {
+	llvm.TargetData
+	ctx llvm.Context
+}
+
+// IntPtrType returns an integer type the size of a pointer.
+func (td targetData) IntPtrType() llvm.Type {
+	return td.ctx.IntType(td.PointerSize() * 8)
+}
+
+// LLVMTypeMap maps Go types to the LLVM types that represent them in an
+// LLVM context. A compiler's compilations share its map, which is safe for
+// concurrent use: it creates types in the context only while its mutex is
+// held.
+//
+// Types are looked up by identity first. A type not yet in the map is
+// then canonicalised: if an equivalent type, with the same LLVM
+// representation, has already been mapped, its LLVM type is reused. The
+// canonical types are bucketed by a structural hash.
 type LLVMTypeMap struct {
-	module llvm.Module
-	target llvm.TargetData
-	types  map[string]llvm.Type // compile-time LLVM type
+	ctx       llvm.Context
+	target    targetData
+	mu        sync.Mutex               // guards types and canonical
+	types     map[types.Type]llvm.Type // compile-time LLVM type
+	canonical map[uint32][]types.Type  // canonical types, by typeHash
 }
 
+// TypeMap extends LLVMTypeMap with the runtime type descriptors of Go
+// types, which are defined in the module being compiled. Unlike the
+// LLVMTypeMap it extends, a TypeMap belongs to a single compilation, and is
+// not safe for concurrent use.
 type TypeMap struct {
 	*LLVMTypeMap
+	module    llvm.Module
 	types     map[types.Type]llvm.Value // runtime/reflect type representation
 	expr      map[ast.Expr]types.Type
 	functions *FunctionCache
+	pkgpath   string
+
+	// pending holds the runtime type descriptors declared by ToRuntime
+	// and not yet defined, in the order they were declared; declared
+	// maps the names of those that are named to them.
+	pending  []*runtimeTypeDecl
+	declared map[string]*runtimeTypeDecl
 
 	runtimeType,
 	runtimeCommonType,
@@ -53,90 +90,372 @@ type TypeMap struct {
 	runtimePtrType,
 	runtimeSliceType,
 	runtimeStructType llvm.Type
-
-	hashAlgFunctionType,
-	equalAlgFunctionType,
-	printAlgFunctionType,
-	copyAlgFunctionType llvm.Type
 }
 
-func NewLLVMTypeMap(module llvm.Module, target llvm.TargetData) *LLVMTypeMap {
-	tm := &LLVMTypeMap{module: module, target: target}
-	tm.types = make(map[string]llvm.Type)
+func NewLLVMTypeMap(ctx llvm.Context, target llvm.TargetData) *LLVMTypeMap {
+	tm := &LLVMTypeMap{ctx: ctx, target: targetData{target, ctx}}
+	tm.types = make(map[types.Type]llvm.Type)
+	tm.canonical = make(map[uint32][]types.Type)
 	return tm
 }
 
-func NewTypeMap(llvmtm *LLVMTypeMap, exprTypes map[ast.Expr]types.Type, c *FunctionCache) *TypeMap {
-	tm := &TypeMap{LLVMTypeMap: llvmtm}
+func NewTypeMap(llvmtm *LLVMTypeMap, module llvm.Module, pkgpath string, exprTypes map[ast.Expr]types.Type, c *FunctionCache) *TypeMap {
+	tm := &TypeMap{LLVMTypeMap: llvmtm, module: module}
 	tm.types = make(map[types.Type]llvm.Value)
+	tm.declared = make(map[string]*runtimeTypeDecl)
 	tm.expr = exprTypes
 	tm.functions = c
-
-	// Load "reflect.go", and generate LLVM types for the runtime type
-	// structures.
-	pkg, err := parseReflect()
-	if err != nil {
-		panic(err) // FIXME return err
-	}
-	objToLLVMType := func(name string) llvm.Type {
-		obj := pkg.Scope.Lookup(name)
-		return tm.ToLLVM(obj.Type.(types.Type))
-	}
-	tm.runtimeType = objToLLVMType("runtimeType")
-	tm.runtimeCommonType = objToLLVMType("commonType")
-	tm.runtimeUncommonType = objToLLVMType("uncommonType")
-	tm.runtimeArrayType = objToLLVMType("arrayType")
-	tm.runtimeChanType = objToLLVMType("chanType")
-	tm.runtimeFuncType = objToLLVMType("funcType")
-	tm.runtimeInterfaceType = objToLLVMType("interfaceType")
-	tm.runtimeMapType = objToLLVMType("mapType")
-	tm.runtimePtrType = objToLLVMType("ptrType")
-	tm.runtimeSliceType = objToLLVMType("sliceType")
-	tm.runtimeStructType = objToLLVMType("structType")
-
-	// Types for algorithms. See 'runtime/runtime.h'.
-	uintptrType := tm.target.IntPtrType()
-	voidPtrType := llvm.PointerType(llvm.Int8Type(), 0)
-	boolType := llvm.Int1Type()
-
-	// Create runtime algorithm function types.
-	params := []llvm.Type{uintptrType, voidPtrType}
-	tm.hashAlgFunctionType = llvm.FunctionType(uintptrType, params, false)
-	params = []llvm.Type{uintptrType, uintptrType, uintptrType}
-	tm.equalAlgFunctionType = llvm.FunctionType(boolType, params, false)
-	params = []llvm.Type{uintptrType, voidPtrType}
-	tm.printAlgFunctionType = llvm.FunctionType(llvm.VoidType(), params, false)
-	params = []llvm.Type{uintptrType, voidPtrType, voidPtrType}
-	tm.copyAlgFunctionType = llvm.FunctionType(llvm.VoidType(), params, false)
+	tm.pkgpath = pkgpath
+
+	// Generate LLVM types for the runtime type structures.
+	tm.runtimeType = tm.ToLLVM(runtimeTypes.runtimeType)
+	tm.runtimeCommonType = tm.ToLLVM(runtimeTypes.commonType)
+	tm.runtimeUncommonType = tm.ToLLVM(runtimeTypes.uncommonType)
+	tm.runtimeArrayType = tm.ToLLVM(runtimeTypes.arrayType)
+	tm.runtimeChanType = tm.ToLLVM(runtimeTypes.chanType)
+	tm.runtimeFuncType = tm.ToLLVM(runtimeTypes.funcType)
+	tm.runtimeInterfaceType = tm.ToLLVM(runtimeTypes.interfaceType)
+	tm.runtimeMapType = tm.ToLLVM(runtimeTypes.mapType)
+	tm.runtimePtrType = tm.ToLLVM(runtimeTypes.ptrType)
+	tm.runtimeSliceType = tm.ToLLVM(runtimeTypes.sliceType)
+	tm.runtimeStructType = tm.ToLLVM(runtimeTypes.structType)
 
 	return tm
 }
 
+// typeError is raised with panic by the type maps when a type cannot be
+// represented. compileDecl recovers it, reporting an error at the
+// declaration being compiled; Compile reports one raised outside of any
+// declaration at the package clause.
+type typeError struct {
+	typ types.Type
+	msg string
+}
+
+func (e typeError) Error() string {
+	return fmt.Sprintf("%s: %s", e.msg, typeString(e.typ))
+}
+
+// ToLLVM returns the LLVM type used to represent values of type t. If t
+// cannot be represented, the compilation of the current declaration is
+// abandoned, and Compile reports an error at its position.
 func (tm *LLVMTypeMap) ToLLVM(t types.Type) llvm.Type {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	return tm.toLLVM(t)
+}
+
+// toLLVM is ToLLVM for callers that hold the map's mutex.
+func (tm *LLVMTypeMap) toLLVM(t types.Type) llvm.Type {
 	t = types.Underlying(t)
-	tstr := t.String()
-	lt, ok := tm.types[tstr]
-	if !ok {
-		lt = tm.makeLLVMType(t)
-		if lt.IsNil() {
-			panic(fmt.Sprint("Failed to create LLVM type for: ", t))
+	if lt, ok := tm.types[t]; ok {
+		return lt
+	}
+	hash := typeHash(t, 0)
+	for _, u := range tm.canonical[hash] {
+		if equivalentTypes(t, u, nil) {
+			lt := tm.types[u]
+			tm.types[t] = lt
+			return lt
 		}
-		tm.types[tstr] = lt
 	}
+	lt := tm.makeLLVMType(t)
+	if lt.IsNil() {
+		panic(typeError{t, "cannot represent type"})
+	}
+	tm.types[t] = lt
+	tm.canonical[hash] = append(tm.canonical[hash], t)
 	return lt
 }
 
+// maxTypeHashDepth limits the depth to which typeHash descends into a
+// type's components, so that it terminates for recursive types.
+const maxTypeHashDepth = 4
+
+// typeHash returns a hash of the structure of t, such that equivalent
+// types, as determined by equivalentTypes, have equal hashes. Named types
+// hash as their underlying types.
+func typeHash(t types.Type, depth int) uint32 {
+	if depth > maxTypeHashDepth {
+		return 0
+	}
+	depth++
+	const prime = 16777619
+	mix := func(h, x uint32) uint32 { return (h ^ x) * prime }
+	switch t := t.(type) {
+	case *types.Name:
+		if t.Underlying == nil {
+			return 0
+		}
+		return typeHash(t.Underlying, depth-1)
+	case *types.Basic:
+		return mix(1, uint32(t.Kind))
+	case *types.Array:
+		return mix(mix(2, uint32(t.Len)), typeHash(t.Elt, depth))
+	case *types.Slice:
+		return mix(3, typeHash(t.Elt, depth))
+	case *types.Struct:
+		h := mix(4, uint32(len(t.Fields)))
+		for _, f := range t.Fields {
+			h = mix(h, typeHash(f.Type.(types.Type), depth))
+		}
+		return h
+	case *types.Pointer:
+		return mix(5, typeHash(t.Base, depth))
+	case *types.Func:
+		h := mix(6, uint32(len(t.Params)))
+		if t.Recv != nil {
+			h = mix(h, typeHash(t.Recv.Type.(types.Type), depth))
+		}
+		for _, p := range t.Params {
+			h = mix(h, typeHash(p.Type.(types.Type), depth))
+		}
+		for _, r := range t.Results {
+			h = mix(h, typeHash(r.Type.(types.Type), depth))
+		}
+		return h
+	case *types.Interface:
+		return 7
+	case *types.Map:
+		return 8
+	case *types.Chan:
+		return 9
+	}
+	return 0
+}
+
+// typePair is a pair of types assumed to be equivalent while comparing
+// recursive types.
+type typePair struct {
+	x, y *types.Name
+}
+
+// equivalentTypes reports whether x and y are represented by the same
+// LLVM type. Unlike types.Identical, named types are compared by their
+// underlying types, and the names of struct fields and of function
+// parameters are ignored, as they do not affect the representation.
+// Types with identical strings from different packages, which the type
+// map once confused, are therefore only equivalent if their structures
+// are. All interfaces, maps and channels share a representation.
+//
+// assumed holds the named types already being compared, which are
+// assumed to be equivalent, so that the comparison of recursive types
+// terminates.
+func equivalentTypes(x, y types.Type, assumed map[typePair]bool) bool {
+	if x == y {
+		return true
+	}
+	xn, xname := x.(*types.Name)
+	yn, yname := y.(*types.Name)
+	if xname || yname {
+		if xname && yname {
+			if xn.Obj == yn.Obj {
+				return true
+			}
+			pair := typePair{xn, yn}
+			if assumed[pair] {
+				return true
+			}
+			if assumed == nil {
+				assumed = make(map[typePair]bool)
+			}
+			assumed[pair] = true
+		}
+		if xname {
+			x = xn.Underlying
+		}
+		if yname {
+			y = yn.Underlying
+		}
+		if x == nil || y == nil {
+			return false
+		}
+		return equivalentTypes(x, y, assumed)
+	}
+
+	equivalentLists := func(a, b types.ObjList) bool {
+		if len(a) != len(b) {
+			return false
+		}
+		for i := range a {
+			if !equivalentTypes(a[i].Type.(types.Type), b[i].Type.(types.Type), assumed) {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch x := x.(type) {
+	case *types.Basic:
+		y, ok := y.(*types.Basic)
+		return ok && x.Kind == y.Kind
+	case *types.Array:
+		y, ok := y.(*types.Array)
+		return ok && x.Len == y.Len && equivalentTypes(x.Elt, y.Elt, assumed)
+	case *types.Slice:
+		y, ok := y.(*types.Slice)
+		return ok && equivalentTypes(x.Elt, y.Elt, assumed)
+	case *types.Struct:
+		y, ok := y.(*types.Struct)
+		return ok && equivalentLists(x.Fields, y.Fields)
+	case *types.Pointer:
+		y, ok := y.(*types.Pointer)
+		return ok && equivalentTypes(x.Base, y.Base, assumed)
+	case *types.Func:
+		y, ok := y.(*types.Func)
+		if !ok || (x.Recv == nil) != (y.Recv == nil) {
+			return false
+		}
+		if x.Recv != nil {
+			xrecv, yrecv := x.Recv.Type.(types.Type), y.Recv.Type.(types.Type)
+			if !equivalentTypes(xrecv, yrecv, assumed) {
+				return false
+			}
+		}
+		return equivalentLists(x.Params, y.Params) && equivalentLists(x.Results, y.Results)
+	case *types.Interface:
+		_, ok := y.(*types.Interface)
+		return ok
+	case *types.Map:
+		_, ok := y.(*types.Map)
+		return ok
+	case *types.Chan:
+		_, ok := y.(*types.Chan)
+		return ok
+	}
+	return false
+}
+
+// runtimeTypeDecl is a runtime type descriptor that has been declared by
+// ToRuntime, but not yet defined by EmitRuntimeTypes.
+type runtimeTypeDecl struct {
+	global llvm.Value   // the declaration, replaced by the definition
+	types  []types.Type // the identical types declared with it
+}
+
+// ToRuntime returns a pointer to the runtime type descriptor for t.
+//
+// The descriptor is only declared; EmitRuntimeTypes defines it once the
+// package's code has been compiled. Descriptors are therefore created
+// only for the types that the code needs them for, such as those boxed
+// in interfaces, asserted to, or passed to the runtime's map, channel
+// and slice functions, and for the types they refer to. If t cannot be
+// represented, the compilation of the current declaration is abandoned,
+// and Compile reports an error at its position.
 func (tm *TypeMap) ToRuntime(t types.Type) llvm.Value {
-	t = types.Underlying(t)
-	r, ok := tm.types[t]
-	if !ok {
-		_, r = tm.makeRuntimeType(t)
-		if r.IsNil() {
-			panic(fmt.Sprint("Failed to create runtime type for: ", t))
+	// Named types have their own runtime type, carrying the type's name
+	// and methods, distinct from that of the underlying type.
+	if _, isname := t.(*types.Name); !isname {
+		t = types.Underlying(t)
+	}
+	if r, ok := tm.types[t]; ok {
+		return r
+	}
+
+	// Report types that cannot be represented where they are used, rather
+	// than when their descriptors are defined.
+	tm.ToLLVM(t)
+
+	// Descriptors are named after the type, and given linkonce_odr
+	// linkage, so that identical types in different packages share a
+	// single descriptor once linked.
+	name := tm.runtimeTypeName(t)
+	if name != "" {
+		if d, ok := tm.declared[name]; ok {
+			d.types = append(d.types, t)
+			tm.types[t] = d.global
+			return d.global
+		}
+		if global := tm.module.NamedGlobal(name); !global.IsNil() {
+			r := llvm.ConstBitCast(global, llvm.PointerType(tm.runtimeType, 0))
+			tm.types[t] = r
+			return r
+		}
+	}
+	d := &runtimeTypeDecl{llvm.AddGlobal(tm.module, tm.runtimeType, name), []types.Type{t}}
+	tm.pending = append(tm.pending, d)
+	if name != "" {
+		tm.declared[name] = d
+	}
+	tm.types[t] = d.global
+	return d.global
+}
+
+// EmitRuntimeTypes defines the runtime type descriptors declared by
+// ToRuntime. Defining a descriptor may declare those of the types it
+// refers to, which are defined in turn. If a descriptor cannot be
+// created, Compile reports an error at the package clause.
+func (tm *TypeMap) EmitRuntimeTypes() {
+	for len(tm.pending) > 0 {
+		d := tm.pending[0]
+		tm.pending = tm.pending[1:]
+		global, ptr := tm.makeRuntimeType(d.types[0])
+		if ptr.IsNil() {
+			panic(typeError{d.types[0], "cannot create runtime type"})
+		}
+		name := d.global.Name()
+		d.global.ReplaceAllUsesWith(ptr)
+		d.global.EraseFromParentAsGlobal()
+		for _, t := range d.types {
+			tm.types[t] = ptr
 		}
-		tm.types[t] = r
+		if name != "" {
+			delete(tm.declared, name)
+			global.SetName(name)
+			global.SetLinkage(llvm.LinkOnceODRLinkage)
+		} else {
+			global.SetLinkage(llvm.PrivateLinkage)
+		}
+	}
+}
+
+// runtimeTypeName returns the symbol name of the runtime type descriptor
+// for t, which is "type." followed by the type's string, with named types
+// and unexported field and method names qualified by the import paths of
+// their packages. Types that are identical, and only those, have the same
+// name. Types referring to named types declared inside functions are local
+// to the package, and have no symbol name.
+func (tm *TypeMap) runtimeTypeName(t types.Type) string {
+	q := &runtimeTypeQualifier{tm: tm}
+	var buf bytes.Buffer
+	buf.WriteString("type.")
+	writeType(&buf, t, q)
+	if q.local {
+		return ""
+	}
+	return buf.String()
+}
+
+// runtimeTypeQualifier qualifies the names in runtime type descriptors'
+// symbol names. It records whether a type refers to a local named type.
+type runtimeTypeQualifier struct {
+	tm    *TypeMap
+	local bool
+}
+
+func (q *runtimeTypeQualifier) qualifyType(obj *ast.Object) string {
+	if types.Universe.Lookup(obj.Name) == obj {
+		return obj.Name
+	}
+	path, ok := q.tm.functions.pathmap[obj]
+	if !ok {
+		q.local = true
+		return obj.Name
+	}
+	return path + "." + obj.Name
+}
+
+func (q *runtimeTypeQualifier) qualifyName(pkg, name string) string {
+	return q.tm.pkgPath(pkg) + "." + name
+}
+
+// pkgPath returns the import path pkg, of a struct or interface type's
+// package, or the import path of the package being compiled if pkg is "".
+func (tm *TypeMap) pkgPath(pkg string) string {
+	if pkg == "" {
+		return tm.pkgpath
 	}
-	return r
+	return pkg
 }
 
 func (tm *LLVMTypeMap) makeLLVMType(t types.Type) llvm.Type {
@@ -174,20 +493,21 @@ func (tm *LLVMTypeMap) badLLVMType(b *types.Bad) llvm.Type {
 func (tm *LLVMTypeMap) basicLLVMType(b *types.Basic) llvm.Type {
 	switch b.Kind {
 	case types.BoolKind:
-		return llvm.Int1Type()
+		return tm.ctx.Int1Type()
 	case types.Int8Kind, types.Uint8Kind:
-		return llvm.Int8Type()
+		return tm.ctx.Int8Type()
 	case types.Int16Kind, types.Uint16Kind:
-		return llvm.Int16Type()
-	case types.Int32Kind, types.Uint32Kind, types.UintKind, types.IntKind:
-		return llvm.Int32Type()
+		return tm.ctx.Int16Type()
+	case types.Int32Kind, types.Uint32Kind:
+		return tm.ctx.Int32Type()
 	case types.Int64Kind, types.Uint64Kind:
-		return llvm.Int64Type()
+		return tm.ctx.Int64Type()
 	case types.Float32Kind:
-		return llvm.FloatType()
+		return tm.ctx.FloatType()
 	case types.Float64Kind:
-		return llvm.DoubleType()
-	case types.UnsafePointerKind, types.UintptrKind:
+		return tm.ctx.DoubleType()
+	case types.IntKind, types.UintKind, types.UnsafePointerKind, types.UintptrKind:
+		// int and uint are the same size as a pointer.
 		return tm.target.IntPtrType()
 	//case Complex64: TODO
 	//case Complex128:
@@ -195,75 +515,88 @@ func (tm *LLVMTypeMap) basicLLVMType(b *types.Basic) llvm.Type {
 	//case UntypedFloat:
 	//case UntypedComplex:
 	case types.StringKind:
-		i8ptr := llvm.PointerType(llvm.Int8Type(), 0)
-		elements := []llvm.Type{i8ptr, llvm.Int32Type()}
-		return llvm.StructType(elements, false)
+		i8ptr := llvm.PointerType(tm.ctx.Int8Type(), 0)
+		elements := []llvm.Type{i8ptr, tm.target.IntPtrType()}
+		return tm.ctx.StructType(elements, false)
 	}
-	panic(fmt.Sprint("unhandled kind: ", b.Kind))
+	panic(typeError{b, "unhandled basic type"})
 }
 
 func (tm *LLVMTypeMap) arrayLLVMType(a *types.Array) llvm.Type {
-	return llvm.ArrayType(tm.ToLLVM(a.Elt), int(a.Len))
+	return llvm.ArrayType(tm.toLLVM(a.Elt), int(a.Len))
 }
 
 func (tm *LLVMTypeMap) sliceLLVMType(s *types.Slice) llvm.Type {
 	elements := []llvm.Type{
-		llvm.PointerType(tm.ToLLVM(s.Elt), 0),
-		tm.ToLLVM(types.Uint),
-		tm.ToLLVM(types.Uint),
+		llvm.PointerType(tm.toLLVM(s.Elt), 0),
+		tm.toLLVM(types.Uint),
+		tm.toLLVM(types.Uint),
 	}
-	return llvm.StructType(elements, false)
+	return tm.ctx.StructType(elements, false)
 }
 
 func (tm *LLVMTypeMap) structLLVMType(s *types.Struct) llvm.Type {
 	// Types may be circular, so we need to first create an empty
 	// struct type, then fill in its body after visiting its
 	// members.
-	sstr := s.String()
-	typ, ok := tm.types[sstr]
-	if !ok {
-		typ = llvm.GlobalContext().StructCreateNamed("")
-		tm.types[sstr] = typ
-		elements := make([]llvm.Type, len(s.Fields))
-		for i, f := range s.Fields {
-			ft := f.Type.(types.Type)
-			elements[i] = tm.ToLLVM(ft)
-		}
-		typ.StructSetBody(elements, false)
+	typ := tm.ctx.StructCreateNamed("")
+	tm.types[s] = typ
+	elements := make([]llvm.Type, len(s.Fields))
+	for i, f := range s.Fields {
+		ft := f.Type.(types.Type)
+		elements[i] = tm.toLLVM(ft)
 	}
+	typ.StructSetBody(elements, false)
 	return typ
 }
 
 func (tm *LLVMTypeMap) pointerLLVMType(p *types.Pointer) llvm.Type {
-	return llvm.PointerType(tm.ToLLVM(p.Base), 0)
+	return llvm.PointerType(tm.toLLVM(p.Base), 0)
 }
 
+// funcLLVMType returns the LLVM type of a function value, which is a pair
+// of function pointer and context pointer. The context pointer is nil for
+// top-level functions, and points to the captured variables for closures.
 func (tm *LLVMTypeMap) funcLLVMType(f *types.Func) llvm.Type {
+	fnptr_type := tm.makeRawFuncLLVMType(f)
+	ctx_type := llvm.PointerType(tm.ctx.Int8Type(), 0)
+	return tm.ctx.StructType([]llvm.Type{fnptr_type, ctx_type}, false)
+}
+
+// rawFuncLLVMType returns the LLVM function pointer type for the
+// specified function signature.
+func (tm *LLVMTypeMap) rawFuncLLVMType(f *types.Func) llvm.Type {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	return tm.makeRawFuncLLVMType(f)
+}
+
+func (tm *LLVMTypeMap) makeRawFuncLLVMType(f *types.Func) llvm.Type {
 	param_types := make([]llvm.Type, 0)
 
 	// Add receiver parameter.
 	if f.Recv != nil {
 		recv_type := f.Recv.Type.(types.Type)
-		param_types = append(param_types, tm.ToLLVM(recv_type))
+		param_types = append(param_types, tm.toLLVM(recv_type))
 	}
 
 	for _, param := range f.Params {
 		param_type := param.Type.(types.Type)
-		param_types = append(param_types, tm.ToLLVM(param_type))
+		param_types = append(param_types, tm.toLLVM(param_type))
 	}
 
 	var return_type llvm.Type
 	switch len(f.Results) {
 	case 0:
-		return_type = llvm.VoidType()
+		return_type = tm.ctx.VoidType()
 	case 1:
-		return_type = tm.ToLLVM(f.Results[0].Type.(types.Type))
+		return_type = tm.toLLVM(f.Results[0].Type.(types.Type))
 	default:
 		elements := make([]llvm.Type, len(f.Results))
 		for i, result := range f.Results {
-			elements[i] = tm.ToLLVM(result.Type.(types.Type))
+			elements[i] = tm.toLLVM(result.Type.(types.Type))
 		}
-		return_type = llvm.StructType(elements, false)
+		return_type = tm.ctx.StructType(elements, false)
 	}
 
 	fn_type := llvm.FunctionType(return_type, param_types, false)
@@ -271,45 +604,27 @@ func (tm *LLVMTypeMap) funcLLVMType(f *types.Func) llvm.Type {
 }
 
 func (tm *LLVMTypeMap) interfaceLLVMType(i *types.Interface) llvm.Type {
-	valptr_type := llvm.PointerType(llvm.Int8Type(), 0)
-	typptr_type := valptr_type // runtimeCommonType may not be defined yet
-	elements := make([]llvm.Type, 2+len(i.Methods))
-	elements[0] = valptr_type // value
-	elements[1] = typptr_type // type
-	for n, m := range i.Methods {
-		// Add an opaque pointer parameter to the function for the
-		// struct pointer.
-		fntype := m.Type.(*types.Func)
-		receiver_type := &types.Pointer{Base: types.Int8}
-		fntype.Recv = ast.NewObj(ast.Var, "")
-		fntype.Recv.Type = receiver_type
-		elements[n+2] = tm.ToLLVM(fntype)
-	}
-	return llvm.StructType(elements, false)
+	// All interfaces are a pair of an itab or runtime type pointer,
+	// and a data pointer; see interfaces.go.
+	i8ptr := llvm.PointerType(tm.ctx.Int8Type(), 0)
+	return tm.ctx.StructType([]llvm.Type{i8ptr, i8ptr}, false)
 }
 
 func (tm *LLVMTypeMap) mapLLVMType(m *types.Map) llvm.Type {
-	// XXX This map type will change in the future, when I get around to it.
-	// At the moment, it's representing a really dumb singly linked list.
-	list_type := llvm.GlobalContext().StructCreateNamed("")
-	list_ptr_type := llvm.PointerType(list_type, 0)
-	size_type := llvm.Int32Type()
-	element_types := []llvm.Type{size_type, list_type}
-	typ := llvm.StructType(element_types, false)
-	tm.types[m.String()] = typ
-
-	list_element_types := []llvm.Type{
-		list_ptr_type, tm.ToLLVM(m.Key), tm.ToLLVM(m.Elt)}
-	list_type.StructSetBody(list_element_types, false)
-	return typ
+	// A map is a pointer to a runtime hash table (see runtime.map_). We
+	// only expose the first field, which holds the number of entries, so
+	// that len(m) may be computed without a function call.
+	elements := []llvm.Type{tm.toLLVM(types.Int)}
+	return llvm.PointerType(tm.ctx.StructType(elements, false), 0)
 }
 
 func (tm *LLVMTypeMap) chanLLVMType(c *types.Chan) llvm.Type {
-	panic("unimplemented")
+	// Channels are pointers to runtime structures.
+	return llvm.PointerType(tm.ctx.Int8Type(), 0)
 }
 
 func (tm *LLVMTypeMap) nameLLVMType(n *types.Name) llvm.Type {
-	return tm.ToLLVM(n.Underlying)
+	return tm.toLLVM(n.Underlying)
 }
 
 ///////////////////////////////////////////////////////////////////////////////
@@ -343,19 +658,40 @@ func (tm *TypeMap) makeRuntimeType(t types.Type) (global, ptr llvm.Value) {
 }
 
 func (tm *TypeMap) makeAlgorithmTable(t types.Type) llvm.Value {
-	// TODO set these to actual functions.
-	hashAlg := llvm.ConstNull(llvm.PointerType(tm.hashAlgFunctionType, 0))
-	printAlg := llvm.ConstNull(llvm.PointerType(tm.printAlgFunctionType, 0))
-	copyAlg := llvm.ConstNull(llvm.PointerType(tm.copyAlgFunctionType, 0))
+	// Strings and interfaces have their own algorithms; all other types
+	// are treated as plain memory.
+	//
+	// TODO generate algorithms for structs and arrays containing strings
+	// or interfaces.
+	//
+	// Slices, maps and functions are not comparable, so their hash and
+	// equal algorithms panic if a value is compared through an interface.
+	prefix, eqprefix := "mem", "mem"
+	switch t := types.Underlying(t).(type) {
+	case *types.Basic:
+		if t.Kind == types.StringKind {
+			prefix, eqprefix = "str", "str"
+		}
+	case *types.Interface:
+		prefix, eqprefix = "inter", "inter"
+		if len(t.Methods) == 0 {
+			prefix, eqprefix = "nilinter", "nilinter"
+		}
+	case *types.Slice, *types.Map, *types.Func:
+		eqprefix = "no"
+	}
 
-	equalAlg := tm.functions.NamedFunction("runtime.memequal", "func f(uintptr, unsafe.Pointer, unsafe.Pointer) bool")
+	hashAlg := tm.functions.NamedFunction("runtime."+eqprefix+"hash", "func f(uintptr, unsafe.Pointer) uintptr")
+	equalAlg := tm.functions.NamedFunction("runtime."+eqprefix+"equal", "func f(uintptr, unsafe.Pointer, unsafe.Pointer) bool")
+	printAlg := tm.functions.NamedFunction("runtime."+prefix+"print", "func f(uintptr, unsafe.Pointer)")
+	copyAlg := tm.functions.NamedFunction("runtime.memcopy", "func f(uintptr, unsafe.Pointer, unsafe.Pointer)")
 	elems := []llvm.Value{hashAlg, equalAlg, printAlg, copyAlg}
-	return llvm.ConstStruct(elems, false)
+	return tm.ctx.ConstStruct(elems, false)
 }
 
 func (tm *TypeMap) makeRuntimeTypeGlobal(v llvm.Value) (global, ptr llvm.Value) {
 	runtimeTypeValue := llvm.ConstNull(tm.runtimeType)
-	initType := llvm.StructType([]llvm.Type{tm.runtimeType, v.Type()}, false)
+	initType := tm.ctx.StructType([]llvm.Type{tm.runtimeType, v.Type()}, false)
 	global = llvm.AddGlobal(tm.module, initType, "")
 	ptr = llvm.ConstBitCast(global, llvm.PointerType(tm.runtimeType, 0))
 
@@ -395,12 +731,12 @@ func (tm *TypeMap) makeCommonType(t types.Type, k reflect.Kind) llvm.Value {
 	// TODO padding
 
 	// Alignment.
-	align := llvm.ConstTrunc(llvm.AlignOf(lt), llvm.Int8Type())
+	align := llvm.ConstTrunc(llvm.AlignOf(lt), tm.ctx.Int8Type())
 	typ = llvm.ConstInsertValue(typ, align, []uint32{3}) // var
 	typ = llvm.ConstInsertValue(typ, align, []uint32{4}) // field
 
 	// Kind.
-	kind := llvm.ConstInt(llvm.Int8Type(), uint64(k), false)
+	kind := llvm.ConstInt(tm.ctx.Int8Type(), uint64(k), false)
 	typ = llvm.ConstInsertValue(typ, kind, []uint32{5})
 
 	// Algorithm table.
@@ -410,12 +746,38 @@ func (tm *TypeMap) makeCommonType(t types.Type, k reflect.Kind) llvm.Value {
 	algptr = llvm.ConstBitCast(algptr, elementTypes[6])
 	typ = llvm.ConstInsertValue(typ, algptr, []uint32{6})
 
-	// TODO string, gc
+	// String.
+	str := tm.makeStringGlobal(typeString(t))
+	str = llvm.ConstBitCast(str, elementTypes[8])
+	typ = llvm.ConstInsertValue(typ, str, []uint32{8})
+
+	// TODO gc
 	return typ
 }
 
+// makeStringGlobal creates a global variable containing the string value
+// specified, and returns a pointer to it.
+func (tm *TypeMap) makeStringGlobal(s string) llvm.Value {
+	strdata := tm.ctx.ConstString(s, false)
+	strdataGlobal := llvm.AddGlobal(tm.module, strdata.Type(), "")
+	strdataGlobal.SetInitializer(strdata)
+	strdataGlobal.SetLinkage(llvm.PrivateLinkage)
+	strdataGlobal.SetGlobalConstant(true)
+
+	stringType := tm.ToLLVM(types.String)
+	elementTypes := stringType.StructElementTypes()
+	strptr := llvm.ConstBitCast(strdataGlobal, elementTypes[0])
+	strlen := llvm.ConstInt(elementTypes[1], uint64(len(s)), false)
+	str := tm.ctx.ConstStruct([]llvm.Value{strptr, strlen}, false)
+	strGlobal := llvm.AddGlobal(tm.module, str.Type(), "")
+	strGlobal.SetInitializer(str)
+	strGlobal.SetLinkage(llvm.PrivateLinkage)
+	strGlobal.SetGlobalConstant(true)
+	return strGlobal
+}
+
 func (tm *TypeMap) badRuntimeType(b *types.Bad) (global, ptr llvm.Value) {
-	panic("bad type")
+	panic(typeError{b, "invalid type"})
 }
 
 func (tm *TypeMap) basicRuntimeType(b *types.Basic) (global, ptr llvm.Value) {
@@ -424,7 +786,17 @@ func (tm *TypeMap) basicRuntimeType(b *types.Basic) (global, ptr llvm.Value) {
 }
 
 func (tm *TypeMap) arrayRuntimeType(a *types.Array) (global, ptr llvm.Value) {
-	panic("unimplemented")
+	commonType := tm.makeCommonType(a, reflect.Array)
+	elemRuntimeType := tm.ToRuntime(a.Elt)
+	sliceRuntimeType := tm.ToRuntime(&types.Slice{Elt: a.Elt})
+	elementTypes := tm.runtimeArrayType.StructElementTypes()
+	arrayType := llvm.ConstNull(tm.runtimeArrayType)
+	arrayType = llvm.ConstInsertValue(arrayType, commonType, []uint32{0})
+	arrayType = llvm.ConstInsertValue(arrayType, elemRuntimeType, []uint32{1})
+	arrayType = llvm.ConstInsertValue(arrayType, sliceRuntimeType, []uint32{2})
+	length := llvm.ConstInt(elementTypes[3], a.Len, false)
+	arrayType = llvm.ConstInsertValue(arrayType, length, []uint32{3})
+	return tm.makeRuntimeTypeGlobal(arrayType)
 }
 
 func (tm *TypeMap) sliceRuntimeType(s *types.Slice) (global, ptr llvm.Value) {
@@ -440,7 +812,55 @@ func (tm *TypeMap) structRuntimeType(s *types.Struct) (global, ptr llvm.Value) {
 	commonType := tm.makeCommonType(s, reflect.Struct)
 	structType := llvm.ConstNull(tm.runtimeStructType)
 	structType = llvm.ConstInsertValue(structType, commonType, []uint32{0})
-	// TODO set fields
+	if len(s.Fields) == 0 {
+		return tm.makeRuntimeTypeGlobal(structType)
+	}
+
+	// Create the structField array, and a slice referring to it.
+	fieldsSliceType := tm.runtimeStructType.StructElementTypes()[1]
+	fieldsPtrType := fieldsSliceType.StructElementTypes()[0]
+	fieldType := fieldsPtrType.ElementType()
+	fieldElementTypes := fieldType.StructElementTypes()
+	structLLVMType := tm.ToLLVM(s)
+	fields := make([]llvm.Value, len(s.Fields))
+	for i, field := range s.Fields {
+		fieldValue := llvm.ConstNull(fieldType)
+		if field.Name != "" {
+			name := tm.makeStringGlobal(field.Name)
+			name = llvm.ConstBitCast(name, fieldElementTypes[0])
+			fieldValue = llvm.ConstInsertValue(fieldValue, name, []uint32{0})
+			if !ast.IsExported(field.Name) {
+				pkgpath := tm.makeStringGlobal(tm.pkgPath(s.Pkg))
+				pkgpath = llvm.ConstBitCast(pkgpath, fieldElementTypes[1])
+				fieldValue = llvm.ConstInsertValue(fieldValue, pkgpath, []uint32{1})
+			}
+		}
+		typ := tm.ToRuntime(field.Type.(types.Type))
+		typ = llvm.ConstBitCast(typ, fieldElementTypes[2])
+		fieldValue = llvm.ConstInsertValue(fieldValue, typ, []uint32{2})
+		if i < len(s.Tags) && s.Tags[i] != "" {
+			tag := tm.makeStringGlobal(s.Tags[i])
+			tag = llvm.ConstBitCast(tag, fieldElementTypes[3])
+			fieldValue = llvm.ConstInsertValue(fieldValue, tag, []uint32{3})
+		}
+		offset := tm.target.ElementOffset(structLLVMType, i)
+		offsetValue := llvm.ConstInt(fieldElementTypes[4], offset, false)
+		fieldValue = llvm.ConstInsertValue(fieldValue, offsetValue, []uint32{4})
+		fields[i] = fieldValue
+	}
+	fieldsArray := llvm.ConstArray(fieldType, fields)
+	fieldsGlobal := llvm.AddGlobal(tm.module, fieldsArray.Type(), "")
+	fieldsGlobal.SetInitializer(fieldsArray)
+	fieldsGlobal.SetLinkage(llvm.PrivateLinkage)
+	fieldsGlobal.SetGlobalConstant(true)
+	fieldsPtr := llvm.ConstBitCast(fieldsGlobal, fieldsPtrType)
+	fieldsLen := llvm.ConstInt(
+		fieldsSliceType.StructElementTypes()[1], uint64(len(fields)), false)
+	fieldsSlice := llvm.ConstNull(fieldsSliceType)
+	fieldsSlice = llvm.ConstInsertValue(fieldsSlice, fieldsPtr, []uint32{0})
+	fieldsSlice = llvm.ConstInsertValue(fieldsSlice, fieldsLen, []uint32{1})
+	fieldsSlice = llvm.ConstInsertValue(fieldsSlice, fieldsLen, []uint32{2})
+	structType = llvm.ConstInsertValue(structType, fieldsSlice, []uint32{1})
 	return tm.makeRuntimeTypeGlobal(structType)
 }
 
@@ -449,19 +869,119 @@ func (tm *TypeMap) pointerRuntimeType(p *types.Pointer) (global, ptr llvm.Value)
 	ptrType := llvm.ConstNull(tm.runtimePtrType)
 	ptrType = llvm.ConstInsertValue(ptrType, commonType, []uint32{0})
 	ptrType = llvm.ConstInsertValue(ptrType, tm.ToRuntime(p.Base), []uint32{1})
+
+	// A pointer to a named type has the named type's methods, so the
+	// runtime can create itabs for it. The pointer type itself is unnamed.
+	if n, ok := p.Base.(*types.Name); ok && len(n.Methods) > 0 {
+		uncommonTypeInit := tm.makeUncommonType(n, true)
+		uncommonType := llvm.AddGlobal(tm.module, uncommonTypeInit.Type(), "")
+		uncommonType.SetInitializer(uncommonTypeInit)
+		commonType = llvm.ConstInsertValue(commonType, uncommonType, []uint32{9})
+		ptrType = llvm.ConstInsertValue(ptrType, commonType, []uint32{0})
+	}
 	return tm.makeRuntimeTypeGlobal(ptrType)
 }
 
 func (tm *TypeMap) funcRuntimeType(f *types.Func) (global, ptr llvm.Value) {
-	panic("unimplemented")
+	commonType := tm.makeCommonType(f, reflect.Func)
+	elementTypes := tm.runtimeFuncType.StructElementTypes()
+	funcType := llvm.ConstNull(tm.runtimeFuncType)
+	funcType = llvm.ConstInsertValue(funcType, commonType, []uint32{0})
+
+	// dotdotdot
+	if f.IsVariadic {
+		variadic := llvm.ConstInt(elementTypes[1], 1, false)
+		funcType = llvm.ConstInsertValue(funcType, variadic, []uint32{1})
+	}
+
+	// in
+	paramTypes := make([]types.Type, len(f.Params))
+	for i, param := range f.Params {
+		paramTypes[i] = param.Type.(types.Type)
+	}
+	in := tm.makeRuntimeTypeSlice(paramTypes, elementTypes[2])
+	funcType = llvm.ConstInsertValue(funcType, in, []uint32{2})
+
+	// out
+	resultTypes := make([]types.Type, len(f.Results))
+	for i, result := range f.Results {
+		resultTypes[i] = result.Type.(types.Type)
+	}
+	out := tm.makeRuntimeTypeSlice(resultTypes, elementTypes[3])
+	funcType = llvm.ConstInsertValue(funcType, out, []uint32{3})
+
+	return tm.makeRuntimeTypeGlobal(funcType)
+}
+
+// makeRuntimeTypeSlice creates a constant slice, of the specified LLVM
+// type, containing pointers to the runtime types of the specified types.
+func (tm *TypeMap) makeRuntimeTypeSlice(typs []types.Type, slicetyp llvm.Type) llvm.Value {
+	slice := llvm.ConstNull(slicetyp)
+	if len(typs) == 0 {
+		return slice
+	}
+	elementTypes := slicetyp.StructElementTypes()
+	ptrtyp := elementTypes[0].ElementType()
+	ptrs := make([]llvm.Value, len(typs))
+	for i, typ := range typs {
+		ptrs[i] = llvm.ConstBitCast(tm.ToRuntime(typ), ptrtyp)
+	}
+	array := llvm.ConstArray(ptrtyp, ptrs)
+	arrayGlobal := llvm.AddGlobal(tm.module, array.Type(), "")
+	arrayGlobal.SetInitializer(array)
+	arrayGlobal.SetLinkage(llvm.PrivateLinkage)
+	arrayGlobal.SetGlobalConstant(true)
+	arrayptr := llvm.ConstBitCast(arrayGlobal, elementTypes[0])
+	length := llvm.ConstInt(elementTypes[1], uint64(len(typs)), false)
+	slice = llvm.ConstInsertValue(slice, arrayptr, []uint32{0})
+	slice = llvm.ConstInsertValue(slice, length, []uint32{1})
+	slice = llvm.ConstInsertValue(slice, length, []uint32{2})
+	return slice
 }
 
 func (tm *TypeMap) interfaceRuntimeType(i *types.Interface) (global, ptr llvm.Value) {
 	commonType := tm.makeCommonType(i, reflect.Interface)
 	interfaceType := llvm.ConstNull(tm.runtimeInterfaceType)
 	interfaceType = llvm.ConstInsertValue(interfaceType, commonType, []uint32{0})
-	// TODO set methods
-	//interfaceType = llvm.ConstInsertValue(interfaceType, methods, []uint32{1})
+	if len(i.Methods) == 0 {
+		return tm.makeRuntimeTypeGlobal(interfaceType)
+	}
+
+	// Create the imethod array, and a slice referring to it. The methods
+	// are sorted by name, as they are in types.Interface.
+	methodsSliceType := tm.runtimeInterfaceType.StructElementTypes()[1]
+	methodsPtrType := methodsSliceType.StructElementTypes()[0]
+	imethodType := methodsPtrType.ElementType()
+	imethodElementTypes := imethodType.StructElementTypes()
+	imethods := make([]llvm.Value, len(i.Methods))
+	for n, m := range i.Methods {
+		imethod := llvm.ConstNull(imethodType)
+		name := tm.makeStringGlobal(m.Name)
+		name = llvm.ConstBitCast(name, imethodElementTypes[0])
+		imethod = llvm.ConstInsertValue(imethod, name, []uint32{0})
+		if !ast.IsExported(m.Name) {
+			pkgpath := tm.makeStringGlobal(tm.pkgPath(i.Pkg))
+			pkgpath = llvm.ConstBitCast(pkgpath, imethodElementTypes[1])
+			imethod = llvm.ConstInsertValue(imethod, pkgpath, []uint32{1})
+		}
+		typ := tm.ToRuntime(m.Type.(types.Type))
+		typ = llvm.ConstBitCast(typ, imethodElementTypes[2])
+		imethod = llvm.ConstInsertValue(imethod, typ, []uint32{2})
+		imethods[n] = imethod
+	}
+	imethodsArray := llvm.ConstArray(imethodType, imethods)
+	imethodsGlobal := llvm.AddGlobal(tm.module, imethodsArray.Type(), "")
+	imethodsGlobal.SetInitializer(imethodsArray)
+	imethodsGlobal.SetLinkage(llvm.PrivateLinkage)
+	imethodsGlobal.SetGlobalConstant(true)
+	imethodsPtr := llvm.ConstBitCast(imethodsGlobal, methodsPtrType)
+	imethodsLen := llvm.ConstInt(
+		methodsSliceType.StructElementTypes()[1], uint64(len(imethods)), false)
+	imethodsSlice := llvm.ConstNull(methodsSliceType)
+	imethodsSlice = llvm.ConstInsertValue(imethodsSlice, imethodsPtr, []uint32{0})
+	imethodsSlice = llvm.ConstInsertValue(imethodsSlice, imethodsLen, []uint32{1})
+	imethodsSlice = llvm.ConstInsertValue(imethodsSlice, imethodsLen, []uint32{2})
+	interfaceType = llvm.ConstInsertValue(interfaceType, imethodsSlice, []uint32{1})
 	return tm.makeRuntimeTypeGlobal(interfaceType)
 }
 
@@ -475,36 +995,152 @@ func (tm *TypeMap) mapRuntimeType(m *types.Map) (global, ptr llvm.Value) {
 }
 
 func (tm *TypeMap) chanRuntimeType(c *types.Chan) (global, ptr llvm.Value) {
-	panic("unimplemented")
+	commonType := tm.makeCommonType(c, reflect.Chan)
+	elementTypes := tm.runtimeChanType.StructElementTypes()
+	chanType := llvm.ConstNull(tm.runtimeChanType)
+	chanType = llvm.ConstInsertValue(chanType, commonType, []uint32{0})
+	chanType = llvm.ConstInsertValue(chanType, tm.ToRuntime(c.Elt), []uint32{1})
+
+	// go/ast and reflect use different values for specifying channel
+	// direction.
+	var dir reflect.ChanDir
+	if c.Dir&ast.RECV != 0 {
+		dir |= reflect.RecvDir
+	}
+	if c.Dir&ast.SEND != 0 {
+		dir |= reflect.SendDir
+	}
+	chanDir := llvm.ConstInt(elementTypes[2], uint64(dir), false)
+	chanType = llvm.ConstInsertValue(chanType, chanDir, []uint32{2})
+	return tm.makeRuntimeTypeGlobal(chanType)
 }
 
 func (tm *TypeMap) nameRuntimeType(n *types.Name) (global, ptr llvm.Value) {
+	// Recursive references to the type, e.g. through pointer fields or
+	// methods, refer to its declaration, which is replaced with global.
 	global, ptr = tm.makeRuntimeType(n.Underlying)
 	globalInit := global.Initializer()
 
 	// Locate the common type.
 	underlyingRuntimeType := llvm.ConstExtractValue(globalInit, []uint32{1})
 	commonType := underlyingRuntimeType
-	if _, ok := n.Underlying.(*types.Basic); !ok {
+	isCommonType := underlyingRuntimeType.Type() == tm.runtimeCommonType
+	if !isCommonType {
 		commonType = llvm.ConstExtractValue(commonType, []uint32{0})
 	}
 
 	// Insert the uncommon type.
-	uncommonTypeInit := llvm.ConstNull(tm.runtimeUncommonType)
+	uncommonTypeInit := tm.makeUncommonType(n, false)
 	uncommonType := llvm.AddGlobal(tm.module, uncommonTypeInit.Type(), "")
 	uncommonType.SetInitializer(uncommonTypeInit)
 	commonType = llvm.ConstInsertValue(commonType, uncommonType, []uint32{9})
 
+	// Replace the string with the type's name.
+	elementTypes := tm.runtimeCommonType.StructElementTypes()
+	str := tm.makeStringGlobal(typeString(n))
+	str = llvm.ConstBitCast(str, elementTypes[8])
+	commonType = llvm.ConstInsertValue(commonType, str, []uint32{8})
+
 	// Update the global's initialiser.
-	if _, ok := n.Underlying.(*types.Basic); !ok {
+	if !isCommonType {
 		underlyingRuntimeType = llvm.ConstInsertValue(underlyingRuntimeType, commonType, []uint32{0})
 	} else {
 		underlyingRuntimeType = commonType
 	}
 	globalInit = llvm.ConstInsertValue(globalInit, underlyingRuntimeType, []uint32{1})
-	global.SetName("__llgo.reflect." + n.Obj.Name)
-	global.SetLinkage(llvm.PrivateLinkage)
+	global.SetInitializer(globalInit)
 	return global, ptr
 }
 
+// makeUncommonType creates the uncommonType initialiser for a named type,
+// containing the type's name, package path and methods; or, if ptr is
+// true, for a pointer to the named type, containing only the methods.
+// The methods are sorted by name, as they are in types.Name.
+func (tm *TypeMap) makeUncommonType(n *types.Name, ptr bool) llvm.Value {
+	uncommonType := llvm.ConstNull(tm.runtimeUncommonType)
+	elementTypes := tm.runtimeUncommonType.StructElementTypes()
+
+	// The type and its methods are declared in the same package, which is
+	// the package being compiled if the type is local.
+	path, ok := tm.functions.pathmap[n.Obj]
+	if !ok {
+		path = tm.pkgpath
+	}
+	if !ptr {
+		name := tm.makeStringGlobal(n.Obj.Name)
+		name = llvm.ConstBitCast(name, elementTypes[0])
+		uncommonType = llvm.ConstInsertValue(uncommonType, name, []uint32{0})
+	}
+	if !ptr && types.Universe.Lookup(n.Obj.Name) != n.Obj {
+		pkgpath := tm.makeStringGlobal(path)
+		pkgpath = llvm.ConstBitCast(pkgpath, elementTypes[1])
+		uncommonType = llvm.ConstInsertValue(uncommonType, pkgpath, []uint32{1})
+	}
+	if len(n.Methods) == 0 {
+		return uncommonType
+	}
+
+	// Create the method array, and a slice referring to it.
+	methodsSliceType := elementTypes[2]
+	methodsPtrType := methodsSliceType.StructElementTypes()[0]
+	methodType := methodsPtrType.ElementType()
+	methodElementTypes := methodType.StructElementTypes()
+	methods := make([]llvm.Value, len(n.Methods))
+	for i, m := range n.Methods {
+		method := llvm.ConstNull(methodType)
+		name := tm.makeStringGlobal(m.Name)
+		name = llvm.ConstBitCast(name, methodElementTypes[0])
+		method = llvm.ConstInsertValue(method, name, []uint32{0})
+		if !ast.IsExported(m.Name) {
+			pkgpath := tm.makeStringGlobal(path)
+			pkgpath = llvm.ConstBitCast(pkgpath, methodElementTypes[1])
+			method = llvm.ConstInsertValue(method, pkgpath, []uint32{1})
+		}
+
+		// mtyp is the method's type without a receiver, which the
+		// runtime compares with the type of an interface's method when
+		// creating an itab. typ is the method's type with the receiver
+		// as the first parameter.
+		ftyp := m.Type.(*types.Func)
+		mtyp := &types.Func{
+			Params:     ftyp.Params,
+			Results:    ftyp.Results,
+			IsVariadic: ftyp.IsVariadic,
+		}
+		mtypValue := llvm.ConstBitCast(tm.ToRuntime(mtyp), methodElementTypes[2])
+		method = llvm.ConstInsertValue(method, mtypValue, []uint32{2})
+		params := append(types.ObjList{ftyp.Recv}, ftyp.Params...)
+		typ := &types.Func{
+			Params:     params,
+			Results:    ftyp.Results,
+			IsVariadic: ftyp.IsVariadic,
+		}
+		typValue := llvm.ConstBitCast(tm.ToRuntime(typ), methodElementTypes[3])
+		method = llvm.ConstInsertValue(method, typValue, []uint32{3})
+
+		// ifn is called through interfaces, with the interface's data
+		// word as its receiver, and tfn directly.
+		ifn := tm.functions.makeInterfaceMethod(m, ptr)
+		ifn = llvm.ConstBitCast(ifn, methodElementTypes[4])
+		method = llvm.ConstInsertValue(method, ifn, []uint32{4})
+		fn := tm.functions.Resolve(m).LLVMValue()
+		tfn := llvm.ConstBitCast(fn, methodElementTypes[5])
+		method = llvm.ConstInsertValue(method, tfn, []uint32{5})
+		methods[i] = method
+	}
+	methodsArray := llvm.ConstArray(methodType, methods)
+	methodsGlobal := llvm.AddGlobal(tm.module, methodsArray.Type(), "")
+	methodsGlobal.SetInitializer(methodsArray)
+	methodsGlobal.SetLinkage(llvm.PrivateLinkage)
+	methodsGlobal.SetGlobalConstant(true)
+	methodsPtr := llvm.ConstBitCast(methodsGlobal, methodsPtrType)
+	methodsLen := llvm.ConstInt(
+		methodsSliceType.StructElementTypes()[1], uint64(len(methods)), false)
+	methodsSlice := llvm.ConstNull(methodsSliceType)
+	methodsSlice = llvm.ConstInsertValue(methodsSlice, methodsPtr, []uint32{0})
+	methodsSlice = llvm.ConstInsertValue(methodsSlice, methodsLen, []uint32{1})
+	methodsSlice = llvm.ConstInsertValue(methodsSlice, methodsLen, []uint32{2})
+	return llvm.ConstInsertValue(uncommonType, methodsSlice, []uint32{2})
+}
+
 // vim: set ft=go :
@@ -42,8 +42,19 @@ func (c *compiler) VisitMake(expr *ast.CallExpr) Value {
 		}
 		slice := c.makeSlice(utyp.Elt, length, capacity)
 		return c.NewLLVMValue(slice, typ)
+	case *types.Map:
+		var capacity Value
+		if len(expr.Args) == 2 {
+			capacity = c.VisitExpr(expr.Args[1])
+		}
+		return c.makeMap(typ, capacity)
+	case *types.Chan:
+		var capacity Value
+		if len(expr.Args) == 2 {
+			capacity = c.VisitExpr(expr.Args[1])
+		}
+		return c.makeChan(typ, capacity)
 	}
-	// TODO map, chan
 	return c.NewLLVMValue(llvm.ConstNull(c.types.ToLLVM(typ)), typ)
 }
 
@@ -25,29 +25,52 @@ package llgo
 import (
 	"github.com/axw/gollvm/llvm"
 	"github.com/axw/llgo/types"
+	"go/ast"
 )
 
+// makeMap allocates a new map, with space for at least the specified
+// number of entries.
+func (c *compiler) makeMap(typ types.Type, cap_ Value) *LLVMValue {
+	makemap := c.NamedFunction("runtime.makemap", "func f(t uintptr, cap int) uintptr")
+	ptrType := c.target.IntPtrType()
+	args := make([]llvm.Value, 2)
+	args[0] = llvm.ConstPtrToInt(c.types.ToRuntime(typ), ptrType)
+	if cap_ != nil {
+		args[1] = cap_.Convert(types.Int).LLVMValue()
+	} else {
+		args[1] = llvm.ConstNull(c.types.ToLLVM(types.Int))
+	}
+	m := c.builder.CreateCall(makemap, args, "")
+	m = c.builder.CreateIntToPtr(m, c.types.ToLLVM(typ), "")
+	return c.NewLLVMValue(m, typ)
+}
+
 // mapLookup searches a map for a specified key, returning a pointer to the
-// memory location for the value. If insert is given as true, and the key
-// does not exist in the map, it will be added with an uninitialised value.
+// memory location for the value, and a boolean indicating whether the key
+// exists. If insert is given as true, and the key does not exist in the
+// map, it will be added with a zero value. Otherwise a missing key yields
+// a pointer to a zero value that must not be stored to.
 func (c *compiler) mapLookup(m *LLVMValue, key Value, insert bool) (elem *LLVMValue, notnull *LLVMValue) {
-	mapType := m.Type().(*types.Map)
+	mapType := types.Underlying(m.Type()).(*types.Map)
+	if key.Type() != mapType.Key {
+		key = key.Convert(mapType.Key)
+	}
 	maplookup := c.NamedFunction("runtime.maplookup", "func f(t, m, k uintptr, insert bool) uintptr")
 	ptrType := c.target.IntPtrType()
 	args := make([]llvm.Value, 4)
 	args[0] = llvm.ConstPtrToInt(c.types.ToRuntime(m.Type()), ptrType)
-	args[1] = c.builder.CreatePtrToInt(m.pointer.LLVMValue(), ptrType, "")
+	args[1] = c.builder.CreatePtrToInt(m.LLVMValue(), ptrType, "")
 	if insert {
-		args[3] = llvm.ConstAllOnes(llvm.Int1Type())
+		args[3] = llvm.ConstAllOnes(c.context.Int1Type())
 	} else {
-		args[3] = llvm.ConstNull(llvm.Int1Type())
+		args[3] = llvm.ConstNull(c.context.Int1Type())
 	}
 
 	if lv, islv := key.(*LLVMValue); islv && lv.pointer != nil {
 		args[2] = c.builder.CreatePtrToInt(lv.pointer.LLVMValue(), ptrType, "")
 	}
 	if args[2].IsNil() {
-		stackval := c.builder.CreateAlloca(c.types.ToLLVM(key.Type()), "")
+		stackval := c.createEntryAlloca(c.types.ToLLVM(key.Type()), "")
 		c.builder.CreateStore(key.LLVMValue(), stackval)
 		args[2] = c.builder.CreatePtrToInt(stackval, ptrType, "")
 	}
@@ -64,41 +87,75 @@ func (c *compiler) mapLookup(m *LLVMValue, key Value, insert bool) (elem *LLVMVa
 	return value.makePointee(), c.NewLLVMValue(notnull_, types.Bool)
 }
 
+// mapIndexElement returns the element referred to by a map index
+// expression, inserting a zero value if the key is not in the map. If
+// x is not a map index expression, mapIndexElement returns nil.
+func (c *compiler) mapIndexElement(x ast.Expr) *LLVMValue {
+	if x, ok := x.(*ast.IndexExpr); ok {
+		if t, ok := c.types.expr[x.X]; ok {
+			if _, ok := types.Underlying(t).(*types.Map); ok {
+				m := c.VisitExpr(x.X).(*LLVMValue)
+				index := c.VisitExpr(x.Index)
+				elem, _ := c.mapLookup(m, index, true)
+				return elem
+			}
+		}
+	}
+	return nil
+}
+
 func (c *compiler) mapDelete(m *LLVMValue, key Value) {
+	mapType := types.Underlying(m.Type()).(*types.Map)
+	if key.Type() != mapType.Key {
+		key = key.Convert(mapType.Key)
+	}
 	mapdelete := c.NamedFunction("runtime.mapdelete", "func f(t, m, k uintptr)")
 	ptrType := c.target.IntPtrType()
 	args := make([]llvm.Value, 3)
 	args[0] = llvm.ConstPtrToInt(c.types.ToRuntime(m.Type()), ptrType)
-	args[1] = c.builder.CreatePtrToInt(m.pointer.LLVMValue(), ptrType, "")
+	args[1] = c.builder.CreatePtrToInt(m.LLVMValue(), ptrType, "")
 	if lv, islv := key.(*LLVMValue); islv && lv.pointer != nil {
 		args[2] = c.builder.CreatePtrToInt(lv.pointer.LLVMValue(), ptrType, "")
 	}
 	if args[2].IsNil() {
-		stackval := c.builder.CreateAlloca(c.types.ToLLVM(key.Type()), "")
+		stackval := c.createEntryAlloca(c.types.ToLLVM(key.Type()), "")
 		c.builder.CreateStore(key.LLVMValue(), stackval)
 		args[2] = c.builder.CreatePtrToInt(stackval, ptrType, "")
 	}
 	c.builder.CreateCall(mapdelete, args, "")
 }
 
-// mapNext iterates through a map, accepting an iterator state value,
-// and returning a new state value, key pointer, and value pointer.
-func (c *compiler) mapNext(m *LLVMValue, nextin llvm.Value) (nextout, pk, pv llvm.Value) {
-	mapnext := c.NamedFunction("runtime.mapnext", "func f(t, m, n uintptr) (uintptr, uintptr, uintptr)")
+// mapIterInit creates an iterator over a map, positioned at the first
+// entry. The iterator is allocated on the stack.
+func (c *compiler) mapIterInit(m *LLVMValue) (it llvm.Value) {
+	mapiterinit := c.NamedFunction("runtime.mapiterinit", "func f(t, m, it uintptr)")
 	ptrType := c.target.IntPtrType()
+	fields := []llvm.Type{ptrType, ptrType, ptrType, ptrType, ptrType}
+	it = c.createEntryAlloca(c.context.StructType(fields, false), "")
 	args := make([]llvm.Value, 3)
 	args[0] = llvm.ConstPtrToInt(c.types.ToRuntime(m.Type()), ptrType)
-	args[1] = c.builder.CreatePtrToInt(m.pointer.LLVMValue(), ptrType, "")
-	args[2] = nextin
-	results := c.builder.CreateCall(mapnext, args, "")
-	nextout = c.builder.CreateExtractValue(results, 0, "")
-	pk = c.builder.CreateExtractValue(results, 1, "")
-	pv = c.builder.CreateExtractValue(results, 2, "")
+	args[1] = c.builder.CreatePtrToInt(m.LLVMValue(), ptrType, "")
+	args[2] = c.builder.CreatePtrToInt(it, ptrType, "")
+	c.builder.CreateCall(mapiterinit, args, "")
+	return it
+}
 
-	keyptrtype := &types.Pointer{Base: m.Type().(*types.Map).Key.(types.Type)}
-	valptrtype := &types.Pointer{Base: m.Type().(*types.Map).Elt.(types.Type)}
+// mapIterNext advances a map iterator to the next entry.
+func (c *compiler) mapIterNext(it llvm.Value) {
+	mapiternext := c.NamedFunction("runtime.mapiternext", "func f(it uintptr)")
+	arg := c.builder.CreatePtrToInt(it, c.target.IntPtrType(), "")
+	c.builder.CreateCall(mapiternext, []llvm.Value{arg}, "")
+}
+
+// mapIterEntry returns the key and value pointers for a map iterator's
+// current entry. The key pointer is null when iteration is complete.
+func (c *compiler) mapIterEntry(m *LLVMValue, it llvm.Value) (pk, pv llvm.Value) {
+	pk = c.builder.CreateLoad(c.builder.CreateStructGEP(it, 0, ""), "")
+	pv = c.builder.CreateLoad(c.builder.CreateStructGEP(it, 1, ""), "")
+	mapType := types.Underlying(m.Type()).(*types.Map)
+	keyptrtype := &types.Pointer{Base: mapType.Key}
+	valptrtype := &types.Pointer{Base: mapType.Elt}
 	pk = c.builder.CreateIntToPtr(pk, c.types.ToLLVM(keyptrtype), "")
 	pv = c.builder.CreateIntToPtr(pv, c.types.ToLLVM(valptrtype), "")
-
 	return
 }
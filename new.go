@@ -23,7 +23,6 @@ SOFTWARE.
 package llgo
 
 import (
-	"github.com/axw/gollvm/llvm"
 	"github.com/axw/llgo/types"
 	"go/ast"
 )
@@ -34,8 +33,8 @@ func (c *compiler) VisitNew(expr *ast.CallExpr) Value {
 	}
 	typ := c.GetType(expr.Args[0])
 	llvm_typ := c.types.ToLLVM(typ)
-	mem := c.builder.CreateMalloc(llvm_typ, "")
-	c.builder.CreateStore(llvm.ConstNull(llvm_typ), mem)
+	// The allocated memory is already zeroed.
+	mem := c.createTypeMalloc(llvm_typ)
 	return c.NewLLVMValue(mem, &types.Pointer{Base: typ})
 }
 
@@ -0,0 +1,71 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package llgo
+
+import (
+	"github.com/axw/gollvm/llvm"
+)
+
+// optimize runs the LLVM optimization passes selected by the optimization
+// level and inlining threshold over the module. Each function is verified
+// before it is optimized.
+func (c *compiler) optimize(machine llvm.TargetMachine) {
+	if c.optLevel <= 0 {
+		return
+	}
+
+	pmb := llvm.NewPassManagerBuilder()
+	defer pmb.Dispose()
+	pmb.SetOptLevel(c.optLevel)
+	switch threshold := c.inlineThreshold; {
+	case threshold > 0:
+		pmb.UseInlinerWithThreshold(uint(threshold))
+	case c.optLevel > 2:
+		pmb.UseInlinerWithThreshold(275)
+	case c.optLevel > 1:
+		pmb.UseInlinerWithThreshold(225)
+	}
+
+	fpm := llvm.NewFunctionPassManagerForModule(c.module.Module)
+	defer fpm.Dispose()
+	c.target.AddToPassManager(fpm)
+	machine.AddAnalysisPasses(fpm)
+	fpm.AddVerifierPass()
+	pmb.PopulateFunc(fpm)
+	fpm.InitializeFunc()
+	for fn := c.module.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if !fn.IsDeclaration() {
+			fpm.RunFunc(fn)
+		}
+	}
+	fpm.FinalizeFunc()
+
+	pm := llvm.NewPassManager()
+	defer pm.Dispose()
+	c.target.AddToPassManager(pm)
+	machine.AddAnalysisPasses(pm)
+	pmb.Populate(pm)
+	pm.Run(c.module.Module)
+}
+
+// vim: set ft=go :
@@ -24,7 +24,89 @@ package runtime
 
 import "unsafe"
 
+type hashalg func(uintptr, unsafe.Pointer) uintptr
 type equalalg func(uintptr, unsafe.Pointer, unsafe.Pointer) bool
+type printalg func(uintptr, unsafe.Pointer)
+type copyalg func(uintptr, unsafe.Pointer, unsafe.Pointer)
+
+// funcval is the representation of a function value: a function pointer,
+// and a pointer to the closure context (nil for top-level functions).
+type funcval struct {
+	fn  unsafe.Pointer
+	ctx unsafe.Pointer
+}
+
+// hashalgat returns the hash function from a type's algorithm table. The
+// table holds bare function pointers, which must be made into function
+// values before they can be called.
+func hashalgat(algs unsafe.Pointer) hashalg {
+	f := funcval{fn: *(*unsafe.Pointer)(algs)}
+	return *(*hashalg)(unsafe.Pointer(&f))
+}
+
+// equalalgat returns the equal function from a type's algorithm table.
+func equalalgat(algs unsafe.Pointer) equalalg {
+	eqptr := unsafe.Pointer(uintptr(algs) + unsafe.Sizeof(algs))
+	f := funcval{fn: *(*unsafe.Pointer)(eqptr)}
+	return *(*equalalg)(unsafe.Pointer(&f))
+}
+
+// printalgat returns the print function from a type's algorithm table.
+func printalgat(algs unsafe.Pointer) printalg {
+	printptr := unsafe.Pointer(uintptr(algs) + 2*unsafe.Sizeof(algs))
+	f := funcval{fn: *(*unsafe.Pointer)(printptr)}
+	return *(*printalg)(unsafe.Pointer(&f))
+}
+
+// copyalgat returns the copy function from a type's algorithm table.
+func copyalgat(algs unsafe.Pointer) copyalg {
+	copyptr := unsafe.Pointer(uintptr(algs) + 3*unsafe.Sizeof(algs))
+	f := funcval{fn: *(*unsafe.Pointer)(copyptr)}
+	return *(*copyalg)(unsafe.Pointer(&f))
+}
+
+// eface is the layout of an empty interface.
+type eface struct {
+	typ   *type_
+	value uintptr
+}
+
+// iface is the layout of a non-empty interface.
+type iface struct {
+	tab   *itab
+	value uintptr
+}
+
+// ifaceeface returns the empty interface holding the same value as the
+// non-empty interface i.
+func ifaceeface(i *iface) eface {
+	if i.tab == nil {
+		return eface{}
+	}
+	return eface{i.tab.typ, i.value}
+}
+
+// efacevalue returns a pointer to the value stored in an interface.
+func efacevalue(e *eface) unsafe.Pointer {
+	if e.typ.size <= unsafe.Sizeof(e.value) {
+		// value fits in pointer
+		return unsafe.Pointer(&e.value)
+	}
+	return unsafe.Pointer(e.value)
+}
+
+// memhash computes the FNV-1a hash of a region of memory.
+func memhash(size uintptr, p unsafe.Pointer) uintptr {
+	var h uint32 = 2166136261
+	a := uintptr(p)
+	end := a + size
+	for a != end {
+		h ^= uint32(*(*byte)(unsafe.Pointer(a)))
+		h *= 16777619
+		a++
+	}
+	return uintptr(h)
+}
 
 func memequal(size uintptr, lhs, rhs unsafe.Pointer) bool {
 	if lhs == rhs {
@@ -43,3 +125,95 @@ func memequal(size uintptr, lhs, rhs unsafe.Pointer) bool {
 	}
 	return true
 }
+
+func memprint(size uintptr, p unsafe.Pointer) {
+	var v uint64 = 0xbadb00b
+	switch size {
+	case 1:
+		v = uint64(*(*uint8)(p))
+	case 2:
+		v = uint64(*(*uint16)(p))
+	case 4:
+		v = uint64(*(*uint32)(p))
+	case 8:
+		v = *(*uint64)(p)
+	}
+	print(v)
+}
+
+func memcopy(size uintptr, dst, src unsafe.Pointer) {
+	if src == nil {
+		memset(dst, 0, int(size))
+	} else {
+		memmove(dst, src, int(size))
+	}
+}
+
+// nohash is the hash algorithm for types that are not comparable.
+func nohash(size uintptr, p unsafe.Pointer) uintptr {
+	panic(errorString("hash of unhashable type"))
+}
+
+// noequal is the equal algorithm for types that are not comparable.
+func noequal(size uintptr, lhs, rhs unsafe.Pointer) bool {
+	panic(errorString("comparing uncomparable type"))
+}
+
+// strhash computes the hash of the contents of a string.
+func strhash(size uintptr, p unsafe.Pointer) uintptr {
+	s := *(*string)(p)
+	return memhash(uintptr(len(s)), *(*unsafe.Pointer)(p))
+}
+
+func strequal(size uintptr, lhs, rhs unsafe.Pointer) bool {
+	return *(*string)(lhs) == *(*string)(rhs)
+}
+
+func strprint(size uintptr, p unsafe.Pointer) {
+	print(*(*string)(p))
+}
+
+// nilinterhash computes the hash of an empty interface's dynamic value,
+// using the hash function of its dynamic type.
+func nilinterhash(size uintptr, p unsafe.Pointer) uintptr {
+	e := (*eface)(p)
+	if e.typ == nil {
+		return 0
+	}
+	hash := hashalgat(unsafe.Pointer(e.typ.alg))
+	return hash(e.typ.size, efacevalue(e))
+}
+
+func nilinterequal(size uintptr, lhs, rhs unsafe.Pointer) bool {
+	a, b := (*eface)(lhs), (*eface)(rhs)
+	return compareI2I(uintptr(unsafe.Pointer(a.typ)),
+		uintptr(unsafe.Pointer(b.typ)), a.value, b.value)
+}
+
+func nilinterprint(size uintptr, p unsafe.Pointer) {
+	e := (*eface)(p)
+	if e.typ == nil {
+		print("nil")
+		return
+	}
+	printfn := printalgat(unsafe.Pointer(e.typ.alg))
+	printfn(e.typ.size, efacevalue(e))
+}
+
+// interhash computes the hash of a non-empty interface's dynamic value.
+func interhash(size uintptr, p unsafe.Pointer) uintptr {
+	e := ifaceeface((*iface)(p))
+	return nilinterhash(size, unsafe.Pointer(&e))
+}
+
+func interequal(size uintptr, lhs, rhs unsafe.Pointer) bool {
+	a, b := ifaceeface((*iface)(lhs)), ifaceeface((*iface)(rhs))
+	return nilinterequal(size, unsafe.Pointer(&a), unsafe.Pointer(&b))
+}
+
+func interprint(size uintptr, p unsafe.Pointer) {
+	e := ifaceeface((*iface)(p))
+	nilinterprint(size, unsafe.Pointer(&e))
+}
+
+// vim: set ft=go :
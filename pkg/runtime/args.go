@@ -0,0 +1,90 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package runtime
+
+import "unsafe"
+
+// argc, argv and envp hold the arguments to the program's C main function.
+var argc int32
+var argv, envp unsafe.Pointer
+
+// setargs records the arguments to the program's C main function. It is
+// called by the main function generated by the compiler, before any
+// package is initialised.
+func setargs(c int32, v, e unsafe.Pointer) {
+	argc = c
+	argv = v
+	envp = e
+}
+
+// cstring returns a string referring to the NUL-terminated C string at p.
+// The string's bytes are not copied.
+func cstring(p unsafe.Pointer) _string {
+	n := 0
+	for *(*uint8)(unsafe.Pointer(uintptr(p) + uintptr(n))) != 0 {
+		n++
+	}
+	return _string{(*uint8)(p), n}
+}
+
+// cstrings converts the first n pointers of a NULL-terminated array of C
+// strings to a slice of strings. If n is negative, the array is converted
+// up to its terminating NULL.
+func cstrings(array unsafe.Pointer, n int) []_string {
+	var ptr unsafe.Pointer
+	ptrsize := unsafe.Sizeof(ptr)
+	if n < 0 {
+		n = 0
+		if array != unsafe.Pointer(uintptr(0)) {
+			for *(*uintptr)(unsafe.Pointer(uintptr(array) + uintptr(n)*ptrsize)) != 0 {
+				n++
+			}
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	var s _string
+	mem := mallocgc(uintptr(n) * unsafe.Sizeof(s))
+	for i := 0; i < n; i++ {
+		p := *(*unsafe.Pointer)(unsafe.Pointer(uintptr(array) + uintptr(i)*ptrsize))
+		elem := (*_string)(unsafe.Pointer(uintptr(mem) + uintptr(i)*unsafe.Sizeof(s)))
+		*elem = cstring(p)
+	}
+	result := slice{(*uint8)(mem), uint(n), uint(n)}
+	return *(*[]_string)(unsafe.Pointer(&result))
+}
+
+// args returns the program's command-line arguments, starting with the
+// program name, as the os package's Args.
+func args() []_string {
+	return cstrings(argv, int(argc))
+}
+
+// environ returns the program's environment, as strings of the form
+// "key=value".
+func environ() []_string {
+	return cstrings(envp, -1)
+}
+
+// vim: set ft=go :
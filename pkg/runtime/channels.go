@@ -0,0 +1,241 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package runtime
+
+import "unsafe"
+
+// chan_ is the runtime representation of a channel. Buffered elements are
+// stored in a circular buffer of cap elements, starting at index head.
+// Goroutines blocked sending to or receiving from the channel wait in
+// sendq and recvq; elements are handed directly between a waiting
+// goroutine and the goroutine that readies it.
+type chan_ struct {
+	lock    int32
+	elemtyp *type_
+	cap     int
+	len     int
+	head    int
+	closed  bool
+	buf     unsafe.Pointer
+	sendq   waitq
+	recvq   waitq
+}
+
+// sudog is a goroutine waiting on a channel. elem points to the element
+// being sent, or to the memory receiving the element; ok records whether
+// the element was transferred, rather than the channel being closed.
+// sudogs are allocated with malloc, as they are referred to only by the
+// channel while the goroutine is blocked.
+type sudog struct {
+	g    *g
+	elem unsafe.Pointer
+	ok   bool
+	next *sudog
+}
+
+// waitq is a queue of goroutines waiting on a channel.
+type waitq struct {
+	first, last *sudog
+}
+
+// enqueue adds a waiting goroutine to the end of the queue.
+func (q *waitq) enqueue(sg *sudog) {
+	if q.last == nil {
+		q.first = sg
+	} else {
+		q.last.next = sg
+	}
+	q.last = sg
+}
+
+// dequeue removes the goroutine at the front of the queue, returning nil
+// if the queue is empty.
+func (q *waitq) dequeue() *sudog {
+	sg := q.first
+	if sg != nil {
+		q.first = sg.next
+		if q.first == nil {
+			q.last = nil
+		}
+		sg.next = nil
+	}
+	return sg
+}
+
+// wait blocks the calling goroutine on the waitq q, releasing the
+// channel's lock, until it is readied by another operation on the
+// channel. It returns whether the element was transferred.
+func (c *chan_) wait(q *waitq, elem unsafe.Pointer) bool {
+	sg := (*sudog)(malloc(int(unsafe.Sizeof(sudog{}))))
+	sg.g = getg()
+	sg.elem = elem
+	q.enqueue(sg)
+	gopark(&c.lock)
+	ok := sg.ok
+	free(unsafe.Pointer(sg))
+	return ok
+}
+
+// chanmake allocates a channel of the given type, with a buffer of the
+// specified capacity.
+func chanmake(t unsafe.Pointer, cap int) *chan_ {
+	typ := (*type_)(t)
+	chantyp := (*chanType)(unsafe.Pointer(&typ.commonType))
+	c := (*chan_)(mallocgc(unsafe.Sizeof(chan_{})))
+	c.elemtyp = chantyp.elem
+	c.cap = cap
+	if cap > 0 {
+		c.buf = mallocgc(uintptr(cap) * c.elemtyp.size)
+	}
+	return c
+}
+
+// elemat returns a pointer to the i'th element of the channel's buffer.
+func (c *chan_) elemat(i int) unsafe.Pointer {
+	return unsafe.Pointer(uintptr(c.buf) + uintptr(i)*c.elemtyp.size)
+}
+
+// chansend sends the element pointed to by elem on the channel, blocking
+// until a receiver takes it or there is room in the channel's buffer.
+// Sending on a nil channel blocks forever.
+func chansend(c *chan_, elem unsafe.Pointer) {
+	if c == nil {
+		gopark(nil)
+	}
+	copyfn := copyalgat(unsafe.Pointer(c.elemtyp.alg))
+	lock(&c.lock)
+	if c.closed {
+		unlock(&c.lock)
+		panic(plainError("send on closed channel"))
+	}
+	if sg := c.recvq.dequeue(); sg != nil {
+		copyfn(c.elemtyp.size, sg.elem, elem)
+		sg.ok = true
+		unlock(&c.lock)
+		goready(sg.g)
+		return
+	}
+	if c.len < c.cap {
+		copyfn(c.elemtyp.size, c.elemat((c.head+c.len)%c.cap), elem)
+		c.len++
+		unlock(&c.lock)
+		return
+	}
+	if !c.wait(&c.sendq, elem) {
+		panic(plainError("send on closed channel"))
+	}
+}
+
+// chanrecv receives an element from the channel, storing it in the memory
+// pointed to by elem, blocking until there is an element to receive. If
+// the channel is closed and empty, the element's zero value is stored and
+// false is returned. Receiving from a nil channel blocks forever.
+func chanrecv(c *chan_, elem unsafe.Pointer) bool {
+	if c == nil {
+		gopark(nil)
+	}
+	copyfn := copyalgat(unsafe.Pointer(c.elemtyp.alg))
+	lock(&c.lock)
+	if c.len > 0 {
+		copyfn(c.elemtyp.size, elem, c.elemat(c.head))
+		c.head = (c.head + 1) % c.cap
+		c.len--
+		// Move a blocked sender's element into the space made.
+		sg := c.sendq.dequeue()
+		if sg != nil {
+			copyfn(c.elemtyp.size, c.elemat((c.head+c.len)%c.cap), sg.elem)
+			c.len++
+			sg.ok = true
+		}
+		unlock(&c.lock)
+		if sg != nil {
+			goready(sg.g)
+		}
+		return true
+	}
+	if sg := c.sendq.dequeue(); sg != nil {
+		copyfn(c.elemtyp.size, elem, sg.elem)
+		sg.ok = true
+		unlock(&c.lock)
+		goready(sg.g)
+		return true
+	}
+	if c.closed {
+		unlock(&c.lock)
+		copyfn(c.elemtyp.size, elem, nil)
+		return false
+	}
+	return c.wait(&c.recvq, elem)
+}
+
+// chanclose closes the channel. Blocked receivers receive the zero value,
+// and blocked senders panic. Other receivers observe the closed state
+// once the channel's buffer has been drained.
+func chanclose(c *chan_) {
+	if c == nil {
+		panic(plainError("close of nil channel"))
+	}
+	lock(&c.lock)
+	if c.closed {
+		unlock(&c.lock)
+		panic(plainError("close of closed channel"))
+	}
+	c.closed = true
+	copyfn := copyalgat(unsafe.Pointer(c.elemtyp.alg))
+	var waiters waitq
+	for sg := c.recvq.dequeue(); sg != nil; sg = c.recvq.dequeue() {
+		copyfn(c.elemtyp.size, sg.elem, nil)
+		waiters.enqueue(sg)
+	}
+	for sg := c.sendq.dequeue(); sg != nil; sg = c.sendq.dequeue() {
+		waiters.enqueue(sg)
+	}
+	unlock(&c.lock)
+	// A readied goroutine frees its sudog, so take the next first.
+	for sg := waiters.first; sg != nil; {
+		next := sg.next
+		goready(sg.g)
+		sg = next
+	}
+}
+
+// chancap returns the capacity of the channel's buffer, or zero if the
+// channel is nil.
+func chancap(c *chan_) int {
+	if c == nil {
+		return 0
+	}
+	return c.cap
+}
+
+// chanlen returns the number of elements queued in the channel's buffer.
+// An element being handed to a receiver on an unbuffered channel is not
+// counted.
+func chanlen(c *chan_) int {
+	if c == nil || c.cap == 0 {
+		return 0
+	}
+	return c.len
+}
+
+// vim: set ft=go :
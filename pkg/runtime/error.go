@@ -74,12 +74,110 @@ func newErrorString(s string, ret *interface{}) {
 	*ret = errorString(s)
 }
 
+// A plainError is a runtime error whose message is not prefixed with
+// "runtime error: ", as for misuse of maps and channels.
+type plainError string
+
+func (e plainError) RuntimeError() {}
+
+func (e plainError) Error() string {
+	return string(e)
+}
+
+// A boundsError represents an index or slice expression whose operands
+// are out of range. x is the offending index or bound, and y the bound it
+// exceeded; code identifies the check that failed.
+type boundsError struct {
+	x    int
+	y    int
+	code uint8
+}
+
+const (
+	boundsIndex      = iota // s[x], 0 <= x < len(s) failed
+	boundsSliceAlen         // s[?:x], 0 <= x <= len(s) failed
+	boundsSliceAcap         // s[?:x], 0 <= x <= cap(s) failed
+	boundsSliceB            // s[x:y], 0 <= x <= y failed
+	boundsSlice3Alen        // s[?:?:x], 0 <= x <= len(s) failed
+	boundsSlice3Acap        // s[?:?:x], 0 <= x <= cap(s) failed
+	boundsSlice3B           // s[?:x:y], 0 <= x <= y failed
+	boundsSlice3C           // s[x:y:?], 0 <= x <= y failed
+)
+
+// boundsErrorFmt holds the messages for each code, where x and y are
+// replaced by the values of the corresponding fields.
+var boundsErrorFmt = [...]string{
+	boundsIndex:      "index out of range [x] with length y",
+	boundsSliceAlen:  "slice bounds out of range [:x] with length y",
+	boundsSliceAcap:  "slice bounds out of range [:x] with capacity y",
+	boundsSliceB:     "slice bounds out of range [x:y]",
+	boundsSlice3Alen: "slice bounds out of range [::x] with length y",
+	boundsSlice3Acap: "slice bounds out of range [::x] with capacity y",
+	boundsSlice3B:    "slice bounds out of range [:x:y]",
+	boundsSlice3C:    "slice bounds out of range [x:y:]",
+}
+
+// boundsNegErrorFmt holds the messages used when x is negative, in which
+// case y is irrelevant.
+var boundsNegErrorFmt = [...]string{
+	boundsIndex:      "index out of range [x]",
+	boundsSliceAlen:  "slice bounds out of range [:x]",
+	boundsSliceAcap:  "slice bounds out of range [:x]",
+	boundsSliceB:     "slice bounds out of range [x:]",
+	boundsSlice3Alen: "slice bounds out of range [::x]",
+	boundsSlice3Acap: "slice bounds out of range [::x]",
+	boundsSlice3B:    "slice bounds out of range [:x:]",
+	boundsSlice3C:    "slice bounds out of range [x::]",
+}
+
+func (e boundsError) RuntimeError() {}
+
+func (e boundsError) Error() string {
+	fmt := boundsErrorFmt[e.code]
+	if e.x < 0 {
+		fmt = boundsNegErrorFmt[e.code]
+	}
+	s := "runtime error: "
+	for i := 0; i < len(fmt); i++ {
+		switch fmt[i] {
+		case 'x':
+			s += itoa(e.x)
+		case 'y':
+			s += itoa(e.y)
+		default:
+			s += fmt[i : i+1]
+		}
+	}
+	return s
+}
+
+// itoa returns the decimal representation of n.
+func itoa(n int) string {
+	var buf [20]byte
+	i := len(buf)
+	neg := n < 0
+	u := uint64(n)
+	if neg {
+		u = uint64(-n)
+	}
+	for u >= 10 {
+		i--
+		buf[i] = byte('0' + u%10)
+		u /= 10
+	}
+	i--
+	buf[i] = byte('0' + u)
+	if neg {
+		i--
+		buf[i] = '-'
+	}
+	return string(buf[i:])
+}
+
 type stringer interface {
 	String() string
 }
 
-func typestring(interface{}) string
-
 // For calling from C.
 // Prints an argument passed to panic.
 // There's room for arbitrary complexity here, but we keep it
@@ -0,0 +1,373 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package runtime
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// The garbage collector is a conservative, non-moving mark-sweep
+// collector. Every word in the registered roots (global variables), the
+// stack, and reachable heap blocks is treated as a potential pointer; a
+// word pointing anywhere within a heap block keeps the block alive.
+//
+// The collector stops the world while it marks; see stoptheworld. The
+// stack of the goroutine calling GC is scanned from its current frame. The
+// stacks of other goroutines are scanned from the stack pointer saved when
+// they last switched to the scheduler, stopped at a safe point or entered
+// a system call, along with their saved registers and the arguments of
+// those not yet started.
+//
+// If the program is compiled with precise garbage collection, stacks are
+// instead scanned using the shadow stack maintained by the compiler: a
+// linked list with an entry for each active function with roots, holding
+// the address of each root and a map of the pointers within it. Each
+// thread has its own list, whose head is held in a thread-local variable.
+// The scheduler saves the head in each goroutine that switches out, sets
+// the thread's head to a goroutine's saved head before the goroutine
+// resumes, and restores its own once the goroutine switches back; the
+// collector scans the list of each goroutine from its saved head.
+//
+//	struct entry { entry *next; uintptr nroots; struct { void *root; gcrootmap *map; } roots[]; };
+//
+// The compiler provides a map for every root, describing the offsets of
+// the pointers within it; see gcrootmap.
+
+// gcblock is the header preceding each block of memory allocated by
+// mallocgc.
+type gcblock struct {
+	next   *gcblock // next in the list of all blocks
+	gray   *gcblock // next in the list of blocks to be scanned
+	size   uintptr  // size of the block, excluding the header
+	marked bool
+}
+
+// gcroot is a range of memory outside the heap that is scanned for
+// pointers into the heap.
+type gcroot struct {
+	next       *gcroot
+	start, end uintptr
+}
+
+// gcminheap is the smallest heap size at which a collection is triggered.
+const gcminheap = 4 << 20
+
+var (
+	gcblocks  *gcblock
+	gcnblocks int
+	gcgray    *gcblock
+	gcroots   *gcroot
+
+	// gcsorted is an array of all blocks, sorted by address, which is
+	// used to look up blocks during a collection.
+	gcsorted uintptr
+)
+
+// gcrootmap is the layout of a root in the shadow stack. The header is
+// followed by nptrs pointer offsets.
+type gcrootmap struct {
+	nptrs uintptr
+}
+
+// heaplock serialises allocation and collection across threads. It is
+// acquired with lockheap.
+var heaplock int32
+
+// gcscanstack scans the calling goroutine's stack with gcscan, from the
+// caller's frame to base, or to the base of the main thread's stack if
+// base is zero. It is defined by the compiler.
+func gcscanstack(base uintptr)
+
+// gcshadowstack returns the head of the calling thread's shadow stack, or
+// zero if it is empty. It is defined by the compiler.
+func gcshadowstack() uintptr
+
+// gcsetshadowstack sets the head of the calling thread's shadow stack to
+// the specified entry. It is defined by the compiler.
+func gcsetshadowstack(entry uintptr)
+
+// mallocgc allocates size bytes of zeroed memory, which is freed by the
+// garbage collector once it is no longer reachable.
+func mallocgc(size uintptr) unsafe.Pointer {
+	if size == 0 {
+		size = 1
+	}
+	lockheap()
+	if memStats.NextGC == 0 {
+		memStats.NextGC = gcminheap
+	}
+	if memStats.HeapAlloc+uint64(size) > memStats.NextGC {
+		gc()
+	}
+
+	hdrsize := unsafe.Sizeof(gcblock{})
+	b := (*gcblock)(malloc(int(hdrsize + size)))
+	b.size = size
+	b.next = gcblocks
+	gcblocks = b
+	gcnblocks++
+
+	memStats.Mallocs++
+	memStats.TotalAlloc += uint64(size)
+	memStats.HeapAlloc += uint64(size)
+	memStats.HeapObjects++
+	memStats.Alloc = memStats.HeapAlloc
+	unlock(&heaplock)
+	return unsafe.Pointer(uintptr(unsafe.Pointer(b)) + hdrsize)
+}
+
+// gcaddroots registers size bytes of memory at start as a root, to be
+// scanned for pointers at each collection.
+func gcaddroots(start, size uintptr) {
+	r := (*gcroot)(malloc(int(unsafe.Sizeof(gcroot{}))))
+	r.start = start
+	r.end = start + size
+	lockheap()
+	r.next = gcroots
+	gcroots = r
+	unlock(&heaplock)
+}
+
+// lockheap acquires heaplock. A collection holds the lock while it stops
+// the world, so a thread waiting for it then stops, as at any other safe
+// point.
+func lockheap() {
+	for !atomic.CompareAndSwapInt32(&heaplock, 0, 1) {
+		if atomic.LoadInt32(&sched.gcwaiting) != 0 {
+			gcstopm(getm())
+			continue
+		}
+		yield()
+	}
+}
+
+// GC runs a garbage collection.
+func GC() {
+	lockheap()
+	gc()
+	unlock(&heaplock)
+}
+
+// gc runs a garbage collection, stopping the world while it marks.
+// heaplock must be held.
+func gc() {
+	if sched.initdone {
+		stoptheworld()
+	}
+	gcsort()
+	for r := gcroots; r != nil; r = r.next {
+		gcscan(r.start, r.end)
+	}
+	entry := gcshadowstack()
+	precise := entry != 0
+	if precise {
+		gcscanshadowstack(entry)
+	} else if sched.initdone {
+		gcscanstack(getg().stackhi)
+	} else {
+		gcscanstack(0)
+	}
+	if sched.initdone {
+		gcscangoroutines(getg(), precise)
+	}
+	for gcgray != nil {
+		b := gcgray
+		gcgray = b.gray
+		start := uintptr(unsafe.Pointer(b)) + unsafe.Sizeof(gcblock{})
+		gcscan(start, start+b.size)
+	}
+	if sched.initdone {
+		// Unmarked blocks are unreachable, so goroutines may run while
+		// they are swept; they cannot allocate until heaplock is released.
+		starttheworld()
+	}
+	free(unsafe.Pointer(gcsorted))
+	gcsorted = 0
+	gcsweep()
+
+	memStats.NumGC++
+	memStats.NextGC = memStats.HeapAlloc * 2
+	if memStats.NextGC < gcminheap {
+		memStats.NextGC = gcminheap
+	}
+}
+
+// gcscan marks each unmarked block pointed to by a word in the range
+// [start, end), and queues it to be scanned.
+func gcscan(start, end uintptr) {
+	wordsize := unsafe.Sizeof(start)
+	for p := align(start, uint8(wordsize)); p+wordsize <= end; p += wordsize {
+		b := gcfind(*(*uintptr)(unsafe.Pointer(p)))
+		if b != nil && !b.marked {
+			b.marked = true
+			b.gray = gcgray
+			gcgray = b
+		}
+	}
+}
+
+// gcscangoroutines scans the stacks, saved registers and unstarted
+// arguments of each live goroutine other than curg; or, if precise is
+// true, the shadow stacks and unstarted arguments. The world must be
+// stopped, so that the registers and shadow stacks of running goroutines
+// have been saved.
+func gcscangoroutines(curg *g, precise bool) {
+	lock(&sched.lock)
+	for gp := sched.allgs; gp != nil; gp = gp.alllink {
+		if gp == curg || atomic.LoadInt32(&gp.status) == gDead {
+			continue
+		}
+		if precise {
+			gcscanshadowstack(gp.gcroots)
+		} else {
+			ctx := uintptr(gp.context)
+			gcscan(ctx, ctx+ucontextSize)
+			if sp := *(*uintptr)(unsafe.Pointer(ctx + ucontextSp)); gp.stacklo <= sp && sp < gp.stackhi {
+				gcscan(sp, gp.stackhi)
+			}
+		}
+		if gp.argsize != 0 {
+			gcscan(uintptr(gp.arg), uintptr(gp.arg)+gp.argsize)
+		}
+	}
+	unlock(&sched.lock)
+}
+
+// gcscanshadowstack scans the roots in each shadow stack entry, starting
+// with the specified entry, marking the blocks they point to.
+func gcscanshadowstack(entry uintptr) {
+	wordsize := unsafe.Sizeof(entry)
+	for ; entry != 0; entry = *(*uintptr)(unsafe.Pointer(entry)) {
+		nroots := *(*uintptr)(unsafe.Pointer(entry + wordsize))
+		for i := uintptr(0); i < nroots; i++ {
+			slot := entry + (2+2*i)*wordsize
+			root := *(*uintptr)(unsafe.Pointer(slot))
+			m := *(**gcrootmap)(unsafe.Pointer(slot + wordsize))
+			ptrs := uintptr(unsafe.Pointer(m)) + unsafe.Sizeof(gcrootmap{})
+			for j := uintptr(0); j < m.nptrs; j++ {
+				p := root + *(*uintptr)(unsafe.Pointer(ptrs + j*wordsize))
+				gcscan(p, p+wordsize)
+			}
+		}
+	}
+}
+
+// gcsweep frees each unmarked block, and clears the marks of the rest.
+func gcsweep() {
+	var prev *gcblock
+	b := gcblocks
+	for b != nil {
+		next := b.next
+		if b.marked {
+			b.marked = false
+			prev = b
+		} else {
+			if prev == nil {
+				gcblocks = next
+			} else {
+				prev.next = next
+			}
+			gcnblocks--
+			memStats.Frees++
+			memStats.HeapAlloc -= uint64(b.size)
+			memStats.HeapObjects--
+			free(unsafe.Pointer(b))
+		}
+		b = next
+	}
+	memStats.Alloc = memStats.HeapAlloc
+}
+
+// gcblockat returns a pointer to the i'th element of gcsorted.
+func gcblockat(i int) **gcblock {
+	return (**gcblock)(unsafe.Pointer(gcsorted + uintptr(i)*unsafe.Sizeof(gcsorted)))
+}
+
+// gcsort fills gcsorted with the blocks in gcblocks, heapsorted by address.
+func gcsort() {
+	n := gcnblocks
+	gcsorted = uintptr(malloc(n * int(unsafe.Sizeof(gcsorted))))
+	i := 0
+	for b := gcblocks; b != nil; b = b.next {
+		*gcblockat(i) = b
+		i++
+	}
+	for i := n/2 - 1; i >= 0; i-- {
+		gcsiftdown(i, n)
+	}
+	for i := n - 1; i > 0; i-- {
+		gcswap(0, i)
+		gcsiftdown(0, i)
+	}
+}
+
+func gcswap(i, j int) {
+	pi, pj := gcblockat(i), gcblockat(j)
+	tmp := *pi
+	*pi = *pj
+	*pj = tmp
+}
+
+func gcsiftdown(root, n int) {
+	for {
+		child := 2*root + 1
+		if child >= n {
+			return
+		}
+		if child+1 < n && gcless(child, child+1) {
+			child++
+		}
+		if !gcless(root, child) {
+			return
+		}
+		gcswap(root, child)
+		root = child
+	}
+}
+
+func gcless(i, j int) bool {
+	return uintptr(unsafe.Pointer(*gcblockat(i))) < uintptr(unsafe.Pointer(*gcblockat(j)))
+}
+
+// gcfind returns the block containing the address p, or nil if p does not
+// point into a block.
+func gcfind(p uintptr) *gcblock {
+	hdrsize := unsafe.Sizeof(gcblock{})
+	lo, hi := 0, gcnblocks
+	for lo < hi {
+		mid := (lo + hi) / 2
+		b := *gcblockat(mid)
+		start := uintptr(unsafe.Pointer(b)) + hdrsize
+		if p < start {
+			hi = mid
+		} else if p >= start+b.size {
+			lo = mid + 1
+		} else {
+			return b
+		}
+	}
+	return nil
+}
+
+// vim: set ft=go :
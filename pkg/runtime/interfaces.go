@@ -25,12 +25,14 @@ package runtime
 import "unsafe"
 
 func compareI2I(atyp, btyp, aval, bval uintptr) bool {
+	if atyp == 0 || btyp == 0 {
+		// Interfaces are nil if their type is nil.
+		return atyp == btyp
+	}
 	if atyp == btyp {
 		atyp := (*type_)(unsafe.Pointer(atyp))
 		btyp := (*type_)(unsafe.Pointer(btyp))
-		algs := unsafe.Pointer(atyp.alg)
-		eqPtr := unsafe.Pointer(uintptr(algs) + unsafe.Sizeof(algs))
-		eqFn := *(*equalalg)(eqPtr)
+		eqFn := equalalgat(unsafe.Pointer(atyp.alg))
 		var avalptr, bvalptr unsafe.Pointer
 		if atyp.size <= unsafe.Sizeof(aval) {
 			// value fits in pointer
@@ -44,3 +46,112 @@ func compareI2I(atyp, btyp, aval, bval uintptr) bool {
 	}
 	return false
 }
+
+// missingmethod returns the name of the first method of the interface type
+// iface that is not in the method set of the type t, or nil if t implements
+// iface. A method of t implements one of iface only if it has the same name
+// and type, and, if the name is unexported, the same package path. If fns
+// is non-nil, the functions implementing the interface's methods are stored
+// in the array it points to.
+func missingmethod(t *type_, iface *interfaceType, fns unsafe.Pointer) *string {
+	var methods []_method
+	if t.uncommon != nil {
+		methods = t.uncommon.methods
+	}
+	// Both method sets are sorted by name. Unexported methods with the
+	// same name from different packages are adjacent.
+	j := 0
+	for i := 0; i < len(iface.methods); i++ {
+		imethod := &iface.methods[i]
+		name := imethod.name
+		for j < len(methods) && *methods[j].name < *name {
+			j++
+		}
+		k := j
+		for k < len(methods) && *methods[k].name == *name && !samepkgpath(methods[k].pkgPath, imethod.pkgPath) {
+			k++
+		}
+		if k == len(methods) || *methods[k].name != *name {
+			return name
+		}
+		// Type descriptors are unique, so the types are identical only
+		// if their descriptors are the same.
+		if unsafe.Pointer(methods[k].mtyp) != unsafe.Pointer(imethod.typ) {
+			return name
+		}
+		if fns != nil {
+			fnptr := uintptr(fns) + uintptr(i)*unsafe.Sizeof(fns)
+			*(*unsafe.Pointer)(unsafe.Pointer(fnptr)) = methods[k].ifn
+		}
+	}
+	return nil
+}
+
+// samepkgpath reports whether two package paths, which are nil for
+// exported names, are the same.
+func samepkgpath(a, b *string) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil {
+		return false
+	}
+	return *a == *b
+}
+
+// itab is the type word of a non-empty interface, recording the
+// interface's type and dynamic type. It is followed in memory by the
+// functions implementing the interface's methods, in the order of the
+// interface's methods. The compiler loads these words by index.
+type itab struct {
+	inter *type_
+	typ   *type_
+	link  *itab
+}
+
+// itabtablesize is the number of buckets in itabtable.
+const itabtablesize = 1009
+
+// itabtable caches itabs by interface type and dynamic type. Itabs are
+// never freed, so they are allocated outside of the garbage collected heap.
+//
+// TODO synchronise access to itabtable once the runtime has locking
+// primitives; until then, racing insertions may lose an itab, which is
+// then created again on the next lookup.
+var itabtable [itabtablesize]*itab
+
+// getitab returns the itab for the interface type iface and the dynamic
+// type t, creating it and adding it to the cache if necessary. getitab
+// returns zero if t is zero, or if t does not implement iface.
+func getitab(iface, t uintptr) uintptr {
+	if t == 0 {
+		return 0
+	}
+	h := (iface ^ t) % itabtablesize
+	for m := itabtable[h]; m != nil; m = m.link {
+		inter := uintptr(unsafe.Pointer(m.inter))
+		typ := uintptr(unsafe.Pointer(m.typ))
+		if inter == iface && typ == t {
+			return uintptr(unsafe.Pointer(m))
+		}
+	}
+
+	typ := (*type_)(unsafe.Pointer(t))
+	ifacetyp := (*type_)(unsafe.Pointer(iface))
+	ityp := (*interfaceType)(unsafe.Pointer(&ifacetyp.commonType))
+	hdrsize := unsafe.Sizeof(itab{})
+	fnsize := uintptr(len(ityp.methods)) * unsafe.Sizeof(t)
+	m := (*itab)(malloc(int(hdrsize + fnsize)))
+	fns := unsafe.Pointer(uintptr(unsafe.Pointer(m)) + hdrsize)
+	if missingmethod(typ, ityp, fns) != nil {
+		free(unsafe.Pointer(m))
+		return 0
+	}
+	m.inter = ifacetyp
+	m.typ = typ
+	m.link = itabtable[h]
+	itabtable[h] = m
+	return uintptr(unsafe.Pointer(m))
+}
+
+// vim: set ft=go :
@@ -24,116 +24,236 @@ package runtime
 
 import "unsafe"
 
+// map_ is the runtime representation of a map. Maps are implemented as
+// open-addressing hash tables with linear probing. Deleted entries are
+// marked with tombstones, which are discarded when the table is rehashed.
 type map_ struct {
-	length int32
-	head   *mapentry
+	length   int            // number of live entries; must be first
+	used     int            // number of live and deleted entries
+	nbuckets uintptr        // number of buckets; always a power of two
+	buckets  unsafe.Pointer // bucket array
 }
 
-type mapentry struct {
-	next *mapentry
-	// after this comes the key, then the value.
+// mapbucket is the header of each bucket in a map's bucket array. After
+// the header comes the key, then the value.
+type mapbucket struct {
+	state uintptr
+	hash  uintptr
 }
 
-func maplookup(t unsafe.Pointer, m *map_, key unsafe.Pointer, insert bool) unsafe.Pointer {
-	if m == nil {
-		return 0
-	}
+// Bucket states.
+const (
+	bucketEmpty = iota
+	bucketFull
+	bucketDeleted
+)
+
+// minBuckets is the minimum number of buckets in a non-nil map.
+const minBuckets = 8
+
+// maplayout describes the layout of buckets for a map type, and the
+// algorithms used to hash and compare keys.
+type maplayout struct {
+	keysize    uintptr
+	keyoffset  uintptr
+	elemsize   uintptr
+	elemoffset uintptr
+	bucketsize uintptr
+	keyhash    hashalg
+	keyequal   equalalg
+}
 
+func initmaplayout(l *maplayout, t unsafe.Pointer) {
 	typ := (*type_)(t)
 	maptyp := (*mapType)(unsafe.Pointer(&typ.commonType))
-	ptrsize := uintptr(unsafe.Sizeof(m.head.next))
-	keysize := uintptr(maptyp.key.size)
-	keyoffset := align(ptrsize, maptyp.key.align)
-	elemsize := uintptr(maptyp.elem.size)
-	elemoffset := align(keyoffset+keysize, maptyp.elem.align)
-	entrysize := elemoffset + elemsize
-
-	// Search for the entry with the specified key.
+	var b mapbucket
+	ptrsize := uint8(unsafe.Sizeof(b.hash))
+	l.keysize = maptyp.key.size
+	l.keyoffset = align(unsafe.Sizeof(b), maptyp.key.align)
+	l.elemsize = maptyp.elem.size
+	l.elemoffset = align(l.keyoffset+l.keysize, maptyp.elem.align)
+	l.bucketsize = align(l.elemoffset+l.elemsize, ptrsize)
+
 	keyalgs := unsafe.Pointer(maptyp.key.alg)
-	keyeqptr := unsafe.Pointer(uintptr(keyalgs) + unsafe.Sizeof(maptyp.key.alg))
-	keyeqfun := *(*equalalg)(keyeqptr)
-	var last *mapentry
-	for ptr := m.head; ptr != nil; ptr = ptr.next {
-		keyptr := unsafe.Pointer(uintptr(unsafe.Pointer(ptr)) + keyoffset)
-		if keyeqfun(keysize, key, keyptr) {
-			elemptr := unsafe.Pointer(uintptr(unsafe.Pointer(ptr)) + elemoffset)
-			return elemptr
+	l.keyhash = hashalgat(keyalgs)
+	l.keyequal = equalalgat(keyalgs)
+}
+
+// mapbucketat returns a pointer to the i'th bucket in a map.
+func mapbucketat(l *maplayout, m *map_, i uintptr) *mapbucket {
+	return (*mapbucket)(unsafe.Pointer(uintptr(m.buckets) + i*l.bucketsize))
+}
+
+// mapfind returns the bucket containing the specified key, or nil if
+// the key is not in the map.
+func mapfind(l *maplayout, m *map_, key unsafe.Pointer, hash uintptr) *mapbucket {
+	keyequal := l.keyequal
+	mask := m.nbuckets - 1
+	i := hash & mask
+	for n := uintptr(0); n < m.nbuckets; n++ {
+		b := mapbucketat(l, m, i)
+		if b.state == bucketEmpty {
+			return nil
 		}
-		last = ptr
+		if b.state == bucketFull && b.hash == hash {
+			keyptr := unsafe.Pointer(uintptr(unsafe.Pointer(b)) + l.keyoffset)
+			if keyequal(l.keysize, key, keyptr) {
+				return b
+			}
+		}
+		i = (i + 1) & mask
+	}
+	return nil
+}
+
+// mapfree returns the first bucket along the probe sequence for the
+// specified hash that does not contain a live entry. The map must not
+// be full.
+func mapfree(l *maplayout, m *map_, hash uintptr) *mapbucket {
+	mask := m.nbuckets - 1
+	i := hash & mask
+	b := mapbucketat(l, m, i)
+	for b.state == bucketFull {
+		i = (i + 1) & mask
+		b = mapbucketat(l, m, i)
 	}
+	return b
+}
 
-	// Not found: insert the key if requested.
-	if insert {
-		newentry := (*mapentry)(malloc(entrysize))
-		newentry.next = nil
-		keyptr := unsafe.Pointer(uintptr(unsafe.Pointer(newentry) + keyoffset))
-		elemptr := unsafe.Pointer(uintptr(unsafe.Pointer(newentry)) + elemoffset)
-		memcpy(keyptr, key, keysize)
-		if last != nil {
-			last.next = newentry
-		} else {
-			m.head = newentry
+// maprehash reallocates a map's bucket array, growing it if the number
+// of live entries requires it, and discarding tombstones.
+func maprehash(l *maplayout, m *map_) {
+	oldbuckets := m.buckets
+	oldnbuckets := m.nbuckets
+	nbuckets := oldnbuckets
+	for uintptr(m.length+1)*2 > nbuckets {
+		nbuckets <<= 1
+	}
+	m.buckets = mallocgc(nbuckets * l.bucketsize)
+	m.nbuckets = nbuckets
+	m.used = m.length
+	for i := uintptr(0); i < oldnbuckets; i++ {
+		b := (*mapbucket)(unsafe.Pointer(uintptr(oldbuckets) + i*l.bucketsize))
+		if b.state == bucketFull {
+			newb := mapfree(l, m, b.hash)
+			memcpy(unsafe.Pointer(newb), unsafe.Pointer(b), int(l.bucketsize))
 		}
-		m.length++
-		return elemptr
 	}
+	// The old buckets are left to the garbage collector.
+}
 
-	return 0
+func makemap(t unsafe.Pointer, cap int) *map_ {
+	var l maplayout
+	initmaplayout(&l, t)
+	var hdr map_
+	m := (*map_)(mallocgc(unsafe.Sizeof(hdr)))
+	nbuckets := uintptr(minBuckets)
+	for nbuckets*3 < uintptr(cap)*4 {
+		nbuckets <<= 1
+	}
+	m.nbuckets = nbuckets
+	m.buckets = mallocgc(nbuckets * l.bucketsize)
+	return m
 }
 
-func mapdelete(t unsafe.Pointer, m *map_, key unsafe.Pointer) {
+func maplookup(t unsafe.Pointer, m *map_, key unsafe.Pointer, insert bool) unsafe.Pointer {
 	if m == nil {
-		return 0
+		if insert {
+			panic(plainError("assignment to entry in nil map"))
+		}
+		return nil
 	}
 
-	typ := (*type_)(t)
-	maptyp := (*mapType)(unsafe.Pointer(&typ.commonType))
-	ptrsize := uintptr(unsafe.Sizeof(m.head.next))
-	keysize := uintptr(maptyp.key.size)
-	keyoffset := align(ptrsize, maptyp.key.align)
+	var l maplayout
+	initmaplayout(&l, t)
+	keyhash := l.keyhash
+	hash := keyhash(l.keysize, key)
+	b := mapfind(&l, m, key, hash)
+	if b != nil {
+		return unsafe.Pointer(uintptr(unsafe.Pointer(b)) + l.elemoffset)
+	}
+	if !insert {
+		return nil
+	}
 
-	// Search for the entry with the specified key.
-	keyalgs := unsafe.Pointer(maptyp.key.alg)
-	keyeqptr := unsafe.Pointer(uintptr(keyalgs) + unsafe.Sizeof(maptyp.key.alg))
-	keyeqfun := *(*equalalg)(keyeqptr)
-	var last *mapentry
-	for ptr := m.head; ptr != nil; ptr = ptr.next {
-		keyptr := unsafe.Pointer(uintptr(unsafe.Pointer(ptr)) + keyoffset)
-		if keyeqfun(keysize, key, keyptr) {
-			if last == nil {
-				m.head = ptr.next
-			} else {
-				last.next = ptr.next
-			}
-			free(ptr)
-			m.length--
-			return
-		}
-		last = ptr
+	// Not found: insert the key, rehashing first if the load factor
+	// (including tombstones) would exceed 3/4.
+	if uintptr(m.used+1)*4 > m.nbuckets*3 {
+		maprehash(&l, m)
+	}
+	b = mapfree(&l, m, hash)
+	if b.state == bucketEmpty {
+		m.used++
 	}
+	b.state = bucketFull
+	b.hash = hash
+	keyptr := unsafe.Pointer(uintptr(unsafe.Pointer(b)) + l.keyoffset)
+	elemptr := unsafe.Pointer(uintptr(unsafe.Pointer(b)) + l.elemoffset)
+	memcpy(keyptr, key, int(l.keysize))
+	memset(elemptr, 0, int(l.elemsize))
+	m.length++
+	return elemptr
 }
 
-func mapnext(t unsafe.Pointer, m *map_, nextin unsafe.Pointer) (nextout, pk, pv unsafe.Pointer) {
+func mapdelete(t unsafe.Pointer, m *map_, key unsafe.Pointer) {
 	if m == nil {
 		return
 	}
-	ptr := (*mapentry)(nextin)
-	if ptr == nil {
-		ptr = m.head
-	} else {
-		ptr = ptr.next
+
+	var l maplayout
+	initmaplayout(&l, t)
+	keyhash := l.keyhash
+	b := mapfind(&l, m, key, keyhash(l.keysize, key))
+	if b != nil {
+		b.state = bucketDeleted
+		m.length--
 	}
-	if ptr != nil {
-		typ := (*type_)(t)
-		maptyp := (*mapType)(unsafe.Pointer(&typ.commonType))
-		ptrsize := uintptr(unsafe.Sizeof(m.head.next))
-		keysize := uintptr(maptyp.key.size)
-		keyoffset := align(ptrsize, maptyp.key.align)
-		elemsize := uintptr(maptyp.elem.size)
-		elemoffset := align(keyoffset+keysize, maptyp.elem.align)
-		nextout = unsafe.Pointer(ptr)
-		pk = unsafe.Pointer(uintptr(unsafe.Pointer(ptr)) + keyoffset)
-		pv = unsafe.Pointer(uintptr(unsafe.Pointer(ptr)) + elemoffset)
+}
+
+// mapiter holds the state of an iteration over a map. The compiler
+// allocates a mapiter for each range statement over a map, so its layout
+// must be kept in sync with mapIterInit.
+type mapiter struct {
+	key  unsafe.Pointer // current key; nil when iteration is complete
+	elem unsafe.Pointer // current value
+	t    unsafe.Pointer // map type
+	m    *map_
+	next uintptr // index of the next bucket to visit
+}
+
+// mapiterinit initialises an iterator over m, and advances it to the
+// first entry.
+func mapiterinit(t unsafe.Pointer, m *map_, it *mapiter) {
+	it.t = t
+	it.m = m
+	it.next = 0
+	mapiternext(it)
+}
+
+// mapiternext advances an iterator to the next entry in the map, setting
+// it.key to nil if there are no more entries.
+//
+// Deleting entries during iteration is safe, as buckets are never moved
+// by a deletion; deleted entries that have not yet been reached will not
+// be produced. Entries inserted during iteration may cause the map to be
+// rehashed, in which case iteration order is unspecified.
+func mapiternext(it *mapiter) {
+	it.key = nil
+	it.elem = nil
+	m := it.m
+	if m == nil {
+		return
+	}
+
+	var l maplayout
+	initmaplayout(&l, it.t)
+	for it.next < m.nbuckets {
+		b := mapbucketat(&l, m, it.next)
+		it.next++
+		if b.state == bucketFull {
+			it.key = unsafe.Pointer(uintptr(unsafe.Pointer(b)) + l.keyoffset)
+			it.elem = unsafe.Pointer(uintptr(unsafe.Pointer(b)) + l.elemoffset)
+			return
+		}
 	}
-	return
 }
@@ -0,0 +1,241 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package runtime
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// The network poller lets goroutines wait for file descriptors to become
+// ready for I/O without each blocking a thread. A descriptor in
+// non-blocking mode is registered with the poller by netpollopen; a
+// goroutine whose read or write fails with EAGAIN calls netpollwait, which
+// parks it until the poller reports the descriptor ready, and then retries.
+// Readiness may be reported spuriously, so the operation may fail with
+// EAGAIN again.
+//
+// While goroutines are waiting, a single thread blocks in the poller and
+// readies them. It holds no processor, and exits once none are waiting.
+//
+// The functions are intended for packages such as net, which declare them
+// with "#llgo name" directives, passing descriptors as uintptr values:
+//
+//     // #llgo name: runtime.netpollwait
+//     func runtime_netpollwait(pd uintptr, mode int32) int32
+
+// Modes of netpollwait.
+const (
+	pollRead  = 'r'
+	pollWrite = 'w'
+)
+
+// Results of netpollwait.
+const (
+	pollReady = iota
+	pollClosing
+)
+
+// pollDesc is a descriptor registered with the poller. rg and wg are the
+// goroutines waiting for it to become readable and writable; rready and
+// wready record readiness not yet consumed by a waiter.
+type pollDesc struct {
+	lock           int32
+	fd             int32
+	rg, wg         *g
+	rready, wready bool
+	closing        bool
+	link           *pollDesc // next in poll.free
+}
+
+var poll struct {
+	// lock protects inited and free. pollDescs are never freed, as the
+	// poller may still be reporting events for a closed descriptor, but
+	// are reused by netpollopen.
+	lock   int32
+	inited bool
+	free   *pollDesc
+
+	// nwait is the number of goroutines parked in netpollwait, and
+	// polling is non-zero while a thread is running pollloop. Both are
+	// updated atomically.
+	nwait   int32
+	polling int32
+}
+
+// pollloopfunc is pollloop, whose code address is passed to C. It is set
+// by netpollinit.
+var pollloopfunc func(unsafe.Pointer) unsafe.Pointer
+
+// netpollinit initialises the poller, the first time it is called.
+func netpollinit() {
+	lock(&poll.lock)
+	if !poll.inited {
+		pollloopfunc = pollloop
+		netpollcreate()
+		poll.inited = true
+	}
+	unlock(&poll.lock)
+}
+
+// netpollopen registers the descriptor fd, which should be in non-blocking
+// mode, with the poller. It returns the descriptor's pollDesc, or an errno
+// value if it could not be registered.
+func netpollopen(fd int32) (uintptr, int32) {
+	netpollinit()
+	lock(&poll.lock)
+	pd := poll.free
+	if pd != nil {
+		poll.free = pd.link
+	} else {
+		pd = (*pollDesc)(malloc(int(unsafe.Sizeof(pollDesc{}))))
+	}
+	unlock(&poll.lock)
+	lock(&pd.lock)
+	pd.fd = fd
+	pd.rready, pd.wready = false, false
+	pd.closing = false
+	pd.link = nil
+	unlock(&pd.lock)
+	if errno := netpolladd(fd, pd); errno != 0 {
+		netpollfree(pd)
+		return 0, errno
+	}
+	return uintptr(unsafe.Pointer(pd)), 0
+}
+
+// netpollclose unregisters a descriptor from the poller, before it is
+// closed. No goroutine may be waiting on it; see netpollunblock.
+func netpollclose(pd uintptr) {
+	p := (*pollDesc)(unsafe.Pointer(pd))
+	netpolldel(p.fd)
+	netpollfree(p)
+}
+
+// netpollfree returns a pollDesc to poll.free.
+func netpollfree(pd *pollDesc) {
+	lock(&poll.lock)
+	pd.link = poll.free
+	poll.free = pd
+	unlock(&poll.lock)
+}
+
+// netpollunblock readies the goroutines waiting on a descriptor, and
+// causes their netpollwait calls, and any later ones, to return
+// pollClosing.
+func netpollunblock(pd uintptr) {
+	p := (*pollDesc)(unsafe.Pointer(pd))
+	lock(&p.lock)
+	p.closing = true
+	rg, wg := p.rg, p.wg
+	p.rg, p.wg = nil, nil
+	unlock(&p.lock)
+	netpollwake(rg)
+	netpollwake(wg)
+}
+
+// netpollwait parks the calling goroutine until the descriptor is ready
+// for reading, if mode is 'r', or for writing, if mode is 'w'. It returns
+// pollReady, or pollClosing if netpollunblock has been called.
+func netpollwait(pd uintptr, mode int32) int32 {
+	p := (*pollDesc)(unsafe.Pointer(pd))
+	ready, waiter := &p.rready, &p.rg
+	if mode == pollWrite {
+		ready, waiter = &p.wready, &p.wg
+	}
+	lock(&p.lock)
+	for !*ready && !p.closing {
+		*waiter = getg()
+		atomic.AddInt32(&poll.nwait, 1)
+		pollstart()
+		gopark(&p.lock)
+		lock(&p.lock)
+	}
+	*ready = false
+	closing := p.closing
+	unlock(&p.lock)
+	if closing {
+		return pollClosing
+	}
+	return pollReady
+}
+
+// netpollready records that a descriptor is ready for reading or writing,
+// readying the goroutines waiting for it. It is called by netpoll.
+func netpollready(pd *pollDesc, read, write bool) {
+	var rg, wg *g
+	lock(&pd.lock)
+	if read {
+		pd.rready = true
+		rg, pd.rg = pd.rg, nil
+	}
+	if write {
+		pd.wready = true
+		wg, pd.wg = pd.wg, nil
+	}
+	unlock(&pd.lock)
+	netpollwake(rg)
+	netpollwake(wg)
+}
+
+// netpollwake readies a goroutine parked in netpollwait, if gp is not nil.
+func netpollwake(gp *g) {
+	if gp != nil {
+		atomic.AddInt32(&poll.nwait, -1)
+		goready(gp)
+	}
+}
+
+// pollstart starts a thread to run pollloop, if there is none.
+func pollstart() {
+	if atomic.LoadInt32(&poll.polling) != 0 || !atomic.CompareAndSwapInt32(&poll.polling, 0, 1) {
+		return
+	}
+	mp := (*m)(malloc(int(unsafe.Sizeof(m{}))))
+	thread := (*uintptr)(malloc(int(unsafe.Sizeof(uintptr(0)))))
+	pthread_create(thread, unsafe.Pointer(uintptr(0)), funcaddr(unsafe.Pointer(&pollloopfunc)), unsafe.Pointer(mp))
+	pthread_detach(*thread)
+	free(unsafe.Pointer(thread))
+}
+
+// pollloop is the start routine of the thread started by pollstart. The
+// thread has no processor, so the goroutines it readies are queued on the
+// global run queue.
+func pollloop(arg unsafe.Pointer) unsafe.Pointer {
+	pthread_setspecific(sched.mkey, arg)
+	for {
+		for atomic.LoadInt32(&poll.nwait) > 0 {
+			netpoll(true)
+		}
+		// A goroutine may start waiting after the check above, but
+		// before polling is cleared, and so not start a thread.
+		atomic.StoreInt32(&poll.polling, 0)
+		if atomic.LoadInt32(&poll.nwait) == 0 || !atomic.CompareAndSwapInt32(&poll.polling, 0, 1) {
+			break
+		}
+	}
+	free(arg)
+	return unsafe.Pointer(uintptr(0))
+}
+
+// vim: set ft=go :
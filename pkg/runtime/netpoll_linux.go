@@ -0,0 +1,116 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package runtime
+
+import "unsafe"
+
+// Constants from <sys/epoll.h>.
+const (
+	_EPOLLIN       = 0x1
+	_EPOLLOUT      = 0x4
+	_EPOLLERR      = 0x8
+	_EPOLLHUP      = 0x10
+	_EPOLLRDHUP    = 0x2000
+	_EPOLLET       = 0x80000000
+	_EPOLL_CLOEXEC = 0x80000
+	_EPOLL_CTL_ADD = 1
+	_EPOLL_CTL_DEL = 2
+)
+
+const (
+	// pollmaxevents is the number of events read by each call to
+	// epoll_wait.
+	pollmaxevents = 128
+
+	// polltimeout is the number of milliseconds that netpoll blocks for,
+	// after which pollloop checks whether goroutines are still waiting.
+	polltimeout = 10
+)
+
+var (
+	epfd int32 = -1
+
+	// epollevents is a buffer of pollmaxevents epoll_event structures,
+	// used only by the thread running pollloop.
+	epollevents unsafe.Pointer
+)
+
+// netpollcreate creates the epoll instance.
+func netpollcreate() {
+	epfd = epoll_create1(_EPOLL_CLOEXEC)
+	epollevents = malloc(pollmaxevents * epolleventSize)
+}
+
+// netpolladd registers fd with the epoll instance, edge-triggered, with
+// pd as its data. It returns an errno value on failure.
+func netpolladd(fd int32, pd *pollDesc) int32 {
+	ev := malloc(epolleventSize)
+	*(*uint32)(ev) = _EPOLLIN | _EPOLLOUT | _EPOLLRDHUP | _EPOLLET
+	*(**pollDesc)(unsafe.Pointer(uintptr(ev) + epolleventData)) = pd
+	var errno int32
+	if epoll_ctl(epfd, _EPOLL_CTL_ADD, fd, ev) != 0 {
+		errno = *__errno_location()
+	}
+	free(ev)
+	return errno
+}
+
+// netpolldel unregisters fd from the epoll instance.
+func netpolldel(fd int32) {
+	ev := malloc(epolleventSize)
+	epoll_ctl(epfd, _EPOLL_CTL_DEL, fd, ev)
+	free(ev)
+}
+
+// netpoll readies the goroutines waiting on descriptors that have become
+// ready. If block is true, it waits up to polltimeout milliseconds for one
+// to do so.
+func netpoll(block bool) {
+	var timeout int32
+	if block {
+		timeout = polltimeout
+	}
+	n := epoll_wait(epfd, epollevents, pollmaxevents, timeout)
+	for i := int32(0); i < n; i++ {
+		ev := uintptr(epollevents) + uintptr(i)*epolleventSize
+		events := *(*uint32)(unsafe.Pointer(ev))
+		pd := *(**pollDesc)(unsafe.Pointer(ev + epolleventData))
+		read := events&(_EPOLLIN|_EPOLLRDHUP|_EPOLLHUP|_EPOLLERR) != 0
+		write := events&(_EPOLLOUT|_EPOLLHUP|_EPOLLERR) != 0
+		netpollready(pd, read, write)
+	}
+}
+
+//extern epoll_create1
+func epoll_create1(flags int32) int32
+
+//extern epoll_ctl
+func epoll_ctl(epfd, op, fd int32, event unsafe.Pointer) int32
+
+//extern epoll_wait
+func epoll_wait(epfd int32, events unsafe.Pointer, maxevents, timeout int32) int32
+
+//extern __errno_location
+func __errno_location() *int32
+
+// vim: set ft=go :
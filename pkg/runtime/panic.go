@@ -0,0 +1,201 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package runtime
+
+import "unsafe"
+
+// deferframe records the deferred calls of an active function, most
+// recent first. The compiler allocates one on the stack of each function
+// containing defer statements, and generates a call to pushdeferframe on
+// entry to the function and to rundefers on each return from it.
+type deferframe struct {
+	prev   *deferframe
+	defers *_defer
+}
+
+// _defer is a deferred call, allocated on the heap by a defer statement.
+// The evaluated function and arguments of the call follow the header; fn
+// is an indirect function that makes the call, given the record.
+type _defer struct {
+	next *_defer
+	fn   unsafe.Pointer
+}
+
+// deferfunc is the type of the indirect functions that make deferred
+// calls.
+type deferfunc func(d *_defer)
+
+// _panic is a panic in progress, and the panic, if any, in progress when
+// it began, whose deferred calls it interrupted.
+type _panic struct {
+	arg  interface{}
+	link *_panic
+}
+
+// pushdeferframe pushes the calling function's frame onto the goroutine's
+// list of functions with deferred calls.
+func pushdeferframe(f unsafe.Pointer) {
+	gp := getg()
+	frame := (*deferframe)(f)
+	frame.prev = gp.deferframes
+	gp.deferframes = frame
+}
+
+// rundefers makes the deferred calls of the function whose frame is f,
+// most recent first, removing each from the frame before it is made, and
+// then pops the frame from the goroutine's list.
+func rundefers(f unsafe.Pointer) {
+	frame := (*deferframe)(f)
+	for frame.defers != nil {
+		d := frame.defers
+		frame.defers = d.next
+		fv := funcval{fn: d.fn}
+		fn := *(*deferfunc)(unsafe.Pointer(&fv))
+		fn(d)
+	}
+	getg().deferframes = frame.prev
+}
+
+// gopanic is called for each call to the panic builtin. There is no
+// support for recover, so every panic is fatal: the pending deferred calls
+// of the goroutine are made, innermost function first, and then the value
+// and the stack trace of the panicking goroutine are printed, as by gc,
+// and the program exits with status 2. If a deferred call panics, its
+// value is printed after those of the panics it interrupted.
+//
+// Like print and println, the output is written to standard output,
+// whereas gc writes it to standard error.
+func gopanic(e interface{}) {
+	p := &_panic{arg: e}
+	if gp := getg(); gp != nil {
+		p.link = gp.panics
+		gp.panics = p
+		for gp.deferframes != nil {
+			rundefers(unsafe.Pointer(gp.deferframes))
+		}
+	}
+	printpanics(p)
+	fatalpanic()
+}
+
+// printpanics prints the values of a panic and of the panics it
+// interrupted, earliest first.
+func printpanics(p *_panic) {
+	if p.link != nil {
+		printpanics(p.link)
+		print("\n\t")
+	}
+	print("panic: ")
+	printany(p.arg)
+}
+
+// fatalpanic completes the message printed for a panic with a stack trace,
+// and exits.
+func fatalpanic() {
+	print("\n\n")
+	gp := getg()
+	if gp != nil {
+		print("goroutine ", gp.goid, " [running]:\n")
+	}
+	traceback()
+	exit(2)
+}
+
+// typestring returns the string form of an interface value's dynamic type.
+func typestring(i interface{}) string {
+	e := (*eface)(unsafe.Pointer(&i))
+	return *e.typ.string
+}
+
+// panicnil is called when a nil pointer is dereferenced.
+func panicnil() {
+	panic(errorString("invalid memory address or nil pointer dereference"))
+}
+
+// panicdivide is called when an integer is divided by zero.
+func panicdivide() {
+	panic(errorString("integer divide by zero"))
+}
+
+// panicindex is called when an index expression is out of range.
+func panicindex(index, length int) {
+	panic(boundsError{x: index, y: length, code: boundsIndex})
+}
+
+// panicslice is called when a two-index slice expression's bounds are out
+// of range. bound is the operand's length if islen is true, and otherwise
+// its capacity. The bounds are checked in the same order as by gc, so that
+// the same error is reported.
+func panicslice(low, high, bound int, islen bool) {
+	if uint(high) > uint(bound) {
+		if islen {
+			panic(boundsError{x: high, y: bound, code: boundsSliceAlen})
+		}
+		panic(boundsError{x: high, y: bound, code: boundsSliceAcap})
+	}
+	panic(boundsError{x: low, y: high, code: boundsSliceB})
+}
+
+// panicslice3 is called when a three-index slice expression's bounds are
+// out of range, as for panicslice.
+func panicslice3(low, high, max, bound int, islen bool) {
+	if uint(max) > uint(bound) {
+		if islen {
+			panic(boundsError{x: max, y: bound, code: boundsSlice3Alen})
+		}
+		panic(boundsError{x: max, y: bound, code: boundsSlice3Acap})
+	}
+	if uint(high) > uint(max) {
+		panic(boundsError{x: high, y: max, code: boundsSlice3B})
+	}
+	panic(boundsError{x: low, y: high, code: boundsSlice3C})
+}
+
+// panictypeassert is called when a single-valued type assertion fails.
+// iface, have and want are the runtime type descriptors of the interface,
+// its dynamic type and the asserted type, respectively.
+func panictypeassert(iface, have, want uintptr) {
+	wanttyp := (*type_)(unsafe.Pointer(want))
+	if have == 0 {
+		panic(&TypeAssertionError{assertedString: *wanttyp.string})
+	}
+	havetyp := (*type_)(unsafe.Pointer(have))
+	if wanttyp.kind == kindInterface {
+		ityp := (*interfaceType)(unsafe.Pointer(&wanttyp.commonType))
+		if missing := missingmethod(havetyp, ityp, nil); missing != nil {
+			panic(&TypeAssertionError{
+				concreteString: *havetyp.string,
+				assertedString: *wanttyp.string,
+				missingMethod:  *missing,
+			})
+		}
+	}
+	ifacetyp := (*type_)(unsafe.Pointer(iface))
+	panic(&TypeAssertionError{
+		interfaceString: *ifacetyp.string,
+		concreteString:  *havetyp.string,
+		assertedString:  *wanttyp.string,
+	})
+}
+
+// vim: set ft=go :
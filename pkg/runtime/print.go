@@ -0,0 +1,529 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package runtime
+
+import "unsafe"
+
+// printfloat prints a float64 value in the format used by gc's print and
+// println: the shortest decimal representation that reads back as the
+// same value, in %e form for large and small exponents.
+func printfloat(v float64) {
+	printfloatbits(*(*uint64)(unsafe.Pointer(&v)), &float64info)
+}
+
+// printfloat32 prints a float32 value, with the shortest representation
+// that reads back as the same float32.
+func printfloat32(v float32) {
+	printfloatbits(uint64(*(*uint32)(unsafe.Pointer(&v))), &float32info)
+}
+
+// printcomplex prints a complex128 value, given its real and imaginary
+// parts, in the form (r+ii).
+func printcomplex(re, im float64) {
+	printcomplexbits(*(*uint64)(unsafe.Pointer(&re)),
+		*(*uint64)(unsafe.Pointer(&im)), &float64info)
+}
+
+// printcomplex64 prints a complex64 value, given its real and imaginary
+// parts.
+func printcomplex64(re, im float32) {
+	printcomplexbits(uint64(*(*uint32)(unsafe.Pointer(&re))),
+		uint64(*(*uint32)(unsafe.Pointer(&im))), &float32info)
+}
+
+func printcomplexbits(re, im uint64, flt *floatInfo) {
+	print("(")
+	printfloatbits(re, flt)
+
+	// The imaginary part is always signed. Infinities print their own
+	// sign, and NaN never has one.
+	exp := im >> flt.mantbits & (1<<flt.expbits - 1)
+	mant := im & (uint64(1)<<flt.mantbits - 1)
+	switch {
+	case exp == 1<<flt.expbits-1 && mant == 0:
+	case exp == 1<<flt.expbits-1 || im>>(flt.expbits+flt.mantbits) == 0:
+		print("+")
+	}
+	printfloatbits(im, flt)
+	print("i)")
+}
+
+// printstring prints a string byte by byte, so that embedded NULs are
+// written out rather than terminating the output as they would in printf.
+func printstring(s string) {
+	for i := 0; i < len(s); i++ {
+		putchar(s[i])
+	}
+}
+
+// putchar writes a byte to standard output, through the same buffer as
+// printf. Its body is defined by the compiler.
+func putchar(c byte)
+
+type floatInfo struct {
+	mantbits uint
+	expbits  uint
+	bias     int
+}
+
+var float32info = floatInfo{23, 8, -127}
+var float64info = floatInfo{52, 11, -1023}
+
+func printfloatbits(bits uint64, flt *floatInfo) {
+	neg := bits>>(flt.expbits+flt.mantbits) != 0
+	exp := int(bits>>flt.mantbits) & (1<<flt.expbits - 1)
+	mant := bits & (uint64(1)<<flt.mantbits - 1)
+
+	switch exp {
+	case 1<<flt.expbits - 1:
+		switch {
+		case mant != 0:
+			print("NaN")
+		case neg:
+			print("-Inf")
+		default:
+			print("+Inf")
+		}
+		return
+	case 0:
+		// denormalised
+		exp++
+	default:
+		// add the implicit top bit
+		mant |= uint64(1) << flt.mantbits
+	}
+	exp += flt.bias
+
+	var d decimal
+	decimalAssign(&d, mant)
+	decimalShift(&d, exp-int(flt.mantbits))
+	roundShortest(&d, mant, exp, flt)
+
+	// %e is used if the exponent from the conversion is less than -4 or
+	// greater than or equal to the precision, which is 6 for the
+	// shortest representation.
+	var buf [32]byte
+	n := 0
+	if neg {
+		buf[n] = '-'
+		n++
+	}
+	if d.nd == 0 {
+		// zero
+		d.d[0] = '0'
+		d.nd = 1
+		d.dp = 1
+	}
+	if e := d.dp - 1; e < -4 || e >= 6 {
+		buf[n] = d.d[0]
+		n++
+		if d.nd > 1 {
+			buf[n] = '.'
+			n++
+			n += copy(buf[n:], d.d[1:d.nd])
+		}
+		buf[n] = 'e'
+		n++
+		if e < 0 {
+			buf[n] = '-'
+			e = -e
+		} else {
+			buf[n] = '+'
+		}
+		n++
+		if e >= 100 {
+			buf[n] = byte(e/100 + '0')
+			n++
+		}
+		buf[n] = byte(e/10%10 + '0')
+		buf[n+1] = byte(e%10 + '0')
+		n += 2
+		print(string(buf[:n]))
+		return
+	}
+
+	// %f: integer part, padded with zeros, then any fraction.
+	var fbuf [64]byte
+	copy(fbuf[:], buf[:n])
+	if d.dp > 0 {
+		m := d.dp
+		if m > d.nd {
+			m = d.nd
+		}
+		n += copy(fbuf[n:], d.d[:m])
+		for ; m < d.dp; m++ {
+			fbuf[n] = '0'
+			n++
+		}
+	} else {
+		fbuf[n] = '0'
+		n++
+	}
+	if d.nd > d.dp {
+		fbuf[n] = '.'
+		n++
+		for i := d.dp; i < d.nd; i++ {
+			if i < 0 {
+				fbuf[n] = '0'
+			} else {
+				fbuf[n] = d.d[i]
+			}
+			n++
+		}
+	}
+	print(string(fbuf[:n]))
+}
+
+// decimal is an arbitrary precision decimal number, used for converting
+// floating point values to their shortest decimal representation.
+type decimal struct {
+	d     [decimalDigits]byte // digits, big-endian representation
+	nd    int                 // number of digits used
+	dp    int                 // decimal point
+	trunc bool                // discarded nonzero digits beyond d[:nd]
+}
+
+// decimalDigits is the number of digits held by a decimal.
+const decimalDigits = 800
+
+// maxShift is the largest shift that can be applied to a decimal in one
+// step without overflowing the uint64 accumulator.
+const maxShift = 56
+
+func decimalAssign(a *decimal, v uint64) {
+	var buf [24]byte
+	n := 0
+	for v > 0 {
+		v1 := v / 10
+		buf[n] = byte(v - 10*v1 + '0')
+		n++
+		v = v1
+	}
+	a.nd = 0
+	for n > 0 {
+		n--
+		a.d[a.nd] = buf[n]
+		a.nd++
+	}
+	a.dp = a.nd
+	decimalTrim(a)
+}
+
+// decimalTrim removes trailing zeros.
+func decimalTrim(a *decimal) {
+	for a.nd > 0 && a.d[a.nd-1] == '0' {
+		a.nd--
+	}
+	if a.nd == 0 {
+		a.dp = 0
+	}
+}
+
+// decimalShift multiplies a by 2**k.
+func decimalShift(a *decimal, k int) {
+	switch {
+	case a.nd == 0:
+	case k > 0:
+		for k > maxShift {
+			leftShift(a, maxShift)
+			k -= maxShift
+		}
+		leftShift(a, uint(k))
+	case k < 0:
+		for k < -maxShift {
+			rightShift(a, maxShift)
+			k += maxShift
+		}
+		rightShift(a, uint(-k))
+	}
+}
+
+// leftShift multiplies a by 2**k, k <= maxShift.
+func leftShift(a *decimal, k uint) {
+	// Compute the digits from the least significant end, leaving room
+	// for the new leading digits.
+	var buf [decimalDigits + 20]byte
+	w := len(buf)
+	var n uint64
+	for r := a.nd; r > 0; r-- {
+		n += uint64(a.d[r-1]-'0') << k
+		quo := n / 10
+		w--
+		buf[w] = byte(n - 10*quo + '0')
+		n = quo
+	}
+	for n > 0 {
+		quo := n / 10
+		w--
+		buf[w] = byte(n - 10*quo + '0')
+		n = quo
+	}
+	a.dp += len(buf) - w - a.nd
+	a.nd = copy(a.d[:], buf[w:])
+	if len(buf)-w > a.nd {
+		for _, c := range buf[w+a.nd:] {
+			if c != '0' {
+				a.trunc = true
+			}
+		}
+	}
+	decimalTrim(a)
+}
+
+// rightShift divides a by 2**k, k <= maxShift.
+func rightShift(a *decimal, k uint) {
+	r := 0 // read index
+	w := 0 // write index
+
+	// Pick up enough leading digits to cover the first shift.
+	var n uint64
+	for ; n>>k == 0; r++ {
+		if r >= a.nd {
+			if n == 0 {
+				// a == 0; shouldn't happen, but handle anyway.
+				a.nd = 0
+				return
+			}
+			for n>>k == 0 {
+				n = n * 10
+				r++
+			}
+			break
+		}
+		n = n*10 + uint64(a.d[r]-'0')
+	}
+	a.dp -= r - 1
+
+	var mask uint64 = (1 << k) - 1
+
+	// Pick up a digit, put down a digit.
+	for ; r < a.nd; r++ {
+		dig := n >> k
+		n &= mask
+		a.d[w] = byte(dig + '0')
+		w++
+		n = n*10 + uint64(a.d[r]-'0')
+	}
+
+	// Put down extra digits.
+	for n > 0 {
+		dig := n >> k
+		n &= mask
+		if w < len(a.d) {
+			a.d[w] = byte(dig + '0')
+			w++
+		} else if dig > 0 {
+			a.trunc = true
+		}
+		n = n * 10
+	}
+
+	a.nd = w
+	decimalTrim(a)
+}
+
+// shouldRoundUp reports whether a should be rounded up when chopped to
+// nd digits.
+func shouldRoundUp(a *decimal, nd int) bool {
+	if a.d[nd] == '5' && nd+1 == a.nd {
+		// exactly halfway - round to even
+		if a.trunc {
+			return true
+		}
+		return nd > 0 && (a.d[nd-1]-'0')%2 == 1
+	}
+	return a.d[nd] >= '5'
+}
+
+func decimalRound(a *decimal, nd int) {
+	if nd < 0 || nd >= a.nd {
+		return
+	}
+	if shouldRoundUp(a, nd) {
+		decimalRoundUp(a, nd)
+	} else {
+		decimalRoundDown(a, nd)
+	}
+}
+
+func decimalRoundDown(a *decimal, nd int) {
+	if nd < 0 || nd >= a.nd {
+		return
+	}
+	a.nd = nd
+	decimalTrim(a)
+}
+
+func decimalRoundUp(a *decimal, nd int) {
+	if nd < 0 || nd >= a.nd {
+		return
+	}
+	for i := nd - 1; i >= 0; i-- {
+		if a.d[i] < '9' {
+			a.d[i]++
+			a.nd = i + 1
+			return
+		}
+	}
+	// Number is all 9s; change to a single 1 with an adjusted decimal point.
+	a.d[0] = '1'
+	a.nd = 1
+	a.dp++
+}
+
+// roundShortest rounds d (= mant * 2**(exp-mantbits)) to the shortest
+// number of digits that will let the original floating point value be
+// precisely reconstructed.
+func roundShortest(d *decimal, mant uint64, exp int, flt *floatInfo) {
+	if mant == 0 {
+		d.nd = 0
+		return
+	}
+
+	// If mantissa scale is larger than decimal scale, every digit is
+	// needed (2**(exp-mantbits) >= 10**(dp-nd) is approximated with
+	// 332/100 for log2(10)).
+	minexp := flt.bias + 1
+	if exp > minexp && 332*(d.dp-d.nd) >= 100*(exp-int(flt.mantbits)) {
+		return
+	}
+
+	// Compute the upper and lower bounds: halfway between the value and
+	// its floating point neighbours.
+	var upper decimal
+	decimalAssign(&upper, mant*2+1)
+	decimalShift(&upper, exp-int(flt.mantbits)-1)
+
+	var mantlo uint64
+	var explo int
+	if mant > 1<<flt.mantbits || exp == minexp {
+		mantlo = mant - 1
+		explo = exp
+	} else {
+		mantlo = mant*2 - 1
+		explo = exp - 1
+	}
+	var lower decimal
+	decimalAssign(&lower, mantlo*2+1)
+	decimalShift(&lower, explo-int(flt.mantbits)-1)
+
+	// The bounds are possible outputs only if the mantissa is even, so
+	// that round-to-even reading would give back the original value.
+	inclusive := mant%2 == 0
+
+	// Walk along until d has distinguished itself from upper and lower.
+	// upperdelta tracks whether rounding up stays within the upper bound.
+	var upperdelta uint8
+	for ui := 0; ; ui++ {
+		// upper has the most digits before the decimal point, so the
+		// indices into lower and d may start negative.
+		mi := ui - upper.dp + d.dp
+		if mi >= d.nd {
+			break
+		}
+		li := ui - upper.dp + lower.dp
+		l := byte('0')
+		if li >= 0 && li < lower.nd {
+			l = lower.d[li]
+		}
+		m := byte('0')
+		if mi >= 0 {
+			m = d.d[mi]
+		}
+		u := byte('0')
+		if ui < upper.nd {
+			u = upper.d[ui]
+		}
+
+		// Rounding down is fine if lower has a different digit, or if
+		// lower is inclusive and this is its final digit.
+		okdown := l != m || inclusive && li+1 == lower.nd
+
+		switch {
+		case upperdelta == 0 && m+1 < u:
+			upperdelta = 2
+		case upperdelta == 0 && m != u:
+			upperdelta = 1
+		case upperdelta == 1 && (m != '9' || u != '0'):
+			upperdelta = 2
+		}
+		// Rounding up is fine if upper has a different digit and either
+		// upper is inclusive or it is bigger than the rounded result.
+		okup := upperdelta > 0 && (inclusive || upperdelta > 1 || ui+1 < upper.nd)
+
+		switch {
+		case okdown && okup:
+			decimalRound(d, mi+1)
+			return
+		case okdown:
+			decimalRoundDown(d, mi+1)
+			return
+		case okup:
+			decimalRoundUp(d, mi+1)
+			return
+		}
+	}
+}
+
+// printhex prints an unsigned integer in hexadecimal, with a leading
+// "0x", as gc's print and println do for pointers.
+func printhex(v uint64) {
+	const digits = "0123456789abcdef"
+	var buf [18]byte
+	i := len(buf)
+	for {
+		i--
+		buf[i] = digits[v%16]
+		if v < 16 {
+			break
+		}
+		v /= 16
+	}
+	i--
+	buf[i] = 'x'
+	i--
+	buf[i] = '0'
+	print(string(buf[i:]))
+}
+
+// printpointer prints a pointer value.
+func printpointer(p unsafe.Pointer) {
+	printhex(uint64(uintptr(p)))
+}
+
+// printeface prints an interface value as its type and data words.
+func printeface(typ, data unsafe.Pointer) {
+	print("(")
+	printpointer(typ)
+	print(",")
+	printpointer(data)
+	print(")")
+}
+
+// printslice prints a slice as its length, capacity and data pointer.
+func printslice(s slice) {
+	print("[", s.len, "/", s.cap, "]")
+	printpointer(unsafe.Pointer(s.array))
+}
+
+// vim: set ft=go :
@@ -0,0 +1,968 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package runtime
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// The scheduler multiplexes goroutines (g) onto operating system threads
+// (m). A thread must hold a processor (p) to run goroutines, and there are
+// GOMAXPROCS processors, each with a queue of runnable goroutines. New and
+// readied goroutines are queued on the current thread's processor. A
+// thread whose processor has no work takes a goroutine from the global
+// queue, or steals half of another processor's queue. Goroutines are not
+// preempted: each runs until it blocks, calls Gosched or exits.
+//
+// Each goroutine has its own stack, and switches to and from the thread's
+// scheduler with the C library's ucontext functions. Threads are started
+// when a goroutine is queued while a processor is idle, and exit when they
+// find no work.
+//
+// The main thread, and any other thread that calls into Go without having
+// been started by the scheduler, has a goroutine of its own for the code
+// already running on it. That goroutine is locked to the thread: it is
+// never queued, and no other thread runs it. The thread's scheduler runs
+// on a separate stack, and runs other goroutines while the thread's own
+// goroutine is blocked.
+//
+// A goroutine making a system call that may block calls entersyscall
+// first, releasing its thread's processor so that other goroutines may
+// run meanwhile, and exitsyscall afterwards, to take a processor again.
+//
+// The garbage collector stops the world while it marks: stoptheworld takes
+// every idle processor, and waits for each running one to stop at a safe
+// point, where its thread calls gcstopm. The safe points are the
+// scheduler, entering a system call, and waiting for the heap lock, which
+// the collector holds, to allocate.
+
+const (
+	// goroutineStackSize is the size of each goroutine's stack. Stacks do
+	// not grow.
+	goroutineStackSize = 256 << 10
+
+	// maxprocs is the largest value that GOMAXPROCS may be set to.
+	maxprocs = 64
+
+	// runqsize is the capacity of each processor's run queue. Goroutines
+	// that do not fit are queued on the global run queue.
+	runqsize = 256
+)
+
+// Goroutine states.
+const (
+	gRunnable = iota
+	gRunning
+	gWaiting
+	gDead
+)
+
+// Processor states.
+const (
+	pIdle = iota
+	pRunning
+	pDead   // unused, as its id is not less than GOMAXPROCS
+	pGCStop // idle, and held by a collection that has stopped the world
+)
+
+// g is a goroutine.
+type g struct {
+	goid    int64
+	status  int32
+	context unsafe.Pointer // saved registers and stack pointer, a ucontext_t
+
+	// stacklo and stackhi are the bounds of the goroutine's stack. stack
+	// is the stack's memory, if it was allocated by newgoroutine.
+	stacklo, stackhi uintptr
+	stack            unsafe.Pointer
+
+	// fn is the function called by goentry, with argsize bytes of
+	// arguments copied to arg.
+	fn      unsafe.Pointer
+	arg     unsafe.Pointer
+	argsize uintptr
+
+	// gcroots is the head of the goroutine's shadow stack, saved when it
+	// last switched to the scheduler, stopped at a safe point or entered a
+	// system call; see gcscanshadowstack.
+	gcroots uintptr
+
+	// deferframes is the innermost of the goroutine's active functions
+	// with deferred calls, and panics the innermost of its panics.
+	deferframes *deferframe
+	panics      *_panic
+
+	lockedm   *m // the thread that alone may run the goroutine
+	schedlink *g // next goroutine in the global run queue
+	alllink   *g // next goroutine in allgs
+}
+
+// m is an operating system thread.
+type m struct {
+	g0      unsafe.Pointer // the scheduler's context, a ucontext_t
+	curg    *g
+	p       *p
+	lockedg *g // the thread's own goroutine, if it was not started by the scheduler
+
+	// waitlock is a lock released by the scheduler once curg has
+	// switched to it. yielded records that lockedg called Gosched, so
+	// that the scheduler runs another goroutine first.
+	waitlock *int32
+	yielded  bool
+
+	// nextp is a processor handed to the thread while it waits for one
+	// to run lockedg. waitlink is the next thread waiting in sched.waitm.
+	nextp    unsafe.Pointer
+	waiting  bool
+	waitlink *m
+
+	// insyscall records that curg released the thread's processor in
+	// entersyscall.
+	insyscall bool
+}
+
+// p is a processor, with a queue of up to runqsize runnable goroutines
+// held in a circular buffer, starting at index head.
+type p struct {
+	id     int
+	status int32
+	link   *p // next processor in sched.pidle
+	lock   int32
+	runq   [runqsize]*g
+	head   int
+	size   int
+
+	// gcwait records that the processor was running when the world was
+	// stopped, and has yet to stop.
+	gcwait bool
+}
+
+var allp [maxprocs]p
+
+var sched struct {
+	// lock protects the fields below, other than ngoroutines, npidle,
+	// gcwaiting and stopwait, which are updated atomically.
+	lock int32
+
+	initdone   bool
+	mkey       uint32 // key of the thread-specific m
+	gomaxprocs int
+	allgs      *g
+	goidgen    int64
+
+	runqhead, runqtail *g // global run queue
+	runqsize           int32
+
+	pidle  *p
+	npidle int32
+
+	// waitm is a list of threads with a runnable goroutine of their own,
+	// waiting for a processor.
+	waitm  *m
+	nwaitm int32
+
+	ngoroutines int32
+
+	// gcwaiting is set while a collection stops the world, and stopwait
+	// is the number of running processors yet to stop.
+	gcwaiting int32
+	stopwait  int32
+}
+
+// Top-level functions whose code addresses are passed to C. They are set
+// by schedinit, as the functions refer to them indirectly.
+var (
+	goentryfunc  func()
+	schedulefunc func()
+	mstartfunc   func(unsafe.Pointer) unsafe.Pointer
+)
+
+func init() {
+	schedinit()
+}
+
+// schedinit initialises the scheduler, with GOMAXPROCS processors as set
+// by the environment variable of that name, or one processor by default.
+// The calling thread takes the first processor.
+func schedinit() {
+	goentryfunc = goentry
+	schedulefunc = schedule
+	mstartfunc = mstart
+	pthread_key_create(&sched.mkey, unsafe.Pointer(uintptr(0)))
+	n := gomaxprocsenv()
+	if n < 1 {
+		n = 1
+	}
+	if n > maxprocs {
+		n = maxprocs
+	}
+	sched.gomaxprocs = n
+	for i := range allp {
+		allp[i].id = i
+		allp[i].status = pDead
+	}
+	for i := n - 1; i > 0; i-- {
+		pidleput(&allp[i])
+	}
+	sched.initdone = true
+	mp := getm()
+	mp.p = &allp[0]
+	allp[0].status = pRunning
+}
+
+// gomaxprocsenv returns the value of the GOMAXPROCS environment variable,
+// or zero if it is not set to a decimal number.
+func gomaxprocsenv() int {
+	const prefix = "GOMAXPROCS="
+	for _, kv := range environ() {
+		s := *(*string)(unsafe.Pointer(&kv))
+		if len(s) <= len(prefix) || s[:len(prefix)] != prefix {
+			continue
+		}
+		n := 0
+		for i := len(prefix); i < len(s); i++ {
+			if s[i] < '0' || s[i] > '9' {
+				return 0
+			}
+			n = n*10 + int(s[i]-'0')
+		}
+		return n
+	}
+	return 0
+}
+
+// yield causes the calling thread to relinquish the CPU. Its body is
+// defined by the compiler.
+func yield()
+
+// lock acquires the spin lock l. Spin locks are only held briefly, and
+// never across a goroutine switch, so waiters yield the thread rather
+// than the goroutine.
+func lock(l *int32) {
+	for !atomic.CompareAndSwapInt32(l, 0, 1) {
+		yield()
+	}
+}
+
+// unlock releases the spin lock l.
+func unlock(l *int32) {
+	atomic.StoreInt32(l, 0)
+}
+
+// getm returns the calling thread's m, creating one if the thread was not
+// started by the scheduler and has not called into Go before.
+func getm() *m {
+	mp := (*m)(pthread_getspecific(sched.mkey))
+	if mp == nil {
+		mp = newexternalm()
+	}
+	return mp
+}
+
+// getg returns the calling goroutine, or nil if called by the scheduler.
+func getg() *g {
+	return getm().curg
+}
+
+// newg allocates a goroutine, numbering it, and adds it to allgs.
+func newg() *g {
+	gp := (*g)(malloc(int(unsafe.Sizeof(g{}))))
+	gp.context = malloc(ucontextSize)
+	lock(&sched.lock)
+	sched.goidgen++
+	gp.goid = sched.goidgen
+	gp.alllink = sched.allgs
+	sched.allgs = gp
+	unlock(&sched.lock)
+	atomic.AddInt32(&sched.ngoroutines, 1)
+	return gp
+}
+
+// freeg removes a dead goroutine from allgs and frees it.
+func freeg(gp *g) {
+	lock(&sched.lock)
+	if sched.allgs == gp {
+		sched.allgs = gp.alllink
+	} else {
+		prev := sched.allgs
+		for prev.alllink != gp {
+			prev = prev.alllink
+		}
+		prev.alllink = gp.alllink
+	}
+	unlock(&sched.lock)
+	if gp.argsize != 0 {
+		free(gp.arg)
+	}
+	free(gp.stack)
+	free(gp.context)
+	free(unsafe.Pointer(gp))
+}
+
+// makegcontext initialises ctx to call fn, which must not return, on the
+// specified stack.
+func makegcontext(ctx, stack unsafe.Pointer, size uintptr, fn unsafe.Pointer) {
+	getcontext(ctx)
+	*(*uintptr)(unsafe.Pointer(uintptr(ctx) + ucontextLink)) = 0
+	*(*unsafe.Pointer)(unsafe.Pointer(uintptr(ctx) + ucontextStackSp)) = stack
+	*(*uintptr)(unsafe.Pointer(uintptr(ctx) + ucontextStackSize)) = size
+	makecontext(ctx, fn, 0)
+}
+
+// newexternalm creates an m for the calling thread, which was not started
+// by the scheduler, with a goroutine for the code running on the thread.
+// The thread's scheduler runs on a stack of its own.
+func newexternalm() *m {
+	mp := (*m)(malloc(int(unsafe.Sizeof(m{}))))
+	gp := newg()
+	gp.status = gRunning
+	gp.lockedm = mp
+	attr := malloc(pthreadAttrSize)
+	pthread_getattr_np(pthread_self(), attr)
+	pthread_attr_getstack(attr, &gp.stacklo, &gp.stackhi)
+	pthread_attr_destroy(attr)
+	free(attr)
+	gp.stackhi += gp.stacklo
+	mp.lockedg = gp
+	mp.curg = gp
+	mp.g0 = malloc(ucontextSize)
+	stack := malloc(goroutineStackSize)
+	makegcontext(mp.g0, stack, goroutineStackSize, funcaddr(unsafe.Pointer(&schedulefunc)))
+	pthread_setspecific(sched.mkey, unsafe.Pointer(mp))
+	return mp
+}
+
+// funcaddr returns the code address of the top-level function stored in
+// the variable at f.
+func funcaddr(f unsafe.Pointer) unsafe.Pointer {
+	return (*funcval)(f).fn
+}
+
+// newgoroutine starts a goroutine that calls fn with a copy of the
+// argsize bytes of arguments at arg. The compiler generates a call to it,
+// with an indirect function taking a pointer to the arguments, for each
+// go statement.
+func newgoroutine(fn, arg unsafe.Pointer, argsize uintptr) {
+	gp := newg()
+	gp.fn = fn
+	if argsize != 0 {
+		gp.arg = malloc(int(argsize))
+		gp.argsize = argsize
+		memcpy(gp.arg, arg, int(argsize))
+	}
+	gp.stack = malloc(goroutineStackSize)
+	gp.stacklo = uintptr(gp.stack)
+	gp.stackhi = gp.stacklo + goroutineStackSize
+	makegcontext(gp.context, gp.stack, goroutineStackSize, funcaddr(unsafe.Pointer(&goentryfunc)))
+	gp.status = gRunnable
+	runqput(getm().p, gp)
+	wakep()
+}
+
+// goroutinefunc is the type of the indirect functions that goroutines
+// call.
+type goroutinefunc func(arg unsafe.Pointer)
+
+// goentry is the entry point of each goroutine started by newgoroutine.
+// It calls the goroutine's function, and then exits the goroutine.
+func goentry() {
+	gp := getg()
+	f := funcval{fn: gp.fn}
+	fn := *(*goroutinefunc)(unsafe.Pointer(&f))
+	fn(gp.arg)
+	atomic.AddInt32(&sched.ngoroutines, -1)
+	gswitch(gDead, nil)
+}
+
+// gswitch sets the status of the calling goroutine, and switches to the
+// scheduler. If l is not nil, the scheduler releases it once the
+// goroutine's context has been saved, so that the goroutine cannot be
+// readied and resumed by another thread before then.
+func gswitch(status int32, l *int32) {
+	mp := getm()
+	gp := mp.curg
+	mp.waitlock = l
+	gp.gcroots = gcshadowstack()
+	atomic.StoreInt32(&gp.status, status)
+	swapcontext(gp.context, mp.g0)
+}
+
+// gopark blocks the calling goroutine until it is readied by goready,
+// releasing the lock l once it has switched to the scheduler.
+func gopark(l *int32) {
+	gswitch(gWaiting, l)
+}
+
+// goready makes a goroutine blocked by gopark runnable.
+func goready(gp *g) {
+	atomic.StoreInt32(&gp.status, gRunnable)
+	if gp.lockedm == nil {
+		runqput(getm().p, gp)
+		wakep()
+	}
+}
+
+// Gosched yields the processor, allowing other goroutines to run. It does
+// not suspend the current goroutine, so execution resumes automatically.
+func Gosched() {
+	gswitch(gRunnable, nil)
+}
+
+// entersyscall is called by a goroutine before a system call that may
+// block. The thread's processor is released: if goroutines are queued, a
+// new thread is started to run them on it, and otherwise it is handed off
+// as by releasep.
+func entersyscall() {
+	mp := getm()
+	pp := mp.p
+	if pp == nil {
+		return
+	}
+	// The goroutine's registers and shadow stack are saved for the
+	// collector, which may scan its stack while the system call blocks.
+	getcontext(mp.curg.context)
+	mp.curg.gcroots = gcshadowstack()
+	if atomic.LoadInt32(&sched.gcwaiting) != 0 {
+		gcstopm(mp)
+	}
+	mp.insyscall = true
+	if pp.id < sched.gomaxprocs && (pp.size > 0 || atomic.LoadInt32(&sched.runqsize) > 0) {
+		mp.p = nil
+		newm(pp)
+		return
+	}
+	releasep(mp)
+}
+
+// exitsyscall is called by a goroutine after a system call for which it
+// called entersyscall. The thread takes an idle processor if there is one;
+// otherwise the goroutine switches to the scheduler as runnable, to be run
+// once a processor is free.
+func exitsyscall() {
+	mp := getm()
+	if !mp.insyscall {
+		return
+	}
+	mp.insyscall = false
+	lock(&sched.lock)
+	pp := pidleget()
+	unlock(&sched.lock)
+	if pp != nil {
+		pp.status = pRunning
+		mp.p = pp
+		return
+	}
+	gswitch(gRunnable, nil)
+}
+
+// schedule runs goroutines on the calling thread until it finds no work.
+// It runs on the thread's own stack for threads started by the scheduler,
+// which exit when it returns, and on a separate stack for other threads,
+// for which it never returns.
+func schedule() {
+	mp := getm()
+	for {
+		gp := findrunnable(mp)
+		if gp == nil {
+			return
+		}
+		mp.curg = gp
+		atomic.StoreInt32(&gp.status, gRunning)
+		// The thread's shadow stack is switched to the goroutine's while
+		// it runs.
+		roots := gcshadowstack()
+		gcsetshadowstack(gp.gcroots)
+		swapcontext(mp.g0, gp.context)
+		gcsetshadowstack(roots)
+		mp.curg = nil
+		if mp.waitlock != nil {
+			unlock(mp.waitlock)
+			mp.waitlock = nil
+		}
+		switch atomic.LoadInt32(&gp.status) {
+		case gRunnable:
+			if gp == mp.lockedg {
+				mp.yielded = true
+			} else {
+				runqput(mp.p, gp)
+				if mp.p == nil {
+					// The goroutine left a system call and found no
+					// idle processor, but one may have been freed since.
+					wakep()
+				}
+			}
+		case gDead:
+			freeg(gp)
+		}
+	}
+}
+
+// findrunnable returns the next goroutine for the thread to run. The
+// thread's own goroutine is preferred, then goroutines from the thread's
+// processor, the global run queue and other processors. A thread with
+// nothing to run releases its processor; threads started by the scheduler
+// then return nil, while other threads wait for their own goroutine to be
+// readied, running other goroutines if there is work and an idle
+// processor in the meantime.
+func findrunnable(mp *m) *g {
+	for {
+		if mp.p != nil && atomic.LoadInt32(&sched.gcwaiting) != 0 {
+			gcstopm(mp)
+		}
+		own := mp.lockedg != nil && atomic.LoadInt32(&mp.lockedg.status) == gRunnable
+		if mp.p != nil && (mp.p.id >= sched.gomaxprocs || (mp.lockedg == nil && atomic.LoadInt32(&sched.nwaitm) > 0)) {
+			// The processor has been retired by GOMAXPROCS, or is
+			// wanted by a thread waiting to run its own goroutine.
+			releasep(mp)
+			if mp.lockedg == nil {
+				return nil
+			}
+		}
+		if mp.p != nil {
+			if own && !mp.yielded {
+				return mp.lockedg
+			}
+			mp.yielded = false
+			if gp := runqget(mp.p); gp != nil {
+				return gp
+			}
+			if gp := globrunqget(); gp != nil {
+				return gp
+			}
+			if gp := runqsteal(mp.p); gp != nil {
+				return gp
+			}
+			if own {
+				return mp.lockedg
+			}
+			releasep(mp)
+			if mp.lockedg == nil {
+				return nil
+			}
+		}
+
+		// The thread has no processor. Its own goroutine is either blocked,
+		// or runnable and waiting for a processor to be handed to the
+		// thread by releasep.
+		if nextp := atomic.LoadPointer(&mp.nextp); nextp != unsafe.Pointer(uintptr(0)) {
+			mp.nextp = unsafe.Pointer(uintptr(0))
+			mp.p = (*p)(nextp)
+			continue
+		}
+		if !mp.waiting && (own || atomic.LoadInt32(&sched.runqsize) > 0) {
+			lock(&sched.lock)
+			if pp := pidleget(); pp != nil {
+				pp.status = pRunning
+				mp.p = pp
+			} else if own {
+				mp.waiting = true
+				mp.waitlink = sched.waitm
+				sched.waitm = mp
+				atomic.AddInt32(&sched.nwaitm, 1)
+			}
+			unlock(&sched.lock)
+			if mp.p != nil {
+				continue
+			}
+		}
+		if mp.lockedg == nil {
+			// The thread's goroutine left a system call and was queued
+			// for another thread to run.
+			return nil
+		}
+		usleep(50)
+	}
+}
+
+// releasep releases the thread's processor. The goroutines of a
+// processor retired by GOMAXPROCS are moved to the global run queue;
+// other processors are passed to handoffp.
+func releasep(mp *m) {
+	pp := mp.p
+	mp.p = nil
+	lock(&sched.lock)
+	if pp.id < sched.gomaxprocs {
+		handoffp(pp)
+		unlock(&sched.lock)
+		return
+	}
+	pp.status = pDead
+	gcstopp(pp)
+	unlock(&sched.lock)
+	for gp := runqget(pp); gp != nil; gp = runqget(pp) {
+		globrunqput(gp)
+	}
+}
+
+// handoffp hands an unused processor, along with any goroutines queued on
+// it, to a thread waiting to run its own goroutine, if there is one, or
+// else makes it idle. While the world is stopped, the processor is held
+// for the collection instead. sched.lock must be held.
+func handoffp(pp *p) {
+	gcstopp(pp)
+	if atomic.LoadInt32(&sched.gcwaiting) != 0 {
+		pp.status = pGCStop
+		return
+	}
+	w := sched.waitm
+	if w == nil {
+		pidleput(pp)
+		return
+	}
+	sched.waitm = w.waitlink
+	w.waitlink = nil
+	w.waiting = false
+	atomic.AddInt32(&sched.nwaitm, -1)
+	pp.status = pRunning
+	atomic.StorePointer(&w.nextp, unsafe.Pointer(pp))
+}
+
+// pidleput adds a processor to the idle list. sched.lock must be held.
+func pidleput(pp *p) {
+	pp.status = pIdle
+	pp.link = sched.pidle
+	sched.pidle = pp
+	atomic.AddInt32(&sched.npidle, 1)
+}
+
+// pidleget removes a processor from the idle list, returning nil if the
+// list is empty. sched.lock must be held.
+func pidleget() *p {
+	pp := sched.pidle
+	if pp != nil {
+		sched.pidle = pp.link
+		pp.link = nil
+		atomic.AddInt32(&sched.npidle, -1)
+	}
+	return pp
+}
+
+// wakep starts a thread to run queued goroutines, if there is an idle
+// processor.
+func wakep() {
+	if atomic.LoadInt32(&sched.npidle) == 0 {
+		return
+	}
+	lock(&sched.lock)
+	pp := pidleget()
+	unlock(&sched.lock)
+	if pp != nil {
+		pp.status = pRunning
+		newm(pp)
+	}
+}
+
+// newm starts a thread that runs goroutines on the specified processor.
+func newm(pp *p) {
+	mp := (*m)(malloc(int(unsafe.Sizeof(m{}))))
+	mp.p = pp
+	mp.g0 = malloc(ucontextSize)
+	thread := (*uintptr)(malloc(int(unsafe.Sizeof(uintptr(0)))))
+	pthread_create(thread, unsafe.Pointer(uintptr(0)), funcaddr(unsafe.Pointer(&mstartfunc)), unsafe.Pointer(mp))
+	pthread_detach(*thread)
+	free(unsafe.Pointer(thread))
+}
+
+// mstart is the start routine of threads started by newm.
+func mstart(arg unsafe.Pointer) unsafe.Pointer {
+	pthread_setspecific(sched.mkey, arg)
+	schedule()
+	free((*m)(arg).g0)
+	free(arg)
+	return unsafe.Pointer(uintptr(0))
+}
+
+// runqput queues a runnable goroutine on the specified processor, or on
+// the global run queue if the processor is nil or its queue is full.
+func runqput(pp *p, gp *g) {
+	if pp != nil {
+		lock(&pp.lock)
+		if pp.size < runqsize {
+			pp.runq[(pp.head+pp.size)%runqsize] = gp
+			pp.size++
+			unlock(&pp.lock)
+			return
+		}
+		unlock(&pp.lock)
+	}
+	globrunqput(gp)
+}
+
+// runqget dequeues a goroutine from the processor's run queue, returning
+// nil if it is empty.
+func runqget(pp *p) *g {
+	lock(&pp.lock)
+	var gp *g
+	if pp.size > 0 {
+		gp = pp.runq[pp.head]
+		pp.runq[pp.head] = nil
+		pp.head = (pp.head + 1) % runqsize
+		pp.size--
+	}
+	unlock(&pp.lock)
+	return gp
+}
+
+// runqsteal steals half of the goroutines queued on another processor,
+// queueing them on pp, and returns one of them, or nil if there were none
+// to steal.
+func runqsteal(pp *p) *g {
+	for i := 1; i < maxprocs; i++ {
+		victim := &allp[(pp.id+i)%maxprocs]
+		if victim.size == 0 {
+			continue
+		}
+		lock(&victim.lock)
+		n := victim.size - victim.size/2
+		var first, last *g
+		for j := 0; j < n; j++ {
+			gp := victim.runq[victim.head]
+			victim.runq[victim.head] = nil
+			victim.head = (victim.head + 1) % runqsize
+			victim.size--
+			if first == nil {
+				first = gp
+			} else {
+				last.schedlink = gp
+			}
+			last = gp
+		}
+		unlock(&victim.lock)
+		if first != nil {
+			for gp := first.schedlink; gp != nil; {
+				next := gp.schedlink
+				gp.schedlink = nil
+				runqput(pp, gp)
+				gp = next
+			}
+			first.schedlink = nil
+			return first
+		}
+	}
+	return nil
+}
+
+// globrunqput queues a goroutine on the global run queue.
+func globrunqput(gp *g) {
+	lock(&sched.lock)
+	gp.schedlink = nil
+	if sched.runqtail == nil {
+		sched.runqhead = gp
+	} else {
+		sched.runqtail.schedlink = gp
+	}
+	sched.runqtail = gp
+	atomic.AddInt32(&sched.runqsize, 1)
+	unlock(&sched.lock)
+}
+
+// globrunqget dequeues a goroutine from the global run queue, returning
+// nil if it is empty.
+func globrunqget() *g {
+	if atomic.LoadInt32(&sched.runqsize) == 0 {
+		return nil
+	}
+	lock(&sched.lock)
+	gp := sched.runqhead
+	if gp != nil {
+		sched.runqhead = gp.schedlink
+		if sched.runqhead == nil {
+			sched.runqtail = nil
+		}
+		gp.schedlink = nil
+		atomic.AddInt32(&sched.runqsize, -1)
+	}
+	unlock(&sched.lock)
+	return gp
+}
+
+// GOMAXPROCS sets the maximum number of CPUs that can be executing
+// simultaneously and returns the previous setting. If n < 1, it does not
+// change the current setting. Processors beyond a reduced setting are
+// retired by their threads once their current goroutines block or yield.
+func GOMAXPROCS(n int) int {
+	if n > maxprocs {
+		n = maxprocs
+	}
+	lock(&sched.lock)
+	old := sched.gomaxprocs
+	if n < 1 || n == old {
+		unlock(&sched.lock)
+		return old
+	}
+	sched.gomaxprocs = n
+	var pidle *p
+	for pp := sched.pidle; pp != nil; {
+		next := pp.link
+		if pp.id < n {
+			pp.link = pidle
+			pidle = pp
+		} else {
+			pp.status = pDead
+			atomic.AddInt32(&sched.npidle, -1)
+		}
+		pp = next
+	}
+	sched.pidle = pidle
+	for i := old; i < n; i++ {
+		if allp[i].status == pDead {
+			handoffp(&allp[i])
+		}
+	}
+	unlock(&sched.lock)
+	for i := old; i < n; i++ {
+		wakep()
+	}
+	return old
+}
+
+// stoptheworld stops every processor other than the calling thread's, so
+// that the collector may scan the stacks of all goroutines while no other
+// goroutine runs. Idle processors are taken at once; running processors
+// stop at their next safe point. Goroutines are not preempted, so one that
+// loops without reaching a safe point delays the collection until it does.
+func stoptheworld() {
+	mp := getm()
+	lock(&sched.lock)
+	atomic.StoreInt32(&sched.gcwaiting, 1)
+	for pp := pidleget(); pp != nil; pp = pidleget() {
+		pp.status = pGCStop
+	}
+	for i := range allp {
+		pp := &allp[i]
+		if pp != mp.p && pp.status == pRunning {
+			pp.gcwait = true
+			atomic.AddInt32(&sched.stopwait, 1)
+		}
+	}
+	unlock(&sched.lock)
+	for atomic.LoadInt32(&sched.stopwait) > 0 {
+		usleep(50)
+	}
+}
+
+// starttheworld releases the processors stopped by stoptheworld: threads
+// waiting in gcstopm resume, and the idle processors are handed off again,
+// with threads started for the goroutines queued meanwhile.
+func starttheworld() {
+	lock(&sched.lock)
+	atomic.StoreInt32(&sched.gcwaiting, 0)
+	for i := range allp {
+		pp := &allp[i]
+		switch {
+		case pp.status != pGCStop:
+		case pp.id < sched.gomaxprocs:
+			handoffp(pp)
+		default:
+			pp.status = pDead
+		}
+	}
+	unlock(&sched.lock)
+	for n := atomic.LoadInt32(&sched.runqsize); n > 0 && atomic.LoadInt32(&sched.npidle) > 0; n-- {
+		wakep()
+	}
+}
+
+// gcstopm stops the thread, and its processor if it has one, at a safe
+// point while the world is stopped, and waits for the collection to
+// finish. The registers and shadow stack of the thread's goroutine, if it
+// is running one, are saved for the collector, which scans its stack from
+// the saved stack pointer.
+func gcstopm(mp *m) {
+	if gp := mp.curg; gp != nil {
+		getcontext(gp.context)
+		gp.gcroots = gcshadowstack()
+	}
+	if mp.p != nil {
+		lock(&sched.lock)
+		gcstopp(mp.p)
+		unlock(&sched.lock)
+	}
+	for atomic.LoadInt32(&sched.gcwaiting) != 0 {
+		usleep(50)
+	}
+}
+
+// gcstopp records that a processor that was running when the world was
+// stopped has stopped. sched.lock must be held.
+func gcstopp(pp *p) {
+	if pp.gcwait {
+		pp.gcwait = false
+		atomic.AddInt32(&sched.stopwait, -1)
+	}
+}
+
+// NumCPU returns the number of logical CPUs on the local machine.
+func NumCPU() int {
+	return sysconf(_SC_NPROCESSORS_ONLN)
+}
+
+// NumGoroutine returns the number of goroutines that currently exist.
+func NumGoroutine() int {
+	return int(atomic.LoadInt32(&sched.ngoroutines))
+}
+
+//extern getcontext
+func getcontext(ucp unsafe.Pointer) int32
+
+//extern makecontext
+func makecontext(ucp, fn unsafe.Pointer, argc int32)
+
+//extern swapcontext
+func swapcontext(oucp, ucp unsafe.Pointer) int32
+
+//extern pthread_create
+func pthread_create(thread *uintptr, attr, start, arg unsafe.Pointer) int32
+
+//extern pthread_detach
+func pthread_detach(thread uintptr) int32
+
+//extern pthread_self
+func pthread_self() uintptr
+
+//extern pthread_getattr_np
+func pthread_getattr_np(thread uintptr, attr unsafe.Pointer) int32
+
+//extern pthread_attr_getstack
+func pthread_attr_getstack(attr unsafe.Pointer, addr, size *uintptr) int32
+
+//extern pthread_attr_destroy
+func pthread_attr_destroy(attr unsafe.Pointer) int32
+
+//extern pthread_key_create
+func pthread_key_create(key *uint32, destructor unsafe.Pointer) int32
+
+//extern pthread_getspecific
+func pthread_getspecific(key uint32) unsafe.Pointer
+
+//extern pthread_setspecific
+func pthread_setspecific(key uint32, value unsafe.Pointer) int32
+
+//extern sysconf
+func sysconf(name int32) int
+
+//extern usleep
+func usleep(usec uint32) int32
+
+// vim: set ft=go :
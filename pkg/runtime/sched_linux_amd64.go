@@ -0,0 +1,46 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package runtime
+
+// The layout of the C library's ucontext_t and pthread_attr_t, as used by
+// the scheduler, and of the parts of ucontext_t read by the garbage
+// collector.
+const (
+	ucontextSize      = 968
+	ucontextLink      = 8   // uc_link
+	ucontextStackSp   = 16  // uc_stack.ss_sp
+	ucontextStackSize = 32  // uc_stack.ss_size
+	ucontextSp        = 160 // uc_mcontext.gregs[REG_RSP]
+
+	pthreadAttrSize = 56
+)
+
+const _SC_NPROCESSORS_ONLN = 84
+
+// The layout of struct epoll_event, which is packed on amd64.
+const (
+	epolleventSize = 12
+	epolleventData = 4 // data
+)
+
+// vim: set ft=go :
@@ -36,8 +36,11 @@ type slice struct {
 func sliceappend(t unsafe.Pointer, a, b slice) slice {
 	typ := (*type_)(t)
 	slicetyp := (*sliceType)(unsafe.Pointer(&typ.commonType))
+	if b.len == 0 {
+		return a
+	}
 	if a.cap-a.len < b.len {
-		a = slicegrow(slicetyp, a, a.cap+(b.len-(a.cap-a.len)))
+		a = growslice(slicetyp, a, b.len)
 	}
 	end := uintptr(unsafe.Pointer(a.array))
 	end += uintptr(a.len) * slicetyp.elem.size
@@ -47,8 +50,22 @@ func sliceappend(t unsafe.Pointer, a, b slice) slice {
 	return a
 }
 
+// growslice returns a copy of the slice with room for at least n more
+// elements. The capacity is doubled until it is sufficient, so a sequence
+// of appends takes amortised constant time per element.
+func growslice(t *sliceType, a slice, n uint) slice {
+	newcap := a.cap
+	if newcap == 0 {
+		newcap = 4
+	}
+	for newcap-a.len < n {
+		newcap *= 2
+	}
+	return slicegrow(t, a, newcap)
+}
+
 func slicegrow(t *sliceType, a slice, newcap uint) slice {
-	mem := malloc(int(t.elem.size * uintptr(newcap)))
+	mem := mallocgc(t.elem.size * uintptr(newcap))
 	if a.len > 0 {
 		size := uintptr(a.len) * t.elem.size
 		memcpy(mem, unsafe.Pointer(a.array), int(size))
@@ -56,20 +73,31 @@ func slicegrow(t *sliceType, a slice, newcap uint) slice {
 	return slice{(*uint8)(mem), a.len, newcap}
 }
 
-func sliceslice(t unsafe.Pointer, a slice, low, high int32) slice {
-	if high == -1 {
-		high = a.len
-	} else {
-		// TODO check upper bound
+// slicecopy takes a slice type and two slices, and copies elements from
+// the second slice to the first using the element type's copy algorithm.
+// The number of elements copied is returned.
+func slicecopy(t unsafe.Pointer, dst, src slice) int {
+	typ := (*type_)(t)
+	slicetyp := (*sliceType)(unsafe.Pointer(&typ.commonType))
+	n := dst.len
+	if src.len < n {
+		n = src.len
 	}
-	a.cap -= low
-	a.len = high - low
-	if low > 0 {
-		typ := (*type_)(t)
-		slicetyp := (*sliceType)(unsafe.Pointer(&typ.commonType))
-		newptr := uintptr(unsafe.Pointer(a.array))
-		newptr += uintptr(slicetyp.elem.size * uintptr(low))
-		a.array = (*uint8)(unsafe.Pointer(newptr))
+	size := slicetyp.elem.size
+	copyfn := copyalgat(unsafe.Pointer(slicetyp.elem.alg))
+	dstptr := uintptr(unsafe.Pointer(dst.array))
+	srcptr := uintptr(unsafe.Pointer(src.array))
+	if dstptr <= srcptr {
+		for i := uint(0); i < n; i++ {
+			offset := uintptr(i) * size
+			copyfn(size, unsafe.Pointer(dstptr+offset), unsafe.Pointer(srcptr+offset))
+		}
+	} else {
+		// The slices may overlap, so copy from the end.
+		for i := n; i > 0; i-- {
+			offset := uintptr(i-1) * size
+			copyfn(size, unsafe.Pointer(dstptr+offset), unsafe.Pointer(srcptr+offset))
+		}
 	}
-	return a
+	return int(n)
 }
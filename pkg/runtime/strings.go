@@ -36,7 +36,7 @@ func strcat(a, b _string) _string {
 		return a
 	}
 
-	mem := malloc(a.len + b.len)
+	mem := mallocgc(uintptr(a.len + b.len))
 	if mem == unsafe.Pointer(uintptr(0)) {
 		// TODO panic? abort?
 	}
@@ -49,6 +49,31 @@ func strcat(a, b _string) _string {
 	return a
 }
 
+// concatstrings concatenates the strings in a with a single allocation.
+// If at most one of the strings is non-empty, it is returned as is.
+func concatstrings(a []_string) _string {
+	var n, count int
+	var last _string
+	for _, s := range a {
+		if s.len > 0 {
+			n += s.len
+			count++
+			last = s
+		}
+	}
+	if count <= 1 {
+		return last
+	}
+
+	mem := mallocgc(uintptr(n))
+	offset := uintptr(mem)
+	for _, s := range a {
+		memcpy(unsafe.Pointer(offset), unsafe.Pointer(s.str), s.len)
+		offset += uintptr(s.len)
+	}
+	return _string{(*uint8)(mem), n}
+}
+
 func strcmp(a, b _string) int32 {
 	sz := a.len
 	if b.len < sz {
@@ -75,19 +100,167 @@ func strcmp(a, b _string) int32 {
 	return 0
 }
 
-func stringslice(a _string, low, high int32) _string {
-	if high == -1 {
-		high = a.len
-	} else {
-		// TODO check upper bound
+// strnext decodes the UTF-8 encoded rune in s at byte offset i, returning
+// the offset of the following rune and the decoded value. Invalid encodings
+// decode to U+FFFD with a width of one byte.
+func strnext(s _string, i int) (next int, value int32) {
+	const runeError = 0xFFFD
+	p := uintptr(unsafe.Pointer(s.str)) + uintptr(i)
+	c0 := *(*uint8)(unsafe.Pointer(p))
+	if c0 < 0x80 {
+		return i + 1, int32(c0)
 	}
-	if low > 0 {
-		newptr := uintptr(unsafe.Pointer(a.str))
-		newptr += uintptr(low)
-		a.str = (*uint8)(unsafe.Pointer(newptr))
+
+	var width int
+	var min int32
+	switch {
+	case c0&0xE0 == 0xC0:
+		width, value, min = 2, int32(c0&0x1F), 0x80
+	case c0&0xF0 == 0xE0:
+		width, value, min = 3, int32(c0&0x0F), 0x800
+	case c0&0xF8 == 0xF0:
+		width, value, min = 4, int32(c0&0x07), 0x10000
+	default:
+		return i + 1, runeError
 	}
-	a.len = high - low
-	return a
+	if width > s.len-i {
+		return i + 1, runeError
+	}
+	for j := 1; j < width; j++ {
+		c := *(*uint8)(unsafe.Pointer(p + uintptr(j)))
+		if c&0xC0 != 0x80 {
+			return i + 1, runeError
+		}
+		value = value<<6 | int32(c&0x3F)
+	}
+	if value < min || value > 0x10FFFF || (value >= 0xD800 && value <= 0xDFFF) {
+		return i + 1, runeError
+	}
+	return i + width, value
+}
+
+// encoderune writes the UTF-8 encoding of r to p, returning the number of
+// bytes written. Invalid runes are encoded as U+FFFD.
+func encoderune(p uintptr, r int32) int {
+	if r < 0 || r > 0x10FFFF || (r >= 0xD800 && r <= 0xDFFF) {
+		r = 0xFFFD
+	}
+	switch {
+	case r < 0x80:
+		*(*uint8)(unsafe.Pointer(p)) = uint8(r)
+		return 1
+	case r < 0x800:
+		*(*uint8)(unsafe.Pointer(p)) = 0xC0 | uint8(r>>6)
+		*(*uint8)(unsafe.Pointer(p + 1)) = 0x80 | uint8(r&0x3F)
+		return 2
+	case r < 0x10000:
+		*(*uint8)(unsafe.Pointer(p)) = 0xE0 | uint8(r>>12)
+		*(*uint8)(unsafe.Pointer(p + 1)) = 0x80 | uint8((r>>6)&0x3F)
+		*(*uint8)(unsafe.Pointer(p + 2)) = 0x80 | uint8(r&0x3F)
+		return 3
+	}
+	*(*uint8)(unsafe.Pointer(p)) = 0xF0 | uint8(r>>18)
+	*(*uint8)(unsafe.Pointer(p + 1)) = 0x80 | uint8((r>>12)&0x3F)
+	*(*uint8)(unsafe.Pointer(p + 2)) = 0x80 | uint8((r>>6)&0x3F)
+	*(*uint8)(unsafe.Pointer(p + 3)) = 0x80 | uint8(r&0x3F)
+	return 4
+}
+
+// runewidth returns the number of bytes in the UTF-8 encoding of r.
+func runewidth(r int32) int {
+	switch {
+	case r < 0 || r > 0x10FFFF || (r >= 0xD800 && r <= 0xDFFF):
+		return 3 // U+FFFD
+	case r < 0x80:
+		return 1
+	case r < 0x800:
+		return 2
+	case r < 0x10000:
+		return 3
+	}
+	return 4
+}
+
+// strtobytes converts a string to a newly allocated []byte.
+func strtobytes(s _string) slice {
+	var b slice
+	if s.len > 0 {
+		b.array = (*uint8)(mallocgc(uintptr(s.len)))
+		memcpy(unsafe.Pointer(b.array), unsafe.Pointer(s.str), s.len)
+		b.len = uint(s.len)
+		b.cap = uint(s.len)
+	}
+	return b
+}
+
+// bytestostr converts a []byte to a newly allocated string.
+func bytestostr(b slice) _string {
+	var s _string
+	if b.len > 0 {
+		s.str = (*uint8)(mallocgc(uintptr(b.len)))
+		memcpy(unsafe.Pointer(s.str), unsafe.Pointer(b.array), int(b.len))
+		s.len = int(b.len)
+	}
+	return s
+}
+
+// strtorunes converts a string to a newly allocated []rune, decoding
+// invalid UTF-8 sequences as U+FFFD.
+func strtorunes(s _string) slice {
+	n := 0
+	for i := 0; i < s.len; n++ {
+		i, _ = strnext(s, i)
+	}
+	var r slice
+	if n > 0 {
+		var value int32
+		r.array = (*uint8)(mallocgc(uintptr(n) * unsafe.Sizeof(value)))
+		r.len = uint(n)
+		r.cap = uint(n)
+		p := uintptr(unsafe.Pointer(r.array))
+		for i := 0; i < s.len; p += unsafe.Sizeof(value) {
+			i, value = strnext(s, i)
+			*(*int32)(unsafe.Pointer(p)) = value
+		}
+	}
+	return r
+}
+
+// runestostr converts a []rune to a newly allocated string containing
+// the UTF-8 encoding of each rune.
+func runestostr(r slice) _string {
+	var value int32
+	n := 0
+	for i := uint(0); i < r.len; i++ {
+		p := uintptr(unsafe.Pointer(r.array)) + uintptr(i)*unsafe.Sizeof(value)
+		n += runewidth(*(*int32)(unsafe.Pointer(p)))
+	}
+	var s _string
+	if n > 0 {
+		s.str = (*uint8)(mallocgc(uintptr(n)))
+		s.len = n
+		dst := uintptr(unsafe.Pointer(s.str))
+		for i := uint(0); i < r.len; i++ {
+			p := uintptr(unsafe.Pointer(r.array)) + uintptr(i)*unsafe.Sizeof(value)
+			dst += uintptr(encoderune(dst, *(*int32)(unsafe.Pointer(p))))
+		}
+	}
+	return s
+}
+
+// intstr converts an integer to a string containing the UTF-8 encoding
+// of the integer's value as a rune. Values outside the range of valid
+// Unicode code points produce "\uFFFD".
+func intstr(v int64) _string {
+	r := int32(0xFFFD)
+	if v >= 0 && v <= 0x10FFFF {
+		r = int32(v)
+	}
+	var s _string
+	s.len = runewidth(r)
+	s.str = (*uint8)(mallocgc(uintptr(s.len)))
+	encoderune(uintptr(unsafe.Pointer(s.str)), r)
+	return s
 }
 
 // vim: set ft=go:
@@ -0,0 +1,120 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package runtime
+
+import "unsafe"
+
+// Stack traces are found with the C library's backtrace function, which
+// unwinds the stack using the unwind tables emitted with each function.
+// Return addresses are mapped to functions with the tables of functions
+// that the compiler creates for each package, which are registered with
+// addfunctab before the program starts. The tables record the position of
+// each function's declaration, but not of the calls within it, so each
+// frame is printed with the line of the function's declaration and the
+// offset of the return address into the function.
+
+// funcinfo is an entry in a table of functions created by the compiler.
+// line is zero for functions generated by the compiler, and -1 for the C
+// entry points into the program.
+type funcinfo struct {
+	entry uintptr
+	name  string
+	file  string
+	line  int
+}
+
+// functab is a table of n funcinfos registered by addfunctab.
+type functab struct {
+	next  *functab
+	funcs unsafe.Pointer
+	n     int
+}
+
+var functabs *functab
+
+// maxframes is the largest number of frames printed by traceback.
+const maxframes = 100
+
+// addfunctab registers a package's table of functions. The compiler
+// generates a call to it in a constructor in each package.
+func addfunctab(funcs unsafe.Pointer, n int) {
+	t := (*functab)(malloc(int(unsafe.Sizeof(functab{}))))
+	t.funcs = funcs
+	t.n = n
+	t.next = functabs
+	functabs = t
+}
+
+// findfunc returns the function containing pc, which is the function with
+// the highest entry address not above pc, or nil if there is none.
+func findfunc(pc uintptr) *funcinfo {
+	var f *funcinfo
+	for t := functabs; t != nil; t = t.next {
+		for i := 0; i < t.n; i++ {
+			fi := (*funcinfo)(unsafe.Pointer(uintptr(t.funcs) + uintptr(i)*unsafe.Sizeof(funcinfo{})))
+			if fi.entry <= pc && (f == nil || fi.entry > f.entry) {
+				f = fi
+			}
+		}
+	}
+	return f
+}
+
+// traceback prints the calling goroutine's stack, as gc does. Frames of
+// the runtime and of functions generated by the compiler are omitted. The
+// trace ends at the goroutine's entry point: runtime.goentry, or a C entry
+// point for goroutines that were not started by a go statement.
+func traceback() {
+	wordsize := unsafe.Sizeof(uintptr(0))
+	pcs := malloc(maxframes * int(wordsize))
+	n := int(backtrace(pcs, maxframes))
+	for i := 0; i < n; i++ {
+		pc := *(*uintptr)(unsafe.Pointer(uintptr(pcs) + uintptr(i)*wordsize))
+		// The return address may be the start of the next function, if
+		// the call does not return.
+		f := findfunc(pc - 1)
+		if f == nil {
+			continue
+		}
+		if f.line < 0 || f.name == "runtime.goentry" {
+			break
+		}
+		if f.line == 0 || hasprefix(f.name, "runtime.") {
+			continue
+		}
+		print(f.name, "(...)\n\t", f.file, ":", f.line, " +")
+		printhex(uint64(pc - f.entry))
+		print("\n")
+	}
+	free(pcs)
+}
+
+// hasprefix reports whether s begins with prefix.
+func hasprefix(s, prefix string) bool {
+	return len(s) >= len(prefix) && s[:len(prefix)] == prefix
+}
+
+//extern backtrace
+func backtrace(buffer unsafe.Pointer, size int32) int32
+
+// vim: set ft=go :
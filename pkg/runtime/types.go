@@ -43,10 +43,15 @@ type commonType struct {
 	alg        *uintptr
 	gc         unsafe.Pointer
 	string     *string
-	_          uintptr // *uncommonType
+	uncommon   *uncommonType
 	_          uintptr // *runtimeType
 }
 
+// Type kinds, as defined in the reflect package.
+const (
+	kindInterface = 20
+)
+
 type uncommonType struct {
 	name    *string
 	pkgPath *string
@@ -62,11 +67,28 @@ type _method struct {
 	tfn     unsafe.Pointer
 }
 
+type imethod struct {
+	name    *string
+	pkgPath *string
+	typ     *type_
+}
+
+type interfaceType struct {
+	commonType
+	methods []imethod
+}
+
 type sliceType struct {
 	commonType
 	elem *type_
 }
 
+type chanType struct {
+	commonType
+	elem *type_
+	dir  uintptr
+}
+
 type mapType struct {
 	commonType
 	key  *type_
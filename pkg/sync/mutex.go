@@ -0,0 +1,54 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sync
+
+import "sync/atomic"
+
+// A Mutex is a mutual exclusion lock. The zero value for a Mutex is an
+// unlocked mutex.
+type Mutex struct {
+	state int32
+}
+
+const mutexLocked = 1
+
+// Lock locks m. If the lock is already in use, the calling goroutine
+// blocks until the mutex is available.
+func (m *Mutex) Lock() {
+	for !atomic.CompareAndSwapInt32(&m.state, 0, mutexLocked) {
+		runtime_Gosched()
+	}
+}
+
+// Unlock unlocks m. It is a run-time error if m is not locked on entry.
+//
+// A locked Mutex is not associated with a particular goroutine. It is
+// allowed for one goroutine to lock a Mutex and then arrange for another
+// goroutine to unlock it.
+func (m *Mutex) Unlock() {
+	if !atomic.CompareAndSwapInt32(&m.state, mutexLocked, 0) {
+		panic("sync: unlock of unlocked mutex")
+	}
+}
+
+// vim: set ft=go :
@@ -1,5 +1,5 @@
 /*
-Copyright (c) 2011, 2012 Andrew Wilkins <axwalk@gmail.com>
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
 
 Permission is hereby granted, free of charge, to any person obtaining a copy of
 this software and associated documentation files (the "Software"), to deal in
@@ -20,25 +20,30 @@ OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
 SOFTWARE.
 */
 
-package llgo
-
-import (
-	"github.com/axw/gollvm/llvm"
-)
-
-func getnewgoroutine(module llvm.Module) llvm.Value {
-	fn := module.NamedFunction("llgo_newgoroutine")
-	if fn.IsNil() {
-		i8Ptr := llvm.PointerType(llvm.Int8Type(), 0)
-		VoidFnPtr := llvm.PointerType(llvm.FunctionType(
-			llvm.VoidType(), []llvm.Type{i8Ptr}, false), 0)
-		i32 := llvm.Int32Type()
-		fn_type := llvm.FunctionType(
-			llvm.VoidType(), []llvm.Type{VoidFnPtr, i8Ptr, i32}, true)
-		fn = llvm.AddFunction(module, "llgo_newgoroutine", fn_type)
-		fn.SetFunctionCallConv(llvm.CCallConv)
+package sync
+
+import "sync/atomic"
+
+// Once is an object that will perform exactly one action.
+type Once struct {
+	m    Mutex
+	done uint32
+}
+
+// Do calls the function f if and only if Do is being called for the
+// first time for this instance of Once. Calls to Do for the same Once
+// return only once f has returned, so f has been run by the time any
+// call returns.
+func (o *Once) Do(f func()) {
+	if atomic.LoadUint32(&o.done) == 1 {
+		return
+	}
+	o.m.Lock()
+	if o.done == 0 {
+		f()
+		atomic.StoreUint32(&o.done, 1)
 	}
-	return fn
+	o.m.Unlock()
 }
 
 // vim: set ft=go :
@@ -0,0 +1,94 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package sync
+
+import "sync/atomic"
+
+// An RWMutex is a reader/writer mutual exclusion lock. The lock can be
+// held by an arbitrary number of readers or a single writer. The zero
+// value for an RWMutex is an unlocked mutex.
+//
+// readers counts the goroutines holding a read lock, or is -1 while a
+// writer holds the lock. w is held by a writer from the time it starts
+// waiting, so that writers take turns.
+type RWMutex struct {
+	w       Mutex
+	readers int32
+}
+
+// RLock locks rw for reading.
+func (rw *RWMutex) RLock() {
+	for {
+		r := atomic.LoadInt32(&rw.readers)
+		if r >= 0 && atomic.CompareAndSwapInt32(&rw.readers, r, r+1) {
+			return
+		}
+		runtime_Gosched()
+	}
+}
+
+// RUnlock undoes a single RLock call; it does not affect other
+// simultaneous readers. It is a run-time error if rw is not locked for
+// reading on entry.
+func (rw *RWMutex) RUnlock() {
+	for {
+		r := atomic.LoadInt32(&rw.readers)
+		if r <= 0 {
+			panic("sync: RUnlock of unlocked RWMutex")
+		}
+		if atomic.CompareAndSwapInt32(&rw.readers, r, r-1) {
+			return
+		}
+	}
+}
+
+// Lock locks rw for writing. If the lock is already locked for reading
+// or writing, Lock blocks until the lock is available.
+func (rw *RWMutex) Lock() {
+	rw.w.Lock()
+	for !atomic.CompareAndSwapInt32(&rw.readers, 0, -1) {
+		runtime_Gosched()
+	}
+}
+
+// Unlock unlocks rw for writing. It is a run-time error if rw is not
+// locked for writing on entry.
+func (rw *RWMutex) Unlock() {
+	if !atomic.CompareAndSwapInt32(&rw.readers, -1, 0) {
+		panic("sync: Unlock of unlocked RWMutex")
+	}
+	rw.w.Unlock()
+}
+
+// RLocker returns a Locker interface that implements the Lock and Unlock
+// methods by calling rw.RLock and rw.RUnlock.
+func (rw *RWMutex) RLocker() Locker {
+	return (*rlocker)(rw)
+}
+
+type rlocker RWMutex
+
+func (r *rlocker) Lock()   { (*RWMutex)(r).RLock() }
+func (r *rlocker) Unlock() { (*RWMutex)(r).RUnlock() }
+
+// vim: set ft=go :
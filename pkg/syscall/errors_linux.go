@@ -0,0 +1,115 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package syscall
+
+// Error numbers for Linux.
+const (
+	E2BIG        = Errno(0x7)
+	EACCES       = Errno(0xd)
+	EAGAIN       = Errno(0xb)
+	EBADF        = Errno(0x9)
+	EBUSY        = Errno(0x10)
+	ECHILD       = Errno(0xa)
+	EDEADLK      = Errno(0x23)
+	EDEADLOCK    = Errno(0x23)
+	EDOM         = Errno(0x21)
+	EEXIST       = Errno(0x11)
+	EFAULT       = Errno(0xe)
+	EFBIG        = Errno(0x1b)
+	EINTR        = Errno(0x4)
+	EINVAL       = Errno(0x16)
+	EIO          = Errno(0x5)
+	EISDIR       = Errno(0x15)
+	ELOOP        = Errno(0x28)
+	EMFILE       = Errno(0x18)
+	EMLINK       = Errno(0x1f)
+	ENAMETOOLONG = Errno(0x24)
+	ENFILE       = Errno(0x17)
+	ENODEV       = Errno(0x13)
+	ENOENT       = Errno(0x2)
+	ENOEXEC      = Errno(0x8)
+	ENOLCK       = Errno(0x25)
+	ENOMEM       = Errno(0xc)
+	ENOSPC       = Errno(0x1c)
+	ENOSYS       = Errno(0x26)
+	ENOTBLK      = Errno(0xf)
+	ENOTDIR      = Errno(0x14)
+	ENOTEMPTY    = Errno(0x27)
+	ENOTTY       = Errno(0x19)
+	ENXIO        = Errno(0x6)
+	EPERM        = Errno(0x1)
+	EPIPE        = Errno(0x20)
+	ERANGE       = Errno(0x22)
+	EROFS        = Errno(0x1e)
+	ESPIPE       = Errno(0x1d)
+	ESRCH        = Errno(0x3)
+	ETXTBSY      = Errno(0x1a)
+	EWOULDBLOCK  = Errno(0xb)
+	EXDEV        = Errno(0x12)
+)
+
+// Error table
+var errors = [...]string{
+	1:  "operation not permitted",
+	2:  "no such file or directory",
+	3:  "no such process",
+	4:  "interrupted system call",
+	5:  "input/output error",
+	6:  "no such device or address",
+	7:  "argument list too long",
+	8:  "exec format error",
+	9:  "bad file descriptor",
+	10: "no child processes",
+	11: "resource temporarily unavailable",
+	12: "cannot allocate memory",
+	13: "permission denied",
+	14: "bad address",
+	15: "block device required",
+	16: "device or resource busy",
+	17: "file exists",
+	18: "invalid cross-device link",
+	19: "no such device",
+	20: "not a directory",
+	21: "is a directory",
+	22: "invalid argument",
+	23: "too many open files in system",
+	24: "too many open files",
+	25: "inappropriate ioctl for device",
+	26: "text file busy",
+	27: "file too large",
+	28: "no space left on device",
+	29: "illegal seek",
+	30: "read-only file system",
+	31: "too many links",
+	32: "broken pipe",
+	33: "numerical argument out of domain",
+	34: "numerical result out of range",
+	35: "resource deadlock avoided",
+	36: "file name too long",
+	37: "no locks available",
+	38: "function not implemented",
+	39: "directory not empty",
+	40: "too many levels of symbolic links",
+}
+
+// vim: set ft=go :
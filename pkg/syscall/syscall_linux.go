@@ -0,0 +1,222 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package syscall
+
+import "unsafe"
+
+const (
+	Stdin  = 0
+	Stdout = 1
+	Stderr = 2
+)
+
+// Flags for Open.
+const (
+	O_RDONLY   = 0x0
+	O_WRONLY   = 0x1
+	O_RDWR     = 0x2
+	O_CREAT    = 0x40
+	O_EXCL     = 0x80
+	O_TRUNC    = 0x200
+	O_APPEND   = 0x400
+	O_NONBLOCK = 0x800
+	O_CLOEXEC  = 0x80000
+)
+
+// Commands for fcntl.
+const (
+	F_GETFL = 0x3
+	F_SETFL = 0x4
+)
+
+// A Signal is a number describing a process signal.
+type Signal int
+
+const (
+	SIGHUP  = Signal(0x1)
+	SIGINT  = Signal(0x2)
+	SIGQUIT = Signal(0x3)
+	SIGKILL = Signal(0x9)
+	SIGPIPE = Signal(0xd)
+	SIGTERM = Signal(0xf)
+)
+
+func (s Signal) Signal() {}
+
+func (s Signal) String() string {
+	return "signal " + itoa(int(s))
+}
+
+func Read(fd int, p []byte) (int, error) {
+	r1, _, e := Syscall(SYS_READ, uintptr(fd), bufferPointer(p), uintptr(len(p)))
+	return int(r1), errnoErr(e)
+}
+
+func Write(fd int, p []byte) (int, error) {
+	r1, _, e := Syscall(SYS_WRITE, uintptr(fd), bufferPointer(p), uintptr(len(p)))
+	return int(r1), errnoErr(e)
+}
+
+func Open(path string, mode int, perm uint32) (int, error) {
+	p, err := BytePtrFromString(path)
+	if err != nil {
+		return -1, err
+	}
+	r1, _, e := Syscall(SYS_OPEN, uintptr(unsafe.Pointer(p)), uintptr(mode), uintptr(perm))
+	return int(r1), errnoErr(e)
+}
+
+func Close(fd int) error {
+	_, _, e := Syscall(SYS_CLOSE, uintptr(fd), 0, 0)
+	return errnoErr(e)
+}
+
+func Seek(fd int, offset int64, whence int) (int64, error) {
+	r1, _, e := Syscall(SYS_LSEEK, uintptr(fd), uintptr(offset), uintptr(whence))
+	return int64(r1), errnoErr(e)
+}
+
+func Fsync(fd int) error {
+	_, _, e := Syscall(SYS_FSYNC, uintptr(fd), 0, 0)
+	return errnoErr(e)
+}
+
+func Dup(oldfd int) (int, error) {
+	r1, _, e := RawSyscall(SYS_DUP, uintptr(oldfd), 0, 0)
+	return int(r1), errnoErr(e)
+}
+
+func Dup2(oldfd, newfd int) error {
+	_, _, e := RawSyscall(SYS_DUP2, uintptr(oldfd), uintptr(newfd), 0)
+	return errnoErr(e)
+}
+
+func Pipe(p []int) error {
+	if len(p) != 2 {
+		return EINVAL
+	}
+	var pp [2]int32
+	_, _, e := RawSyscall(SYS_PIPE, uintptr(unsafe.Pointer(&pp[0])), 0, 0)
+	if e != 0 {
+		return e
+	}
+	p[0], p[1] = int(pp[0]), int(pp[1])
+	return nil
+}
+
+// SetNonblock puts the file descriptor in non-blocking mode, or takes it
+// out of it.
+func SetNonblock(fd int, nonblocking bool) error {
+	flags, _, e := RawSyscall(SYS_FCNTL, uintptr(fd), F_GETFL, 0)
+	if e != 0 {
+		return e
+	}
+	if nonblocking {
+		flags |= O_NONBLOCK
+	} else {
+		flags &^= O_NONBLOCK
+	}
+	_, _, e = RawSyscall(SYS_FCNTL, uintptr(fd), F_SETFL, flags)
+	return errnoErr(e)
+}
+
+// pathSyscall calls a system call whose only argument is a path.
+func pathSyscall(trap uintptr, path string) error {
+	p, err := BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	_, _, e := Syscall(trap, uintptr(unsafe.Pointer(p)), 0, 0)
+	return errnoErr(e)
+}
+
+func Unlink(path string) error {
+	return pathSyscall(SYS_UNLINK, path)
+}
+
+func Rmdir(path string) error {
+	return pathSyscall(SYS_RMDIR, path)
+}
+
+func Chdir(path string) error {
+	return pathSyscall(SYS_CHDIR, path)
+}
+
+func Mkdir(path string, mode uint32) error {
+	p, err := BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	_, _, e := Syscall(SYS_MKDIR, uintptr(unsafe.Pointer(p)), uintptr(mode), 0)
+	return errnoErr(e)
+}
+
+func Rename(oldpath, newpath string) error {
+	from, err := BytePtrFromString(oldpath)
+	if err != nil {
+		return err
+	}
+	to, err := BytePtrFromString(newpath)
+	if err != nil {
+		return err
+	}
+	_, _, e := Syscall(SYS_RENAME, uintptr(unsafe.Pointer(from)), uintptr(unsafe.Pointer(to)), 0)
+	return errnoErr(e)
+}
+
+func Getcwd(buf []byte) (int, error) {
+	r1, _, e := Syscall(SYS_GETCWD, bufferPointer(buf), uintptr(len(buf)), 0)
+	return int(r1), errnoErr(e)
+}
+
+func Getpid() int {
+	r1, _, _ := RawSyscall(SYS_GETPID, 0, 0, 0)
+	return int(r1)
+}
+
+func Getppid() int {
+	r1, _, _ := RawSyscall(SYS_GETPPID, 0, 0, 0)
+	return int(r1)
+}
+
+func Getuid() int {
+	r1, _, _ := RawSyscall(SYS_GETUID, 0, 0, 0)
+	return int(r1)
+}
+
+func Getgid() int {
+	r1, _, _ := RawSyscall(SYS_GETGID, 0, 0, 0)
+	return int(r1)
+}
+
+func Kill(pid int, sig Signal) error {
+	_, _, e := RawSyscall(SYS_KILL, uintptr(pid), uintptr(sig), 0)
+	return errnoErr(e)
+}
+
+// Exit terminates the process immediately with the specified status.
+func Exit(code int) {
+	RawSyscall(SYS_EXIT_GROUP, uintptr(code), 0, 0)
+}
+
+// vim: set ft=go :
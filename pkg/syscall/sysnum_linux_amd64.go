@@ -0,0 +1,52 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package syscall
+
+// System call numbers for linux/amd64.
+const (
+	SYS_READ       = 0
+	SYS_WRITE      = 1
+	SYS_OPEN       = 2
+	SYS_CLOSE      = 3
+	SYS_LSEEK      = 8
+	SYS_PIPE       = 22
+	SYS_DUP        = 32
+	SYS_DUP2       = 33
+	SYS_GETPID     = 39
+	SYS_EXIT       = 60
+	SYS_KILL       = 62
+	SYS_FCNTL      = 72
+	SYS_FSYNC      = 74
+	SYS_GETCWD     = 79
+	SYS_CHDIR      = 80
+	SYS_RENAME     = 82
+	SYS_MKDIR      = 83
+	SYS_RMDIR      = 84
+	SYS_UNLINK     = 87
+	SYS_GETUID     = 102
+	SYS_GETGID     = 104
+	SYS_GETPPID    = 110
+	SYS_EXIT_GROUP = 231
+)
+
+// vim: set ft=go :
@@ -0,0 +1,179 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package testing
+
+// The fmt package is not yet compiled by llgo, so the log methods of T
+// format their arguments themselves. Strings, booleans, integers, errors
+// and values with a String method are formatted as by fmt; other values
+// are shown as "?". Of Printf's verbs, only %v, %s, %d, %t, %q and %% are
+// understood, and flags and widths are ignored.
+
+type stringer interface {
+	String() string
+}
+
+// sprintln formats the arguments as fmt.Sprintln does, without the
+// trailing newline.
+func sprintln(args []interface{}) string {
+	var buf []byte
+	for i, arg := range args {
+		if i > 0 {
+			buf = append(buf, ' ')
+		}
+		buf = append(buf, format(arg, 'v')...)
+	}
+	return string(buf)
+}
+
+// sprintf formats the arguments according to the format, as fmt.Sprintf
+// does.
+func sprintf(f string, args []interface{}) string {
+	var buf []byte
+	argnum := 0
+	for i := 0; i < len(f); i++ {
+		if f[i] != '%' {
+			buf = append(buf, f[i])
+			continue
+		}
+		// Skip flags and widths.
+		i++
+		for i < len(f) && (f[i] == '+' || f[i] == '-' || f[i] == '#' || f[i] == ' ' || '0' <= f[i] && f[i] <= '9') {
+			i++
+		}
+		if i == len(f) {
+			buf = append(buf, "%!(NOVERB)"...)
+			break
+		}
+		verb := f[i]
+		switch {
+		case verb == '%':
+			buf = append(buf, '%')
+		case argnum >= len(args):
+			buf = append(buf, "%!"...)
+			buf = append(buf, verb)
+			buf = append(buf, "(MISSING)"...)
+		default:
+			buf = append(buf, format(args[argnum], verb)...)
+			argnum++
+		}
+	}
+	if argnum < len(args) {
+		buf = append(buf, "%!(EXTRA "...)
+		buf = append(buf, sprintln(args[argnum:])...)
+		buf = append(buf, ')')
+	}
+	return string(buf)
+}
+
+// format formats a value for the verb.
+func format(arg interface{}, verb byte) string {
+	var s string
+	switch arg := arg.(type) {
+	case nil:
+		s = "<nil>"
+	case string:
+		s = arg
+	case bool:
+		s = "false"
+		if arg {
+			s = "true"
+		}
+	case int:
+		s = itoa(int64(arg))
+	case int8:
+		s = itoa(int64(arg))
+	case int16:
+		s = itoa(int64(arg))
+	case int32:
+		s = itoa(int64(arg))
+	case int64:
+		s = itoa(arg)
+	case uint:
+		s = utoa(uint64(arg))
+	case uint8:
+		s = utoa(uint64(arg))
+	case uint16:
+		s = utoa(uint64(arg))
+	case uint32:
+		s = utoa(uint64(arg))
+	case uint64:
+		s = utoa(arg)
+	case uintptr:
+		s = utoa(uint64(arg))
+	case error:
+		s = arg.Error()
+	case stringer:
+		s = arg.String()
+	default:
+		s = "?"
+	}
+	if verb == 'q' {
+		return quote(s)
+	}
+	return s
+}
+
+func itoa(i int64) string {
+	if i < 0 {
+		return "-" + utoa(uint64(-i))
+	}
+	return utoa(uint64(i))
+}
+
+func utoa(u uint64) string {
+	var buf [20]byte
+	n := len(buf)
+	for {
+		n--
+		buf[n] = byte('0' + u%10)
+		u /= 10
+		if u == 0 {
+			break
+		}
+	}
+	return string(buf[n:])
+}
+
+// quote returns the string as a double-quoted Go string literal, escaping
+// quotes, backslashes and control characters.
+func quote(s string) string {
+	const hex = "0123456789abcdef"
+	buf := []byte{'"'}
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' || c == '\\':
+			buf = append(buf, '\\', c)
+		case c == '\n':
+			buf = append(buf, '\\', 'n')
+		case c == '\t':
+			buf = append(buf, '\\', 't')
+		case c < ' ' || c == 0x7f:
+			buf = append(buf, '\\', 'x', hex[c>>4], hex[c&0xf])
+		default:
+			buf = append(buf, c)
+		}
+	}
+	return string(append(buf, '"'))
+}
+
+// vim: set ft=go :
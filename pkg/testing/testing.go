@@ -0,0 +1,181 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+// Package testing provides the parts of the standard testing package used
+// by "llgo test": the T type passed to TestXxx functions, and Main, which
+// the generated test program calls to run them.
+//
+// There is no support for recover or runtime.Goexit, so each test runs on
+// its own goroutine, and FailNow parks the goroutine forever instead of
+// unwinding it; the test's deferred calls are not run.
+package testing
+
+import (
+	"os"
+)
+
+// InternalTest is a test function found by "llgo test", with its name.
+type InternalTest struct {
+	Name string
+	F    func(*T)
+}
+
+// T is a type passed to Test functions to manage test state and support
+// formatted test logs. Logs are accumulated during execution and dumped to
+// standard output when done.
+type T struct {
+	name   string
+	failed bool
+	output []byte
+	done   chan bool
+}
+
+// Fail marks the function as having failed but continues execution.
+func (t *T) Fail() {
+	t.failed = true
+}
+
+// Failed reports whether the function has failed.
+func (t *T) Failed() bool {
+	return t.failed
+}
+
+// FailNow marks the function as having failed and stops its execution.
+// Execution will continue at the next test.
+func (t *T) FailNow() {
+	t.Fail()
+	t.done <- true
+	<-make(chan bool)
+}
+
+// log records the text in the test's log, indented and on its own line.
+func (t *T) log(s string) {
+	t.output = append(t.output, "\t"...)
+	t.output = append(t.output, s...)
+	if len(s) == 0 || s[len(s)-1] != '\n' {
+		t.output = append(t.output, '\n')
+	}
+}
+
+// Log formats its arguments using default formatting, analogous to
+// Println, and records the text in the error log.
+func (t *T) Log(args ...interface{}) { t.log(sprintln(args)) }
+
+// Logf formats its arguments according to the format, analogous to
+// Printf, and records the text in the error log.
+func (t *T) Logf(format string, args ...interface{}) { t.log(sprintf(format, args)) }
+
+// Error is equivalent to Log() followed by Fail().
+func (t *T) Error(args ...interface{}) {
+	t.log(sprintln(args))
+	t.Fail()
+}
+
+// Errorf is equivalent to Logf() followed by Fail().
+func (t *T) Errorf(format string, args ...interface{}) {
+	t.log(sprintf(format, args))
+	t.Fail()
+}
+
+// Fatal is equivalent to Log() followed by FailNow().
+func (t *T) Fatal(args ...interface{}) {
+	t.log(sprintln(args))
+	t.FailNow()
+}
+
+// Fatalf is equivalent to Logf() followed by FailNow().
+func (t *T) Fatalf(format string, args ...interface{}) {
+	t.log(sprintf(format, args))
+	t.FailNow()
+}
+
+// Parallel is accepted for compatibility; tests are always run one at a
+// time.
+func (t *T) Parallel() {}
+
+// run runs the test function on a new goroutine, and waits for it to
+// return or call FailNow.
+func (t *T) run(f func(*T)) {
+	go func() {
+		f(t)
+		t.done <- true
+	}()
+	<-t.done
+}
+
+// Main runs the tests whose names contain the string given with
+// -test.run, or all of them, and exits with status 1 if any failed. The
+// name and log of each failed test are printed, as are those of passed
+// tests with -test.v.
+func Main(tests []InternalTest) {
+	verbose, pattern := false, ""
+	for _, arg := range os.Args[1:] {
+		switch {
+		case arg == "-test.v" || arg == "-v":
+			verbose = true
+		case hasPrefix(arg, "-test.run="):
+			pattern = arg[len("-test.run="):]
+		case hasPrefix(arg, "-run="):
+			pattern = arg[len("-run="):]
+		}
+	}
+
+	ok := true
+	for _, test := range tests {
+		if !contains(test.Name, pattern) {
+			continue
+		}
+		if verbose {
+			os.Stdout.WriteString("=== RUN " + test.Name + "\n")
+		}
+		t := &T{name: test.Name, done: make(chan bool)}
+		t.run(test.F)
+		if t.failed {
+			ok = false
+			os.Stdout.WriteString("--- FAIL: " + test.Name + "\n")
+			os.Stdout.Write(t.output)
+		} else if verbose {
+			os.Stdout.WriteString("--- PASS: " + test.Name + "\n")
+			os.Stdout.Write(t.output)
+		}
+	}
+	if !ok {
+		os.Stdout.WriteString("FAIL\n")
+		os.Exit(1)
+	}
+	os.Stdout.WriteString("PASS\n")
+}
+
+func hasPrefix(s, prefix string) bool {
+	return len(s) >= len(prefix) && s[:len(prefix)] == prefix
+}
+
+func contains(s, substr string) bool {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if s[i:i+len(substr)] == substr {
+			return true
+		}
+	}
+	return false
+}
+
+// vim: set ft=go :
@@ -27,14 +27,15 @@ import (
 	"github.com/axw/gollvm/llvm"
 	"github.com/axw/llgo/types"
 	"go/ast"
+	"strings"
 )
 
 func getprintf(module llvm.Module) llvm.Value {
 	printf := module.NamedFunction("printf")
 	if printf.IsNil() {
-		CharPtr := llvm.PointerType(llvm.Int8Type(), 0)
+		CharPtr := llvm.PointerType(module.Context().Int8Type(), 0)
 		fn_type := llvm.FunctionType(
-			llvm.Int32Type(), []llvm.Type{CharPtr}, true)
+			module.Context().Int32Type(), []llvm.Type{CharPtr}, true)
 		printf = llvm.AddFunction(module, "printf", fn_type)
 		printf.SetFunctionCallConv(llvm.CCallConv)
 	}
@@ -43,11 +44,11 @@ func getprintf(module llvm.Module) llvm.Value {
 
 func (c *compiler) getBoolString(v llvm.Value) llvm.Value {
 	startBlock := c.builder.GetInsertBlock()
-	resultBlock := llvm.InsertBasicBlock(startBlock, "")
+	resultBlock := c.context.InsertBasicBlock(startBlock, "")
 	resultBlock.MoveAfter(startBlock)
-	falseBlock := llvm.InsertBasicBlock(resultBlock, "")
+	falseBlock := c.context.InsertBasicBlock(resultBlock, "")
 
-	CharPtr := llvm.PointerType(llvm.Int8Type(), 0)
+	CharPtr := llvm.PointerType(c.context.Int8Type(), 0)
 	falseString := c.builder.CreateGlobalStringPtr("false", "")
 	falseString = c.builder.CreateBitCast(falseString, CharPtr, "")
 	trueString := c.builder.CreateGlobalStringPtr("true", "")
@@ -63,116 +64,164 @@ func (c *compiler) getBoolString(v llvm.Value) llvm.Value {
 	return result
 }
 
+// printValues prints values in the format of gc's print and println.
+// Integers, strings and booleans are printed with printf; floats, pointers,
+// interfaces and slices are printed by functions in the runtime, which in
+// turn use printf, so that the output is ordered.
 func (c *compiler) printValues(println_ bool, values ...Value) Value {
-	var args []llvm.Value = nil
-	if len(values) > 0 {
-		format := ""
-		args = make([]llvm.Value, 0, len(values)+1)
-		for i, value := range values {
-			llvm_value := value.LLVMValue()
-
-			// If it's a named type, get the underlying type.
-			typ := value.Type()
-			if name, isname := typ.(*types.Name); isname {
-				typ = name.Underlying
-			}
+	// int, uint and uintptr are the same size as a pointer.
+	intFormat, uintFormat := "%d", "%u"
+	if c.target.PointerSize() == 8 {
+		intFormat, uintFormat = "%lld", "%llu" // FIXME windows
+	}
 
-			if println_ && i > 0 {
-				format += " "
-			}
-			switch typ := typ.(type) {
-			case *types.Basic:
-				switch typ.Kind {
-				case types.UintKind:
-					format += "%u" // TODO make 32/64-bit
-				case types.Uint8Kind:
-					format += "%hhu"
-				case types.Uint16Kind:
-					format += "%hu"
-				case types.Uint32Kind, types.UintptrKind: // FIXME uintptr to become bitwidth dependent
-					format += "%u"
-				case types.Uint64Kind:
-					format += "%llu" // FIXME windows
-				case types.IntKind:
-					format += "%d" // TODO make 32/64-bit
-				case types.Int8Kind:
-					format += "%hhd"
-				case types.Int16Kind:
-					format += "%hd"
-				case types.Int32Kind:
-					format += "%d"
-				case types.Int64Kind:
-					format += "%lld" // FIXME windows
-				case types.Float32Kind:
-					format += "%f"
-				case types.Float64Kind:
-					// Doesn't match up with gc's formatting, which allocates
-					// a minimum of three digits for the exponent.
-					format += "%+e"
-				case types.StringKind:
-					ptrval := c.builder.CreateExtractValue(llvm_value, 0, "")
-					lenval := c.builder.CreateExtractValue(llvm_value, 1, "")
-					llvm_value = ptrval
-					args = append(args, lenval)
-					format += "%.*s"
-				case types.BoolKind:
-					format += "%s"
-					llvm_value = c.getBoolString(llvm_value)
-				case types.UnsafePointerKind:
-					format += "%p"
-				default:
-					panic(fmt.Sprint("Unhandled Basic Kind: ", typ.Kind))
-				}
+	printf := getprintf(c.module.Module)
+	var format string
+	var args []llvm.Value
+	var result llvm.Value
+	flush := func() {
+		if format == "" {
+			return
+		}
+		args = append([]llvm.Value{c.builder.CreateGlobalStringPtr(format, "")}, args...)
+		result = c.builder.CreateCall(printf, args, "")
+		format, args = "", nil
+	}
+	printPointer := func(ptr llvm.Value) {
+		flush()
+		fn := c.NamedFunction("runtime.printpointer", "func f(p unsafe.Pointer)")
+		ptr = c.builder.CreatePtrToInt(ptr, c.target.IntPtrType(), "")
+		c.builder.CreateCall(fn, []llvm.Value{ptr}, "")
+	}
 
-			case *types.Interface:
-				format += "(%p,%p)"
-				ival := c.builder.CreateExtractValue(llvm_value, 0, "")
-				itype := c.builder.CreateExtractValue(llvm_value, 1, "")
-				args = append(args, itype)
-				llvm_value = ival
-
-			case *types.Slice, *types.Array:
-				// If we see a constant array, we either:
-				//     Create an internal constant if it's a constant array, or
-				//     Create space on the stack and store it there.
-				init_ := value
-				init_value := init_.LLVMValue()
-				switch init_.(type) {
-				case ConstValue:
-					llvm_value = llvm.AddGlobal(c.module.Module, init_value.Type(), "")
-					llvm_value.SetInitializer(init_value)
-					llvm_value.SetGlobalConstant(true)
-					//llvm_value.SetLinkage(llvm.PrivateLinkage)
-				case *LLVMValue:
-					llvm_value = c.builder.CreateAlloca(init_value.Type(), "")
-					c.builder.CreateStore(init_value, llvm_value)
-				}
-				// FIXME don't assume string...
-				format += "%s"
+	for i, value := range values {
+		llvm_value := value.LLVMValue()
 
-			case *types.Pointer:
-				format += "0x%x"
+		// If it's a named type, get the underlying type.
+		typ := value.Type()
+		if name, isname := typ.(*types.Name); isname {
+			typ = name.Underlying
+		}
 
+		if println_ && i > 0 {
+			format += " "
+		}
+		switch typ := typ.(type) {
+		case *types.Basic:
+			switch typ.Kind {
+			case types.UintKind:
+				format += uintFormat
+			case types.Uint8Kind:
+				format += "%hhu"
+			case types.Uint16Kind:
+				format += "%hu"
+			case types.Uint32Kind:
+				format += "%u"
+			case types.UintptrKind:
+				format += uintFormat
+			case types.Uint64Kind:
+				format += "%llu" // FIXME windows
+			case types.IntKind:
+				format += intFormat
+			case types.Int8Kind:
+				format += "%hhd"
+			case types.Int16Kind:
+				format += "%hd"
+			case types.Int32Kind:
+				format += "%d"
+			case types.Int64Kind:
+				format += "%lld" // FIXME windows
+			case types.Float32Kind:
+				flush()
+				fn := c.NamedFunction("runtime.printfloat32", "func f(v float32)")
+				v := value.Convert(types.Float32).LLVMValue()
+				c.builder.CreateCall(fn, []llvm.Value{v}, "")
+				continue
+			case types.Float64Kind:
+				flush()
+				fn := c.NamedFunction("runtime.printfloat", "func f(v float64)")
+				v := value.Convert(types.Float64).LLVMValue()
+				c.builder.CreateCall(fn, []llvm.Value{v}, "")
+				continue
+			case types.Complex64Kind, types.Complex128Kind:
+				flush()
+				var fn llvm.Value
+				if typ.Kind == types.Complex64Kind {
+					fn = c.NamedFunction("runtime.printcomplex64", "func f(re, im float32)")
+				} else {
+					fn = c.NamedFunction("runtime.printcomplex", "func f(re, im float64)")
+				}
+				re := c.builder.CreateExtractValue(llvm_value, 0, "")
+				im := c.builder.CreateExtractValue(llvm_value, 1, "")
+				c.builder.CreateCall(fn, []llvm.Value{re, im}, "")
+				continue
+			case types.StringKind:
+				// printf stops at a NUL, so strings that may contain
+				// one are written out by the runtime.
+				if v, ok := value.(ConstValue); !ok || strings.Contains(v.Val.(string), "\x00") {
+					flush()
+					fn := c.NamedFunction("runtime.printstring", "func f(s string)")
+					c.builder.CreateCall(fn, []llvm.Value{llvm_value}, "")
+					continue
+				}
+				ptrval := c.builder.CreateExtractValue(llvm_value, 0, "")
+				lenval := c.builder.CreateExtractValue(llvm_value, 1, "")
+				// printf's precision argument is a C int.
+				lenval = c.builder.CreateTrunc(lenval, c.context.Int32Type(), "")
+				llvm_value = ptrval
+				args = append(args, lenval)
+				format += "%.*s"
+			case types.BoolKind:
+				format += "%s"
+				llvm_value = c.getBoolString(llvm_value)
+			case types.UnsafePointerKind:
+				printPointer(llvm_value)
+				continue
 			default:
-				panic(fmt.Sprint("Unhandled type kind: ", typ))
+				panic(fmt.Sprint("Unhandled Basic Kind: ", typ.Kind))
 			}
 
-			args = append(args, llvm_value)
-		}
-		if println_ {
-			format += "\n"
+		case *types.Interface:
+			flush()
+			fn := c.NamedFunction("runtime.printeface", "func f(typ, data unsafe.Pointer)")
+			typptr := c.builder.CreateExtractValue(llvm_value, 0, "")
+			data := c.builder.CreateExtractValue(llvm_value, 1, "")
+			typptr = c.builder.CreatePtrToInt(typptr, c.target.IntPtrType(), "")
+			data = c.builder.CreatePtrToInt(data, c.target.IntPtrType(), "")
+			c.builder.CreateCall(fn, []llvm.Value{typptr, data}, "")
+			continue
+
+		case *types.Slice:
+			flush()
+			fn := c.NamedFunction("runtime.printslice", "func f(s slice)")
+			i8slice := fn.Type().ElementType().ParamTypes()[0]
+			s := c.coerceSlice(llvm_value, i8slice)
+			c.builder.CreateCall(fn, []llvm.Value{s}, "")
+			continue
+
+		case *types.Func:
+			// Functions are printed as their function pointer.
+			printPointer(c.builder.CreateExtractValue(llvm_value, 0, ""))
+			continue
+
+		case *types.Pointer, *types.Map, *types.Chan:
+			printPointer(llvm_value)
+			continue
+
+		default:
+			panic(fmt.Sprint("Unhandled type kind: ", typ))
 		}
-		formatval := c.builder.CreateGlobalStringPtr(format, "")
-		args = append([]llvm.Value{formatval}, args...)
-	} else {
-		var format string
-		if println_ {
-			format = "\n"
-		}
-		args = []llvm.Value{c.builder.CreateGlobalStringPtr(format, "")}
+
+		args = append(args, llvm_value)
 	}
-	printf := getprintf(c.module.Module)
-	return c.NewLLVMValue(c.builder.CreateCall(printf, args, ""), types.Int32)
+	if println_ {
+		format += "\n"
+	}
+	if format != "" || result.IsNil() {
+		args = append([]llvm.Value{c.builder.CreateGlobalStringPtr(format, "")}, args...)
+		result = c.builder.CreateCall(printf, args, "")
+	}
+	return c.NewLLVMValue(result, types.Int32)
 }
 
 func (c *compiler) VisitPrint(expr *ast.CallExpr, println_ bool) Value {
@@ -26,17 +26,8 @@ import (
 	"fmt"
 	"github.com/axw/llgo/types"
 	"go/ast"
-	"go/build"
 	"go/parser"
 	"go/token"
-	"path"
-	"sync"
-)
-
-var (
-	parseReflectOnce   sync.Once
-	parseReflectResult *ast.Package
-	parseReflectError  error
 )
 
 func parseFile(fset *token.FileSet, name string) (*ast.File, error) {
@@ -61,46 +52,120 @@ func parseFiles(fset *token.FileSet, filenames []string) (files map[string]*ast.
 	return
 }
 
-// parseReflect parses the standard "reflect" package, builds and type-checks
-// its AST. This is used to generate runtime type structures, using the
-// existing types.Type -> llvm.Type mapping code.
-func parseReflect() (*ast.Package, error) {
-	parseReflectOnce.Do(doParseReflect)
-	return parseReflectResult, parseReflectError
-}
+// runtimeTypes holds the layouts of the runtime type structures defined in
+// the reflect package. The layouts are defined here, rather than parsed from
+// the reflect package's source, so that creating a TypeMap is cheap and does
+// not depend on the installed Go tree. They must be kept in sync with
+// reflect/type.go and pkg/runtime/types.go.
+var runtimeTypes = makeRuntimeTypes()
 
-func doParseReflect() {
-	buildpkg, err := build.Import("reflect", "", 0)
-	if err != nil {
-		parseReflectResult, parseReflectError = nil, err
-		return
-	}
+type reflectTypes struct {
+	runtimeType,
+	commonType,
+	uncommonType,
+	arrayType,
+	chanType,
+	funcType,
+	interfaceType,
+	mapType,
+	ptrType,
+	sliceType,
+	structType types.Type
+}
 
-	filenames := make([]string, len(buildpkg.GoFiles))
-	for i, f := range buildpkg.GoFiles {
-		filenames[i] = path.Join(buildpkg.Dir, f)
+func makeRuntimeTypes() *reflectTypes {
+	field := func(name string, typ types.Type) *ast.Object {
+		obj := ast.NewObj(ast.Var, name)
+		obj.Type = typ
+		return obj
 	}
-	fset := token.NewFileSet()
-	files, err := parseFiles(fset, filenames)
-	if err != nil {
-		parseReflectResult, parseReflectError = nil, err
-		return
+	structType := func(fields ...*ast.Object) *types.Struct {
+		return &types.Struct{Fields: fields}
 	}
+	ptr := func(t types.Type) types.Type { return &types.Pointer{Base: t} }
+	slice := func(t types.Type) types.Type { return &types.Slice{Elt: t} }
 
-	pkg, err := ast.NewPackage(fset, files, types.GcImport, types.Universe)
-	if err != nil {
-		parseReflectResult, parseReflectError = nil, err
-		return
-	}
+	var r reflectTypes
+	r.runtimeType = &types.Interface{}
+	runtimeTypePtr := ptr(r.runtimeType)
+	stringPtr := ptr(types.String)
 
-	_, err = types.Check(fset, pkg)
-	if err != nil {
-		parseReflectResult, parseReflectError = nil, err
-		return
+	method := structType(
+		field("name", stringPtr),
+		field("pkgPath", stringPtr),
+		field("mtyp", runtimeTypePtr),
+		field("typ", runtimeTypePtr),
+		field("ifn", types.UnsafePointer),
+		field("tfn", types.UnsafePointer),
+	)
+	r.uncommonType = structType(
+		field("name", stringPtr),
+		field("pkgPath", stringPtr),
+		field("methods", slice(method)),
+	)
+	r.commonType = structType(
+		field("size", types.Uintptr),
+		field("hash", types.Uint32),
+		field("_", types.Uint8),
+		field("align", types.Uint8),
+		field("fieldAlign", types.Uint8),
+		field("kind", types.Uint8),
+		field("alg", ptr(types.Uintptr)),
+		field("gc", types.UnsafePointer),
+		field("string", stringPtr),
+		field("", ptr(r.uncommonType)),
+		field("ptrToThis", runtimeTypePtr),
+	)
+	// As in reflect, the embedded commonType is tagged with the kind, so
+	// that otherwise identical layouts (e.g. ptrType and sliceType) are
+	// distinct types.
+	kindType := func(kind string, fields ...*ast.Object) *types.Struct {
+		common := field("", r.commonType)
+		s := structType(append([]*ast.Object{common}, fields...)...)
+		s.Tags = make([]string, len(s.Fields))
+		s.Tags[0] = `reflect:"` + kind + `"`
+		return s
 	}
 
-	parseReflectResult, parseReflectError = pkg, nil
-	return
+	r.arrayType = kindType(
+		"array",
+		field("elem", runtimeTypePtr),
+		field("slice", runtimeTypePtr),
+		field("len", types.Uintptr),
+	)
+	r.chanType = kindType(
+		"chan",
+		field("elem", runtimeTypePtr),
+		field("dir", types.Uintptr),
+	)
+	r.funcType = kindType(
+		"func",
+		field("dotdotdot", types.Bool),
+		field("in", slice(runtimeTypePtr)),
+		field("out", slice(runtimeTypePtr)),
+	)
+	imethod := structType(
+		field("name", stringPtr),
+		field("pkgPath", stringPtr),
+		field("typ", runtimeTypePtr),
+	)
+	r.interfaceType = kindType("interface", field("methods", slice(imethod)))
+	r.mapType = kindType(
+		"map",
+		field("key", runtimeTypePtr),
+		field("elem", runtimeTypePtr),
+	)
+	r.ptrType = kindType("ptr", field("elem", runtimeTypePtr))
+	r.sliceType = kindType("slice", field("elem", runtimeTypePtr))
+	structField := structType(
+		field("name", stringPtr),
+		field("pkgPath", stringPtr),
+		field("typ", runtimeTypePtr),
+		field("tag", stringPtr),
+		field("offset", types.Uintptr),
+	)
+	r.structType = kindType("struct", field("fields", slice(structField)))
+	return &r
 }
 
 // vim: set ft=go:
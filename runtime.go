@@ -9,8 +9,13 @@ import (
 	"go/token"
 	"path"
 	"strings"
+	"sync"
 )
 
+// FunctionCache caches the runtime functions declared in a module. Each
+// compilation has its own cache, which is not safe for concurrent use; the
+// types of the runtime functions are cached by the compiler's
+// runtimeFuncTypes, which its compilations share.
 type FunctionCache struct {
 	*compiler
 	functions map[string]llvm.Value
@@ -31,42 +36,8 @@ func (c *FunctionCache) NamedFunction(name string, signature string) llvm.Value
 		value := c.Resolve(obj)
 		f = value.LLVMValue()
 	} else {
-		fset := token.NewFileSet()
-		code := `package runtime;import("unsafe");` + signature + `{panic()}`
-		file, err := parser.ParseFile(fset, "", code, 0)
-		if err != nil {
-			panic(err)
-		}
-
-		// Parse the runtime package, since we may need to refer to
-		// its types. TODO cache the package.
-		buildpkg, err := build.Import("github.com/axw/llgo/pkg/runtime", "", 0)
-		if err != nil {
-			panic(err)
-		}
-		runtimefiles := make([]string, len(buildpkg.GoFiles))
-		for i, f := range buildpkg.GoFiles {
-			runtimefiles[i] = path.Join(buildpkg.Dir, f)
-		}
-
-		files, err := parseFiles(fset, runtimefiles)
-		if err != nil {
-			panic(err)
-		}
-		files["<src>"] = file
-		pkg, err := ast.NewPackage(fset, files, types.GcImport, types.Universe)
-		if err != nil {
-			panic(err)
-		}
-
-		_, err = types.Check(fset, pkg)
-		if err != nil {
-			panic(err)
-		}
-
-		fdecl := file.Decls[len(file.Decls)-1].(*ast.FuncDecl)
-		ftype := fdecl.Name.Obj.Type.(*types.Func)
-		llvmfptrtype := c.types.ToLLVM(ftype)
+		ftype := c.runtimeFuncTypes.lookup(signature)
+		llvmfptrtype := c.types.rawFuncLLVMType(ftype)
 		f = llvm.AddFunction(c.module.Module, name, llvmfptrtype.ElementType())
 		if !strings.HasPrefix(name, "llvm.") {
 			f.SetLinkage(llvm.AvailableExternallyLinkage)
@@ -75,3 +46,62 @@ func (c *FunctionCache) NamedFunction(name string, signature string) llvm.Value
 	c.functions[name+":"+signature] = f
 	return f
 }
+
+// runtimeFuncTypes maps the signatures of runtime functions, as declared
+// with NamedFunction, to their types. It is safe for concurrent use.
+type runtimeFuncTypes struct {
+	mu    sync.Mutex
+	types map[string]*types.Func
+}
+
+// lookup returns the type of the runtime function with the specified
+// signature, type-checking the signature in the runtime package if it has
+// not been seen before.
+func (r *runtimeFuncTypes) lookup(signature string) *types.Func {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if ftype, ok := r.types[signature]; ok {
+		return ftype
+	}
+
+	fset := token.NewFileSet()
+	code := `package runtime;import("unsafe");` + signature + `{panic()}`
+	file, err := parser.ParseFile(fset, "", code, 0)
+	if err != nil {
+		panic(err)
+	}
+
+	// Parse the runtime package, since we may need to refer to
+	// its types. TODO cache the package.
+	buildpkg, err := build.Import("github.com/axw/llgo/pkg/runtime", "", 0)
+	if err != nil {
+		panic(err)
+	}
+	runtimefiles := make([]string, len(buildpkg.GoFiles))
+	for i, f := range buildpkg.GoFiles {
+		runtimefiles[i] = path.Join(buildpkg.Dir, f)
+	}
+
+	files, err := parseFiles(fset, runtimefiles)
+	if err != nil {
+		panic(err)
+	}
+	files["<src>"] = file
+	pkg, err := ast.NewPackage(fset, files, types.GcImport, types.Universe)
+	if err != nil {
+		panic(err)
+	}
+
+	_, err = types.Check(fset, pkg)
+	if err != nil {
+		panic(err)
+	}
+
+	fdecl := file.Decls[len(file.Decls)-1].(*ast.FuncDecl)
+	ftype := fdecl.Name.Obj.Type.(*types.Func)
+	if r.types == nil {
+		r.types = make(map[string]*types.Func)
+	}
+	r.types[signature] = ftype
+	return ftype
+}
@@ -36,7 +36,7 @@ func (c *compiler) alignofType(t types.Type) int {
 		case types.BoolKind:
 			return 1
 		case types.IntKind, types.UintKind:
-			return 4 // TODO make same as uintptr?
+			return c.target.PointerSize()
 		case types.Int8Kind, types.Uint8Kind:
 			return 1
 		case types.Int16Kind, types.Uint16Kind:
@@ -74,7 +74,7 @@ func (c *compiler) sizeofType(t types.Type) int {
 		case types.BoolKind:
 			return 1
 		case types.IntKind, types.UintKind:
-			return 4 // TODO make same as uintptr?
+			return c.target.PointerSize()
 		case types.Int8Kind, types.Uint8Kind:
 			return 1
 		case types.Int16Kind, types.Uint16Kind:
@@ -86,7 +86,7 @@ func (c *compiler) sizeofType(t types.Type) int {
 		case types.Complex128Kind:
 			return 16
 		case types.StringKind:
-			return c.target.PointerSize() + 4
+			return c.target.PointerSize() * 2
 		case types.UintptrKind, types.UnsafePointerKind:
 			return c.target.PointerSize()
 		}
@@ -113,9 +113,7 @@ func (c *compiler) sizeofType(t types.Type) int {
 		}
 		return (eltsize + eltpad) * int(t.Len)
 	case *types.Interface:
-		// XXX This needs to change if/when interfaces are
-		// changed to dynamically lookup methods, like in gc.
-		return (2+len(t.Methods)) * c.target.PointerSize()
+		return 2 * c.target.PointerSize()
 	default:
 		panic(fmt.Sprintf("unhandled type: %T", t))
 	}
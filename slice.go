@@ -30,11 +30,11 @@ import (
 
 // makeLiteralSlice allocates a new slice, storing in it the provided elements.
 func (c *compiler) makeLiteralSlice(v []llvm.Value, elttyp types.Type) llvm.Value {
-	n := llvm.ConstInt(llvm.Int32Type(), uint64(len(v)), false)
+	n := llvm.ConstInt(c.target.IntPtrType(), uint64(len(v)), false)
 	llvmelttyp := c.types.ToLLVM(elttyp)
-	mem := c.builder.CreateArrayMalloc(llvmelttyp, n, "")
+	mem := c.createArrayMalloc(llvmelttyp, n)
 	for i, value := range v {
-		indices := []llvm.Value{llvm.ConstInt(llvm.Int32Type(), uint64(i), false)}
+		indices := []llvm.Value{llvm.ConstInt(c.context.Int32Type(), uint64(i), false)}
 		ep := c.builder.CreateGEP(mem, indices, "")
 		c.builder.CreateStore(value, ep)
 	}
@@ -51,22 +51,19 @@ func (c *compiler) makeLiteralSlice(v []llvm.Value, elttyp types.Type) llvm.Valu
 func (c *compiler) makeSlice(elttyp types.Type, length, capacity Value) llvm.Value {
 	var lengthValue llvm.Value
 	if length != nil {
-		lengthValue = length.Convert(types.Int32).LLVMValue()
+		lengthValue = length.Convert(types.Int).LLVMValue()
 	} else {
-		lengthValue = llvm.ConstNull(llvm.Int32Type())
+		lengthValue = llvm.ConstNull(c.target.IntPtrType())
 	}
 
 	// TODO check capacity >= length
 	capacityValue := lengthValue
 	if capacity != nil {
-		capacityValue = capacity.Convert(types.Int32).LLVMValue()
+		capacityValue = capacity.Convert(types.Int).LLVMValue()
 	}
 
 	llvmelttyp := c.types.ToLLVM(elttyp)
-	mem := c.builder.CreateArrayMalloc(llvmelttyp, capacityValue, "")
-	sizeof := llvm.ConstTrunc(llvm.SizeOf(llvmelttyp), llvm.Int32Type())
-	size := c.builder.CreateMul(capacityValue, sizeof, "")
-	c.memsetZero(mem, size)
+	mem := c.createArrayMalloc(llvmelttyp, capacityValue)
 
 	slicetyp := types.Slice{Elt: elttyp}
 	struct_ := llvm.Undef(c.types.ToLLVM(&slicetyp))
@@ -91,10 +88,15 @@ func (c *compiler) coerceSlice(src llvm.Value, dsttyp llvm.Type) llvm.Value {
 	return dst
 }
 
+// VisitAppend lowers each form of append: appending zero or more elements,
+// appending the contents of another slice with "...", and appending the
+// bytes of a string to a []byte. The elements to append are presented to
+// runtime.sliceappend as a slice, which grows the destination as needed.
 func (c *compiler) VisitAppend(expr *ast.CallExpr) Value {
-	// TODO handle ellpisis arg
 	s := c.VisitExpr(expr.Args[0])
-	elem := c.VisitExpr(expr.Args[1])
+	if len(expr.Args) == 1 {
+		return s
+	}
 
 	sliceappend := c.NamedFunction("runtime.sliceappend", "func f(t uintptr, dst, src slice) slice")
 	i8slice := sliceappend.Type().ElementType().ReturnType()
@@ -105,15 +107,44 @@ func (c *compiler) VisitAppend(expr *ast.CallExpr) Value {
 	sliceTyp := a_.Type()
 	a := c.coerceSlice(a_, i8slice)
 
-	// Construct a fresh []int8 for the temporary slice.
-	b_ := elem.LLVMValue()
-	one := llvm.ConstInt(llvm.Int32Type(), 1, false)
-	mem := c.builder.CreateAlloca(elem.LLVMValue().Type(), "")
-	c.builder.CreateStore(b_, mem)
-	b := llvm.Undef(i8slice)
-	b = c.builder.CreateInsertValue(b, c.builder.CreateBitCast(mem, i8ptr, ""), 0, "")
-	b = c.builder.CreateInsertValue(b, one, 1, "")
-	b = c.builder.CreateInsertValue(b, one, 2, "")
+	var b llvm.Value
+	if expr.Ellipsis.IsValid() {
+		// append(s, t...): t is a slice, or a string if s is a []byte.
+		// Strings have the same leading data pointer and length.
+		other := c.VisitExpr(expr.Args[1])
+		if basicKind(other.Type()) == types.StringKind {
+			b_ := other.Convert(types.String).LLVMValue()
+			ptr := c.builder.CreateExtractValue(b_, 0, "")
+			length := c.builder.CreateExtractValue(b_, 1, "")
+			b = llvm.Undef(i8slice)
+			b = c.builder.CreateInsertValue(b, c.builder.CreateBitCast(ptr, i8ptr, ""), 0, "")
+			b = c.builder.CreateInsertValue(b, length, 1, "")
+			b = c.builder.CreateInsertValue(b, length, 2, "")
+		} else {
+			b = c.coerceSlice(other.LLVMValue(), i8slice)
+		}
+	} else {
+		// Construct a fresh []int8 for the temporary slice, backed by
+		// an array of the elements on the stack.
+		elttyp := types.Underlying(s.Type()).(*types.Slice).Elt
+		elems := expr.Args[1:]
+		arraytyp := &types.Array{Elt: elttyp, Len: uint64(len(elems))}
+		mem := c.allocateVar(nil, c.types.ToLLVM(arraytyp))
+		for i, arg := range elems {
+			elem := c.VisitExpr(arg).Convert(elttyp)
+			indices := []llvm.Value{
+				llvm.ConstNull(c.context.Int32Type()),
+				llvm.ConstInt(c.context.Int32Type(), uint64(i), false),
+			}
+			ptr := c.builder.CreateGEP(mem, indices, "")
+			c.builder.CreateStore(elem.LLVMValue(), ptr)
+		}
+		n := llvm.ConstInt(c.target.IntPtrType(), uint64(len(elems)), false)
+		b = llvm.Undef(i8slice)
+		b = c.builder.CreateInsertValue(b, c.builder.CreateBitCast(mem, i8ptr, ""), 0, "")
+		b = c.builder.CreateInsertValue(b, n, 1, "")
+		b = c.builder.CreateInsertValue(b, n, 2, "")
+	}
 
 	// Call runtime function, then coerce the result.
 	runtimeTyp := c.types.ToRuntime(s.Type())
@@ -123,58 +154,122 @@ func (c *compiler) VisitAppend(expr *ast.CallExpr) Value {
 	return c.NewLLVMValue(c.coerceSlice(result, sliceTyp), s.Type())
 }
 
+func (c *compiler) VisitCopy(expr *ast.CallExpr) Value {
+	dst := c.VisitExpr(expr.Args[0])
+	src := c.VisitExpr(expr.Args[1])
+	dstValue := dst.LLVMValue()
+	srcValue := src.LLVMValue()
+	elttyp := types.Underlying(dst.Type()).(*types.Slice).Elt
+
+	// Element types containing pointers are copied element-wise by the
+	// runtime, using the element type's copy algorithm.
+	if hasPointers(elttyp) {
+		slicecopy := c.NamedFunction("runtime.slicecopy", "func f(t uintptr, dst, src slice) int")
+		i8slice := slicecopy.Type().ElementType().ParamTypes()[1]
+		runtimeTyp := c.types.ToRuntime(dst.Type())
+		runtimeTyp = c.builder.CreatePtrToInt(runtimeTyp, c.target.IntPtrType(), "")
+		dstValue = c.coerceSlice(dstValue, i8slice)
+		srcValue = c.coerceSlice(srcValue, i8slice)
+		args := []llvm.Value{runtimeTyp, dstValue, srcValue}
+		result := c.builder.CreateCall(slicecopy, args, "")
+		return c.NewLLVMValue(result, types.Int)
+	}
+
+	// Otherwise, copy the minimum of the two lengths with memmove. The
+	// source may be a string, if the destination is a []byte; strings
+	// and slices both have the data pointer and length first.
+	dstlen := c.builder.CreateExtractValue(dstValue, 1, "")
+	srclen := c.builder.CreateExtractValue(srcValue, 1, "")
+	dstlt := c.builder.CreateICmp(llvm.IntULT, dstlen, srclen, "")
+	n := c.builder.CreateSelect(dstlt, dstlen, srclen, "")
+	llvmelttyp := c.types.ToLLVM(elttyp)
+	sizeof := llvm.ConstTruncOrBitCast(llvm.SizeOf(llvmelttyp), c.target.IntPtrType())
+	size := c.builder.CreateMul(n, sizeof, "")
+	memmove := c.NamedFunction("runtime.memmove", "func f(dst, src unsafe.Pointer, size int)")
+	dstptr := c.builder.CreateExtractValue(dstValue, 0, "")
+	dstptr = c.builder.CreatePtrToInt(dstptr, c.target.IntPtrType(), "")
+	srcptr := c.builder.CreateExtractValue(srcValue, 0, "")
+	srcptr = c.builder.CreatePtrToInt(srcptr, c.target.IntPtrType(), "")
+	c.builder.CreateCall(memmove, []llvm.Value{dstptr, srcptr, size}, "")
+	return c.NewLLVMValue(n, types.Int)
+}
+
+// VisitSliceExpr lowers slice expressions of slices, arrays, pointers to
+// arrays and strings, in both the two- and three-index forms. The result
+// shares the operand's memory.
 func (c *compiler) VisitSliceExpr(expr *ast.SliceExpr) Value {
-	// expr.X, expr.Low, expr.High
 	value := c.VisitExpr(expr.X)
-	var low, high llvm.Value
+	var low, high, max llvm.Value
 	if expr.Low != nil {
-		low = c.VisitExpr(expr.Low).Convert(types.Int32).LLVMValue()
+		low = c.VisitExpr(expr.Low).Convert(types.Int).LLVMValue()
 	} else {
-		low = llvm.ConstNull(llvm.Int32Type())
+		low = llvm.ConstNull(c.target.IntPtrType())
 	}
 	if expr.High != nil {
-		high = c.VisitExpr(expr.High).Convert(types.Int32).LLVMValue()
-	} else {
-		high = llvm.ConstAllOnes(llvm.Int32Type()) // -1
+		high = c.VisitExpr(expr.High).Convert(types.Int).LLVMValue()
+	}
+	if expr.Max != nil {
+		max = c.VisitExpr(expr.Max).Convert(types.Int).LLVMValue()
 	}
-	switch typ := types.Underlying(value.Type()).(type) {
+
+	// Slicing a pointer to an array slices the array it points to.
+	typ := types.Underlying(value.Type())
+	if ptrtyp, ok := typ.(*types.Pointer); ok {
+		arrayptr := value.LLVMValue()
+		c.nilCheck(arrayptr)
+		value = c.NewLLVMValue(arrayptr, value.Type()).makePointee()
+		typ = types.Underlying(ptrtyp.Base)
+	}
+
+	var ptr, length, capacity llvm.Value
+	var slicetyp types.Type
+	var isArray bool
+	switch typ := typ.(type) {
 	case *types.Array:
-		sliceslice := c.NamedFunction("runtime.sliceslice", "func f(t uintptr, s slice, low, high int32) slice")
-		i8slice := sliceslice.Type().ElementType().ReturnType()
-		sliceValue := llvm.Undef(i8slice) // temporary slice
 		arrayptr := value.(*LLVMValue).pointer.LLVMValue()
-		arrayptr = c.builder.CreateBitCast(arrayptr, i8slice.StructElementTypes()[0], "")
-		arraylen := llvm.ConstInt(llvm.Int32Type(), typ.Len, false)
-		sliceValue = c.builder.CreateInsertValue(sliceValue, arrayptr, 0, "")
-		sliceValue = c.builder.CreateInsertValue(sliceValue, arraylen, 1, "")
-		sliceValue = c.builder.CreateInsertValue(sliceValue, arraylen, 2, "")
-		sliceTyp := &types.Slice{Elt: typ.Elt}
-		runtimeTyp := c.types.ToRuntime(sliceTyp)
-		runtimeTyp = c.builder.CreatePtrToInt(runtimeTyp, c.target.IntPtrType(), "")
-		args := []llvm.Value{runtimeTyp, sliceValue, low, high}
-		result := c.builder.CreateCall(sliceslice, args, "")
-		llvmSliceTyp := c.types.ToLLVM(sliceTyp)
-		return c.NewLLVMValue(c.coerceSlice(result, llvmSliceTyp), sliceTyp)
+		zero := llvm.ConstNull(c.context.Int32Type())
+		ptr = c.builder.CreateGEP(arrayptr, []llvm.Value{zero, zero}, "")
+		length = llvm.ConstInt(c.target.IntPtrType(), typ.Len, false)
+		capacity = length
+		slicetyp = &types.Slice{Elt: typ.Elt}
+		isArray = true
 	case *types.Slice:
-		sliceslice := c.NamedFunction("runtime.sliceslice", "func f(t uintptr, s slice, low, high int32) slice")
-		i8slice := sliceslice.Type().ElementType().ReturnType()
-		sliceValue := value.LLVMValue()
-		sliceTyp := sliceValue.Type()
-		sliceValue = c.coerceSlice(sliceValue, i8slice)
-		runtimeTyp := c.types.ToRuntime(value.Type())
-		runtimeTyp = c.builder.CreatePtrToInt(runtimeTyp, c.target.IntPtrType(), "")
-		args := []llvm.Value{runtimeTyp, sliceValue, low, high}
-		result := c.builder.CreateCall(sliceslice, args, "")
-		return c.NewLLVMValue(c.coerceSlice(result, sliceTyp), value.Type())
+		slice := value.LLVMValue()
+		ptr = c.builder.CreateExtractValue(slice, 0, "")
+		length = c.builder.CreateExtractValue(slice, 1, "")
+		capacity = c.builder.CreateExtractValue(slice, 2, "")
+		slicetyp = value.Type()
 	case *types.Name: // String
-		stringslice := c.NamedFunction("runtime.stringslice", "func f(a string, low, high int32) string")
-		args := []llvm.Value{value.LLVMValue(), low, high}
-		result := c.builder.CreateCall(stringslice, args, "")
+		// Slicing a string shares its bytes, as strings are immutable.
+		str := value.LLVMValue()
+		length := c.builder.CreateExtractValue(str, 1, "")
+		if high.IsNil() {
+			high = length
+		}
+		c.sliceBoundsCheck(low, high, llvm.Value{}, length, true)
+		ptr := c.builder.CreateExtractValue(str, 0, "")
+		ptr = c.builder.CreateGEP(ptr, []llvm.Value{low}, "")
+		result := llvm.Undef(str.Type())
+		result = c.builder.CreateInsertValue(result, ptr, 0, "")
+		result = c.builder.CreateInsertValue(result, c.builder.CreateSub(high, low, ""), 1, "")
 		return c.NewLLVMValue(result, value.Type())
 	default:
 		panic("unimplemented")
 	}
-	panic("unreachable")
+
+	if high.IsNil() {
+		high = length
+	}
+	c.sliceBoundsCheck(low, high, max, capacity, isArray)
+	if max.IsNil() {
+		max = capacity
+	}
+	ptr = c.builder.CreateGEP(ptr, []llvm.Value{low}, "")
+	result := llvm.Undef(c.types.ToLLVM(slicetyp))
+	result = c.builder.CreateInsertValue(result, ptr, 0, "")
+	result = c.builder.CreateInsertValue(result, c.builder.CreateSub(high, low, ""), 1, "")
+	result = c.builder.CreateInsertValue(result, c.builder.CreateSub(max, low, ""), 2, "")
+	return c.NewLLVMValue(result, slicetyp)
 }
 
 // vim: set ft=go :
@@ -0,0 +1,232 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package llgo
+
+import (
+	"github.com/axw/gollvm/llvm"
+)
+
+// Local variables are compiled to stack memory, with loads and stores at
+// each use, which is simple to generate from the AST. The functions are
+// then converted to SSA form by LLVM's memory-to-register promotion, which
+// replaces the loads and stores with registers and places phi nodes where
+// control flow merges. Promotion applies only to allocas in a function's
+// entry block that are not otherwise addressed, so variables whose address
+// is taken, such as garbage collector roots, remain in memory.
+//
+// There is no separate Go-level IR: Go-specific optimisations, such as the
+// elimination of redundant nil checks, are run over the promoted LLVM IR,
+// where each variable's value is a register, before the module is handed
+// to LLVM's optimiser.
+
+// insertPoint is a saved position of the compiler's builder, to which it
+// is returned after code has been generated elsewhere, such as in the
+// entry block or in another function.
+//
+// The builder inserts either at the end of its block or before one of its
+// instructions, but the LLVM C API reports only the block. The position
+// is therefore marked by a placeholder instruction, which is removed when
+// the position is restored.
+type insertPoint struct {
+	block  llvm.BasicBlock
+	marker llvm.Value
+}
+
+// saveInsertPoint saves the builder's position; it is typically used as
+// defer c.restoreInsertPoint(c.saveInsertPoint()).
+func (c *compiler) saveInsertPoint() insertPoint {
+	block := c.builder.GetInsertBlock()
+	if block.IsNil() {
+		return insertPoint{}
+	}
+	marker := c.builder.CreateAlloca(c.context.Int8Type(), "")
+	c.builder.SetInsertPointBefore(marker)
+	return insertPoint{block, marker}
+}
+
+// restoreInsertPoint returns the builder to a position saved with
+// saveInsertPoint.
+func (c *compiler) restoreInsertPoint(ip insertPoint) {
+	if ip.block.IsNil() {
+		c.builder.ClearInsertionPoint()
+		return
+	}
+	if next := llvm.NextInstruction(ip.marker); next.IsNil() {
+		c.builder.SetInsertPointAtEnd(ip.block)
+	} else {
+		c.builder.SetInsertPointBefore(next)
+	}
+	ip.marker.EraseFromParentAsInstruction()
+}
+
+// createEntryAlloca allocates stack memory in the current function's entry
+// block. The memory is allocated once per call, however many times the
+// variable's declaration is executed, and may be promoted to a register.
+func (c *compiler) createEntryAlloca(typ llvm.Type, name string) llvm.Value {
+	defer c.restoreInsertPoint(c.saveInsertPoint())
+	c.setInsertPointAtEntry()
+	return c.builder.CreateAlloca(typ, name)
+}
+
+// setInsertPointAtEntry positions the builder at the start of the current
+// function's entry block.
+func (c *compiler) setInsertPointAtEntry() {
+	entry := c.builder.GetInsertBlock().Parent().EntryBasicBlock()
+	if first := entry.FirstInstruction(); first.IsNil() {
+		c.builder.SetInsertPointAtEnd(entry)
+	} else {
+		c.builder.SetInsertPointBefore(first)
+	}
+}
+
+// buildSSA converts each function defined in the module to SSA form, and
+// eliminates redundant nil checks from it.
+func (c *compiler) buildSSA() {
+	fpm := llvm.NewFunctionPassManagerForModule(c.module.Module)
+	defer fpm.Dispose()
+	fpm.AddPromoteMemoryToRegisterPass()
+	fpm.InitializeFunc()
+	cleanup := llvm.NewFunctionPassManagerForModule(c.module.Module)
+	defer cleanup.Dispose()
+	cleanup.AddCFGSimplificationPass()
+	cleanup.InitializeFunc()
+	for fn := c.module.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if !fn.IsDeclaration() {
+			fpm.RunFunc(fn)
+			if c.eliminateNilChecks(fn) {
+				cleanup.RunFunc(fn)
+			}
+		}
+	}
+	fpm.FinalizeFunc()
+	cleanup.FinalizeFunc()
+}
+
+// eliminateNilChecks removes the nil checks emitted by nilCheck whose
+// pointers are known not to be nil: those that have been checked before
+// on every path to the check, and the addresses of variables. The checks
+// are disabled by making their conditions false, leaving the panicking
+// blocks for CFG simplification to remove. It reports whether any checks
+// were removed.
+//
+// A block is known to be reached only from its predecessor if it has just
+// one, so the pointers checked on the way to it are followed along chains
+// of such blocks. Checks split the block in which they are emitted, and
+// the block in which code continues is placed after the check's block, so
+// the blocks are visited in order.
+func (c *compiler) eliminateNilChecks(fn llvm.Value) bool {
+	panicnil := c.module.NamedFunction("runtime.panicnil")
+	if panicnil.IsNil() {
+		return false
+	}
+	removed := false
+	checked := make(map[llvm.BasicBlock][]llvm.Value)
+	for block := fn.FirstBasicBlock(); !block.IsNil(); block = llvm.NextBasicBlock(block) {
+		known := checked[block]
+		term := block.LastInstruction()
+		if ptr, cont, ok := nilCheckOperand(term, panicnil); ok {
+			if isNonNil(ptr, known) {
+				term.SetOperand(0, llvm.ConstNull(c.context.Int1Type()))
+				removed = true
+			} else if hasSinglePredecessor(cont) {
+				contKnown := make([]llvm.Value, len(known), len(known)+1)
+				copy(contKnown, known)
+				checked[cont] = append(contKnown, ptr)
+			}
+			continue
+		}
+		if term.IsNil() || term.InstructionOpcode() != llvm.Br {
+			continue
+		}
+		for i := 0; i < term.OperandsCount(); i++ {
+			if op := term.Operand(i); op.IsBasicBlock() {
+				if succ := op.AsBasicBlock(); hasSinglePredecessor(succ) {
+					checked[succ] = known
+				}
+			}
+		}
+	}
+	return removed
+}
+
+// nilCheckOperand returns the pointer checked by the terminator of a block
+// if it is a branch emitted by nilCheck, along with the block in which code
+// continues if the pointer is not nil. The branch must test that the
+// pointer is equal to nil, and branch to a call to runtime.panicnil if it
+// is, so that making the condition false disables the check.
+func nilCheckOperand(term, panicnil llvm.Value) (ptr llvm.Value, cont llvm.BasicBlock, ok bool) {
+	if term.IsNil() || term.InstructionOpcode() != llvm.Br || term.OperandsCount() != 3 {
+		return
+	}
+	cond := term.Operand(0)
+	if cond.IsAInstruction().IsNil() || cond.InstructionOpcode() != llvm.ICmp {
+		return
+	}
+	if cond.IntPredicate() != llvm.IntEQ || !cond.Operand(1).IsNull() {
+		return
+	}
+	// The operands of a conditional branch are the condition, and the
+	// blocks branched to if it is false and true. Only the block branched
+	// to if the pointer is nil may panic.
+	panicBlock := term.Operand(2).AsBasicBlock()
+	cont = term.Operand(1).AsBasicBlock()
+	if cont == panicBlock || !callsFunction(panicBlock, panicnil) || callsFunction(cont, panicnil) {
+		return
+	}
+	return cond.Operand(0), cont, true
+}
+
+// callsFunction reports whether the first instruction of the block is a
+// call to the specified function.
+func callsFunction(block llvm.BasicBlock, fn llvm.Value) bool {
+	call := block.FirstInstruction()
+	return !call.IsNil() && call.InstructionOpcode() == llvm.Call && call.Operand(call.OperandsCount()-1) == fn
+}
+
+// isNonNil reports whether the pointer is known not to be nil, given the
+// pointers that have been checked.
+func isNonNil(ptr llvm.Value, checked []llvm.Value) bool {
+	if !ptr.IsAAllocaInst().IsNil() || !ptr.IsAGlobalVariable().IsNil() {
+		return true
+	}
+	for _, v := range checked {
+		if v == ptr {
+			return true
+		}
+	}
+	return false
+}
+
+// hasSinglePredecessor reports whether the block is branched to from just
+// one terminator.
+func hasSinglePredecessor(block llvm.BasicBlock) bool {
+	n := 0
+	for use := block.AsValue().FirstUse(); !use.IsNil(); use = use.NextUse() {
+		if n++; n > 1 {
+			return false
+		}
+	}
+	return n == 1
+}
+
+// vim: set ft=go :
@@ -51,7 +51,11 @@ func (c *compiler) maybeImplicitBranch(dest llvm.BasicBlock) {
 }
 
 func (c *compiler) VisitIncDecStmt(stmt *ast.IncDecStmt) {
-	ptr := c.VisitExpr(stmt.X).(*LLVMValue).pointer
+	lhs := c.mapIndexElement(stmt.X)
+	if lhs == nil {
+		lhs = c.VisitExpr(stmt.X).(*LLVMValue)
+	}
+	ptr := lhs.pointer
 	value := c.builder.CreateLoad(ptr.LLVMValue(), "")
 	one := llvm.ConstInt(value.Type(), 1, false)
 
@@ -77,20 +81,14 @@ func (c *compiler) VisitBlockStmt(stmt *ast.BlockStmt, createNewBlock bool) {
 	var doneBlock llvm.BasicBlock
 	if createNewBlock {
 		currBlock := c.builder.GetInsertBlock()
-		doneBlock = llvm.InsertBasicBlock(currBlock, "")
+		doneBlock = c.context.InsertBasicBlock(currBlock, "")
 		doneBlock.MoveAfter(currBlock)
-		newBlock := llvm.InsertBasicBlock(doneBlock, "")
+		newBlock := c.context.InsertBasicBlock(doneBlock, "")
 		c.builder.CreateBr(newBlock)
 		c.builder.SetInsertPointAtEnd(newBlock)
 	}
 
-	for _, stmt := range stmt.List {
-		c.VisitStmt(stmt)
-		if _, ok := stmt.(*ast.BranchStmt); ok {
-			// Ignore anything after a branch statement.
-			break
-		}
-	}
+	c.visitStmtList(stmt.List)
 
 	if createNewBlock {
 		c.maybeImplicitBranch(doneBlock)
@@ -102,18 +100,13 @@ func (c *compiler) VisitReturnStmt(stmt *ast.ReturnStmt) {
 	f := c.functions[len(c.functions)-1]
 	ftyp := f.Type().(*types.Func)
 	if len(ftyp.Results) == 0 {
+		c.runDefers()
 		c.builder.CreateRetVoid()
 		return
 	}
 
 	values := make([]llvm.Value, len(ftyp.Results))
-	if stmt.Results == nil {
-		// Bare return. No need to update named results, so just
-		// prepare return values.
-		for i, obj := range ftyp.Results {
-			values[i] = obj.Data.(*LLVMValue).LLVMValue()
-		}
-	} else {
+	if stmt.Results != nil {
 		results := make([]Value, len(ftyp.Results))
 		if len(stmt.Results) == 1 && len(ftyp.Results) > 1 {
 			aggresult := c.VisitExpr(stmt.Results[0])
@@ -130,17 +123,30 @@ func (c *compiler) VisitReturnStmt(stmt *ast.ReturnStmt) {
 				results[i] = c.VisitExpr(expr)
 			}
 		}
+
+		// Every result is loaded before any named result is updated,
+		// as the results may refer to them, as in "return b, a".
 		for i, result := range results {
 			resultobj := ftyp.Results[i]
-			value := result.Convert(resultobj.Type.(types.Type))
-			values[i] = value.LLVMValue()
-			if resultobj.Name != "_" && resultobj.Name != "" {
+			values[i] = result.Convert(resultobj.Type.(types.Type)).LLVMValue()
+		}
+		for i, resultobj := range ftyp.Results {
+			if resultobj.Name != "" {
 				resultptr := resultobj.Data.(*LLVMValue).pointer.LLVMValue()
-				c.builder.CreateStore(value.LLVMValue(), resultptr)
+				c.builder.CreateStore(values[i], resultptr)
 			}
 		}
 	}
 
+	// Deferred calls may modify the named results, so they are loaded
+	// after the calls are made.
+	c.runDefers()
+	for i, resultobj := range ftyp.Results {
+		if resultobj.Name != "" {
+			values[i] = resultobj.Data.(*LLVMValue).LLVMValue()
+		}
+	}
+
 	if len(values) == 1 {
 		c.builder.CreateRet(values[0])
 	} else {
@@ -168,105 +174,210 @@ func nonAssignmentToken(t token.Token) token.Token {
 	return token.ILLEGAL
 }
 
+// destructureExpr evaluates the right-hand side of a multi-value
+// assignment or declaration, returning the resultant values.
+func (c *compiler) destructureExpr(x ast.Expr) []Value {
+	var values []Value
+	switch x := x.(type) {
+	case *ast.IndexExpr:
+		// value, ok := m[k]
+		m := c.VisitExpr(x.X).(*LLVMValue)
+		index := c.VisitExpr(x.Index)
+		value, notnull := c.mapLookup(m, index, false)
+		values = []Value{value, notnull}
+	case *ast.CallExpr:
+		value := c.VisitExpr(x)
+		aggregate := value.LLVMValue()
+		struct_type := value.Type().(*types.Struct)
+		values = make([]Value, len(struct_type.Fields))
+		for i, f := range struct_type.Fields {
+			t := c.ObjGetType(f)
+			value_ := c.builder.CreateExtractValue(aggregate, i, "")
+			values[i] = c.NewLLVMValue(value_, t)
+		}
+	case *ast.TypeAssertExpr:
+		// value, ok := x.(T)
+		lhs := c.VisitExpr(x.X).(*LLVMValue)
+		typ := c.GetType(x.Type)
+		value, ok := lhs.typeAssert(typ)
+		values = []Value{value, ok}
+	case *ast.UnaryExpr:
+		// value, ok := <-ch
+		ch := c.VisitExpr(x.X).(*LLVMValue)
+		value, ok := c.chanRecv(ch)
+		values = []Value{value, ok}
+	}
+	return values
+}
+
+// isBlank reports whether the expression is the blank identifier. Values
+// assigned to the blank identifier are evaluated, but never stored.
+func isBlank(x ast.Expr) bool {
+	if paren, ok := x.(*ast.ParenExpr); ok {
+		return isBlank(paren.X)
+	}
+	ident, ok := x.(*ast.Ident)
+	return ok && ident.Name == "_"
+}
+
 func (c *compiler) VisitAssignStmt(stmt *ast.AssignStmt) {
 	// x (add_op|mul_op)= y
 	if stmt.Tok != token.DEFINE && stmt.Tok != token.ASSIGN {
-		// TODO handle assignment to map element.
 		op := nonAssignmentToken(stmt.Tok)
-		lhs := c.VisitExpr(stmt.Lhs[0])
-		rhsValue := c.VisitExpr(stmt.Rhs[0])
-		newValue := lhs.BinaryOp(op, rhsValue).(*LLVMValue).LLVMValue()
-		c.builder.CreateStore(newValue, lhs.(*LLVMValue).pointer.LLVMValue())
+		lhs := c.mapIndexElement(stmt.Lhs[0])
+		if lhs == nil {
+			lhs = c.VisitExpr(stmt.Lhs[0]).(*LLVMValue)
+		}
+		var newValue llvm.Value
+		if operands := c.stringConcatOperands(stmt.Rhs[0]); op == token.ADD && len(operands) > 1 {
+			// s += a + b + ...: concatenate all operands at once.
+			values := []Value{lhs}
+			for _, operand := range operands {
+				values = append(values, c.VisitExpr(operand))
+			}
+			newValue = c.concatenateStringValues(values, lhs.Type()).LLVMValue()
+		} else {
+			rhsValue := c.VisitExpr(stmt.Rhs[0])
+			newValue = lhs.BinaryOp(op, rhsValue).(*LLVMValue).LLVMValue()
+		}
+		c.builder.CreateStore(newValue, lhs.pointer.LLVMValue())
 		return
 	}
 
 	// a, b, ... [:]= x, y, ...
+	//
+	// The assignment proceeds in two phases. First, the operands of
+	// index expressions and pointer indirections on the left, and the
+	// expressions on the right, are evaluated in order; the values on the
+	// right are loaded, so that "a, b = b, a" swaps a and b. Then the
+	// assignments are made, from left to right. A single value is not
+	// loaded, so a struct or array can be copied directly to its target.
+	targets := make([]assignTarget, len(stmt.Lhs))
+	for i, expr := range stmt.Lhs {
+		if !isBlank(expr) {
+			targets[i] = c.assignTarget(expr, stmt)
+		}
+	}
 	values := make([]Value, len(stmt.Lhs))
 	if len(stmt.Rhs) == 1 && len(stmt.Lhs) > 1 {
-		switch x := stmt.Rhs[0].(type) {
-		case *ast.IndexExpr:
-			// value, ok := m[k]
-			m := c.VisitExpr(x.X).(*LLVMValue)
-			index := c.VisitExpr(x.Index)
-			value, notnull := c.mapLookup(m, index, false)
-			values[0] = value
-			values[1] = notnull
-		case *ast.CallExpr:
-			value := c.VisitExpr(x)
-			aggregate := value.LLVMValue()
-			struct_type := value.Type().(*types.Struct)
-			for i, f := range struct_type.Fields {
-				t := c.ObjGetType(f)
-				value_ := c.builder.CreateExtractValue(aggregate, i, "")
-				values[i] = c.NewLLVMValue(value_, t)
-			}
-		}
+		values = c.destructureExpr(stmt.Rhs[0])
 	} else {
 		for i, expr := range stmt.Rhs {
 			values[i] = c.VisitExpr(expr)
 		}
 	}
+	if len(values) > 1 {
+		for i, value := range values {
+			values[i] = c.loadValue(value)
+		}
+	}
+
 	for i, expr := range stmt.Lhs {
 		value := values[i]
-		switch x := expr.(type) {
-		case *ast.Ident:
-			if x.Name != "_" {
-				obj := x.Obj
-				if stmt.Tok == token.DEFINE {
-					value_type := value.LLVMValue().Type()
-					ptr := c.builder.CreateAlloca(value_type, x.Name)
-					c.builder.CreateStore(value.LLVMValue(), ptr)
-					llvm_value := c.NewLLVMValue(
-						ptr, &types.Pointer{Base: value.Type()})
-					obj.Data = llvm_value.makePointee()
-				} else {
-					if obj.Data == nil {
-						// FIXME this is crap, going to need to revisit
-						// how decl's are visited (should be in data
-						// dependent order.)
-						functions := c.functions
-						c.functions = nil
-						c.VisitValueSpec(obj.Decl.(*ast.ValueSpec), false)
-						c.functions = functions
-					}
-					ptr := (obj.Data).(*LLVMValue).pointer
-					value = value.Convert(types.Deref(ptr.Type()))
-					c.builder.CreateStore(value.LLVMValue(), ptr.LLVMValue())
-				}
-			}
+		if isBlank(expr) {
 			continue
-		case *ast.IndexExpr:
-			if t, ok := c.types.expr[x.X]; ok {
-				if _, ok := t.(*types.Map); ok {
-					m := c.VisitExpr(x.X).(*LLVMValue)
-					index := c.VisitExpr(x.Index)
-					elem, _ := c.mapLookup(m, index, true)
-					ptr := elem.pointer
-					value = value.Convert(types.Deref(ptr.Type()))
-					c.builder.CreateStore(value.LLVMValue(), ptr.LLVMValue())
-					continue
-				}
+		}
+		target := targets[i]
+		ptr := target.ptr
+		switch {
+		case target.m != nil:
+			elem, _ := c.mapLookup(target.m, target.key, true)
+			ptr = elem.pointer
+		case target.obj != nil:
+			obj := target.obj
+			value_type := value.LLVMValue().Type()
+			stackptr := c.allocateVar(obj, value_type)
+			c.builder.CreateStore(value.LLVMValue(), stackptr)
+			llvm_value := c.NewLLVMValue(
+				stackptr, &types.Pointer{Base: value.Type()})
+			c.debugDeclare(obj, llvm_value, 0)
+			obj.Data = llvm_value.makePointee()
+			continue
+		}
+		value = c.convertValue(value, types.Deref(ptr.Type()))
+		c.storeValue(value, ptr.LLVMValue())
+	}
+}
+
+// assignTarget is the destination of an assignment, whose operands have
+// been evaluated: a pointer to a variable or element, a map and key, or a
+// variable declared by the assignment.
+type assignTarget struct {
+	ptr *LLVMValue
+	m   *LLVMValue
+	key Value
+	obj *ast.Object
+}
+
+// assignTarget evaluates the operands of the left hand side of an
+// assignment statement.
+func (c *compiler) assignTarget(expr ast.Expr, stmt *ast.AssignStmt) assignTarget {
+	switch x := expr.(type) {
+	case *ast.Ident:
+		obj := x.Obj
+		if stmt.Tok == token.DEFINE && obj.Decl == stmt {
+			return assignTarget{obj: obj}
+		}
+		if obj.Data == nil {
+			// FIXME this is crap, going to need to revisit
+			// how decl's are visited (should be in data
+			// dependent order.)
+			functions := c.functions
+			c.functions = nil
+			c.VisitValueSpec(obj.Decl.(*ast.ValueSpec), false)
+			c.functions = functions
+		}
+		return assignTarget{ptr: obj.Data.(*LLVMValue).pointer}
+	case *ast.IndexExpr:
+		// The map element is not created until the assignment is made.
+		if t, ok := c.types.expr[x.X]; ok {
+			if _, ok := types.Underlying(t).(*types.Map); ok {
+				m := c.loadValue(c.VisitExpr(x.X)).(*LLVMValue)
+				key := c.loadValue(c.VisitExpr(x.Index))
+				return assignTarget{m: m, key: key}
 			}
 		}
-		// default (since we can't fallthrough in non-map index exprs)
-		ptr := c.VisitExpr(expr).(*LLVMValue).pointer
-		value = value.Convert(types.Deref(ptr.Type()))
-		c.builder.CreateStore(value.LLVMValue(), ptr.LLVMValue())
 	}
+	return assignTarget{ptr: c.VisitExpr(expr).(*LLVMValue).pointer}
+}
+
+// loadValue returns the value, loaded now if it is held in memory, so that
+// later stores to the memory do not affect it.
+func (c *compiler) loadValue(v Value) Value {
+	if v, ok := v.(*LLVMValue); ok && v.pointer != nil {
+		return c.NewLLVMValue(v.LLVMValue(), v.Type())
+	}
+	return v
+}
+
+// convertValue converts the value to the specified type. Unlike Convert, a
+// value of an identical type is returned as is, so a value held in memory
+// can be copied without first being loaded.
+func (c *compiler) convertValue(v Value, typ types.Type) Value {
+	if types.Identical(v.Type(), typ) {
+		return v
+	}
+	return v.Convert(typ)
+}
+
+func (c *compiler) VisitSendStmt(stmt *ast.SendStmt) {
+	ch := c.VisitExpr(stmt.Chan).(*LLVMValue)
+	value := c.VisitExpr(stmt.Value)
+	c.chanSend(ch, value)
 }
 
 func (c *compiler) VisitIfStmt(stmt *ast.IfStmt) {
 	currBlock := c.builder.GetInsertBlock()
-	resumeBlock := llvm.AddBasicBlock(currBlock.Parent(), "endif")
+	resumeBlock := c.context.AddBasicBlock(currBlock.Parent(), "endif")
 	resumeBlock.MoveAfter(currBlock)
 	defer c.builder.SetInsertPointAtEnd(resumeBlock)
 
 	var ifBlock, elseBlock llvm.BasicBlock
 	if stmt.Else != nil {
-		elseBlock = llvm.InsertBasicBlock(resumeBlock, "else")
-		ifBlock = llvm.InsertBasicBlock(elseBlock, "if")
+		elseBlock = c.context.InsertBasicBlock(resumeBlock, "else")
+		ifBlock = c.context.InsertBasicBlock(elseBlock, "if")
 	} else {
-		ifBlock = llvm.InsertBasicBlock(resumeBlock, "if")
+		ifBlock = c.context.InsertBasicBlock(resumeBlock, "if")
 	}
 	if stmt.Else == nil {
 		elseBlock = resumeBlock
@@ -291,19 +402,19 @@ func (c *compiler) VisitIfStmt(stmt *ast.IfStmt) {
 
 func (c *compiler) VisitForStmt(stmt *ast.ForStmt) {
 	currBlock := c.builder.GetInsertBlock()
-	doneBlock := llvm.AddBasicBlock(currBlock.Parent(), "done")
+	doneBlock := c.context.AddBasicBlock(currBlock.Parent(), "done")
 	doneBlock.MoveAfter(currBlock)
-	loopBlock := llvm.InsertBasicBlock(doneBlock, "loop")
+	loopBlock := c.context.InsertBasicBlock(doneBlock, "loop")
 	defer c.builder.SetInsertPointAtEnd(doneBlock)
 
 	condBlock := loopBlock
 	if stmt.Cond != nil {
-		condBlock = llvm.InsertBasicBlock(loopBlock, "cond")
+		condBlock = c.context.InsertBasicBlock(loopBlock, "cond")
 	}
 
 	postBlock := condBlock
 	if stmt.Post != nil {
-		postBlock = llvm.InsertBasicBlock(doneBlock, "post")
+		postBlock = c.context.InsertBasicBlock(doneBlock, "post")
 	}
 
 	c.breakblocks = append(c.breakblocks, doneBlock)
@@ -342,10 +453,44 @@ func (c *compiler) VisitForStmt(stmt *ast.ForStmt) {
 }
 
 func (c *compiler) VisitGoStmt(stmt *ast.GoStmt) {
-	//stmt.Call *ast.CallExpr
-	// TODO 
+	// Store the arguments in a structure on the stack, which the runtime
+	// copies for the new goroutine.
+	args_struct_type, values, indirect_fn := c.indirectCall(stmt.Call, nil)
+	var args_mem llvm.Value
+	var args_size llvm.Value
+	if len(values) > 0 {
+		args_mem = c.builder.CreateAlloca(args_struct_type, "")
+		for i, value := range values {
+			c.builder.CreateStore(value, c.builder.CreateStructGEP(args_mem, i, ""))
+		}
+		args_size = llvm.SizeOf(args_struct_type)
+		args_size = llvm.ConstTruncOrBitCast(args_size, c.target.IntPtrType())
+	} else {
+		args_mem = llvm.ConstNull(llvm.PointerType(args_struct_type, 0))
+		args_size = llvm.ConstInt(c.target.IntPtrType(), 0, false)
+	}
+
+	// Call "newgoroutine" with the indirect function and stored args.
+	newgoroutine := c.NamedFunction("runtime.newgoroutine", "func f(fn, arg unsafe.Pointer, argsize uintptr)")
+	fn_arg := c.builder.CreatePtrToInt(indirect_fn, c.target.IntPtrType(), "")
+	args_arg := c.builder.CreatePtrToInt(args_mem, c.target.IntPtrType(), "")
+	c.builder.CreateCall(newgoroutine,
+		[]llvm.Value{fn_arg, args_arg, args_size}, "")
+}
+
+// indirectCall evaluates the function and arguments of the call made by a
+// go or defer statement, and creates a function that makes the call with
+// them, taking a pointer to a structure in which they are stored. The
+// structure begins with fields of the specified types, for the caller's
+// use, followed by the arguments. If the function is not constant (e.g. a
+// closure), then the function value is stored in the structure too, after
+// the arguments.
+//
+// indirectCall returns the type of the structure, the values to store in
+// the fields following the header, and the indirect function.
+func (c *compiler) indirectCall(call *ast.CallExpr, header []llvm.Type) (llvm.Type, []llvm.Value, llvm.Value) {
 	var fn *LLVMValue
-	switch x := (stmt.Call.Fun).(type) {
+	switch x := call.Fun.(type) {
 	case *ast.Ident:
 		fn = c.Resolve(x.Obj).(*LLVMValue)
 		if fn == nil {
@@ -353,73 +498,50 @@ func (c *compiler) VisitGoStmt(stmt *ast.GoStmt) {
 				"No function found with name '%s'", x.String()))
 		}
 	default:
-		fn = c.VisitExpr(stmt.Call.Fun).(*LLVMValue)
+		fn = c.VisitExpr(call.Fun).(*LLVMValue)
 	}
 
-	// Evaluate arguments, store in a structure on the stack.
-	var args_struct_type llvm.Type
-	var args_mem llvm.Value
-	var args_size llvm.Value
-	if stmt.Call.Args != nil {
-		param_types := make([]llvm.Type, 0)
-		fn_type := types.Deref(fn.Type()).(*types.Func)
-		for _, param := range fn_type.Params {
-			typ := param.Type.(types.Type)
-			param_types = append(param_types, c.types.ToLLVM(typ))
-		}
-		args_struct_type = llvm.StructType(param_types, false)
-		args_mem = c.builder.CreateAlloca(args_struct_type, "")
-		for i, expr := range stmt.Call.Args {
-			value_i := c.VisitExpr(expr)
-			value_i = value_i.Convert(fn_type.Params[i].Type.(types.Type))
-			arg_i := c.builder.CreateGEP(args_mem, []llvm.Value{
-				llvm.ConstInt(llvm.Int32Type(), 0, false),
-				llvm.ConstInt(llvm.Int32Type(), uint64(i), false)}, "")
-			c.builder.CreateStore(value_i.LLVMValue(), arg_i)
-		}
-		args_size = llvm.SizeOf(args_struct_type)
-		args_size = llvm.ConstTrunc(args_size, llvm.Int32Type())
-	} else {
-		args_struct_type = llvm.VoidType()
-		args_mem = llvm.ConstNull(llvm.PointerType(args_struct_type, 0))
-		args_size = llvm.ConstInt(llvm.Int32Type(), 0, false)
+	fn_value := fn.LLVMValue()
+	passfn := !fn_value.IsConstant()
+	fn_type := types.Deref(fn.Type()).(*types.Func)
+	field_types := append([]llvm.Type{}, header...)
+	values := c.evalCallArgs(fn_type, call)
+	for _, value := range values {
+		field_types = append(field_types, value.Type())
+	}
+	nargs := len(values)
+	if passfn {
+		field_types = append(field_types, fn_value.Type())
+		values = append(values, fn_value)
 	}
+	struct_type := c.context.StructType(field_types, false)
 
 	// When done, return to where we were.
-	defer c.builder.SetInsertPointAtEnd(c.builder.GetInsertBlock())
+	defer c.restoreInsertPoint(c.saveInsertPoint())
 
-	// Create a function that will take a pointer to a structure of the type
-	// defined above, or no parameters if there are none to pass.
 	indirect_fn_type := llvm.FunctionType(
-		llvm.VoidType(),
-		[]llvm.Type{llvm.PointerType(args_struct_type, 0)}, false)
+		c.context.VoidType(),
+		[]llvm.Type{llvm.PointerType(struct_type, 0)}, false)
 	indirect_fn := llvm.AddFunction(c.module.Module, "", indirect_fn_type)
 	indirect_fn.SetFunctionCallConv(llvm.CCallConv)
 
-	// Call "newgoroutine" with the indirect function and stored args.
-	newgoroutine := getnewgoroutine(c.module.Module)
-	ngr_param_types := newgoroutine.Type().ElementType().ParamTypes()
-	fn_arg := c.builder.CreateBitCast(indirect_fn, ngr_param_types[0], "")
-	args_arg := c.builder.CreateBitCast(args_mem,
-		llvm.PointerType(llvm.Int8Type(), 0), "")
-	c.builder.CreateCall(newgoroutine,
-		[]llvm.Value{fn_arg, args_arg, args_size}, "")
-
-	entry := llvm.AddBasicBlock(indirect_fn, "entry")
+	// The indirect function has no debug information.
+	c.builder.SetCurrentDebugLocation(llvm.Value{})
+	entry := c.context.AddBasicBlock(indirect_fn, "entry")
 	c.builder.SetInsertPointAtEnd(entry)
-	var args []llvm.Value
-	if stmt.Call.Args != nil {
-		args_mem = indirect_fn.Param(0)
-		args = make([]llvm.Value, len(stmt.Call.Args))
-		for i := range stmt.Call.Args {
-			arg_i := c.builder.CreateGEP(args_mem, []llvm.Value{
-				llvm.ConstInt(llvm.Int32Type(), 0, false),
-				llvm.ConstInt(llvm.Int32Type(), uint64(i), false)}, "")
-			args[i] = c.builder.CreateLoad(arg_i, "")
-		}
-	}
-	c.builder.CreateCall(fn.LLVMValue(), args, "")
+	mem := indirect_fn.Param(0)
+	args := make([]llvm.Value, nargs)
+	for i := range args {
+		arg_i := c.builder.CreateStructGEP(mem, len(header)+i, "")
+		args[i] = c.builder.CreateLoad(arg_i, "")
+	}
+	if passfn {
+		fn_ptr := c.builder.CreateStructGEP(mem, len(header)+len(args), "")
+		fn_value = c.builder.CreateLoad(fn_ptr, "")
+	}
+	c.createCall(fn_value, args)
 	c.builder.CreateRetVoid()
+	return struct_type, values, indirect_fn
 }
 
 func (c *compiler) VisitSwitchStmt(stmt *ast.SwitchStmt) {
@@ -438,20 +560,10 @@ func (c *compiler) VisitSwitchStmt(stmt *ast.SwitchStmt) {
 		return
 	}
 
-	// makeValueFunc takes an expression, evaluates it, and returns
-	// a Value representing its equality comparison with the tag.
-	makeValueFunc := func(expr ast.Expr) func() Value {
-		return func() Value {
-			return c.VisitExpr(expr).BinaryOp(token.EQL, tag)
-		}
-	}
-
-	// Create a BasicBlock for each case clause and each associated
-	// statement body. Each case clause will branch to either its
-	// statement body (success) or to the next case (failure), or the
-	// end block if there are no remaining cases.
+	// Create a BasicBlock for each case clause's statement body, in
+	// order, so fallthrough can branch to the next one.
 	startBlock := c.builder.GetInsertBlock()
-	endBlock := llvm.AddBasicBlock(startBlock.Parent(), "end")
+	endBlock := c.context.AddBasicBlock(startBlock.Parent(), "end")
 	endBlock.MoveAfter(startBlock)
 	defer c.builder.SetInsertPointAtEnd(endBlock)
 
@@ -459,66 +571,136 @@ func (c *compiler) VisitSwitchStmt(stmt *ast.SwitchStmt) {
 	c.breakblocks = append(c.breakblocks, endBlock)
 	defer func() { c.breakblocks = c.breakblocks[:len(c.breakblocks)-1] }()
 
-	caseBlocks := make([]llvm.BasicBlock, 0, len(stmt.Body.List))
-	stmtBlocks := make([]llvm.BasicBlock, 0, len(stmt.Body.List))
-	for _ = range stmt.Body.List {
-		caseBlocks = append(caseBlocks, llvm.InsertBasicBlock(endBlock, ""))
-	}
-	for _ = range stmt.Body.List {
-		stmtBlocks = append(stmtBlocks, llvm.InsertBasicBlock(endBlock, ""))
-	}
-
-	c.builder.CreateBr(caseBlocks[0])
+	clauses := make([]*ast.CaseClause, len(stmt.Body.List))
+	stmtBlocks := make([]llvm.BasicBlock, len(stmt.Body.List))
+	defaultBlock := endBlock
 	for i, stmt := range stmt.Body.List {
-		c.builder.SetInsertPointAtEnd(caseBlocks[i])
-		stmtBlock := stmtBlocks[i]
-		nextBlock := endBlock
-		if i+1 < len(caseBlocks) {
-			nextBlock = caseBlocks[i+1]
-		}
-
-		clause := stmt.(*ast.CaseClause)
-		if clause.List != nil {
-			value := c.VisitExpr(clause.List[0])
-			result := value.BinaryOp(token.EQL, tag)
-			for _, expr := range clause.List[1:] {
-				rhsResultFunc := makeValueFunc(expr)
-				result = c.compileLogicalOp(token.LOR, result, rhsResultFunc)
-			}
-			c.builder.CreateCondBr(result.LLVMValue(), stmtBlock, nextBlock)
-		} else {
-			// default case
-			c.builder.CreateBr(stmtBlock)
+		clauses[i] = stmt.(*ast.CaseClause)
+		stmtBlocks[i] = c.context.InsertBasicBlock(endBlock, "")
+		if clauses[i].List == nil {
+			defaultBlock = stmtBlocks[i]
 		}
+	}
+
+	if isInteger(tag.Type()) && constCases(clauses) {
+		c.switchInstruction(tag, clauses, stmtBlocks, defaultBlock)
+	} else {
+		c.switchComparisons(tag, clauses, stmtBlocks, defaultBlock)
+	}
 
-		c.builder.SetInsertPointAtEnd(stmtBlock)
+	for i, clause := range clauses {
+		c.builder.SetInsertPointAtEnd(stmtBlocks[i])
 		branchBlock := endBlock
-		for _, stmt := range clause.Body {
-			if br, isbr := stmt.(*ast.BranchStmt); isbr {
-				if br.Tok == token.FALLTHROUGH {
-					if i+1 < len(stmtBlocks) {
-						branchBlock = stmtBlocks[i+1]
-					}
-				} else {
-					c.VisitStmt(stmt)
+		body := clause.Body
+		if n := len(body); n > 0 {
+			// fallthrough may only be the last statement of a case.
+			if br, isbr := body[n-1].(*ast.BranchStmt); isbr && br.Tok == token.FALLTHROUGH {
+				if i+1 < len(stmtBlocks) {
+					branchBlock = stmtBlocks[i+1]
 				}
-				// Ignore anything after a branch statement.
-				break
-			} else {
-				c.VisitStmt(stmt)
+				body = body[:n-1]
 			}
 		}
+		c.visitStmtList(body)
 		c.maybeImplicitBranch(branchBlock)
 	}
 }
 
+// constCases reports whether the expressions of all case clauses are
+// constants, in which case they may be evaluated in any order.
+func constCases(clauses []*ast.CaseClause) bool {
+	for _, clause := range clauses {
+		for _, expr := range clause.List {
+			if !isConstExpr(expr) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isConstExpr reports whether the expression is built from literals and
+// named constants only.
+func isConstExpr(expr ast.Expr) bool {
+	switch x := expr.(type) {
+	case *ast.BasicLit:
+		return true
+	case *ast.Ident:
+		return x.Obj != nil && x.Obj.Kind == ast.Con
+	case *ast.ParenExpr:
+		return isConstExpr(x.X)
+	case *ast.UnaryExpr:
+		return x.Op != token.ARROW && x.Op != token.AND && isConstExpr(x.X)
+	case *ast.BinaryExpr:
+		return isConstExpr(x.X) && isConstExpr(x.Y)
+	}
+	return false
+}
+
+// switchInstruction lowers an expression switch on an integer tag, whose
+// cases are all constant, to an LLVM switch instruction. The first of any
+// duplicate case values is used.
+func (c *compiler) switchInstruction(tag Value, clauses []*ast.CaseClause, stmtBlocks []llvm.BasicBlock, defaultBlock llvm.BasicBlock) {
+	var ncases int
+	for _, clause := range clauses {
+		ncases += len(clause.List)
+	}
+	sw := c.builder.CreateSwitch(tag.LLVMValue(), defaultBlock, ncases)
+	seen := make(map[uint64]bool)
+	for i, clause := range clauses {
+		for _, expr := range clause.List {
+			value := c.VisitExpr(expr).Convert(tag.Type()).LLVMValue()
+			if v := value.ZExtValue(); !seen[v] {
+				seen[v] = true
+				sw.AddCase(value, stmtBlocks[i])
+			}
+		}
+	}
+}
+
+// switchComparisons lowers an expression switch to a chain of comparisons
+// of the tag with each case expression in turn, evaluating the case
+// expressions lazily, in order. The default case is taken if no other
+// case matches, wherever it appears.
+func (c *compiler) switchComparisons(tag Value, clauses []*ast.CaseClause, stmtBlocks []llvm.BasicBlock, defaultBlock llvm.BasicBlock) {
+	// makeValueFunc takes an expression, evaluates it, and returns
+	// a Value representing its equality comparison with the tag.
+	makeValueFunc := func(expr ast.Expr) func() Value {
+		return func() Value {
+			return c.VisitExpr(expr).BinaryOp(token.EQL, tag)
+		}
+	}
+
+	// Each case block branches to its statement body if the case
+	// matches, and to the next case block otherwise; the last branches
+	// to the default case, if any.
+	caseBlock := c.builder.GetInsertBlock()
+	for i, clause := range clauses {
+		if clause.List == nil {
+			continue
+		}
+		nextBlock := c.context.InsertBasicBlock(stmtBlocks[0], "")
+		c.builder.SetInsertPointAtEnd(caseBlock)
+		value := c.VisitExpr(clause.List[0])
+		result := value.BinaryOp(token.EQL, tag)
+		for _, expr := range clause.List[1:] {
+			rhsResultFunc := makeValueFunc(expr)
+			result = c.compileLogicalOp(token.LOR, result, rhsResultFunc)
+		}
+		c.builder.CreateCondBr(result.LLVMValue(), stmtBlocks[i], nextBlock)
+		caseBlock = nextBlock
+	}
+	c.builder.SetInsertPointAtEnd(caseBlock)
+	c.builder.CreateBr(defaultBlock)
+}
+
 func (c *compiler) VisitRangeStmt(stmt *ast.RangeStmt) {
 	currBlock := c.builder.GetInsertBlock()
-	doneBlock := llvm.AddBasicBlock(currBlock.Parent(), "done")
+	doneBlock := c.context.AddBasicBlock(currBlock.Parent(), "done")
 	doneBlock.MoveAfter(currBlock)
-	postBlock := llvm.InsertBasicBlock(doneBlock, "post")
-	loopBlock := llvm.InsertBasicBlock(postBlock, "loop")
-	condBlock := llvm.InsertBasicBlock(loopBlock, "cond")
+	postBlock := c.context.InsertBasicBlock(doneBlock, "post")
+	loopBlock := c.context.InsertBasicBlock(postBlock, "loop")
+	condBlock := c.context.InsertBasicBlock(loopBlock, "cond")
 	defer c.builder.SetInsertPointAtEnd(doneBlock)
 
 	// Evaluate range expression first.
@@ -527,7 +709,7 @@ func (c *compiler) VisitRangeStmt(stmt *ast.RangeStmt) {
 	// If it's a pointer type, we'll first check that it's non-nil.
 	typ := types.Underlying(x.Type())
 	if _, ok := typ.(*types.Pointer); ok {
-		ifBlock := llvm.InsertBasicBlock(doneBlock, "if")
+		ifBlock := c.context.InsertBasicBlock(doneBlock, "if")
 		isnotnull := c.builder.CreateIsNotNull(x.LLVMValue(), "")
 		c.builder.CreateCondBr(isnotnull, ifBlock, doneBlock)
 		c.builder.SetInsertPointAtEnd(ifBlock)
@@ -539,16 +721,29 @@ func (c *compiler) VisitRangeStmt(stmt *ast.RangeStmt) {
 	if stmt.Tok == token.DEFINE {
 		if key := stmt.Key.(*ast.Ident); key.Name != "_" {
 			keyType = key.Obj.Type.(types.Type)
-			keyPtr = c.builder.CreateAlloca(c.types.ToLLVM(keyType), "")
-			key.Obj.Data = c.NewLLVMValue(keyPtr, &types.Pointer{Base: keyType}).makePointee()
+			keyPtr = c.allocateVar(key.Obj, c.types.ToLLVM(keyType))
+			keyPtrValue := c.NewLLVMValue(keyPtr, &types.Pointer{Base: keyType})
+			c.debugDeclare(key.Obj, keyPtrValue, 0)
+			key.Obj.Data = keyPtrValue.makePointee()
 		}
 		if stmt.Value != nil {
 			if value := stmt.Value.(*ast.Ident); value.Name != "_" {
 				valueType = value.Obj.Type.(types.Type)
-				valuePtr = c.builder.CreateAlloca(c.types.ToLLVM(valueType), "")
-				value.Obj.Data = c.NewLLVMValue(valuePtr, &types.Pointer{Base: valueType}).makePointee()
+				valuePtr = c.allocateVar(value.Obj, c.types.ToLLVM(valueType))
+				valuePtrValue := c.NewLLVMValue(valuePtr, &types.Pointer{Base: valueType})
+				c.debugDeclare(value.Obj, valuePtrValue, 0)
+				value.Obj.Data = valuePtrValue.makePointee()
 			}
 		}
+	} else {
+		// Assign to the existing variables, discarding the key or value
+		// if it is assigned to the blank identifier.
+		if !isBlank(stmt.Key) {
+			keyPtr = c.VisitExpr(stmt.Key).(*LLVMValue).pointer.LLVMValue()
+		}
+		if stmt.Value != nil && !isBlank(stmt.Value) {
+			valuePtr = c.VisitExpr(stmt.Value).(*LLVMValue).pointer.LLVMValue()
+		}
 	}
 
 	c.breakblocks = append(c.breakblocks, doneBlock)
@@ -567,6 +762,8 @@ func (c *compiler) VisitRangeStmt(stmt *ast.RangeStmt) {
 	switch typ := types.Underlying(typ).(type) {
 	case *types.Map:
 		goto maprange
+	case *types.Chan:
+		goto chanrange
 	case *types.Name:
 		goto stringrange
 	case *types.Array:
@@ -580,7 +777,7 @@ func (c *compiler) VisitRangeStmt(stmt *ast.RangeStmt) {
 			}
 		}
 		base = x.LLVMValue()
-		length = llvm.ConstInt(llvm.Int32Type(), typ.Len, false)
+		length = llvm.ConstInt(c.target.IntPtrType(), typ.Len, false)
 		goto arrayrange
 	case *types.Slice:
 		slicevalue := x.LLVMValue()
@@ -591,12 +788,12 @@ func (c *compiler) VisitRangeStmt(stmt *ast.RangeStmt) {
 
 maprange:
 	{
-		currBlock = c.builder.GetInsertBlock()
+		m := x.(*LLVMValue)
+		it := c.mapIterInit(m)
 		c.builder.CreateBr(condBlock)
 		c.builder.SetInsertPointAtEnd(condBlock)
-		nextptrphi := c.builder.CreatePHI(c.target.IntPtrType(), "next")
-		nextptr, pk, pv := c.mapNext(x.(*LLVMValue), nextptrphi)
-		notnull := c.builder.CreateIsNotNull(nextptr, "")
+		pk, pv := c.mapIterEntry(m, it)
+		notnull := c.builder.CreateIsNotNull(pk, "")
 		c.builder.CreateCondBr(notnull, loopBlock, doneBlock)
 		c.builder.SetInsertPointAtEnd(loopBlock)
 		if !keyPtr.IsNil() {
@@ -610,21 +807,66 @@ maprange:
 		c.VisitBlockStmt(stmt.Body, false)
 		c.maybeImplicitBranch(postBlock)
 		c.builder.SetInsertPointAtEnd(postBlock)
+		c.mapIterNext(it)
+		c.builder.CreateBr(condBlock)
+		return
+	}
+
+chanrange:
+	{
+		c.builder.CreateBr(condBlock)
+		c.builder.SetInsertPointAtEnd(condBlock)
+		elem, ok := c.chanRecv(x.(*LLVMValue))
+		c.builder.CreateCondBr(ok.LLVMValue(), loopBlock, doneBlock)
+		c.builder.SetInsertPointAtEnd(loopBlock)
+		if !keyPtr.IsNil() {
+			c.builder.CreateStore(elem.LLVMValue(), keyPtr)
+		}
+		c.VisitBlockStmt(stmt.Body, false)
+		c.maybeImplicitBranch(postBlock)
+		c.builder.SetInsertPointAtEnd(postBlock)
 		c.builder.CreateBr(condBlock)
-		nextptrphi.AddIncoming([]llvm.Value{llvm.ConstNull(c.target.IntPtrType()), nextptr}, []llvm.BasicBlock{currBlock, postBlock})
 		return
 	}
 
 stringrange:
-	panic("string range unimplemented")
+	{
+		strnext := c.NamedFunction("runtime.strnext", "func f(s _string, i int) (int, int32)")
+		_string := strnext.Type().ElementType().ParamTypes()[0]
+		s := c.coerceString(x.LLVMValue(), _string)
+		length := c.builder.CreateExtractValue(s, 1, "")
+		zero := llvm.ConstNull(c.target.IntPtrType())
+		currBlock = c.builder.GetInsertBlock()
+		c.builder.CreateBr(condBlock)
+		c.builder.SetInsertPointAtEnd(condBlock)
+		index := c.builder.CreatePHI(c.target.IntPtrType(), "index")
+		lessthan := c.builder.CreateICmp(llvm.IntULT, index, length, "")
+		c.builder.CreateCondBr(lessthan, loopBlock, doneBlock)
+		c.builder.SetInsertPointAtEnd(loopBlock)
+		result := c.builder.CreateCall(strnext, []llvm.Value{s, index}, "")
+		newindex := c.builder.CreateExtractValue(result, 0, "")
+		if !keyPtr.IsNil() {
+			c.builder.CreateStore(index, keyPtr)
+		}
+		if !valuePtr.IsNil() {
+			value := c.builder.CreateExtractValue(result, 1, "")
+			c.builder.CreateStore(value, valuePtr)
+		}
+		c.VisitBlockStmt(stmt.Body, false)
+		c.maybeImplicitBranch(postBlock)
+		c.builder.SetInsertPointAtEnd(postBlock)
+		c.builder.CreateBr(condBlock)
+		index.AddIncoming([]llvm.Value{zero, newindex}, []llvm.BasicBlock{currBlock, postBlock})
+		return
+	}
 
 arrayrange:
 	{
-		zero := llvm.ConstNull(llvm.Int32Type())
+		zero := llvm.ConstNull(c.target.IntPtrType())
 		currBlock = c.builder.GetInsertBlock()
 		c.builder.CreateBr(condBlock)
 		c.builder.SetInsertPointAtEnd(condBlock)
-		index := c.builder.CreatePHI(llvm.Int32Type(), "index")
+		index := c.builder.CreatePHI(c.target.IntPtrType(), "index")
 		lessthan := c.builder.CreateICmp(llvm.IntULT, index, length, "")
 		c.builder.CreateCondBr(lessthan, loopBlock, doneBlock)
 		c.builder.SetInsertPointAtEnd(loopBlock)
@@ -645,29 +887,68 @@ arrayrange:
 		c.VisitBlockStmt(stmt.Body, false)
 		c.maybeImplicitBranch(postBlock)
 		c.builder.SetInsertPointAtEnd(postBlock)
-		newindex := c.builder.CreateAdd(index, llvm.ConstInt(llvm.Int32Type(), 1, false), "")
+		newindex := c.builder.CreateAdd(index, llvm.ConstInt(c.target.IntPtrType(), 1, false), "")
 		c.builder.CreateBr(condBlock)
 		index.AddIncoming([]llvm.Value{zero, newindex}, []llvm.BasicBlock{currBlock, postBlock})
 	}
 }
 
+// visitStmtList compiles a list of statements. Statements following a
+// branch statement or a call to panic are unreachable, and are skipped up
+// to the next labeled statement, which may be the target of a goto.
+func (c *compiler) visitStmtList(list []ast.Stmt) {
+	unreachable := false
+	for _, stmt := range list {
+		if _, ok := stmt.(*ast.LabeledStmt); ok {
+			unreachable = false
+		}
+		if !unreachable {
+			c.VisitStmt(stmt)
+			_, unreachable = stmt.(*ast.BranchStmt)
+			unreachable = unreachable || isPanicStmt(stmt)
+		}
+	}
+}
+
+// labelInfo is an entry in the current function's label table. The
+// depths of the break and continue block stacks at the labeled statement
+// locate the statement's own break and continue blocks, for labeled break
+// and continue statements within it.
+type labelInfo struct {
+	block         llvm.BasicBlock
+	breakDepth    int
+	continueDepth int
+}
+
+// lookupLabel returns the named label's entry in the current function's
+// label table, adding it if the label has not been seen yet; goto
+// statements may refer to labels defined later in the function.
+func (c *compiler) lookupLabel(name string) *labelInfo {
+	label, ok := c.labels[name]
+	if !ok {
+		f := c.builder.GetInsertBlock().Parent()
+		label = &labelInfo{block: c.context.AddBasicBlock(f, name)}
+		c.labels[name] = label
+	}
+	return label
+}
+
 func (c *compiler) VisitBranchStmt(stmt *ast.BranchStmt) {
-	// TODO handle labeled continue, break.
 	switch stmt.Tok {
 	case token.BREAK:
 		block := c.breakblocks[len(c.breakblocks)-1]
+		if stmt.Label != nil {
+			block = c.breakblocks[c.lookupLabel(stmt.Label.Name).breakDepth]
+		}
 		c.builder.CreateBr(block)
 	case token.CONTINUE:
 		block := c.continueblocks[len(c.continueblocks)-1]
-		c.builder.CreateBr(block)
-	case token.GOTO:
-		block, _ := stmt.Label.Obj.Data.(llvm.BasicBlock)
-		if block.IsNil() {
-			f := c.builder.GetInsertBlock().Parent()
-			block = llvm.AddBasicBlock(f, stmt.Label.Name)
-			stmt.Label.Obj.Data = block
+		if stmt.Label != nil {
+			block = c.continueblocks[c.lookupLabel(stmt.Label.Name).continueDepth]
 		}
 		c.builder.CreateBr(block)
+	case token.GOTO:
+		c.builder.CreateBr(c.lookupLabel(stmt.Label.Name).block)
 	default:
 		// TODO implement goto, fallthrough
 		panic("unimplemented: " + stmt.Tok.String())
@@ -695,7 +976,7 @@ func (c *compiler) VisitTypeSwitchStmt(stmt *ast.TypeSwitchStmt) {
 	}
 
 	currBlock := c.builder.GetInsertBlock()
-	endBlock := llvm.AddBasicBlock(currBlock.Parent(), "")
+	endBlock := c.context.AddBasicBlock(currBlock.Parent(), "")
 	endBlock.MoveAfter(currBlock)
 	defer c.builder.SetInsertPointAtEnd(endBlock)
 
@@ -713,10 +994,10 @@ func (c *compiler) VisitTypeSwitchStmt(stmt *ast.TypeSwitchStmt) {
 	for _, stmt := range stmt.Body.List {
 		caseClause := stmt.(*ast.CaseClause)
 		if caseClause.List == nil {
-			defaultBlock = llvm.InsertBasicBlock(endBlock, "")
+			defaultBlock = c.context.InsertBasicBlock(endBlock, "")
 		} else {
-			condBlock := llvm.InsertBasicBlock(endBlock, "")
-			stmtBlock := llvm.InsertBasicBlock(endBlock, "")
+			condBlock := c.context.InsertBasicBlock(endBlock, "")
+			stmtBlock := c.context.InsertBasicBlock(endBlock, "")
 			condBlocks = append(condBlocks, condBlock)
 			stmtBlocks = append(stmtBlocks, stmtBlock)
 		}
@@ -725,8 +1006,7 @@ func (c *compiler) VisitTypeSwitchStmt(stmt *ast.TypeSwitchStmt) {
 
 	// Evaluate the expression, then jump to the first condition block.
 	iface := c.VisitExpr(typeAssertExpr.X).(*LLVMValue)
-	typptr := c.builder.CreateExtractValue(iface.LLVMValue(), 1, "")
-	typptr = c.builder.CreatePtrToInt(typptr, c.target.IntPtrType(), "")
+	typptr := iface.interfaceDynamicType()
 	if len(stmt.Body.List) == 1 && defaultBlock != endBlock {
 		c.builder.CreateBr(defaultBlock)
 	} else {
@@ -784,23 +1064,19 @@ func (c *compiler) VisitTypeSwitchStmt(stmt *ast.TypeSwitchStmt) {
 				assignIdent.Obj.Data = iface
 			}
 		}
-		for _, stmt := range caseClause.Body {
-			c.VisitStmt(stmt)
-		}
+		c.visitStmtList(caseClause.Body)
 		c.maybeImplicitBranch(endBlock)
 	}
 }
 
 func (c *compiler) VisitLabeledStmt(stmt *ast.LabeledStmt) {
 	currBlock := c.builder.GetInsertBlock()
-	labeledBlock, _ := stmt.Label.Obj.Data.(llvm.BasicBlock)
-	if labeledBlock.IsNil() {
-		labeledBlock = llvm.AddBasicBlock(currBlock.Parent(), stmt.Label.Name)
-		stmt.Label.Obj.Data = labeledBlock
-	}
-	labeledBlock.MoveAfter(currBlock)
-	c.builder.CreateBr(labeledBlock)
-	c.builder.SetInsertPointAtEnd(labeledBlock)
+	label := c.lookupLabel(stmt.Label.Name)
+	label.breakDepth = len(c.breakblocks)
+	label.continueDepth = len(c.continueblocks)
+	label.block.MoveAfter(currBlock)
+	c.maybeImplicitBranch(label.block)
+	c.builder.SetInsertPointAtEnd(label.block)
 	c.VisitStmt(stmt.Stmt)
 }
 
@@ -809,6 +1085,8 @@ func (c *compiler) VisitStmt(stmt ast.Stmt) {
 		c.logger.Println("Compile statement:", reflect.TypeOf(stmt),
 			"@", c.fileset.Position(stmt.Pos()))
 	}
+	defer c.positionPanic(stmt.Pos())
+	c.setDebugLine(stmt.Pos())
 	switch x := stmt.(type) {
 	case *ast.ReturnStmt:
 		c.VisitReturnStmt(x)
@@ -828,6 +1106,8 @@ func (c *compiler) VisitStmt(stmt ast.Stmt) {
 		c.VisitDecl(x.Decl)
 	case *ast.GoStmt:
 		c.VisitGoStmt(x)
+	case *ast.DeferStmt:
+		c.VisitDeferStmt(x)
 	case *ast.SwitchStmt:
 		c.VisitSwitchStmt(x)
 	case *ast.RangeStmt:
@@ -838,8 +1118,12 @@ func (c *compiler) VisitStmt(stmt ast.Stmt) {
 		c.VisitTypeSwitchStmt(x)
 	case *ast.LabeledStmt:
 		c.VisitLabeledStmt(x)
+	case *ast.SendStmt:
+		c.VisitSendStmt(x)
+	case *ast.EmptyStmt:
+		// nothing to do
 	default:
-		panic(fmt.Sprintf("Unhandled Stmt node: %s", reflect.TypeOf(stmt)))
+		c.fatalf(stmt.Pos(), "unhandled statement: %s", reflect.TypeOf(stmt))
 	}
 }
 
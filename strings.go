@@ -25,6 +25,7 @@ package llgo
 import (
 	"github.com/axw/gollvm/llvm"
 	"github.com/axw/llgo/types"
+	"go/ast"
 	"go/token"
 )
 
@@ -48,6 +49,66 @@ func (c *compiler) concatenateStrings(lhs, rhs *LLVMValue) *LLVMValue {
 	return c.NewLLVMValue(result, types.String)
 }
 
+// stringConcatOperands returns the operands of a chain of string
+// concatenations, such as "a + b + c", in evaluation order.
+func (c *compiler) stringConcatOperands(expr ast.Expr) []ast.Expr {
+	switch x := expr.(type) {
+	case *ast.ParenExpr:
+		return c.stringConcatOperands(x.X)
+	case *ast.BinaryExpr:
+		typ := c.types.expr[x]
+		if x.Op == token.ADD && typ != nil && basicKind(typ) == types.StringKind {
+			lhs := c.stringConcatOperands(x.X)
+			return append(lhs, c.stringConcatOperands(x.Y)...)
+		}
+	}
+	return []ast.Expr{expr}
+}
+
+// concatenateStringValues concatenates string values of the given type.
+// Adjacent constants are folded, and three or more operands are
+// concatenated by runtime.concatstrings, which allocates the result once
+// rather than once per operand.
+func (c *compiler) concatenateStringValues(values []Value, typ types.Type) Value {
+	var operands []Value
+	for _, v := range values {
+		if n := len(operands); n > 0 {
+			lhs, lhsconst := operands[n-1].(ConstValue)
+			rhs, rhsconst := v.(ConstValue)
+			if lhsconst && rhsconst {
+				operands[n-1] = lhs.BinaryOp(token.ADD, rhs)
+				continue
+			}
+		}
+		operands = append(operands, v)
+	}
+	switch len(operands) {
+	case 1:
+		return operands[0]
+	case 2:
+		return operands[0].BinaryOp(token.ADD, operands[1])
+	}
+
+	concatstrings := c.NamedFunction("runtime.concatstrings", "func f(a []_string) _string")
+	fntyp := concatstrings.Type().ElementType()
+	slicetyp := fntyp.ParamTypes()[0]
+	_string := fntyp.ReturnType()
+	n := llvm.ConstInt(c.target.IntPtrType(), uint64(len(operands)), false)
+	array := c.createEntryAlloca(llvm.ArrayType(_string, len(operands)), "")
+	for i, v := range operands {
+		str := c.coerceString(v.Convert(typ).LLVMValue(), _string)
+		c.builder.CreateStore(str, c.builder.CreateStructGEP(array, i, ""))
+	}
+	ptr := c.builder.CreateBitCast(array, slicetyp.StructElementTypes()[0], "")
+	slice := llvm.Undef(slicetyp)
+	slice = c.builder.CreateInsertValue(slice, ptr, 0, "")
+	slice = c.builder.CreateInsertValue(slice, n, 1, "")
+	slice = c.builder.CreateInsertValue(slice, n, 2, "")
+	result := c.builder.CreateCall(concatstrings, []llvm.Value{slice}, "")
+	result = c.coerceString(result, c.types.ToLLVM(typ))
+	return c.NewLLVMValue(result, typ)
+}
+
 func (c *compiler) compareStrings(lhs, rhs *LLVMValue, op token.Token) *LLVMValue {
 	strcmp := c.NamedFunction("runtime.strcmp", "func f(a, b _string) int32")
 	_string := strcmp.Type().ElementType().ParamTypes()[0]
@@ -55,7 +116,7 @@ func (c *compiler) compareStrings(lhs, rhs *LLVMValue, op token.Token) *LLVMValu
 	rhsstr := c.coerceString(rhs.LLVMValue(), _string)
 	args := []llvm.Value{lhsstr, rhsstr}
 	result := c.builder.CreateCall(strcmp, args, "")
-	zero := llvm.ConstNull(llvm.Int32Type())
+	zero := llvm.ConstNull(c.context.Int32Type())
 	var pred llvm.IntPredicate
 	switch op {
 	case token.EQL:
@@ -77,4 +138,50 @@ func (c *compiler) compareStrings(lhs, rhs *LLVMValue, op token.Token) *LLVMValu
 	return c.NewLLVMValue(result, types.Bool)
 }
 
+// stringToSlice converts a string to a []byte or []rune, copying the
+// string's contents into a newly allocated slice.
+func (c *compiler) stringToSlice(v *LLVMValue, typ types.Type) *LLVMValue {
+	var fn llvm.Value
+	if isSliceOf(typ, types.Int32Kind) {
+		fn = c.NamedFunction("runtime.strtorunes", "func f(s _string) slice")
+	} else {
+		fn = c.NamedFunction("runtime.strtobytes", "func f(s _string) slice")
+	}
+	_string := fn.Type().ElementType().ParamTypes()[0]
+	s := c.coerceString(v.LLVMValue(), _string)
+	result := c.builder.CreateCall(fn, []llvm.Value{s}, "")
+	return c.NewLLVMValue(c.coerceSlice(result, c.types.ToLLVM(typ)), typ)
+}
+
+// sliceToString converts a []byte or []rune to a string, copying the
+// slice's contents into a newly allocated string.
+func (c *compiler) sliceToString(v *LLVMValue, typ types.Type) *LLVMValue {
+	var fn llvm.Value
+	if isSliceOf(v.Type(), types.Int32Kind) {
+		fn = c.NamedFunction("runtime.runestostr", "func f(s slice) _string")
+	} else {
+		fn = c.NamedFunction("runtime.bytestostr", "func f(s slice) _string")
+	}
+	sliceType := fn.Type().ElementType().ParamTypes()[0]
+	s := c.coerceSlice(v.LLVMValue(), sliceType)
+	result := c.builder.CreateCall(fn, []llvm.Value{s}, "")
+	return c.NewLLVMValue(c.coerceString(result, c.types.ToLLVM(typ)), typ)
+}
+
+// intToString converts an integer to a string containing the UTF-8
+// encoding of the integer's value as a rune.
+func (c *compiler) intToString(v *LLVMValue, typ types.Type) *LLVMValue {
+	intstr := c.NamedFunction("runtime.intstr", "func f(v int64) _string")
+	value := v.LLVMValue()
+	if value.Type().IntTypeWidth() < 64 {
+		if isUnsigned(v.Type()) {
+			value = c.builder.CreateZExt(value, c.context.Int64Type(), "")
+		} else {
+			value = c.builder.CreateSExt(value, c.context.Int64Type(), "")
+		}
+	}
+	result := c.builder.CreateCall(intstr, []llvm.Value{value}, "")
+	return c.NewLLVMValue(c.coerceString(result, c.types.ToLLVM(typ)), typ)
+}
+
 // vim: set ft=go:
@@ -0,0 +1,141 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package llgo
+
+import (
+	"github.com/axw/gollvm/llvm"
+	"github.com/axw/llgo/types"
+	"go/token"
+	"strconv"
+)
+
+// funcSym records the Go name and source position of a function built by
+// buildFunction, for the module's function table.
+type funcSym struct {
+	name     string
+	pos      token.Pos
+	closures int // number of function literals within the function
+}
+
+// recordFunction records the name and position of a function about to be
+// built. Function literals are anonymous in the module, and are named
+// after the outermost enclosing function, as gc does; so are init
+// functions, after their package.
+func (c *compiler) recordFunction(fn llvm.Value, pos token.Pos) {
+	name := fn.Name()
+	if name == "" {
+		if len(c.functions) == 0 {
+			name = c.module.Name + ".init"
+		} else {
+			outer := c.funcsyms[c.functions[0].LLVMValue()]
+			outer.closures++
+			name = outer.name + ".func" + strconv.Itoa(outer.closures)
+		}
+	}
+	c.funcsyms[fn] = &funcSym{name: name, pos: pos}
+}
+
+// createFunctionTable creates a table describing each function defined in
+// the module, which the runtime uses to print stack traces, and returns a
+// function that registers it with runtime.addfunctab. The function is
+// returned as a constructor; if the module defines no functions, nil is
+// returned.
+//
+// Each entry holds the function's address, name, and the file and line of
+// its declaration, as the runtime's funcinfo. Functions generated by the
+// compiler have no position, and are given line zero; the C entry points
+// into the program, where stack traces end, are given line -1.
+func (c *compiler) createFunctionTable() Value {
+	intptr := c.target.IntPtrType()
+	stringType := c.types.ToLLVM(types.String)
+	inttype := c.types.ToLLVM(types.Int)
+	entryType := c.context.StructType([]llvm.Type{intptr, stringType, stringType, inttype}, false)
+	entrypoints := make(map[string]bool)
+	for _, name := range c.module.Exports {
+		entrypoints[name] = true
+	}
+	if c.module.Name == "main" {
+		entrypoints["main"] = true
+	}
+
+	var entries []llvm.Value
+	for fn := c.module.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if fn.IsDeclaration() {
+			continue
+		}
+		name, file, line := fn.Name(), "", 0
+		if sym := c.funcsyms[fn]; sym != nil {
+			name = sym.name
+			if sym.pos.IsValid() {
+				position := c.fileset.Position(sym.pos)
+				file, line = position.Filename, position.Line
+			}
+		} else if entrypoints[name] {
+			line = -1
+		}
+		entry := c.context.ConstStruct([]llvm.Value{
+			llvm.ConstPtrToInt(fn, intptr),
+			c.constString(name),
+			c.constString(file),
+			llvm.ConstInt(inttype, uint64(int64(line)), true),
+		}, false)
+		entries = append(entries, entry)
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	table := llvm.ConstArray(entryType, entries)
+	global := llvm.AddGlobal(c.module.Module, table.Type(), "")
+	global.SetInitializer(table)
+	global.SetLinkage(llvm.PrivateLinkage)
+	global.SetGlobalConstant(true)
+
+	fntype := llvm.FunctionType(c.context.VoidType(), nil, false)
+	fn := llvm.AddFunction(c.module.Module, "", fntype)
+	fn.SetLinkage(llvm.PrivateLinkage)
+	entry := c.context.AddBasicBlock(fn, "entry")
+	c.builder.SetInsertPointAtEnd(entry)
+	addfunctab := c.NamedFunction("runtime.addfunctab", "func f(funcs unsafe.Pointer, n int)")
+	funcs := c.builder.CreatePtrToInt(global, intptr, "")
+	n := llvm.ConstInt(inttype, uint64(len(entries)), false)
+	c.builder.CreateCall(addfunctab, []llvm.Value{funcs, n}, "")
+	c.builder.CreateRetVoid()
+	return c.NewLLVMValue(fn, new(types.Func))
+}
+
+// constString returns a constant string value, whose data is held in a
+// private global.
+func (c *compiler) constString(s string) llvm.Value {
+	data := c.context.ConstString(s, false)
+	global := llvm.AddGlobal(c.module.Module, data.Type(), "")
+	global.SetInitializer(data)
+	global.SetLinkage(llvm.PrivateLinkage)
+	global.SetGlobalConstant(true)
+	i8ptr := llvm.PointerType(c.context.Int8Type(), 0)
+	ptr := llvm.ConstBitCast(global, i8ptr)
+	length := llvm.ConstInt(c.target.IntPtrType(), uint64(len(s)), false)
+	return c.context.ConstStruct([]llvm.Value{ptr, length}, false)
+}
+
+// vim: set ft=go :
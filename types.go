@@ -26,7 +26,6 @@ import (
 	"fmt"
 	"github.com/axw/llgo/types"
 	"go/ast"
-	"math/big"
 )
 
 // Get a Type from an ast object.
@@ -47,164 +46,81 @@ func (c *compiler) ObjGetType(obj *ast.Object) types.Type {
 	return nil
 }
 
+// GetType returns the type denoted by a type expression, as recorded by
+// the type checker.
 func (c *compiler) GetType(expr ast.Expr) types.Type {
-	switch x := (expr).(type) {
-	case *ast.Ellipsis:
+	if x, ok := expr.(*ast.Ellipsis); ok {
 		return c.GetType(x.Elt)
-	default:
-		value := c.VisitExpr(expr)
-		return value.(TypeValue).typ
 	}
-	return nil
-}
-
-func (c *compiler) VisitArrayType(a *ast.ArrayType) TypeValue {
-	elttype := c.GetType(a.Elt)
-	if a.Len == nil {
-		return TypeValue{&types.Slice{Elt: elttype}}
+	if !c.info.TypeExprs[expr] {
+		panic(fmt.Sprintf("%T is not a type expression", expr))
 	}
-
-	result := &types.Array{Elt: elttype}
-	_, isellipsis := a.Len.(*ast.Ellipsis)
-	if !isellipsis {
-		lenvalue := c.VisitExpr(a.Len)
-		constval, isconst := lenvalue.(ConstValue)
-		if !isconst {
-			panic("Array length must be a constant integer expression")
-		}
-		intval, isint := (constval.Val).(*big.Int)
-		if !isint {
-			panic("Array length must be a constant integer expression")
-		}
-		result.Len = uint64(intval.Int64())
-	}
-	return TypeValue{result}
+	return c.info.Types[expr]
 }
 
-func (c *compiler) VisitFuncType(f *ast.FuncType) TypeValue {
-	var fn_type types.Func
-
-	if f.Params != nil && len(f.Params.List) > 0 {
-		final_param_type := f.Params.List[len(f.Params.List)-1].Type
-		if _, varargs := final_param_type.(*ast.Ellipsis); varargs {
-			fn_type.IsVariadic = true
-		}
-		for i := 0; i < len(f.Params.List); i++ {
-			namecount := len(f.Params.List[i].Names)
-			typ := c.GetType(f.Params.List[i].Type)
-			if namecount == 0 {
-				arg := ast.NewObj(ast.Var, "_")
-				arg.Type = typ
-				fn_type.Params = append(fn_type.Params, arg)
-			} else {
-				args := make([]*ast.Object, namecount)
-				for j := 0; j < namecount; j++ {
-					ident := f.Params.List[i].Names[j]
-					if ident != nil {
-						args[j] = ident.Obj
-					} else {
-						args[j] = ast.NewObj(ast.Var, "_")
-					}
-					args[j].Type = typ
-				}
-				fn_type.Params = append(fn_type.Params, args...)
+// hasPointers reports whether values of the type t contain pointers, in
+// which case they are copied using the type's copy algorithm rather than
+// as plain memory.
+func hasPointers(t types.Type) bool {
+	switch t := types.Underlying(t).(type) {
+	case *types.Basic:
+		return t.Kind == types.StringKind || t.Kind == types.UnsafePointerKind
+	case *types.Array:
+		return t.Len > 0 && hasPointers(t.Elt)
+	case *types.Struct:
+		for _, f := range t.Fields {
+			if hasPointers(f.Type.(types.Type)) {
+				return true
 			}
 		}
+		return false
+	case *types.Name:
+		// Named basic types.
+		return hasPointers(t.Underlying)
 	}
+	return true
+}
 
-	if f.Results != nil {
-		for i := 0; i < len(f.Results.List); i++ {
-			namecount := len(f.Results.List[i].Names)
-			typ := c.GetType(f.Results.List[i].Type)
-			if namecount > 0 {
-				results := make([]*ast.Object, namecount)
-				for j := 0; j < namecount; j++ {
-					ident := f.Results.List[i].Names[j]
-					if ident != nil {
-						results[j] = ident.Obj
-					} else {
-						results[j] = ast.NewObj(ast.Var, "_")
-					}
-					results[j].Type = typ
-				}
-				fn_type.Results = append(fn_type.Results, results...)
-			} else {
-				result := ast.NewObj(ast.Var, "_")
-				result.Type = typ
-				fn_type.Results = append(fn_type.Results, result)
-			}
-		}
+// basicKind returns the kind of the basic type underlying t, or
+// types.InvalidKind if t is not a basic type.
+func basicKind(t types.Type) types.BasicTypeKind {
+	t = types.Underlying(t)
+	if n, ok := t.(*types.Name); ok {
+		t = n.Underlying
 	}
-
-	return TypeValue{&fn_type}
+	if b, ok := t.(*types.Basic); ok {
+		return b.Kind
+	}
+	return types.InvalidKind
 }
 
-func (c *compiler) VisitStructType(s *ast.StructType) TypeValue {
-	var typ = new(types.Struct)
-	if s.Fields != nil && s.Fields.List != nil {
-		tags := make(map[*ast.Object]string)
-		var i int = 0
-		for _, field := range s.Fields.List {
-			fieldtype := c.GetType(field.Type)
-			if field.Names != nil {
-				for _, name := range field.Names {
-					obj := name.Obj
-					if obj == nil {
-						obj = ast.NewObj(ast.Var, "_")
-					}
-					obj.Type = fieldtype
-					typ.Fields = append(typ.Fields, obj)
-					if field.Tag != nil {
-						tags[obj] = field.Tag.Value
-					}
-				}
-				i += len(field.Names)
-			} else {
-				obj := ast.NewObj(ast.Var, "_")
-				obj.Type = fieldtype
-				typ.Fields = append(typ.Fields, obj)
-				if field.Tag != nil {
-					tags[obj] = field.Tag.Value
-				}
-				i++
-			}
-		}
-
-		typ.Tags = make([]string, len(typ.Fields))
-		for i, field := range typ.Fields {
-			// TODO unquote string?
-			typ.Tags[i] = tags[field]
-		}
+// isUnsigned reports whether the type t is an unsigned integer type.
+func isUnsigned(t types.Type) bool {
+	switch basicKind(t) {
+	case types.UintKind, types.Uint8Kind, types.Uint16Kind,
+		types.Uint32Kind, types.Uint64Kind, types.UintptrKind:
+		return true
 	}
-	return TypeValue{typ}
+	return false
 }
 
-func (c *compiler) VisitInterfaceType(i *ast.InterfaceType) TypeValue {
-	var iface types.Interface
-	if i.Methods != nil && i.Methods.List != nil {
-		for _, field := range i.Methods.List {
-			if field.Names == nil {
-				// If field.Names is nil, then we have an embedded interface.
-				fmt.Println("==nil")
-				embedded := c.GetType(field.Type)
-
-				embedded_iface, isiface := embedded.(*types.Interface)
-				if isiface {
-					iface.Methods = append(iface.Methods,
-						embedded_iface.Methods...)
-				}
-			} else {
-				// TODO
-				fmt.Println("!=nil")
-			}
-		}
+// isInteger reports whether the type t is an integer type.
+func isInteger(t types.Type) bool {
+	switch basicKind(t) {
+	case types.IntKind, types.Int8Kind, types.Int16Kind,
+		types.Int32Kind, types.Int64Kind:
+		return true
 	}
-	return TypeValue{&iface}
+	return isUnsigned(t)
 }
 
-func (c *compiler) VisitMapType(m *ast.MapType) TypeValue {
-	k, v := c.GetType(m.Key), c.GetType(m.Value)
-	return TypeValue{&types.Map{Key: k, Elt: v}}
+// isSliceOf reports whether the type t is a slice whose element type
+// has the specified basic kind, e.g. []byte or []rune.
+func isSliceOf(t types.Type, kind types.BasicTypeKind) bool {
+	if s, ok := types.Underlying(t).(*types.Slice); ok {
+		return basicKind(s.Elt) == kind
+	}
+	return false
 }
 
 // vim: set ft=go :
@@ -23,6 +23,51 @@ type checker struct {
 	errors  scanner.ErrorList
 	types   map[ast.Expr]Type
 	methods map[*ast.Object]ObjList
+
+	// typeExprs, objects and selections are recorded in the Info
+	// returned by Check.
+	typeExprs  map[ast.Expr]bool
+	objects    map[*ast.Ident]*ast.Object
+	selections map[*ast.SelectorExpr]*Selection
+}
+
+// Info holds the results of type checking a package, from which the code
+// generator takes the types of expressions and the objects they denote.
+type Info struct {
+	// Types maps each checked expression to its type. Type expressions
+	// are mapped to the types they denote.
+	Types map[ast.Expr]Type
+
+	// TypeExprs records the expressions that denote types, rather than
+	// values.
+	TypeExprs map[ast.Expr]bool
+
+	// Objects maps each identifier to the object it denotes, including
+	// the selected names of qualified identifiers, fields and methods,
+	// which the parser does not resolve.
+	Objects map[*ast.Ident]*ast.Object
+
+	// Selections maps each selector expression that selects a field or
+	// method of a value to the selection.
+	Selections map[*ast.SelectorExpr]*Selection
+}
+
+// A Selection describes the field or method selected by a selector
+// expression x.f.
+type Selection struct {
+	// Obj is the selected field or method.
+	Obj *ast.Object
+
+	// Index is the path of field indices from the struct type of x, or
+	// the struct type x points to, to the selected field; or, for a
+	// method, to the embedded field whose type has the method. Index is
+	// empty for methods of x's own type, and for interface methods.
+	Index []int
+
+	// Indirect records that the path goes through a pointer, in which
+	// case the selected field or method's receiver is addressable
+	// regardless of x.
+	Indirect bool
 }
 
 func (c *checker) errorf(pos token.Pos, format string, args ...interface{}) string {
@@ -145,6 +190,25 @@ func (c *checker) decomposeRepeatConsts(file *ast.File) {
 	}
 }
 
+// isComparable reports whether values of type t may be compared with
+// == and !=. Structs and arrays are comparable if their fields or elements
+// are; slices, maps and functions are not comparable.
+func isComparable(t Type) bool {
+	switch t := Underlying(t).(type) {
+	case *Slice, *Map, *Func:
+		return false
+	case *Array:
+		return isComparable(t.Elt)
+	case *Struct:
+		for _, f := range t.Fields {
+			if ft, ok := f.Type.(Type); ok && !isComparable(ft) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 // untypedPriority returns an integer priority value that corresponds
 // to the given type's position in the sequence:
 //     integer, character, floating-point, complex.
@@ -198,6 +262,7 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 				x.Name)
 			return &Bad{Msg: msg}
 		}
+		c.objects[x] = obj
 		if obj.Kind == ast.Con && obj.Name == "nil" && obj.Decl == nil {
 			// TODO check assignee is suitable for taking "nil".
 			return &Bad{Msg: "nil typechecking unimplemented"}
@@ -221,15 +286,80 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 
 	case *ast.CompositeLit:
 		// TODO do this properly.
-		typ := c.makeType(x.Type, true)
+		var typ Type
+		if x.Type != nil {
+			typ = c.makeType(x.Type, true)
+		} else {
+			// The type is elided within an enclosing composite literal,
+			// which has recorded the element type.
+			typ = c.types[x]
+			if typ == nil {
+				msg := c.errorf(x.Pos(), "missing type in composite literal")
+				return &Bad{Msg: msg}
+			}
+		}
+
+		// Record the key and element types for nested literals whose
+		// types are elided. An elided element type may be a pointer to
+		// the literal's type, for which &T is elided too.
+		base := typ
+		if t, ok := Underlying(typ).(*Pointer); ok && x.Type == nil {
+			base = t.Base
+		}
+		var keyType, eltType Type
+		switch t := Underlying(base).(type) {
+		case *Array:
+			eltType = t.Elt
+		case *Slice:
+			eltType = t.Elt
+		case *Map:
+			keyType, eltType = t.Key, t.Elt
+		}
+		elided := func(x ast.Expr, t Type) {
+			if lit, ok := x.(*ast.CompositeLit); ok && lit.Type == nil && t != nil {
+				c.types[lit] = t
+			}
+		}
 		for _, elt := range x.Elts {
 			if kv, ok := elt.(*ast.KeyValueExpr); ok {
+				elided(kv.Key, keyType)
+				elided(kv.Value, eltType)
 				c.checkExpr(kv.Key, nil)
 				c.checkExpr(kv.Value, nil)
 			} else {
+				elided(elt, eltType)
 				c.checkExpr(elt, nil)
 			}
 		}
+
+		// Array and slice elements are indexed by constant keys, or follow
+		// on from the preceding element. The length of a [...]T array is
+		// one more than the greatest index.
+		switch t := Underlying(base).(type) {
+		case *Array, *Slice:
+			var index, length int64
+			for _, elt := range x.Elts {
+				if kv, ok := elt.(*ast.KeyValueExpr); ok {
+					key, ok := evalConst(kv.Key).Val.(*big.Int)
+					if !ok || key.Sign() < 0 {
+						msg := c.errorf(kv.Key.Pos(), "index must be non-negative integer constant")
+						return &Bad{Msg: msg}
+					}
+					index = key.Int64()
+				}
+				if t, ok := t.(*Array); ok && !isEllipsisArray(x.Type) && uint64(index) >= t.Len {
+					msg := c.errorf(elt.Pos(), "array index %d out of bounds [0:%d]", index, t.Len)
+					return &Bad{Msg: msg}
+				}
+				index++
+				if index > length {
+					length = index
+				}
+			}
+			if t, ok := t.(*Array); ok && isEllipsisArray(x.Type) {
+				t.Len = uint64(length)
+			}
+		}
 		return typ
 
 	case *ast.BinaryExpr:
@@ -240,8 +370,23 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 		_, xUntyped := xType.(*Basic)
 		_, yUntyped := yType.(*Basic)
 		switch x.Op {
-		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
-			// TODO check the operands are comparable.
+		case token.EQL, token.NEQ:
+			// Slices, maps and functions may only be compared with nil,
+			// which is typed as Bad.
+			_, xBad := xType.(*Bad)
+			_, yBad := yType.(*Bad)
+			if !xBad && !yBad {
+				for _, t := range []Type{xType, yType} {
+					if !isComparable(t) {
+						msg := c.errorf(x.Pos(), "invalid operation: %s cannot be compared", t)
+						return &Bad{Msg: msg}
+					}
+				}
+			}
+			// TODO check when to use untyped bool.
+			return Bool
+		case token.LSS, token.LEQ, token.GTR, token.GEQ:
+			// TODO check the operands are ordered.
 			// TODO check when to use untyped bool.
 			return Bool
 		case token.SHL, token.SHR:
@@ -384,8 +529,10 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 						msg := c.errorf(x.Pos(), "append must be called with a slice type")
 						return &Bad{Msg: msg}
 					}
-					// TODO check elem matches slice element type.
-					c.checkExpr(args[1], nil)
+					// TODO check elems match slice element type.
+					for _, arg := range args[1:] {
+						c.checkExpr(arg, nil)
+					}
 					return s
 				//case "close":
 				//case "complex":
@@ -428,7 +575,11 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 				case "print":
 					// TODO check args are expressions.
 					for _, arg := range args {
-						c.checkExpr(arg, nil)
+						t := c.checkExpr(arg, nil)
+						switch Underlying(t).(type) {
+						case *Array, *Struct:
+							c.errorf(arg.Pos(), "illegal types for operand: %s", x.Name)
+						}
 					}
 					return nil
 				case "imag", "real":
@@ -443,7 +594,7 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 						return &Bad{Msg: msg}
 					}
 				case "panic":
-					// TODO check arg is an expression.
+					c.checkExpr(args[0], nil)
 					return nil
 				//case "recover":
 				default:
@@ -507,6 +658,9 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 				return c.checkExpr(x.Sel, nil)
 			}
 		}
+		if isTypeExpr(x.X) {
+			return c.checkMethodExpr(x)
+		}
 
 		name := x.Sel.Name
 		t := c.checkExpr(x.X, nil)
@@ -516,20 +670,32 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 			})
 			if i < len(iface.Methods) && iface.Methods[i].Name == name {
 				x.Sel.Obj = iface.Methods[i]
+				c.selections[x] = &Selection{Obj: x.Sel.Obj}
 			}
 		} else {
 			// Do a breadth-first search on the type for a method of field.
 			// We don't stop at the first find, but instead we go the full
 			// breadth (but not depth) to ensure no there is no ambiguity in
 			// the selection.
-			curr := []Type{t}
+			//
+			// Each candidate records the path of embedded fields leading
+			// to it, and whether the path goes through a pointer.
+			type candidate struct {
+				typ      Type
+				index    []int
+				indirect bool
+			}
+			_, isptr := Underlying(t).(*Pointer)
+			curr := []candidate{{t, nil, isptr}}
+			sel := c.selections[x] // set if x has been checked before
 			for x.Sel.Obj == nil && len(curr) > 0 {
 				found := 0
-				next := make([]Type, 0)
-				for _, t := range curr {
+				next := make([]candidate, 0)
+				for _, cand := range curr {
 					// Selectors automatically dereference pointers to structs.
 					// We can safely do this here because pointer types may not
 					// be method receivers.
+					t := cand.typ
 					if p, ok := Underlying(t).(*Pointer); ok {
 						if _, ok := Underlying(p.Base).(*Struct); ok {
 							t = p.Base
@@ -542,6 +708,7 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 						})
 						if i < len(n.Methods) && n.Methods[i].Name == name {
 							x.Sel.Obj = n.Methods[i]
+							sel = &Selection{x.Sel.Obj, cand.index, cand.indirect}
 							found++
 						}
 					}
@@ -549,14 +716,17 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 					if t, ok := Underlying(t).(*Struct); ok {
 						if i, ok := t.FieldIndices[name]; ok {
 							x.Sel.Obj = t.Fields[i]
+							sel = &Selection{x.Sel.Obj, appendIndex(cand.index, int(i)), cand.indirect}
 							found++
 						} else {
 							// Add embedded types to the next set of types
 							// to check.
-							for _, field := range t.Fields {
+							for i, field := range t.Fields {
 								if field.Name == "" {
 									c.checkObj(field, false)
-									next = append(next, field.Type.(Type))
+									ftyp := field.Type.(Type)
+									_, isptr := Underlying(ftyp).(*Pointer)
+									next = append(next, candidate{ftyp, appendIndex(cand.index, i), cand.indirect || isptr})
 								}
 							}
 						}
@@ -570,6 +740,25 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 				}
 				curr = next
 			}
+
+			if sel != nil {
+				c.selections[x] = sel
+			}
+
+			// A pointer method may only be called on a value if the
+			// value is addressable, in which case its address is taken
+			// implicitly.
+			indirect := sel != nil && sel.Indirect
+			if obj := x.Sel.Obj; obj != nil && obj.Kind == ast.Fun && !indirect {
+				c.checkObj(obj, false)
+				fn := obj.Type.(*Func)
+				_, ptrrecv := fn.Recv.Type.(*Pointer)
+				if ptrrecv && !c.isAddressable(x.X) {
+					msg := c.errorf(x.Pos(),
+						"cannot call pointer method %s on %s", x.Sel, t)
+					return &Bad{Msg: msg}
+				}
+			}
 		}
 
 		if x.Sel.Obj == nil {
@@ -577,8 +766,14 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 				"failed to resolve selector %s.%s", x.X, x.Sel)
 			return &Bad{Msg: msg}
 		} else {
+			c.objects[x.Sel] = x.Sel.Obj
 			c.checkObj(x.Sel.Obj, false)
-			return x.Sel.Obj.Type.(Type)
+			typ := x.Sel.Obj.Type.(Type)
+			if fn, ok := typ.(*Func); ok && fn.Recv != nil {
+				// A method value is bound to its receiver.
+				typ = &Func{Params: fn.Params, Results: fn.Results, IsVariadic: fn.IsVariadic}
+			}
+			return typ
 		}
 
 	case *ast.IndexExpr:
@@ -671,6 +866,9 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 		if x.High != nil {
 			c.checkExpr(x.High, nil)
 		}
+		if x.Max != nil {
+			c.checkExpr(x.Max, nil)
+		}
 
 		lhs := c.checkExpr(x.X, nil)
 		switch t := Underlying(lhs).(type) {
@@ -685,11 +883,11 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 			return lhs
 		case *Name:
 			if Underlying(t) == Underlying(String) {
-				return lhs
+				return c.checkStringSlice(x, lhs)
 			}
 		case *Basic:
 			if t == String.Underlying {
-				return lhs
+				return c.checkStringSlice(x, lhs)
 			}
 		}
 		msg := c.errorf(x.Pos(), "invalid type for slice expression")
@@ -697,17 +895,49 @@ func (c *checker) checkExpr(x ast.Expr, assignees []*ast.Ident) (typ Type) {
 
 	case *ast.FuncLit:
 		t := c.makeType(x.Type, false)
-		c.checkStmt(x.Body)
+		c.checkFuncBody(x.Body)
 		return t
 	}
 
 	panic(fmt.Sprintf("unreachable (%T)", x))
 }
 
+// checkStringSlice checks a slice expression whose operand is a string,
+// which may not use the 3-index form.
+func (c *checker) checkStringSlice(x *ast.SliceExpr, lhs Type) Type {
+	if x.Max != nil {
+		msg := c.errorf(x.Pos(), "3-index slice of string")
+		return &Bad{Msg: msg}
+	}
+	return lhs
+}
+
+// appendIndex returns a copy of the field index path with i appended, so
+// that the paths of sibling candidates do not share storage.
+func appendIndex(index []int, i int) []int {
+	return append(append([]int(nil), index...), i)
+}
+
+// isEllipsisArray reports whether x is an array type of the form [...]T.
+func isEllipsisArray(x ast.Expr) bool {
+	if t, ok := x.(*ast.ArrayType); ok {
+		_, ok = t.Len.(*ast.Ellipsis)
+		return ok
+	}
+	return false
+}
+
 func evalConst(x ast.Expr) Const {
 	switch x := x.(type) {
 	case *ast.BasicLit:
 		return MakeConst(x.Kind, x.Value)
+	case *ast.ParenExpr:
+		return evalConst(x.X)
+	case *ast.UnaryExpr:
+		return evalConst(x.X).UnaryOp(x.Op)
+	case *ast.BinaryExpr:
+		lhs, rhs := evalConst(x.X).Match(evalConst(x.Y))
+		return lhs.BinaryOp(x.Op, rhs)
 	case *ast.Ident:
 		if x.Obj == nil {
 			panic("x.Obj == nil")
@@ -730,6 +960,104 @@ func evalConst(x ast.Expr) Const {
 // makeType makes a new type for an AST type specification x or returns
 // the type referred to by a type name x. If cycleOk is set, a type may
 // refer to itself directly or indirectly; otherwise cycles are errors.
+// isTypeExpr reports whether x denotes a type, as does the operand of a
+// method expression.
+func isTypeExpr(x ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.Ident:
+		return x.Obj != nil && x.Obj.Kind == ast.Typ
+	case *ast.ParenExpr:
+		return isTypeExpr(x.X)
+	case *ast.StarExpr:
+		return isTypeExpr(x.X)
+	case *ast.SelectorExpr:
+		if ident, ok := x.X.(*ast.Ident); ok && ident.Obj != nil && ident.Obj.Kind == ast.Pkg {
+			obj := ident.Obj.Data.(*ast.Scope).Lookup(x.Sel.Name)
+			return obj != nil && obj.Kind == ast.Typ
+		}
+	}
+	return false
+}
+
+// isAddressable reports whether the checked expression x is addressable:
+// a variable, a pointer indirection, a slice index, or a field selector
+// or array index of an addressable operand. Map index expressions are
+// not addressable.
+func (c *checker) isAddressable(x ast.Expr) bool {
+	switch x := x.(type) {
+	case *ast.Ident:
+		return x.Obj != nil && x.Obj.Kind == ast.Var
+	case *ast.ParenExpr:
+		return c.isAddressable(x.X)
+	case *ast.StarExpr:
+		return true
+	case *ast.SelectorExpr:
+		if x.Sel.Obj == nil || x.Sel.Obj.Kind != ast.Var {
+			return false
+		}
+		if ident, ok := x.X.(*ast.Ident); ok && ident.Obj != nil && ident.Obj.Kind == ast.Pkg {
+			return true
+		}
+		// Fields reached through a pointer are always addressable.
+		if sel := c.selections[x]; sel != nil && sel.Indirect {
+			return true
+		}
+		return c.isAddressable(x.X)
+	case *ast.IndexExpr:
+		switch Underlying(c.types[x.X]).(type) {
+		case *Slice, *Pointer:
+			return true
+		case *Array:
+			return c.isAddressable(x.X)
+		}
+	}
+	return false
+}
+
+// checkMethodExpr checks a method expression T.m or (*T).m, whose type is
+// that of the method with the receiver as the first parameter.
+func (c *checker) checkMethodExpr(x *ast.SelectorExpr) Type {
+	t := c.makeType(x.X, true)
+	name := x.Sel.Name
+	base := t
+	if p, ok := t.(*Pointer); ok {
+		base = p.Base
+	}
+
+	var methods ObjList
+	iface, isiface := Underlying(base).(*Interface)
+	if isiface {
+		methods = iface.Methods
+	} else if n, ok := base.(*Name); ok {
+		methods = n.Methods
+	}
+	i := sort.Search(len(methods), func(i int) bool {
+		return methods[i].Name >= name
+	})
+	if i == len(methods) || methods[i].Name != name || (isiface && base != t) {
+		msg := c.errorf(x.Pos(), "%s has no method %s", x.X, name)
+		return &Bad{Msg: msg}
+	}
+	method := methods[i]
+	c.checkObj(method, false)
+	mtyp := method.Type.(*Func)
+	if !isiface {
+		if _, ptrrecv := mtyp.Recv.Type.(*Pointer); ptrrecv && base == t {
+			msg := c.errorf(x.Pos(),
+				"invalid method expression %s.%s (needs pointer receiver: (*%s).%s)",
+				x.X, name, x.X, name)
+			return &Bad{Msg: msg}
+		}
+	}
+	x.Sel.Obj = method
+	c.objects[x.Sel] = method
+
+	recv := ast.NewObj(ast.Var, "")
+	recv.Type = t
+	params := append(ObjList{recv}, mtyp.Params...)
+	return &Func{Params: params, Results: mtyp.Results, IsVariadic: mtyp.IsVariadic}
+}
+
 func (c *checker) makeType(x ast.Expr, cycleOk bool) (typ Type) {
 	if debug {
 		fmt.Printf("makeType (cycleOk = %v)\n", cycleOk)
@@ -738,6 +1066,12 @@ func (c *checker) makeType(x ast.Expr, cycleOk bool) (typ Type) {
 			fmt.Printf("-> %T %v\n\n", typ, typ)
 		}()
 	}
+	defer func() {
+		if typ != nil {
+			c.types[x] = typ
+			c.typeExprs[x] = true
+		}
+	}()
 
 	switch t := x.(type) {
 	case *ast.BadExpr:
@@ -754,6 +1088,7 @@ func (c *checker) makeType(x ast.Expr, cycleOk bool) (typ Type) {
 			msg := c.errorf(t.Pos(), "%s is not a type", t.Name)
 			return &Bad{Msg: msg}
 		}
+		c.objects[t] = obj
 		c.checkObj(obj, cycleOk)
 		if !cycleOk && obj.Type.(*Name).Underlying == nil {
 			msg := c.errorf(obj.Pos(), "illegal cycle in declaration of %s", obj.Name)
@@ -913,13 +1248,21 @@ func (c *checker) checkStmt(s ast.Stmt) {
 	case *ast.BranchStmt:
 		// no-op
 
+	case *ast.EmptyStmt:
+		// no-op
+
 	case *ast.DeclStmt:
-		// Only a GenDecl/ValueSpec is permissible in a statement.
+		// Only a GenDecl with ValueSpecs or TypeSpecs is permissible in a
+		// statement.
 		decl := s.Decl.(*ast.GenDecl)
 		for _, spec := range decl.Specs {
-			spec := spec.(*ast.ValueSpec)
-			for _, name := range spec.Names {
-				c.checkObj(name.Obj, true)
+			switch spec := spec.(type) {
+			case *ast.ValueSpec:
+				for _, name := range spec.Names {
+					c.checkObj(name.Obj, true)
+				}
+			case *ast.TypeSpec:
+				c.checkObj(spec.Name.Obj, true)
 			}
 		}
 	//case *ast.DeferStmt:
@@ -976,6 +1319,19 @@ func (c *checker) checkStmt(s ast.Stmt) {
 			k, v = Int, x.Elt
 		case *Map:
 			k, v = x.Key, x.Elt
+		case *Name:
+			// The only named basic type supporting range is string.
+			if x.Underlying != String.Underlying {
+				c.errorf(s.Pos(), "invalid type for range")
+				return
+			}
+			k, v = Int, Rune
+		case *Basic:
+			if x != String.Underlying {
+				c.errorf(s.Pos(), "invalid type for range")
+				return
+			}
+			k, v = Int, Rune
 		case *Chan:
 			k = x.Elt
 			if s.Value != nil {
@@ -989,17 +1345,20 @@ func (c *checker) checkStmt(s ast.Stmt) {
 
 		// TODO check key, value are addressable and assignable from range
 		// values.
-		if ident, ok := s.Key.(*ast.Ident); ok && ident.Obj.Type == nil {
-			ident.Obj.Type = k
-		} else {
-			c.checkExpr(s.Key, nil)
+		assign := func(x ast.Expr, t Type) {
+			ident, ok := x.(*ast.Ident)
+			switch {
+			case ok && ident.Name == "_":
+				// the blank identifier discards the value
+			case ok && ident.Obj.Type == nil:
+				ident.Obj.Type = t
+			default:
+				c.checkExpr(x, nil)
+			}
 		}
+		assign(s.Key, k)
 		if s.Value != nil {
-			if ident, ok := s.Value.(*ast.Ident); ok && ident.Obj.Type == nil {
-				ident.Obj.Type = v
-			} else {
-				c.checkExpr(s.Value, nil)
-			}
+			assign(s.Value, v)
 		}
 		c.checkStmt(s.Body)
 
@@ -1061,6 +1420,12 @@ func (c *checker) checkStmt(s ast.Stmt) {
 	}
 }
 
+// checkFuncBody checks the body of a function declaration or literal.
+func (c *checker) checkFuncBody(body *ast.BlockStmt) {
+	c.checkStmt(body)
+	c.checkLabels(body)
+}
+
 // checkObj type checks an object.
 func (c *checker) checkObj(obj *ast.Object, ref bool) {
 	if obj.Type != nil {
@@ -1187,7 +1552,7 @@ func (c *checker) checkObj(obj *ast.Object, ref bool) {
 			// Only check body of non-method functions. We check method
 			// bodies later, to avoid references to incomplete types.
 			if fndecl.Body != nil {
-				c.checkStmt(fndecl.Body)
+				c.checkFuncBody(fndecl.Body)
 			}
 		}
 
@@ -1197,15 +1562,19 @@ func (c *checker) checkObj(obj *ast.Object, ref bool) {
 }
 
 // Check typechecks a package.
-// It augments the AST by assigning types to all ast.Objects and returns a map
-// of types for all expression nodes in statements, and a scanner.ErrorList if
-// there are errors.
+// It augments the AST by assigning types to all ast.Objects and returns the
+// types of all expression nodes in statements, the objects identifiers
+// denote and the selections of selector expressions, and a
+// scanner.ErrorList if there are errors.
 //
-func Check(fset *token.FileSet, pkg *ast.Package) (types map[ast.Expr]Type, err error) {
+func Check(fset *token.FileSet, pkg *ast.Package) (info *Info, err error) {
 	var c checker
 	c.fset = fset
 	c.types = make(map[ast.Expr]Type)
 	c.methods = make(map[*ast.Object]ObjList)
+	c.typeExprs = make(map[ast.Expr]bool)
+	c.objects = make(map[*ast.Ident]*ast.Object)
+	c.selections = make(map[*ast.SelectorExpr]*Selection)
 
 	// Compute sorted list of file names so that
 	// package file iterations are reproducible (needed for testing).
@@ -1225,7 +1594,7 @@ func Check(fset *token.FileSet, pkg *ast.Package) (types map[ast.Expr]Type, err
 		// Check init functions here, since they don't exist in the package scope.
 		for _, decl := range file.Decls {
 			if fdecl, ok := decl.(*ast.FuncDecl); ok && fdecl.Recv == nil && fdecl.Name.Name == "init" {
-				c.checkStmt(fdecl.Body)
+				c.checkFuncBody(fdecl.Body)
 			}
 
 			// Check unnamed var's.
@@ -1250,13 +1619,19 @@ func Check(fset *token.FileSet, pkg *ast.Package) (types map[ast.Expr]Type, err
 	for _, methods := range c.methods {
 		for _, m := range methods {
 			if f := m.Decl.(*ast.FuncDecl); f.Body != nil {
-				c.checkStmt(f.Body)
+				c.checkFuncBody(f.Body)
 			}
 		}
 	}
 
 	c.errors.RemoveMultiples()
-	return c.types, c.errors.Err()
+	info = &Info{
+		Types:      c.types,
+		TypeExprs:  c.typeExprs,
+		Objects:    c.objects,
+		Selections: c.selections,
+	}
+	return info, c.errors.Err()
 }
 
 // vim: set ft=go :
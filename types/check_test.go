@@ -202,6 +202,88 @@ func check(t *testing.T, testname string, testfiles []string) {
 	}
 }
 
+const infoSrc = `package p
+
+type Inner struct{ x int }
+
+func (Inner) M() {}
+
+type Outer struct {
+	y int
+	*Inner
+}
+
+func f(o Outer) {
+	type L []byte
+	_ = o.x
+	_ = o.M
+	_ = L(nil)
+}
+`
+
+// TestCheckInfo checks that Check records the selections of selector
+// expressions, and the types and objects of type expressions, including
+// those of local type declarations.
+func TestCheckInfo(t *testing.T) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "info.go", infoSrc, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]*ast.File{"info.go": file}
+	pkg, err := ast.NewPackage(fset, files, GcImport, Universe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := Check(fset, pkg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]struct {
+		index    []int
+		indirect bool
+	}{
+		"x": {[]int{1, 0}, true},
+		"M": {[]int{1}, true},
+	}
+	for x, sel := range info.Selections {
+		w, ok := want[x.Sel.Name]
+		if !ok {
+			t.Errorf("unexpected selection %s", x.Sel.Name)
+			continue
+		}
+		delete(want, x.Sel.Name)
+		if fmt.Sprint(sel.Index) != fmt.Sprint(w.index) || sel.Indirect != w.indirect {
+			t.Errorf("%s: got index %v, indirect %v; want %v, %v", x.Sel.Name, sel.Index, sel.Indirect, w.index, w.indirect)
+		}
+		if sel.Obj != x.Sel.Obj || info.Objects[x.Sel] != sel.Obj {
+			t.Errorf("%s: selected object not recorded", x.Sel.Name)
+		}
+	}
+	for name := range want {
+		t.Errorf("missing selection %s", name)
+	}
+
+	var conv *ast.CallExpr
+	ast.Inspect(file, func(n ast.Node) bool {
+		if call, ok := n.(*ast.CallExpr); ok {
+			conv = call
+		}
+		return true
+	})
+	if !info.TypeExprs[conv.Fun] {
+		t.Fatalf("conversion type not recorded as a type expression")
+	}
+	name, ok := info.Types[conv.Fun].(*Name)
+	if !ok || name.Obj.Name != "L" || info.Objects[conv.Fun.(*ast.Ident)] != name.Obj {
+		t.Errorf("got %v for the local type; want L", info.Types[conv.Fun])
+	}
+	if _, ok := Underlying(name).(*Slice); !ok {
+		t.Errorf("got underlying type %v for L; want a slice", Underlying(name))
+	}
+}
+
 func TestCheck(t *testing.T) {
 	// For easy debugging w/o changing the testing code,
 	// if there is a local test file, only test that file.
@@ -10,6 +10,7 @@ package types
 
 import (
 	"go/token"
+	"math"
 	"math/big"
 	"strconv"
 )
@@ -128,22 +129,122 @@ func (x Const) Match(y Const) (u, v Const) {
 	return
 }
 
-// Convert attempts to convert the constant x to a given type.
-// If the attempt is successful, the result is the new constant;
-// otherwise the result is invalid.
-func (x Const) Convert(typ *Type) Const {
-	// TODO(gri) implement this
-	/*
-			switch x := x.Val.(type) {
-			case bool:
-			case *big.Int:
-			case *big.Rat:
-			case cmplx:
-			case string:
+// Convert converts the constant x to the representation of constants of
+// the basic type with the given kind, and reports whether x is
+// representable by a value of that type: an integer must be in range, a
+// floating-point number must be finite, and a complex number must have
+// representable parts. intSize is the size of int, uint and uintptr, in
+// bits. Floating-point and complex values are not rounded.
+func (x Const) Convert(kind BasicTypeKind, intSize int) (Const, bool) {
+	switch kind {
+	case BoolKind:
+		_, ok := x.Val.(bool)
+		return x, ok
+	case StringKind:
+		_, ok := x.Val.(string)
+		return x, ok
+	case Float32Kind, Float64Kind:
+		re, ok := x.real()
+		return Const{re}, ok && floatInRange(re, kind)
+	case Complex64Kind, Complex128Kind:
+		var re, im *big.Rat
+		switch v := x.Val.(type) {
+		case cmplx:
+			re, im = v.re, v.im
+		default:
+			var ok bool
+			if re, ok = x.real(); !ok {
+				return x, false
 			}
-		    //panic("unimplemented")
-	*/
-	return x
+			im = big.NewRat(0, 1)
+		}
+		fkind := Float32Kind
+		if kind == Complex128Kind {
+			fkind = Float64Kind
+		}
+		return Const{cmplx{re, im}}, floatInRange(re, fkind) && floatInRange(im, fkind)
+	}
+
+	// The remaining kinds are integers.
+	re, ok := x.real()
+	if !ok || !re.IsInt() {
+		return x, false
+	}
+	var bits int
+	signed := false
+	switch kind {
+	case IntKind:
+		bits, signed = intSize, true
+	case Int8Kind:
+		bits, signed = 8, true
+	case Int16Kind:
+		bits, signed = 16, true
+	case Int32Kind:
+		bits, signed = 32, true
+	case Int64Kind:
+		bits, signed = 64, true
+	case UintKind, UintptrKind, UnsafePointerKind:
+		bits = intSize
+	case Uint8Kind:
+		bits = 8
+	case Uint16Kind:
+		bits = 16
+	case Uint32Kind:
+		bits = 32
+	case Uint64Kind:
+		bits = 64
+	default:
+		return x, false
+	}
+	n := new(big.Int).Set(re.Num())
+	if signed {
+		// -2^(bits-1) <= n < 2^(bits-1)
+		if n.Sign() < 0 {
+			ok = new(big.Int).Not(n).BitLen() < bits
+		} else {
+			ok = n.BitLen() < bits
+		}
+	} else {
+		ok = n.Sign() >= 0 && n.BitLen() <= bits
+	}
+	return Const{n}, ok
+}
+
+// IsInteger reports whether the constant is an integer, or a
+// floating-point or complex number with an integral value.
+func (x Const) IsInteger() bool {
+	re, ok := x.real()
+	return ok && re.IsInt()
+}
+
+// real returns the value of a numeric constant as a big.Rat, and whether
+// it is real: that is, not a complex number with a non-zero imaginary
+// part.
+func (x Const) real() (*big.Rat, bool) {
+	switch v := x.Val.(type) {
+	case *big.Int:
+		return new(big.Rat).SetInt(v), true
+	case *big.Rat:
+		return v, true
+	case cmplx:
+		return v.re, v.im.Sign() == 0
+	}
+	return nil, false
+}
+
+var (
+	maxFloat32 = new(big.Rat).SetFloat64(math.MaxFloat32)
+	maxFloat64 = new(big.Rat).SetFloat64(math.MaxFloat64)
+)
+
+// floatInRange reports whether the magnitude of x is no greater than the
+// largest finite value of the floating-point type with the given kind.
+func floatInRange(x *big.Rat, kind BasicTypeKind) bool {
+	max := maxFloat64
+	if kind == Float32Kind {
+		max = maxFloat32
+	}
+	return new(big.Rat).Abs(x).Cmp(max) <= 0
 }
 
 func (x Const) String() string {
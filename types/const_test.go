@@ -0,0 +1,49 @@
+// Copyright 2012 Andrew Wilkins <axwalk@gmail.com>.
+
+package types
+
+import (
+	"go/token"
+	"testing"
+)
+
+var convertTests = []struct {
+	tok  token.Token
+	lit  string
+	kind BasicTypeKind
+	ok   bool
+}{
+	{token.INT, "127", Int8Kind, true},
+	{token.INT, "128", Int8Kind, false},
+	{token.INT, "-128", Int8Kind, true},
+	{token.INT, "-129", Int8Kind, false},
+	{token.INT, "255", Uint8Kind, true},
+	{token.INT, "256", Uint8Kind, false},
+	{token.INT, "-1", UintKind, false},
+	{token.INT, "2147483647", IntKind, true},
+	{token.INT, "2147483648", IntKind, false},
+	{token.INT, "18446744073709551615", Uint64Kind, true},
+	{token.INT, "18446744073709551616", Uint64Kind, false},
+	{token.INT, "9223372036854775808", Int64Kind, false},
+	{token.FLOAT, "2.0", Int16Kind, true},
+	{token.FLOAT, "2.5", Int16Kind, false},
+	{token.INT, "3", Float32Kind, true},
+	{token.FLOAT, "1e38", Float32Kind, true},
+	{token.FLOAT, "1e39", Float32Kind, false},
+	{token.FLOAT, "1e39", Float64Kind, true},
+	{token.FLOAT, "1e309", Float64Kind, false},
+	{token.IMAG, "2i", Float64Kind, false},
+	{token.IMAG, "1e39i", Complex64Kind, false},
+	{token.IMAG, "1e39i", Complex128Kind, true},
+	{token.STRING, `"s"`, StringKind, true},
+	{token.STRING, `"s"`, IntKind, false},
+}
+
+func TestConvert(t *testing.T) {
+	for _, test := range convertTests {
+		_, ok := MakeConst(test.tok, test.lit).Convert(test.kind, 32)
+		if ok != test.ok {
+			t.Errorf("%s converted to %s: got %v, expected %v", test.lit, test.kind, ok, test.ok)
+		}
+	}
+}
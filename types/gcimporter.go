@@ -276,17 +276,28 @@ func (p *gcParser) expectKeyword(keyword string) {
 // ImportPath = string_lit .
 //
 func (p *gcParser) parsePkgId() *ast.Object {
+	return p.lookupPkg(p.parsePkgPath())
+}
+
+// parsePkgPath parses an ImportPath, and returns the import path of the
+// package it denotes.
+func (p *gcParser) parsePkgPath() string {
 	id, err := strconv.Unquote(p.expect(scanner.String))
 	if err != nil {
 		p.error(err)
 	}
-
-	switch id {
-	case "":
+	if id == "" {
 		// id == "" stands for the imported package id
 		// (only known at time of package installation)
 		id = p.id
-	case "unsafe":
+	}
+	return id
+}
+
+// lookupPkg returns the package object for the import path id, creating
+// it if the package has not been imported.
+func (p *gcParser) lookupPkg(id string) *ast.Object {
+	if id == "unsafe" {
 		// package unsafe is not in the imports map - handle explicitly
 		return Unsafe
 	}
@@ -327,11 +338,18 @@ func (p *gcParser) parseDotIdent() string {
 // ExportedName = "@" ImportPath "." dotIdentifier .
 //
 func (p *gcParser) parseExportedName() (*ast.Object, string) {
+	path, name := p.parseExportedPath()
+	return p.lookupPkg(path), name
+}
+
+// parseExportedPath parses an ExportedName, and returns the import path of
+// the package qualifying it, and the name.
+func (p *gcParser) parseExportedPath() (path, name string) {
 	p.expect('@')
-	pkg := p.parsePkgId()
+	path = p.parsePkgPath()
 	p.expect('.')
-	name := p.parseDotIdent()
-	return pkg, name
+	name = p.parseDotIdent()
+	return
 }
 
 // ----------------------------------------------------------------------------
@@ -375,7 +393,10 @@ func (p *gcParser) parseMapType() Type {
 
 // Name = identifier | "?" | ExportedName  .
 //
-func (p *gcParser) parseName() (name string) {
+// Unexported names are ExportedNames, qualified with the package that
+// declares them; parseName returns its import path, or "" for other names.
+//
+func (p *gcParser) parseName() (path, name string) {
 	switch p.tok {
 	case scanner.Ident:
 		name = p.lit
@@ -385,7 +406,8 @@ func (p *gcParser) parseName() (name string) {
 		p.next()
 	case '@':
 		// exported name prefixed with package path
-		_, name = p.parseExportedName()
+		path, name = p.parseExportedPath()
+		p.lookupPkg(path)
 	default:
 		p.error("name expected")
 	}
@@ -394,8 +416,8 @@ func (p *gcParser) parseName() (name string) {
 
 // Field = Name Type [ string_lit ] .
 //
-func (p *gcParser) parseField() (fld *ast.Object, tag string) {
-	name := p.parseName()
+func (p *gcParser) parseField() (fld *ast.Object, path, tag string) {
+	path, name := p.parseName()
 	ftyp := p.parseType()
 	if name == "" {
 		// anonymous field - ftyp must be T or *T and T must be a type name
@@ -417,10 +439,14 @@ func (p *gcParser) parseField() (fld *ast.Object, tag string) {
 func (p *gcParser) parseStructType() Type {
 	var fields []*ast.Object
 	var tags []string
+	var pkg string
 	indices := make(map[string]uint64)
 
 	parseField := func() {
-		fld, tag := p.parseField()
+		fld, path, tag := p.parseField()
+		if path != "" {
+			pkg = path
+		}
 		fields = append(fields, fld)
 		tags = append(tags, tag)
 		switch fld.Name {
@@ -447,13 +473,13 @@ func (p *gcParser) parseStructType() Type {
 	}
 	p.expect('}')
 
-	return &Struct{Fields: fields, Tags: tags, FieldIndices: indices}
+	return &Struct{Fields: fields, Tags: tags, FieldIndices: indices, Pkg: pkg}
 }
 
 // Parameter = ( identifier | "?" ) [ "..." ] Type [ string_lit ] .
 //
 func (p *gcParser) parseParameter() (par *ast.Object, isVariadic bool) {
-	name := p.parseName()
+	_, name := p.parseName()
 	if name == "" {
 		name = "_" // cannot access unnamed identifiers
 	}
@@ -530,15 +556,15 @@ func (p *gcParser) parseSignature() *Func {
 
 // MethodOrEmbedSpec = Name [ Signature ] .
 //
-func (p *gcParser) parseMethodOrEmbedSpec() *ast.Object {
-	name := p.parseName()
+func (p *gcParser) parseMethodOrEmbedSpec() (obj *ast.Object, path string) {
+	path, name := p.parseName()
 	if p.tok == '(' {
 		obj := ast.NewObj(ast.Fun, name)
 		obj.Type = p.parseSignature()
-		return obj
+		return obj, path
 	}
 	// TODO lookup name and return that type
-	return ast.NewObj(ast.Typ, "_")
+	return ast.NewObj(ast.Typ, "_"), ""
 }
 
 // InterfaceType = "interface" "{" [ MethodOrEmbedList ] "}" .
@@ -546,13 +572,18 @@ func (p *gcParser) parseMethodOrEmbedSpec() *ast.Object {
 //
 func (p *gcParser) parseInterfaceType() Type {
 	var methods ObjList
+	var pkg string
 
 	parseMethod := func() {
-		switch m := p.parseMethodOrEmbedSpec(); m.Kind {
+		m, path := p.parseMethodOrEmbedSpec()
+		switch m.Kind {
 		case ast.Typ:
 			// TODO expand embedded methods
 		case ast.Fun:
 			methods = append(methods, m)
+			if path != "" {
+				pkg = path
+			}
 		}
 	}
 
@@ -568,7 +599,7 @@ func (p *gcParser) parseInterfaceType() Type {
 	p.expect('}')
 
 	methods.Sort()
-	return &Interface{Methods: methods}
+	return &Interface{Methods: methods, Pkg: pkg}
 }
 
 // ChanType = ( "chan" [ "<-" ] | "<-" "chan" ) Type .
@@ -845,7 +876,8 @@ func (p *gcParser) parseMethodDecl() {
 	p.expect(')')
 
 	// unexported method names in imports are qualified with their package.
-	fn := ast.NewObj(ast.Fun, p.parseName())
+	_, name := p.parseName()
+	fn := ast.NewObj(ast.Fun, name)
 	fnType := p.parseSignature()
 	fnType.Recv = recv
 	fn.Type = fnType
@@ -0,0 +1,212 @@
+// Copyright 2012 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// This file implements the checking of labels and branch statements.
+
+package types
+
+import (
+	"go/ast"
+	"go/token"
+)
+
+// labelBlock is a block of statements in a function body. Statements
+// within a block are identified by their index in the block.
+type labelBlock struct {
+	parent *labelBlock
+	index  int   // index of the statement containing the block in parent
+	decls  []int // indices of the statements declaring variables
+}
+
+// label records where a label is defined, and whether it is used.
+type label struct {
+	stmt  *ast.LabeledStmt
+	block *labelBlock
+	index int
+	used  bool
+}
+
+// gotoStmt records where a goto statement occurs.
+type gotoStmt struct {
+	stmt  *ast.BranchStmt
+	block *labelBlock
+	index int
+}
+
+type labelChecker struct {
+	*checker
+	labels map[string]*label
+	order  []*label // labels in order of definition
+	gotos  []gotoStmt
+}
+
+// checkLabels checks the labels and branch statements of a function body.
+// Labels must be used; break and continue statements must refer to the
+// label of an enclosing statement that may be broken out of or continued;
+// and goto statements must not jump into a block, or over a variable
+// declaration.
+func (c *checker) checkLabels(body *ast.BlockStmt) {
+	lc := &labelChecker{checker: c, labels: make(map[string]*label)}
+	lc.blockStmts(&labelBlock{}, body.List, nil)
+
+	for _, g := range lc.gotos {
+		name := g.stmt.Label.Name
+		l := lc.labels[name]
+		if l == nil {
+			c.errorf(g.stmt.Label.Pos(), "label %s not defined", name)
+			continue
+		}
+		l.used = true
+
+		// Find the statement in the label's block that contains the
+		// goto; the label's block must enclose the goto.
+		b, index := g.block, g.index
+		for b != nil && b != l.block {
+			b, index = b.parent, b.index
+		}
+		if b == nil {
+			c.errorf(g.stmt.Label.Pos(), "goto %s jumps into block", name)
+			continue
+		}
+		for _, i := range b.decls {
+			if index < i && i < l.index {
+				c.errorf(g.stmt.Label.Pos(),
+					"goto %s jumps over variable declaration", name)
+				break
+			}
+		}
+	}
+
+	for _, l := range lc.order {
+		if !l.used {
+			c.errorf(l.stmt.Label.Pos(),
+				"label %s defined and not used", l.stmt.Label.Name)
+		}
+	}
+}
+
+// declareLabels records the labels defined in a list of statements,
+// before any statement in the list is checked.
+func (lc *labelChecker) declareLabels(b *labelBlock, list []ast.Stmt) {
+	for i, s := range list {
+		for {
+			ls, ok := s.(*ast.LabeledStmt)
+			if !ok {
+				break
+			}
+			name := ls.Label.Name
+			if name != "_" {
+				if _, ok := lc.labels[name]; ok {
+					lc.errorf(ls.Label.Pos(), "label %s already defined", name)
+				} else {
+					l := &label{stmt: ls, block: b, index: i}
+					lc.labels[name] = l
+					lc.order = append(lc.order, l)
+				}
+			}
+			s = ls.Stmt
+		}
+	}
+}
+
+// blockStmts checks the branch statements in a list of statements, which
+// form the block b. enclosing is the list of labeled statements enclosing
+// the block, innermost last.
+func (lc *labelChecker) blockStmts(b *labelBlock, list []ast.Stmt, enclosing []*ast.LabeledStmt) {
+	lc.declareLabels(b, list)
+	for i, s := range list {
+		lc.stmt(b, i, s, enclosing)
+	}
+}
+
+// stmt checks the branch statements in the statement s, which is at
+// the specified index in block b.
+func (lc *labelChecker) stmt(b *labelBlock, index int, s ast.Stmt, enclosing []*ast.LabeledStmt) {
+	nested := func(list []ast.Stmt) {
+		lc.blockStmts(&labelBlock{parent: b, index: index}, list, enclosing)
+	}
+
+	switch s := s.(type) {
+	case *ast.DeclStmt:
+		if d, ok := s.Decl.(*ast.GenDecl); ok && d.Tok == token.VAR {
+			b.decls = append(b.decls, index)
+		}
+
+	case *ast.AssignStmt:
+		if s.Tok == token.DEFINE {
+			b.decls = append(b.decls, index)
+		}
+
+	case *ast.LabeledStmt:
+		lc.stmt(b, index, s.Stmt, append(enclosing, s))
+
+	case *ast.BranchStmt:
+		if s.Label == nil {
+			return
+		}
+		name := s.Label.Name
+		if s.Tok == token.GOTO {
+			// Labels may be defined after the goto, so the goto is
+			// checked once all labels are known.
+			lc.gotos = append(lc.gotos, gotoStmt{s, b, index})
+			return
+		}
+
+		// The label of a break or continue statement must be that of
+		// an enclosing for, switch or select statement; or for continue,
+		// an enclosing for statement.
+		var target *ast.LabeledStmt
+		for _, ls := range enclosing {
+			if ls.Label.Name == name {
+				target = ls
+			}
+		}
+		valid := false
+		if target != nil {
+			switch target.Stmt.(type) {
+			case *ast.ForStmt, *ast.RangeStmt:
+				valid = true
+			case *ast.SwitchStmt, *ast.TypeSwitchStmt, *ast.SelectStmt:
+				valid = s.Tok == token.BREAK
+			}
+		}
+		if !valid {
+			lc.errorf(s.Label.Pos(), "invalid %s label %s", s.Tok, name)
+			return
+		}
+		lc.labels[name].used = true
+
+	case *ast.BlockStmt:
+		nested(s.List)
+
+	case *ast.IfStmt:
+		nested([]ast.Stmt{s.Body})
+		if s.Else != nil {
+			nested([]ast.Stmt{s.Else})
+		}
+
+	case *ast.CaseClause:
+		nested(s.Body)
+
+	case *ast.CommClause:
+		nested(s.Body)
+
+	case *ast.SwitchStmt:
+		nested(s.Body.List)
+
+	case *ast.TypeSwitchStmt:
+		nested(s.Body.List)
+
+	case *ast.SelectStmt:
+		nested(s.Body.List)
+
+	case *ast.ForStmt:
+		nested(s.Body.List)
+
+	case *ast.RangeStmt:
+		nested(s.Body.List)
+	}
+}
+
+// vim: set ft=go :
@@ -25,6 +25,7 @@ func (k BasicTypeKind) String() string {
 
 // Constants for basic types.
 const (
+	InvalidKind       = BasicTypeKind(reflect.Invalid)
 	BoolKind          = BasicTypeKind(reflect.Bool)
 	IntKind           = BasicTypeKind(reflect.Int)
 	Int8Kind          = BasicTypeKind(reflect.Int8)
@@ -105,6 +106,7 @@ type Struct struct {
 	Fields       ObjList           // struct fields; or nil
 	Tags         []string          // corresponding tags; or nil
 	FieldIndices map[string]uint64 // fast field lookup (name -> index)
+	Pkg          string            // import path qualifying unexported field names; or "" for the package being checked
 	// TODO(gri) This type needs some rethinking:
 	// - at the moment anonymous fields are marked with "" object names,
 	//   and their names have to be reconstructed
@@ -192,6 +194,7 @@ func (f *Func) String() string {
 type Interface struct {
 	ImplementsType
 	Methods ObjList // interface methods sorted by name; or nil
+	Pkg     string  // import path qualifying unexported method names; or "" for the package being checked
 }
 
 func (i *Interface) String() string {
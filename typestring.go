@@ -0,0 +1,170 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package llgo
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/axw/llgo/types"
+	"go/ast"
+)
+
+// typeString returns a string representation of the type, in Go syntax.
+// This is what is stored in runtime type descriptors, and what is
+// reported in runtime error messages.
+func typeString(t types.Type) string {
+	var buf bytes.Buffer
+	writeType(&buf, t, nil)
+	return buf.String()
+}
+
+// A qualifier qualifies the names written by writeType with the packages
+// that declare them, so that types that are not identical are written
+// differently.
+type qualifier interface {
+	// qualifyType returns the name of the named type declared by obj.
+	qualifyType(obj *ast.Object) string
+
+	// qualifyName returns an unexported field or method name, declared
+	// in the package with import path pkg, which is "" for the package
+	// being compiled.
+	qualifyName(pkg, name string) string
+}
+
+// writeName writes a field or method name, declared in the package with
+// import path pkg, qualifying it if it is unexported.
+func writeName(buf *bytes.Buffer, pkg, name string, q qualifier) {
+	if q != nil && !ast.IsExported(name) && name != "_" {
+		name = q.qualifyName(pkg, name)
+	}
+	buf.WriteString(name)
+}
+
+func writeParams(buf *bytes.Buffer, params types.ObjList, isVariadic bool, q qualifier) {
+	buf.WriteByte('(')
+	for i, p := range params {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		if isVariadic && i == len(params)-1 {
+			buf.WriteString("...")
+		}
+		writeType(buf, p.Type.(types.Type), q)
+	}
+	buf.WriteByte(')')
+}
+
+func writeSignature(buf *bytes.Buffer, f *types.Func, q qualifier) {
+	writeParams(buf, f.Params, f.IsVariadic, q)
+	switch len(f.Results) {
+	case 0:
+	case 1:
+		buf.WriteByte(' ')
+		writeType(buf, f.Results[0].Type.(types.Type), q)
+	default:
+		buf.WriteByte(' ')
+		writeParams(buf, f.Results, false, q)
+	}
+}
+
+// writeType writes the Go syntax for t to buf. If q is non-nil, it is used
+// to qualify the names of named types, and unexported field and method
+// names.
+func writeType(buf *bytes.Buffer, t types.Type, q qualifier) {
+	switch t := t.(type) {
+	case *types.Basic:
+		buf.WriteString(t.Kind.String())
+	case *types.Array:
+		fmt.Fprintf(buf, "[%d]", t.Len)
+		writeType(buf, t.Elt, q)
+	case *types.Slice:
+		buf.WriteString("[]")
+		writeType(buf, t.Elt, q)
+	case *types.Struct:
+		if len(t.Fields) == 0 {
+			buf.WriteString("struct {}")
+			break
+		}
+		buf.WriteString("struct { ")
+		for i, f := range t.Fields {
+			if i > 0 {
+				buf.WriteString("; ")
+			}
+			if f.Name != "" && f.Name != "_" {
+				writeName(buf, t.Pkg, f.Name, q)
+				buf.WriteByte(' ')
+			}
+			writeType(buf, f.Type.(types.Type), q)
+			if t.Tags != nil && t.Tags[i] != "" {
+				buf.WriteByte(' ')
+				buf.WriteString(t.Tags[i])
+			}
+		}
+		buf.WriteString(" }")
+	case *types.Pointer:
+		buf.WriteByte('*')
+		writeType(buf, t.Base, q)
+	case *types.Func:
+		buf.WriteString("func")
+		writeSignature(buf, t, q)
+	case *types.Interface:
+		if len(t.Methods) == 0 {
+			buf.WriteString("interface {}")
+			break
+		}
+		buf.WriteString("interface { ")
+		for i, m := range t.Methods {
+			if i > 0 {
+				buf.WriteString("; ")
+			}
+			writeName(buf, t.Pkg, m.Name, q)
+			writeSignature(buf, m.Type.(*types.Func), q)
+		}
+		buf.WriteString(" }")
+	case *types.Map:
+		buf.WriteString("map[")
+		writeType(buf, t.Key, q)
+		buf.WriteByte(']')
+		writeType(buf, t.Elt, q)
+	case *types.Chan:
+		switch t.Dir {
+		case ast.SEND:
+			buf.WriteString("chan<- ")
+		case ast.RECV:
+			buf.WriteString("<-chan ")
+		default:
+			buf.WriteString("chan ")
+		}
+		writeType(buf, t.Elt, q)
+	case *types.Name:
+		if q != nil {
+			buf.WriteString(q.qualifyType(t.Obj))
+		} else {
+			buf.WriteString(t.Obj.Name)
+		}
+	default:
+		fmt.Fprint(buf, t)
+	}
+}
+
+// vim: set ft=go :
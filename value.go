@@ -27,15 +27,9 @@ import (
 	"github.com/axw/gollvm/llvm"
 	"github.com/axw/llgo/types"
 	"go/token"
-	"math"
 	"math/big"
 )
 
-var (
-	maxBigInt32 = big.NewInt(math.MaxInt32)
-	minBigInt32 = big.NewInt(math.MinInt32)
-)
-
 // Value is an interface for representing values returned by Go expressions.
 type Value interface {
 	// BinaryOp applies the specified binary operator to this value and the
@@ -118,6 +112,9 @@ func (lhs *LLVMValue) BinaryOp(op token.Token, rhs_ Value) Value {
 		result := lhs.BinaryOp(token.EQL, rhs_)
 		return result.UnaryOp(token.NOT)
 	}
+	if op == token.SHL || op == token.SHR {
+		return lhs.shift(op, rhs_)
+	}
 
 	var result llvm.Value
 	c := lhs.compiler
@@ -143,47 +140,56 @@ func (lhs *LLVMValue) BinaryOp(op token.Token, rhs_ Value) Value {
 		rhs = c.NewLLVMValue(value.LLVMValue(), value.Type())
 	}
 
+	// value == interface: put the interface on the left.
+	if _, ok := types.Underlying(rhs.typ).(*types.Interface); ok && op == token.EQL {
+		if _, ok := types.Underlying(lhs.typ).(*types.Interface); !ok {
+			return rhs.BinaryOp(op, lhs)
+		}
+	}
+
 	switch typ := types.Underlying(lhs.typ).(type) {
 	case *types.Struct:
-		// TODO check types are the same.
-		element_types_count := lhs.LLVMValue().Type().StructElementTypesCount()
-		struct_fields := typ.Fields
-		if element_types_count > 0 {
-			t := c.ObjGetType(struct_fields[0])
-			first_lhs := c.NewLLVMValue(b.CreateExtractValue(lhs.LLVMValue(), 0, ""), t)
-			first_rhs := c.NewLLVMValue(b.CreateExtractValue(rhs.LLVMValue(), 0, ""), t)
-			first := first_lhs.BinaryOp(op, first_rhs)
-
-			logical_op := token.LAND
-			if op == token.NEQ {
-				logical_op = token.LOR
-			}
-
-			result := first
-			for i := 1; i < element_types_count; i++ {
-				t := c.ObjGetType(struct_fields[i])
-				next_lhs := c.NewLLVMValue(b.CreateExtractValue(lhs.LLVMValue(), i, ""), t)
-				next_rhs := c.NewLLVMValue(b.CreateExtractValue(rhs.LLVMValue(), i, ""), t)
-				next := next_lhs.BinaryOp(op, next_rhs)
-				result = result.BinaryOp(logical_op, next)
-			}
-			return result
+		// Structs are equal if all of their fields are equal. The type
+		// checker ensures that all fields are comparable.
+		lhsValue, rhsValue := lhs.LLVMValue(), rhs.LLVMValue()
+		result := llvm.ConstAllOnes(lhs.compiler.context.Int1Type())
+		for i, f := range typ.Fields {
+			t := c.ObjGetType(f)
+			lhsField := c.NewLLVMValue(b.CreateExtractValue(lhsValue, i, ""), t)
+			rhsField := c.NewLLVMValue(b.CreateExtractValue(rhsValue, i, ""), t)
+			eq := lhsField.BinaryOp(token.EQL, rhsField).LLVMValue()
+			result = b.CreateAnd(result, eq, "")
 		}
+		return c.NewLLVMValue(result, types.Bool)
+
+	case *types.Array:
+		return lhs.compareArrays(rhs)
 
 	case *types.Interface:
 		if rhsisnil {
-			valueNull := b.CreateIsNull(b.CreateExtractValue(lhs.LLVMValue(), 0, ""), "")
-			typeNull := b.CreateIsNull(b.CreateExtractValue(lhs.LLVMValue(), 1, ""), "")
-			result := b.CreateAnd(typeNull, valueNull, "")
-			return c.NewLLVMValue(result, types.Bool)
+			// An interface is nil if its dynamic type is nil; an
+			// interface holding a nil pointer is not nil.
+			typeNull := b.CreateIsNull(b.CreateExtractValue(lhs.LLVMValue(), 0, ""), "")
+			return c.NewLLVMValue(typeNull, types.Bool)
+		}
+		if _, ok := types.Underlying(rhs.typ).(*types.Interface); !ok {
+			// interface == value: convert the value to the interface.
+			rhs = rhs.convertV2I(typ).(*LLVMValue)
 		}
-		// TODO check for interface/interface comparison vs. interface/value comparison.
 		return lhs.compareI2I(rhs)
 
 	case *types.Slice:
 		// []T == nil
 		isnil := b.CreateIsNull(b.CreateExtractValue(lhs.LLVMValue(), 0, ""), "")
 		return c.NewLLVMValue(isnil, types.Bool)
+
+	case *types.Func:
+		// func == nil
+		isnil := b.CreateIsNull(b.CreateExtractValue(lhs.LLVMValue(), 0, ""), "")
+		if op == token.NEQ {
+			isnil = b.CreateNot(isnil, "")
+		}
+		return c.NewLLVMValue(isnil, types.Bool)
 	}
 
 	// Strings.
@@ -218,14 +224,26 @@ func (lhs *LLVMValue) BinaryOp(op token.Token, rhs_ Value) Value {
 		if isfp {
 			result = b.CreateFDiv(lhs.LLVMValue(), rhs.LLVMValue(), "")
 		} else {
-			result = b.CreateUDiv(lhs.LLVMValue(), rhs.LLVMValue(), "")
+			divisor := rhs.LLVMValue()
+			c.divideByZeroCheck(divisor)
+			if isUnsigned(lhs.typ) {
+				result = b.CreateUDiv(lhs.LLVMValue(), divisor, "")
+			} else {
+				result = b.CreateSDiv(lhs.LLVMValue(), divisor, "")
+			}
 		}
 		return lhs.compiler.NewLLVMValue(result, lhs.typ)
 	case token.REM:
 		if isfp {
 			result = b.CreateFRem(lhs.LLVMValue(), rhs.LLVMValue(), "")
 		} else {
-			result = b.CreateURem(lhs.LLVMValue(), rhs.LLVMValue(), "")
+			divisor := rhs.LLVMValue()
+			c.divideByZeroCheck(divisor)
+			if isUnsigned(lhs.typ) {
+				result = b.CreateURem(lhs.LLVMValue(), divisor, "")
+			} else {
+				result = b.CreateSRem(lhs.LLVMValue(), divisor, "")
+			}
 		}
 		return lhs.compiler.NewLLVMValue(result, lhs.typ)
 	case token.ADD:
@@ -242,14 +260,6 @@ func (lhs *LLVMValue) BinaryOp(op token.Token, rhs_ Value) Value {
 			result = b.CreateSub(lhs.LLVMValue(), rhs.LLVMValue(), "")
 		}
 		return lhs.compiler.NewLLVMValue(result, lhs.typ)
-	case token.SHL:
-		rhs = rhs.Convert(lhs.Type()).(*LLVMValue)
-		result = b.CreateShl(lhs.LLVMValue(), rhs.LLVMValue(), "")
-		return lhs.compiler.NewLLVMValue(result, lhs.typ)
-	case token.SHR:
-		rhs = rhs.Convert(lhs.Type()).(*LLVMValue)
-		result = b.CreateAShr(lhs.LLVMValue(), rhs.LLVMValue(), "")
-		return lhs.compiler.NewLLVMValue(result, lhs.typ)
 	case token.NEQ:
 		if isfp {
 			result = b.CreateFCmp(llvm.FloatONE, lhs.LLVMValue(), rhs.LLVMValue(), "")
@@ -268,28 +278,44 @@ func (lhs *LLVMValue) BinaryOp(op token.Token, rhs_ Value) Value {
 		if isfp {
 			result = b.CreateFCmp(llvm.FloatOLT, lhs.LLVMValue(), rhs.LLVMValue(), "")
 		} else {
-			result = b.CreateICmp(llvm.IntULT, lhs.LLVMValue(), rhs.LLVMValue(), "")
+			pred := llvm.IntSLT
+			if isUnsigned(lhs.typ) {
+				pred = llvm.IntULT
+			}
+			result = b.CreateICmp(pred, lhs.LLVMValue(), rhs.LLVMValue(), "")
 		}
 		return lhs.compiler.NewLLVMValue(result, types.Bool)
-	case token.LEQ: // TODO signed/unsigned
+	case token.LEQ:
 		if isfp {
 			result = b.CreateFCmp(llvm.FloatOLE, lhs.LLVMValue(), rhs.LLVMValue(), "")
 		} else {
-			result = b.CreateICmp(llvm.IntULE, lhs.LLVMValue(), rhs.LLVMValue(), "")
+			pred := llvm.IntSLE
+			if isUnsigned(lhs.typ) {
+				pred = llvm.IntULE
+			}
+			result = b.CreateICmp(pred, lhs.LLVMValue(), rhs.LLVMValue(), "")
 		}
 		return lhs.compiler.NewLLVMValue(result, types.Bool)
 	case token.GTR:
 		if isfp {
 			result = b.CreateFCmp(llvm.FloatOGT, lhs.LLVMValue(), rhs.LLVMValue(), "")
 		} else {
-			result = b.CreateICmp(llvm.IntUGT, lhs.LLVMValue(), rhs.LLVMValue(), "")
+			pred := llvm.IntSGT
+			if isUnsigned(lhs.typ) {
+				pred = llvm.IntUGT
+			}
+			result = b.CreateICmp(pred, lhs.LLVMValue(), rhs.LLVMValue(), "")
 		}
 		return lhs.compiler.NewLLVMValue(result, types.Bool)
 	case token.GEQ:
 		if isfp {
 			result = b.CreateFCmp(llvm.FloatOGE, lhs.LLVMValue(), rhs.LLVMValue(), "")
 		} else {
-			result = b.CreateICmp(llvm.IntUGE, lhs.LLVMValue(), rhs.LLVMValue(), "")
+			pred := llvm.IntSGE
+			if isUnsigned(lhs.typ) {
+				pred = llvm.IntUGE
+			}
+			result = b.CreateICmp(pred, lhs.LLVMValue(), rhs.LLVMValue(), "")
 		}
 		return lhs.compiler.NewLLVMValue(result, types.Bool)
 	case token.AND: // a & b
@@ -307,6 +333,112 @@ func (lhs *LLVMValue) BinaryOp(op token.Token, rhs_ Value) Value {
 	panic("unreachable")
 }
 
+// compareArrays compares two arrays of the same type element by element,
+// returning true if all elements are equal. The comparison is performed
+// in a loop, which exits at the first unequal element.
+func (lhs *LLVMValue) compareArrays(rhs *LLVMValue) *LLVMValue {
+	c := lhs.compiler
+	b := c.builder
+	typ := types.Underlying(lhs.typ).(*types.Array)
+	if typ.Len == 0 {
+		return c.NewLLVMValue(llvm.ConstAllOnes(lhs.compiler.context.Int1Type()), types.Bool)
+	}
+
+	// Put the arrays in memory so their elements may be indexed
+	// dynamically.
+	arrayptr := func(v *LLVMValue) llvm.Value {
+		if v.pointer != nil {
+			return v.pointer.LLVMValue()
+		}
+		ptr := c.createEntryAlloca(c.types.ToLLVM(typ), "")
+		b.CreateStore(v.LLVMValue(), ptr)
+		return ptr
+	}
+	lhsptr, rhsptr := arrayptr(lhs), arrayptr(rhs)
+
+	intType := c.target.IntPtrType()
+	zero := llvm.ConstNull(intType)
+	length := llvm.ConstInt(intType, typ.Len, false)
+	entryBlock := b.GetInsertBlock()
+	doneBlock := lhs.compiler.context.InsertBasicBlock(entryBlock, "")
+	doneBlock.MoveAfter(entryBlock)
+	nextBlock := lhs.compiler.context.InsertBasicBlock(doneBlock, "")
+	loopBlock := lhs.compiler.context.InsertBasicBlock(nextBlock, "")
+	b.CreateBr(loopBlock)
+
+	// Compare the elements at the current index, exiting the loop if
+	// they differ.
+	b.SetInsertPointAtEnd(loopBlock)
+	index := b.CreatePHI(intType, "")
+	indices := []llvm.Value{zero, index}
+	lhsElem := c.NewLLVMValue(b.CreateGEP(lhsptr, indices, ""), &types.Pointer{Base: typ.Elt}).makePointee()
+	rhsElem := c.NewLLVMValue(b.CreateGEP(rhsptr, indices, ""), &types.Pointer{Base: typ.Elt}).makePointee()
+	eq := lhsElem.BinaryOp(token.EQL, rhsElem).LLVMValue()
+	eqBlock := b.GetInsertBlock() // element comparison may create blocks
+	b.CreateCondBr(eq, nextBlock, doneBlock)
+
+	// Advance to the next index, exiting the loop at the end of the array.
+	b.SetInsertPointAtEnd(nextBlock)
+	nextIndex := b.CreateAdd(index, llvm.ConstInt(intType, 1, false), "")
+	more := b.CreateICmp(llvm.IntULT, nextIndex, length, "")
+	b.CreateCondBr(more, loopBlock, doneBlock)
+	index.AddIncoming([]llvm.Value{zero, nextIndex}, []llvm.BasicBlock{entryBlock, nextBlock})
+
+	b.SetInsertPointAtEnd(doneBlock)
+	result := b.CreatePHI(lhs.compiler.context.Int1Type(), "")
+	result.AddIncoming(
+		[]llvm.Value{llvm.ConstNull(lhs.compiler.context.Int1Type()), llvm.ConstAllOnes(lhs.compiler.context.Int1Type())},
+		[]llvm.BasicBlock{eqBlock, nextBlock})
+	return c.NewLLVMValue(result, types.Bool)
+}
+
+// shift shifts lhs left or right by the unsigned count in rhs_. LLVM's
+// shift instructions are undefined if the count is at least the width of
+// the operand, whereas Go defines the result to be zero, or -1 for a right
+// shift of a negative signed integer.
+func (lhs *LLVMValue) shift(op token.Token, rhs_ Value) *LLVMValue {
+	c := lhs.compiler
+	b := c.builder
+	var count llvm.Value
+	switch rhs_ := rhs_.(type) {
+	case ConstValue:
+		count = rhs_.Convert(types.Uint).LLVMValue()
+	default:
+		count = rhs_.LLVMValue()
+	}
+
+	// Compare the count against the operand's width before converting
+	// it to the operand's type, as truncating it may change its value.
+	lhsval := lhs.LLVMValue()
+	lhstype := lhsval.Type()
+	bits := lhstype.IntTypeWidth()
+	width := llvm.ConstInt(count.Type(), uint64(bits), false)
+	toolarge := b.CreateICmp(llvm.IntUGE, count, width, "")
+	switch countbits := count.Type().IntTypeWidth(); {
+	case countbits < bits:
+		count = b.CreateZExt(count, lhstype, "")
+	case countbits > bits:
+		count = b.CreateTrunc(count, lhstype, "")
+	}
+
+	var result llvm.Value
+	zero := llvm.ConstNull(lhstype)
+	switch {
+	case op == token.SHL:
+		result = b.CreateShl(lhsval, count, "")
+		result = b.CreateSelect(toolarge, zero, result, "")
+	case isUnsigned(lhs.typ):
+		result = b.CreateLShr(lhsval, count, "")
+		result = b.CreateSelect(toolarge, zero, result, "")
+	default:
+		// Shifting right by width-1 yields the sign bit in every bit.
+		maxcount := llvm.ConstInt(lhstype, uint64(bits-1), false)
+		count = b.CreateSelect(toolarge, maxcount, count, "")
+		result = b.CreateAShr(lhsval, count, "")
+	}
+	return c.NewLLVMValue(result, lhs.typ)
+}
+
 func (v *LLVMValue) UnaryOp(op token.Token) Value {
 	b := v.compiler.builder
 	switch op {
@@ -367,7 +499,7 @@ func (v *LLVMValue) Convert(dst_typ types.Type) Value {
 		if interface_, isinterface := dst_typ.(*types.Interface); isinterface {
 			return v.convertI2I(interface_)
 		} else {
-			return v.convertI2V(dst_typ)
+			return v.mustTypeAssert(orig_dst_typ)
 		}
 	}
 
@@ -376,32 +508,24 @@ func (v *LLVMValue) Convert(dst_typ types.Type) Value {
 		return v.convertV2I(interface_)
 	}
 
-	// string -> []byte
-	byteslice := &types.Slice{Elt: types.Byte}
-	if src_typ == types.String && types.Identical(dst_typ, byteslice) {
-		c := v.compiler
-		value := v.LLVMValue()
-		strdata := c.builder.CreateExtractValue(value, 0, "")
-		strlen := c.builder.CreateExtractValue(value, 1, "")
-		struct_ := llvm.Undef(c.types.ToLLVM(byteslice))
-		struct_ = c.builder.CreateInsertValue(struct_, strdata, 0, "")
-		struct_ = c.builder.CreateInsertValue(struct_, strlen, 1, "")
-		struct_ = c.builder.CreateInsertValue(struct_, strlen, 2, "")
-		return c.NewLLVMValue(struct_, byteslice)
-	}
-	// []byte -> string
-	if types.Identical(src_typ, byteslice) && dst_typ == types.String {
-		c := v.compiler
-		value := v.LLVMValue()
-		data := c.builder.CreateExtractValue(value, 0, "")
-		len := c.builder.CreateExtractValue(value, 1, "")
-		struct_ := llvm.Undef(c.types.ToLLVM(types.String))
-		struct_ = c.builder.CreateInsertValue(struct_, data, 0, "")
-		struct_ = c.builder.CreateInsertValue(struct_, len, 1, "")
-		return c.NewLLVMValue(struct_, types.String)
+	// string -> []byte, []rune
+	if src_typ == types.String {
+		if isSliceOf(dst_typ, types.Uint8Kind) || isSliceOf(dst_typ, types.Int32Kind) {
+			return v.compiler.stringToSlice(v, orig_dst_typ)
+		}
+	}
+
+	if dst_typ == types.String {
+		// []byte, []rune -> string
+		if isSliceOf(src_typ, types.Uint8Kind) || isSliceOf(src_typ, types.Int32Kind) {
+			return v.compiler.sliceToString(v, orig_dst_typ)
+		}
+		// integer -> string
+		if isInteger(src_typ) {
+			return v.compiler.intToString(v, orig_dst_typ)
+		}
 	}
 
-	// TODO other special conversions, e.g. int->string.
 	llvm_type := v.compiler.types.ToLLVM(dst_typ)
 
 	// Unsafe pointer conversions.
@@ -434,8 +558,11 @@ func (v *LLVMValue) Convert(dst_typ types.Type) Value {
 			delta := srcBits - dstBits
 			switch {
 			case delta < 0:
-				// TODO check if (un)signed, use S/ZExt accordingly.
-				lv = v.compiler.builder.CreateZExt(lv, llvm_type, "")
+				if isUnsigned(src_typ) {
+					lv = v.compiler.builder.CreateZExt(lv, llvm_type, "")
+				} else {
+					lv = v.compiler.builder.CreateSExt(lv, llvm_type, "")
+				}
 			case delta > 0:
 				lv = v.compiler.builder.CreateTrunc(lv, llvm_type, "")
 			}
@@ -508,11 +635,16 @@ func (lhs ConstValue) BinaryOp(op token.Token, rhs_ Value) Value {
 		return lhs_.BinaryOp(op, rhs)
 
 	case ConstValue:
-		// TODO Check if either one is untyped, and convert to the
-		// other's type.
 		// TODO use type from typechecking here.
 		c := lhs.compiler
 		var typ types.Type = lhs.typ
+		if _, ok := lhs.typ.(*types.Basic); ok && op != token.SHL && op != token.SHR {
+			// An untyped operand takes the type of a typed operand;
+			// the result of a shift has the type of its left operand.
+			if _, ok := rhs.typ.(*types.Basic); !ok {
+				typ = rhs.typ
+			}
+		}
 
 		switch op {
 		case token.EQL, token.NEQ, token.LSS, token.LEQ, token.GTR, token.GEQ:
@@ -522,13 +654,56 @@ func (lhs ConstValue) BinaryOp(op token.Token, rhs_ Value) Value {
 		}
 
 		a, b := lhs.Const.Match(rhs.Const)
-		return ConstValue{a.BinaryOp(op, b), c, typ}
+		result := ConstValue{a.BinaryOp(op, b), c, typ}
+		if _, ok := typ.(*types.Basic); !ok {
+			result.Const = result.representable(typ)
+		}
+		return result
 	}
 	panic("unimplemented")
 }
 
 func (v ConstValue) UnaryOp(op token.Token) Value {
-	return ConstValue{v.Const.UnaryOp(op), v.compiler, v.typ}
+	if _, ok := v.typ.(*types.Basic); ok {
+		return ConstValue{v.Const.UnaryOp(op), v.compiler, v.typ}
+	}
+	result := v
+	if op == token.XOR && isUnsigned(v.typ) {
+		// The complement of a typed unsigned constant is taken within
+		// the bits of its type, as ^x is x ^ m for unsigned x, where m
+		// has every bit set.
+		c := v.compiler
+		bits := c.target.TypeSizeInBits(c.types.ToLLVM(v.typ))
+		m := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+		m.Sub(m, big.NewInt(1))
+		x, _ := v.Const.Convert(types.Uint64Kind, 64)
+		result.Const = types.Const{new(big.Int).Xor(x.Val.(*big.Int), m)}
+	} else {
+		result.Const = v.Const.UnaryOp(op)
+	}
+	result.Const = result.representable(v.typ)
+	return result
+}
+
+// representable converts the constant to the representation of constants
+// of typ, whose underlying type is a basic type, and panics if the
+// constant is not representable by a value of typ, as when it overflows
+// an integer type or is not integral.
+func (v ConstValue) representable(typ types.Type) types.Const {
+	intSize := int(v.compiler.target.PointerSize()) * 8
+	x, ok := v.Const.Convert(basicKind(typ), intSize)
+	if ok {
+		return x
+	}
+	name := typ.(*types.Name).Obj.Name
+	switch v.Val.(type) {
+	case bool, string:
+		panic(fmt.Sprintf("cannot convert %s to type %s", v.Const, name))
+	}
+	if isInteger(typ) && !v.Const.IsInteger() {
+		panic(fmt.Sprintf("constant %s truncated to integer", v.Const))
+	}
+	panic(fmt.Sprintf("constant %s overflows %s", v.Const, name))
 }
 
 func (v ConstValue) Convert(dstTyp types.Type) Value {
@@ -543,8 +718,17 @@ func (v ConstValue) Convert(dstTyp types.Type) Value {
 		}
 
 		compiler := v.compiler
+		if x, ok := v.Val.(*big.Int); ok && dstTyp == types.String {
+			// Integer constants convert to the UTF-8 encoding of the
+			// corresponding rune; invalid runes become U+FFFD.
+			r := '\uFFFD'
+			if x.Sign() >= 0 && x.BitLen() <= 31 {
+				r = rune(x.Int64())
+			}
+			return ConstValue{types.Const{string(r)}, compiler, origDstTyp}
+		}
 		if isBasic {
-			return ConstValue{v.Const.Convert(&dstTyp), compiler, origDstTyp}
+			return ConstValue{v.representable(origDstTyp), compiler, origDstTyp}
 		} else {
 			return compiler.NewLLVMValue(v.LLVMValue(), v.Type()).Convert(origDstTyp)
 			//panic(fmt.Errorf("unhandled conversion from %v to %v", v.typ, dstTyp))
@@ -557,42 +741,43 @@ func (v ConstValue) Convert(dstTyp types.Type) Value {
 
 func (v ConstValue) LLVMValue() llvm.Value {
 	typ := types.Underlying(v.Type())
+	if name, ok := typ.(*types.Name); ok {
+		if _, ok := name.Underlying.(*types.Basic); ok {
+			v.Const = v.representable(v.Type())
+		}
+	}
 	switch typ {
-	case types.Int, types.Uint:
-		return llvm.ConstInt(llvm.Int32Type(), uint64(v.Int64()), true)
-		// TODO 32/64bit (probably wait for gc)
-		//int_val := v.Val.(*big.Int)
-		//if int_val.Cmp(maxBigInt32) > 0 || int_val.Cmp(minBigInt32) < 0 {
-		//	panic(fmt.Sprint("const ", int_val, " overflows int"))
-		//}
-		//return llvm.ConstInt(v.compiler.target.IntPtrType(), uint64(v.Int64()), true)
+	case types.Int:
+		inttype := v.compiler.target.IntPtrType()
+		return llvm.ConstInt(inttype, uint64(v.Int64()), true)
 	case types.Uint:
-		return llvm.ConstInt(llvm.Int32Type(), uint64(v.Int64()), false)
+		inttype := v.compiler.target.IntPtrType()
+		return llvm.ConstInt(inttype, uint64(v.Int64()), false)
 
 	case types.Int8:
-		return llvm.ConstInt(llvm.Int8Type(), uint64(v.Int64()), true)
+		return llvm.ConstInt(v.compiler.context.Int8Type(), uint64(v.Int64()), true)
 	case types.Uint8, types.Byte:
-		return llvm.ConstInt(llvm.Int8Type(), uint64(v.Int64()), false)
+		return llvm.ConstInt(v.compiler.context.Int8Type(), uint64(v.Int64()), false)
 
 	case types.Int16:
-		return llvm.ConstInt(llvm.Int16Type(), uint64(v.Int64()), true)
+		return llvm.ConstInt(v.compiler.context.Int16Type(), uint64(v.Int64()), true)
 	case types.Uint16:
-		return llvm.ConstInt(llvm.Int16Type(), uint64(v.Int64()), false)
+		return llvm.ConstInt(v.compiler.context.Int16Type(), uint64(v.Int64()), false)
 
 	case types.Int32, types.Rune:
-		return llvm.ConstInt(llvm.Int32Type(), uint64(v.Int64()), true)
+		return llvm.ConstInt(v.compiler.context.Int32Type(), uint64(v.Int64()), true)
 	case types.Uint32:
-		return llvm.ConstInt(llvm.Int32Type(), uint64(v.Int64()), false)
+		return llvm.ConstInt(v.compiler.context.Int32Type(), uint64(v.Int64()), false)
 
 	case types.Int64:
-		return llvm.ConstInt(llvm.Int64Type(), uint64(v.Int64()), true)
+		return llvm.ConstInt(v.compiler.context.Int64Type(), uint64(v.Int64()), true)
 	case types.Uint64:
-		return llvm.ConstInt(llvm.Int64Type(), uint64(v.Int64()), true)
+		return llvm.ConstInt(v.compiler.context.Int64Type(), uint64(v.Int64()), true)
 
 	case types.Float32:
-		return llvm.ConstFloat(llvm.FloatType(), float64(v.Float64()))
+		return llvm.ConstFloat(v.compiler.context.FloatType(), float64(v.Float64()))
 	case types.Float64:
-		return llvm.ConstFloat(llvm.DoubleType(), float64(v.Float64()))
+		return llvm.ConstFloat(v.compiler.context.DoubleType(), float64(v.Float64()))
 
 	case types.UnsafePointer, types.Uintptr:
 		inttype := v.compiler.target.IntPtrType()
@@ -601,14 +786,14 @@ func (v ConstValue) LLVMValue() llvm.Value {
 	case types.String:
 		strval := (v.Val).(string)
 		ptr := v.compiler.builder.CreateGlobalStringPtr(strval, "")
-		len_ := llvm.ConstInt(llvm.Int32Type(), uint64(len(strval)), false)
-		return llvm.ConstStruct([]llvm.Value{ptr, len_}, false)
+		len_ := llvm.ConstInt(v.compiler.target.IntPtrType(), uint64(len(strval)), false)
+		return v.compiler.context.ConstStruct([]llvm.Value{ptr, len_}, false)
 
 	case types.Bool:
-		if v := v.Val.(bool); v {
-			return llvm.ConstAllOnes(llvm.Int1Type())
+		if b := v.Val.(bool); b {
+			return llvm.ConstAllOnes(v.compiler.context.Int1Type())
 		}
-		return llvm.ConstNull(llvm.Int1Type())
+		return llvm.ConstNull(v.compiler.context.Int1Type())
 	}
 	panic(fmt.Errorf("Unhandled type: %v", typ)) //v.typ.Kind))
 }
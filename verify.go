@@ -0,0 +1,86 @@
+/*
+Copyright (c) 2012 Andrew Wilkins <axwalk@gmail.com>
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of
+this software and associated documentation files (the "Software"), to deal in
+the Software without restriction, including without limitation the rights to
+use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies
+of the Software, and to permit persons to whom the Software is furnished to do
+so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package llgo
+
+import (
+	"github.com/axw/gollvm/llvm"
+	"go/token"
+	"strings"
+)
+
+// verifyFunction verifies the LLVM IR of a function that has just been
+// built, if verification is enabled. Invalid IR is reported as an error at
+// the position of the Go function that produced it, with the verifier's
+// messages. Only the first invalid function is reported.
+func (c *compiler) verifyFunction(fn llvm.Value) {
+	if !c.verify || c.verifyFailed {
+		return
+	}
+	if llvm.VerifyFunction(fn, llvm.ReturnStatusAction) == nil {
+		return
+	}
+	// The verifier only describes the problem when verifying a whole
+	// module, which is incomplete while enclosing functions are being
+	// built.
+	msg := "verification failed"
+	if len(c.functions) == 0 {
+		if err := llvm.VerifyModule(c.module.Module, llvm.ReturnStatusAction); err != nil {
+			msg = strings.TrimSpace(err.Error())
+		}
+	}
+	c.reportInvalidFunction(fn, msg)
+}
+
+// verifyModule verifies the LLVM IR of the whole module, if verification
+// is enabled, attributing any invalid IR to the functions that contain it.
+func (c *compiler) verifyModule() {
+	if !c.verify || c.verifyFailed {
+		return
+	}
+	err := llvm.VerifyModule(c.module.Module, llvm.ReturnStatusAction)
+	if err == nil {
+		return
+	}
+	msg := strings.TrimSpace(err.Error())
+	for fn := c.module.FirstFunction(); !fn.IsNil(); fn = llvm.NextFunction(fn) {
+		if !fn.IsDeclaration() && llvm.VerifyFunction(fn, llvm.ReturnStatusAction) != nil {
+			c.reportInvalidFunction(fn, msg)
+			return
+		}
+	}
+	c.errorf(token.NoPos, "internal compiler error: invalid LLVM IR generated for package %s: %s", c.module.Name, msg)
+	c.verifyFailed = true
+}
+
+// reportInvalidFunction reports that the compiler generated invalid IR
+// for fn, naming the Go function it was built for where it is known.
+func (c *compiler) reportInvalidFunction(fn llvm.Value, msg string) {
+	c.verifyFailed = true
+	name, pos := fn.Name(), token.NoPos
+	if sym := c.funcsyms[fn]; sym != nil {
+		name, pos = sym.name, sym.pos
+	}
+	c.errorf(pos, "internal compiler error: invalid LLVM IR generated for %s: %s", name, msg)
+}
+
+// vim: set ft=go :